@@ -6,7 +6,10 @@ import (
 	"os"
 	"plandex/auth"
 	"plandex/types"
+	"plandex/version"
 	"time"
+
+	"github.com/plandex/plandex/shared"
 )
 
 const dialTimeout = 10 * time.Second
@@ -33,6 +36,8 @@ func init() {
 func getApiHost() string {
 	if auth.Current == nil {
 		return ""
+	} else if auth.Current.IsLocal {
+		return auth.MustGetLocalHost()
 	} else if auth.Current.IsCloud {
 		return cloudApiHost
 	} else {
@@ -47,6 +52,17 @@ type authenticatedTransport struct {
 // RoundTrip executes a single HTTP transaction and adds a custom header
 func (t *authenticatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	auth.SetAuthHeader(req)
+	req.Header.Set(shared.ClientVersionHeader, version.Version)
+	return t.underlyingTransport.RoundTrip(req)
+}
+
+// versionTransport lets the server record which CLI version a session was created from, including for sign-in requests that aren't authenticated yet
+type versionTransport struct {
+	underlyingTransport http.RoundTripper
+}
+
+func (t *versionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set(shared.ClientVersionHeader, version.Version)
 	return t.underlyingTransport.RoundTrip(req)
 }
 
@@ -55,8 +71,10 @@ var netDialer = &net.Dialer{
 }
 
 var unauthenticatedClient = &http.Client{
-	Transport: &http.Transport{
-		Dial: netDialer.Dial,
+	Transport: &versionTransport{
+		underlyingTransport: &http.Transport{
+			Dial: netDialer.Dial,
+		},
 	},
 	Timeout: fastReqTimeout,
 }
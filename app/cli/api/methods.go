@@ -197,6 +197,41 @@ func (a *Api) ListPlans(projectIds []string) ([]*shared.Plan, *shared.ApiError)
 	return plans, nil
 }
 
+func (a *Api) ListSharedPlans(projectIds []string) ([]*shared.SharedPlan, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/shared?", getApiHost())
+	parts := []string{}
+	for _, projectId := range projectIds {
+		parts = append(parts, fmt.Sprintf("projectId=%s", projectId))
+	}
+	serverUrl += strings.Join(parts, "&")
+
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+
+		didRefresh, apiErr := refreshTokenIfNeeded(apiErr)
+		if didRefresh {
+			return a.ListSharedPlans(projectIds)
+		}
+		return nil, apiErr
+	}
+
+	var plans []*shared.SharedPlan
+	err = json.NewDecoder(resp.Body).Decode(&plans)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return plans, nil
+}
+
 func (a *Api) ListArchivedPlans(projectIds []string) ([]*shared.Plan, *shared.ApiError) {
 	serverUrl := fmt.Sprintf("%s/plans/archive?", getApiHost())
 	parts := []string{}
@@ -444,6 +479,7 @@ func (a *Api) TellPlan(planId, branch string, req shared.TellPlanRequest, onStre
 
 	var client *http.Client
 	if req.ConnectStream {
+		request.Header.Set("Accept", shared.EVENT_STREAM_CONTENT_TYPE)
 		client = authenticatedStreamingClient
 	} else {
 		client = authenticatedFastClient
@@ -468,7 +504,7 @@ func (a *Api) TellPlan(planId, branch string, req shared.TellPlanRequest, onStre
 
 	if req.ConnectStream {
 		log.Println("Connecting stream")
-		connectPlanRespStream(resp.Body, onStream)
+		connectPlanRespStream(planId, branch, resp, onStream)
 	} else {
 		// log.Println("Background exec - not connecting stream")
 		resp.Body.Close()
@@ -495,6 +531,7 @@ func (a *Api) BuildPlan(planId, branch string, req shared.BuildPlanRequest, onSt
 
 	var client *http.Client
 	if req.ConnectStream {
+		request.Header.Set("Accept", shared.EVENT_STREAM_CONTENT_TYPE)
 		client = authenticatedStreamingClient
 	} else {
 		client = authenticatedFastClient
@@ -521,7 +558,7 @@ func (a *Api) BuildPlan(planId, branch string, req shared.BuildPlanRequest, onSt
 
 	if req.ConnectStream {
 		log.Println("Connecting stream")
-		connectPlanRespStream(resp.Body, onStream)
+		connectPlanRespStream(planId, branch, resp, onStream)
 	} else {
 		// log.Println("Background exec - not connecting stream")
 		resp.Body.Close()
@@ -566,16 +603,32 @@ func (a *Api) RespondMissingFile(planId, branch string, req shared.RespondMissin
 }
 
 func (a *Api) ConnectPlan(planId, branch string, onStream types.OnStreamPlan) *shared.ApiError {
+	resp, apiErr := connectPlanStreamRequest(planId, branch, "")
+	if apiErr != nil {
+		return apiErr
+	}
+
+	connectPlanRespStream(planId, branch, resp, onStream)
+
+	return nil
+}
+
+// connectPlanStreamRequest opens a plan's stream. lastEventId, if set, resumes a dropped stream after the last message received.
+func connectPlanStreamRequest(planId, branch, lastEventId string) (*http.Response, *shared.ApiError) {
 	serverUrl := fmt.Sprintf("%s/plans/%s/%s/connect", getApiHost(), planId, branch)
 
 	req, err := http.NewRequest(http.MethodPatch, serverUrl, nil)
 	if err != nil {
-		return &shared.ApiError{Msg: fmt.Sprintf("error creating request: %v", err)}
+		return nil, &shared.ApiError{Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+	req.Header.Set("Accept", shared.EVENT_STREAM_CONTENT_TYPE)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
 	}
 
 	resp, err := authenticatedStreamingClient.Do(req)
 	if err != nil {
-		return &shared.ApiError{Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 
 	if resp.StatusCode >= 400 {
@@ -585,15 +638,13 @@ func (a *Api) ConnectPlan(planId, branch string, onStream types.OnStreamPlan) *s
 		didRefresh, apiErr := refreshTokenIfNeeded(apiErr)
 
 		if didRefresh {
-			return a.ConnectPlan(planId, branch, onStream)
+			return connectPlanStreamRequest(planId, branch, lastEventId)
 		}
 
-		return apiErr
+		return nil, apiErr
 	}
 
-	connectPlanRespStream(resp.Body, onStream)
-
-	return nil
+	return resp, nil
 }
 
 func (a *Api) StopPlan(planId, branch string) *shared.ApiError {
@@ -691,6 +742,34 @@ func (a *Api) ApplyPlan(planId, branch string, req shared.ApplyPlanRequest) (str
 	return string(responseData), nil
 }
 
+func (a *Api) UnapplyPlan(planId, branch string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/unapply", getApiHost(), planId, branch)
+
+	req, err := http.NewRequest(http.MethodPatch, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+
+		didRefresh, apiErr := refreshTokenIfNeeded(apiErr)
+		if didRefresh {
+			return a.UnapplyPlan(planId, branch)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
 func (a *Api) ArchivePlan(planId string) *shared.ApiError {
 	serverUrl := fmt.Sprintf("%s/plans/%s/archive", getApiHost(), planId)
 
@@ -747,6 +826,62 @@ func (a *Api) UnarchivePlan(planId string) *shared.ApiError {
 	return nil
 }
 
+func (a *Api) SharePlan(planId, branch string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/share", getApiHost(), planId, branch)
+
+	req, err := http.NewRequest(http.MethodPatch, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+
+		didRefresh, apiErr := refreshTokenIfNeeded(apiErr)
+		if didRefresh {
+			return a.SharePlan(planId, branch)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) UnsharePlan(planId, branch string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/unshare", getApiHost(), planId, branch)
+
+	req, err := http.NewRequest(http.MethodPatch, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+
+		didRefresh, apiErr := refreshTokenIfNeeded(apiErr)
+		if didRefresh {
+			return a.UnsharePlan(planId, branch)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
 func (a *Api) RenamePlan(planId string, name string) *shared.ApiError {
 	serverUrl := fmt.Sprintf("%s/plans/%s/rename", getApiHost(), planId)
 
@@ -1107,14 +1242,13 @@ func (a *Api) GetPlanDiffs(planId, branch string) (string, *shared.ApiError) {
 	return string(body), nil
 }
 
-func (a *Api) ListLogs(planId, branch string) (*shared.LogResponse, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/plans/%s/%s/logs", getApiHost(), planId, branch)
+func (a *Api) ExportPatches(planId, branch string) (*shared.ExportPatchesResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/patches", getApiHost(), planId, branch)
 
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
-
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
@@ -1122,71 +1256,61 @@ func (a *Api) ListLogs(planId, branch string) (*shared.LogResponse, *shared.ApiE
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListLogs(planId, branch)
+			return a.ExportPatches(planId, branch)
 		}
 		return nil, apiErr
 	}
 
-	var logs shared.LogResponse
-	err = json.NewDecoder(resp.Body).Decode(&logs)
+	var res shared.ExportPatchesResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &logs, nil
+	return &res, nil
 }
 
-func (a *Api) RewindPlan(planId, branch string, req shared.RewindPlanRequest) (*shared.RewindPlanResponse, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/plans/%s/%s/rewind", getApiHost(), planId, branch)
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
-	}
-
-	request, err := http.NewRequest(http.MethodPatch, serverUrl, bytes.NewBuffer(reqBytes))
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+func (a *Api) ListReviewComments(planId, branch string, all bool) ([]*shared.ReviewComment, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/review_comments", getApiHost(), planId, branch)
+	if all {
+		serverUrl += "?all=true"
 	}
-	request.Header.Set("Content-Type", "application/json")
 
-	resp, err := authenticatedFastClient.Do(request)
+	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
-
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.RewindPlan(planId, branch, req)
+			return a.ListReviewComments(planId, branch, all)
 		}
 		return nil, apiErr
 	}
 
-	var rewindPlanResponse shared.RewindPlanResponse
-	err = json.NewDecoder(resp.Body).Decode(&rewindPlanResponse)
+	var comments []*shared.ReviewComment
+	err = json.NewDecoder(resp.Body).Decode(&comments)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &rewindPlanResponse, nil
+	return comments, nil
 }
 
-func (a *Api) SignIn(req shared.SignInRequest, customHost string) (*shared.SessionResponse, *shared.ApiError) {
-	host := customHost
-	if host == "" {
-		host = cloudApiHost
-	}
-	serverUrl := host + "/accounts/sign_in"
+func (a *Api) CreateReviewComment(planId, branch string, req shared.CreateReviewCommentRequest) (*shared.ReviewComment, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/review_comments", getApiHost(), planId, branch)
+
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
 	}
 
-	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
@@ -1194,90 +1318,133 @@ func (a *Api) SignIn(req shared.SignInRequest, customHost string) (*shared.Sessi
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.CreateReviewComment(planId, branch, req)
+		}
 		return nil, apiErr
 	}
 
-	var sessionResponse shared.SessionResponse
-	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	var comment shared.ReviewComment
+	err = json.NewDecoder(resp.Body).Decode(&comment)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &sessionResponse, nil
+	return &comment, nil
 }
 
-func (a *Api) CreateAccount(req shared.CreateAccountRequest, customHost string) (*shared.SessionResponse, *shared.ApiError) {
-	host := customHost
-	if host == "" {
-		host = cloudApiHost
-	}
-	serverUrl := host + "/accounts"
+func (a *Api) ResolveReviewComments(planId, branch string, req shared.ResolveReviewCommentsRequest) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/review_comments/resolve", getApiHost(), planId, branch)
+
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
 	}
 
-	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	httpReq, err := http.NewRequest(http.MethodPatch, serverUrl, bytes.NewBuffer(reqBytes))
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(httpReq)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
-		return nil, apiErr
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ResolveReviewComments(planId, branch, req)
+		}
+		return apiErr
 	}
 
-	var sessionResponse shared.SessionResponse
-	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	return nil
+}
+
+func (a *Api) DeleteReviewComment(planId, branch, commentId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/review_comments/%s", getApiHost(), planId, branch, commentId)
+
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
 
-	return &sessionResponse, nil
-}
-
-func (a *Api) ConvertTrial(req shared.ConvertTrialRequest) (*shared.SessionResponse, *shared.ApiError) {
-	serverUrl := getApiHost() + "/accounts/convert_trial"
-	reqBytes, err := json.Marshal(req)
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
+	defer resp.Body.Close()
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.DeleteReviewComment(planId, branch, commentId)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) ListLogs(planId, branch string) (*shared.LogResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/logs", getApiHost(), planId, branch)
+
+	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
+
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
 		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListLogs(planId, branch)
+		}
 		return nil, apiErr
 	}
 
-	var sessionResponse shared.SessionResponse
-	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	var logs shared.LogResponse
+	err = json.NewDecoder(resp.Body).Decode(&logs)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &sessionResponse, nil
+	return &logs, nil
 }
 
-func (a *Api) CreateOrg(req shared.CreateOrgRequest) (*shared.CreateOrgResponse, *shared.ApiError) {
-	serverUrl := getApiHost() + "/orgs"
+func (a *Api) RewindPlan(planId, branch string, req shared.RewindPlanRequest) (*shared.RewindPlanResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/rewind", getApiHost(), planId, branch)
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
 	}
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	request, err := http.NewRequest(http.MethodPatch, serverUrl, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(request)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
+
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
@@ -1285,21 +1452,238 @@ func (a *Api) CreateOrg(req shared.CreateOrgRequest) (*shared.CreateOrgResponse,
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.CreateOrg(req)
+			return a.RewindPlan(planId, branch, req)
 		}
 		return nil, apiErr
 	}
 
-	var createOrgResponse shared.CreateOrgResponse
-	err = json.NewDecoder(resp.Body).Decode(&createOrgResponse)
+	var rewindPlanResponse shared.RewindPlanResponse
+	err = json.NewDecoder(resp.Body).Decode(&rewindPlanResponse)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &createOrgResponse, nil
+	return &rewindPlanResponse, nil
 }
 
-func (a *Api) GetOrgSession() *shared.ApiError {
+func (a *Api) SignIn(req shared.SignInRequest, customHost string) (*shared.SessionResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts/sign_in"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var sessionResponse shared.SessionResponse
+	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &sessionResponse, nil
+}
+
+func (a *Api) GetSsoConfig(customHost string) (*shared.SsoConfigResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts/sso"
+
+	resp, err := unauthenticatedClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	// servers from before sso was added
+	if resp.StatusCode == http.StatusNotFound {
+		return &shared.SsoConfigResponse{}, nil
+	}
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var res shared.SsoConfigResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &res, nil
+}
+
+func (a *Api) StartSso(customHost string) (*shared.StartSsoResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts/sso/start"
+
+	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", nil)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var res shared.StartSsoResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &res, nil
+}
+
+func (a *Api) PollSso(req shared.PollSsoRequest, customHost string) (*shared.PollSsoResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts/sso/poll"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var res shared.PollSsoResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &res, nil
+}
+
+func (a *Api) CreateAccount(req shared.CreateAccountRequest, customHost string) (*shared.SessionResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var sessionResponse shared.SessionResponse
+	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &sessionResponse, nil
+}
+
+func (a *Api) ConvertTrial(req shared.ConvertTrialRequest) (*shared.SessionResponse, *shared.ApiError) {
+	serverUrl := getApiHost() + "/accounts/convert_trial"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		return nil, apiErr
+	}
+
+	var sessionResponse shared.SessionResponse
+	err = json.NewDecoder(resp.Body).Decode(&sessionResponse)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &sessionResponse, nil
+}
+
+func (a *Api) CreateOrg(req shared.CreateOrgRequest) (*shared.CreateOrgResponse, *shared.ApiError) {
+	serverUrl := getApiHost() + "/orgs"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.CreateOrg(req)
+		}
+		return nil, apiErr
+	}
+
+	var createOrgResponse shared.CreateOrgResponse
+	err = json.NewDecoder(resp.Body).Decode(&createOrgResponse)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &createOrgResponse, nil
+}
+
+func (a *Api) GetOrgSession() *shared.ApiError {
 	serverUrl := getApiHost() + "/orgs/session"
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
@@ -1309,15 +1693,418 @@ func (a *Api) GetOrgSession() *shared.ApiError {
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
-		apiErr := handleApiError(resp, errorBody)
-		return apiErr
+		apiErr := handleApiError(resp, errorBody)
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) ListOrgs() ([]*shared.Org, *shared.ApiError) {
+	serverUrl := getApiHost() + "/orgs"
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListOrgs()
+		}
+		return nil, apiErr
+	}
+
+	var orgs []*shared.Org
+	err = json.NewDecoder(resp.Body).Decode(&orgs)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return orgs, nil
+}
+
+func (a *Api) DeleteUser(userId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/orgs/users/%s", getApiHost(), userId)
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.DeleteUser(userId)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) ListOrgRoles() ([]*shared.OrgRole, *shared.ApiError) {
+	serverUrl := getApiHost() + "/orgs/roles"
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListOrgRoles()
+		}
+		return nil, apiErr
+	}
+
+	var roles []*shared.OrgRole
+	err = json.NewDecoder(resp.Body).Decode(&roles)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+	}
+
+	return roles, nil
+}
+
+func (a *Api) CreateOrgRole(req shared.CreateOrgRoleRequest) (*shared.OrgRole, *shared.ApiError) {
+	serverUrl := getApiHost() + "/orgs/roles"
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.CreateOrgRole(req)
+		}
+		return nil, apiErr
+	}
+
+	var role shared.OrgRole
+	err = json.NewDecoder(resp.Body).Decode(&role)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &role, nil
+}
+
+func (a *Api) UpdateOrgRole(roleId string, req shared.UpdateOrgRoleRequest) (*shared.OrgRole, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/roles/%s", getApiHost(), roleId)
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(request)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.UpdateOrgRole(roleId, req)
+		}
+		return nil, apiErr
+	}
+
+	var role shared.OrgRole
+	err = json.NewDecoder(resp.Body).Decode(&role)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &role, nil
+}
+
+func (a *Api) DeleteOrgRole(roleId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/orgs/roles/%s", getApiHost(), roleId)
+
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.DeleteOrgRole(roleId)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) SetOrgUserRole(userId string, req shared.SetOrgUserRoleRequest) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/orgs/users/%s/role", getApiHost(), userId)
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(request)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.SetOrgUserRole(userId, req)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) InviteUser(req shared.InviteRequest) *shared.ApiError {
+	serverUrl := getApiHost() + "/invites"
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.InviteUser(req)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) ListPendingInvites() ([]*shared.Invite, *shared.ApiError) {
+	serverUrl := getApiHost() + "/invites/pending"
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListPendingInvites()
+		}
+		return nil, apiErr
+	}
+
+	var invites []*shared.Invite
+	err = json.NewDecoder(resp.Body).Decode(&invites)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return invites, nil
+}
+
+func (a *Api) ListAcceptedInvites() ([]*shared.Invite, *shared.ApiError) {
+	serverUrl := getApiHost() + "/invites/accepted"
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListAcceptedInvites()
+		}
+		return nil, apiErr
+	}
+
+	var invites []*shared.Invite
+	err = json.NewDecoder(resp.Body).Decode(&invites)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return invites, nil
+}
+
+func (a *Api) ListAllInvites() ([]*shared.Invite, *shared.ApiError) {
+	serverUrl := getApiHost() + "/invites/all"
+	resp, err := authenticatedFastClient.Get(serverUrl)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListAllInvites()
+		}
+		return nil, apiErr
+	}
+
+	var invites []*shared.Invite
+	err = json.NewDecoder(resp.Body).Decode(&invites)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return invites, nil
+}
+
+func (a *Api) DeleteInvite(inviteId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/invites/%s", getApiHost(), inviteId)
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		apiErr := handleApiError(resp, errorBody)
+
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.DeleteInvite(inviteId)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) CreateEmailVerification(email, customHost, userId string) (*shared.CreateEmailVerificationResponse, *shared.ApiError) {
+	host := customHost
+	if host == "" {
+		host = cloudApiHost
+	}
+	serverUrl := host + "/accounts/email_verifications"
+	req := shared.CreateEmailVerificationRequest{Email: email, UserId: userId}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+	}
+
+	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return nil, handleApiError(resp, errorBody)
+	}
+
+	var verificationResponse shared.CreateEmailVerificationResponse
+	err = json.NewDecoder(resp.Body).Decode(&verificationResponse)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &verificationResponse, nil
+}
+
+func (a *Api) SignOut() *shared.ApiError {
+	serverUrl := getApiHost() + "/accounts/sign_out"
+
+	req, err := http.NewRequest(http.MethodPost, serverUrl, nil)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+	}
+
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return handleApiError(resp, errorBody)
 	}
 
 	return nil
 }
 
-func (a *Api) ListOrgs() ([]*shared.Org, *shared.ApiError) {
-	serverUrl := getApiHost() + "/orgs"
+func (a *Api) ListUsers() (*shared.ListUsersResponse, *shared.ApiError) {
+	serverUrl := getApiHost() + "/users"
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
@@ -1329,51 +2116,60 @@ func (a *Api) ListOrgs() ([]*shared.Org, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListOrgs()
+			return a.ListUsers()
 		}
 		return nil, apiErr
 	}
 
-	var orgs []*shared.Org
-	err = json.NewDecoder(resp.Body).Decode(&orgs)
+	var r *shared.ListUsersResponse
+	err = json.NewDecoder(resp.Body).Decode(&r)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return orgs, nil
+	return r, nil
 }
 
-func (a *Api) DeleteUser(userId string) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/orgs/users/%s", getApiHost(), userId)
-	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
-	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
-	}
+func (a *Api) ListBranches(planId string) ([]*shared.Branch, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/branches", getApiHost(), planId)
 
-	resp, err := authenticatedFastClient.Do(req)
+	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.DeleteUser(userId)
+			return a.ListBranches(planId)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var branches []*shared.Branch
+	err = json.NewDecoder(resp.Body).Decode(&branches)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+	}
+
+	return branches, nil
 }
 
-func (a *Api) ListOrgRoles() ([]*shared.OrgRole, *shared.ApiError) {
-	serverUrl := getApiHost() + "/orgs/roles"
-	resp, err := authenticatedFastClient.Get(serverUrl)
+func (a *Api) CreateBranch(planId, branch string, req shared.CreateBranchRequest) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/branches", getApiHost(), planId, branch)
+
+	reqBytes, err := json.Marshal(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1383,40 +2179,35 @@ func (a *Api) ListOrgRoles() ([]*shared.OrgRole, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListOrgRoles()
+			return a.CreateBranch(planId, branch, req)
 		}
-		return nil, apiErr
-	}
-
-	var roles []*shared.OrgRole
-	err = json.NewDecoder(resp.Body).Decode(&roles)
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return apiErr
 	}
 
-	return roles, nil
+	return nil
 }
 
-func (a *Api) InviteUser(req shared.InviteRequest) *shared.ApiError {
-	serverUrl := getApiHost() + "/invites"
-	reqBytes, err := json.Marshal(req)
+func (a *Api) DeleteBranch(planId, branch string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/plans/%s/branches/%s", getApiHost(), planId, branch)
+
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
 	}
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
-		apiErr := handleApiError(resp, errorBody)
 
+		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.InviteUser(req)
+			return a.DeleteBranch(planId, branch)
 		}
 		return apiErr
 	}
@@ -1424,11 +2215,13 @@ func (a *Api) InviteUser(req shared.InviteRequest) *shared.ApiError {
 	return nil
 }
 
-func (a *Api) ListPendingInvites() ([]*shared.Invite, *shared.ApiError) {
-	serverUrl := getApiHost() + "/invites/pending"
+func (a *Api) GetSettings(planId, branch string) (*shared.PlanSettings, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/settings", getApiHost(), planId, branch)
+
 	resp, err := authenticatedFastClient.Get(serverUrl)
+
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1437,52 +2230,70 @@ func (a *Api) ListPendingInvites() ([]*shared.Invite, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListPendingInvites()
+			return a.GetSettings(planId, branch)
 		}
 		return nil, apiErr
 	}
 
-	var invites []*shared.Invite
-	err = json.NewDecoder(resp.Body).Decode(&invites)
+	var settings shared.PlanSettings
+	err = json.NewDecoder(resp.Body).Decode(&settings)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
 	}
 
-	return invites, nil
+	return &settings, nil
 }
 
-func (a *Api) ListAcceptedInvites() ([]*shared.Invite, *shared.ApiError) {
-	serverUrl := getApiHost() + "/invites/accepted"
-	resp, err := authenticatedFastClient.Get(serverUrl)
+func (a *Api) UpdateSettings(planId, branch string, req shared.UpdateSettingsRequest) (*shared.UpdateSettingsResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/plans/%s/%s/settings", getApiHost(), planId, branch)
+
+	reqBytes, err := json.Marshal(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
+	}
+
+	// log.Println("UpdateSettings", string(reqBytes))
+
+	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(request)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListAcceptedInvites()
+			return a.UpdateSettings(planId, branch, req)
 		}
 		return nil, apiErr
 	}
 
-	var invites []*shared.Invite
-	err = json.NewDecoder(resp.Body).Decode(&invites)
+	var updateRes shared.UpdateSettingsResponse
+	err = json.NewDecoder(resp.Body).Decode(&updateRes)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
 	}
 
-	return invites, nil
+	return &updateRes, nil
+
 }
 
-func (a *Api) ListAllInvites() ([]*shared.Invite, *shared.ApiError) {
-	serverUrl := getApiHost() + "/invites/all"
+func (a *Api) GetOrgDefaultSettings() (*shared.PlanSettings, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/default_settings", getApiHost())
+
 	resp, err := authenticatedFastClient.Get(serverUrl)
+
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1491,60 +2302,93 @@ func (a *Api) ListAllInvites() ([]*shared.Invite, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListAllInvites()
+			return a.GetOrgDefaultSettings()
 		}
 		return nil, apiErr
 	}
 
-	var invites []*shared.Invite
-	err = json.NewDecoder(resp.Body).Decode(&invites)
+	var settings shared.PlanSettings
+	err = json.NewDecoder(resp.Body).Decode(&settings)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
 	}
 
-	return invites, nil
+	return &settings, nil
 }
 
-func (a *Api) DeleteInvite(inviteId string) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/invites/%s", getApiHost(), inviteId)
-	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+func (a *Api) UpdateOrgDefaultSettings(req shared.UpdateSettingsRequest) (*shared.UpdateSettingsResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/default_settings", getApiHost())
+
+	reqBytes, err := json.Marshal(req)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
 	}
 
-	resp, err := authenticatedFastClient.Do(req)
+	// log.Println("UpdateSettings", string(reqBytes))
+
+	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := authenticatedFastClient.Do(request)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
-		apiErr := handleApiError(resp, errorBody)
 
+		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.DeleteInvite(inviteId)
+			return a.UpdateOrgDefaultSettings(req)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var updateRes shared.UpdateSettingsResponse
+	err = json.NewDecoder(resp.Body).Decode(&updateRes)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+	}
+
+	return &updateRes, nil
+
 }
 
-func (a *Api) CreateEmailVerification(email, customHost, userId string) (*shared.CreateEmailVerificationResponse, *shared.ApiError) {
-	host := customHost
-	if host == "" {
-		host = cloudApiHost
+func (a *Api) CreateCustomModel(model *shared.AvailableModel) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/custom_models", getApiHost())
+	body, err := json.Marshal(model)
+	if err != nil {
+		return &shared.ApiError{Msg: "Failed to marshal model"}
 	}
-	serverUrl := host + "/accounts/email_verifications"
-	req := shared.CreateEmailVerificationRequest{Email: email, UserId: userId}
-	reqBytes, err := json.Marshal(req)
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
+	defer resp.Body.Close()
 
-	resp, err := unauthenticatedClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.CreateCustomModel(model)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) ListCustomModels() ([]*shared.AvailableModel, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/custom_models", getApiHost())
+	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
@@ -1552,22 +2396,27 @@ func (a *Api) CreateEmailVerification(email, customHost, userId string) (*shared
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
-		return nil, handleApiError(resp, errorBody)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.ListCustomModels()
+		}
+		return nil, apiErr
 	}
 
-	var verificationResponse shared.CreateEmailVerificationResponse
-	err = json.NewDecoder(resp.Body).Decode(&verificationResponse)
+	var models []*shared.AvailableModel
+	err = json.NewDecoder(resp.Body).Decode(&models)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &verificationResponse, nil
+	return models, nil
 }
 
-func (a *Api) SignOut() *shared.ApiError {
-	serverUrl := getApiHost() + "/accounts/sign_out"
-
-	req, err := http.NewRequest(http.MethodPost, serverUrl, nil)
+func (a *Api) DeleteAvailableModel(modelId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/custom_models/%s", getApiHost(), modelId)
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
 		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
@@ -1580,45 +2429,52 @@ func (a *Api) SignOut() *shared.ApiError {
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
-		return handleApiError(resp, errorBody)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.DeleteAvailableModel(modelId)
+		}
+		return apiErr
 	}
 
 	return nil
 }
 
-func (a *Api) ListUsers() (*shared.ListUsersResponse, *shared.ApiError) {
-	serverUrl := getApiHost() + "/users"
-	resp, err := authenticatedFastClient.Get(serverUrl)
+func (a *Api) CreateModelPack(set *shared.ModelPack) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/model_sets", getApiHost())
+	body, err := json.Marshal(set)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return &shared.ApiError{Msg: "Failed to marshal model pack"}
+	}
+
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListUsers()
+			return a.CreateModelPack(set)
 		}
-		return nil, apiErr
+		return apiErr
 	}
 
-	var r *shared.ListUsersResponse
-	err = json.NewDecoder(resp.Body).Decode(&r)
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
-	}
+	return nil
 
-	return r, nil
 }
 
-func (a *Api) ListBranches(planId string) ([]*shared.Branch, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/plans/%s/branches", getApiHost(), planId)
+func (a *Api) ListModelPacks() ([]*shared.ModelPack, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/model_sets", getApiHost())
 
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1628,31 +2484,32 @@ func (a *Api) ListBranches(planId string) ([]*shared.Branch, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListBranches(planId)
+			return a.ListModelPacks()
 		}
 		return nil, apiErr
 	}
 
-	var branches []*shared.Branch
-	err = json.NewDecoder(resp.Body).Decode(&branches)
+	var sets []*shared.ModelPack
+	err = json.NewDecoder(resp.Body).Decode(&sets)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return branches, nil
+	return sets, nil
+
 }
 
-func (a *Api) CreateBranch(planId, branch string, req shared.CreateBranchRequest) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/plans/%s/%s/branches", getApiHost(), planId, branch)
+func (a *Api) DeleteModelPack(setId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/model_sets/%s", getApiHost(), setId)
 
-	reqBytes, err := json.Marshal(req)
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(reqBytes))
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1662,7 +2519,7 @@ func (a *Api) CreateBranch(planId, branch string, req shared.CreateBranchRequest
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.CreateBranch(planId, branch, req)
+			return a.DeleteModelPack(setId)
 		}
 		return apiErr
 	}
@@ -1670,17 +2527,16 @@ func (a *Api) CreateBranch(planId, branch string, req shared.CreateBranchRequest
 	return nil
 }
 
-func (a *Api) DeleteBranch(planId, branch string) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/plans/%s/branches/%s", getApiHost(), planId, branch)
-
-	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+func (a *Api) CreateAccessToken(req shared.CreateAccessTokenRequest) (*shared.CreateAccessTokenResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/access_tokens", getApiHost())
+	body, err := json.Marshal(req)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
 	}
 
-	resp, err := authenticatedFastClient.Do(req)
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1690,62 +2546,60 @@ func (a *Api) DeleteBranch(planId, branch string) *shared.ApiError {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.DeleteBranch(planId, branch)
+			return a.CreateAccessToken(req)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var res shared.CreateAccessTokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &res, nil
 }
 
-func (a *Api) GetSettings(planId, branch string) (*shared.PlanSettings, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/plans/%s/%s/settings", getApiHost(), planId, branch)
+func (a *Api) ListAccessTokens() ([]*shared.AccessToken, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/access_tokens", getApiHost())
 
 	resp, err := authenticatedFastClient.Get(serverUrl)
-
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.GetSettings(planId, branch)
+			return a.ListAccessTokens()
 		}
 		return nil, apiErr
 	}
 
-	var settings shared.PlanSettings
-	err = json.NewDecoder(resp.Body).Decode(&settings)
+	var tokens []*shared.AccessToken
+	err = json.NewDecoder(resp.Body).Decode(&tokens)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &settings, nil
+	return tokens, nil
 }
 
-func (a *Api) UpdateSettings(planId, branch string, req shared.UpdateSettingsRequest) (*shared.UpdateSettingsResponse, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/plans/%s/%s/settings", getApiHost(), planId, branch)
-
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
-	}
-
-	// log.Println("UpdateSettings", string(reqBytes))
+func (a *Api) RevokeAccessToken(tokenId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/access_tokens/%s", getApiHost(), tokenId)
 
-	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
-	request.Header.Set("Content-Type", "application/json")
 
-	resp, err := authenticatedFastClient.Do(request)
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1755,69 +2609,82 @@ func (a *Api) UpdateSettings(planId, branch string, req shared.UpdateSettingsReq
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.UpdateSettings(planId, branch, req)
+			return a.RevokeAccessToken(tokenId)
 		}
-		return nil, apiErr
-	}
-
-	var updateRes shared.UpdateSettingsResponse
-	err = json.NewDecoder(resp.Body).Decode(&updateRes)
-	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return apiErr
 	}
 
-	return &updateRes, nil
-
+	return nil
 }
 
-func (a *Api) GetOrgDefaultSettings() (*shared.PlanSettings, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/default_settings", getApiHost())
+func (a *Api) ListSessions() ([]*shared.Session, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/accounts/sessions", getApiHost())
 
 	resp, err := authenticatedFastClient.Get(serverUrl)
-
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		errorBody, _ := io.ReadAll(resp.Body)
+
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.GetOrgDefaultSettings()
+			return a.ListSessions()
 		}
 		return nil, apiErr
 	}
 
-	var settings shared.PlanSettings
-	err = json.NewDecoder(resp.Body).Decode(&settings)
+	var sessions []*shared.Session
+	err = json.NewDecoder(resp.Body).Decode(&sessions)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &settings, nil
+	return sessions, nil
 }
 
-func (a *Api) UpdateOrgDefaultSettings(req shared.UpdateSettingsRequest) (*shared.UpdateSettingsResponse, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/default_settings", getApiHost())
+func (a *Api) RevokeSession(sessionId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/accounts/sessions/%s", getApiHost(), sessionId)
 
-	reqBytes, err := json.Marshal(req)
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %s", err)}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
 
-	// log.Println("UpdateSettings", string(reqBytes))
+	resp, err := authenticatedFastClient.Do(req)
+	if err != nil {
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+	}
+	defer resp.Body.Close()
 
-	request, err := http.NewRequest(http.MethodPut, serverUrl, bytes.NewBuffer(reqBytes))
+	if resp.StatusCode >= 400 {
+		errorBody, _ := io.ReadAll(resp.Body)
+
+		apiErr := handleApiError(resp, errorBody)
+		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
+		if tokenRefreshed {
+			return a.RevokeSession(sessionId)
+		}
+		return apiErr
+	}
+
+	return nil
+}
+
+func (a *Api) RevokeOtherSessions() (*shared.RevokeSessionsResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/accounts/sessions", getApiHost())
+
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
-	request.Header.Set("Content-Type", "application/json")
 
-	resp, err := authenticatedFastClient.Do(request)
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1827,31 +2694,26 @@ func (a *Api) UpdateOrgDefaultSettings(req shared.UpdateSettingsRequest) (*share
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.UpdateOrgDefaultSettings(req)
+			return a.RevokeOtherSessions()
 		}
 		return nil, apiErr
 	}
 
-	var updateRes shared.UpdateSettingsResponse
-	err = json.NewDecoder(resp.Body).Decode(&updateRes)
+	var res shared.RevokeSessionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
 	if err != nil {
-		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %s", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return &updateRes, nil
-
+	return &res, nil
 }
 
-func (a *Api) CreateCustomModel(model *shared.AvailableModel) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/custom_models", getApiHost())
-	body, err := json.Marshal(model)
-	if err != nil {
-		return &shared.ApiError{Msg: "Failed to marshal model"}
-	}
+func (a *Api) ListAuditEvents(params shared.ListAuditEventsParams) ([]*shared.AuditEvent, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/audit_events?%s", getApiHost(), params.Query().Encode())
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
+	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1861,16 +2723,23 @@ func (a *Api) CreateCustomModel(model *shared.AvailableModel) *shared.ApiError {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.CreateCustomModel(model)
+			return a.ListAuditEvents(params)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var events []*shared.AuditEvent
+	err = json.NewDecoder(resp.Body).Decode(&events)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return events, nil
 }
 
-func (a *Api) ListCustomModels() ([]*shared.AvailableModel, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/custom_models", getApiHost())
+func (a *Api) ListWebhooks() ([]*shared.Webhook, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/webhooks", getApiHost())
+
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
@@ -1883,30 +2752,30 @@ func (a *Api) ListCustomModels() ([]*shared.AvailableModel, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListCustomModels()
+			return a.ListWebhooks()
 		}
 		return nil, apiErr
 	}
 
-	var models []*shared.AvailableModel
-	err = json.NewDecoder(resp.Body).Decode(&models)
+	var webhooks []*shared.Webhook
+	err = json.NewDecoder(resp.Body).Decode(&webhooks)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return models, nil
+	return webhooks, nil
 }
 
-func (a *Api) DeleteAvailableModel(modelId string) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/custom_models/%s", getApiHost(), modelId)
-	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
+func (a *Api) CreateWebhook(req shared.CreateWebhookRequest) (*shared.CreateWebhookResponse, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/webhooks", getApiHost())
+	body, err := json.Marshal(req)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error marshalling request: %v", err)}
 	}
 
-	resp, err := authenticatedFastClient.Do(req)
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -1916,22 +2785,29 @@ func (a *Api) DeleteAvailableModel(modelId string) *shared.ApiError {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.DeleteAvailableModel(modelId)
+			return a.CreateWebhook(req)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var res shared.CreateWebhookResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &res, nil
 }
 
-func (a *Api) CreateModelPack(set *shared.ModelPack) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/model_sets", getApiHost())
-	body, err := json.Marshal(set)
+func (a *Api) DeleteWebhook(webhookId string) *shared.ApiError {
+	serverUrl := fmt.Sprintf("%s/orgs/webhooks/%s", getApiHost(), webhookId)
+
+	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
 	if err != nil {
-		return &shared.ApiError{Msg: "Failed to marshal model pack"}
+		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
 	}
 
-	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", bytes.NewBuffer(body))
+	resp, err := authenticatedFastClient.Do(req)
 	if err != nil {
 		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
@@ -1943,17 +2819,16 @@ func (a *Api) CreateModelPack(set *shared.ModelPack) *shared.ApiError {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.CreateModelPack(set)
+			return a.DeleteWebhook(webhookId)
 		}
 		return apiErr
 	}
 
 	return nil
-
 }
 
-func (a *Api) ListModelPacks() ([]*shared.ModelPack, *shared.ApiError) {
-	serverUrl := fmt.Sprintf("%s/model_sets", getApiHost())
+func (a *Api) ListWebhookDeliveries(webhookId string) ([]*shared.WebhookDelivery, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/webhooks/%s/deliveries", getApiHost(), webhookId)
 
 	resp, err := authenticatedFastClient.Get(serverUrl)
 	if err != nil {
@@ -1967,32 +2842,26 @@ func (a *Api) ListModelPacks() ([]*shared.ModelPack, *shared.ApiError) {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.ListModelPacks()
+			return a.ListWebhookDeliveries(webhookId)
 		}
 		return nil, apiErr
 	}
 
-	var sets []*shared.ModelPack
-	err = json.NewDecoder(resp.Body).Decode(&sets)
+	var deliveries []*shared.WebhookDelivery
+	err = json.NewDecoder(resp.Body).Decode(&deliveries)
 	if err != nil {
 		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
 	}
 
-	return sets, nil
-
+	return deliveries, nil
 }
 
-func (a *Api) DeleteModelPack(setId string) *shared.ApiError {
-	serverUrl := fmt.Sprintf("%s/model_sets/%s", getApiHost(), setId)
-
-	req, err := http.NewRequest(http.MethodDelete, serverUrl, nil)
-	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error creating request: %v", err)}
-	}
+func (a *Api) TestWebhook(webhookId string) (*shared.WebhookDelivery, *shared.ApiError) {
+	serverUrl := fmt.Sprintf("%s/orgs/webhooks/%s/test", getApiHost(), webhookId)
 
-	resp, err := authenticatedFastClient.Do(req)
+	resp, err := authenticatedFastClient.Post(serverUrl, "application/json", nil)
 	if err != nil {
-		return &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error sending request: %v", err)}
 	}
 	defer resp.Body.Close()
 
@@ -2002,10 +2871,16 @@ func (a *Api) DeleteModelPack(setId string) *shared.ApiError {
 		apiErr := handleApiError(resp, errorBody)
 		tokenRefreshed, apiErr := refreshTokenIfNeeded(apiErr)
 		if tokenRefreshed {
-			return a.DeleteModelPack(setId)
+			return a.TestWebhook(webhookId)
 		}
-		return apiErr
+		return nil, apiErr
 	}
 
-	return nil
+	var delivery shared.WebhookDelivery
+	err = json.NewDecoder(resp.Body).Decode(&delivery)
+	if err != nil {
+		return nil, &shared.ApiError{Type: shared.ApiErrorTypeOther, Msg: fmt.Sprintf("error decoding response: %v", err)}
+	}
+
+	return &delivery, nil
 }
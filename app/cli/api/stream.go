@@ -4,48 +4,108 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
-	"io"
 	"log"
+	"net/http"
 	"plandex/types"
+	"strings"
+	"time"
 
 	"github.com/plandex/plandex/shared"
 )
 
-func connectPlanRespStream(body io.ReadCloser, onStream types.OnStreamPlan) {
-	reader := bufio.NewReader(body)
+// how many times in a row the CLI tries to reconnect a dropped plan stream before giving up
+const maxStreamReconnectAttempts = 5
 
+// connectPlanRespStream reads a plan stream in whichever protocol the server answered with. The CLI asks for server-sent events, but older servers only know the separated message protocol. If the connection drops before the plan finishes, it reconnects and picks up after the last message it received.
+func connectPlanRespStream(planId, branch string, resp *http.Response, onStream types.OnStreamPlan) {
 	go func() {
+		var modelStreamId string
+		var lastSeq int64
+		numAttempts := 0
+
 		for {
-			s, err := readUntilSeparator(reader, shared.STREAM_MESSAGE_SEPARATOR)
-			if err != nil {
-				log.Println("Error reading line:", err)
-				onStream(types.OnStreamPlanParams{Msg: nil, Err: err})
-				body.Close()
+			err := readPlanRespStream(resp, func(msg *shared.StreamMessage) {
+				numAttempts = 0
+
+				if msg.Type == shared.StreamMessageStart && msg.ModelStreamId != "" {
+					modelStreamId = msg.ModelStreamId
+				}
+
+				if msg.Seq > 0 {
+					if msg.Seq <= lastSeq {
+						// already received before reconnecting
+						return
+					}
+					lastSeq = msg.Seq
+				}
+
+				onStream(types.OnStreamPlanParams{Msg: msg, Err: nil})
+			})
+			resp.Body.Close()
+
+			if err == nil {
 				return
 			}
 
-			var msg shared.StreamMessage
-			err = json.Unmarshal([]byte(s), &msg)
-			if err != nil {
-				log.Println("Error unmarshalling message:", err)
+			// servers that don't send a stream id can't resume
+			if modelStreamId == "" || numAttempts >= maxStreamReconnectAttempts {
 				onStream(types.OnStreamPlanParams{Msg: nil, Err: err})
-				body.Close()
 				return
 			}
 
-			// log.Println("Received message:", msg)
-
-			onStream(types.OnStreamPlanParams{Msg: &msg, Err: nil})
+			numAttempts++
+			log.Printf("Plan stream dropped: %v | reconnecting, attempt %d\n", err, numAttempts)
+			time.Sleep(time.Duration(numAttempts) * 500 * time.Millisecond)
 
-			if msg.Type == shared.StreamMessageFinished || msg.Type == shared.StreamMessageError || msg.Type == shared.StreamMessageAborted {
-				body.Close()
+			var apiErr *shared.ApiError
+			resp, apiErr = connectPlanStreamRequest(planId, branch, shared.FormatStreamEventId(modelStreamId, lastSeq))
+			if apiErr != nil {
+				log.Println("Error reconnecting plan stream:", apiErr.Msg)
+				// the plan most likely finished while disconnected
+				onStream(types.OnStreamPlanParams{Msg: nil, Err: err})
 				return
 			}
-
 		}
 	}()
 }
 
+// readPlanRespStream calls onMsg for each message until the plan finishes, returning nil, or the connection fails
+func readPlanRespStream(resp *http.Response, onMsg func(msg *shared.StreamMessage)) error {
+	reader := bufio.NewReader(resp.Body)
+
+	readMessage := func() (string, error) {
+		return readUntilSeparator(reader, shared.STREAM_MESSAGE_SEPARATOR)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), shared.EVENT_STREAM_CONTENT_TYPE) {
+		readMessage = func() (string, error) {
+			return readSSEData(reader)
+		}
+	}
+
+	for {
+		s, err := readMessage()
+		if err != nil {
+			log.Println("Error reading line:", err)
+			return err
+		}
+
+		var msg shared.StreamMessage
+		err = json.Unmarshal([]byte(s), &msg)
+		if err != nil {
+			log.Println("Error unmarshalling message:", err)
+			return err
+		}
+
+		// log.Println("Received message:", msg)
+
+		onMsg(&msg)
+
+		if msg.Type == shared.StreamMessageFinished || msg.Type == shared.StreamMessageError || msg.Type == shared.StreamMessageAborted {
+			return nil
+		}
+	}
+}
+
 func readUntilSeparator(reader *bufio.Reader, separator string) (string, error) {
 	var result []byte
 	sepBytes := []byte(separator)
@@ -60,3 +120,26 @@ func readUntilSeparator(reader *bufio.Reader, separator string) (string, error)
 		}
 	}
 }
+
+// readSSEData reads the next event from a server-sent event stream and returns its data. Comments (heartbeats) and other fields are skipped.
+func readSSEData(reader *bufio.Reader) (string, error) {
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if len(data) > 0 {
+				return strings.Join(data, "\n"), nil
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
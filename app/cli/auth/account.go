@@ -12,10 +12,11 @@ import (
 const (
 	AuthFreeTrialOption = "Start an anonymous trial on Plandex Cloud (no email required)"
 	AuthAccountOption   = "Sign in, accept an invite, or create an account"
+	AuthLocalOption     = "Run Plandex locally (no account or server needed)"
 )
 
 func promptInitialAuth() error {
-	selected, err := term.SelectFromList("👋 Hey there!\nIt looks like this is your first time using Plandex on this computer.\nWhat would you like to do?", []string{AuthFreeTrialOption, AuthAccountOption})
+	selected, err := term.SelectFromList("👋 Hey there!\nIt looks like this is your first time using Plandex on this computer.\nWhat would you like to do?", []string{AuthFreeTrialOption, AuthAccountOption, AuthLocalOption})
 
 	if err != nil {
 		return fmt.Errorf("error selecting auth option: %v", err)
@@ -35,6 +36,15 @@ func promptInitialAuth() error {
 		if err != nil {
 			return fmt.Errorf("error selecting or signing in to account: %v", err)
 		}
+
+	case AuthLocalOption:
+		err = SignInLocal()
+
+		if err != nil {
+			return fmt.Errorf("error signing in to local account: %v", err)
+		}
+
+		term.PrintCmds("", "new", "plans")
 	}
 
 	return nil
@@ -136,15 +146,28 @@ func SelectOrSignInOrCreate() error {
 const (
 	SignInCloudOption = "Plandex Cloud"
 	SignInOtherOption = "Another host"
+	SignInLocalOption = "Local mode (runs on this computer, no account needed)"
 )
 
 func promptSignInNewAccount() error {
-	selected, err := term.SelectFromList("Use Plandex Cloud or another host?", []string{SignInCloudOption, SignInOtherOption})
+	selected, err := term.SelectFromList("Use Plandex Cloud or another host?", []string{SignInCloudOption, SignInOtherOption, SignInLocalOption})
 
 	if err != nil {
 		return fmt.Errorf("error selecting sign in option: %v", err)
 	}
 
+	if selected == SignInLocalOption {
+		err = SignInLocal()
+
+		if err != nil {
+			return fmt.Errorf("error signing in to local account: %v", err)
+		}
+
+		term.PrintCmds("", "new", "plans")
+
+		return nil
+	}
+
 	var host string
 	var email string
 
@@ -161,6 +184,17 @@ func promptSignInNewAccount() error {
 			return fmt.Errorf("error prompting host: %v", err)
 		}
 
+		signedIn, err := promptSso(host)
+
+		if err != nil {
+			return fmt.Errorf("error signing in with sso: %v", err)
+		}
+
+		if signedIn {
+			term.PrintCmds("", "new", "plans")
+			return nil
+		}
+
 		email, err = term.GetRequiredUserStringInput("Your email:")
 
 		if err != nil {
@@ -7,6 +7,9 @@ import (
 	"plandex/fs"
 	"plandex/term"
 	"plandex/types"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
 )
 
 func MustResolveAuthWithOrg() {
@@ -18,6 +21,10 @@ func MustResolveAuth(requireOrg bool) {
 		term.OutputErrorAndExit("error resolving auth: api client not set")
 	}
 
+	if resolveAccessTokenAuth() {
+		return
+	}
+
 	// load HomeAuthPath file into ClientAuth struct
 	bytes, err := os.ReadFile(fs.HomeAuthPath)
 
@@ -80,6 +87,18 @@ func RefreshInvalidToken() error {
 		return fmt.Errorf("error refreshing token: auth not loaded")
 	}
 
+	if Current.IsLocal {
+		return fmt.Errorf("error refreshing token: local account token is invalid")
+	}
+
+	if Current.IsAccessToken {
+		return fmt.Errorf("error refreshing token: access token from %s is invalid, expired, or revoked", accessTokenEnvVar)
+	}
+
+	if Current.IsSso {
+		return signInSso(Current.Host)
+	}
+
 	hasAccount, pin, err := verifyEmail(Current.Email, Current.Host)
 
 	if err != nil {
@@ -99,3 +118,31 @@ func RefreshInvalidToken() error {
 
 	return nil
 }
+
+const accessTokenEnvVar = "PLANDEX_TOKEN"
+const accessTokenHostEnvVar = "PLANDEX_HOST"
+
+// resolveAccessTokenAuth uses an access token from the environment when one is set, which lets the CLI run non-interactively in CI. The token's org is resolved by the server, so no org selection is needed.
+func resolveAccessTokenAuth() bool {
+	token := os.Getenv(accessTokenEnvVar)
+	if token == "" {
+		return false
+	}
+
+	if !strings.HasPrefix(token, shared.AccessTokenPrefix) {
+		term.OutputErrorAndExit("%s must be an access token starting with '%s'", accessTokenEnvVar, shared.AccessTokenPrefix)
+	}
+
+	host := os.Getenv(accessTokenHostEnvVar)
+
+	Current = &types.ClientAuth{
+		ClientAccount: types.ClientAccount{
+			IsCloud: host == "",
+			Host:    host,
+			Token:   token,
+		},
+		IsAccessToken: true,
+	}
+
+	return true
+}
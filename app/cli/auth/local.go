@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plandex/fs"
+	"plandex/term"
+	"plandex/types"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/plandex/plandex/shared"
+)
+
+// Local accounts are served by an in-process copy of the server, started the first time a command makes an api request. Its database and plan repos live under the home plandex dir. The server is only linked into builds with the 'local' tag (see local_server.go), since it needs cgo--other builds stay pure Go so they can be cross-compiled.
+
+type localServerInfo struct {
+	Host    string
+	Session *shared.SessionResponse
+}
+
+var localServer *localServerInfo
+var localErr error
+var localOnce sync.Once
+
+func startLocal() (*localServerInfo, error) {
+	localOnce.Do(func() {
+		localServer, localErr = startLocalServer(filepath.Join(fs.HomePlandexDir, "local"))
+	})
+	return localServer, localErr
+}
+
+// MustGetLocalHost starts the local server if needed and returns its address. The stored token is refreshed from the server so that a rotated token never sends a local account through email verification.
+func MustGetLocalHost() string {
+	server, err := startLocal()
+	if err != nil {
+		term.OutputErrorAndExit("Error starting local server: %v", err)
+	}
+
+	if Current != nil && Current.IsLocal && Current.Token != server.Session.Token {
+		Current.Token = server.Session.Token
+
+		err = storeAccount(&Current.ClientAccount)
+		if err != nil {
+			term.OutputErrorAndExit("Error storing account: %v", err)
+		}
+
+		err = writeCurrentAuth()
+		if err != nil {
+			term.OutputErrorAndExit("Error writing auth: %v", err)
+		}
+	}
+
+	return server.Host
+}
+
+// SignInLocal switches to the local account, creating it on first use. It's a no-op if the local account is already current.
+func SignInLocal() error {
+	if Current == nil {
+		bytes, err := os.ReadFile(fs.HomeAuthPath)
+		if err == nil {
+			var auth types.ClientAuth
+			if json.Unmarshal(bytes, &auth) == nil && auth.IsLocal {
+				return nil
+			}
+		}
+	} else if Current.IsLocal {
+		return nil
+	}
+
+	term.StartSpinner("")
+	server, err := startLocal()
+	term.StopSpinner()
+
+	if err != nil {
+		return fmt.Errorf("error starting local server: %v", err)
+	}
+
+	session := server.Session
+
+	auth := &types.ClientAuth{
+		ClientAccount: types.ClientAccount{
+			IsLocal:  true,
+			Email:    session.Email,
+			UserId:   session.UserId,
+			UserName: session.UserName,
+			Token:    session.Token,
+		},
+	}
+
+	if len(session.Orgs) > 0 {
+		auth.OrgId = session.Orgs[0].Id
+		auth.OrgName = session.Orgs[0].Name
+	}
+
+	err = setAuth(auth)
+	if err != nil {
+		return fmt.Errorf("error setting auth: %v", err)
+	}
+
+	fmt.Printf("✅ Using Plandex in %s | Plans are stored in %s\n", color.New(color.Bold, term.ColorHiGreen).Sprint("local mode"), color.New(term.ColorHiCyan).Sprint(filepath.Join(fs.HomePlandexDir, "local")))
+	fmt.Println()
+
+	return nil
+}
+
+// WaitForLocalPlans keeps the process alive while the local server is still running plans, since they'd be stopped when the cli exits
+func WaitForLocalPlans() {
+	if localServer == nil {
+		return
+	}
+
+	n := numLocalActivePlans()
+	if n == 0 {
+		return
+	}
+
+	term.StartSpinner(fmt.Sprintf("Waiting for %d active plan(s) to finish (ctrl+c to stop)", n))
+	for numLocalActivePlans() > 0 {
+		time.Sleep(500 * time.Millisecond)
+	}
+	term.StopSpinner()
+}
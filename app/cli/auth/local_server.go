@@ -0,0 +1,17 @@
+//go:build local
+
+package auth
+
+import "plandex-server/local"
+
+func startLocalServer(dir string) (*localServerInfo, error) {
+	server, err := local.Start(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &localServerInfo{Host: server.Host, Session: server.Session}, nil
+}
+
+func numLocalActivePlans() int {
+	return local.NumActivePlans()
+}
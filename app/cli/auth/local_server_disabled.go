@@ -0,0 +1,13 @@
+//go:build !local
+
+package auth
+
+import "fmt"
+
+func startLocalServer(dir string) (*localServerInfo, error) {
+	return nil, fmt.Errorf("this build of plandex doesn't include local mode--build it with '-tags local' (which requires cgo) to use --local")
+}
+
+func numLocalActivePlans() int {
+	return 0
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"os/exec"
+	"plandex/term"
+	"plandex/types"
+	"runtime"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/plandex/plandex/shared"
+)
+
+const ssoPollInterval = 2 * time.Second
+
+// promptSso checks whether the host supports SSO, and if it does, asks whether to use it (or uses it without asking if the host requires it). It returns false if the user should sign in with an email pin instead.
+func promptSso(host string) (bool, error) {
+	term.StartSpinner("")
+	config, apiErr := apiClient.GetSsoConfig(host)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		return false, fmt.Errorf("error getting sso config: %v", apiErr.Msg)
+	}
+
+	if !config.Enabled {
+		return false, nil
+	}
+
+	if !config.Required {
+		ssoOption := fmt.Sprintf("Sign in with %s", config.ProviderName)
+		pinOption := "Sign in with an email pin"
+
+		selected, err := term.SelectFromList("How do you want to sign in?", []string{ssoOption, pinOption})
+
+		if err != nil {
+			return false, fmt.Errorf("error selecting sign in option: %v", err)
+		}
+
+		if selected == pinOption {
+			return false, nil
+		}
+	}
+
+	err := signInSso(host)
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// signInSso has the user sign in with the host's identity provider in a browser, then picks up the session from the server. Since the CLI just polls, it works over ssh and on machines without a browser--the link can be opened anywhere.
+func signInSso(host string) error {
+	term.StartSpinner("")
+	res, apiErr := apiClient.StartSso(host)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		return fmt.Errorf("error starting sso sign in: %v", apiErr.Msg)
+	}
+
+	fmt.Println("🔑 Opening your browser to sign in. If it doesn't open, go to:")
+	fmt.Println()
+	fmt.Println(res.AuthUrl)
+	fmt.Println()
+	fmt.Println("After signing in, enter this code in the browser to confirm it's you:")
+	fmt.Println()
+	fmt.Println(color.New(color.Bold, term.ColorHiCyan).Sprint(res.UserCode))
+	fmt.Println()
+
+	openBrowser(res.AuthUrl)
+
+	term.StartSpinner("Waiting for you to sign in...")
+
+	var session *shared.SessionResponse
+	for session == nil {
+		if time.Now().After(res.ExpiresAt) {
+			term.StopSpinner()
+			return fmt.Errorf("sign in timed out")
+		}
+
+		time.Sleep(ssoPollInterval)
+
+		pollRes, apiErr := apiClient.PollSso(shared.PollSsoRequest{
+			LoginId:    res.LoginId,
+			PollSecret: res.PollSecret,
+		}, host)
+
+		if apiErr != nil {
+			term.StopSpinner()
+			return fmt.Errorf("error signing in: %v", apiErr.Msg)
+		}
+
+		session = pollRes.Session
+	}
+
+	term.StopSpinner()
+
+	err := setAuth(&types.ClientAuth{
+		ClientAccount: types.ClientAccount{
+			Email:    session.Email,
+			UserId:   session.UserId,
+			UserName: session.UserName,
+			Token:    session.Token,
+			IsTrial:  false,
+			IsCloud:  host == "",
+			Host:     host,
+			IsSso:    true,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("error setting auth: %v", err)
+	}
+
+	orgId, orgName, err := resolveOrgAuth(session.Orgs)
+
+	if err != nil {
+		return fmt.Errorf("error resolving org: %v", err)
+	}
+
+	Current.OrgId = orgId
+	Current.OrgName = orgName
+
+	err = writeCurrentAuth()
+
+	if err != nil {
+		return fmt.Errorf("error writing auth: %v", err)
+	}
+
+	fmt.Printf("✅ Signed in as %s | Org: %s\n", color.New(color.Bold, term.ColorHiGreen).Sprintf("<%s> %s", Current.UserName, Current.Email), color.New(term.ColorHiCyan).Sprint(Current.OrgName))
+	fmt.Println()
+
+	return nil
+}
+
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	// the url is printed too, so there's nothing to do if this fails
+	cmd.Start()
+}
@@ -0,0 +1,167 @@
+package changes_tui
+
+import (
+	"fmt"
+	"log"
+	"plandex/api"
+	"plandex/lib"
+	"plandex/term"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
+	"github.com/muesli/reflow/truncate"
+	"github.com/plandex/plandex/shared"
+)
+
+const maxHeaderComments = 3
+
+type finishedCreateComment struct {
+	comment *shared.ReviewComment
+	err     *shared.ApiError
+}
+
+func newCommentInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "Leave a comment"
+	input.CharLimit = shared.MaxReviewCommentLength
+	input.Prompt = "💬 "
+	input.Cursor.SetMode(cursor.CursorStatic)
+	return input
+}
+
+// selectedReplacementId is the id of the selected change, or empty when the new file or full file is selected, since comments on those apply to the whole file
+func (m changesUIModel) selectedReplacementId() string {
+	if m.selectionInfo == nil || m.selectionInfo.currentRep == nil {
+		return ""
+	}
+	return m.selectionInfo.currentRep.Id
+}
+
+func (m changesUIModel) commentsFor(path, replacementId string) []*shared.ReviewComment {
+	var res []*shared.ReviewComment
+	for _, comment := range m.comments {
+		if comment.Path == path && comment.ReplacementId == replacementId {
+			res = append(res, comment)
+		}
+	}
+	return res
+}
+
+func (m changesUIModel) selectedComments() []*shared.ReviewComment {
+	if m.selectionInfo == nil {
+		return nil
+	}
+	return m.commentsFor(m.selectionInfo.currentPath, m.selectedReplacementId())
+}
+
+func (m *changesUIModel) startCommenting() tea.Cmd {
+	m.isCommenting = true
+	m.commentErr = nil
+	m.commentInput.Reset()
+	return m.commentInput.Focus()
+}
+
+func (m *changesUIModel) stopCommenting() {
+	m.isCommenting = false
+	m.isSavingComment = false
+	m.commentErr = nil
+	m.commentInput.Blur()
+	m.commentInput.Reset()
+}
+
+func (m changesUIModel) updateCommentInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.isSavingComment {
+		return m, nil
+	}
+
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.stopCommenting()
+		return m, nil
+
+	case tea.KeyEnter:
+		body := strings.TrimSpace(m.commentInput.Value())
+		if body == "" {
+			return m, nil
+		}
+
+		req := shared.CreateReviewCommentRequest{
+			Path:          m.selectionInfo.currentPath,
+			ReplacementId: m.selectedReplacementId(),
+			Body:          body,
+		}
+
+		m.isSavingComment = true
+		m.commentErr = nil
+		go func() {
+			comment, err := api.Client.CreateReviewComment(lib.CurrentPlanId, lib.CurrentBranch, req)
+			if err != nil {
+				log.Printf("error creating review comment: %v", err)
+			}
+			program.Send(finishedCreateComment{comment: comment, err: err})
+		}()
+		return m, m.spinner.Tick
+	}
+
+	var cmd tea.Cmd
+	m.commentInput, cmd = m.commentInput.Update(msg)
+	return m, cmd
+}
+
+func (m changesUIModel) renderHeaderComments(width int) string {
+	comments := m.selectedComments()
+	if len(comments) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for i, comment := range comments {
+		if i == maxHeaderComments {
+			lines = append(lines, color.New(color.FgHiBlack).Sprintf(" +%d more • plandex comments to see all", len(comments)-maxHeaderComments))
+			break
+		}
+
+		author := comment.AuthorName
+		if author == "" {
+			author = comment.AuthorEmail
+		}
+		body := strings.Join(strings.Fields(comment.Body), " ")
+
+		line := fmt.Sprintf(" 💬 %s: %s", color.New(color.Bold).Sprint(author), body)
+		lines = append(lines, truncate.StringWithTail(line, uint(max(width-1, 0)), "…"))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m changesUIModel) renderCommentInput() string {
+	style := lipgloss.NewStyle().Padding(1).BorderStyle(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color(borderColor)).Width(m.width - 2).Height(m.height - 2)
+
+	target := color.New(color.Bold, term.ColorHiMagenta).Sprint(m.selectionInfo.currentPath)
+	if m.selectionInfo.currentRep != nil {
+		target = color.New(color.Bold).Sprint(m.selectionInfo.currentRep.StreamedChange.Summary) + " in " + target
+	}
+
+	prompt := color.New(color.Bold).Sprint("💬 Comment on ") + target + "\n\n"
+
+	if m.isSavingComment {
+		prompt += m.spinner.View()
+	} else {
+		prompt += m.commentInput.View() + "\n\n"
+
+		if m.commentErr != nil {
+			prompt += color.New(term.ColorHiRed, color.Bold).Sprint("🚨 "+m.commentErr.Msg) + "\n\n"
+		}
+
+		prompt += color.New(term.ColorHiCyan, color.Bold).Sprint("(enter) save | (esc) cancel")
+	}
+
+	return style.Render(prompt)
+}
@@ -104,6 +104,10 @@ func (m changesUIModel) renderMainViewHeader() string {
 		header = " 👉 " + m.selectionInfo.currentRep.StreamedChange.Summary
 	}
 
+	if comments := m.renderHeaderComments(m.width - sidebarWidth); comments != "" {
+		header += "\n" + comments
+	}
+
 	return style.Render(header)
 }
 
@@ -4,6 +4,7 @@ import (
 	"github.com/charmbracelet/bubbles/help"
 	bubbleKey "github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -31,6 +32,11 @@ type changesUIModel struct {
 	isConfirmingRejectFile   bool
 	rejectFileErr            *shared.ApiError
 	justRejectedFile         bool
+	comments                 []*shared.ReviewComment
+	isCommenting             bool
+	isSavingComment          bool
+	commentInput             textinput.Model
+	commentErr               *shared.ApiError
 	spinner                  spinner.Model
 }
 
@@ -47,6 +53,7 @@ type keymap = struct {
 	end,
 	switchView,
 	reject,
+	comment,
 	copy,
 	applyAll,
 	yes,
@@ -58,13 +65,15 @@ func (m changesUIModel) Init() tea.Cmd {
 	return nil
 }
 
-func initialModel(currentPlan *shared.CurrentPlanState) *changesUIModel {
+func initialModel(currentPlan *shared.CurrentPlanState, comments []*shared.ReviewComment) *changesUIModel {
 	s := spinner.New()
 	s.Spinner = spinner.Points
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
 	initialState := changesUIModel{
 		currentPlan:              currentPlan,
+		comments:                 comments,
+		commentInput:             newCommentInput(),
 		selectedFileIndex:        0,
 		selectedReplacementIndex: 0,
 		help:                     help.New(),
@@ -127,6 +136,11 @@ func initialModel(currentPlan *shared.CurrentPlanState) *changesUIModel {
 				bubbleKey.WithHelp("r", "reject file"),
 			),
 
+			comment: bubbleKey.NewBinding(
+				bubbleKey.WithKeys("m"),
+				bubbleKey.WithHelp("m", "comment"),
+			),
+
 			copy: bubbleKey.NewBinding(
 				bubbleKey.WithKeys("c"),
 				bubbleKey.WithHelp("c", "copy change"),
@@ -11,8 +11,8 @@ import (
 
 var program *tea.Program
 
-func StartChangesUI(currentPlan *shared.CurrentPlanState) error {
-	initial := initialModel(currentPlan)
+func StartChangesUI(currentPlan *shared.CurrentPlanState, comments []*shared.ReviewComment) error {
+	initial := initialModel(currentPlan, comments)
 
 	if len(initial.currentPlan.PlanResult.SortedPaths) == 0 {
 		fmt.Println("🤷‍♂️ No changes pending")
@@ -38,7 +38,7 @@ func StartChangesUI(currentPlan *shared.CurrentPlanState) error {
 	}
 
 	if mod.shouldApplyAll {
-		lib.MustApplyPlan(lib.CurrentPlanId, lib.CurrentBranch, false)
+		lib.MustApplyPlan(lib.CurrentPlanId, lib.CurrentBranch, false, nil, nil)
 	}
 
 	if mod.rejectFileErr != nil {
@@ -51,5 +51,15 @@ func StartChangesUI(currentPlan *shared.CurrentPlanState) error {
 		return nil
 	}
 
+	if len(mod.comments) > 0 && !mod.shouldApplyAll {
+		suffix := "s"
+		if len(mod.comments) == 1 {
+			suffix = ""
+		}
+		fmt.Printf("💬 %d open review comment%s on these changes\n", len(mod.comments), suffix)
+		fmt.Println()
+		term.PrintCmds("", "comments", "comments address")
+	}
+
 	return nil
 }
@@ -42,6 +42,10 @@ func (m changesUIModel) renderSidebar() string {
 				s += color.New(fgColor).Sprintf(" %s %d ", icon, 1)
 			}
 
+			if len(m.commentsFor(path, "")) > 0 {
+				s += "💬"
+			}
+
 			s += "\n"
 			sb.WriteString(s)
 		} else {
@@ -89,6 +93,10 @@ func (m changesUIModel) renderSidebar() string {
 			s += color.New(fgColor).Sprintf(" %s %d ", icon, num)
 		}
 
+		if len(m.commentsFor(path, rep.Id)) > 0 {
+			s += "💬"
+		}
+
 		s += "\n"
 
 		sb.WriteString(s)
@@ -111,6 +119,10 @@ func (m changesUIModel) renderSidebar() string {
 		} else {
 			sb.WriteString(color.New(fgColor).Sprint(" 🔀   "))
 		}
+
+		if len(m.commentsFor(path, "")) > 0 {
+			sb.WriteString("💬")
+		}
 	}
 
 	helpHeight := lipgloss.Height(m.renderHelp())
@@ -35,7 +35,7 @@ func (m changesUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case spinner.TickMsg:
-		if m.isRejectingFile {
+		if m.isRejectingFile || m.isSavingComment {
 			spinnerModel, cmd := m.spinner.Update(msg)
 			m.spinner = spinnerModel
 			return m, cmd
@@ -65,7 +65,23 @@ func (m changesUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.setSelectionInfo()
 		m.updateMainView(true)
 
+	case finishedCreateComment:
+		m.isSavingComment = false
+
+		if msg.err != nil {
+			m.commentErr = msg.err
+			return m, nil
+		}
+
+		m.comments = append(m.comments, msg.comment)
+		m.stopCommenting()
+		m.updateMainView(false)
+
 	case tea.KeyMsg:
+		if m.isCommenting {
+			return m.updateCommentInput(msg)
+		}
+
 		if m.isConfirmingRejectFile {
 			if !bubbleKey.Matches(msg, m.keymap.yes) && !bubbleKey.Matches(msg, m.keymap.no) &&
 				!bubbleKey.Matches(msg, m.keymap.quit) {
@@ -117,6 +133,9 @@ func (m changesUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case bubbleKey.Matches(msg, m.keymap.reject):
 			m.isConfirmingRejectFile = true
 
+		case bubbleKey.Matches(msg, m.keymap.comment):
+			return m, m.startCommenting()
+
 		case bubbleKey.Matches(msg, m.keymap.yes):
 			m.isRejectingFile = true
 			m.isConfirmingRejectFile = false
@@ -159,6 +178,14 @@ func (m changesUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// handle escape sequences sometimes sent by arrow keys
 			m.resolveEscapeSequence(msg.String())
 		}
+
+	default:
+		// pass along anything else the comment input needs, like pasted text
+		if m.isCommenting {
+			var cmd tea.Cmd
+			m.commentInput, cmd = m.commentInput.Update(msg)
+			return m, cmd
+		}
 	}
 
 	return m, nil
@@ -17,6 +17,10 @@ var topBorderStyle = lipgloss.NewStyle().
 	BorderForeground(borderColor)
 
 func (m changesUIModel) View() string {
+	if m.isCommenting {
+		return m.renderCommentInput()
+	}
+
 	if m.isConfirmingRejectFile {
 		return m.renderConfirmRejectFile()
 	}
@@ -143,6 +147,10 @@ func (m changesUIModel) renderHelp() string {
 		help += "(↑/↓) select change • "
 	}
 
+	if m.selectionInfo != nil {
+		help += "co(m)ment • "
+	}
+
 	help += "(ctrl+a) apply all changes • (q)uit"
 	style := lipgloss.NewStyle().Width(m.width).Inherit(topBorderStyle).Foreground(lipgloss.Color(helpTextColor))
 	return style.Render(help)
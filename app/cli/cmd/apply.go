@@ -9,17 +9,20 @@ import (
 )
 
 var autoConfirm bool
+var applyExclude []string
 
 func init() {
 	applyCmd.Flags().BoolVarP(&autoConfirm, "yes", "y", false, "Automatically confirm unless plan is outdated")
+	applyCmd.Flags().StringSliceVarP(&applyExclude, "exclude", "e", nil, "Skip paths matching these paths or globs (changes stay pending)")
 
 	RootCmd.AddCommand(applyCmd)
 }
 
 var applyCmd = &cobra.Command{
-	Use:     "apply",
+	Use:     "apply [paths-or-globs...]",
 	Aliases: []string{"ap"},
 	Short:   "Apply a plan to the project",
+	Long:    "Apply a plan to the project. Pass paths or globs (like 'internal/db/**') to apply only matching files--changes to other files stay pending.",
 	Run:     apply,
 }
 
@@ -31,5 +34,5 @@ func apply(cmd *cobra.Command, args []string) {
 		term.OutputNoCurrentPlanErrorAndExit()
 	}
 
-	lib.MustApplyPlan(lib.CurrentPlanId, lib.CurrentBranch, autoConfirm)
+	lib.MustApplyPlan(lib.CurrentPlanId, lib.CurrentBranch, autoConfirm, args, applyExclude)
 }
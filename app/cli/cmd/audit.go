@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/format"
+	"plandex/term"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var auditActor string
+var auditAction string
+var auditSince string
+var auditUntil string
+var auditLimit int
+var auditJsonl bool
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List your org's audit log",
+	Args:  cobra.NoArgs,
+	Run:   listAuditEvents,
+}
+
+func init() {
+	RootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "Only show events by this user's email")
+	auditCmd.Flags().StringVar(&auditAction, "action", "", "Only show this action, like 'plan.delete', or a category, like 'plan'")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only show events since a time--a duration like '24h' or '7d', a date, or an RFC3339 time")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "Only show events before a time--same formats as --since")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 0, fmt.Sprintf("Max number of events--defaults to %d, or all matching events with --jsonl", shared.AuditEventsDefaultLimit))
+	auditCmd.Flags().BoolVar(&auditJsonl, "jsonl", false, "Export events to stdout as JSON lines")
+}
+
+func listAuditEvents(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	if auditLimit < 0 {
+		term.OutputErrorAndExit("--limit can't be negative")
+	}
+
+	params := shared.ListAuditEventsParams{
+		ActorEmail: strings.ToLower(strings.TrimSpace(auditActor)),
+		Action:     strings.TrimSpace(auditAction),
+	}
+
+	var err error
+	params.Since, err = parseAuditTime(auditSince)
+	if err != nil {
+		term.OutputErrorAndExit("Invalid --since: %v", err)
+	}
+	params.Until, err = parseAuditTime(auditUntil)
+	if err != nil {
+		term.OutputErrorAndExit("Invalid --until: %v", err)
+	}
+
+	if auditJsonl {
+		exportAuditEvents(params)
+		return
+	}
+
+	params.Limit = auditLimit
+	if params.Limit == 0 {
+		params.Limit = shared.AuditEventsDefaultLimit
+	}
+
+	term.StartSpinner("")
+	events, apiErr := api.Client.ListAuditEvents(params)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching audit events: %v", apiErr.Msg)
+		return
+	}
+
+	if len(events) == 0 {
+		fmt.Println("🤷‍♂️ No audit events")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Time", "Actor", "Action", "Resource", "IP"})
+
+	for _, event := range events {
+		actor := event.ActorEmail
+		if event.AccessTokenId != "" {
+			actor += "\n(access token)"
+		}
+
+		table.Append([]string{
+			format.Time(event.CreatedAt),
+			actor,
+			string(event.Action),
+			formatAuditResource(event),
+			event.Ip,
+		})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	if len(events) == params.Limit {
+		fmt.Printf("Showing the latest %d events. Use --limit for more, or --jsonl to export them all.\n", len(events))
+	}
+}
+
+// exportAuditEvents writes every matching event as a JSON line, newest first, fetching pages until there are none left (or --limit is reached)
+func exportAuditEvents(params shared.ListAuditEventsParams) {
+	encoder := json.NewEncoder(os.Stdout)
+	numExported := 0
+
+	for {
+		params.Limit = shared.AuditEventsMaxLimit
+		if auditLimit > 0 {
+			params.Limit = min(params.Limit, auditLimit-numExported)
+		}
+
+		events, apiErr := api.Client.ListAuditEvents(params)
+
+		if apiErr != nil {
+			term.OutputErrorAndExit("Error fetching audit events: %v", apiErr.Msg)
+			return
+		}
+
+		for _, event := range events {
+			err := encoder.Encode(event)
+			if err != nil {
+				term.OutputErrorAndExit("Error writing audit event: %v", err)
+			}
+		}
+		numExported += len(events)
+
+		if len(events) < params.Limit || (auditLimit > 0 && numExported >= auditLimit) {
+			return
+		}
+
+		params.Before = events[len(events)-1].Id
+	}
+}
+
+func formatAuditResource(event *shared.AuditEvent) string {
+	var lines []string
+	if event.ResourceId != "" {
+		lines = append(lines, event.ResourceId)
+	}
+
+	var keys []string
+	for k := range event.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, event.Details[k]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// parseAuditTime accepts a duration before now like '90m', '24h' or '7d', a date like '2024-05-01', or an RFC3339 time
+func parseAuditTime(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			t := time.Now().AddDate(0, 0, -n)
+			return &t, nil
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		t := time.Now().Add(-d)
+		return &t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("%q isn't a duration, date, or RFC3339 time", s)
+	}
+	return &t, nil
+}
@@ -6,6 +6,7 @@ import (
 	"plandex/auth"
 	"plandex/lib"
 	"plandex/term"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -15,8 +16,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var cdShared bool
+
 func init() {
 	RootCmd.AddCommand(cdCmd)
+	cdCmd.Flags().BoolVarP(&cdShared, "shared", "s", false, "Select from plans other org members have shared")
 }
 
 var cdCmd = &cobra.Command{
@@ -37,16 +41,38 @@ func cd(cmd *cobra.Command, args []string) {
 	}
 
 	var plan *shared.Plan
+	var plans []*shared.Plan
+	// for shared plans, the branches the current user can read
+	sharedBranchesByPlanId := map[string][]string{}
 
 	term.StartSpinner("")
-	plans, apiErr := api.Client.ListPlans([]string{lib.CurrentProjectId})
-	term.StopSpinner()
+	if cdShared {
+		sharedPlans, apiErr := api.Client.ListSharedPlans([]string{lib.CurrentProjectId})
+		term.StopSpinner()
+
+		if apiErr != nil {
+			term.OutputErrorAndExit("Error getting shared plans: %v", apiErr)
+		}
+
+		for _, p := range sharedPlans {
+			plans = append(plans, p.Plan)
+			sharedBranchesByPlanId[p.Plan.Id] = p.Branches
+		}
+	} else {
+		var apiErr *shared.ApiError
+		plans, apiErr = api.Client.ListPlans([]string{lib.CurrentProjectId})
+		term.StopSpinner()
 
-	if apiErr != nil {
-		term.OutputErrorAndExit("Error getting plans: %v", apiErr)
+		if apiErr != nil {
+			term.OutputErrorAndExit("Error getting plans: %v", apiErr)
+		}
 	}
 
 	if len(plans) == 0 {
+		if cdShared {
+			fmt.Println("🤷‍♂️ No shared plans")
+			return
+		}
 		fmt.Println("🤷‍♂️ No plans")
 		fmt.Println()
 		term.PrintCmds("", "new")
@@ -104,6 +130,14 @@ func cd(cmd *cobra.Command, args []string) {
 	// reload current plan, which will also handle setting the right branch
 	lib.MustLoadCurrentPlan()
 
+	// a shared plan's owner may not have shared the branch that was last used here
+	if sharedBranches := sharedBranchesByPlanId[plan.Id]; len(sharedBranches) > 0 && !slices.Contains(sharedBranches, lib.CurrentBranch) {
+		err = lib.WriteCurrentBranch(sharedBranches[0])
+		if err != nil {
+			term.OutputErrorAndExit("Error setting current branch: %v", err)
+		}
+	}
+
 	// fire and forget SetProjectPlan request (we don't care about the response or errors)
 	// this only matters for setting the current plan on a new device (i.e. when the current plan is not set)
 	go api.Client.SetProjectPlan(lib.CurrentProjectId, shared.SetProjectPlanRequest{PlanId: plan.Id})
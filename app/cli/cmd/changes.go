@@ -39,6 +39,25 @@ func changes(cmd *cobra.Command, args []string) {
 
 	// log.Println(spew.Sdump(currentPlanState))
 
+	if currentPlanState.HasPendingBuilds() {
+		term.StopSpinner()
+		if lib.PrintPendingMergeConflicts(currentPlanState) {
+			res, err := term.ConfirmYesNo("Rebuild the conflicting changes now? (No to resolve them manually first)")
+
+			if err != nil {
+				term.OutputErrorAndExit("Error getting confirmation user input: %v", err)
+			}
+
+			if !res {
+				fmt.Println("Edit the conflicting files, then update context and build")
+				fmt.Println()
+				term.PrintCmds("", "update", "build")
+				return
+			}
+		}
+		term.ResumeSpinner()
+	}
+
 	for currentPlanState.HasPendingBuilds() {
 		plansRunningRes, apiErr := api.Client.ListPlansRunning([]string{lib.CurrentProjectId}, false)
 
@@ -107,9 +126,15 @@ func changes(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	comments, apiErr := api.Client.ListReviewComments(lib.CurrentPlanId, lib.CurrentBranch, false)
+
 	term.StopSpinner()
 
-	err := changes_tui.StartChangesUI(currentPlanState)
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error getting review comments: %s", apiErr.Msg)
+	}
+
+	err := changes_tui.StartChangesUI(currentPlanState, comments)
 
 	if err != nil {
 		term.OutputErrorAndExit("Error starting changes UI: %v\n", err)
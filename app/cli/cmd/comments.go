@@ -0,0 +1,378 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/format"
+	"plandex/lib"
+	"plandex/plan_exec"
+	"plandex/term"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var commentsAll bool
+var commentChange int
+var commentsAddressBg bool
+
+var commentsCmd = &cobra.Command{
+	Use:   "comments",
+	Short: "List review comments on the current plan's pending changes",
+	Args:  cobra.NoArgs,
+	Run:   listComments,
+}
+
+var addCommentCmd = &cobra.Command{
+	Use:   "add <path> [comment]",
+	Short: "Comment on a file's pending changes, or a specific change with --change",
+	Args:  cobra.RangeArgs(1, 2),
+	Run:   addComment,
+}
+
+var resolveCommentsCmd = &cobra.Command{
+	Use:   "resolve [indexes...]",
+	Short: "Resolve review comments by index, or all open comments",
+	Run:   resolveComments,
+}
+
+var rmCommentCmd = &cobra.Command{
+	Use:   "rm <index>",
+	Short: "Delete a review comment by index",
+	Args:  cobra.ExactArgs(1),
+	Run:   rmComment,
+}
+
+var addressCommentsCmd = &cobra.Command{
+	Use:   "address",
+	Short: "Send open review comments to the plan as a prompt, then resolve them",
+	Args:  cobra.NoArgs,
+	Run:   addressComments,
+}
+
+func init() {
+	RootCmd.AddCommand(commentsCmd)
+	commentsCmd.AddCommand(addCommentCmd)
+	commentsCmd.AddCommand(resolveCommentsCmd)
+	commentsCmd.AddCommand(rmCommentCmd)
+	commentsCmd.AddCommand(addressCommentsCmd)
+
+	commentsCmd.Flags().BoolVarP(&commentsAll, "all", "a", false, "Include resolved comments")
+	addCommentCmd.Flags().IntVarP(&commentChange, "change", "c", 0, "Number of the change to comment on, as shown in 'plandex changes'")
+	addressCommentsCmd.Flags().BoolVar(&commentsAddressBg, "bg", false, "Execute autonomously in the background")
+}
+
+func listComments(cmd *cobra.Command, args []string) {
+	mustResolveCommentsPlan()
+
+	term.StartSpinner("")
+	comments, planState := mustGetReviewComments(commentsAll)
+	term.StopSpinner()
+
+	if len(comments) == 0 {
+		fmt.Println("🤷‍♂️ No review comments")
+		fmt.Println()
+		term.PrintCmds("", "changes", "comments add")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(true)
+	table.SetColWidth(60)
+	table.SetHeader([]string{"#", "File", "Change", "Author", "Comment", "Created"})
+
+	numOpen := 0
+	for _, comment := range comments {
+		var idx string
+		if comment.ResolvedAt == nil {
+			numOpen++
+			idx = strconv.Itoa(numOpen)
+		} else {
+			idx = "✅"
+		}
+
+		author := comment.AuthorName
+		if author == "" {
+			author = comment.AuthorEmail
+		}
+
+		table.Append([]string{
+			idx,
+			comment.Path,
+			changeLabel(planState.PlanResult, comment),
+			author,
+			comment.Body,
+			format.Time(comment.CreatedAt),
+		})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	if numOpen > 0 {
+		term.PrintCmds("", "comments address", "comments resolve", "comments add")
+	} else {
+		term.PrintCmds("", "comments add")
+	}
+}
+
+func addComment(cmd *cobra.Command, args []string) {
+	mustResolveCommentsPlan()
+
+	path := strings.TrimSpace(args[0])
+
+	var body string
+	if len(args) > 1 {
+		body = args[1]
+	} else {
+		var err error
+		body, err = term.GetRequiredUserStringInput("Comment:")
+		if err != nil {
+			term.OutputErrorAndExit("Error reading comment: %v", err)
+		}
+	}
+
+	term.StartSpinner("")
+
+	planState, apiErr := api.Client.GetCurrentPlanState(lib.CurrentPlanId, lib.CurrentBranch)
+
+	if apiErr != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error getting current plan state: %v", apiErr.Msg)
+	}
+
+	if _, ok := planState.PlanResult.FileResultsByPath[path]; !ok {
+		term.StopSpinner()
+		term.OutputErrorAndExit("No pending changes for %s", path)
+	}
+
+	req := shared.CreateReviewCommentRequest{
+		Path: path,
+		Body: body,
+	}
+
+	if commentChange != 0 {
+		replacementId := replacementIdForChange(planState.PlanResult, path, commentChange)
+		if replacementId == "" {
+			term.StopSpinner()
+			term.OutputErrorAndExit("%s has no change %d--run 'plandex changes' to see its numbered changes", path, commentChange)
+		}
+		req.ReplacementId = replacementId
+	}
+
+	_, apiErr = api.Client.CreateReviewComment(lib.CurrentPlanId, lib.CurrentBranch, req)
+
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error adding comment: %v", apiErr.Msg)
+	}
+
+	fmt.Println("💬 Comment added")
+	fmt.Println()
+	term.PrintCmds("", "comments", "comments address")
+}
+
+func resolveComments(cmd *cobra.Command, args []string) {
+	mustResolveCommentsPlan()
+
+	term.StartSpinner("")
+	comments, _ := mustGetReviewComments(false)
+
+	if len(comments) == 0 {
+		term.StopSpinner()
+		fmt.Println("🤷‍♂️ No open review comments")
+		return
+	}
+
+	var ids []string
+	for _, arg := range args {
+		comment := mustGetCommentByIndex(comments, arg)
+		ids = append(ids, comment.Id)
+	}
+
+	apiErr := api.Client.ResolveReviewComments(lib.CurrentPlanId, lib.CurrentBranch, shared.ResolveReviewCommentsRequest{Ids: ids})
+
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error resolving comments: %v", apiErr.Msg)
+	}
+
+	numResolved := len(ids)
+	if numResolved == 0 {
+		numResolved = len(comments)
+	}
+	suffix := "s"
+	if numResolved == 1 {
+		suffix = ""
+	}
+	fmt.Printf("✅ Resolved %d comment%s\n", numResolved, suffix)
+}
+
+func rmComment(cmd *cobra.Command, args []string) {
+	mustResolveCommentsPlan()
+
+	term.StartSpinner("")
+	comments, _ := mustGetReviewComments(false)
+	comment := mustGetCommentByIndex(comments, args[0])
+
+	apiErr := api.Client.DeleteReviewComment(lib.CurrentPlanId, lib.CurrentBranch, comment.Id)
+
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error deleting comment: %v", apiErr.Msg)
+	}
+
+	fmt.Println("✅ Comment deleted")
+}
+
+func addressComments(cmd *cobra.Command, args []string) {
+	mustResolveCommentsPlan()
+
+	apiKeys := lib.MustVerifyApiKeys()
+
+	term.StartSpinner("")
+	comments, planState := mustGetReviewComments(false)
+	term.StopSpinner()
+
+	if len(comments) == 0 {
+		fmt.Println("🤷‍♂️ No open review comments")
+		return
+	}
+
+	prompt := shared.ReviewCommentsPrompt(comments, planState.PlanResult)
+
+	// resolve before sending so the comments aren't addressed twice if the plan is interrupted and the command is run again--they can be listed with 'plandex comments --all'
+	var ids []string
+	for _, comment := range comments {
+		ids = append(ids, comment.Id)
+	}
+
+	apiErr := api.Client.ResolveReviewComments(lib.CurrentPlanId, lib.CurrentBranch, shared.ResolveReviewCommentsRequest{Ids: ids})
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error resolving comments: %v", apiErr.Msg)
+	}
+
+	suffix := "s"
+	if len(comments) == 1 {
+		suffix = ""
+	}
+	fmt.Printf("💬 Addressing %d review comment%s\n", len(comments), suffix)
+	fmt.Println()
+
+	plan_exec.TellPlan(plan_exec.ExecParams{
+		CurrentPlanId: lib.CurrentPlanId,
+		CurrentBranch: lib.CurrentBranch,
+		ApiKeys:       apiKeys,
+		CheckOutdatedContext: func(maybeContexts []*shared.Context) (bool, bool) {
+			return lib.MustCheckOutdatedContext(false, maybeContexts)
+		},
+	}, prompt, commentsAddressBg, false, false, false)
+}
+
+func mustResolveCommentsPlan() {
+	auth.MustResolveAuthWithOrg()
+	lib.MustResolveProject()
+
+	if lib.CurrentPlanId == "" {
+		term.OutputNoCurrentPlanErrorAndExit()
+	}
+}
+
+// mustGetReviewComments fetches the current branch's comments along with its plan state, sorted in the order 'plandex changes' shows them--by file, then by change. Indexes passed to 'comments resolve' and 'comments rm' refer to this order.
+func mustGetReviewComments(all bool) ([]*shared.ReviewComment, *shared.CurrentPlanState) {
+	comments, apiErr := api.Client.ListReviewComments(lib.CurrentPlanId, lib.CurrentBranch, all)
+
+	if apiErr != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error getting review comments: %v", apiErr.Msg)
+	}
+
+	planState, apiErr := api.Client.GetCurrentPlanState(lib.CurrentPlanId, lib.CurrentBranch)
+
+	if apiErr != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error getting current plan state: %v", apiErr.Msg)
+	}
+
+	pathOrder := map[string]int{}
+	for i, path := range planState.PlanResult.SortedPaths {
+		pathOrder[path] = i
+	}
+
+	sortKey := func(comment *shared.ReviewComment) (int, int) {
+		pathIdx, ok := pathOrder[comment.Path]
+		if !ok {
+			pathIdx = len(pathOrder)
+		}
+		return pathIdx, changeNumbers(planState.PlanResult, comment.Path)[comment.ReplacementId]
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		iPath, iChange := sortKey(comments[i])
+		jPath, jChange := sortKey(comments[j])
+		if iPath != jPath {
+			return iPath < jPath
+		}
+		return iChange < jChange
+	})
+
+	return comments, planState
+}
+
+func mustGetCommentByIndex(comments []*shared.ReviewComment, arg string) *shared.ReviewComment {
+	idx, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || idx < 1 || idx > len(comments) {
+		term.StopSpinner()
+		term.OutputErrorAndExit("No open comment with index %s--run 'plandex comments' to see them", arg)
+	}
+	return comments[idx-1]
+}
+
+// changeNumbers maps a file's replacement ids to the numbers they're shown with in the 'plandex changes' sidebar. A new file is change 1, and its later changes are numbered after it.
+func changeNumbers(planResult *shared.PlanResult, path string) map[string]int {
+	res := map[string]int{}
+	num := 0
+	for i, result := range planResult.FileResultsByPath[path] {
+		if i == 0 && len(result.Replacements) == 0 && result.Content != "" {
+			num++
+			continue
+		}
+		for _, rep := range result.Replacements {
+			num++
+			res[rep.Id] = num
+		}
+	}
+	return res
+}
+
+func replacementIdForChange(planResult *shared.PlanResult, path string, change int) string {
+	for id, num := range changeNumbers(planResult, path) {
+		if num == change {
+			return id
+		}
+	}
+	return ""
+}
+
+func changeLabel(planResult *shared.PlanResult, comment *shared.ReviewComment) string {
+	if comment.ReplacementId == "" {
+		return "whole file"
+	}
+
+	num, ok := changeNumbers(planResult, comment.Path)[comment.ReplacementId]
+	if !ok {
+		return color.New(color.FgHiBlack).Sprint("outdated")
+	}
+	return strconv.Itoa(num)
+}
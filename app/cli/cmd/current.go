@@ -61,6 +61,10 @@ func current(cmd *cobra.Command, args []string) {
 	name := color.New(color.Bold, term.ColorHiGreen).Sprint(plan.Name)
 	branch := currentBranchesByPlanId[lib.CurrentPlanId]
 
+	if branch == nil {
+		term.OutputErrorAndExit("Branch %s not found--if it was shared with you, it may have been unshared", lib.CurrentBranch)
+	}
+
 	row := []string{
 		name,
 		format.Time(plan.UpdatedAt),
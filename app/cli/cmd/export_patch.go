@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/lib"
+	"plandex/term"
+
+	"github.com/spf13/cobra"
+)
+
+var exportPatchOutDir string
+
+var exportPatchCmd = &cobra.Command{
+	Use:   "export-patch",
+	Short: "Export pending changes as a 'git format-patch' series",
+	Long:  "Export pending changes as a 'git format-patch' series, with one patch per conversation turn. The patches can be reviewed and applied with 'git am' without Plandex.",
+	Args:  cobra.NoArgs,
+	Run:   exportPatch,
+}
+
+func init() {
+	exportPatchCmd.Flags().StringVarP(&exportPatchOutDir, "out", "o", "plandex-patches", "Directory to write the patch files to")
+
+	RootCmd.AddCommand(exportPatchCmd)
+}
+
+func exportPatch(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+	lib.MustResolveProject()
+
+	if lib.CurrentPlanId == "" {
+		term.OutputNoCurrentPlanErrorAndExit()
+	}
+
+	term.StartSpinner("")
+	res, apiErr := api.Client.ExportPatches(lib.CurrentPlanId, lib.CurrentBranch)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error exporting patches: %v", apiErr.Msg)
+	}
+
+	if len(res.Patches) == 0 {
+		fmt.Println("🤷‍♂️ No pending changes to export")
+		return
+	}
+
+	err := os.MkdirAll(exportPatchOutDir, os.ModePerm)
+	if err != nil {
+		term.OutputErrorAndExit("Error creating output directory: %v", err)
+	}
+
+	for _, patch := range res.Patches {
+		path := filepath.Join(exportPatchOutDir, patch.Name)
+		err = os.WriteFile(path, []byte(patch.Body), 0644)
+		if err != nil {
+			term.OutputErrorAndExit("Error writing %s: %v", path, err)
+		}
+		fmt.Println("📄 " + path)
+	}
+
+	suffix := ""
+	if len(res.Patches) > 1 {
+		suffix = "es"
+	}
+	fmt.Println()
+	fmt.Printf("✅ Exported %d patch%s to %s\n", len(res.Patches), suffix, exportPatchOutDir)
+	fmt.Println()
+	fmt.Printf("Apply them with 'git am %s'\n", filepath.Join(exportPatchOutDir, "*.patch"))
+}
@@ -26,10 +26,12 @@ import (
 )
 
 var archivedOnly bool
+var sharedOnly bool
 
 func init() {
 	RootCmd.AddCommand(plansCmd)
 	plansCmd.Flags().BoolVarP(&archivedOnly, "archived", "a", false, "List archived plans")
+	plansCmd.Flags().BoolVarP(&sharedOnly, "shared", "s", false, "List plans other org members have shared")
 }
 
 // plansCmd represents the list command
@@ -46,6 +48,8 @@ func plans(cmd *cobra.Command, args []string) {
 
 	if archivedOnly {
 		listArchived()
+	} else if sharedOnly {
+		listShared()
 	} else {
 		listActive()
 	}
@@ -403,3 +407,43 @@ func listArchived() {
 	fmt.Println()
 	term.PrintCmds("", "unarchive")
 }
+
+func listShared() {
+	lib.MustResolveProject()
+
+	term.StartSpinner("")
+	plans, apiErr := api.Client.ListSharedPlans([]string{lib.CurrentProjectId})
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error getting shared plans: %v", apiErr)
+	}
+
+	if len(plans) == 0 {
+		fmt.Println("🤷‍♂️ No shared plans")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"#", "Name", "Owner", "Branches", "Updated"})
+
+	for i, p := range plans {
+		num := strconv.Itoa(i + 1)
+		if p.Plan.Id == lib.CurrentPlanId {
+			num = color.New(color.Bold, term.ColorHiGreen).Sprint(num)
+		}
+
+		table.Append([]string{
+			num,
+			p.Plan.Name,
+			p.OwnerEmail,
+			strings.Join(p.Branches, ", "),
+			format.Time(p.Plan.UpdatedAt),
+		})
+	}
+	table.Render()
+
+	fmt.Println()
+	term.PrintCmds("", "cd --shared")
+}
@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/lib"
+	"plandex/term"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var roleLabel string
+var roleDescription string
+var rolePermissions []string
+var roleThisProject bool
+
+var rolesCmd = &cobra.Command{
+	Use:   "roles",
+	Short: "List your org's roles and their permissions",
+	Run:   listRoles,
+}
+
+var createRoleCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a custom role",
+	Long: `Create a custom role. Give it permissions with --permission, which can be repeated. Limit a permission to a project with permission:project, using the project's name or id, or limit every permission that can be to the current project with --this-project.
+
+For example, to let users run and update any plan in the current project, but only read plans in other projects:
+
+plandex roles create project-dev -p create_plan -p update_any_plan --this-project`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  createRole,
+}
+
+var updateRoleCmd = &cobra.Command{
+	Use:   "update [name]",
+	Short: "Update a custom role--permissions given with --permission replace the role's current ones",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   updateRole,
+}
+
+var deleteRoleCmd = &cobra.Command{
+	Use:     "delete [name]",
+	Aliases: []string{"rm"},
+	Short:   "Delete a custom role that no users have",
+	Args:    cobra.MaximumNArgs(1),
+	Run:     deleteRole,
+}
+
+var assignRoleCmd = &cobra.Command{
+	Use:   "assign [email] [role]",
+	Short: "Give a user in your org a different role",
+	Args:  cobra.MaximumNArgs(2),
+	Run:   assignRole,
+}
+
+func init() {
+	RootCmd.AddCommand(rolesCmd)
+	rolesCmd.AddCommand(createRoleCmd)
+	rolesCmd.AddCommand(updateRoleCmd)
+	rolesCmd.AddCommand(deleteRoleCmd)
+	rolesCmd.AddCommand(assignRoleCmd)
+
+	for _, cmd := range []*cobra.Command{createRoleCmd, updateRoleCmd} {
+		cmd.Flags().StringVarP(&roleLabel, "label", "l", "", "Label for the role")
+		cmd.Flags().StringVarP(&roleDescription, "description", "d", "", "Description of the role")
+		cmd.Flags().StringSliceVarP(&rolePermissions, "permission", "p", nil, "Permission for the role, as permission or permission:project (repeatable)")
+		cmd.Flags().BoolVar(&roleThisProject, "this-project", false, "Limit permissions to the current project")
+	}
+}
+
+func listRoles(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	roles, projects := mustListRolesAndProjects()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Name", "Label", "Description", "Permissions"})
+
+	for _, role := range roles {
+		permissions := "built-in"
+		if !role.IsDefault {
+			var lines []string
+			for _, permission := range role.Permissions {
+				lines = append(lines, formatRolePermission(permission, projects))
+			}
+			permissions = strings.Join(lines, "\n")
+			if permissions == "" {
+				permissions = "none"
+			}
+		}
+
+		table.Append([]string{role.Name, role.Label, role.Description, permissions})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	term.PrintCmds("", "roles create", "roles assign")
+}
+
+func createRole(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	var err error
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		name, err = term.GetRequiredUserStringInput("Name for the role (e.g. 'project-dev'):")
+		if err != nil {
+			term.OutputErrorAndExit("Error reading role name: %v", err)
+		}
+	}
+
+	_, projects := mustListRolesAndProjects()
+
+	var permissions []*shared.OrgRolePermission
+	if len(rolePermissions) > 0 {
+		permissions = mustParseRolePermissions(rolePermissions, projects)
+	} else {
+		permissions = mustSelectRolePermissions(projects)
+	}
+
+	err = shared.ValidateCustomRole(name, permissions)
+	if err != nil {
+		term.OutputErrorAndExit("%v", err)
+	}
+
+	term.StartSpinner("")
+	role, apiErr := api.Client.CreateOrgRole(shared.CreateOrgRoleRequest{
+		Name:        name,
+		Label:       roleLabel,
+		Description: roleDescription,
+		Permissions: permissions,
+	})
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error creating role: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Created role %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(role.Name))
+	fmt.Println()
+
+	term.PrintCmds("", "roles", "roles assign", "invite")
+}
+
+func updateRole(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	roles, projects := mustListRolesAndProjects()
+	role := mustSelectCustomRole(args, roles)
+
+	req := shared.UpdateOrgRoleRequest{
+		Label:       role.Label,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}
+
+	if cmd.Flags().Changed("label") {
+		req.Label = roleLabel
+	}
+	if cmd.Flags().Changed("description") {
+		req.Description = roleDescription
+	}
+
+	if len(rolePermissions) > 0 {
+		req.Permissions = mustParseRolePermissions(rolePermissions, projects)
+	} else if !cmd.Flags().Changed("label") && !cmd.Flags().Changed("description") {
+		req.Permissions = mustSelectRolePermissions(projects)
+	}
+
+	term.StartSpinner("")
+	_, apiErr := api.Client.UpdateOrgRole(role.Id, req)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error updating role: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Updated role %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(role.Name))
+}
+
+func deleteRole(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	roles, _ := mustListRolesAndProjects()
+	role := mustSelectCustomRole(args, roles)
+
+	term.StartSpinner("")
+	apiErr := api.Client.DeleteOrgRole(role.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error deleting role: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Deleted role %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(role.Name))
+}
+
+func assignRole(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	email, roleName := "", ""
+	if len(args) >= 1 {
+		email = args[0]
+	}
+	if len(args) == 2 {
+		roleName = args[1]
+	}
+
+	term.StartSpinner("")
+	userResp, apiErr := api.Client.ListUsers()
+	if apiErr != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error fetching users: %v", apiErr.Msg)
+	}
+	roles, apiErr := api.Client.ListOrgRoles()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching org roles: %v", apiErr.Msg)
+	}
+
+	var err error
+	if email == "" {
+		var emails []string
+		for _, user := range userResp.Users {
+			emails = append(emails, user.Email)
+		}
+
+		email, err = term.SelectFromList("Select a user:", emails)
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting user: %v", err)
+		}
+	}
+
+	var user *shared.User
+	for _, u := range userResp.Users {
+		if strings.EqualFold(u.Email, email) {
+			user = u
+			break
+		}
+	}
+
+	if user == nil {
+		term.OutputErrorAndExit("No user in your org with email '%s'", email)
+	}
+
+	if roleName == "" {
+		var names []string
+		for _, role := range roles {
+			names = append(names, role.Name)
+		}
+
+		roleName, err = term.SelectFromList("Select a role:", names)
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting role: %v", err)
+		}
+	}
+
+	role := findRole(roleName, roles)
+	if role == nil {
+		term.OutputErrorAndExit("Role '%s' not found", roleName)
+	}
+
+	term.StartSpinner("")
+	apiErr = api.Client.SetOrgUserRole(user.Id, shared.SetOrgUserRoleRequest{OrgRoleId: role.Id})
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error setting role: %v", apiErr.Msg)
+	}
+
+	fmt.Printf("✅ %s now has the %s role\n", user.Email, color.New(color.Bold, term.ColorHiCyan).Sprint(role.Label))
+}
+
+func mustListRolesAndProjects() ([]*shared.OrgRole, []*shared.Project) {
+	term.StartSpinner("")
+	roles, apiErr := api.Client.ListOrgRoles()
+	if apiErr != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error fetching org roles: %v", apiErr.Msg)
+	}
+
+	projects, apiErr := api.Client.ListProjects()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching projects: %v", apiErr.Msg)
+	}
+
+	return roles, projects
+}
+
+// findRole matches a role by name or label
+func findRole(nameOrLabel string, roles []*shared.OrgRole) *shared.OrgRole {
+	for _, role := range roles {
+		if role.Name == nameOrLabel || role.Label == nameOrLabel {
+			return role
+		}
+	}
+	return nil
+}
+
+func mustSelectCustomRole(args []string, roles []*shared.OrgRole) *shared.OrgRole {
+	var customRoles []*shared.OrgRole
+	for _, role := range roles {
+		if !role.IsDefault {
+			customRoles = append(customRoles, role)
+		}
+	}
+
+	if len(customRoles) == 0 {
+		fmt.Println("🤷‍♂️ No custom roles")
+		fmt.Println()
+		term.PrintCmds("", "roles create")
+		os.Exit(0)
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		var names []string
+		for _, role := range customRoles {
+			names = append(names, role.Name)
+		}
+
+		var err error
+		name, err = term.SelectFromList("Select a role:", names)
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting role: %v", err)
+		}
+	}
+
+	role := findRole(name, customRoles)
+	if role == nil {
+		if findRole(name, roles) != nil {
+			term.OutputErrorAndExit("'%s' is a built-in role and can't be changed", name)
+		}
+		term.OutputErrorAndExit("Custom role '%s' not found", name)
+	}
+
+	return role
+}
+
+func mustParseRolePermissions(args []string, projects []*shared.Project) []*shared.OrgRolePermission {
+	var res []*shared.OrgRolePermission
+
+	for _, arg := range args {
+		name, project, _ := strings.Cut(arg, ":")
+		permission := &shared.OrgRolePermission{Permission: name}
+
+		if project != "" {
+			permission.ResourceId = mustResolveRoleProjectId(project, projects)
+		} else if roleThisProject {
+			customPermission := shared.GetCustomRolePermission(name)
+			if customPermission != nil && customPermission.ProjectScoped {
+				lib.MustResolveProject()
+				permission.ResourceId = lib.CurrentProjectId
+			}
+		}
+
+		res = append(res, permission)
+	}
+
+	return res
+}
+
+func mustResolveRoleProjectId(nameOrId string, projects []*shared.Project) string {
+	for _, project := range projects {
+		if project.Id == nameOrId || project.Name == nameOrId {
+			return project.Id
+		}
+	}
+	term.OutputErrorAndExit("Project '%s' not found", nameOrId)
+	return ""
+}
+
+func mustSelectRolePermissions(projects []*shared.Project) []*shared.OrgRolePermission {
+	const done = "Done"
+	const allProjects = "All projects"
+
+	var res []*shared.OrgRolePermission
+
+	for {
+		opts := []string{done}
+		for _, permission := range shared.CustomRolePermissions {
+			opts = append(opts, fmt.Sprintf("%s: %s", permission.Name, permission.Description))
+		}
+
+		selected, err := term.SelectFromList("Add a permission:", opts)
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting permission: %v", err)
+		}
+
+		if selected == done {
+			return res
+		}
+
+		name, _, _ := strings.Cut(selected, ":")
+		permission := &shared.OrgRolePermission{Permission: name}
+
+		if shared.GetCustomRolePermission(name).ProjectScoped && len(projects) > 0 {
+			projectOpts := []string{allProjects}
+			for _, project := range projects {
+				projectOpts = append(projectOpts, project.Name)
+			}
+
+			selectedProject, err := term.SelectFromList("Limit to a project?", projectOpts)
+			if err != nil {
+				term.OutputErrorAndExit("Error selecting project: %v", err)
+			}
+
+			if selectedProject != allProjects {
+				permission.ResourceId = mustResolveRoleProjectId(selectedProject, projects)
+			}
+		}
+
+		res = append(res, permission)
+		fmt.Printf("Added %s\n", formatRolePermission(permission, projects))
+	}
+}
+
+func formatRolePermission(permission *shared.OrgRolePermission, projects []*shared.Project) string {
+	if permission.ResourceId == "" {
+		return permission.Permission
+	}
+
+	for _, project := range projects {
+		if project.Id == permission.ResourceId {
+			return fmt.Sprintf("%s (project: %s)", permission.Permission, project.Name)
+		}
+	}
+
+	return fmt.Sprintf("%s (project: %s)", permission.Permission, permission.ResourceId)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"plandex/auth"
 	"plandex/term"
 
 	"github.com/fatih/color"
@@ -10,6 +11,7 @@ import (
 )
 
 var helpShowAll bool
+var useLocal bool
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -17,6 +19,14 @@ var RootCmd = &cobra.Command{
 	// Short: "Plandex: iterative development with AI",
 	SilenceErrors: true,
 	SilenceUsage:  true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if useLocal {
+			err := auth.SignInLocal()
+			if err != nil {
+				term.OutputErrorAndExit("Error switching to local mode: %v", err)
+			}
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		run(cmd, args)
 	},
@@ -51,6 +61,9 @@ func Execute() {
 }
 
 func run(cmd *cobra.Command, args []string) {
+	if useLocal {
+		term.PrintCmds("", "new", "plans")
+	}
 }
 
 func init() {
@@ -68,4 +81,6 @@ func init() {
 
 	// add an --all/-a flag
 	helpCmd.Flags().BoolVarP(&helpShowAll, "all", "a", false, "Show all commands")
+
+	RootCmd.PersistentFlags().BoolVar(&useLocal, "local", false, "Run Plandex locally with an in-process server (no account needed)")
 }
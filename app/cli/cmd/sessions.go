@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/format"
+	"plandex/term"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var revokeAllSessions bool
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List devices signed in to your account",
+	Run:   listSessions,
+}
+
+var revokeSessionCmd = &cobra.Command{
+	Use:     "revoke [index]",
+	Aliases: []string{"rm"},
+	Short:   "Sign out a device, or all other devices with --all",
+	Args:    cobra.MaximumNArgs(1),
+	Run:     revokeSession,
+}
+
+func init() {
+	RootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(revokeSessionCmd)
+
+	revokeSessionCmd.Flags().BoolVarP(&revokeAllSessions, "all", "a", false, "Sign out every device except this one")
+}
+
+func mustResolveSessionAuth() {
+	auth.MustResolveAuth(false)
+
+	if auth.Current.IsAccessToken {
+		term.OutputErrorAndExit("Sessions can't be managed with an access token--unset PLANDEX_TOKEN and sign in")
+	}
+}
+
+func listSessions(cmd *cobra.Command, args []string) {
+	mustResolveSessionAuth()
+
+	term.StartSpinner("")
+	sessions, apiErr := api.Client.ListSessions()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching sessions: %v", apiErr.Msg)
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"#", "Signed In", "Last Used", "Client Version", "IP"})
+
+	for i, session := range sessions {
+		num := strconv.Itoa(i + 1)
+		if session.IsCurrent {
+			num += " (this device)"
+		}
+
+		table.Append([]string{
+			num,
+			format.Time(session.CreatedAt),
+			sessionLastUsed(session),
+			valueOrUnknown(session.ClientVersion),
+			valueOrUnknown(session.Ip),
+		})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	term.PrintCmds("", "sessions revoke", "sessions revoke --all")
+}
+
+func revokeSession(cmd *cobra.Command, args []string) {
+	mustResolveSessionAuth()
+
+	if revokeAllSessions {
+		term.StartSpinner("")
+		res, apiErr := api.Client.RevokeOtherSessions()
+		term.StopSpinner()
+
+		if apiErr != nil {
+			term.OutputErrorAndExit("Error revoking sessions: %v", apiErr.Msg)
+			return
+		}
+
+		fmt.Printf("✅ Signed out %d other %s\n", res.NumRevoked, pluralize("session", res.NumRevoked))
+		return
+	}
+
+	term.StartSpinner("")
+	sessions, apiErr := api.Client.ListSessions()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching sessions: %v", apiErr.Msg)
+		return
+	}
+
+	var toRevoke *shared.Session
+
+	if len(args) == 1 {
+		index, err := strconv.Atoi(args[0])
+		if err != nil || index < 1 || index > len(sessions) {
+			term.OutputErrorAndExit("No session at index '%s'--use 'plandex sessions' to list them", args[0])
+		}
+		toRevoke = sessions[index-1]
+	} else {
+		opts := make([]string, len(sessions))
+		for i, session := range sessions {
+			opts[i] = sessionLabel(i, session)
+		}
+
+		selected, err := term.SelectFromList("Select a session to sign out:", opts)
+
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting session: %v", err)
+		}
+
+		for i, opt := range opts {
+			if opt == selected {
+				toRevoke = sessions[i]
+				break
+			}
+		}
+	}
+
+	if toRevoke.IsCurrent {
+		confirmed, err := term.ConfirmYesNo("This is the session you're using now. You'll need to sign in again. Continue?")
+		if err != nil {
+			term.OutputErrorAndExit("Error confirming: %v", err)
+		}
+		if !confirmed {
+			return
+		}
+	}
+
+	term.StartSpinner("")
+	apiErr = api.Client.RevokeSession(toRevoke.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error revoking session: %v", apiErr.Msg)
+		return
+	}
+
+	if toRevoke.IsCurrent {
+		fmt.Println("✅ Signed out of this device")
+		fmt.Println()
+		term.PrintCmds("", "sign-in")
+	} else {
+		fmt.Println("✅ Signed out session")
+	}
+}
+
+func sessionLastUsed(session *shared.Session) string {
+	if session.IsCurrent {
+		return "now"
+	}
+	if session.LastUsedAt == nil {
+		return "unknown"
+	}
+	return format.Time(*session.LastUsedAt)
+}
+
+func sessionLabel(i int, session *shared.Session) string {
+	label := fmt.Sprintf("%d. signed in %s, last used %s, %s", i+1, format.Time(session.CreatedAt), sessionLastUsed(session), valueOrUnknown(session.Ip))
+	if session.IsCurrent {
+		label += " (this device)"
+	}
+	return label
+}
+
+func valueOrUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func pluralize(s string, n int) string {
+	if n == 1 {
+		return s
+	}
+	return s + "s"
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/lib"
+	"plandex/term"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [branch]",
+	Short: "Share the current plan branch read-only with your org",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   share,
+}
+
+var unshareCmd = &cobra.Command{
+	Use:   "unshare [branch]",
+	Short: "Stop sharing the current plan branch with your org",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   unshare,
+}
+
+func init() {
+	RootCmd.AddCommand(shareCmd)
+	RootCmd.AddCommand(unshareCmd)
+}
+
+func share(cmd *cobra.Command, args []string) {
+	branch := mustResolveShareBranch(args)
+
+	term.StartSpinner("")
+	apiErr := api.Client.SharePlan(lib.CurrentPlanId, branch)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error sharing plan: %v", apiErr.Msg)
+	}
+
+	fmt.Printf("✅ Shared branch %s with your org\n", color.New(color.Bold, term.ColorHiGreen).Sprint(branch))
+	fmt.Println()
+	fmt.Println("Org members can now list it with 'plandex plans --shared', and read its convo, context, diffs, and logs, or connect to watch it stream. They can't make changes unless their role allows updating any plan.")
+	fmt.Println()
+	term.PrintCmds("", "unshare")
+}
+
+func unshare(cmd *cobra.Command, args []string) {
+	branch := mustResolveShareBranch(args)
+
+	term.StartSpinner("")
+	apiErr := api.Client.UnsharePlan(lib.CurrentPlanId, branch)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error unsharing plan: %v", apiErr.Msg)
+	}
+
+	fmt.Printf("✅ Stopped sharing branch %s\n", color.New(color.Bold, term.ColorHiGreen).Sprint(branch))
+}
+
+func mustResolveShareBranch(args []string) string {
+	auth.MustResolveAuthWithOrg()
+	lib.MustResolveProject()
+
+	if lib.CurrentPlanId == "" {
+		term.OutputNoCurrentPlanErrorAndExit()
+	}
+
+	if len(args) > 0 {
+		return strings.TrimSpace(args[0])
+	}
+
+	return lib.CurrentBranch
+}
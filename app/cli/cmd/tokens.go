@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/format"
+	"plandex/lib"
+	"plandex/term"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var tokenName string
+var tokenScope string
+var tokenProjectIds []string
+var tokenThisProject bool
+var tokenExpiresInDays int
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "List your access tokens",
+	Run:   listTokens,
+}
+
+var createTokenCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an access token for CI and scripts",
+	Run:   createToken,
+}
+
+var revokeTokenCmd = &cobra.Command{
+	Use:     "revoke [name-or-index]",
+	Aliases: []string{"rm"},
+	Short:   "Revoke an access token by name or index",
+	Args:    cobra.MaximumNArgs(1),
+	Run:     revokeToken,
+}
+
+func init() {
+	RootCmd.AddCommand(tokensCmd)
+	tokensCmd.AddCommand(createTokenCmd)
+	tokensCmd.AddCommand(revokeTokenCmd)
+
+	createTokenCmd.Flags().StringVarP(&tokenName, "name", "n", "", "Name for the token")
+	createTokenCmd.Flags().StringVarP(&tokenScope, "scope", "s", string(shared.AccessTokenScopeExecute), "Token scope: read, execute, or admin")
+	createTokenCmd.Flags().StringSliceVarP(&tokenProjectIds, "project", "p", nil, "Restrict the token to a project id (repeatable)")
+	createTokenCmd.Flags().BoolVar(&tokenThisProject, "this-project", false, "Restrict the token to the current project")
+	createTokenCmd.Flags().IntVarP(&tokenExpiresInDays, "days", "d", 90, "Days until the token expires--0 for no expiration")
+}
+
+func listTokens(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	term.StartSpinner("")
+	tokens, apiErr := api.Client.ListAccessTokens()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching access tokens: %v", apiErr.Msg)
+		return
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("🤷‍♂️ No access tokens")
+		fmt.Println()
+		term.PrintCmds("", "tokens create")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"#", "Name", "Scope", "Projects", "Expires", "Last Used", "Created"})
+
+	for i, token := range tokens {
+		projects := "all"
+		if len(token.ProjectIds) > 0 {
+			projects = strings.Join(token.ProjectIds, "\n")
+		}
+
+		expires := "never"
+		if token.ExpiresAt != nil {
+			expires = format.Time(*token.ExpiresAt)
+		}
+
+		lastUsed := "never"
+		if token.LastUsedAt != nil {
+			lastUsed = format.Time(*token.LastUsedAt)
+		}
+
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			token.Name,
+			string(token.Scope),
+			projects,
+			expires,
+			lastUsed,
+			format.Time(token.CreatedAt),
+		})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	term.PrintCmds("", "tokens create", "tokens revoke")
+}
+
+func createToken(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	scope, err := shared.ParseAccessTokenScope(tokenScope)
+	if err != nil {
+		term.OutputErrorAndExit("%v", err)
+	}
+
+	if tokenExpiresInDays < 0 {
+		term.OutputErrorAndExit("--days can't be negative")
+	}
+
+	name := tokenName
+	if name == "" {
+		name, err = term.GetRequiredUserStringInput("Name for the token (e.g. 'github-actions'):")
+		if err != nil {
+			term.OutputErrorAndExit("Error reading token name: %v", err)
+		}
+	}
+
+	projectIds := tokenProjectIds
+	if tokenThisProject {
+		lib.MustResolveProject()
+		projectIds = append(projectIds, lib.CurrentProjectId)
+	}
+
+	term.StartSpinner("")
+	res, apiErr := api.Client.CreateAccessToken(shared.CreateAccessTokenRequest{
+		Name:          name,
+		Scope:         scope,
+		ProjectIds:    projectIds,
+		ExpiresInDays: tokenExpiresInDays,
+	})
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error creating access token: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Created access token %s with %s scope\n", color.New(color.Bold, term.ColorHiCyan).Sprint(res.AccessToken.Name), res.AccessToken.Scope)
+	fmt.Println()
+	fmt.Println(res.Token)
+	fmt.Println()
+	fmt.Println("Copy it now--it won't be shown again. Set it as PLANDEX_TOKEN in your CI environment to run plandex without signing in.")
+	fmt.Println()
+
+	term.PrintCmds("", "tokens", "tokens revoke")
+}
+
+func revokeToken(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	term.StartSpinner("")
+	tokens, apiErr := api.Client.ListAccessTokens()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching access tokens: %v", apiErr.Msg)
+		return
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println("🤷‍♂️ No access tokens")
+		return
+	}
+
+	var toRevoke *shared.AccessToken
+
+	if len(args) == 1 {
+		input := args[0]
+		// Try to parse input as index
+		index, err := strconv.Atoi(input)
+		if err == nil && index > 0 && index <= len(tokens) {
+			toRevoke = tokens[index-1]
+		} else {
+			// Search by name
+			for _, t := range tokens {
+				if t.Name == input {
+					toRevoke = t
+					break
+				}
+			}
+		}
+
+		if toRevoke == nil {
+			term.OutputErrorAndExit("No access token found for '%s'", input)
+		}
+	}
+
+	if toRevoke == nil {
+		opts := make([]string, len(tokens))
+		for i, t := range tokens {
+			opts[i] = fmt.Sprintf("%d. %s (%s)", i+1, t.Name, t.Scope)
+		}
+
+		selected, err := term.SelectFromList("Select an access token:", opts)
+
+		if err != nil {
+			term.OutputErrorAndExit("Error selecting access token: %v", err)
+		}
+
+		for i, opt := range opts {
+			if opt == selected {
+				toRevoke = tokens[i]
+				break
+			}
+		}
+	}
+
+	term.StartSpinner("")
+	apiErr = api.Client.RevokeAccessToken(toRevoke.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error revoking access token: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Revoked access token %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(toRevoke.Name))
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"plandex/auth"
+	"plandex/lib"
+	"plandex/term"
+
+	"github.com/spf13/cobra"
+)
+
+var unapplyAutoConfirm bool
+
+func init() {
+	unapplyCmd.Flags().BoolVarP(&unapplyAutoConfirm, "yes", "y", false, "Automatically confirm")
+
+	RootCmd.AddCommand(unapplyCmd)
+}
+
+var unapplyCmd = &cobra.Command{
+	Use:   "unapply",
+	Short: "Undo the last apply",
+	Long:  "Undo the last apply. Restores the project files that were overwritten (undoing the apply commit if it's still at HEAD) and marks the applied changes as pending again.",
+	Args:  cobra.NoArgs,
+	Run:   unapply,
+}
+
+func unapply(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+	lib.MustResolveProject()
+
+	if lib.CurrentPlanId == "" {
+		term.OutputNoCurrentPlanErrorAndExit()
+	}
+
+	lib.MustUnapplyPlan(lib.CurrentPlanId, lib.CurrentBranch, unapplyAutoConfirm)
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"plandex/api"
+	"plandex/auth"
+	"plandex/format"
+	"plandex/term"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/plandex/plandex/shared"
+	"github.com/spf13/cobra"
+)
+
+var webhookEvents []string
+var webhookListenPort int
+var webhookListenSecret string
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "List your org's webhooks",
+	Run:   listWebhooks,
+}
+
+var createWebhookCmd = &cobra.Command{
+	Use:   "create <url>",
+	Short: "Register a webhook for plan events",
+	Args:  cobra.ExactArgs(1),
+	Run:   createWebhook,
+}
+
+var deleteWebhookCmd = &cobra.Command{
+	Use:   "rm [url-or-index]",
+	Short: "Remove a webhook by url or index",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   deleteWebhook,
+}
+
+var webhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries [url-or-index]",
+	Short: "Show a webhook's latest deliveries",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   listWebhookDeliveries,
+}
+
+var testWebhookCmd = &cobra.Command{
+	Use:   "test [url-or-index]",
+	Short: "Send a test event to a webhook",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   testWebhook,
+}
+
+var listenWebhooksCmd = &cobra.Command{
+	Use:   "listen",
+	Short: "Receive webhooks locally and print them",
+	Args:  cobra.NoArgs,
+	Run:   listenWebhooks,
+}
+
+func init() {
+	RootCmd.AddCommand(webhooksCmd)
+	webhooksCmd.AddCommand(createWebhookCmd)
+	webhooksCmd.AddCommand(deleteWebhookCmd)
+	webhooksCmd.AddCommand(webhookDeliveriesCmd)
+	webhooksCmd.AddCommand(testWebhookCmd)
+	webhooksCmd.AddCommand(listenWebhooksCmd)
+
+	var events []string
+	for _, event := range shared.WebhookEvents {
+		events = append(events, string(event))
+	}
+	createWebhookCmd.Flags().StringSliceVarP(&webhookEvents, "event", "e", nil, "Event to send (repeatable)--defaults to all of: "+strings.Join(events, ", "))
+
+	listenWebhooksCmd.Flags().IntVarP(&webhookListenPort, "port", "p", 8787, "Port to listen on")
+	listenWebhooksCmd.Flags().StringVarP(&webhookListenSecret, "secret", "s", "", "Signing secret to verify deliveries with")
+}
+
+func listWebhooks(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	webhooks := mustGetWebhooks()
+
+	if len(webhooks) == 0 {
+		fmt.Println("🤷‍♂️ No webhooks")
+		fmt.Println()
+		term.PrintCmds("", "webhooks create")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"#", "Url", "Events", "Created"})
+
+	for i, webhook := range webhooks {
+		var events []string
+		for _, event := range webhook.Events {
+			events = append(events, string(event))
+		}
+
+		table.Append([]string{
+			strconv.Itoa(i + 1),
+			webhook.Url,
+			strings.Join(events, "\n"),
+			format.Time(webhook.CreatedAt),
+		})
+	}
+
+	table.Render()
+	fmt.Println()
+
+	term.PrintCmds("", "webhooks create", "webhooks test", "webhooks deliveries", "webhooks rm")
+}
+
+func createWebhook(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	req := shared.CreateWebhookRequest{Url: args[0]}
+	for _, event := range webhookEvents {
+		req.Events = append(req.Events, shared.WebhookEvent(strings.TrimSpace(event)))
+	}
+	if len(req.Events) == 0 {
+		req.Events = shared.WebhookEvents
+	}
+
+	err := shared.ValidateWebhookRequest(req)
+	if err != nil {
+		term.OutputErrorAndExit("%v", err)
+	}
+
+	term.StartSpinner("")
+	res, apiErr := api.Client.CreateWebhook(req)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error creating webhook: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Created webhook for %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(res.Webhook.Url))
+	fmt.Println()
+	fmt.Println("Signing secret:")
+	fmt.Println(res.Secret)
+	fmt.Println()
+	fmt.Printf("Copy it now--it won't be shown again. Each delivery is signed with it in the %s header: 't=<unix seconds>,v1=<hex hmac-sha256 of \"<t>.<body>\">'.\n", shared.WebhookSignatureHeader)
+	fmt.Println()
+
+	term.PrintCmds("", "webhooks test", "webhooks listen")
+}
+
+func deleteWebhook(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	webhook := mustSelectWebhook(args)
+	if webhook == nil {
+		return
+	}
+
+	term.StartSpinner("")
+	apiErr := api.Client.DeleteWebhook(webhook.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error removing webhook: %v", apiErr.Msg)
+		return
+	}
+
+	fmt.Printf("✅ Removed webhook for %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(webhook.Url))
+}
+
+func listWebhookDeliveries(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	webhook := mustSelectWebhook(args)
+	if webhook == nil {
+		return
+	}
+
+	term.StartSpinner("")
+	deliveries, apiErr := api.Client.ListWebhookDeliveries(webhook.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching webhook deliveries: %v", apiErr.Msg)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		fmt.Printf("🤷‍♂️ No deliveries to %s yet\n", webhook.Url)
+		fmt.Println()
+		term.PrintCmds("", "webhooks test")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Event", "Status", "Attempts", "Response", "Next Attempt", "Created"})
+
+	for _, delivery := range deliveries {
+		table.Append([]string{
+			string(delivery.Event),
+			formatWebhookDeliveryStatus(delivery.Status),
+			strconv.Itoa(delivery.Attempts),
+			formatWebhookDeliveryResponse(delivery),
+			formatWebhookNextAttempt(delivery),
+			format.Time(delivery.CreatedAt),
+		})
+	}
+
+	table.Render()
+}
+
+func testWebhook(cmd *cobra.Command, args []string) {
+	auth.MustResolveAuthWithOrg()
+
+	webhook := mustSelectWebhook(args)
+	if webhook == nil {
+		return
+	}
+
+	term.StartSpinner("")
+	delivery, apiErr := api.Client.TestWebhook(webhook.Id)
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error testing webhook: %v", apiErr.Msg)
+		return
+	}
+
+	if delivery.Status == shared.WebhookDeliveryStatusSucceeded {
+		fmt.Printf("✅ Delivered %s to %s (%d)\n", shared.WebhookEventTest, color.New(color.Bold, term.ColorHiCyan).Sprint(webhook.Url), delivery.ResponseStatus)
+		return
+	}
+
+	fmt.Printf("🚨 Couldn't deliver %s to %s\n", shared.WebhookEventTest, color.New(color.Bold, term.ColorHiCyan).Sprint(webhook.Url))
+	fmt.Println(delivery.Error)
+	os.Exit(1)
+}
+
+// listenWebhooks runs a local receiver that prints each delivery it gets, verifying signatures when given the secret. Point a webhook at it (e.g. 'plandex webhooks create http://localhost:8787') to try out events against a local server started with WEBHOOK_ALLOW_PRIVATE_URLS=1.
+func listenWebhooks(cmd *cobra.Command, args []string) {
+	addr := fmt.Sprintf("localhost:%d", webhookListenPort)
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Error reading body", http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("%s %s %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint(r.Header.Get(shared.WebhookEventHeader)), r.Header.Get(shared.WebhookDeliveryHeader), format.Time(time.Now()))
+
+		if webhookListenSecret != "" {
+			err = shared.VerifyWebhookSignature(webhookListenSecret, r.Header.Get(shared.WebhookSignatureHeader), body, time.Now())
+			if err != nil {
+				fmt.Printf("🚨 Invalid signature: %v\n\n", err)
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+				return
+			}
+			fmt.Println("✅ Signature verified")
+		}
+
+		var payload map[string]interface{}
+		if json.Unmarshal(body, &payload) == nil {
+			formatted, _ := json.MarshalIndent(payload, "", "  ")
+			body = formatted
+		}
+
+		fmt.Println(string(body))
+		fmt.Println()
+	})
+
+	fmt.Printf("Listening for webhooks at %s\n", color.New(color.Bold, term.ColorHiCyan).Sprint("http://"+addr))
+	if webhookListenSecret == "" {
+		fmt.Println("Pass --secret to verify signatures")
+	}
+	fmt.Println("Outside --local mode, the server only sends webhooks to localhost if it was started with WEBHOOK_ALLOW_PRIVATE_URLS=1")
+	fmt.Println()
+
+	err := http.ListenAndServe(addr, nil)
+	if err != nil {
+		term.OutputErrorAndExit("Error listening for webhooks: %v", err)
+	}
+}
+
+func mustGetWebhooks() []*shared.Webhook {
+	term.StartSpinner("")
+	webhooks, apiErr := api.Client.ListWebhooks()
+	term.StopSpinner()
+
+	if apiErr != nil {
+		term.OutputErrorAndExit("Error fetching webhooks: %v", apiErr.Msg)
+	}
+
+	return webhooks
+}
+
+// mustSelectWebhook gets the webhook chosen by url or index in args, or has the user pick one. It returns nil when there are none.
+func mustSelectWebhook(args []string) *shared.Webhook {
+	webhooks := mustGetWebhooks()
+
+	if len(webhooks) == 0 {
+		fmt.Println("🤷‍♂️ No webhooks")
+		fmt.Println()
+		term.PrintCmds("", "webhooks create")
+		return nil
+	}
+
+	if len(args) == 1 {
+		input := args[0]
+		index, err := strconv.Atoi(input)
+		if err == nil && index > 0 && index <= len(webhooks) {
+			return webhooks[index-1]
+		}
+
+		for _, webhook := range webhooks {
+			if webhook.Url == input || webhook.Id == input {
+				return webhook
+			}
+		}
+
+		term.OutputErrorAndExit("No webhook found for '%s'", input)
+	}
+
+	if len(webhooks) == 1 {
+		return webhooks[0]
+	}
+
+	opts := make([]string, len(webhooks))
+	for i, webhook := range webhooks {
+		opts[i] = fmt.Sprintf("%d. %s", i+1, webhook.Url)
+	}
+
+	selected, err := term.SelectFromList("Select a webhook:", opts)
+
+	if err != nil {
+		term.OutputErrorAndExit("Error selecting webhook: %v", err)
+	}
+
+	for i, opt := range opts {
+		if opt == selected {
+			return webhooks[i]
+		}
+	}
+
+	return nil
+}
+
+func formatWebhookDeliveryStatus(status shared.WebhookDeliveryStatus) string {
+	switch status {
+	case shared.WebhookDeliveryStatusSucceeded:
+		return color.New(color.FgGreen).Sprint(status)
+	case shared.WebhookDeliveryStatusFailed:
+		return color.New(color.FgRed).Sprint(status)
+	}
+	return string(status)
+}
+
+func formatWebhookDeliveryResponse(delivery *shared.WebhookDelivery) string {
+	if delivery.Error != "" {
+		return delivery.Error
+	}
+	if delivery.ResponseStatus != 0 {
+		return strconv.Itoa(delivery.ResponseStatus)
+	}
+	return ""
+}
+
+func formatWebhookNextAttempt(delivery *shared.WebhookDelivery) string {
+	if delivery.Status != shared.WebhookDeliveryStatusPending || delivery.NextAttemptAt == nil {
+		return ""
+	}
+	return format.Time(*delivery.NextAttemptAt)
+}
@@ -7,12 +7,13 @@ import (
 	"plandex/api"
 	"plandex/fs"
 	"plandex/term"
+	"sort"
 	"strings"
 
 	"github.com/plandex/plandex/shared"
 )
 
-func MustApplyPlan(planId, branch string, autoConfirm bool) {
+func MustApplyPlan(planId, branch string, autoConfirm bool, patterns, excludePatterns []string) {
 	term.StartSpinner("")
 
 	currentPlanState, apiErr := api.Client.GetCurrentPlanState(planId, branch)
@@ -81,8 +82,50 @@ func MustApplyPlan(planId, branch string, autoConfirm bool) {
 		return
 	}
 
+	isSelective := len(patterns) > 0 || len(excludePatterns) > 0
+	var selectedPaths []string
+	var numSkipped int
+
+	if isSelective {
+		var allPaths []string
+		for path := range toApply {
+			allPaths = append(allPaths, path)
+		}
+		sort.Strings(allPaths)
+
+		var err error
+		selectedPaths, err = shared.FilterPaths(allPaths, patterns, excludePatterns)
+
+		if err != nil {
+			term.StopSpinner()
+			term.OutputErrorAndExit("Error matching paths: %v", err)
+		}
+
+		if len(selectedPaths) == 0 {
+			term.StopSpinner()
+			fmt.Println("🤷‍♂️ No pending changes match the given paths")
+			return
+		}
+
+		selected := make(map[string]string, len(selectedPaths))
+		for _, path := range selectedPaths {
+			selected[path] = toApply[path]
+		}
+		numSkipped = len(toApply) - len(selected)
+		toApply = selected
+	}
+
 	if !autoConfirm {
 		term.StopSpinner()
+
+		if isSelective {
+			fmt.Println("Files to apply:")
+			for _, path := range selectedPaths {
+				fmt.Println(" • ", path)
+			}
+			fmt.Println()
+		}
+
 		numToApply := len(toApply)
 		suffix := ""
 		if numToApply > 1 {
@@ -119,10 +162,22 @@ func MustApplyPlan(planId, branch string, autoConfirm bool) {
 		openAIBase = os.Getenv("OPENAI_ENDPOINT")
 	}
 
+	for path, content := range toApply {
+		toApply[path] = strings.ReplaceAll(content, "\\`\\`\\`", "```")
+	}
+
+	// snapshot the files before they're overwritten so the apply can be undone with 'plandex unapply'
+	snapshot, err := newApplySnapshot(planId, branch, toApply)
+
+	if err != nil {
+		onErr("failed to snapshot files before applying: %v", err)
+	}
+
 	commitSummary, apiErr = api.Client.ApplyPlan(planId, branch, shared.ApplyPlanRequest{
 		ApiKeys:     apiKeys,
 		OpenAIBase:  openAIBase,
 		OpenAIOrgId: os.Getenv("OPENAI_ORG_ID"),
+		Paths:       selectedPaths,
 	})
 
 	if apiErr != nil {
@@ -130,13 +185,17 @@ func MustApplyPlan(planId, branch string, autoConfirm bool) {
 		return
 	}
 
+	err = writeApplySnapshot(snapshot)
+
+	if err != nil {
+		onErr("failed to store apply snapshot: %v", err)
+	}
+
 	var updatedFiles []string
 	for path, content := range toApply {
 		// Compute destination path
 		dstPath := filepath.Join(fs.ProjectRoot, path)
 
-		content = strings.ReplaceAll(content, "\\`\\`\\`", "```")
-
 		// Check if the file exists
 		var exists bool
 		_, err := os.Stat(dstPath)
@@ -188,6 +247,19 @@ func MustApplyPlan(planId, branch string, autoConfirm bool) {
 
 	term.StopSpinner()
 
+	defer func() {
+		if numSkipped > 0 {
+			suffix := ""
+			if numSkipped > 1 {
+				suffix = "s"
+			}
+			fmt.Println()
+			fmt.Printf("⏳ Changes to %d file%s are still pending\n", numSkipped, suffix)
+			fmt.Println()
+			term.PrintCmds("", "apply", "changes", "diff")
+		}
+	}()
+
 	if len(updatedFiles) == 0 {
 		fmt.Println("✅ Applied changes, but no files were updated")
 		return
@@ -216,6 +288,15 @@ func MustApplyPlan(planId, branch string, autoConfirm bool) {
 				err := GitAddAndCommitPaths(fs.ProjectRoot, msg, updatedFiles, true)
 				if err != nil {
 					onGitErr("Failed to commit changes:", err.Error())
+				} else {
+					sha, err := GitHeadSha(fs.ProjectRoot)
+					if err == nil {
+						snapshot.GitCommitSha = sha
+						err = writeApplySnapshot(snapshot)
+					}
+					if err != nil {
+						onGitErr("Failed to record apply commit for 'plandex unapply':", err.Error())
+					}
 				}
 			}
 		}
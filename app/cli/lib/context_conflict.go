@@ -5,8 +5,11 @@ import (
 	"os"
 	"plandex/api"
 	"plandex/term"
+	"sort"
+	"strings"
 
 	"github.com/fatih/color"
+	"github.com/plandex/plandex/shared"
 )
 
 func checkContextConflicts(filesByPath map[string]string) (bool, error) {
@@ -19,15 +22,40 @@ func checkContextConflicts(filesByPath map[string]string) (bool, error) {
 		return false, fmt.Errorf("error getting current plan state: %v", err)
 	}
 
-	conflictedPaths := currentPlan.PlanResult.FileResultsByPath.ConflictedPaths(filesByPath)
+	mergeResults := currentPlan.MergeContextUpdates(filesByPath)
+
+	var mergedPaths []string
+	conflictedPaths := map[string]*shared.Merge3Result{}
+	for path, res := range mergeResults {
+		if res == nil || res.HasConflicts() {
+			conflictedPaths[path] = res
+		} else {
+			mergedPaths = append(mergedPaths, path)
+		}
+	}
+	sort.Strings(mergedPaths)
 
 	// log.Println("Conflicted paths:", conflictedPaths)
 
+	if len(mergedPaths) > 0 {
+		term.StopSpinner()
+		color.New(color.Bold, term.ColorHiCyan).Println("🔀 Updates will be merged with pending changes:")
+		for _, path := range mergedPaths {
+			fmt.Println("📄 " + path)
+		}
+		fmt.Println()
+		term.ResumeSpinner()
+	}
+
 	if len(conflictedPaths) > 0 {
 		term.StopSpinner()
 		color.New(color.Bold, term.ColorHiYellow).Println("⚠️  Some updates conflict with pending changes:")
-		for path := range conflictedPaths {
-			fmt.Println("📄 " + path)
+		for path, res := range conflictedPaths {
+			if res == nil {
+				fmt.Println("📄 " + path)
+			} else {
+				printMergeConflicts(path, res.Conflicts)
+			}
 		}
 
 		fmt.Println()
@@ -46,3 +74,59 @@ func checkContextConflicts(filesByPath map[string]string) (bool, error) {
 
 	return len(conflictedPaths) > 0, nil
 }
+
+// PrintPendingMergeConflicts shows the conflicted regions for any paths that were invalidated by a context update and haven't been rebuilt yet. Returns whether there were any.
+func PrintPendingMergeConflicts(currentPlan *shared.CurrentPlanState) bool {
+	conflictsByPath := map[string][]*shared.MergeConflict{}
+	for _, desc := range currentPlan.ConvoMessageDescriptions {
+		for path, conflicts := range desc.BuildPathConflicts {
+			if desc.BuildPathsInvalidated[path] {
+				conflictsByPath[path] = conflicts
+			}
+		}
+	}
+
+	if len(conflictsByPath) == 0 {
+		return false
+	}
+
+	var paths []string
+	for path := range conflictsByPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	color.New(color.Bold, term.ColorHiYellow).Println("⚠️  Your updates to these files conflict with pending changes:")
+	for _, path := range paths {
+		printMergeConflicts(path, conflictsByPath[path])
+	}
+	fmt.Println()
+
+	return true
+}
+
+func printMergeConflicts(path string, conflicts []*shared.MergeConflict) {
+	suffix := ""
+	if len(conflicts) > 1 {
+		suffix = "s"
+	}
+	fmt.Printf("📄 %s • %d conflicting region%s\n", path, len(conflicts), suffix)
+
+	for _, conflict := range conflicts {
+		fmt.Println()
+		color.New(color.FgHiWhite).Printf("  line %d\n", conflict.StartLine)
+		color.New(color.FgGreen).Println(indentConflictText("<<<<<<< yours", conflict.Ours))
+		color.New(color.FgHiBlack).Println(indentConflictText("||||||| original", conflict.Base))
+		color.New(color.FgMagenta).Println(indentConflictText("======= plandex", conflict.Theirs))
+		fmt.Println("  >>>>>>>")
+	}
+	fmt.Println()
+}
+
+func indentConflictText(header, text string) string {
+	lines := []string{"  " + header}
+	for _, line := range shared.SplitLines(text) {
+		lines = append(lines, "  "+strings.TrimSuffix(line, "\n"))
+	}
+	return strings.Join(lines, "\n")
+}
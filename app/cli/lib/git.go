@@ -88,6 +88,41 @@ func GitCommit(repoDir, commitMsg string, paths []string, lockMutex bool) error
 	return nil
 }
 
+func GitHeadSha(repoDir string) (string, error) {
+	gitMutex.Lock()
+	defer gitMutex.Unlock()
+
+	res, err := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error getting HEAD sha for dir: %s, err: %v, output: %s", repoDir, err, string(res))
+	}
+
+	return strings.TrimSpace(string(res)), nil
+}
+
+// GitUndoCommit removes the commit at HEAD, leaving its changes in the working tree and unstaging the given paths. Any other staged changes are left as they are.
+func GitUndoCommit(repoDir string, paths []string) error {
+	gitMutex.Lock()
+	defer gitMutex.Unlock()
+
+	res, err := exec.Command("git", "-C", repoDir, "reset", "--soft", "HEAD~1").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error undoing commit for dir: %s, err: %v, output: %s", repoDir, err, string(res))
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	args := append([]string{"-C", repoDir, "reset", "-q", "--"}, paths...)
+	res, err = exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error unstaging files for dir: %s, err: %v, output: %s", repoDir, err, string(res))
+	}
+
+	return nil
+}
+
 func CheckUncommittedChanges() (bool, error) {
 	gitMutex.Lock()
 	defer gitMutex.Unlock()
@@ -0,0 +1,246 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plandex/api"
+	"plandex/fs"
+	"plandex/term"
+	"plandex/types"
+	"sort"
+	"time"
+)
+
+func MustUnapplyPlan(planId, branch string, autoConfirm bool) {
+	term.StartSpinner("")
+
+	snapshot, err := getApplySnapshot(planId, branch)
+
+	if err != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error loading apply snapshot: %v", err)
+	}
+
+	if snapshot == nil {
+		term.StopSpinner()
+		fmt.Println("🤷‍♂️ No apply to undo")
+		return
+	}
+
+	var paths []string
+	var modifiedPaths []string
+	// the files as they are now, to put back if the unapply can't be completed
+	current := make(map[string]*types.ApplySnapshotFile, len(snapshot.Files))
+	for path, file := range snapshot.Files {
+		paths = append(paths, path)
+
+		bytes, err := os.ReadFile(filepath.Join(fs.ProjectRoot, path))
+		if err != nil && !os.IsNotExist(err) {
+			term.StopSpinner()
+			term.OutputErrorAndExit("Error reading %s: %v", path, err)
+		}
+
+		current[path] = &types.ApplySnapshotFile{Existed: err == nil, Content: string(bytes)}
+
+		if os.IsNotExist(err) || string(bytes) != file.Applied {
+			modifiedPaths = append(modifiedPaths, path)
+		}
+	}
+	sort.Strings(paths)
+	sort.Strings(modifiedPaths)
+
+	term.StopSpinner()
+
+	if !autoConfirm {
+		if len(modifiedPaths) > 0 {
+			fmt.Println("⚠️  These files have changed since the plan was applied:")
+			for _, path := range modifiedPaths {
+				fmt.Println(" • ", path)
+			}
+			fmt.Println()
+			fmt.Println("Unapplying will overwrite those changes.")
+			fmt.Println()
+		}
+
+		suffix := ""
+		if len(paths) > 1 {
+			suffix = "s"
+		}
+		shouldContinue, err := term.ConfirmYesNo("Undo the last apply, restoring %d file%s and marking the changes pending again?", len(paths), suffix)
+
+		if err != nil {
+			term.OutputErrorAndExit("failed to get confirmation user input: %s", err)
+		}
+
+		if !shouldContinue {
+			os.Exit(0)
+		}
+	}
+
+	term.StartSpinner("")
+
+	// restore files before the server marks the changes pending again, so a failed write leaves the apply in place to retry rather than half undone
+	err = writeApplySnapshotFiles(paths, snapshot.Files)
+
+	if err != nil {
+		rollbackErr := writeApplySnapshotFiles(paths, current)
+		term.StopSpinner()
+		if rollbackErr != nil {
+			term.OutputErrorAndExit("Error restoring files: %v\n\nError rolling back restored files: %v", err, rollbackErr)
+		}
+		term.OutputErrorAndExit("Error restoring files: %v", err)
+	}
+
+	apiErr := api.Client.UnapplyPlan(planId, branch)
+
+	if apiErr != nil {
+		rollbackErr := writeApplySnapshotFiles(paths, current)
+		term.StopSpinner()
+		if rollbackErr != nil {
+			term.OutputErrorAndExit("Error unapplying plan: %v\n\nError rolling back restored files: %v", apiErr.Msg, rollbackErr)
+		}
+		term.OutputErrorAndExit("Error unapplying plan: %v", apiErr.Msg)
+	}
+
+	if snapshot.GitCommitSha != "" && fs.ProjectRootIsGitRepo() {
+		headSha, err := GitHeadSha(fs.ProjectRoot)
+
+		// only undo the commit if nothing has been committed on top of it
+		if err == nil && headSha == snapshot.GitCommitSha {
+			err = GitUndoCommit(fs.ProjectRoot, paths)
+		}
+
+		// the plan is already unapplied at this point, so a commit that can't be undone is only reported
+		if err != nil {
+			term.StopSpinner()
+			fmt.Printf("⚠️  Couldn't undo the apply commit %s—files were restored in the working tree only: %v\n\n", snapshot.GitCommitSha[:7], err)
+			term.ResumeSpinner()
+		} else if headSha != snapshot.GitCommitSha {
+			term.StopSpinner()
+			fmt.Printf("⚠️  The apply commit %s is no longer at HEAD, so it won't be undone—files were restored in the working tree only\n\n", snapshot.GitCommitSha[:7])
+			term.ResumeSpinner()
+		}
+	}
+
+	err = deleteApplySnapshot(planId, branch)
+
+	if err != nil {
+		term.StopSpinner()
+		term.OutputErrorAndExit("Error removing apply snapshot: %v", err)
+	}
+
+	term.StopSpinner()
+
+	suffix := ""
+	if len(paths) > 1 {
+		suffix = "s"
+	}
+	fmt.Printf("✅ Unapplied changes, %d file%s restored\n", len(paths), suffix)
+	fmt.Println()
+	term.PrintCmds("", "changes", "diff", "apply")
+}
+
+// writeApplySnapshotFiles writes each path's content, or removes it if it didn't exist
+func writeApplySnapshotFiles(paths []string, files map[string]*types.ApplySnapshotFile) error {
+	for _, path := range paths {
+		file := files[path]
+		dstPath := filepath.Join(fs.ProjectRoot, path)
+
+		if file.Existed {
+			err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm)
+			if err != nil {
+				return fmt.Errorf("error creating directory for %s: %v", path, err)
+			}
+
+			err = os.WriteFile(dstPath, []byte(file.Content), 0644)
+			if err != nil {
+				return fmt.Errorf("error writing %s: %v", path, err)
+			}
+		} else {
+			err := os.Remove(dstPath)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing %s: %v", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func newApplySnapshot(planId, branch string, toApply map[string]string) (*types.ApplySnapshot, error) {
+	snapshot := &types.ApplySnapshot{
+		PlanId:    planId,
+		Branch:    branch,
+		CreatedAt: time.Now(),
+		Files:     make(map[string]*types.ApplySnapshotFile, len(toApply)),
+	}
+
+	for path, content := range toApply {
+		file := &types.ApplySnapshotFile{Applied: content}
+
+		bytes, err := os.ReadFile(filepath.Join(fs.ProjectRoot, path))
+		if err == nil {
+			file.Existed = true
+			file.Content = string(bytes)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+
+		snapshot.Files[path] = file
+	}
+
+	return snapshot, nil
+}
+
+func applySnapshotPath(planId, branch string) string {
+	return filepath.Join(HomeCurrentProjectDir, "applies", planId, branch+".json")
+}
+
+func writeApplySnapshot(snapshot *types.ApplySnapshot) error {
+	path := applySnapshotPath(snapshot.PlanId, snapshot.Branch)
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error creating apply snapshot dir: %v", err)
+	}
+
+	bytes, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("error marshalling apply snapshot: %v", err)
+	}
+
+	err = os.WriteFile(path, bytes, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing apply snapshot: %v", err)
+	}
+
+	return nil
+}
+
+func getApplySnapshot(planId, branch string) (*types.ApplySnapshot, error) {
+	bytes, err := os.ReadFile(applySnapshotPath(planId, branch))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading apply snapshot: %v", err)
+	}
+
+	var snapshot types.ApplySnapshot
+	err = json.Unmarshal(bytes, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling apply snapshot: %v", err)
+	}
+
+	return &snapshot, nil
+}
+
+func deleteApplySnapshot(planId, branch string) error {
+	err := os.Remove(applySnapshotPath(planId, branch))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -55,4 +55,7 @@ func main() {
 	}
 
 	cmd.Execute()
+
+	// in local mode, plans run inside this process
+	auth.WaitForLocalPlans()
 }
@@ -45,6 +45,10 @@ type streamUIModel struct {
 	tokensByPath   map[string]int
 	finishedByPath map[string]bool
 
+	// where builds and the reply are in the server's model call queue, when they're waiting on it
+	queuedByPath       map[string]int
+	replyQueuePosition int
+
 	ready  bool
 	width  int
 	height int
@@ -160,6 +164,7 @@ func initialModel(prestartReply, prompt string, buildOnly bool) *streamUIModel {
 
 		tokensByPath:   make(map[string]int),
 		finishedByPath: make(map[string]bool),
+		queuedByPath:   make(map[string]int),
 		spinner:        s,
 		buildSpinner:   buildSpinner,
 		atScrollBottom: true,
@@ -351,6 +351,28 @@ func (m *streamUIModel) streamUpdate(msg *shared.StreamMessage, deferUIUpdate bo
 		}
 		return m, tea.Batch(cmds...)
 
+	case shared.StreamMessageQueued:
+		if msg.QueueInfo == nil {
+			break
+		}
+
+		if msg.QueueInfo.Path == "" {
+			m.replyQueuePosition = msg.QueueInfo.Position
+			return m, m.spinner.Tick
+		}
+
+		m.building = true
+		m.queuedByPath[msg.QueueInfo.Path] = msg.QueueInfo.Position
+		if _, ok := m.tokensByPath[msg.QueueInfo.Path]; !ok {
+			m.tokensByPath[msg.QueueInfo.Path] = 0
+		}
+
+		if !deferUIUpdate {
+			m.updateViewportDimensions()
+		}
+
+		return m, m.buildSpinner.Tick
+
 	case shared.StreamMessageDescribing:
 		m.processing = true
 
@@ -51,6 +51,9 @@ func (m streamUIModel) renderHelp() string {
 
 func (m streamUIModel) renderProcessing() string {
 	if m.starting || m.processing {
+		if m.replyQueuePosition > 0 {
+			return "\n " + m.spinner.View() + color.New(color.FgHiBlack).Sprintf(" waiting for the model (%s in line)", ordinal(m.replyQueuePosition))
+		}
 		return "\n " + m.spinner.View()
 	} else {
 		return ""
@@ -133,6 +136,8 @@ func (m streamUIModel) getRows(static bool) []string {
 			block += " ✅"
 		} else if tokens > 0 {
 			block += fmt.Sprintf(" %d 🪙", tokens)
+		} else if position := m.queuedByPath[filePath]; position > 0 && !static {
+			block += fmt.Sprintf(" ⏳ %s in line", ordinal(position))
 		} else {
 			block += " " + m.buildSpinner.View()
 		}
@@ -216,3 +221,19 @@ func (m streamUIModel) renderMissingFilePrompt() string {
 
 	return style.Render(prompt)
 }
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
@@ -10,21 +10,27 @@ import (
 )
 
 var CmdDesc = map[string][2]string{
-	"new":     {"", "start a new plan"},
-	"rename":  {"", "rename the current plan"},
-	"current": {"cu", "show current plan"},
-	"cd":      {"", "set current plan by name or index"},
-	"load":    {"l", "load files, dirs, urls, notes, images, or piped data into context"},
-	"tell":    {"t", "describe a task, ask a question, or chat"},
-	"changes": {"ch", "review pending changes in a TUI"},
-	"diff":    {"", "review pending changes in 'git diff' format"},
-	"summary": {"", "show the latest summary of the current plan"},
+	"new":          {"", "start a new plan"},
+	"rename":       {"", "rename the current plan"},
+	"current":      {"cu", "show current plan"},
+	"cd":           {"", "set current plan by name or index"},
+	"load":         {"l", "load files, dirs, urls, notes, images, or piped data into context"},
+	"tell":         {"t", "describe a task, ask a question, or chat"},
+	"changes":      {"ch", "review pending changes in a TUI"},
+	"diff":         {"", "review pending changes in 'git diff' format"},
+	"export-patch": {"", "export pending changes as a 'git format-patch' series"},
+	"summary":      {"", "show the latest summary of the current plan"},
 	// "preview":     {"pv", "preview the plan in a branch"},
-	"apply":     {"ap", "apply pending changes to project files"},
-	"reject":    {"rj", "reject pending changes to one or more project files"},
-	"archive":   {"arc", "archive a plan"},
-	"unarchive": {"unarc", "unarchive a plan"},
-	"continue":  {"c", "continue the plan"},
+	"apply":            {"ap", "apply pending changes to project files, or only to paths or globs"},
+	"unapply":          {"", "undo the last apply and mark its changes pending again"},
+	"reject":           {"rj", "reject pending changes to one or more project files"},
+	"comments":         {"", "list review comments on pending changes"},
+	"comments add":     {"", "comment on a file's pending changes, or a specific change with --change"},
+	"comments resolve": {"", "resolve review comments by index, or all of them"},
+	"comments address": {"", "send open review comments to the plan as a prompt"},
+	"archive":          {"arc", "archive a plan"},
+	"unarchive":        {"unarc", "unarchive a plan"},
+	"continue":         {"c", "continue the plan"},
 	// "status":      {"s", "show status of the plan"},
 	"rewind":                    {"rw", "rewind to a previous state"},
 	"ls":                        {"", "list everything in context"},
@@ -34,6 +40,10 @@ var CmdDesc = map[string][2]string{
 	"delete-branch":             {"db", "delete a branch by name or index"},
 	"plans":                     {"pl", "list plans"},
 	"plans --archived":          {"", "list archived plans"},
+	"plans --shared":            {"", "list plans other org members have shared"},
+	"cd --shared":               {"", "set current plan to one shared by another org member"},
+	"share":                     {"", "share the current branch read-only with your org"},
+	"unshare":                   {"", "stop sharing the current branch"},
 	"update":                    {"u", "update outdated context"},
 	"log":                       {"", "show log of plan updates"},
 	"convo":                     {"", "show plan conversation"},
@@ -59,9 +69,29 @@ var CmdDesc = map[string][2]string{
 	"stop":                      {"", "stop an active plan stream"},
 	"connect":                   {"conn", "connect to an active plan stream"},
 	"sign-in":                   {"", "sign in, accept an invite, or create an account"},
+	"--local":                   {"", "use plandex locally with an in-process server--no account needed"},
 	"invite":                    {"", "invite a user to join your org"},
 	"revoke":                    {"", "revoke an invite or remove a user from your org"},
 	"users":                     {"", "list users and pending invites in your org"},
+	"sessions":                  {"", "list devices signed in to your account"},
+	"sessions revoke":           {"", "sign out a device"},
+	"sessions revoke --all":     {"", "sign out every device except this one"},
+	"tokens":                    {"", "list your access tokens for CI and scripts"},
+	"tokens create":             {"", "create an access token--set it as PLANDEX_TOKEN to use it"},
+	"tokens revoke":             {"", "revoke an access token"},
+	"roles":                     {"", "list your org's roles and their permissions"},
+	"roles create":              {"", "create a custom role, optionally limited to projects"},
+	"roles update":              {"", "update a custom role's permissions"},
+	"roles delete":              {"", "delete a custom role"},
+	"roles assign":              {"", "give a user in your org a different role"},
+	"audit":                     {"", "list your org's audit log--filter with --actor, --action, --since, --until"},
+	"audit --jsonl":             {"", "export your org's audit log as JSON lines"},
+	"webhooks":                  {"", "list your org's webhooks"},
+	"webhooks create":           {"", "register a webhook for plan events--choose them with --event"},
+	"webhooks rm":               {"", "remove a webhook"},
+	"webhooks deliveries":       {"", "show a webhook's latest deliveries and retries"},
+	"webhooks test":             {"", "send a test event to a webhook"},
+	"webhooks listen":           {"", "receive webhooks locally and print them--verify with --secret"},
 }
 
 func PrintCmds(prefix string, cmds ...string) {
@@ -139,11 +169,11 @@ func PrintCustomHelp(all bool) {
 		fmt.Fprintln(builder)
 
 		color.New(color.Bold, color.BgCyan, color.FgHiWhite).Fprintln(builder, " Plans ")
-		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "new", "plans", "cd", "current", "delete-plan", "rename", "archive", "plans --archived", "unarchive")
+		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "new", "plans", "cd", "current", "delete-plan", "rename", "archive", "plans --archived", "unarchive", "share", "unshare", "plans --shared", "cd --shared")
 		fmt.Fprintln(builder)
 
 		color.New(color.Bold, color.BgCyan, color.FgHiWhite).Fprintln(builder, " Changes ")
-		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "changes", "diff", "apply", "reject")
+		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "changes", "diff", "export-patch", "apply", "unapply", "reject", "comments", "comments add", "comments resolve", "comments address")
 		fmt.Fprintln(builder)
 
 		color.New(color.Bold, color.BgCyan, color.FgHiWhite).Fprintln(builder, " Context ")
@@ -171,7 +201,7 @@ func PrintCustomHelp(all bool) {
 		fmt.Fprintln(builder)
 
 		color.New(color.Bold, color.BgCyan, color.FgHiWhite).Fprintln(builder, " Accounts ")
-		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "sign-in", "invite", "revoke", "users")
+		printCmds(builder, " ", []color.Attribute{color.Bold, ColorHiCyan}, "sign-in", "--local", "invite", "revoke", "users", "sessions", "sessions revoke", "tokens", "tokens create", "tokens revoke", "roles", "roles create", "roles update", "roles delete", "roles assign", "audit", "webhooks", "webhooks create", "webhooks test")
 		fmt.Fprintln(builder)
 	} else {
 
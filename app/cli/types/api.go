@@ -21,6 +21,14 @@ type ApiClient interface {
 	SignIn(req shared.SignInRequest, customHost string) (*shared.SessionResponse, *shared.ApiError)
 	SignOut() *shared.ApiError
 
+	GetSsoConfig(customHost string) (*shared.SsoConfigResponse, *shared.ApiError)
+	StartSso(customHost string) (*shared.StartSsoResponse, *shared.ApiError)
+	PollSso(req shared.PollSsoRequest, customHost string) (*shared.PollSsoResponse, *shared.ApiError)
+
+	ListSessions() ([]*shared.Session, *shared.ApiError)
+	RevokeSession(sessionId string) *shared.ApiError
+	RevokeOtherSessions() (*shared.RevokeSessionsResponse, *shared.ApiError)
+
 	GetOrgSession() *shared.ApiError
 	ListOrgs() ([]*shared.Org, *shared.ApiError)
 	CreateOrg(req shared.CreateOrgRequest) (*shared.CreateOrgResponse, *shared.ApiError)
@@ -29,6 +37,10 @@ type ApiClient interface {
 	DeleteUser(userId string) *shared.ApiError
 
 	ListOrgRoles() ([]*shared.OrgRole, *shared.ApiError)
+	CreateOrgRole(req shared.CreateOrgRoleRequest) (*shared.OrgRole, *shared.ApiError)
+	UpdateOrgRole(roleId string, req shared.UpdateOrgRoleRequest) (*shared.OrgRole, *shared.ApiError)
+	DeleteOrgRole(roleId string) *shared.ApiError
+	SetOrgUserRole(userId string, req shared.SetOrgUserRoleRequest) *shared.ApiError
 
 	InviteUser(req shared.InviteRequest) *shared.ApiError
 	ListPendingInvites() ([]*shared.Invite, *shared.ApiError)
@@ -42,6 +54,7 @@ type ApiClient interface {
 	RenameProject(projectId string, req shared.RenameProjectRequest) *shared.ApiError
 
 	ListPlans(projectIds []string) ([]*shared.Plan, *shared.ApiError)
+	ListSharedPlans(projectIds []string) ([]*shared.SharedPlan, *shared.ApiError)
 	ListArchivedPlans(projectIds []string) ([]*shared.Plan, *shared.ApiError)
 	ListPlansRunning(projectIds []string, includeRecent bool) (*shared.ListPlansRunningResponse, *shared.ApiError)
 
@@ -61,14 +74,23 @@ type ApiClient interface {
 
 	ArchivePlan(planId string) *shared.ApiError
 	UnarchivePlan(planId string) *shared.ApiError
+	SharePlan(planId, branch string) *shared.ApiError
+	UnsharePlan(planId, branch string) *shared.ApiError
 	RenamePlan(planId string, name string) *shared.ApiError
 
 	GetCurrentPlanState(planId, branch string) (*shared.CurrentPlanState, *shared.ApiError)
 	ApplyPlan(planId, branch string, req shared.ApplyPlanRequest) (string, *shared.ApiError)
+	UnapplyPlan(planId, branch string) *shared.ApiError
 	RejectAllChanges(planId, branch string) *shared.ApiError
 	RejectFile(planId, branch, filePath string) *shared.ApiError
 	RejectFiles(planId, branch string, paths []string) *shared.ApiError
 	GetPlanDiffs(planId, branch string) (string, *shared.ApiError)
+	ExportPatches(planId, branch string) (*shared.ExportPatchesResponse, *shared.ApiError)
+
+	ListReviewComments(planId, branch string, all bool) ([]*shared.ReviewComment, *shared.ApiError)
+	CreateReviewComment(planId, branch string, req shared.CreateReviewCommentRequest) (*shared.ReviewComment, *shared.ApiError)
+	ResolveReviewComments(planId, branch string, req shared.ResolveReviewCommentsRequest) *shared.ApiError
+	DeleteReviewComment(planId, branch, commentId string) *shared.ApiError
 
 	LoadContext(planId, branch string, req shared.LoadContextRequest) (*shared.LoadContextResponse, *shared.ApiError)
 	UpdateContext(planId, branch string, req shared.UpdateContextRequest) (*shared.UpdateContextResponse, *shared.ApiError)
@@ -97,4 +119,16 @@ type ApiClient interface {
 	CreateModelPack(set *shared.ModelPack) *shared.ApiError
 	ListModelPacks() ([]*shared.ModelPack, *shared.ApiError)
 	DeleteModelPack(setId string) *shared.ApiError
+
+	CreateAccessToken(req shared.CreateAccessTokenRequest) (*shared.CreateAccessTokenResponse, *shared.ApiError)
+	ListAccessTokens() ([]*shared.AccessToken, *shared.ApiError)
+	RevokeAccessToken(tokenId string) *shared.ApiError
+
+	ListAuditEvents(params shared.ListAuditEventsParams) ([]*shared.AuditEvent, *shared.ApiError)
+
+	ListWebhooks() ([]*shared.Webhook, *shared.ApiError)
+	CreateWebhook(req shared.CreateWebhookRequest) (*shared.CreateWebhookResponse, *shared.ApiError)
+	DeleteWebhook(webhookId string) *shared.ApiError
+	ListWebhookDeliveries(webhookId string) ([]*shared.WebhookDelivery, *shared.ApiError)
+	TestWebhook(webhookId string) (*shared.WebhookDelivery, *shared.ApiError)
 }
@@ -1,24 +1,32 @@
 package types
 
 import (
+	"time"
+
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
 )
 
 type ClientAccount struct {
 	IsCloud  bool   `json:"isCloud"`
+	IsLocal  bool   `json:"isLocal"`
 	Host     string `json:"host"`
 	Email    string `json:"email"`
 	UserName string `json:"userName"`
 	UserId   string `json:"userId"`
 	Token    string `json:"token"`
 	IsTrial  bool   `json:"isTrial"`
+	// signed in through the server's identity provider rather than an email pin
+	IsSso bool `json:"isSso,omitempty"`
 }
 
 type ClientAuth struct {
 	ClientAccount
 	OrgId   string `json:"orgId"`
 	OrgName string `json:"orgName"`
+
+	// set when auth comes from a PLANDEX_TOKEN access token rather than auth.json--it's never written to disk
+	IsAccessToken bool `json:"-"`
 }
 
 type LoadContextParams struct {
@@ -70,3 +78,17 @@ type ChangesUIScrollReplacement struct {
 type ChangesUIViewportsUpdate struct {
 	ScrollReplacement *ChangesUIScrollReplacement
 }
+
+type ApplySnapshotFile struct {
+	Existed bool   `json:"existed"`
+	Content string `json:"content"`
+	Applied string `json:"applied"`
+}
+
+type ApplySnapshot struct {
+	PlanId       string                        `json:"planId"`
+	Branch       string                        `json:"branch"`
+	CreatedAt    time.Time                     `json:"createdAt"`
+	Files        map[string]*ApplySnapshotFile `json:"files"`
+	GitCommitSha string                        `json:"gitCommitSha"` // set if the applied files were committed to the project's git repo
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/plandex/plandex/shared"
+)
+
+// access tokens are long, random strings rather than uuids, so they're safe to hash without a salt
+const accessTokenRandomLength = 40
+
+// how often a token's last_used_at is updated, so that a CI job hammering the api doesn't write on every request
+const accessTokenLastUsedResolution = time.Minute
+
+func hashAccessToken(token string) string {
+	hashBytes := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hashBytes[:])
+}
+
+// CreateAccessToken stores a new token's hash and returns the token, which isn't stored anywhere
+func CreateAccessToken(accessToken *AccessToken) (string, error) {
+	random, err := shared.GetRandomAlphanumeric(accessTokenRandomLength)
+	if err != nil {
+		return "", fmt.Errorf("error generating access token: %v", err)
+	}
+
+	token := shared.AccessTokenPrefix + string(random)
+	accessToken.TokenHash = hashAccessToken(token)
+
+	err = Conn.QueryRow(
+		"INSERT INTO access_tokens (org_id, user_id, name, token_hash, scope, project_ids, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at",
+		accessToken.OrgId, accessToken.UserId, accessToken.Name, accessToken.TokenHash, accessToken.Scope, accessToken.ProjectIds, accessToken.ExpiresAt,
+	).Scan(&accessToken.Id, &accessToken.CreatedAt)
+
+	if err != nil {
+		return "", fmt.Errorf("error creating access token: %v", err)
+	}
+
+	return token, nil
+}
+
+// ValidateAccessToken returns the token's record if it exists and hasn't expired or been revoked, and records that it was used. Recording the use is best-effort.
+func ValidateAccessToken(token string) (*AccessToken, error) {
+	now := time.Now()
+
+	var accessToken AccessToken
+	err := Conn.Get(&accessToken, "SELECT * FROM access_tokens WHERE token_hash = $1 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > $2)", hashAccessToken(token), now)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("invalid token")
+		}
+
+		return nil, fmt.Errorf("error validating access token: %v", err)
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) > accessTokenLastUsedResolution {
+		_, err = Conn.Exec("UPDATE access_tokens SET last_used_at = $1 WHERE id = $2", now, accessToken.Id)
+		if err != nil {
+			// it's only bookkeeping, so a failure here shouldn't fail the request
+			log.Printf("Error updating access token last used: %v\n", err)
+		} else {
+			accessToken.LastUsedAt = &now
+		}
+	}
+
+	return &accessToken, nil
+}
+
+func ListAccessTokens(orgId, userId string) ([]*AccessToken, error) {
+	var accessTokens []*AccessToken
+	err := Conn.Select(&accessTokens, "SELECT * FROM access_tokens WHERE org_id = $1 AND user_id = $2 AND deleted_at IS NULL AND (expires_at IS NULL OR expires_at > $3) ORDER BY created_at", orgId, userId, time.Now())
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing access tokens: %v", err)
+	}
+
+	return accessTokens, nil
+}
+
+// RevokeAccessToken returns false if the user has no active token with this id in the org
+func RevokeAccessToken(orgId, userId, id string) (bool, error) {
+	res, err := Conn.Exec("UPDATE access_tokens SET deleted_at = NOW() WHERE id = $1 AND org_id = $2 AND user_id = $3 AND deleted_at IS NULL", id, orgId, userId)
+
+	if err != nil {
+		return false, fmt.Errorf("error revoking access token: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
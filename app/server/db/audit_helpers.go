@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/plandex/plandex/shared"
+)
+
+// CreateAuditEvent appends to the org's audit log. Pass the transaction making the change when there is one so the event is only recorded if the change is.
+func CreateAuditEvent(event *AuditEvent, tx *sqlx.Tx) error {
+	// NOW() rather than the column default so sqlite stores the same time format that's used to filter and page
+	query := "INSERT INTO audit_events (org_id, actor_id, actor_email, access_token_id, action, resource_id, details, ip, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW()) RETURNING id, created_at"
+	args := []interface{}{event.OrgId, event.ActorId, event.ActorEmail, event.AccessTokenId, event.Action, event.ResourceId, event.Details, event.Ip}
+
+	var err error
+	if tx == nil {
+		err = Conn.QueryRow(query, args...).Scan(&event.Id, &event.CreatedAt)
+	} else {
+		err = tx.QueryRow(query, args...).Scan(&event.Id, &event.CreatedAt)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error creating audit event: %v", err)
+	}
+
+	return nil
+}
+
+// ListAuditEvents lists an org's audit events newest first. Pages continue from the Before event's id, so events with the same timestamp are neither skipped nor repeated.
+func ListAuditEvents(orgId string, params shared.ListAuditEventsParams) ([]*AuditEvent, error) {
+	conditions := []string{"org_id = $1"}
+	args := []interface{}{orgId}
+
+	addCondition := func(condition string, conditionArgs ...interface{}) {
+		for _, arg := range conditionArgs {
+			args = append(args, arg)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if params.ActorEmail != "" {
+		addCondition("actor_email = ?", strings.ToLower(params.ActorEmail))
+	}
+
+	if params.Action != "" {
+		addCondition("(action = ? OR action LIKE ?)", params.Action, params.Action+".%")
+	}
+
+	if params.Since != nil {
+		addCondition("created_at >= ?", params.Since.UTC())
+	}
+
+	if params.Until != nil {
+		addCondition("created_at < ?", params.Until.UTC())
+	}
+
+	if params.Before != "" {
+		var before AuditEvent
+		err := Conn.Get(&before, "SELECT * FROM audit_events WHERE id = $1 AND org_id = $2", params.Before, orgId)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("audit event %s not found", params.Before)
+			}
+			return nil, fmt.Errorf("error getting audit event: %v", err)
+		}
+
+		addCondition("(created_at < ? OR (created_at = ? AND id < ?))", before.CreatedAt.UTC(), before.CreatedAt.UTC(), before.Id)
+	}
+
+	limit := params.Limit
+	if limit <= 0 || limit > shared.AuditEventsMaxLimit {
+		limit = shared.AuditEventsDefaultLimit
+	}
+
+	query := fmt.Sprintf("SELECT * FROM audit_events WHERE %s ORDER BY created_at DESC, id DESC LIMIT %d", strings.Join(conditions, " AND "), limit)
+
+	var events []*AuditEvent
+	err := Conn.Select(&events, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit events: %v", err)
+	}
+
+	return events, nil
+}
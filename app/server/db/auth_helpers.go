@@ -14,13 +14,29 @@ import (
 
 const tokenExpirationDays = 90 // (trial tokens don't expire)
 
-func CreateAuthToken(userId string, isTrial bool, tx *sqlx.Tx) (token, id string, err error) {
+// how often a session's last_used_at is updated--every request authenticates, so writing each time would be wasteful
+const authTokenLastUsedResolution = time.Minute
+
+// AuthTokenClient describes the client a sign-in token was created or used from. Either field can be empty.
+type AuthTokenClient struct {
+	Version string
+	Ip      string
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func CreateAuthToken(userId string, isTrial bool, client AuthTokenClient, tx *sqlx.Tx) (token, id string, err error) {
 	uid := uuid.New()
 	bytes := uid[:]
 	hashBytes := sha256.Sum256(bytes)
 	hash := hex.EncodeToString(hashBytes[:])
 
-	err = tx.QueryRow("INSERT INTO auth_tokens (user_id, token_hash, is_trial) VALUES ($1, $2, $3) RETURNING id", userId, hash, isTrial).Scan(&id)
+	err = tx.QueryRow("INSERT INTO auth_tokens (user_id, token_hash, is_trial, client_version, ip, last_used_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", userId, hash, isTrial, nullIfEmpty(client.Version), nullIfEmpty(client.Ip), time.Now()).Scan(&id)
 
 	if err != nil {
 		return "", "", fmt.Errorf("error creating auth token: %v", err)
@@ -55,6 +71,78 @@ func ValidateAuthToken(token string) (*AuthToken, error) {
 	return &authToken, nil
 }
 
+// TouchAuthToken records that a sign-in token was just used, along with the client that used it. It only writes when the last update is stale or the client changed.
+func TouchAuthToken(authToken *AuthToken, client AuthTokenClient) error {
+	now := time.Now()
+
+	sameClient := (client.Version == "" || (authToken.ClientVersion != nil && *authToken.ClientVersion == client.Version)) &&
+		(client.Ip == "" || (authToken.Ip != nil && *authToken.Ip == client.Ip))
+
+	if sameClient && authToken.LastUsedAt != nil && now.Sub(*authToken.LastUsedAt) < authTokenLastUsedResolution {
+		return nil
+	}
+
+	if client.Version != "" {
+		authToken.ClientVersion = &client.Version
+	}
+	if client.Ip != "" {
+		authToken.Ip = &client.Ip
+	}
+	authToken.LastUsedAt = &now
+
+	_, err := Conn.Exec("UPDATE auth_tokens SET last_used_at = $1, client_version = $2, ip = $3 WHERE id = $4", now, authToken.ClientVersion, authToken.Ip, authToken.Id)
+
+	if err != nil {
+		return fmt.Errorf("error updating auth token last used: %v", err)
+	}
+
+	return nil
+}
+
+// ListAuthTokens returns a user's unexpired sign-in tokens, most recently used first
+func ListAuthTokens(userId string) ([]*AuthToken, error) {
+	var authTokens []*AuthToken
+	err := Conn.Select(&authTokens, "SELECT * FROM auth_tokens WHERE user_id = $1 AND (created_at > $2 OR is_trial = TRUE) AND deleted_at IS NULL ORDER BY COALESCE(last_used_at, created_at) DESC", userId, time.Now().AddDate(0, 0, -tokenExpirationDays))
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing auth tokens: %v", err)
+	}
+
+	return authTokens, nil
+}
+
+// RevokeAuthToken returns false if the user has no active sign-in token with this id
+func RevokeAuthToken(userId, id string) (bool, error) {
+	res, err := Conn.Exec("UPDATE auth_tokens SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL", id, userId)
+
+	if err != nil {
+		return false, fmt.Errorf("error revoking auth token: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// RevokeOtherAuthTokens signs the user out everywhere except the session with exceptId, and returns how many sessions were revoked
+func RevokeOtherAuthTokens(userId, exceptId string) (int, error) {
+	res, err := Conn.Exec("UPDATE auth_tokens SET deleted_at = NOW() WHERE user_id = $1 AND id != $2 AND deleted_at IS NULL", userId, exceptId)
+
+	if err != nil {
+		return 0, fmt.Errorf("error revoking auth tokens: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
 func CreateEmailVerification(email string, userId, pinHash string) error {
 	var err error
 	if userId == "" {
@@ -104,7 +192,7 @@ func GetUserPermissions(userId, orgId string) ([]string, error) {
 	var permissions []string
 
 	query := `
-    SELECT p.name, p.resource_id 
+    SELECT p.name, COALESCE(orp.resource_id, p.resource_id)
     FROM permissions p
     JOIN org_roles_permissions orp ON p.id = orp.permission_id
     JOIN orgs_users ou ON orp.org_role_id = ou.org_role_id
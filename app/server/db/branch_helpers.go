@@ -6,7 +6,6 @@ import (
 	"log"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 	"github.com/plandex/plandex/shared"
 )
 
@@ -151,7 +150,17 @@ func ListPlanBranches(orgId, planId string) ([]*Branch, error) {
 
 func ListBranchesForPlans(orgId string, planIds []string) ([]*Branch, error) {
 	var branches []*Branch
-	err := Conn.Select(&branches, "SELECT * FROM branches WHERE plan_id = ANY($1) ORDER BY created_at", pq.Array(planIds))
+
+	if len(planIds) == 0 {
+		return branches, nil
+	}
+
+	query, args, err := inQuery("SELECT * FROM branches WHERE plan_id IN (?) ORDER BY created_at", planIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&branches, query, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error listing branches: %v", err)
@@ -190,6 +199,12 @@ func DeleteBranch(orgId, planId, branch string) error {
 		return fmt.Errorf("error decrementing active branches: %v", err)
 	}
 
+	err = syncPlanSharedWithOrg(planId, tx)
+
+	if err != nil {
+		return err
+	}
+
 	err = GitDeleteBranch(orgId, planId, branch)
 
 	if err != nil {
@@ -204,3 +219,56 @@ func DeleteBranch(orgId, planId, branch string) error {
 
 	return nil
 }
+
+// SetBranchSharedWithOrg shares a branch with the plan's org, or stops sharing it. The plan counts as shared while any of its branches are.
+func SetBranchSharedWithOrg(planId, branch string, share bool) error {
+	tx, err := Conn.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE branches SET shared_with_org_at = NULL WHERE plan_id = $1 AND name = $2"
+	if share {
+		query = "UPDATE branches SET shared_with_org_at = COALESCE(shared_with_org_at, NOW()) WHERE plan_id = $1 AND name = $2"
+	}
+
+	res, err := tx.Exec(query, planId, branch)
+	if err != nil {
+		return fmt.Errorf("error updating branch: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("branch %s not found", branch)
+	}
+
+	err = syncPlanSharedWithOrg(planId, tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return nil
+}
+
+func syncPlanSharedWithOrg(planId string, tx *sqlx.Tx) error {
+	_, err := tx.Exec(`UPDATE plans SET shared_with_org_at = CASE
+		WHEN EXISTS (SELECT 1 FROM branches WHERE plan_id = $1 AND shared_with_org_at IS NOT NULL) THEN COALESCE(shared_with_org_at, NOW())
+		ELSE NULL
+	END
+	WHERE id = $1`, planId)
+
+	if err != nil {
+		return fmt.Errorf("error updating plan shared status: %v", err)
+	}
+
+	return nil
+}
@@ -538,10 +538,28 @@ func invalidateConflictedResults(orgId, planId string, filesToUpdate map[string]
 		return fmt.Errorf("error getting current plan state: %v", err)
 	}
 
-	conflictPaths := currentPlan.PlanResult.FileResultsByPath.ConflictedPaths(filesToUpdate)
+	// three-way merge the updated files with the pending changes--only paths with truly overlapping changes are invalidated
+	mergeResults := currentPlan.MergeContextUpdates(filesToUpdate)
+
+	conflictPaths := map[string]bool{}
+	mergedPaths := map[string]string{}
+	for path, res := range mergeResults {
+		if res == nil || res.HasConflicts() {
+			conflictPaths[path] = true
+		} else {
+			mergedPaths[path] = res.Merged
+		}
+	}
 
 	// log.Println("invalidateConflictedResults - Conflicted paths:", conflictPaths)
 
+	if len(mergedPaths) > 0 {
+		err = storeMergedResults(orgId, planId, currentPlan, filesToUpdate, mergedPaths)
+		if err != nil {
+			return fmt.Errorf("error storing merged results: %v", err)
+		}
+	}
+
 	if len(conflictPaths) > 0 {
 		errCh := make(chan error)
 		numRoutines := 0
@@ -558,6 +576,13 @@ func invalidateConflictedResults(orgId, planId string, filesToUpdate map[string]
 					}
 					desc.BuildPathsInvalidated[path] = true
 
+					if res := mergeResults[path]; res != nil {
+						if desc.BuildPathConflicts == nil {
+							desc.BuildPathConflicts = make(map[string][]*shared.MergeConflict)
+						}
+						desc.BuildPathConflicts[path] = res.Conflicts
+					}
+
 					// log.Printf("Invalidating build for path: %s, desc: %s\n", path, desc.Id)
 
 					go func(desc *ConvoMessageDescription) {
@@ -598,3 +623,60 @@ func invalidateConflictedResults(orgId, planId string, filesToUpdate map[string]
 
 	return nil
 }
+
+// storeMergedResults replaces the pending results for each merged path with a single result that turns the updated context into the merged file
+func storeMergedResults(orgId, planId string, currentPlan *shared.CurrentPlanState, filesToUpdate, mergedPaths map[string]string) error {
+	pathsSet := map[string]bool{}
+	for path := range mergedPaths {
+		pathsSet[path] = true
+	}
+
+	err := DeletePendingResultsForPaths(orgId, planId, pathsSet)
+	if err != nil {
+		return fmt.Errorf("error deleting pending results: %v", err)
+	}
+
+	errCh := make(chan error, len(mergedPaths))
+
+	for path, merged := range mergedPaths {
+		go func(path, merged string) {
+			updated := filesToUpdate[path]
+
+			// the user's update already includes all the pending changes
+			if merged == updated {
+				errCh <- nil
+				return
+			}
+
+			// attach the merged result to the latest pending result for the path
+			pendingResults := currentPlan.PlanResult.FileResultsByPath[path]
+			latest := pendingResults[len(pendingResults)-1]
+
+			err := StorePlanResult(&PlanFileResult{
+				TypeVersion:    1,
+				OrgId:          orgId,
+				PlanId:         planId,
+				ConvoMessageId: latest.ConvoMessageId,
+				PlanBuildId:    latest.PlanBuildId,
+				Path:           path,
+				Replacements:   shared.ReplacementsForUpdate(updated, merged),
+			})
+
+			if err != nil {
+				errCh <- fmt.Errorf("error storing merged result for %s: %v", path, err)
+				return
+			}
+
+			errCh <- nil
+		}(path, merged)
+	}
+
+	for range mergedPaths {
+		err := <-errCh
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/plandex/plandex/shared"
@@ -13,12 +16,101 @@ import (
 // Models used client-side have a ToApi() method to convert it to the corresponding client-side model.
 
 type AuthToken struct {
-	Id        string     `db:"id"`
-	UserId    string     `db:"user_id"`
-	TokenHash string     `db:"token_hash"`
-	IsTrial   bool       `db:"is_trial"`
-	CreatedAt time.Time  `db:"created_at"`
-	DeletedAt *time.Time `db:"deleted_at"`
+	Id            string     `db:"id"`
+	UserId        string     `db:"user_id"`
+	TokenHash     string     `db:"token_hash"`
+	IsTrial       bool       `db:"is_trial"`
+	LastUsedAt    *time.Time `db:"last_used_at"`
+	ClientVersion *string    `db:"client_version"`
+	Ip            *string    `db:"ip"`
+	CreatedAt     time.Time  `db:"created_at"`
+	DeletedAt     *time.Time `db:"deleted_at"`
+}
+
+func (token *AuthToken) ToApiSession(currentId string) *shared.Session {
+	session := &shared.Session{
+		Id:         token.Id,
+		IsTrial:    token.IsTrial,
+		IsCurrent:  token.Id == currentId,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+
+	if token.ClientVersion != nil {
+		session.ClientVersion = *token.ClientVersion
+	}
+	if token.Ip != nil {
+		session.Ip = *token.Ip
+	}
+
+	return session
+}
+
+type SsoLogin struct {
+	Id             string     `db:"id"`
+	State          string     `db:"state"`
+	Nonce          string     `db:"nonce"`
+	CodeVerifier   string     `db:"code_verifier"`
+	PollSecretHash string     `db:"poll_secret_hash"`
+	UserCode       string     `db:"user_code"`
+	UserId         *string    `db:"user_id"`
+	Error          *string    `db:"error"`
+	CompletedAt    *time.Time `db:"completed_at"`
+	ConsumedAt     *time.Time `db:"consumed_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+type AccessToken struct {
+	Id         string                  `db:"id"`
+	OrgId      string                  `db:"org_id"`
+	UserId     string                  `db:"user_id"`
+	Name       string                  `db:"name"`
+	TokenHash  string                  `db:"token_hash"`
+	Scope      shared.AccessTokenScope `db:"scope"`
+	ProjectIds StringList              `db:"project_ids"`
+	ExpiresAt  *time.Time              `db:"expires_at"`
+	LastUsedAt *time.Time              `db:"last_used_at"`
+	CreatedAt  time.Time               `db:"created_at"`
+	DeletedAt  *time.Time              `db:"deleted_at"`
+}
+
+func (token *AccessToken) ToApi() *shared.AccessToken {
+	return &shared.AccessToken{
+		Id:         token.Id,
+		OrgId:      token.OrgId,
+		UserId:     token.UserId,
+		Name:       token.Name,
+		Scope:      token.Scope,
+		ProjectIds: token.ProjectIds,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// StringList is stored as a JSON array. A nil list is stored as NULL.
+type StringList []string
+
+func (l *StringList) Scan(src interface{}) error {
+	if src == nil {
+		*l = nil
+		return nil
+	}
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, l)
+	case string:
+		return json.Unmarshal([]byte(s), l)
+	default:
+		return fmt.Errorf("unsupported data type: %T", src)
+	}
+}
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
 }
 
 type Org struct {
@@ -243,21 +335,223 @@ type OrgRole struct {
 func (role *OrgRole) ToApi() *shared.OrgRole {
 	return &shared.OrgRole{
 		Id:          role.Id,
+		Name:        role.Name,
 		IsDefault:   role.OrgId == nil,
 		Label:       role.Label,
 		Description: role.Description,
 	}
 }
 
+type OrgRolePermission struct {
+	OrgRoleId  string  `db:"org_role_id"`
+	Permission string  `db:"name"`
+	ResourceId *string `db:"resource_id"`
+}
+
+func (permission *OrgRolePermission) ToApi() *shared.OrgRolePermission {
+	res := &shared.OrgRolePermission{Permission: permission.Permission}
+	if permission.ResourceId != nil {
+		res.ResourceId = *permission.ResourceId
+	}
+	return res
+}
+
+// AuditEventDetails is stored as a JSON object
+type AuditEventDetails map[string]string
+
+func (d *AuditEventDetails) Scan(src interface{}) error {
+	if src == nil {
+		*d = nil
+		return nil
+	}
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, d)
+	case string:
+		return json.Unmarshal([]byte(s), d)
+	default:
+		return fmt.Errorf("unsupported data type: %T", src)
+	}
+}
+
+func (d AuditEventDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+type AuditEvent struct {
+	Id            string             `db:"id"`
+	OrgId         string             `db:"org_id"`
+	ActorId       string             `db:"actor_id"`
+	ActorEmail    string             `db:"actor_email"`
+	AccessTokenId *string            `db:"access_token_id"`
+	Action        shared.AuditAction `db:"action"`
+	ResourceId    *string            `db:"resource_id"`
+	Details       AuditEventDetails  `db:"details"`
+	Ip            *string            `db:"ip"`
+	CreatedAt     time.Time          `db:"created_at"`
+}
+
+func (event *AuditEvent) ToApi() *shared.AuditEvent {
+	res := &shared.AuditEvent{
+		Id:         event.Id,
+		OrgId:      event.OrgId,
+		ActorId:    event.ActorId,
+		ActorEmail: event.ActorEmail,
+		Action:     event.Action,
+		Details:    event.Details,
+		CreatedAt:  event.CreatedAt,
+	}
+	if event.AccessTokenId != nil {
+		res.AccessTokenId = *event.AccessTokenId
+	}
+	if event.ResourceId != nil {
+		res.ResourceId = *event.ResourceId
+	}
+	if event.Ip != nil {
+		res.Ip = *event.Ip
+	}
+	return res
+}
+
+type ReviewComment struct {
+	Id            string     `db:"id"`
+	OrgId         string     `db:"org_id"`
+	PlanId        string     `db:"plan_id"`
+	BranchId      string     `db:"branch_id"`
+	AuthorId      string     `db:"author_id"`
+	Path          string     `db:"path"`
+	ReplacementId *string    `db:"replacement_id"`
+	Body          string     `db:"body"`
+	ResolvedAt    *time.Time `db:"resolved_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+
+	// joined from users when listing
+	AuthorName  string `db:"author_name"`
+	AuthorEmail string `db:"author_email"`
+}
+
+func (comment *ReviewComment) ToApi(branch string) *shared.ReviewComment {
+	res := &shared.ReviewComment{
+		Id:          comment.Id,
+		PlanId:      comment.PlanId,
+		Branch:      branch,
+		AuthorId:    comment.AuthorId,
+		AuthorName:  comment.AuthorName,
+		AuthorEmail: comment.AuthorEmail,
+		Path:        comment.Path,
+		Body:        comment.Body,
+		ResolvedAt:  comment.ResolvedAt,
+		CreatedAt:   comment.CreatedAt,
+	}
+	if comment.ReplacementId != nil {
+		res.ReplacementId = *comment.ReplacementId
+	}
+	return res
+}
+
+type Webhook struct {
+	Id        string     `db:"id"`
+	OrgId     string     `db:"org_id"`
+	CreatorId string     `db:"creator_id"`
+	Url       string     `db:"url"`
+	Secret    string     `db:"secret"`
+	Events    StringList `db:"events"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func (webhook *Webhook) ToApi() *shared.Webhook {
+	events := []shared.WebhookEvent{}
+	for _, event := range webhook.Events {
+		events = append(events, shared.WebhookEvent(event))
+	}
+
+	return &shared.Webhook{
+		Id:        webhook.Id,
+		OrgId:     webhook.OrgId,
+		Url:       webhook.Url,
+		Events:    events,
+		CreatorId: webhook.CreatorId,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	Id             string                       `db:"id"`
+	OrgId          string                       `db:"org_id"`
+	WebhookId      string                       `db:"webhook_id"`
+	Event          shared.WebhookEvent          `db:"event"`
+	Payload        string                       `db:"payload"`
+	Status         shared.WebhookDeliveryStatus `db:"status"`
+	Attempts       int                          `db:"attempts"`
+	ResponseStatus *int                         `db:"response_status"`
+	Error          *string                      `db:"error"`
+	NextAttemptAt  *time.Time                   `db:"next_attempt_at"`
+	DeliveredAt    *time.Time                   `db:"delivered_at"`
+	CreatedAt      time.Time                    `db:"created_at"`
+}
+
+func (delivery *WebhookDelivery) ToApi() *shared.WebhookDelivery {
+	res := &shared.WebhookDelivery{
+		Id:            delivery.Id,
+		WebhookId:     delivery.WebhookId,
+		Event:         delivery.Event,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		NextAttemptAt: delivery.NextAttemptAt,
+		DeliveredAt:   delivery.DeliveredAt,
+		CreatedAt:     delivery.CreatedAt,
+	}
+	if delivery.ResponseStatus != nil {
+		res.ResponseStatus = *delivery.ResponseStatus
+	}
+	if delivery.Error != nil {
+		res.Error = *delivery.Error
+	}
+	return res
+}
+
 type ModelStream struct {
-	Id              string     `db:"id"`
-	OrgId           string     `db:"org_id"`
-	PlanId          string     `db:"plan_id"`
-	InternalIp      string     `db:"internal_ip"`
-	Branch          string     `db:"branch"`
-	LastHeartbeatAt time.Time  `db:"last_heartbeat_at"`
-	CreatedAt       time.Time  `db:"created_at"`
-	FinishedAt      *time.Time `db:"finished_at"`
+	Id              string                 `db:"id"`
+	OrgId           string                 `db:"org_id"`
+	PlanId          string                 `db:"plan_id"`
+	UserId          *string                `db:"user_id"`
+	InternalIp      string                 `db:"internal_ip"`
+	Branch          string                 `db:"branch"`
+	Checkpoint      *ModelStreamCheckpoint `db:"checkpoint"`
+	LastHeartbeatAt time.Time              `db:"last_heartbeat_at"`
+	CreatedAt       time.Time              `db:"created_at"`
+	FinishedAt      *time.Time             `db:"finished_at"`
+}
+
+// ModelStreamCheckpoint is the in-memory state of a running plan that can't be rebuilt from the plan's repo. Servers save it as a plan runs so that if the server goes away, the plan can still be finished cleanly from the database.
+type ModelStreamCheckpoint struct {
+	BuildOnly       bool     `json:"buildOnly"`
+	RepliesFinished bool     `json:"repliesFinished"`
+	MessageNum      int      `json:"messageNum"`
+	ReplyContent    string   `json:"replyContent"`
+	ReplyTokens     int      `json:"replyTokens"`
+	BuildPaths      []string `json:"buildPaths"`
+}
+
+func (c *ModelStreamCheckpoint) Scan(src interface{}) error {
+	if src == nil {
+		return nil
+	}
+	switch s := src.(type) {
+	case []byte:
+		return json.Unmarshal(s, c)
+	case string:
+		return json.Unmarshal([]byte(s), c)
+	default:
+		return fmt.Errorf("unsupported data type: %T", src)
+	}
+}
+
+func (c ModelStreamCheckpoint) Value() (driver.Value, error) {
+	return json.Marshal(c)
 }
 
 // type ModelStreamSubscription struct {
@@ -437,20 +731,21 @@ func (msg *ConvoMessage) ToApi() *shared.ConvoMessage {
 }
 
 type ConvoMessageDescription struct {
-	Id                    string          `json:"id"`
-	OrgId                 string          `json:"orgId"`
-	PlanId                string          `json:"planId"`
-	ConvoMessageId        string          `json:"convoMessageId"`
-	SummarizedToMessageId string          `json:"summarizedToMessageId"`
-	MadePlan              bool            `json:"madePlan"`
-	CommitMsg             string          `json:"commitMsg"`
-	Files                 []string        `json:"files"`
-	Error                 string          `json:"error"`
-	DidBuild              bool            `json:"didBuild"`
-	BuildPathsInvalidated map[string]bool `json:"buildPathsInvalidated"`
-	AppliedAt             *time.Time      `json:"appliedAt,omitempty"`
-	CreatedAt             time.Time       `json:"createdAt"`
-	UpdatedAt             time.Time       `json:"updatedAt"`
+	Id                    string                             `json:"id"`
+	OrgId                 string                             `json:"orgId"`
+	PlanId                string                             `json:"planId"`
+	ConvoMessageId        string                             `json:"convoMessageId"`
+	SummarizedToMessageId string                             `json:"summarizedToMessageId"`
+	MadePlan              bool                               `json:"madePlan"`
+	CommitMsg             string                             `json:"commitMsg"`
+	Files                 []string                           `json:"files"`
+	Error                 string                             `json:"error"`
+	DidBuild              bool                               `json:"didBuild"`
+	BuildPathsInvalidated map[string]bool                    `json:"buildPathsInvalidated"`
+	BuildPathConflicts    map[string][]*shared.MergeConflict `json:"buildPathConflicts,omitempty"`
+	AppliedAt             *time.Time                         `json:"appliedAt,omitempty"`
+	CreatedAt             time.Time                          `json:"createdAt"`
+	UpdatedAt             time.Time                          `json:"updatedAt"`
 }
 
 func (desc *ConvoMessageDescription) ToApi() *shared.ConvoMessageDescription {
@@ -463,6 +758,7 @@ func (desc *ConvoMessageDescription) ToApi() *shared.ConvoMessageDescription {
 		Files:                 desc.Files,
 		DidBuild:              desc.DidBuild,
 		BuildPathsInvalidated: desc.BuildPathsInvalidated,
+		BuildPathConflicts:    desc.BuildPathConflicts,
 		AppliedAt:             desc.AppliedAt,
 		Error:                 desc.Error,
 		CreatedAt:             desc.CreatedAt,
@@ -8,8 +8,6 @@ import (
 	"os"
 
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
@@ -17,8 +15,6 @@ import (
 var Conn *sqlx.DB
 
 func Connect() error {
-	var err error
-
 	dbUrl := os.Getenv("DATABASE_URL")
 	if dbUrl == "" {
 		if os.Getenv("DB_HOST") != "" &&
@@ -36,17 +32,30 @@ func Connect() error {
 		}
 	}
 
-	Conn, err = sqlx.Connect("postgres", dbUrl)
+	return ConnectUrl(dbUrl)
+}
+
+// ConnectUrl connects to the database at dbUrl, picking the store from its scheme. Local mode uses it directly with a sqlite:// url.
+func ConnectUrl(dbUrl string) error {
+	var err error
+
+	if IsSqliteUrl(dbUrl) {
+		store = &sqliteStore{}
+	} else {
+		store = &postgresStore{}
+	}
+
+	Conn, err = store.Open(dbUrl)
 	if err != nil {
 		return err
 	}
 
-	log.Println("connected to database")
-
-	_, err = Conn.Exec("SET TIMEZONE='UTC';")
+	log.Printf("connected to %s database\n", store.Name())
 
+	bus.Close()
+	bus, err = store.NewEventBus(dbUrl)
 	if err != nil {
-		return fmt.Errorf("error setting timezone: %v", err)
+		return fmt.Errorf("error connecting event bus: %v", err)
 	}
 
 	return nil
@@ -57,19 +66,11 @@ func MigrationsUp() error {
 		return errors.New("db not initialized")
 	}
 
-	driver, err := postgres.WithInstance(Conn.DB, &postgres.Config{})
-
-	if err != nil {
-		return fmt.Errorf("error creating postgres driver: %v", err)
-	}
-
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres", driver)
+	return store.MigrationsUp(Conn)
+}
 
-	if err != nil {
-		return fmt.Errorf("error creating migration instance: %v", err)
-	}
+func runMigrations(m *migrate.Migrate) error {
+	var err error
 
 	// Uncomment below (and update migration version) to reset migration state to a specific version after a failure
 	// if os.Getenv("GOENV") == "development" {
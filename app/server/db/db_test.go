@@ -0,0 +1,879 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// testBackends connects to each available backend in turn and runs fn against it. SQLite always runs against a temp file; Postgres runs when TEST_DATABASE_URL points at a scratch database.
+func testBackends(t *testing.T, fn func(t *testing.T)) {
+	backends := map[string]string{
+		"sqlite": "sqlite://" + filepath.Join(t.TempDir(), "plandex.db"),
+	}
+	if pgUrl := os.Getenv("TEST_DATABASE_URL"); pgUrl != "" {
+		backends["postgres"] = pgUrl
+	}
+
+	for name, dbUrl := range backends {
+		t.Run(name, func(t *testing.T) {
+			prevBaseDir := BaseDir
+			BaseDir = t.TempDir()
+			t.Setenv("DATABASE_URL", dbUrl)
+
+			if err := Connect(); err != nil {
+				t.Fatalf("error connecting: %v", err)
+			}
+			if err := MigrationsUp(); err != nil {
+				t.Fatalf("error running migrations: %v", err)
+			}
+
+			t.Cleanup(func() {
+				Conn.Close()
+				BaseDir = prevBaseDir
+			})
+
+			fn(t)
+		})
+	}
+}
+
+// createTestPlan sets up a user, org, project and plan the same way the account and plan handlers do
+func createTestPlan(t *testing.T, email string) (*User, *Org, *Plan) {
+	tx, err := Conn.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	user := &User{Name: "Test", Email: email, Domain: "example.com"}
+	if err := CreateUser(user, tx); err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+
+	org, err := CreateOrg(&shared.CreateOrgRequest{Name: "Test Org"}, user.Id, nil, tx)
+	if err != nil {
+		t.Fatalf("error creating org: %v", err)
+	}
+
+	projectId, err := CreateProject(org.Id, "test-project", tx)
+	if err != nil {
+		t.Fatalf("error creating project: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := CreatePlan(org.Id, projectId, user.Id, "draft")
+	if err != nil {
+		t.Fatalf("error creating plan: %v", err)
+	}
+
+	return user, org, plan
+}
+
+func TestPlansAndBranches(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "plans@example.com")
+
+		orgs, err := GetAccessibleOrgsForUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orgs) != 1 || orgs[0].Id != org.Id {
+			t.Fatalf("expected the user's org to be accessible, got %v", orgs)
+		}
+
+		plans, err := ListOwnedPlans([]string{plan.ProjectId}, user.Id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plans) != 1 || plans[0].Id != plan.Id {
+			t.Fatalf("expected 1 owned plan, got %d", len(plans))
+		}
+
+		plans, err = ListOwnedPlans(nil, user.Id, false)
+		if err != nil || len(plans) != 0 {
+			t.Fatalf("expected no plans for no projects, got %d (%v)", len(plans), err)
+		}
+
+		if _, err := ValidatePlanAccess(plan.Id, user.Id, org.Id); err != nil {
+			t.Fatalf("expected owner to have access: %v", err)
+		}
+
+		main, err := GetDbBranch(plan.Id, "main")
+		if err != nil || main == nil {
+			t.Fatalf("expected main branch: %v", err)
+		}
+
+		// branch off a main that has a commit, as it will once the plan has any context
+		if err := os.WriteFile(filepath.Join(getPlanDir(org.Id, plan.Id), "settings.json"), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := GitAddAndCommit(org.Id, plan.Id, "main", "initial"); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CreateBranch(plan, main, "feature", tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		branches, err := ListBranchesForPlans(org.Id, []string{plan.Id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(branches) != 2 {
+			t.Fatalf("expected 2 branches, got %d", len(branches))
+		}
+
+		// handlers delete branches while holding a lock on main, which checks it out
+		if err := gitCheckoutBranch(getPlanDir(org.Id, plan.Id), "main"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeleteBranch(org.Id, plan.Id, "feature"); err != nil {
+			t.Fatal(err)
+		}
+
+		branches, err = ListPlanBranches(org.Id, plan.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(branches) != 1 {
+			t.Fatalf("expected 1 branch after delete, got %d", len(branches))
+		}
+
+		streams, err := GetActiveOrRecentModelStreams([]string{plan.Id})
+		if err != nil || len(streams) != 0 {
+			t.Fatalf("expected no streams, got %d (%v)", len(streams), err)
+		}
+	})
+}
+
+func TestNonUniqueErr(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		createTestPlan(t, "dupe@example.com")
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		err = CreateUser(&User{Name: "Dupe", Email: "dupe@example.com", Domain: "example.com"}, tx)
+		if err == nil {
+			t.Fatal("expected duplicate email to fail")
+		}
+		if !IsNonUniqueErr(err) {
+			t.Fatalf("expected a non-unique error, got %v", err)
+		}
+	})
+}
+
+func TestOrgDefaultSettings(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		_, org, _ := createTestPlan(t, "settings@example.com")
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		settings, err := GetOrgDefaultSettingsForUpdate(org.Id, tx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		settings.ModelPack = shared.DefaultModelPack
+		if err := StoreOrgDefaultSettings(org.Id, settings, tx); err != nil {
+			t.Fatal(err)
+		}
+		// upserts in place
+		if err := StoreOrgDefaultSettings(org.Id, settings, tx); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		stored, err := GetOrgDefaultSettings(org.Id, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.ModelPack == nil || stored.ModelPack.Name != shared.DefaultModelPack.Name {
+			t.Fatalf("expected stored model pack, got %v", stored.ModelPack)
+		}
+	})
+}
+
+func TestRepoLocks(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "locks@example.com")
+
+		lock := func(scope LockScope, branch string) (string, context.CancelFunc, error) {
+			ctx, cancel := context.WithCancel(context.Background())
+			id, err := LockRepo(LockRepoParams{
+				OrgId:    org.Id,
+				UserId:   user.Id,
+				PlanId:   plan.Id,
+				Branch:   branch,
+				Scope:    scope,
+				Ctx:      ctx,
+				CancelFn: cancel,
+			})
+			return id, cancel, err
+		}
+
+		// handlers release locks by cancelling their context, after which the heartbeat loop deletes the lock. Waiting on that also keeps the loop from outliving the connection.
+		countLocks := func() int {
+			var count int
+			if err := Conn.Get(&count, "SELECT COUNT(*) FROM repo_locks WHERE plan_id = $1", plan.Id); err != nil {
+				t.Fatal(err)
+			}
+			return count
+		}
+		waitUnlocked := func() {
+			deadline := time.Now().Add(5 * time.Second)
+			for countLocks() > 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("timed out waiting for locks to be released")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		_, cancelRead, err := lock(LockScopeRead, "main")
+		if err != nil {
+			t.Fatalf("error taking read lock: %v", err)
+		}
+
+		// readers share the lock
+		_, cancelSecondRead, err := lock(LockScopeRead, "main")
+		if err != nil {
+			t.Fatalf("error taking second read lock: %v", err)
+		}
+
+		if n := countLocks(); n != 2 {
+			t.Fatalf("expected 2 read locks, got %d", n)
+		}
+
+		cancelRead()
+		cancelSecondRead()
+		waitUnlocked()
+
+		_, cancelWrite, err := lock(LockScopeWrite, "main")
+		if err != nil {
+			t.Fatalf("error taking write lock: %v", err)
+		}
+
+		if n := countLocks(); n != 1 {
+			t.Fatalf("expected 1 write lock, got %d", n)
+		}
+
+		if err := WaitForRepoUnlocked(plan.Id, "main", 100*time.Millisecond); err == nil {
+			t.Fatal("expected waiting on a held lock to time out")
+		}
+
+		cancelWrite()
+		if err := WaitForRepoUnlocked(plan.Id, "main", 5*time.Second); err != nil {
+			t.Fatalf("expected the write lock to be released: %v", err)
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, _, _ := createTestPlan(t, "sessions@example.com")
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		laptopToken, laptopId, err := CreateAuthToken(user.Id, false, AuthTokenClient{Version: "1.0.0", Ip: "10.0.0.1"}, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desktopToken, desktopId, err := CreateAuthToken(user.Id, false, AuthTokenClient{}, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		desktop, err := ValidateAuthToken(desktopToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := TouchAuthToken(desktop, AuthTokenClient{Version: "1.1.0", Ip: "10.0.0.2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		sessions, err := ListAuthTokens(user.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(sessions))
+		}
+		for _, session := range sessions {
+			if session.Id == desktopId && (session.ClientVersion == nil || *session.ClientVersion != "1.1.0") {
+				t.Errorf("expected desktop session to record client version 1.1.0, got %v", session.ClientVersion)
+			}
+			if session.LastUsedAt == nil {
+				t.Errorf("expected session %s to have last used time", session.Id)
+			}
+		}
+
+		numRevoked, err := RevokeOtherAuthTokens(user.Id, desktopId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if numRevoked != 1 {
+			t.Fatalf("expected 1 session revoked, got %d", numRevoked)
+		}
+
+		if _, err := ValidateAuthToken(laptopToken); err == nil {
+			t.Errorf("expected revoked laptop token to be invalid")
+		}
+		if revoked, err := RevokeAuthToken(user.Id, laptopId); err != nil || revoked {
+			t.Errorf("expected revoking an already revoked session to be a no-op, got %v, %v", revoked, err)
+		}
+
+		revoked, err := RevokeAuthToken(user.Id, desktopId)
+		if err != nil || !revoked {
+			t.Fatalf("expected desktop session to be revoked, got %v, %v", revoked, err)
+		}
+	})
+}
+
+func TestSsoLogins(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, _ := createTestPlan(t, "sso@example.com")
+
+		login, pollSecret, err := CreateSsoLogin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pending, err := ConsumeSsoLogin(login.Id, pollSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pending == nil || pending.CompletedAt != nil {
+			t.Fatalf("expected pending login, got %v", pending)
+		}
+
+		if wrongSecret, _ := ConsumeSsoLogin(login.Id, "wrong"); wrongSecret != nil {
+			t.Fatalf("expected wrong poll secret to find nothing")
+		}
+
+		byState, err := GetPendingSsoLoginByState(login.State)
+		if err != nil || byState == nil || byState.Id != login.Id {
+			t.Fatalf("expected login by state, got %v, %v", byState, err)
+		}
+
+		if len(byState.UserCode) != ssoUserCodeLength || !SsoUserCodeMatches(byState, strings.ToLower(FormatSsoUserCode(login.UserCode))) {
+			t.Errorf("expected the user code %q to match however it's typed", login.UserCode)
+		}
+		if SsoUserCodeMatches(byState, "") || SsoUserCodeMatches(&SsoLogin{}, "") {
+			t.Errorf("expected an empty user code not to match")
+		}
+
+		adminRoleId, err := GetOrgRoleIdByName(org.Id, "admin")
+		if err != nil || adminRoleId == "" {
+			t.Fatalf("expected admin role, got %q, %v", adminRoleId, err)
+		}
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		// the plan's user is already the org's owner, so only updateRole changes their role
+		if err := AddOrgDomainUser(org.Id, user.Id, adminRoleId, false, tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := CompleteSsoLogin(login.Id, &user.Id, nil, tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		orgUser, err := GetOrgUser(user.Id, org.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orgUser.OrgRoleId == adminRoleId {
+			t.Errorf("expected existing member's role to be kept without updateRole")
+		}
+
+		completed, err := ConsumeSsoLogin(login.Id, pollSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if completed == nil || completed.UserId == nil || *completed.UserId != user.Id {
+			t.Fatalf("expected completed login for user, got %v", completed)
+		}
+
+		if again, _ := ConsumeSsoLogin(login.Id, pollSecret); again != nil {
+			t.Errorf("expected a completed login to only be consumed once")
+		}
+		if byState, _ := GetPendingSsoLoginByState(login.State); byState != nil {
+			t.Errorf("expected completed login not to be pending")
+		}
+	})
+}
+
+func TestCustomOrgRoles(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		owner, org, plan := createTestPlan(t, "roles@example.com")
+		_, otherOrg, _ := createTestPlan(t, "other-roles@example.com")
+
+		role := &OrgRole{OrgId: &org.Id, Name: "project-dev", Label: "Project Dev"}
+		permissions := []*shared.OrgRolePermission{
+			{Permission: "create_plan", ResourceId: plan.ProjectId},
+			{Permission: "update_any_plan", ResourceId: plan.ProjectId},
+			{Permission: "list_org_roles"},
+		}
+
+		tx, err := Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := CreateOrgRole(role, permissions, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err = Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dev := &User{Name: "Dev", Email: "dev-roles@example.com", Domain: "example.com"}
+		if err := CreateUser(dev, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		memberRoleId, err := GetOrgMemberRoleId()
+		if err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := CreateOrgUser(org.Id, dev.Id, memberRoleId, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetOrgUserRole(org.Id, dev.Id, role.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		devPermissions, err := GetUserPermissions(dev.Id, org.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]bool{
+			"create_plan|" + plan.ProjectId:     true,
+			"update_any_plan|" + plan.ProjectId: true,
+			"list_org_roles":                    true,
+		}
+		if len(devPermissions) != len(expected) {
+			t.Fatalf("expected %d permissions, got %v", len(expected), devPermissions)
+		}
+		for _, permission := range devPermissions {
+			if !expected[permission] {
+				t.Errorf("unexpected permission %s", permission)
+			}
+		}
+
+		ownerPermissions, err := GetUserPermissions(owner.Id, org.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var canAssign bool
+		for _, permission := range ownerPermissions {
+			if permission == "set_user_role|"+role.Id {
+				canAssign = true
+			}
+		}
+		if !canAssign {
+			t.Errorf("expected owner to be able to assign the custom role")
+		}
+
+		otherPermissions, err := ListOrgRolePermissions(otherOrg.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, permission := range otherPermissions {
+			if permission.OrgRoleId == role.Id || (permission.ResourceId != nil && *permission.ResourceId == role.Id) {
+				t.Errorf("expected other org not to see custom role permissions, got %+v", permission)
+			}
+		}
+
+		if n, err := NumActiveWithRole(org.Id, role.Id); err != nil || n != 1 {
+			t.Fatalf("expected 1 user with role, got %d, %v", n, err)
+		}
+
+		if err := SetOrgUserRole(org.Id, dev.Id, memberRoleId); err != nil {
+			t.Fatal(err)
+		}
+
+		tx, err = Conn.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteOrgRole(org.Id, role.Id, tx); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted, err := GetOrgRole(org.Id, role.Id); err != nil || deleted != nil {
+			t.Fatalf("expected role to be deleted, got %v, %v", deleted, err)
+		}
+
+		ownerPermissions, err = GetUserPermissions(owner.Id, org.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, permission := range ownerPermissions {
+			if strings.HasSuffix(permission, "|"+role.Id) {
+				t.Errorf("expected deleted role's permissions to be removed, got %s", permission)
+			}
+		}
+	})
+}
+
+func TestAuditEvents(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "audit@example.com")
+
+		actions := []shared.AuditAction{shared.AuditActionPlanCreate, shared.AuditActionInviteCreate, shared.AuditActionPlanRename, shared.AuditActionPlanDelete}
+		for _, action := range actions {
+			err := CreateAuditEvent(&AuditEvent{
+				OrgId:      org.Id,
+				ActorId:    user.Id,
+				ActorEmail: user.Email,
+				Action:     action,
+				ResourceId: &plan.Id,
+				Details:    AuditEventDetails{"name": "test"},
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		all, err := ListAuditEvents(org.Id, shared.ListAuditEventsParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != len(actions) || all[0].Action != shared.AuditActionPlanDelete || all[0].Details["name"] != "test" {
+			t.Fatalf("expected all events newest first, got %v", all)
+		}
+
+		planEvents, err := ListAuditEvents(org.Id, shared.ListAuditEventsParams{Action: "plan"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(planEvents) != 3 {
+			t.Errorf("expected 3 plan events, got %d", len(planEvents))
+		}
+
+		var paged []*AuditEvent
+		params := shared.ListAuditEventsParams{Limit: 3}
+		for {
+			page, err := ListAuditEvents(org.Id, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+			if len(page) < params.Limit {
+				break
+			}
+			params.Before = page[len(page)-1].Id
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("expected paging to return %d events, got %d", len(all), len(paged))
+		}
+		for i := range all {
+			if paged[i].Id != all[i].Id {
+				t.Errorf("expected paged events in the same order")
+			}
+		}
+
+		future := time.Now().Add(time.Hour)
+		if events, err := ListAuditEvents(org.Id, shared.ListAuditEventsParams{Since: &future}); err != nil || len(events) != 0 {
+			t.Errorf("expected no events since the future, got %d, %v", len(events), err)
+		}
+		if events, err := ListAuditEvents(org.Id, shared.ListAuditEventsParams{Until: &future}); err != nil || len(events) != len(all) {
+			t.Errorf("expected all events until the future, got %d, %v", len(events), err)
+		}
+
+		if events, err := ListAuditEvents(org.Id, shared.ListAuditEventsParams{ActorEmail: "someone@example.com"}); err != nil || len(events) != 0 {
+			t.Errorf("expected no events for another actor, got %d, %v", len(events), err)
+		}
+
+		if _, err := Conn.Exec("UPDATE audit_events SET action = 'plan.create' WHERE id = $1", all[0].Id); err == nil {
+			t.Errorf("expected audit events to be append-only")
+		}
+	})
+}
+
+func TestSharedPlans(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		owner, org, plan := createTestPlan(t, "share@example.com")
+		otherUserId := "other-user"
+
+		accessible, err := ValidatePlanAccess(plan.Id, otherUserId, org.Id)
+		if err != nil || accessible != nil {
+			t.Fatalf("expected unshared plan to be inaccessible to other users (%v)", err)
+		}
+
+		if err := SetBranchSharedWithOrg(plan.Id, "main", true); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetBranchSharedWithOrg(plan.Id, "missing", true); err == nil {
+			t.Errorf("expected sharing a missing branch to fail")
+		}
+
+		main, err := GetDbBranch(plan.Id, "main")
+		if err != nil || main.SharedWithOrgAt == nil {
+			t.Fatalf("expected main to be shared (%v)", err)
+		}
+
+		accessible, err = ValidatePlanAccess(plan.Id, otherUserId, org.Id)
+		if err != nil || accessible == nil || accessible.SharedWithOrgAt == nil {
+			t.Fatalf("expected shared plan to be accessible to other users (%v)", err)
+		}
+
+		plans, err := ListSharedPlans(org.Id, []string{plan.ProjectId}, otherUserId)
+		if err != nil || len(plans) != 1 || plans[0].Id != plan.Id {
+			t.Fatalf("expected 1 shared plan, got %d (%v)", len(plans), err)
+		}
+
+		plans, err = ListSharedPlans(org.Id, []string{plan.ProjectId}, owner.Id)
+		if err != nil || len(plans) != 0 {
+			t.Fatalf("expected owner's own plans not to be listed as shared, got %d (%v)", len(plans), err)
+		}
+
+		branchNames, err := ListSharedBranchNames([]string{plan.Id})
+		if err != nil || len(branchNames[plan.Id]) != 1 || branchNames[plan.Id][0] != "main" {
+			t.Fatalf("expected main to be the only shared branch, got %v (%v)", branchNames, err)
+		}
+
+		if err := SetBranchSharedWithOrg(plan.Id, "main", false); err != nil {
+			t.Fatal(err)
+		}
+
+		accessible, err = ValidatePlanAccess(plan.Id, otherUserId, org.Id)
+		if err != nil || accessible != nil {
+			t.Fatalf("expected plan to be inaccessible after unsharing its only shared branch (%v)", err)
+		}
+	})
+}
+
+func TestReviewComments(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "review@example.com")
+
+		main, err := GetDbBranch(plan.Id, "main")
+		if err != nil || main == nil {
+			t.Fatalf("expected main branch: %v", err)
+		}
+
+		replacementId := "rep-1"
+		fileComment := &ReviewComment{OrgId: org.Id, PlanId: plan.Id, BranchId: main.Id, AuthorId: user.Id, Path: "main.go", Body: "split this file up"}
+		repComment := &ReviewComment{OrgId: org.Id, PlanId: plan.Id, BranchId: main.Id, AuthorId: user.Id, Path: "main.go", ReplacementId: &replacementId, Body: "handle the error"}
+
+		for _, comment := range []*ReviewComment{fileComment, repComment} {
+			if err := CreateReviewComment(comment); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		comments, err := ListReviewComments(main.Id, false)
+		if err != nil || len(comments) != 2 {
+			t.Fatalf("expected 2 open comments, got %d (%v)", len(comments), err)
+		}
+		// comments made in the same instant can come back in either order
+		for _, comment := range comments {
+			isRepComment := comment.Id == repComment.Id
+			if comment.AuthorEmail != user.Email || (comment.ToApi("main").ReplacementId == replacementId) != isRepComment {
+				t.Errorf("unexpected comment %+v", comment)
+			}
+		}
+
+		numResolved, err := ResolveReviewComments(main.Id, []string{repComment.Id})
+		if err != nil || numResolved != 1 {
+			t.Fatalf("expected 1 comment resolved, got %d (%v)", numResolved, err)
+		}
+
+		comments, err = ListReviewComments(main.Id, false)
+		if err != nil || len(comments) != 1 || comments[0].Id != fileComment.Id {
+			t.Fatalf("expected only the file comment to be open (%v)", err)
+		}
+
+		comments, err = ListReviewComments(main.Id, true)
+		if err != nil || len(comments) != 2 {
+			t.Fatalf("expected 2 comments including resolved, got %d (%v)", len(comments), err)
+		}
+
+		numResolved, err = ResolveReviewComments(main.Id, nil)
+		if err != nil || numResolved != 1 {
+			t.Fatalf("expected remaining comment resolved, got %d (%v)", numResolved, err)
+		}
+
+		if err := DeleteReviewComment(fileComment.Id); err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := GetReviewComment(main.Id, fileComment.Id)
+		if err != nil || deleted != nil {
+			t.Fatalf("expected comment to be deleted (%v)", err)
+		}
+	})
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "webhooks@example.com")
+
+		webhook := &Webhook{OrgId: org.Id, CreatorId: user.Id, Url: "http://localhost:8787", Events: StringList{string(shared.WebhookEventPlanFinished)}}
+		if err := CreateWebhook(webhook); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(webhook.Secret, shared.WebhookSecretPrefix) {
+			t.Errorf("expected a generated secret, got %q", webhook.Secret)
+		}
+
+		// only statuses the webhook is registered for are queued
+		for _, status := range []shared.PlanStatus{shared.PlanStatusReplying, shared.PlanStatusError, shared.PlanStatusFinished} {
+			if err := SetPlanStatus(plan.Id, "main", status, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		due, err := ListDueWebhookDeliveries(10)
+		if err != nil || len(due) != 1 {
+			t.Fatalf("expected 1 due delivery, got %d (%v)", len(due), err)
+		}
+
+		delivery := due[0]
+		var payload shared.WebhookPayload
+		if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Event != shared.WebhookEventPlanFinished || payload.PlanId != plan.Id || payload.PlanName != plan.Name || payload.Branch != "main" || payload.OrgId != org.Id {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+
+		stale := *delivery
+		claimed, err := ClaimWebhookDelivery(delivery, time.Now().Add(time.Minute))
+		if err != nil || !claimed {
+			t.Fatalf("expected delivery to be claimed (%v)", err)
+		}
+		claimed, err = ClaimWebhookDelivery(&stale, time.Now().Add(time.Minute))
+		if err != nil || claimed {
+			t.Fatalf("expected a second claim to fail (%v)", err)
+		}
+
+		due, err = ListDueWebhookDeliveries(10)
+		if err != nil || len(due) != 0 {
+			t.Fatalf("expected claimed delivery not to be due, got %d (%v)", len(due), err)
+		}
+
+		responseStatus := 200
+		delivery.Status = shared.WebhookDeliveryStatusSucceeded
+		delivery.ResponseStatus = &responseStatus
+		if err := UpdateWebhookDeliveryAttempt(delivery); err != nil {
+			t.Fatal(err)
+		}
+
+		deliveries, err := ListWebhookDeliveries(webhook.Id, 10)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("expected 1 delivery, got %d (%v)", len(deliveries), err)
+		}
+		if deliveries[0].Status != shared.WebhookDeliveryStatusSucceeded || deliveries[0].Attempts != 1 || deliveries[0].DeliveredAt == nil || deliveries[0].NextAttemptAt != nil {
+			t.Errorf("unexpected delivery %+v", deliveries[0])
+		}
+
+		test, err := CreateTestWebhookDelivery(webhook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		due, err = ListDueWebhookDeliveries(10)
+		if err != nil || len(due) != 0 {
+			t.Fatalf("expected test delivery not to be due yet, got %d (%v)", len(due), err)
+		}
+		if claimed, err := ClaimWebhookDelivery(test, time.Now().Add(time.Minute)); err != nil || !claimed {
+			t.Fatalf("expected test delivery to be claimed (%v)", err)
+		}
+
+		if err := DeleteWebhook(org.Id, webhook.Id); err != nil {
+			t.Fatal(err)
+		}
+		deliveries, err = ListWebhookDeliveries(webhook.Id, 10)
+		if err != nil || len(deliveries) != 0 {
+			t.Fatalf("expected deliveries to be deleted with the webhook, got %d (%v)", len(deliveries), err)
+		}
+	})
+}
+
+func TestGitDirLocks(t *testing.T) {
+	var wg sync.WaitGroup
+	counter := 0
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockGitDir("plan-dir")
+			counter++
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counter != 50 {
+		t.Errorf("expected 50 increments, got %d", counter)
+	}
+
+	gitDirLocksMu.Lock()
+	defer gitDirLocksMu.Unlock()
+	if len(gitDirLocks) != 0 {
+		t.Errorf("expected locks to be removed once released, got %d", len(gitDirLocks))
+	}
+}
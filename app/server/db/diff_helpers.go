@@ -2,11 +2,14 @@ package db
 
 import (
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"path/filepath"
-
+	"sort"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/plandex/plandex/shared"
 )
 
@@ -20,150 +23,220 @@ func GetPlanDiffs(orgId, planId string) (string, error) {
 		return "", fmt.Errorf("error getting current plan state: %v", err)
 	}
 
-	// create temp directory
-	tempDirPath, err := os.MkdirTemp(getOrgDir(orgId), "tmp-diffs-*")
+	files := planState.CurrentPlanFiles.Files
 
-	if err != nil {
-		return "", fmt.Errorf("error creating temp dir: %v", err)
+	original := map[string]string{}
+	for path, context := range planState.ContextsByPath {
+		if _, hasPath := files[path]; hasPath {
+			original[path] = context.Body
+		}
 	}
 
-	defer func() {
-		go os.RemoveAll(tempDirPath)
-	}()
+	diffs := shared.DiffFiles(original, files)
 
-	// init a git repo in the temp dir
-	err = initGitRepo(tempDirPath)
+	return shared.FormatFileDiffs(diffs, true), nil
+}
 
-	if err != nil {
-		return "", fmt.Errorf("error initializing git repo: %v", err)
+func GetDiffsForBuild(original, updated string) (string, error) {
+	diff := shared.DiffFile("original", "updated", original, updated)
+
+	if diff == nil {
+		return "", nil
 	}
 
-	files := planState.CurrentPlanFiles.Files
+	return diff.Format(false), nil
+}
 
-	// write the original files to the temp dir
-	errCh := make(chan error, len(planState.ContextsByPath))
-	hasAnyOriginal := false
+type patchTurn struct {
+	commitMsg string
+	createdAt time.Time
+	convoIds  map[string]bool
+	// the final turn holding results without a description
+	isCatchAll bool
+}
 
-	for path, context := range planState.ContextsByPath {
-		go func(path string, context *shared.Context) {
-			_, hasPath := files[path]
-			if hasPath {
-				hasAnyOriginal = true
-				// ensure file directory exists
-				err = os.MkdirAll(filepath.Dir(filepath.Join(tempDirPath, path)), 0755)
-				if err != nil {
-					errCh <- fmt.Errorf("error creating directory: %v", err)
-					return
-				}
-
-				err = os.WriteFile(filepath.Join(tempDirPath, path), []byte(context.Body), 0644)
-				if err != nil {
-					errCh <- fmt.Errorf("error writing file: %v", err)
-					return
-				}
-			}
-			errCh <- nil
-		}(path, context)
+// GetPlanPatches returns the pending changes as a 'git format-patch' series with one patch per conversation turn. The author is set to the requesting user so teammates can 'git am' the series.
+func GetPlanPatches(orgId, planId, authorName, authorEmail string) ([]*shared.PlanPatch, error) {
+	planState, err := GetCurrentPlanState(CurrentPlanStateParams{
+		OrgId:  orgId,
+		PlanId: planId,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting current plan state: %v", err)
 	}
 
-	for range planState.ContextsByPath {
-		err = <-errCh
-		if err != nil {
-			return "", fmt.Errorf("error writing original files to temp dir: %v", err)
+	if len(planState.CurrentPlanFiles.Files) == 0 {
+		return nil, nil
+	}
+
+	var turns []*patchTurn
+	turnsByConvoId := map[string]*patchTurn{}
+
+	for _, desc := range planState.ConvoMessageDescriptions {
+		turn := &patchTurn{
+			commitMsg: desc.CommitMsg,
+			createdAt: desc.CreatedAt,
+			convoIds:  map[string]bool{desc.ConvoMessageId: true},
 		}
+		turns = append(turns, turn)
+		turnsByConvoId[desc.ConvoMessageId] = turn
 	}
 
-	if hasAnyOriginal {
-		// add and commit the files in the temp dir
-		err := gitAdd(tempDirPath, ".")
-		if err != nil {
-			return "", fmt.Errorf("error adding files to git repository for dir: %s, err: %v", tempDirPath, err)
+	sort.Slice(turns, func(i, j int) bool {
+		return turns[i].createdAt.Before(turns[j].createdAt)
+	})
+
+	// results without a description go in a final catch-all turn
+	for _, res := range planState.PlanResult.Results {
+		if !res.IsPending() || turnsByConvoId[res.ConvoMessageId] != nil {
+			continue
 		}
 
-		err = gitCommit(tempDirPath, "original files")
-		if err != nil {
-			return "", fmt.Errorf("error committing files to git repository for dir: %s, err: %v", tempDirPath, err)
+		if len(turns) == 0 || !turns[len(turns)-1].isCatchAll {
+			turns = append(turns, &patchTurn{
+				commitMsg:  "Pending changes",
+				createdAt:  res.CreatedAt,
+				convoIds:   map[string]bool{},
+				isCatchAll: true,
+			})
 		}
+		turns[len(turns)-1].convoIds[res.ConvoMessageId] = true
+	}
+
+	// the series is committed to an in-memory repo so each patch carries the sha that 'git am' will recreate
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		return nil, fmt.Errorf("error initializing git repo: %v", err)
 	}
 
-	// write the current files to the temp dir
-	errCh = make(chan error, len(files))
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("error getting git worktree: %v", err)
+	}
 
-	for path, file := range files {
-		go func(path, file string) {
-			// ensure file directory exists
-			err = os.MkdirAll(filepath.Dir(filepath.Join(tempDirPath, path)), 0755)
+	writeFiles := func(files map[string]string) error {
+		for path, body := range files {
+			err := util.WriteFile(wt.Filesystem, path, []byte(body), 0644)
 			if err != nil {
-				errCh <- fmt.Errorf("error creating directory: %v", err)
-				return
+				return fmt.Errorf("error writing file: %v", err)
 			}
 
-			err = os.WriteFile(filepath.Join(tempDirPath, path), []byte(file), 0644)
+			_, err = wt.Add(path)
 			if err != nil {
-				errCh <- fmt.Errorf("error writing file: %v", err)
-				return
+				return fmt.Errorf("error adding file: %v", err)
 			}
-			errCh <- nil
-		}(path, file)
+		}
+		return nil
 	}
 
-	for range files {
-		err = <-errCh
+	commit := func(msg string, at time.Time) (string, error) {
+		signature := &object.Signature{
+			Name:  authorName,
+			Email: authorEmail,
+			When:  at.UTC(),
+		}
+
+		hash, err := wt.Commit(msg, &git.CommitOptions{
+			Author:            signature,
+			Committer:         signature,
+			AllowEmptyCommits: true,
+		})
 		if err != nil {
-			return "", fmt.Errorf("error writing current files to temp dir: %v", err)
+			return "", fmt.Errorf("error committing files: %v", err)
 		}
+		return hash.String(), nil
 	}
 
-	err = gitAdd(tempDirPath, ".")
-	if err != nil {
-		return "", fmt.Errorf("error adding files to git repository for dir: %s, err: %v", tempDirPath, err)
+	// the first commit holds the original files and isn't exported
+	originalFiles := map[string]string{}
+	for path := range planState.CurrentPlanFiles.Files {
+		if context, ok := planState.ContextsByPath[path]; ok {
+			originalFiles[path] = context.Body
+		}
 	}
 
-	res, err := exec.Command("git", "-C", tempDirPath, "diff", "--cached", "--color=always").CombinedOutput()
+	err = writeFiles(originalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("error writing original files: %v", err)
+	}
 
+	_, err = commit("original files\n", time.Now())
 	if err != nil {
-		return "", fmt.Errorf("error getting diffs: %v", err)
+		return nil, err
 	}
 
-	return string(res), nil
-}
+	includedConvoIds := map[string]bool{}
+	prevFiles := originalFiles
+	var commits []*shared.PatchCommit
+	for _, turn := range turns {
+		for convoId := range turn.convoIds {
+			includedConvoIds[convoId] = true
+		}
 
-func GetDiffsForBuild(original, updated string) (string, error) {
-	// create temp directory
-	tempDirPath, err := os.MkdirTemp("", "tmp-diffs-*")
+		var turnResults []*shared.PlanFileResult
+		for _, res := range planState.PlanResult.Results {
+			if includedConvoIds[res.ConvoMessageId] {
+				turnResults = append(turnResults, res)
+			}
+		}
 
-	if err != nil {
-		return "", fmt.Errorf("error creating temp dir: %v", err)
-	}
+		turnState := &shared.CurrentPlanState{
+			PlanResult:     GetPlanResult(turnResults),
+			ContextsByPath: planState.ContextsByPath,
+		}
 
-	defer func() {
-		go os.RemoveAll(tempDirPath)
-	}()
+		turnFiles, err := turnState.GetFiles()
+		if err != nil {
+			return nil, fmt.Errorf("error getting files for turn: %v", err)
+		}
 
-	// write the original file to the temp dir
-	err = os.WriteFile(filepath.Join(tempDirPath, "original"), []byte(original), 0644)
-	if err != nil {
-		return "", fmt.Errorf("error writing original file: %v", err)
-	}
+		// skip turns that didn't change any files--'git am' can't apply empty patches
+		changed := map[string]string{}
+		prevChanged := map[string]string{}
+		for path, body := range turnFiles.Files {
+			prev, ok := prevFiles[path]
+			if !ok || prev != body {
+				changed[path] = body
+			}
+			if ok && prev != body {
+				prevChanged[path] = prev
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		prevFiles = turnFiles.Files
 
-	// write the updated file to the temp dir
-	err = os.WriteFile(filepath.Join(tempDirPath, "updated"), []byte(updated), 0644)
-	if err != nil {
-		return "", fmt.Errorf("error writing updated file: %v", err)
-	}
+		err = writeFiles(changed)
+		if err != nil {
+			return nil, fmt.Errorf("error writing files for turn: %v", err)
+		}
 
-	res, err := exec.Command("git", "-C", tempDirPath, "diff", "--no-color", "--no-index", "original", "updated").CombinedOutput()
+		// cleaned up as 'git commit -m' would, so the sha matches what 'git am' creates
+		msg := cleanupCommitMsg(turn.commitMsg)
+		if msg == "" {
+			msg = "Pending changes\n"
+		}
 
-	if err != nil {
-		exitError, ok := err.(*exec.ExitError)
-		if ok && exitError.ExitCode() == 1 {
-			// Exit status 1 means diffs were found, which is expected
-		} else {
-			log.Printf("Error getting diffs: %v\n", err)
-			log.Printf("Diff output: %s\n", res)
-			return "", fmt.Errorf("error getting diffs: %v", err)
+		sha, err := commit(msg, turn.createdAt)
+		if err != nil {
+			return nil, err
 		}
+
+		commits = append(commits, &shared.PatchCommit{
+			Sha:         sha,
+			AuthorName:  authorName,
+			AuthorEmail: authorEmail,
+			Date:        turn.createdAt.UTC(),
+			Message:     msg,
+			Diffs:       shared.DiffFiles(prevChanged, changed),
+		})
+	}
+
+	if len(commits) == 0 {
+		return nil, nil
 	}
 
-	return string(res), nil
+	return shared.FormatPatches(commits, "Plandex"), nil
 }
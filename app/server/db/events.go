@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"sync"
+)
+
+// EventBus is pub/sub between server instances. Any instance can publish to a channel and every subscriber to that channel, on any instance, gets the payload. Delivery is best effort: there's no replay, so subscribers only see what's published while they're subscribed.
+type EventBus interface {
+	Publish(channel string, payload []byte) error
+	Subscribe(channel string) (ch <-chan []byte, unsubscribe func(), err error)
+	Close() error
+}
+
+var bus EventBus = newMemoryEventBus()
+
+func CurrentEventBus() EventBus {
+	return bus
+}
+
+func PublishEvent(channel string, payload []byte) error {
+	return bus.Publish(channel, payload)
+}
+
+func SubscribeEvents(channel string) (<-chan []byte, func(), error) {
+	return bus.Subscribe(channel)
+}
+
+// memoryEventBus only reaches subscribers in this process, which is all there is with a single instance
+type memoryEventBus struct {
+	dispatcher *eventDispatcher
+}
+
+func newMemoryEventBus() *memoryEventBus {
+	return &memoryEventBus{dispatcher: newEventDispatcher(nil, nil)}
+}
+
+func (b *memoryEventBus) Publish(channel string, payload []byte) error {
+	b.dispatcher.dispatch(channel, payload)
+	return nil
+}
+
+func (b *memoryEventBus) Subscribe(channel string) (<-chan []byte, func(), error) {
+	return b.dispatcher.subscribe(channel)
+}
+
+func (b *memoryEventBus) Close() error {
+	return nil
+}
+
+// eventDispatcher fans payloads out to this process's subscribers. onFirst and onLast run when a channel gets its first subscriber or loses its last one, so a bus can start and stop listening.
+type eventDispatcher struct {
+	mu      sync.Mutex
+	subs    map[string]map[*eventSubscriber]bool
+	onFirst func(channel string) error
+	onLast  func(channel string)
+}
+
+func newEventDispatcher(onFirst func(channel string) error, onLast func(channel string)) *eventDispatcher {
+	return &eventDispatcher{
+		subs:    map[string]map[*eventSubscriber]bool{},
+		onFirst: onFirst,
+		onLast:  onLast,
+	}
+}
+
+func (d *eventDispatcher) subscribe(channel string) (<-chan []byte, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.subs[channel]) == 0 && d.onFirst != nil {
+		err := d.onFirst(channel)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if d.subs[channel] == nil {
+		d.subs[channel] = map[*eventSubscriber]bool{}
+	}
+
+	sub := newEventSubscriber()
+	d.subs[channel][sub] = true
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+
+			sub.close()
+			delete(d.subs[channel], sub)
+			if len(d.subs[channel]) == 0 {
+				delete(d.subs, channel)
+				if d.onLast != nil {
+					d.onLast(channel)
+				}
+			}
+		})
+	}
+
+	return sub.ch, unsubscribe, nil
+}
+
+func (d *eventDispatcher) dispatch(channel string, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for sub := range d.subs[channel] {
+		sub.enqueue(payload)
+	}
+}
+
+// eventSubscriber queues payloads so a slow reader never holds up delivery to the rest
+type eventSubscriber struct {
+	ch       chan []byte
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	mu       sync.Mutex
+	queue    [][]byte
+	cond     *sync.Cond
+}
+
+func newEventSubscriber() *eventSubscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &eventSubscriber{
+		ch:       make(chan []byte),
+		ctx:      ctx,
+		cancelFn: cancel,
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+	go sub.run()
+	return sub
+}
+
+func (sub *eventSubscriber) run() {
+	for {
+		sub.mu.Lock()
+		for len(sub.queue) == 0 && sub.ctx.Err() == nil {
+			sub.cond.Wait()
+		}
+		if sub.ctx.Err() != nil {
+			sub.mu.Unlock()
+			return
+		}
+		payload := sub.queue[0]
+		sub.queue = sub.queue[1:]
+		sub.mu.Unlock()
+
+		select {
+		case <-sub.ctx.Done():
+			return
+		case sub.ch <- payload:
+		}
+	}
+}
+
+func (sub *eventSubscriber) enqueue(payload []byte) {
+	sub.mu.Lock()
+	sub.queue = append(sub.queue, payload)
+	sub.mu.Unlock()
+	sub.cond.Signal()
+}
+
+func (sub *eventSubscriber) close() {
+	sub.mu.Lock()
+	sub.cancelFn()
+	sub.mu.Unlock()
+	sub.cond.Signal()
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// NOTIFY payloads must be under 8000 bytes, so larger ones are split into chunks and reassembled by listeners
+const pgNotifyChunkSize = 7000
+
+// a message that's still missing chunks after this long won't be completed--its publisher likely went away partway through
+const pgPartialEventTimeout = time.Minute
+
+// pgEventBus sends events over Postgres LISTEN/NOTIFY, so instances sharing a database can reach each other without knowing each other's addresses. Payloads must be valid UTF-8 text.
+type pgEventBus struct {
+	listener   *pq.Listener
+	dispatcher *eventDispatcher
+
+	partialMu sync.Mutex
+	partials  map[string]*pgPartialEvent
+}
+
+type pgPartialEvent struct {
+	chunks    []string
+	total     int
+	startedAt time.Time
+}
+
+func newPgEventBus(dbUrl string) (*pgEventBus, error) {
+	b := &pgEventBus{partials: map[string]*pgPartialEvent{}}
+
+	b.listener = pq.NewListener(dbUrl, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event bus listener error: %v\n", err)
+		}
+		if ev == pq.ListenerEventReconnected {
+			// pq re-listens on every channel, but anything sent while disconnected is gone
+			log.Println("event bus listener reconnected")
+		}
+	})
+
+	err := b.listener.Ping()
+	if err != nil {
+		b.listener.Close()
+		return nil, fmt.Errorf("error connecting event bus listener: %v", err)
+	}
+
+	b.dispatcher = newEventDispatcher(
+		func(channel string) error {
+			err := b.listener.Listen(channel)
+			if err != nil && err != pq.ErrChannelAlreadyOpen {
+				return fmt.Errorf("error listening on channel %s: %v", channel, err)
+			}
+			return nil
+		},
+		func(channel string) {
+			err := b.listener.Unlisten(channel)
+			if err != nil && err != pq.ErrChannelNotOpen {
+				log.Printf("error unlistening on channel %s: %v\n", channel, err)
+			}
+		},
+	)
+
+	go b.receive()
+
+	return b, nil
+}
+
+func (b *pgEventBus) Publish(channel string, payload []byte) error {
+	pgChannel := pgEventChannel(channel)
+	for _, notification := range pgNotifyChunks(uuid.New().String(), payload) {
+		_, err := Conn.Exec("SELECT pg_notify($1, $2)", pgChannel, notification)
+		if err != nil {
+			return fmt.Errorf("error publishing event to channel %s: %v", channel, err)
+		}
+	}
+
+	return nil
+}
+
+// pgNotifyChunks splits a payload into NOTIFY payloads of the form <id>:<index>:<total>:<data>
+func pgNotifyChunks(id string, payload []byte) []string {
+	data := string(payload)
+
+	var chunks []string
+	for len(data) > pgNotifyChunkSize {
+		end := pgNotifyChunkSize
+		// don't split a multi-byte character
+		for end > 0 && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		chunks = append(chunks, data[:end])
+		data = data[end:]
+	}
+	chunks = append(chunks, data)
+
+	for i, chunk := range chunks {
+		chunks[i] = fmt.Sprintf("%s:%d:%d:%s", id, i, len(chunks), chunk)
+	}
+
+	return chunks
+}
+
+func (b *pgEventBus) Subscribe(channel string) (<-chan []byte, func(), error) {
+	return b.dispatcher.subscribe(pgEventChannel(channel))
+}
+
+func (b *pgEventBus) Close() error {
+	return b.listener.Close()
+}
+
+func (b *pgEventBus) receive() {
+	for n := range b.listener.Notify {
+		if n == nil {
+			// sent after a reconnect
+			continue
+		}
+
+		payload, ok := b.assemble(n.Channel, n.Extra)
+		if ok {
+			b.dispatcher.dispatch(n.Channel, payload)
+		}
+	}
+}
+
+// assemble collects a notification's chunk, returning the full payload once every chunk has arrived. Chunks of a message come from one publisher in order.
+func (b *pgEventBus) assemble(channel, extra string) ([]byte, bool) {
+	parts := strings.SplitN(extra, ":", 4)
+	if len(parts) != 4 {
+		log.Printf("malformed event on channel %s\n", channel)
+		return nil, false
+	}
+	id, data := parts[0], parts[3]
+	idx, err1 := strconv.Atoi(parts[1])
+	total, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		log.Printf("malformed event on channel %s\n", channel)
+		return nil, false
+	}
+
+	if total == 1 {
+		return []byte(data), true
+	}
+
+	b.partialMu.Lock()
+	defer b.partialMu.Unlock()
+
+	key := channel + ":" + id
+	partial := b.partials[key]
+	if partial == nil {
+		if idx != 0 {
+			// joined partway through the message
+			return nil, false
+		}
+
+		for k, p := range b.partials {
+			if time.Since(p.startedAt) > pgPartialEventTimeout {
+				delete(b.partials, k)
+			}
+		}
+
+		partial = &pgPartialEvent{total: total, startedAt: time.Now()}
+		b.partials[key] = partial
+	}
+
+	partial.chunks = append(partial.chunks, data)
+	if len(partial.chunks) < partial.total {
+		return nil, false
+	}
+
+	delete(b.partials, key)
+	return []byte(strings.Join(partial.chunks, "")), true
+}
+
+// pgEventChannel maps a channel name to one that fits Postgres' 63 byte limit on identifiers
+func pgEventChannel(channel string) string {
+	sum := sha1.Sum([]byte(channel))
+	return "plandex_" + hex.EncodeToString(sum[:])
+}
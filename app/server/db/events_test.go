@@ -0,0 +1,117 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func receiveEvent(t *testing.T, ch <-chan []byte) string {
+	t.Helper()
+	select {
+	case payload := <-ch:
+		return string(payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return ""
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		chA, unsubscribeA, err := SubscribeEvents("plan:a:main:stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unsubscribeA()
+
+		chA2, unsubscribeA2, err := SubscribeEvents("plan:a:main:stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chB, unsubscribeB, err := SubscribeEvents("plan:b:main:stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unsubscribeB()
+
+		// larger than a single NOTIFY, with multi-byte characters across chunk boundaries
+		large := `{"reply": "` + strings.Repeat("héllo wörld ✓ ", 2000) + `"}`
+
+		for _, payload := range []string{"one", large, "three"} {
+			if err := PublishEvent("plan:a:main:stream", []byte(payload)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := PublishEvent("plan:b:main:stream", []byte("other")); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ch := range []<-chan []byte{chA, chA2} {
+			if got := receiveEvent(t, ch); got != "one" {
+				t.Fatalf("expected one, got %q", got)
+			}
+			if got := receiveEvent(t, ch); got != large {
+				t.Fatalf("large payload didn't round trip, got %d bytes", len(got))
+			}
+			if got := receiveEvent(t, ch); got != "three" {
+				t.Fatalf("expected three, got %q", got)
+			}
+		}
+
+		if got := receiveEvent(t, chB); got != "other" {
+			t.Fatalf("expected other, got %q", got)
+		}
+
+		// the remaining subscriber keeps receiving after another unsubscribes
+		unsubscribeA2()
+		if err := PublishEvent("plan:a:main:stream", []byte("four")); err != nil {
+			t.Fatal(err)
+		}
+		if got := receiveEvent(t, chA); got != "four" {
+			t.Fatalf("expected four, got %q", got)
+		}
+		select {
+		case payload := <-chA2:
+			t.Fatalf("unexpected event after unsubscribing: %q", payload)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestPgNotifyChunks(t *testing.T) {
+	b := &pgEventBus{partials: map[string]*pgPartialEvent{}}
+
+	payloads := []string{"", "small", strings.Repeat("ü✓", 5000), strings.Repeat("x", pgNotifyChunkSize*3)}
+	for i, payload := range payloads {
+		chunks := pgNotifyChunks(fmt.Sprintf("id-%d", i), []byte(payload))
+
+		for j, chunk := range chunks {
+			if len(chunk) >= 8000 {
+				t.Fatalf("chunk %d of payload %d is %d bytes", j, i, len(chunk))
+			}
+			if !utf8.ValidString(chunk) {
+				t.Fatalf("chunk %d of payload %d isn't valid UTF-8", j, i)
+			}
+
+			got, ok := b.assemble("channel", chunk)
+			if ok != (j == len(chunks)-1) {
+				t.Fatalf("payload %d assembled after chunk %d of %d", i, j, len(chunks))
+			}
+			if ok && string(got) != payload {
+				t.Fatalf("payload %d didn't round trip", i)
+			}
+		}
+	}
+
+	// a message joined partway through is dropped rather than delivered incomplete
+	chunks := pgNotifyChunks("late", []byte(strings.Repeat("x", pgNotifyChunkSize*2)))
+	for _, chunk := range chunks[1:] {
+		if _, ok := b.assemble("channel", chunk); ok {
+			t.Fatal("expected a partial message to be dropped")
+		}
+	}
+}
@@ -50,12 +50,28 @@ func InitPlan(orgId, planId string) error {
 		return fmt.Errorf("error initializing git repo: %v", err)
 	}
 
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	err = syncPlanDir(dir)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func DeletePlanDir(orgId, planId string) error {
 	dir := getPlanDir(orgId, planId)
-	err := os.RemoveAll(dir)
+
+	err := planStorage.Delete(dir)
+
+	if err != nil {
+		return fmt.Errorf("error deleting plan from %s storage: %v", planStorage.Name(), err)
+	}
+
+	err = os.RemoveAll(dir)
 
 	if err != nil {
 		return fmt.Errorf("error deleting plan dir: %v", err)
@@ -1,89 +1,162 @@
 package db
 
 import (
-	"bytes"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
+	"plandex-server/metrics"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
-const maxGitRetries = 5
-const initialGitRetryInterval = 100 * time.Millisecond
+// Plan repos are read and written in-process with go-git, so the server doesn't need a git binary. go-git doesn't take git's index.lock, so operations on a repo are serialized by a per-dir mutex instead.
 
-func init() {
-	// ensure git is available
-	cmd := exec.Command("git", "--version")
-	if err := cmd.Run(); err != nil {
-		panic(fmt.Errorf("error running git --version: %v", err))
+const (
+	gitUserName  = "Plandex"
+	gitUserEmail = "server@plandex.ai"
+)
+
+// a dir's mutex is counted by everyone holding or waiting on it, and removed once the count drops to zero, so locks for idle and deleted plans don't pile up
+type gitDirLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var gitDirLocks = map[string]*gitDirLock{}
+var gitDirLocksMu sync.Mutex
+
+func lockGitDir(dir string) func() {
+	gitDirLocksMu.Lock()
+	lock, ok := gitDirLocks[dir]
+	if !ok {
+		lock = &gitDirLock{}
+		gitDirLocks[dir] = lock
+	}
+	lock.refs++
+	gitDirLocksMu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		gitDirLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(gitDirLocks, dir)
+		}
+		gitDirLocksMu.Unlock()
 	}
 }
 
+// timeGitOperation records how long a git operation took, including any wait for the repo's mutex. It's meant to be deferred at the top of the operation.
+func timeGitOperation(operation string, start time.Time) {
+	metrics.ObserveSince(metrics.GitOperationDuration.WithLabelValues(operation), start)
+}
+
 func InitGitRepo(orgId, planId string) error {
 	dir := getPlanDir(orgId, planId)
 	return initGitRepo(dir)
 }
 
 func initGitRepo(dir string) error {
+	defer timeGitOperation("init", time.Now())
+
+	unlock := lockGitDir(dir)
+	defer unlock()
+
 	// Set the default branch name to 'main' for the new repository
-	res, err := exec.Command("git", "-C", dir, "init", "-b", "main").CombinedOutput()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{
+			DefaultBranch: plumbing.NewBranchReferenceName("main"),
+		},
+	})
 	if err != nil {
-		return fmt.Errorf("error initializing git repository with 'main' as default branch for dir: %s, err: %v, output: %s", dir, err, string(res))
+		return fmt.Errorf("error initializing git repository with 'main' as default branch for dir: %s, err: %v", dir, err)
 	}
 
-	// Configure user name and email for the repository
-	if err := setGitConfig(dir, "user.email", "server@plandex.ai"); err != nil {
-		return err
+	// Configure user name and email for the repository so it reads the same when inspected with git
+	cfg, err := repo.Config()
+	if err != nil {
+		return fmt.Errorf("error getting git config for dir: %s, err: %v", dir, err)
 	}
-	if err := setGitConfig(dir, "user.name", "Plandex"); err != nil {
-		return err
+	cfg.User.Name = gitUserName
+	cfg.User.Email = gitUserEmail
+
+	err = repo.SetConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("error setting git config for dir: %s, err: %v", dir, err)
 	}
 
 	return nil
 }
 
 func GitAddAndCommit(orgId, planId, branch, message string) error {
+	defer timeGitOperation("add_and_commit", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	err := retryGitWriteOperationIfIndexFileErr(func() error {
-		return gitAdd(dir, ".")
-	})
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	err = gitAdd(repo, dir)
 	if err != nil {
 		return fmt.Errorf("error adding files to git repository for dir: %s, err: %v", dir, err)
 	}
 
-	err = retryGitWriteOperationIfIndexFileErr(func() error {
-		return gitCommit(dir, message)
-	})
+	err = gitCommit(repo, message)
 	if err != nil {
 		return fmt.Errorf("error committing files to git repository for dir: %s, err: %v", dir, err)
 	}
 
-	return nil
+	return syncPlanDir(dir)
 }
 
 func GitRewindToSha(orgId, planId, branch, sha string) error {
+	defer timeGitOperation("rewind", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	err := retryGitWriteOperationIfIndexFileErr(func() error {
-		return gitRewindToSha(dir, sha)
-	})
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	err = gitRewindToSha(repo, sha)
 	if err != nil {
 		return fmt.Errorf("error rewinding git repository for dir: %s, err: %v", dir, err)
 	}
 
-	return nil
+	return syncPlanDir(dir)
 }
 
 func GetGitCommitHistory(orgId, planId, branch string) (body string, shas []string, err error) {
+	defer timeGitOperation("commit_history", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
+	unlock := lockGitDir(dir)
+	defer unlock()
+
 	body, shas, err = getGitCommitHistory(dir)
 	if err != nil {
 		return "", nil, fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
@@ -93,8 +166,13 @@ func GetGitCommitHistory(orgId, planId, branch string) (body string, shas []stri
 }
 
 func GetLatestCommit(orgId, planId, branch string) (sha, body string, err error) {
+	defer timeGitOperation("latest_commit", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
+	unlock := lockGitDir(dir)
+	defer unlock()
+
 	sha, body, err = getLatestCommit(dir)
 	if err != nil {
 		return "", "", fmt.Errorf("error getting latest commit for dir: %s, err: %v", dir, err)
@@ -103,101 +181,216 @@ func GetLatestCommit(orgId, planId, branch string) (sha, body string, err error)
 	return sha, body, nil
 }
 
+func GetLatestCommitMsgAndParentSha(orgId, planId string) (msg, parentSha string, err error) {
+	defer timeGitOperation("latest_commit", time.Now())
+
+	dir := getPlanDir(orgId, planId)
+
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	commit, err := getHeadCommit(repo)
+	if err != nil {
+		return "", "", fmt.Errorf("error getting latest commit message for dir: %s, err: %v", dir, err)
+	}
+	msg = strings.TrimSpace(commit.Message)
+
+	if len(commit.ParentHashes) == 0 {
+		// the latest commit has no parent
+		return msg, "", nil
+	}
+	parentSha = shortSha(commit.ParentHashes[0])
+
+	return msg, parentSha, nil
+}
+
 func GitListBranches(orgId, planId string) ([]string, error) {
+	defer timeGitOperation("list_branches", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	var out bytes.Buffer
-	cmd := exec.Command("git", "branch", "--format=%(refname:short)")
-	cmd.Dir = dir
-	cmd.Stdout = &out
-	err := cmd.Run()
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.Branches()
 	if err != nil {
 		return nil, fmt.Errorf("error getting git branches for dir: %s, err: %v", dir, err)
 	}
 
-	branches := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var branches []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches = append(branches, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting git branches for dir: %s, err: %v", dir, err)
+	}
 
-	if len(branches) == 0 || (len(branches) == 1 && branches[0] == "") {
+	if len(branches) == 0 {
 		return []string{"main"}, nil
 	}
 
+	sort.Strings(branches)
+
 	return branches, nil
 }
 
 func GitCreateBranch(orgId, planId, branch, newBranch string) error {
+	defer timeGitOperation("create_branch", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	err := retryGitWriteOperationIfIndexFileErr(func() error {
-		res, err := exec.Command("git", "-C", dir, "checkout", "-b", newBranch).CombinedOutput()
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	newRef := plumbing.NewBranchReferenceName(newBranch)
+
+	_, err = repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		// nothing is committed yet, so like 'git checkout -b' there's no ref to create--just point HEAD at the new branch
+		err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, newRef))
 		if err != nil {
-			return fmt.Errorf("error creating git branch for dir: %s, err: %v, output: %s", dir, err, string(res))
+			return fmt.Errorf("error creating git branch for dir: %s, err: %v", dir, err)
 		}
-		return nil
+		return syncPlanDir(dir)
+	} else if err != nil {
+		return fmt.Errorf("error getting git head for dir: %s, err: %v", dir, err)
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting git worktree for dir: %s, err: %v", dir, err)
+	}
+
+	// Keep leaves the index and worktree alone, which is all 'git checkout -b' does since the new branch starts at HEAD
+	err = wt.Checkout(&git.CheckoutOptions{
+		Branch: newRef,
+		Create: true,
+		Keep:   true,
 	})
+	if err != nil {
+		return fmt.Errorf("error creating git branch for dir: %s, err: %v", dir, err)
+	}
 
-	return err
+	return syncPlanDir(dir)
 }
 
 func GitDeleteBranch(orgId, planId, branchName string) error {
+	defer timeGitOperation("delete_branch", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	err := retryGitWriteOperationIfIndexFileErr(func() error {
-		res, err := exec.Command("git", "-C", dir, "branch", "-D", branchName).CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error deleting git branch for dir: %s, err: %v, output: %s", dir, err, string(res))
-		}
-		return nil
-	})
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	repo, err := openGitRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	ref := plumbing.NewBranchReferenceName(branchName)
+
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return fmt.Errorf("error getting git head for dir: %s, err: %v", dir, err)
+	}
+	if head.Type() == plumbing.SymbolicReference && head.Target() == ref {
+		return fmt.Errorf("error deleting git branch for dir: %s, err: cannot delete branch '%s' used by worktree", dir, branchName)
+	}
 
-	return err
+	_, err = repo.Storer.Reference(ref)
+	if err != nil {
+		return fmt.Errorf("error deleting git branch for dir: %s, err: branch '%s' not found", dir, branchName)
+	}
+
+	err = repo.Storer.RemoveReference(ref)
+	if err != nil {
+		return fmt.Errorf("error deleting git branch for dir: %s, err: %v", dir, err)
+	}
+
+	return syncPlanDir(dir)
 }
 
 func GitClearUncommittedChanges(orgId, planId string) error {
+	defer timeGitOperation("clear_uncommitted", time.Now())
+
 	dir := getPlanDir(orgId, planId)
 
-	err := retryGitWriteOperationIfIndexFileErr(func() error {
-		// Reset staged changes
-		res, err := exec.Command("git", "-C", dir, "reset", "--hard").CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error resetting staged changes | err: %v, output: %s", err, string(res))
-		}
-		return nil
-	})
+	unlock := lockGitDir(dir)
+	defer unlock()
 
+	repo, err := openGitRepo(dir)
 	if err != nil {
 		return err
 	}
 
-	err = retryGitWriteOperationIfIndexFileErr(func() error {
-		// Clean untracked files
-		res, err := exec.Command("git", "-C", dir, "clean", "-d", "-f").CombinedOutput()
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting git worktree for dir: %s, err: %v", dir, err)
+	}
+
+	// Reset staged changes
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		// nothing committed yet--everything is uncommitted
+		err = repo.Storer.SetIndex(&index.Index{Version: 2})
+		if err != nil {
+			return fmt.Errorf("error resetting staged changes | err: %v", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("error getting git head for dir: %s, err: %v", dir, err)
+	} else {
+		err = wt.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset})
 		if err != nil {
-			return fmt.Errorf("error cleaning untracked files | err: %v, output: %s", err, string(res))
+			return fmt.Errorf("error resetting staged changes | err: %v", err)
 		}
+	}
 
-		return nil
-	})
+	// Clean untracked files
+	err = wt.Clean(&git.CleanOptions{Dir: true})
+	if err != nil {
+		return fmt.Errorf("error cleaning untracked files | err: %v", err)
+	}
 
-	return err
+	return syncPlanDir(dir)
 }
 
-// Not used currently but may be good to handle these errors specifically later if locking can't fully prevent them
-// func isLockFileError(output string) bool {
-// 	return strings.Contains(output, "fatal: Unable to create") && strings.Contains output, ".git/index.lock': File exists")
-// }
-
 func gitCheckoutBranch(repoDir, branch string) error {
+	defer timeGitOperation("checkout_branch", time.Now())
+
+	unlock := lockGitDir(repoDir)
+	defer unlock()
+
+	repo, err := openGitRepo(repoDir)
+	if err != nil {
+		return err
+	}
+
 	// get current branch and only checkout if it's not the same
-	// trying to check out the same branch will result in an error
-	var out bytes.Buffer
-	cmd := exec.Command("git", "-C", repoDir, "branch", "--show-current")
-	cmd.Stdout = &out
-	err := cmd.Run()
+	head, err := repo.Storer.Reference(plumbing.HEAD)
 	if err != nil {
 		return fmt.Errorf("error getting current git branch for dir: %s, err: %v", repoDir, err)
 	}
 
-	currentBranch := strings.TrimSpace(out.String())
+	currentBranch := ""
+	if head.Type() == plumbing.SymbolicReference {
+		currentBranch = head.Target().Short()
+	}
 
 	log.Println("currentBranch:", currentBranch)
 
@@ -205,197 +398,357 @@ func gitCheckoutBranch(repoDir, branch string) error {
 		return nil
 	}
 
-	err = retryGitWriteOperationIfIndexFileErr(func() error {
-		log.Println("checking out branch:", branch)
-		res, err := exec.Command("git", "-C", repoDir, "checkout", branch).CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error checking out git branch for dir: %s, err: %v, output: %s", repoDir, err, string(res))
-		}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("error getting git worktree for dir: %s, err: %v", repoDir, err)
+	}
 
-		return nil
+	log.Println("checking out branch:", branch)
+	err = wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
 	})
+	if err != nil {
+		return fmt.Errorf("error checking out git branch for dir: %s, err: %v", repoDir, err)
+	}
 
-	return err
+	return syncPlanDir(repoDir)
 }
 
-func gitRewindToSha(repoDir, sha string) error {
-	res, err := exec.Command("git", "-C", repoDir, "reset", "--hard", sha).CombinedOutput()
+func gitRewindToSha(repo *git.Repository, sha string) error {
+	hash, err := repo.ResolveRevision(plumbing.Revision(sha))
+	if err != nil {
+		return fmt.Errorf("error resolving sha: %s, err: %v", sha, err)
+	}
+
+	wt, err := repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("error executing git reset for dir: %s, sha: %s, err: %v, output: %s", repoDir, sha, err, string(res))
+		return fmt.Errorf("error getting git worktree: %v", err)
+	}
+
+	err = wt.Reset(&git.ResetOptions{Commit: *hash, Mode: git.HardReset})
+	if err != nil {
+		return fmt.Errorf("error executing git reset for sha: %s, err: %v", sha, err)
 	}
 
 	return nil
 }
 
 func getLatestCommit(dir string) (sha, body string, err error) {
-	var out bytes.Buffer
-	cmd := exec.Command("git", "log", "--pretty=%h@@|@@%at@@|@@%B@>>>@")
-	cmd.Dir = dir
-	cmd.Stdout = &out
-	err = cmd.Run()
+	repo, err := openGitRepo(dir)
 	if err != nil {
-		return "", "", fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
+		return "", "", err
 	}
 
-	// Process the log output to get it in the desired format.
-	history := processGitHistoryOutput(strings.TrimSpace(out.String()))
-
-	first := history[0]
+	commit, err := getHeadCommit(repo)
+	if err != nil {
+		return "", "", fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
+	}
 
-	sha = first[0]
-	body = first[1]
+	sha = shortSha(commit.Hash)
+	body = formatGitHistoryEntry(sha, commit)
 
 	return sha, body, nil
 }
 
 func getGitCommitHistory(dir string) (body string, shas []string, err error) {
-	var out bytes.Buffer
-	cmd := exec.Command("git", "log", "--pretty=%h@@|@@%at@@|@@%B@>>>@")
-	cmd.Dir = dir
-	cmd.Stdout = &out
-	err = cmd.Run()
+	repo, err := openGitRepo(dir)
 	if err != nil {
-		return "", nil, fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
+		return "", nil, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting git head for dir: %s, err: %v", dir, err)
 	}
 
-	// Process the log output to get it in the desired format.
-	history := processGitHistoryOutput(strings.TrimSpace(out.String()))
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
+	}
 
 	var output []string
-	for _, el := range history {
-		shas = append(shas, el[0])
-		output = append(output, el[1])
+	err = iter.ForEach(func(commit *object.Commit) error {
+		sha := shortSha(commit.Hash)
+		shas = append(shas, sha)
+		output = append(output, formatGitHistoryEntry(sha, commit))
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting git history for dir: %s, err: %v", dir, err)
 	}
 
 	return strings.Join(output, "\n\n"), shas, nil
 }
 
-// processGitHistoryOutput processes the raw output from the git log command and returns a formatted string.
-func processGitHistoryOutput(raw string) [][2]string {
-	var history [][2]string
-	entries := strings.Split(raw, "@>>>@") // Split entries using the custom separator.
+// formatGitHistoryEntry formats a commit for the 'log' command: a colored header with the sha and timestamp, followed by the message
+func formatGitHistoryEntry(sha string, commit *object.Commit) string {
+	message := strings.TrimSpace(commit.Message)
 
-	for _, entry := range entries {
-		// First clean up any leading/trailing whitespace or newlines from each entry.
-		entry = strings.TrimSpace(entry)
+	formattedTs := commit.Author.When.UTC().Format("Mon Jan 2, 2006 | 3:04:05pm MST")
 
-		// Now split the cleaned entry into its parts.
-		parts := strings.Split(entry, "@@|@@")
-		if len(parts) == 3 {
-			sha := parts[0]
-			timestampStr := parts[1]
-			message := strings.TrimSpace(parts[2]) // Trim whitespace from message as well.
+	// Prepare the header with colors.
+	headerColor := color.New(color.FgCyan, color.Bold)
+	dateColor := color.New(color.FgCyan)
 
-			// Extract and format timestamp.
-			timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
-			if err != nil {
-				continue // Skip entries with invalid timestamps.
-			}
+	// Combine sha, formatted timestamp, and message header into one string.
+	header := fmt.Sprintf("%s | %s", headerColor.Sprintf("📝 Update %s", sha), dateColor.Sprintf("%s", formattedTs))
 
-			dt := time.Unix(timestamp, 0).UTC()
-			formattedTs := dt.Format("Mon Jan 2, 2006 | 3:04:05pm MST")
+	// Combine header and message with a newline only if the message is not empty.
+	fullEntry := header
+	if message != "" {
+		fullEntry += "\n" + message
+	}
 
-			// Prepare the header with colors.
-			headerColor := color.New(color.FgCyan, color.Bold)
-			dateColor := color.New(color.FgCyan)
+	return fullEntry
+}
 
-			// Combine sha, formatted timestamp, and message header into one string.
-			header := fmt.Sprintf("%s | %s", headerColor.Sprintf("📝 Update %s", sha), dateColor.Sprintf("%s", formattedTs))
+// gitAdd stages every change in the worktree, like 'git add --all'. As in git, files whose size and mtime match their index entry are taken as unchanged, so only files that were written since the last add get hashed. go-git's own Add hashes the whole worktree on every call, which gets slow as a plan's conversation grows.
+func gitAdd(repo *git.Repository, repoDir string) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("error reading git index: %v", err)
+	}
+
+	// entries modified at or after the index was written are 'racily clean'--they could have changed again within the same mtime, so they're rehashed
+	var indexModTime time.Time
+	info, err := os.Stat(filepath.Join(repoDir, git.GitDirName, "index"))
+	if err == nil {
+		indexModTime = info.ModTime()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error checking git index: %v", err)
+	}
+
+	entries := map[string]*index.Entry{}
+	for _, entry := range idx.Entries {
+		entries[entry.Name] = entry
+	}
+
+	seen := map[string]bool{}
+	changed := false
 
-			// Combine header and message with a newline only if the message is not empty.
-			fullEntry := header
-			if message != "" {
-				fullEntry += "\n" + message
+	err = filepath.WalkDir(repoDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if d.Name() == git.GitDirName {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			history = append(history, [2]string{sha, fullEntry})
+		if !d.Type().IsRegular() {
+			return nil
 		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		seen[name] = true
+
+		mode := filemode.Regular
+		if info.Mode()&0111 != 0 {
+			mode = filemode.Executable
+		}
+
+		entry := entries[name]
+		if entry != nil &&
+			entry.Mode == mode &&
+			entry.Size == uint32(info.Size()) &&
+			entry.ModifiedAt.Equal(info.ModTime()) &&
+			entry.ModifiedAt.Before(indexModTime) {
+			return nil
+		}
+
+		hash, err := writeGitBlob(repo, path)
+		if err != nil {
+			return err
+		}
+
+		if entry == nil {
+			entry = idx.Add(name)
+		}
+		entry.Hash = hash
+		entry.Mode = mode
+		entry.ModifiedAt = info.ModTime()
+		entry.Size = uint32(info.Size())
+		changed = true
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking worktree: %v", err)
 	}
 
-	return history
-}
+	kept := idx.Entries[:0]
+	for _, entry := range idx.Entries {
+		if seen[entry.Name] {
+			kept = append(kept, entry)
+		} else {
+			changed = true
+		}
+	}
+	idx.Entries = kept
+
+	if !changed {
+		return nil
+	}
+
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].Name < idx.Entries[j].Name
+	})
 
-func gitAdd(repoDir, path string) error {
-	res, err := exec.Command("git", "-C", repoDir, "add", path).CombinedOutput()
+	err = repo.Storer.SetIndex(idx)
 	if err != nil {
-		return fmt.Errorf("error adding files to git repository for dir: %s, err: %v, output: %s", repoDir, err, string(res))
+		return fmt.Errorf("error writing git index: %v", err)
 	}
 
 	return nil
 }
 
-func gitCommit(repoDir, commitMsg string) error {
-	res, err := exec.Command("git", "-C", repoDir, "commit", "-m", commitMsg).CombinedOutput()
+func writeGitBlob(repo *git.Repository, path string) (plumbing.Hash, error) {
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("error committing files to git repository for dir: %s, err: %v, output: %s", repoDir, err, string(res))
+		return plumbing.ZeroHash, err
 	}
 
-	return nil
-}
+	hash := plumbing.ComputeHash(plumbing.BlobObject, content)
+	if repo.Storer.HasEncodedObject(hash) == nil {
+		return hash, nil
+	}
 
-func gitRemoveIndexLockFileIfExists(repoDir string) error {
-	// Remove the lock file if it exists
-	lockFilePath := filepath.Join(repoDir, ".git", "index.lock")
-	_, err := os.Stat(lockFilePath)
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
 
-	// log.Println("lockFilePath:", lockFilePath)
-	// log.Println("exists:", err == nil)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	_, err = w.Write(content)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	err = w.Close()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
 
-	if err == nil {
-		if err := os.Remove(lockFilePath); err != nil {
-			if os.IsNotExist(err) {
-				return nil
-			}
+	return repo.Storer.SetEncodedObject(obj)
+}
 
-			return fmt.Errorf("error removing lock file: %v", err)
+// gitCommit commits the index. Like 'git commit', it fails rather than adding a commit that changes nothing.
+func gitCommit(repo *git.Repository, commitMsg string) error {
+	var parent *object.Commit
+	head, err := repo.Head()
+	if err == nil {
+		parent, err = repo.CommitObject(head.Hash())
+		if err != nil {
+			return fmt.Errorf("error getting head commit: %v", err)
 		}
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("error checking lock file: %v", err)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return fmt.Errorf("error getting git head: %v", err)
 	}
 
-	return nil
-}
+	commitMsg = cleanupCommitMsg(commitMsg)
+	if commitMsg == "" {
+		return fmt.Errorf("empty commit message")
+	}
 
-func setGitConfig(repoDir, key, value string) error {
-	res, err := exec.Command("git", "-C", repoDir, "config", key, value).CombinedOutput()
+	wt, err := repo.Worktree()
 	if err != nil {
-		return fmt.Errorf("error setting git config %s to %s for dir: %s, err: %v, output: %s", key, value, repoDir, err, string(res))
+		return fmt.Errorf("error getting git worktree: %v", err)
+	}
+
+	signature := &object.Signature{
+		Name:  gitUserName,
+		Email: gitUserEmail,
+		When:  time.Now(),
+	}
+
+	hash, err := wt.Commit(commitMsg, &git.CommitOptions{
+		Author:    signature,
+		Committer: signature,
+	})
+	if err == git.ErrEmptyCommit {
+		return fmt.Errorf("nothing to commit")
+	} else if err != nil {
+		return fmt.Errorf("error committing: %v", err)
+	}
+
+	if parent != nil {
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return fmt.Errorf("error getting new commit: %v", err)
+		}
+
+		if commit.TreeHash == parent.TreeHash {
+			// go-git commits an unchanged tree, so move the branch back to the parent. The orphaned commit is never referenced.
+			err = repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), parent.Hash))
+			if err != nil {
+				return fmt.Errorf("error restoring head: %v", err)
+			}
+			return fmt.Errorf("nothing to commit, working tree clean")
+		}
 	}
+
 	return nil
 }
 
-func retryGitWriteOperationIfIndexFileErr(operation func() error) error {
-	var err error
-	retryInterval := initialGitRetryInterval
-
-	for attempt := 0; attempt < maxGitRetries; attempt++ {
-		err = operation()
-		if err == nil {
-			return nil
+// cleanupCommitMsg tidies a message the way 'git commit -m' does: trailing whitespace is stripped from each line, runs of blank lines are collapsed, and leading and trailing blank lines are removed
+func cleanupCommitMsg(msg string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
 		}
+		lines = append(lines, line)
+	}
 
-		log.Printf("Retry attempt %d failed. Error: %v", attempt+1, err)
+	if len(lines) == 0 {
+		return ""
+	}
 
-		if exitError, ok := err.(*exec.ExitError); ok {
-			errorOutput := string(exitError.Stderr)
-			log.Printf("git operation error output: %s", errorOutput)
+	return strings.Join(lines, "\n") + "\n"
+}
 
-			if exitError.ExitCode() == 128 && (strings.Contains(string(exitError.Stderr), "new_index file") ||
-				strings.Contains(string(exitError.Stderr), "index.lock')")) {
+func openGitRepo(dir string) (*git.Repository, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening git repository for dir: %s, err: %v", dir, err)
+	}
+	return repo, nil
+}
 
-				log.Printf("Retry attempt %d failed due to 'unable to write new_index file'. Waiting %v before retrying. Error: %v", attempt+1, retryInterval, err)
-				time.Sleep(retryInterval)
-				retryInterval *= 2
-				continue
-			} else {
-				log.Printf("Non-index file error: %v", err)
-			}
-		} else {
-			log.Printf("Non-exit error: %v", err)
-		}
+func getHeadCommit(repo *git.Repository) (*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("error getting git head: %v", err)
+	}
 
-		return err
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("error getting head commit: %v", err)
 	}
 
-	return fmt.Errorf("operation failed after %d attempts: %v", maxGitRetries, err)
+	return commit, nil
+}
+
+func shortSha(hash plumbing.Hash) string {
+	return hash.String()[:7]
 }
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGitPlan points BaseDir at a temp dir and initializes a plan repo in it
+func testGitPlan(tb testing.TB) (orgId, planId, dir string) {
+	prevBaseDir := BaseDir
+	BaseDir = tb.TempDir()
+	tb.Cleanup(func() {
+		BaseDir = prevBaseDir
+	})
+
+	orgId, planId = "org", "plan"
+	dir = getPlanDir(orgId, planId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := InitGitRepo(orgId, planId); err != nil {
+		tb.Fatal(err)
+	}
+
+	return orgId, planId, dir
+}
+
+func writeTestFile(tb testing.TB, dir, path, content string) {
+	full := filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestGitRepoOperations(t *testing.T) {
+	orgId, planId, dir := testGitPlan(t)
+
+	writeTestFile(t, dir, "settings.json", "{}")
+	writeTestFile(t, dir, "conversation/1.json", `{"message": 1}`)
+	if err := GitAddAndCommit(orgId, planId, "main", "  first\n\n\ncommit  \n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing changed
+	if err := GitAddAndCommit(orgId, planId, "main", "empty"); err == nil {
+		t.Fatal("expected an error committing an unchanged tree")
+	}
+
+	writeTestFile(t, dir, "conversation/1.json", `{"message": "one"}`)
+	writeTestFile(t, dir, "conversation/2.json", `{"message": 2}`)
+	if err := os.Remove(filepath.Join(dir, "settings.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := GitAddAndCommit(orgId, planId, "main", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, shas, err := GetGitCommitHistory(orgId, planId, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shas) != 2 {
+		t.Fatalf("expected 2 commits, got %d", len(shas))
+	}
+	if !strings.Contains(body, "second") || !strings.Contains(body, "first\n\ncommit") {
+		t.Fatalf("unexpected history:\n%s", body)
+	}
+
+	sha, _, err := GetLatestCommit(orgId, planId, "main")
+	if err != nil || sha != shas[0] {
+		t.Fatalf("expected latest commit %s, got %s (%v)", shas[0], sha, err)
+	}
+
+	msg, parentSha, err := GetLatestCommitMsgAndParentSha(orgId, planId)
+	if err != nil || msg != "second" || parentSha != shas[1] {
+		t.Fatalf("unexpected latest commit msg %q, parent %s (%v)", msg, parentSha, err)
+	}
+
+	// the repo should look the same to the git binary
+	if _, err := exec.LookPath("git"); err == nil {
+		out, err := exec.Command("git", "-C", dir, "status", "--porcelain").CombinedOutput()
+		if err != nil || len(out) != 0 {
+			t.Fatalf("expected a clean worktree according to git, got %q (%v)", out, err)
+		}
+		out, err = exec.Command("git", "-C", dir, "log", "--format=%h %an <%ae>").CombinedOutput()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := shas[0] + " Plandex <server@plandex.ai>\n" + shas[1] + " Plandex <server@plandex.ai>\n"
+		if string(out) != want {
+			t.Fatalf("expected git log %q, got %q", want, out)
+		}
+	}
+
+	writeTestFile(t, dir, "conversation/2.json", `{"message": "uncommitted"}`)
+	writeTestFile(t, dir, "untracked/3.json", `{}`)
+	if err := GitClearUncommittedChanges(orgId, planId); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "conversation/2.json")); err != nil || string(b) != `{"message": 2}` {
+		t.Fatalf("expected uncommitted change to be reset, got %q (%v)", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "untracked")); !os.IsNotExist(err) {
+		t.Fatalf("expected untracked dir to be removed: %v", err)
+	}
+
+	if err := GitRewindToSha(orgId, planId, "main", shas[1]); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "settings.json")); err != nil || string(b) != "{}" {
+		t.Fatalf("expected settings.json to be restored, got %q (%v)", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "conversation/2.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected conversation/2.json to be removed: %v", err)
+	}
+	if _, shas, _ = GetGitCommitHistory(orgId, planId, "main"); len(shas) != 1 {
+		t.Fatalf("expected 1 commit after rewind, got %d", len(shas))
+	}
+
+	// staging still sees files restored by the reset as unchanged
+	writeTestFile(t, dir, "conversation/1.json", `{"message": "rewritten"}`)
+	if err := GitAddAndCommit(orgId, planId, "main", "third"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGitBranches(t *testing.T) {
+	orgId, planId, dir := testGitPlan(t)
+
+	branches, err := GitListBranches(orgId, planId)
+	if err != nil || len(branches) != 1 || branches[0] != "main" {
+		t.Fatalf("expected just main before the first commit, got %v (%v)", branches, err)
+	}
+
+	writeTestFile(t, dir, "settings.json", "{}")
+	if err := GitAddAndCommit(orgId, planId, "main", "initial"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := GitCreateBranch(orgId, planId, "main", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := GitCreateBranch(orgId, planId, "main", "feature"); err == nil {
+		t.Fatal("expected an error creating an existing branch")
+	}
+
+	writeTestFile(t, dir, "feature.json", "{}")
+	if err := GitAddAndCommit(orgId, planId, "feature", "on feature"); err != nil {
+		t.Fatal(err)
+	}
+
+	branches, err = GitListBranches(orgId, planId)
+	if err != nil || strings.Join(branches, ",") != "feature,main" {
+		t.Fatalf("expected feature and main, got %v (%v)", branches, err)
+	}
+
+	if err := GitDeleteBranch(orgId, planId, "feature"); err == nil {
+		t.Fatal("expected an error deleting the checked out branch")
+	}
+
+	if err := gitCheckoutBranch(dir, "main"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "feature.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected feature.json to be removed on main: %v", err)
+	}
+
+	if err := GitDeleteBranch(orgId, planId, "feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := GitDeleteBranch(orgId, planId, "feature"); err == nil {
+		t.Fatal("expected an error deleting a missing branch")
+	}
+}
+
+func TestCleanupCommitMsg(t *testing.T) {
+	tests := map[string]string{
+		"msg":                                "msg\n",
+		"\n\n  subject  \n\n\n\nbody \t\n\n": "  subject\n\nbody\n",
+		"  \n \n":                            "",
+		"a\n  indented\n":                    "a\n  indented\n",
+	}
+
+	for msg, want := range tests {
+		if got := cleanupCommitMsg(msg); got != want {
+			t.Errorf("cleanupCommitMsg(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+// BenchmarkGitAddAndCommit measures a plan write--one updated file and one new conversation message--on plans with long histories
+func BenchmarkGitAddAndCommit(b *testing.B) {
+	for _, numCommits := range []int{10, 1000} {
+		b.Run(fmt.Sprintf("commits=%d", numCommits), func(b *testing.B) {
+			orgId, planId, dir := testGitPlan(b)
+			seedGitHistory(b, dir, numCommits, func() error {
+				return GitAddAndCommit(orgId, planId, "main", "seed")
+			})
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				writeTestFile(b, dir, "results/current.json", fmt.Sprintf(`{"iteration": %d}`, i))
+				writeTestFile(b, dir, fmt.Sprintf("conversation/bench-%d.json", i), `{"message": "bench"}`)
+				if err := GitAddAndCommit(orgId, planId, "main", "bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGitAddAndCommitCli is the same write through the git binary, as plan writes used to work
+func BenchmarkGitAddAndCommitCli(b *testing.B) {
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git not installed")
+	}
+
+	commit := func(dir, msg string) error {
+		out, err := exec.Command("git", "-C", dir, "add", ".").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		out, err = exec.Command("git", "-C", dir, "commit", "-m", msg).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return nil
+	}
+
+	for _, numCommits := range []int{10, 1000} {
+		b.Run(fmt.Sprintf("commits=%d", numCommits), func(b *testing.B) {
+			_, _, dir := testGitPlan(b)
+			seedGitHistory(b, dir, numCommits, func() error {
+				return commit(dir, "seed")
+			})
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				writeTestFile(b, dir, "results/current.json", fmt.Sprintf(`{"iteration": %d}`, i))
+				writeTestFile(b, dir, fmt.Sprintf("conversation/bench-%d.json", i), `{"message": "bench"}`)
+				if err := commit(dir, "bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// seedGitHistory builds up a plan repo the way a long conversation does: each commit adds a message and rewrites the current results
+func seedGitHistory(b *testing.B, dir string, numCommits int, commit func() error) {
+	for i := 0; i < numCommits; i++ {
+		writeTestFile(b, dir, fmt.Sprintf("conversation/%d.json", i), fmt.Sprintf(`{"message": %q}`, strings.Repeat("content ", 50)))
+		writeTestFile(b, dir, "results/current.json", fmt.Sprintf(`{"seed": %d}`, i))
+		if err := commit(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
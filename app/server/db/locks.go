@@ -2,13 +2,15 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"log"
 	"math/rand"
+	"plandex-server/metrics"
+	"plandex-server/tracing"
 	"time"
 
-	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const lockHeartbeatInterval = 700 * time.Millisecond
@@ -32,7 +34,24 @@ type LockRepoParams struct {
 }
 
 func LockRepo(params LockRepoParams) (string, error) {
-	return lockRepo(params, 0)
+	start := time.Now()
+
+	// the span's context still finishes with params.Ctx, so it can stand in for it
+	ctx, span := tracing.Start(params.Ctx, "repo.lock",
+		append(tracing.PlanAttrs(params.PlanId, params.Branch), attribute.String("plandex.lock.scope", string(params.Scope)))...,
+	)
+	params.Ctx = ctx
+
+	id, err := lockRepo(params, 0)
+	tracing.End(span, err)
+
+	result := "acquired"
+	if err != nil {
+		result = "failed"
+	}
+	metrics.RepoLockWait.WithLabelValues(string(params.Scope), result).Observe(time.Since(start).Seconds())
+
+	return id, err
 }
 
 func lockRepo(params LockRepoParams, numRetry int) (string, error) {
@@ -48,23 +67,36 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 	ctx := params.Ctx
 	cancelFn := params.CancelFn
 
-	tx, err := Conn.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	unlockPlan := store.LockPlan(planId)
+
+	tx, err := Conn.BeginTxx(ctx, store.LockTxOptions())
 	if err != nil {
+		unlockPlan()
 		return "", fmt.Errorf("error starting transaction: %v", err)
 	}
 
-	// Ensure that rollback is attempted in case of failure
-	defer func() {
-		if err != nil {
+	// Ensure that rollback is attempted unless the lock was committed. This also runs before any retry so that other lockers aren't stuck behind this transaction while we wait.
+	committed := false
+	released := false
+	release := func() {
+		if released {
+			return
+		}
+		released = true
+
+		if !committed {
 			if rbErr := tx.Rollback(); rbErr != nil {
 				log.Printf("transaction rollback error: %v\n", rbErr)
 			} else {
 				log.Println("transaction rolled back")
 			}
 		}
-	}()
 
-	query := "SELECT id, org_id, user_id, plan_id, plan_build_id, scope, branch, created_at FROM repo_locks WHERE plan_id = $1 FOR UPDATE"
+		unlockPlan()
+	}
+	defer release()
+
+	query := "SELECT id, org_id, user_id, plan_id, plan_build_id, scope, branch, last_heartbeat_at, created_at FROM repo_locks WHERE plan_id = $1" + store.ForUpdate()
 	queryArgs := []interface{}{planId}
 
 	var locks []*repoLock
@@ -73,7 +105,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 		log.Println("obtaining repo lock with query")
 		rows, err := tx.Query(query, queryArgs...)
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "40001" || pqErr.Code == "40P01") {
+			if store.IsRetryableTxErr(err) {
 				// return concurrency errors directly for retries
 				return err
 			}
@@ -89,7 +121,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 		now := time.Now()
 		for rows.Next() {
 			var lock repoLock
-			if err := rows.Scan(&lock.Id, &lock.OrgId, &lock.UserId, &lock.PlanId, &lock.PlanBuildId, &lock.Scope, &lock.Branch, &lock.CreatedAt); err != nil {
+			if err := rows.Scan(&lock.Id, &lock.OrgId, &lock.UserId, &lock.PlanId, &lock.PlanBuildId, &lock.Scope, &lock.Branch, &lock.LastHeartbeatAt, &lock.CreatedAt); err != nil {
 				return fmt.Errorf("error scanning repo lock: %v", err)
 			}
 
@@ -102,8 +134,11 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 		}
 
 		if len(expiredLockIds) > 0 {
-			query := "DELETE FROM repo_locks WHERE id = ANY($1)"
-			_, err := tx.Exec(query, pq.Array(expiredLockIds))
+			query, args, err := inQuery("DELETE FROM repo_locks WHERE id IN (?)", expiredLockIds)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(query, args...)
 			if err != nil {
 				return fmt.Errorf("error removing expired locks: %v", err)
 			}
@@ -113,7 +148,8 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 	}
 	err = fn()
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "40001" || pqErr.Code == "40P01") {
+		if store.IsRetryableTxErr(err) {
+			release()
 			if numRetry > maxRetries {
 				err = fmt.Errorf("plan is currently being updated by another user")
 				log.Println("max retries reached on serialization error, returning error")
@@ -128,6 +164,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 			case <-ctx.Done():
 				return "", fmt.Errorf("context finished during retry transaction")
 			case <-time.After(wait):
+				recordLockRetry(ctx, scope, "conflict", numRetry)
 				return lockRepo(params, numRetry+1)
 			}
 		}
@@ -172,6 +209,8 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 	if !canAcquire {
 		log.Println("can't acquire lock. canRetry:", canRetry, "numRetry:", numRetry)
 
+		release()
+
 		if canRetry {
 			// 10 second timeout
 			if numRetry > 20 {
@@ -179,6 +218,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 				return "", err
 			}
 			time.Sleep(500 * time.Millisecond)
+			recordLockRetry(ctx, scope, "held", numRetry)
 			return lockRepo(params, numRetry+1)
 		}
 		err = fmt.Errorf("plan is currently being updated by another user")
@@ -223,11 +263,10 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 		return "", fmt.Errorf("error inserting new lock: %v", err)
 	}
 
-	// check if git lock file exists
-	// remove it if so
-	err = gitRemoveIndexLockFileIfExists(getPlanDir(orgId, planId))
+	// pick up writes made on other servers before the plan is read
+	err = hydratePlanDir(getPlanDir(orgId, planId))
 	if err != nil {
-		return "", fmt.Errorf("error removing lock file: %v", err)
+		return "", err
 	}
 
 	branches, err := GitListBranches(orgId, planId)
@@ -249,6 +288,7 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 	if err = tx.Commit(); err != nil {
 		return "", fmt.Errorf("error committing transaction: %v", err)
 	}
+	committed = true
 
 	// Start a goroutine to keep the lock alive
 	go func() {
@@ -275,6 +315,9 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 						cancelFn()
 						return
 					}
+
+					time.Sleep(lockHeartbeatInterval)
+					continue
 				}
 
 				// check if 0 rows were updated
@@ -301,6 +344,44 @@ func lockRepo(params LockRepoParams, numRetry int) (string, error) {
 	return newLock.Id, nil
 }
 
+// WaitForRepoUnlocked waits up to timeout for every live lock on the plan's branch to be released, for when the lock holders have just been cancelled and their heartbeat loops are about to delete their locks
+func WaitForRepoUnlocked(planId, branch string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var heartbeats []time.Time
+		err := Conn.Select(&heartbeats, "SELECT last_heartbeat_at FROM repo_locks WHERE plan_id = $1 AND branch = $2", planId, branch)
+		if err != nil {
+			return fmt.Errorf("error getting repo locks: %v", err)
+		}
+
+		numLive := 0
+		for _, heartbeat := range heartbeats {
+			if time.Since(heartbeat) < lockHeartbeatTimeout {
+				numLive++
+			}
+		}
+
+		if numLive == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("plan still has %d repo locks after %s", numLive, timeout)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func recordLockRetry(ctx context.Context, scope LockScope, reason string, numRetry int) {
+	trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+		attribute.String("plandex.lock.retry_reason", reason),
+		attribute.Int("plandex.lock.retry", numRetry+1),
+	))
+	metrics.RepoLockRetries.WithLabelValues(string(scope), reason).Inc()
+}
+
 func DeleteRepoLock(id string) error {
 	log.Println("deleting repo lock:", id)
 
@@ -314,3 +395,37 @@ func DeleteRepoLock(id string) error {
 
 	return nil
 }
+
+// DeleteExpiredRepoLocks removes locks whose holders stopped heartbeating. Lockers already skip and remove expired locks on the plans they lock, so this just keeps locks left by crashed servers from piling up.
+func DeleteExpiredRepoLocks() error {
+	var locks []*repoLock
+	err := Conn.Select(&locks, "SELECT id, last_heartbeat_at FROM repo_locks")
+	if err != nil {
+		return fmt.Errorf("error getting repo locks: %v", err)
+	}
+
+	var expiredLockIds []string
+	for _, lock := range locks {
+		if time.Since(lock.LastHeartbeatAt) >= lockHeartbeatTimeout {
+			expiredLockIds = append(expiredLockIds, lock.Id)
+		}
+	}
+
+	if len(expiredLockIds) == 0 {
+		return nil
+	}
+
+	query, args, err := inQuery("DELETE FROM repo_locks WHERE id IN (?)", expiredLockIds)
+	if err != nil {
+		return err
+	}
+
+	_, err = Conn.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("error removing expired locks: %v", err)
+	}
+
+	log.Printf("Removed %d expired repo locks\n", len(expiredLockIds))
+
+	return nil
+}
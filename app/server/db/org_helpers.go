@@ -7,7 +7,6 @@ import (
 	"strings"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 	"github.com/plandex/plandex/shared"
 )
 
@@ -28,7 +27,12 @@ func GetAccessibleOrgsForUser(user *User) ([]*Org, error) {
 	}
 
 	if len(orgIds) > 0 {
-		err = Conn.Select(&orgs, "SELECT * FROM orgs WHERE id = ANY($1)", pq.Array(orgIds))
+		query, args, err := inQuery("SELECT * FROM orgs WHERE id IN (?)", orgIds)
+		if err != nil {
+			return nil, err
+		}
+
+		err = Conn.Select(&orgs, query, args...)
 
 		if err != nil {
 			return nil, fmt.Errorf("error getting orgs for user: %v", err)
@@ -54,7 +58,12 @@ func GetAccessibleOrgsForUser(user *User) ([]*Org, error) {
 
 	if len(orgIds) > 0 {
 		var orgsFromInvites []*Org
-		err = Conn.Select(&orgsFromInvites, "SELECT * FROM orgs WHERE id = ANY($1)", pq.Array(orgIds))
+		query, args, err := inQuery("SELECT * FROM orgs WHERE id IN (?)", orgIds)
+		if err != nil {
+			return nil, err
+		}
+
+		err = Conn.Select(&orgsFromInvites, query, args...)
 		if err != nil {
 			return nil, fmt.Errorf("error getting orgs from invites: %v", err)
 		}
@@ -164,7 +173,7 @@ func AddOrgDomainUsers(orgId, domain string, tx *sqlx.Tx) error {
 		}
 
 		// Join all value strings and execute a single query
-		stmt := fmt.Sprintf("INSERT INTO orgs_users (org_id, user_id, org_role_id) VALUES %s ON CONFLICT ON CONSTRAINT org_user_unique DO NOTHING", strings.Join(valueStrings, ","))
+		stmt := fmt.Sprintf("INSERT INTO orgs_users (org_id, user_id, org_role_id) VALUES %s ON CONFLICT (org_id, user_id) DO NOTHING", strings.Join(valueStrings, ","))
 		_, err = tx.Exec(stmt, valueArgs...)
 
 		if err != nil {
@@ -175,6 +184,22 @@ func AddOrgDomainUsers(orgId, domain string, tx *sqlx.Tx) error {
 	return nil
 }
 
+// AddOrgDomainUser adds a single user who signed in with SSO to their domain's org. With updateRole, an existing member's role is changed to orgRoleId, which keeps roles in sync with identity provider groups.
+func AddOrgDomainUser(orgId, userId, orgRoleId string, updateRole bool, tx *sqlx.Tx) error {
+	onConflict := "DO NOTHING"
+	if updateRole {
+		onConflict = "DO UPDATE SET org_role_id = EXCLUDED.org_role_id"
+	}
+
+	_, err := tx.Exec("INSERT INTO orgs_users (org_id, user_id, org_role_id) VALUES ($1, $2, $3) ON CONFLICT (org_id, user_id) "+onConflict, orgId, userId, orgRoleId)
+
+	if err != nil {
+		return fmt.Errorf("error adding org domain user: %v", err)
+	}
+
+	return nil
+}
+
 func DeleteOrgUser(orgId, userId string, tx *sqlx.Tx) error {
 	log.Printf("Deleting org user, org: %s | user: %s\n", orgId, userId)
 
@@ -203,6 +228,25 @@ func CreateOrgUser(orgId, userId, orgRoleId string, tx *sqlx.Tx) error {
 	return nil
 }
 
+// GetOrgRoleIdByName returns the id of the org's role with this name, preferring the org's own roles to the built-in ones, or an empty string if there's no such role
+func GetOrgRoleIdByName(orgId, name string) (string, error) {
+	var orgRoles []*OrgRole
+	err := Conn.Select(&orgRoles, "SELECT * FROM org_roles WHERE name = $1 AND (org_id IS NULL OR org_id = $2)", name, orgId)
+
+	if err != nil {
+		return "", fmt.Errorf("error getting org role: %v", err)
+	}
+
+	var roleId string
+	for _, orgRole := range orgRoles {
+		if orgRole.OrgId != nil || roleId == "" {
+			roleId = orgRole.Id
+		}
+	}
+
+	return roleId, nil
+}
+
 func ListOrgRoles(orgId string) ([]*OrgRole, error) {
 	var orgRoles []*OrgRole
 	err := Conn.Select(&orgRoles, "SELECT * FROM org_roles WHERE org_id IS NULL OR org_id = $1", orgId)
@@ -10,7 +10,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
 )
@@ -85,8 +84,13 @@ func CreatePlan(orgId, projectId, userId, name string) (*Plan, error) {
 }
 
 func ListOwnedPlans(projectIds []string, userId string, archived bool) ([]*Plan, error) {
-	qs := "SELECT * FROM plans WHERE project_id = ANY($1) AND owner_id = $2"
-	qargs := []interface{}{pq.Array(projectIds), userId}
+	var plans []*Plan
+
+	if len(projectIds) == 0 {
+		return plans, nil
+	}
+
+	qs := "SELECT * FROM plans WHERE project_id IN (?) AND owner_id = ?"
 
 	if archived {
 		qs += " AND archived_at IS NOT NULL"
@@ -96,8 +100,12 @@ func ListOwnedPlans(projectIds []string, userId string, archived bool) ([]*Plan,
 
 	qs += " ORDER BY updated_at DESC"
 
-	var plans []*Plan
-	err := Conn.Select(&plans, qs, qargs...)
+	qs, qargs, err := inQuery(qs, projectIds, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&plans, qs, qargs...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error listing plans: %v", err)
@@ -106,6 +114,58 @@ func ListOwnedPlans(projectIds []string, userId string, archived bool) ([]*Plan,
 	return plans, nil
 }
 
+// ListSharedPlans lists active plans in the projects that other org members have shared with the org
+func ListSharedPlans(orgId string, projectIds []string, userId string) ([]*Plan, error) {
+	var plans []*Plan
+
+	if len(projectIds) == 0 {
+		return plans, nil
+	}
+
+	qs, qargs, err := inQuery("SELECT * FROM plans WHERE org_id = ? AND project_id IN (?) AND owner_id != ? AND shared_with_org_at IS NOT NULL AND archived_at IS NULL ORDER BY updated_at DESC", orgId, projectIds, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&plans, qs, qargs...)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing shared plans: %v", err)
+	}
+
+	return plans, nil
+}
+
+// ListSharedBranchNames returns the names of each plan's shared branches, keyed by plan id
+func ListSharedBranchNames(planIds []string) (map[string][]string, error) {
+	res := map[string][]string{}
+
+	if len(planIds) == 0 {
+		return res, nil
+	}
+
+	qs, qargs, err := inQuery("SELECT plan_id, name FROM branches WHERE plan_id IN (?) AND shared_with_org_at IS NOT NULL ORDER BY created_at", planIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []struct {
+		PlanId string `db:"plan_id"`
+		Name   string `db:"name"`
+	}
+	err = Conn.Select(&branches, qs, qargs...)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing shared branches: %v", err)
+	}
+
+	for _, branch := range branches {
+		res[branch.PlanId] = append(res[branch.PlanId], branch.Name)
+	}
+
+	return res, nil
+}
+
 func AddPlanContextTokens(planId, branch string, addTokens int) error {
 	_, err := Conn.Exec("UPDATE branches SET context_tokens = context_tokens + $1 WHERE plan_id = $2 AND name = $3", addTokens, planId, branch)
 	if err != nil {
@@ -207,12 +267,31 @@ func GetPlan(planId string) (*Plan, error) {
 }
 
 func SetPlanStatus(planId, branch string, status shared.PlanStatus, errStr string) error {
+	return setPlanStatus(planId, branch, status, errStr, "")
+}
+
+// SetPlanStatusMissingFile pauses the plan to ask the user what to do about a file it's updating that isn't in context
+func SetPlanStatusMissingFile(planId, branch, path string) error {
+	return setPlanStatus(planId, branch, shared.PlanStatusMissingFile, "", path)
+}
+
+// setPlanStatus also queues a webhook event for statuses that have one
+func setPlanStatus(planId, branch string, status shared.PlanStatus, errStr, missingFilePath string) error {
 	_, err := Conn.Exec("UPDATE branches SET status = $1, error = $2 WHERE plan_id = $3 AND name = $4", status, errStr, planId, branch)
 
 	if err != nil {
 		return fmt.Errorf("error setting plan status: %v", err)
 	}
 
+	if event, ok := shared.WebhookEventForPlanStatus(status); ok {
+		QueuePlanWebhookEvent(planId, branch, shared.WebhookPayload{
+			Event:           event,
+			Status:          status,
+			Error:           errStr,
+			MissingFilePath: missingFilePath,
+		})
+	}
+
 	return nil
 }
 
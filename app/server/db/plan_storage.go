@@ -0,0 +1,162 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// PlanStorage is where plan repos are kept between requests. The server always works on a plan's dir under BaseDir. With remote storage, that dir is a local cache: it's hydrated from storage when the plan is locked and synced back after each git write, so any server can pick up any plan.
+type PlanStorage interface {
+	Name() string
+
+	// Hydrate brings a local plan dir up to date with storage
+	Hydrate(dir string) error
+	// Sync uploads a local plan dir's changes to storage
+	Sync(dir string) error
+	Delete(dir string) error
+}
+
+var planStorage PlanStorage = &fsPlanStorage{}
+
+func CurrentPlanStorage() PlanStorage {
+	return planStorage
+}
+
+// ConnectPlanStorage picks plan storage from PLAN_STORAGE_URL. Leave it unset to keep plans on the local filesystem, or use s3://bucket/prefix for S3. For S3-compatible services like MinIO, also set PLAN_STORAGE_ENDPOINT.
+func ConnectPlanStorage() error {
+	storageUrl := os.Getenv("PLAN_STORAGE_URL")
+	if storageUrl == "" {
+		planStorage = &fsPlanStorage{}
+		return nil
+	}
+
+	u, err := url.Parse(storageUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing PLAN_STORAGE_URL: %v", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		planStorage = &fsPlanStorage{}
+	case "s3":
+		planStorage, err = newS3PlanStorage(u.Host, u.Path, os.Getenv("PLAN_STORAGE_ENDPOINT"))
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported PLAN_STORAGE_URL scheme: %s", u.Scheme)
+	}
+
+	log.Printf("using %s plan storage\n", planStorage.Name())
+
+	return nil
+}
+
+// hydratePlanDir brings a plan's local dir up to date before it's used
+func hydratePlanDir(dir string) error {
+	unlock := lockGitDir(dir)
+	defer unlock()
+
+	err := planStorage.Hydrate(dir)
+	if err != nil {
+		return fmt.Errorf("error hydrating plan dir from %s storage: %v", planStorage.Name(), err)
+	}
+
+	return nil
+}
+
+// syncPlanDir uploads a plan dir after a git write. Callers hold the dir's git lock.
+func syncPlanDir(dir string) error {
+	err := planStorage.Sync(dir)
+	if err != nil {
+		return fmt.Errorf("error syncing plan dir to %s storage: %v", planStorage.Name(), err)
+	}
+
+	return nil
+}
+
+// fsPlanStorage keeps plans only on the local disk, so the plan dir is the storage and there's nothing to copy
+type fsPlanStorage struct{}
+
+func (s *fsPlanStorage) Name() string {
+	return "filesystem"
+}
+
+func (s *fsPlanStorage) Hydrate(dir string) error {
+	return nil
+}
+
+func (s *fsPlanStorage) Sync(dir string) error {
+	return nil
+}
+
+func (s *fsPlanStorage) Delete(dir string) error {
+	return nil
+}
+
+// A storage manifest records, for each file in a cached plan dir, the stored object's ETag and the local file's size and mtime as of the last hydrate or sync. Syncs use it to upload only files written since, and hydrates to download only objects another server changed. It's kept in the .git dir so cleaning the worktree doesn't remove it.
+
+const storageManifestPath = ".git/plandex-storage.json"
+
+type storageManifestEntry struct {
+	ETag    string `json:"etag"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+type storageManifest map[string]*storageManifestEntry
+
+func loadStorageManifest(dir string) (storageManifest, error) {
+	manifest := storageManifest{}
+
+	bytes, err := os.ReadFile(filepath.Join(dir, storageManifestPath))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading storage manifest: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, &manifest)
+	if err != nil {
+		// a corrupt manifest only costs a full transfer
+		log.Printf("error parsing storage manifest for dir: %s, err: %v\n", dir, err)
+		return storageManifest{}, nil
+	}
+
+	return manifest, nil
+}
+
+func (m storageManifest) save(dir string) error {
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error marshalling storage manifest: %v", err)
+	}
+
+	path := filepath.Join(dir, storageManifestPath)
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("error creating storage manifest dir: %v", err)
+	}
+
+	// write then rename so a crash can't leave a truncated manifest
+	err = os.WriteFile(path+".tmp", bytes, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing storage manifest: %v", err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("error writing storage manifest: %v", err)
+	}
+
+	return nil
+}
+
+// matches reports whether the local file is unchanged since the entry was recorded
+func (e *storageManifestEntry) matches(info os.FileInfo) bool {
+	return e != nil && e.Size == info.Size() && e.ModTime == info.ModTime().UnixNano()
+}
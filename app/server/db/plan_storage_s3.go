@@ -0,0 +1,371 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3PlanStorage keeps plan dirs in an S3 bucket, one object per file (including the .git dir). Keys mirror the layout under BaseDir: <prefix>/orgs/<orgId>/plans/<planId>/<path>.
+type s3PlanStorage struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+// transfers run in parallel since a commit touches a handful of small files and a first hydrate can be thousands
+const s3TransferConcurrency = 16
+
+// S3 accepts at most 1000 keys per DeleteObjects call
+const s3DeleteBatchSize = 1000
+
+func newS3PlanStorage(bucket, prefix, endpoint string) (*s3PlanStorage, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("PLAN_STORAGE_URL must include a bucket, like s3://bucket/prefix")
+	}
+
+	cfg := aws.NewConfig()
+	if endpoint != "" {
+		// S3-compatible services generally don't support virtual-hosted buckets
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS session: %v", err)
+	}
+
+	if aws.StringValue(sess.Config.Region) == "" {
+		// the region is only used for signing by most S3-compatible services
+		sess.Config.Region = aws.String("us-east-1")
+	}
+
+	return &s3PlanStorage{
+		client: s3.New(sess),
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (s *s3PlanStorage) Name() string {
+	return "s3"
+}
+
+func (s *s3PlanStorage) Hydrate(dir string) error {
+	keyPrefix, err := s.keyPrefix(dir)
+	if err != nil {
+		return err
+	}
+
+	remote := map[string]*s3.Object{}
+	err = s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(keyPrefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			remote[strings.TrimPrefix(aws.StringValue(obj.Key), keyPrefix)] = obj
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("error listing plan objects for dir: %s, err: %v", dir, err)
+	}
+
+	if len(remote) == 0 {
+		// not stored yet--either new or from before remote storage was set up. The local dir stands and is uploaded on the next sync.
+		return nil
+	}
+
+	manifest, err := loadStorageManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	var toDownload []string
+	for rel, obj := range remote {
+		entry := manifest[rel]
+		if entry != nil && entry.ETag == aws.StringValue(obj.ETag) {
+			info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel)))
+			if err == nil && entry.matches(info) {
+				continue
+			}
+		}
+		toDownload = append(toDownload, rel)
+	}
+
+	var mu sync.Mutex
+	err = runParallel(toDownload, func(rel string) error {
+		entry, err := s.download(keyPrefix+rel, filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		manifest[rel] = entry
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error downloading plan files for dir: %s, err: %v", dir, err)
+	}
+
+	// removed by another server since this cache was last hydrated
+	for rel := range manifest {
+		if remote[rel] != nil {
+			continue
+		}
+		err := os.Remove(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing deleted plan file: %v", err)
+		}
+		delete(manifest, rel)
+	}
+
+	return manifest.save(dir)
+}
+
+func (s *s3PlanStorage) Sync(dir string) error {
+	keyPrefix, err := s.keyPrefix(dir)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := loadStorageManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	var toUpload []string
+	infos := map[string]os.FileInfo{}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(relPath)
+		if strings.HasPrefix(rel, storageManifestPath) {
+			return nil
+		}
+		seen[rel] = true
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if manifest[rel].matches(info) {
+			return nil
+		}
+
+		toUpload = append(toUpload, rel)
+		infos[rel] = info
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking plan dir: %s, err: %v", dir, err)
+	}
+
+	var mu sync.Mutex
+	err = runParallel(toUpload, func(rel string) error {
+		etag, err := s.upload(keyPrefix+rel, filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		manifest[rel] = &storageManifestEntry{
+			ETag:    etag,
+			Size:    infos[rel].Size(),
+			ModTime: infos[rel].ModTime().UnixNano(),
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading plan files for dir: %s, err: %v", dir, err)
+	}
+
+	var deleted []string
+	for rel := range manifest {
+		if !seen[rel] {
+			deleted = append(deleted, keyPrefix+rel)
+			delete(manifest, rel)
+		}
+	}
+
+	err = s.deleteKeys(deleted)
+	if err != nil {
+		return fmt.Errorf("error deleting removed plan files for dir: %s, err: %v", dir, err)
+	}
+
+	return manifest.save(dir)
+}
+
+func (s *s3PlanStorage) Delete(dir string) error {
+	keyPrefix, err := s.keyPrefix(dir)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	err = s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(keyPrefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("error listing plan objects for dir: %s, err: %v", dir, err)
+	}
+
+	err = s.deleteKeys(keys)
+	if err != nil {
+		return fmt.Errorf("error deleting plan objects for dir: %s, err: %v", dir, err)
+	}
+
+	return nil
+}
+
+// keyPrefix maps a dir under BaseDir to the key prefix its files are stored under
+func (s *s3PlanStorage) keyPrefix(dir string) (string, error) {
+	rel, err := filepath.Rel(BaseDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("plan dir %s is outside the base dir", dir)
+	}
+
+	return path.Join(s.prefix, filepath.ToSlash(rel)) + "/", nil
+}
+
+func (s *s3PlanStorage) upload(key, localPath string) (string, error) {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error uploading %s: %v", key, err)
+	}
+
+	return aws.StringValue(res.ETag), nil
+}
+
+func (s *s3PlanStorage) download(key, localPath string) (*storageManifestEntry, error) {
+	res, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error downloading %s: %v", key, err)
+	}
+	defer res.Body.Close()
+
+	err = os.MkdirAll(filepath.Dir(localPath), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	// write then rename so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), ".download-*")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(tmp, res.Body)
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), localPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("error writing %s: %v", localPath, err)
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storageManifestEntry{
+		ETag:    aws.StringValue(res.ETag),
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+	}, nil
+}
+
+func (s *s3PlanStorage) deleteKeys(keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > s3DeleteBatchSize {
+			n = s3DeleteBatchSize
+		}
+
+		var objects []*s3.ObjectIdentifier
+		for _, key := range keys[:n] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		keys = keys[n:]
+
+		res, err := s.client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(res.Errors) > 0 {
+			return fmt.Errorf("error deleting %s: %s", aws.StringValue(res.Errors[0].Key), aws.StringValue(res.Errors[0].Message))
+		}
+	}
+
+	return nil
+}
+
+// runParallel calls fn for each item with up to s3TransferConcurrency calls in flight, returning the first error
+func runParallel(items []string, fn func(item string) error) error {
+	sem := make(chan struct{}, s3TransferConcurrency)
+	errCh := make(chan error, len(items))
+	var wg sync.WaitGroup
+
+	for _, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(item); err != nil {
+				errCh <- err
+			}
+		}(item)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	return <-errCh
+}
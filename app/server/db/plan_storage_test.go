@@ -0,0 +1,222 @@
+package db
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 serves the handful of path-style S3 calls plan storage makes
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	key := ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	switch {
+	case r.Method == http.MethodPut && key != "":
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		w.Header().Set("ETag", etagFor(body))
+
+	case r.Method == http.MethodGet && key != "":
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", etagFor(body))
+		w.Write(body)
+
+	case r.Method == http.MethodGet:
+		type contents struct {
+			Key  string
+			ETag string
+			Size int
+		}
+		type listBucketResult struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			IsTruncated bool
+			Contents    []contents
+		}
+		prefix := r.URL.Query().Get("prefix")
+		res := listBucketResult{}
+		for k, body := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				res.Contents = append(res.Contents, contents{Key: k, ETag: etagFor(body), Size: len(body)})
+			}
+		}
+		sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+		xml.NewEncoder(w).Encode(res)
+
+	case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		var req struct {
+			Object []struct{ Key string }
+		}
+		xml.NewDecoder(r.Body).Decode(&req)
+		for _, obj := range req.Object {
+			delete(f.objects, obj.Key)
+		}
+		w.Write([]byte(`<DeleteResult></DeleteResult>`))
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func etagFor(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// withPlanStorage swaps in the given storage and base dir, as if running on a separate server
+func withPlanStorage(t *testing.T, storage PlanStorage, baseDir string, fn func()) {
+	prevStorage, prevBaseDir := planStorage, BaseDir
+	planStorage, BaseDir = storage, baseDir
+	defer func() {
+		planStorage, BaseDir = prevStorage, prevBaseDir
+	}()
+	fn()
+}
+
+func TestS3PlanStorage(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	storage, err := newS3PlanStorage("plans", "/prefix/", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgId, planId := "org", "plan"
+	baseA, baseB := t.TempDir(), t.TempDir()
+	dirA, dirB := filepath.Join(baseA, "orgs", orgId, "plans", planId), filepath.Join(baseB, "orgs", orgId, "plans", planId)
+
+	var shas []string
+
+	// server A creates the plan and commits
+	withPlanStorage(t, storage, baseA, func() {
+		if err := os.MkdirAll(dirA, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := InitGitRepo(orgId, planId); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, dirA, "settings.json", "{}")
+		writeTestFile(t, dirA, "conversation/1.json", `{"message": 1}`)
+		if err := GitAddAndCommit(orgId, planId, "main", "first"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	for key := range fake.objects {
+		if !strings.HasPrefix(key, "prefix/orgs/org/plans/plan/") {
+			t.Fatalf("unexpected key %s", key)
+		}
+	}
+	if string(fake.objects["prefix/orgs/org/plans/plan/settings.json"]) != "{}" {
+		t.Fatal("expected settings.json to be uploaded")
+	}
+
+	// server B picks it up and commits on top
+	withPlanStorage(t, storage, baseB, func() {
+		if err := hydratePlanDir(dirB); err != nil {
+			t.Fatal(err)
+		}
+		_, shas, err = GetGitCommitHistory(orgId, planId, "main")
+		if err != nil || len(shas) != 1 {
+			t.Fatalf("expected 1 commit after hydrating, got %v (%v)", shas, err)
+		}
+
+		writeTestFile(t, dirB, "conversation/2.json", `{"message": 2}`)
+		if err := os.Remove(filepath.Join(dirB, "settings.json")); err != nil {
+			t.Fatal(err)
+		}
+		if err := GitAddAndCommit(orgId, planId, "main", "second"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if _, ok := fake.objects["prefix/orgs/org/plans/plan/settings.json"]; ok {
+		t.Fatal("expected settings.json to be deleted from storage")
+	}
+
+	// server A sees B's commit, including the removed file
+	withPlanStorage(t, storage, baseA, func() {
+		if err := hydratePlanDir(dirA); err != nil {
+			t.Fatal(err)
+		}
+		_, shas, err = GetGitCommitHistory(orgId, planId, "main")
+		if err != nil || len(shas) != 2 {
+			t.Fatalf("expected 2 commits after hydrating, got %v (%v)", shas, err)
+		}
+		if b, err := os.ReadFile(filepath.Join(dirA, "conversation/2.json")); err != nil || string(b) != `{"message": 2}` {
+			t.Fatalf("expected conversation/2.json from server B, got %q (%v)", b, err)
+		}
+		if _, err := os.Stat(filepath.Join(dirA, "settings.json")); !os.IsNotExist(err) {
+			t.Fatalf("expected settings.json to be removed: %v", err)
+		}
+
+		// and can keep committing on the hydrated repo
+		writeTestFile(t, dirA, "conversation/3.json", `{"message": 3}`)
+		if err := GitAddAndCommit(orgId, planId, "main", "third"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeletePlanDir(orgId, planId); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if len(fake.objects) != 0 {
+		t.Fatalf("expected all objects to be deleted, %d remain", len(fake.objects))
+	}
+}
+
+func TestS3PlanStorageHydrateNewPlan(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	storage, err := newS3PlanStorage("plans", "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a plan that was never stored keeps its local dir untouched
+	orgId, planId, dir := testGitPlan(t)
+	writeTestFile(t, dir, "settings.json", "{}")
+	withPlanStorage(t, storage, BaseDir, func() {
+		if err := hydratePlanDir(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := GitAddAndCommit(orgId, planId, "main", "first"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -1,6 +1,12 @@
 package db
 
-import "fmt"
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/plandex/plandex/shared"
+)
 
 func GetOrgOwnerRoleId() (string, error) {
 	var roleId string
@@ -23,3 +29,174 @@ func GetOrgMemberRoleId() (string, error) {
 
 	return roleId, nil
 }
+
+// GetOrgRole returns a built-in role or one of the org's custom roles, or nil if the org can't use a role with this id
+func GetOrgRole(orgId, roleId string) (*OrgRole, error) {
+	var role OrgRole
+	err := Conn.Get(&role, "SELECT * FROM org_roles WHERE id = $1 AND (org_id IS NULL OR org_id = $2)", roleId, orgId)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting org role: %v", err)
+	}
+
+	return &role, nil
+}
+
+// ListOrgRolePermissions lists the permissions of every role the org can use. Built-in roles also hold permissions over other orgs' custom roles, which are left out.
+func ListOrgRolePermissions(orgId string) ([]*OrgRolePermission, error) {
+	var permissions []*OrgRolePermission
+
+	query := `
+    SELECT orp.org_role_id, p.name, COALESCE(orp.resource_id, p.resource_id) AS resource_id
+    FROM org_roles_permissions orp
+    JOIN permissions p ON p.id = orp.permission_id
+    JOIN org_roles r ON r.id = orp.org_role_id
+    WHERE (r.org_id IS NULL OR r.org_id = $1)
+    AND (p.resource_id IS NULL OR p.resource_id NOT IN (SELECT id FROM org_roles WHERE org_id IS NOT NULL AND org_id != $1))
+    ORDER BY p.name
+    `
+
+	err := Conn.Select(&permissions, query, orgId)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing org role permissions: %v", err)
+	}
+
+	return permissions, nil
+}
+
+// CreateOrgRole creates a custom role for an org. Owners and admins get permission to invite, remove, and assign users with the new role, just like the built-in roles.
+func CreateOrgRole(role *OrgRole, permissions []*shared.OrgRolePermission, tx *sqlx.Tx) error {
+	err := tx.QueryRow("INSERT INTO org_roles (org_id, name, label, description) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at", role.OrgId, role.Name, role.Label, role.Description).Scan(&role.Id, &role.CreatedAt, &role.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("error creating org role: %v", err)
+	}
+
+	userPermissions := []struct {
+		name        string
+		description string
+	}{
+		{"invite_user", "Invite users with the %s role to an org"},
+		{"remove_user", "Remove users with the %s role from an org"},
+		{"set_user_role", "Update a role in an org to or from %s"},
+	}
+
+	var managerRoleIds []string
+	err = tx.Select(&managerRoleIds, "SELECT id FROM org_roles WHERE org_id IS NULL AND name IN ('owner', 'admin')")
+
+	if err != nil {
+		return fmt.Errorf("error getting owner and admin role ids: %v", err)
+	}
+
+	for _, userPermission := range userPermissions {
+		var permissionId string
+		err = tx.QueryRow("INSERT INTO permissions (name, description, resource_id) VALUES ($1, $2, $3) RETURNING id", userPermission.name, fmt.Sprintf(userPermission.description, role.Label), role.Id).Scan(&permissionId)
+
+		if err != nil {
+			return fmt.Errorf("error creating %s permission for org role: %v", userPermission.name, err)
+		}
+
+		for _, managerRoleId := range managerRoleIds {
+			_, err = tx.Exec("INSERT INTO org_roles_permissions (org_role_id, permission_id) VALUES ($1, $2)", managerRoleId, permissionId)
+
+			if err != nil {
+				return fmt.Errorf("error granting %s permission for org role: %v", userPermission.name, err)
+			}
+		}
+	}
+
+	return setOrgRolePermissions(role.Id, permissions, tx)
+}
+
+// UpdateOrgRole updates a custom role's label and description and replaces its permissions
+func UpdateOrgRole(role *OrgRole, permissions []*shared.OrgRolePermission, tx *sqlx.Tx) error {
+	_, err := tx.Exec("UPDATE org_roles SET label = $1, description = $2 WHERE id = $3 AND org_id IS NOT NULL", role.Label, role.Description, role.Id)
+
+	if err != nil {
+		return fmt.Errorf("error updating org role: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM org_roles_permissions WHERE org_role_id = $1", role.Id)
+
+	if err != nil {
+		return fmt.Errorf("error removing org role permissions: %v", err)
+	}
+
+	return setOrgRolePermissions(role.Id, permissions, tx)
+}
+
+func setOrgRolePermissions(roleId string, permissions []*shared.OrgRolePermission, tx *sqlx.Tx) error {
+	for _, permission := range permissions {
+		var resourceId *string
+		if permission.ResourceId != "" {
+			resourceId = &permission.ResourceId
+		}
+
+		var permissionId string
+		err := tx.Get(&permissionId, "SELECT id FROM permissions WHERE name = $1 AND resource_id IS NULL", permission.Permission)
+
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("permission %s not found", permission.Permission)
+			}
+			return fmt.Errorf("error getting permission: %v", err)
+		}
+
+		_, err = tx.Exec("INSERT INTO org_roles_permissions (org_role_id, permission_id, resource_id) VALUES ($1, $2, $3)", roleId, permissionId, resourceId)
+
+		if err != nil {
+			return fmt.Errorf("error adding org role permission: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// NumActiveWithRole counts the org's users and pending invites with a role
+func NumActiveWithRole(orgId, roleId string) (int, error) {
+	var count int
+	err := Conn.Get(&count, "SELECT (SELECT COUNT(*) FROM orgs_users WHERE org_id = $1 AND org_role_id = $2) + (SELECT COUNT(*) FROM invites WHERE org_id = $1 AND org_role_id = $2 AND accepted_at IS NULL)", orgId, roleId)
+
+	if err != nil {
+		return 0, fmt.Errorf("error counting users with role: %v", err)
+	}
+
+	return count, nil
+}
+
+// DeleteOrgRole deletes a custom role that no user or pending invite has. Accepted invites are kept, but moved to the member role.
+func DeleteOrgRole(orgId, roleId string, tx *sqlx.Tx) error {
+	_, err := tx.Exec("UPDATE invites SET org_role_id = (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'member') WHERE org_id = $1 AND org_role_id = $2 AND accepted_at IS NOT NULL", orgId, roleId)
+
+	if err != nil {
+		return fmt.Errorf("error updating accepted invites for org role: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM permissions WHERE resource_id = $1", roleId)
+
+	if err != nil {
+		return fmt.Errorf("error deleting org role user permissions: %v", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM org_roles WHERE id = $1 AND org_id = $2", roleId, orgId)
+
+	if err != nil {
+		return fmt.Errorf("error deleting org role: %v", err)
+	}
+
+	return nil
+}
+
+func SetOrgUserRole(orgId, userId, roleId string) error {
+	_, err := Conn.Exec("UPDATE orgs_users SET org_role_id = $1 WHERE org_id = $2 AND user_id = $3", roleId, orgId, userId)
+
+	if err != nil {
+		return fmt.Errorf("error setting org user role: %v", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -338,9 +339,20 @@ func GetPlanResult(planFileResults []*shared.PlanFileResult) *shared.PlanResult
 	}
 }
 
-func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPlanState, error) {
+const appliedCommitMsg = "✅ Marked pending results as applied"
+
+func ApplyPlan(orgId, userId, branchName string, plan *Plan, paths []string) (*shared.CurrentPlanState, error) {
 	planId := plan.Id
 
+	// if paths are passed, only results for those paths are applied--the rest stay pending
+	var pathsSet map[string]bool
+	if len(paths) > 0 {
+		pathsSet = make(map[string]bool)
+		for _, path := range paths {
+			pathsSet[path] = true
+		}
+	}
+
 	resultsDir := getPlanResultsDir(orgId, planId)
 
 	errCh := make(chan error)
@@ -395,11 +407,30 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPla
 	}
 
 	var pendingDbResults []*PlanFileResult
+	appliedConvoIds := make(map[string]bool)
+	stillPendingConvoIds := make(map[string]bool)
 
 	for _, result := range results {
 		apiResult := result.ToApi()
 		if apiResult.IsPending() {
+			if pathsSet != nil && !pathsSet[result.Path] {
+				stillPendingConvoIds[result.ConvoMessageId] = true
+				continue
+			}
 			pendingDbResults = append(pendingDbResults, result)
+			appliedConvoIds[result.ConvoMessageId] = true
+		}
+	}
+
+	if pathsSet != nil && len(pendingDbResults) == 0 {
+		return nil, fmt.Errorf("no pending results for paths: %v", paths)
+	}
+
+	// a description is only marked applied once none of its results are still pending
+	var applyDescriptions []*ConvoMessageDescription
+	for _, description := range convoMessageDescriptions {
+		if !stillPendingConvoIds[description.ConvoMessageId] {
+			applyDescriptions = append(applyDescriptions, description)
 		}
 	}
 
@@ -458,7 +489,7 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPla
 		}(result)
 	}
 
-	for _, description := range convoMessageDescriptions {
+	for _, description := range applyDescriptions {
 		go func(description *ConvoMessageDescription) {
 			description.AppliedAt = &now
 
@@ -539,7 +570,7 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPla
 	}
 
 	numRoutines := len(pendingDbResults) +
-		len(convoMessageDescriptions)
+		len(applyDescriptions)
 	if len(pendingNewFilesSet) > 0 {
 		numRoutines++
 	}
@@ -554,7 +585,23 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPla
 		}
 	}
 
-	msg := "✅ Marked pending results as applied"
+	msg := appliedCommitMsg
+
+	if pathsSet != nil {
+		var appliedPaths []string
+		for path := range pendingNewFilesSet {
+			appliedPaths = append(appliedPaths, path)
+		}
+		for path := range pendingUpdatedFilesSet {
+			appliedPaths = append(appliedPaths, path)
+		}
+		sort.Strings(appliedPaths)
+
+		msg = appliedCommitMsg + " for paths:"
+		for _, path := range appliedPaths {
+			msg += fmt.Sprintf("\n • %s", path)
+		}
+	}
 
 	if loadContextRes != nil && !loadContextRes.MaxTokensExceeded {
 		msg += "\n\n" + loadContextRes.Msg
@@ -570,9 +617,49 @@ func ApplyPlan(orgId, userId, branchName string, plan *Plan) (*shared.CurrentPla
 		return nil, fmt.Errorf("error committing plan: %v", err)
 	}
 
+	if currentPlanState != nil && pathsSet != nil {
+		// only describe the changes that were actually applied in the commit message
+		var descs []*shared.ConvoMessageDescription
+		for _, desc := range currentPlanState.ConvoMessageDescriptions {
+			if appliedConvoIds[desc.ConvoMessageId] {
+				descs = append(descs, desc)
+			}
+		}
+		currentPlanState.ConvoMessageDescriptions = descs
+	}
+
 	return currentPlanState, nil
 }
 
+// UnapplyPlan undoes the latest apply by rewinding the plan to the commit before it, which restores pending results, descriptions, and contexts together. It only works if nothing else has been committed to the plan since the apply.
+func UnapplyPlan(orgId, planId, branchName string) error {
+	msg, parentSha, err := GetLatestCommitMsgAndParentSha(orgId, planId)
+
+	if err != nil {
+		return fmt.Errorf("error getting latest commit: %v", err)
+	}
+
+	if !strings.HasPrefix(msg, appliedCommitMsg) || parentSha == "" {
+		return ErrNoApplyToUndo
+	}
+
+	err = GitRewindToSha(orgId, planId, branchName, parentSha)
+
+	if err != nil {
+		return fmt.Errorf("error rewinding plan: %v", err)
+	}
+
+	err = SyncPlanTokens(orgId, planId, branchName)
+
+	if err != nil {
+		return fmt.Errorf("error syncing plan tokens: %v", err)
+	}
+
+	return nil
+}
+
+var ErrNoApplyToUndo = errors.New("the plan has been updated since it was last applied, or was never applied")
+
 func RejectAllResults(orgId, planId string) error {
 	resultsDir := getPlanResultsDir(orgId, planId)
 
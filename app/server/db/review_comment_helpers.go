@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func CreateReviewComment(comment *ReviewComment) error {
+	err := Conn.QueryRow(
+		"INSERT INTO review_comments (org_id, plan_id, branch_id, author_id, path, replacement_id, body) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at",
+		comment.OrgId, comment.PlanId, comment.BranchId, comment.AuthorId, comment.Path, comment.ReplacementId, comment.Body,
+	).Scan(&comment.Id, &comment.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("error creating review comment: %v", err)
+	}
+
+	return nil
+}
+
+func GetReviewComment(branchId, id string) (*ReviewComment, error) {
+	var comment ReviewComment
+	err := Conn.Get(&comment, "SELECT * FROM review_comments WHERE branch_id = $1 AND id = $2", branchId, id)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting review comment: %v", err)
+	}
+
+	return &comment, nil
+}
+
+// ListReviewComments lists a branch's review comments oldest first, with their authors' names and emails
+func ListReviewComments(branchId string, includeResolved bool) ([]*ReviewComment, error) {
+	query := `SELECT rc.*, u.name AS author_name, u.email AS author_email
+	FROM review_comments rc
+	JOIN users u ON u.id = rc.author_id
+	WHERE rc.branch_id = $1`
+
+	if !includeResolved {
+		query += " AND rc.resolved_at IS NULL"
+	}
+
+	query += " ORDER BY rc.created_at, rc.id"
+
+	var comments []*ReviewComment
+	err := Conn.Select(&comments, query, branchId)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing review comments: %v", err)
+	}
+
+	return comments, nil
+}
+
+// ResolveReviewComments resolves the branch's open comments with the given ids, or all of them if ids is empty. It returns the number resolved.
+func ResolveReviewComments(branchId string, ids []string) (int64, error) {
+	query := "UPDATE review_comments SET resolved_at = NOW() WHERE branch_id = ? AND resolved_at IS NULL"
+	args := []interface{}{branchId}
+
+	if len(ids) > 0 {
+		query += " AND id IN (?)"
+		args = append(args, ids)
+	}
+
+	query, args, err := inQuery(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := Conn.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error resolving review comments: %v", err)
+	}
+
+	numResolved, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	return numResolved, nil
+}
+
+func DeleteReviewComment(id string) error {
+	_, err := Conn.Exec("DELETE FROM review_comments WHERE id = $1", id)
+
+	if err != nil {
+		return fmt.Errorf("error deleting review comment: %v", err)
+	}
+
+	return nil
+}
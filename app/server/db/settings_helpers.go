@@ -109,7 +109,7 @@ func GetOrgDefaultSettings(orgId string, fillDefaultModelPack bool) (*shared.Pla
 }
 
 func GetOrgDefaultSettingsForUpdate(orgId string, tx *sqlx.Tx, fillDefaultModelPack bool) (*shared.PlanSettings, error) {
-	query := "SELECT * FROM default_plan_settings WHERE org_id = $1 FOR UPDATE"
+	query := "SELECT * FROM default_plan_settings WHERE org_id = $1" + store.ForUpdate()
 
 	var defaults DefaultPlanSettings
 
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/plandex/plandex/shared"
+)
+
+// how long the user has to finish signing in with the identity provider
+const SsoLoginExpiration = 10 * time.Minute
+
+// user codes are consonants only, so they're easy to read and type and can't spell anything
+const ssoUserCodeChars = "BCDFGHJKLMNPQRSTVWXZ"
+const ssoUserCodeLength = 8
+
+func hashPollSecret(pollSecret string) string {
+	hashBytes := sha256.Sum256([]byte(pollSecret))
+	return hex.EncodeToString(hashBytes[:])
+}
+
+func randomString(n int) (string, error) {
+	bytes, err := shared.GetRandomAlphanumeric(n)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// CreateSsoLogin starts a login and returns it along with the poll secret the client needs to pick up the session once the login completes. Only the secret's hash is stored.
+func CreateSsoLogin() (*SsoLogin, string, error) {
+	login := &SsoLogin{}
+	var err error
+
+	login.State, err = randomString(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating state: %v", err)
+	}
+	login.Nonce, err = randomString(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating nonce: %v", err)
+	}
+	// PKCE verifiers must be 43-128 characters
+	login.CodeVerifier, err = randomString(64)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating code verifier: %v", err)
+	}
+	pollSecret, err := randomString(40)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating poll secret: %v", err)
+	}
+	login.PollSecretHash = hashPollSecret(pollSecret)
+	login.UserCode, err = newSsoUserCode()
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating user code: %v", err)
+	}
+
+	err = Conn.QueryRow(
+		"INSERT INTO sso_logins (state, nonce, code_verifier, poll_secret_hash, user_code) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		login.State, login.Nonce, login.CodeVerifier, login.PollSecretHash, login.UserCode,
+	).Scan(&login.Id, &login.CreatedAt)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating sso login: %v", err)
+	}
+
+	return login, pollSecret, nil
+}
+
+func newSsoUserCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < ssoUserCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(ssoUserCodeChars))))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(ssoUserCodeChars[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// FormatSsoUserCode splits a user code in two for showing to the user, like BCDF-GHJK
+func FormatSsoUserCode(userCode string) string {
+	return userCode[:ssoUserCodeLength/2] + "-" + userCode[ssoUserCodeLength/2:]
+}
+
+// SsoUserCodeMatches is true if entered is the login's user code, ignoring case, spaces and dashes. Logins from before user codes were added never match.
+func SsoUserCodeMatches(login *SsoLogin, entered string) bool {
+	entered = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(entered))
+	return login.UserCode != "" && subtle.ConstantTimeCompare([]byte(entered), []byte(login.UserCode)) == 1
+}
+
+// GetPendingSsoLoginByState returns nil if there's no unexpired, uncompleted login for the state
+func GetPendingSsoLoginByState(state string) (*SsoLogin, error) {
+	var login SsoLogin
+	err := Conn.Get(&login, "SELECT * FROM sso_logins WHERE state = $1 AND completed_at IS NULL AND created_at > $2", state, time.Now().Add(-SsoLoginExpiration))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting sso login: %v", err)
+	}
+
+	return &login, nil
+}
+
+// CompleteSsoLogin records the outcome of the provider's redirect--either the signed in user or an error to show the client
+func CompleteSsoLogin(id string, userId *string, errMsg *string, tx *sqlx.Tx) error {
+	query := "UPDATE sso_logins SET user_id = $1, error = $2, completed_at = NOW() WHERE id = $3 AND completed_at IS NULL"
+	var err error
+	if tx == nil {
+		_, err = Conn.Exec(query, userId, errMsg, id)
+	} else {
+		_, err = tx.Exec(query, userId, errMsg, id)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error completing sso login: %v", err)
+	}
+
+	return nil
+}
+
+// ConsumeSsoLogin returns the login if the poll secret matches, marking it consumed once it's complete so the session can only be picked up once. It returns nil if there's no matching unexpired, unconsumed login.
+func ConsumeSsoLogin(id, pollSecret string) (*SsoLogin, error) {
+	var login SsoLogin
+	err := Conn.Get(&login, "SELECT * FROM sso_logins WHERE id = $1 AND poll_secret_hash = $2 AND consumed_at IS NULL AND created_at > $3", id, hashPollSecret(pollSecret), time.Now().Add(-SsoLoginExpiration))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting sso login: %v", err)
+	}
+
+	if login.CompletedAt == nil {
+		return &login, nil
+	}
+
+	res, err := Conn.Exec("UPDATE sso_logins SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL", id)
+	if err != nil {
+		return nil, fmt.Errorf("error consuming sso login: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	// a concurrent poll got there first
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &login, nil
+}
+
+func DeleteExpiredSsoLogins() error {
+	_, err := Conn.Exec("DELETE FROM sso_logins WHERE created_at < $1", time.Now().Add(-SsoLoginExpiration))
+
+	if err != nil {
+		return fmt.Errorf("error deleting expired sso logins: %v", err)
+	}
+
+	return nil
+}
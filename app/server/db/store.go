@@ -0,0 +1,33 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Store covers what differs between the database backends. Queries elsewhere in this package stick to SQL that both Postgres and SQLite understand ($n placeholders, RETURNING, ON CONFLICT, NOW()).
+type Store interface {
+	Name() string
+	Open(dbUrl string) (*sqlx.DB, error)
+	MigrationsUp(conn *sqlx.DB) error
+
+	// ForUpdate is appended to SELECTs that lock the rows they read for the rest of the transaction
+	ForUpdate() string
+	LockTxOptions() *sql.TxOptions
+
+	// LockPlan serializes repo lock acquisition for a plan within this process. Postgres relies on row locks instead, so it's a no-op there.
+	LockPlan(planId string) (unlock func())
+
+	// NewEventBus connects pub/sub between the server instances sharing this database
+	NewEventBus(dbUrl string) (EventBus, error)
+
+	IsNonUniqueErr(err error) bool
+	IsRetryableTxErr(err error) bool
+}
+
+var store Store = &postgresStore{}
+
+func CurrentStore() Store {
+	return store
+}
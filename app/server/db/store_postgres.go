@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"plandex-server/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type postgresStore struct{}
+
+func (s *postgresStore) Name() string {
+	return "postgres"
+}
+
+func (s *postgresStore) Open(dbUrl string) (*sqlx.DB, error) {
+	conn, err := sqlx.Connect("postgres", dbUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec("SET TIMEZONE='UTC';")
+
+	if err != nil {
+		return nil, fmt.Errorf("error setting timezone: %v", err)
+	}
+
+	return conn, nil
+}
+
+func (s *postgresStore) MigrationsUp(conn *sqlx.DB) error {
+	driver, err := postgres.WithInstance(conn.DB, &postgres.Config{})
+
+	if err != nil {
+		return fmt.Errorf("error creating postgres driver: %v", err)
+	}
+
+	source, err := iofs.New(migrations.FS, ".")
+
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %v", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+
+	if err != nil {
+		return fmt.Errorf("error creating migration instance: %v", err)
+	}
+
+	return runMigrations(m)
+}
+
+func (s *postgresStore) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (s *postgresStore) LockTxOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+}
+
+func (s *postgresStore) LockPlan(planId string) func() {
+	return func() {}
+}
+
+func (s *postgresStore) NewEventBus(dbUrl string) (EventBus, error) {
+	return newPgEventBus(dbUrl)
+}
+
+func (s *postgresStore) IsNonUniqueErr(err error) bool {
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *postgresStore) IsRetryableTxErr(err error) bool {
+	// serialization failure or deadlock
+	if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "40001" || pqErr.Code == "40P01") {
+		return true
+	}
+	return false
+}
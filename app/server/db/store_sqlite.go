@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"plandex-server/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteStore keeps everything in a single file for local, single-user installs. DATABASE_URL looks like sqlite:///path/to/plandex.db
+type sqliteStore struct {
+	mu        sync.Mutex
+	planLocks map[string]*sync.Mutex
+}
+
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// modernc's driver understands $n placeholders, so rebinding for it should leave them alone
+	sqlx.BindDriver("sqlite", sqlx.DOLLAR)
+
+	// NOW() as Postgres has it, in the same format the driver writes time values
+	sqlite.MustRegisterScalarFunction("now", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+}
+
+func IsSqliteUrl(dbUrl string) bool {
+	return strings.HasPrefix(dbUrl, "sqlite:")
+}
+
+func (s *sqliteStore) Name() string {
+	return "sqlite"
+}
+
+func (s *sqliteStore) Open(dbUrl string) (*sqlx.DB, error) {
+	path := strings.TrimPrefix(strings.TrimPrefix(dbUrl, "sqlite:"), "//")
+	if path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating sqlite database dir: %v", err)
+	}
+
+	// immediate transactions take the write lock up front so concurrent writers wait on busy_timeout rather than failing partway through
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite"
+
+	conn, err := sqlx.Connect("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (s *sqliteStore) MigrationsUp(conn *sqlx.DB) error {
+	driver, err := migratesqlite.WithInstance(conn.DB, &migratesqlite.Config{})
+
+	if err != nil {
+		return fmt.Errorf("error creating sqlite driver: %v", err)
+	}
+
+	source, err := iofs.New(migrations.FS, "sqlite")
+
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %v", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite", driver)
+
+	if err != nil {
+		return fmt.Errorf("error creating migration instance: %v", err)
+	}
+
+	return runMigrations(m)
+}
+
+func (s *sqliteStore) ForUpdate() string {
+	// transactions are immediate, so a transaction that reads has already locked the database for writes
+	return ""
+}
+
+func (s *sqliteStore) LockTxOptions() *sql.TxOptions {
+	return nil
+}
+
+func (s *sqliteStore) LockPlan(planId string) func() {
+	s.mu.Lock()
+	if s.planLocks == nil {
+		s.planLocks = map[string]*sync.Mutex{}
+	}
+	planLock, ok := s.planLocks[planId]
+	if !ok {
+		planLock = &sync.Mutex{}
+		s.planLocks[planId] = planLock
+	}
+	s.mu.Unlock()
+
+	planLock.Lock()
+	return planLock.Unlock
+}
+
+// SQLite means a single instance, so events never need to leave the process
+func (s *sqliteStore) NewEventBus(dbUrl string) (EventBus, error) {
+	return newMemoryEventBus(), nil
+}
+
+func (s *sqliteStore) IsNonUniqueErr(err error) bool {
+	if err, ok := err.(*sqlite.Error); ok {
+		return err.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || err.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+func (s *sqliteStore) IsRetryableTxErr(err error) bool {
+	if err, ok := err.(*sqlite.Error); ok {
+		code := err.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
@@ -5,17 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/plandex/plandex/shared"
+	"github.com/sashabaranov/go-openai"
 )
 
 const modelStreamHeartbeatInterval = 1 * time.Second
 const modelStreamHeartbeatTimeout = 5 * time.Second
 
 func StoreModelStream(stream *ModelStream, ctx context.Context, cancelFn context.CancelFunc) error {
-	query := `INSERT INTO model_streams (org_id, plan_id, internal_ip, branch) VALUES (:org_id, :plan_id, :internal_ip, :branch) RETURNING id, created_at`
+	query := `INSERT INTO model_streams (org_id, plan_id, user_id, internal_ip, branch) VALUES (:org_id, :plan_id, :user_id, :internal_ip, :branch) RETURNING id, created_at`
 
 	row, err := Conn.NamedQuery(query, stream)
 
@@ -100,29 +101,208 @@ func GetActiveModelStream(planId, branch string) (*ModelStream, error) {
 	if time.Now().Add(-modelStreamHeartbeatTimeout).After(stream.LastHeartbeatAt) {
 		log.Printf("Model stream %s has not sent a heartbeat in %s\n", stream.Id, modelStreamHeartbeatTimeout)
 
-		err := SetModelStreamFinished(stream.Id)
+		_, err := finishInterruptedModelStream(&stream, "the server running it stopped responding")
 
 		if err != nil {
-			return nil, fmt.Errorf("error setting model stream finished: %v", err)
+			return nil, err
 		}
 
-		err = SetPlanStatus(planId, branch, shared.PlanStatusError, "Model stream has not sent a heartbeat in 5 seconds")
+		return nil, nil
+	} else {
+		log.Printf("Model stream %s sent heartbeat %d seconds ago\n", stream.Id, int(time.Since(stream.LastHeartbeatAt).Seconds()))
+	}
+
+	return &stream, nil
+}
 
+// SetModelStreamCheckpoint saves a running plan's latest checkpoint
+func SetModelStreamCheckpoint(id string, checkpoint *ModelStreamCheckpoint) error {
+	_, err := Conn.Exec("UPDATE model_streams SET checkpoint = $1 WHERE id = $2 AND finished_at IS NULL", checkpoint, id)
+
+	if err != nil {
+		return fmt.Errorf("error setting model stream checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// FinishOrphanedModelStreams finishes plans whose model streams have stopped heartbeating, either because the server running them crashed or because it was restarted before they were done
+func FinishOrphanedModelStreams() error {
+	var streams []*ModelStream
+	err := Conn.Select(&streams, "SELECT * FROM model_streams WHERE finished_at IS NULL")
+
+	if err != nil {
+		return fmt.Errorf("error getting unfinished model streams: %v", err)
+	}
+
+	for _, stream := range streams {
+		if time.Since(stream.LastHeartbeatAt) < modelStreamHeartbeatTimeout {
+			continue
+		}
+
+		log.Printf("Model stream %s for plan %s is orphaned | last heartbeat at %s\n", stream.Id, stream.PlanId, stream.LastHeartbeatAt)
+
+		_, err := finishInterruptedModelStream(stream, "the server running it stopped responding")
 		if err != nil {
-			return nil, fmt.Errorf("error setting plan status to error: %v", err)
+			log.Printf("Error finishing orphaned model stream %s: %v\n", stream.Id, err)
 		}
+	}
 
-		return nil, nil
+	return nil
+}
+
+// FinishInterruptedModelStream finishes a plan from its last checkpoint, for a server that's shutting down with the plan still running. It returns the error the plan's status was set to.
+func FinishInterruptedModelStream(id, reason string) (string, error) {
+	var stream ModelStream
+	err := Conn.Get(&stream, "SELECT * FROM model_streams WHERE id = $1", id)
+
+	if err != nil {
+		return "", fmt.Errorf("error getting model stream: %v", err)
+	}
+
+	return finishInterruptedModelStream(&stream, reason)
+}
+
+// finishInterruptedModelStream stores any partial reply from the stream's checkpoint, then sets the plan's status to an error explaining what was left undone. Whichever caller sets the stream finished first does this, so it only happens once even when multiple servers notice the same stream.
+func finishInterruptedModelStream(stream *ModelStream, reason string) (string, error) {
+	res, err := Conn.Exec("UPDATE model_streams SET finished_at = NOW() WHERE id = $1 AND finished_at IS NULL", stream.Id)
+
+	if err != nil {
+		return "", fmt.Errorf("error setting model stream finished: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		log.Printf("Model stream %s was already finished\n", stream.Id)
+		return "", nil
+	}
+
+	log.Printf("Finishing interrupted model stream %s for plan %s\n", stream.Id, stream.PlanId)
+
+	checkpoint := stream.Checkpoint
+
+	if stream.UserId != nil {
+		err = restoreModelStreamCheckpoint(stream)
+
+		if err != nil {
+			log.Printf("Error restoring checkpoint for model stream %s: %v\n", stream.Id, err)
+			// don't tell the user the reply was saved if it wasn't
+			checkpoint = nil
+		}
 	} else {
-		log.Printf("Model stream %s sent heartbeat %d seconds ago\n", stream.Id, int(time.Since(stream.LastHeartbeatAt).Seconds()))
+		// streams from before checkpoints were added
+		checkpoint = nil
 	}
 
-	return &stream, nil
+	errStr := InterruptedPlanError(reason, checkpoint)
+
+	err = SetPlanStatus(stream.PlanId, stream.Branch, shared.PlanStatusError, errStr)
+
+	if err != nil {
+		return "", fmt.Errorf("error setting plan status to error: %v", err)
+	}
+
+	return errStr, nil
+}
+
+// restoreModelStreamCheckpoint discards whatever the interrupted stream left uncommitted in the plan's repo and stores its partial reply, the same as when a plan is stopped
+func restoreModelStreamCheckpoint(stream *ModelStream) error {
+	userId := *stream.UserId
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+
+	repoLockId, err := LockRepo(
+		LockRepoParams{
+			OrgId:    stream.OrgId,
+			UserId:   userId,
+			PlanId:   stream.PlanId,
+			Branch:   stream.Branch,
+			Scope:    LockScopeWrite,
+			Ctx:      ctx,
+			CancelFn: cancelFn,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("error locking repo: %v", err)
+	}
+
+	defer func() {
+		err := DeleteRepoLock(repoLockId)
+		if err != nil {
+			log.Printf("Error unlocking repo: %v\n", err)
+		}
+	}()
+
+	err = GitClearUncommittedChanges(stream.OrgId, stream.PlanId)
+
+	if err != nil {
+		return fmt.Errorf("error clearing uncommitted changes: %v", err)
+	}
+
+	checkpoint := stream.Checkpoint
+
+	if checkpoint == nil || checkpoint.BuildOnly || checkpoint.RepliesFinished || checkpoint.ReplyContent == "" {
+		return nil
+	}
+
+	msg := ConvoMessage{
+		OrgId:   stream.OrgId,
+		PlanId:  stream.PlanId,
+		UserId:  userId,
+		Role:    openai.ChatMessageRoleAssistant,
+		Tokens:  checkpoint.ReplyTokens,
+		Num:     checkpoint.MessageNum + 1,
+		Stopped: true,
+		Message: checkpoint.ReplyContent,
+	}
+
+	_, err = StoreConvoMessage(&msg, userId, stream.Branch, true)
+
+	if err != nil {
+		return fmt.Errorf("error storing convo message: %v", err)
+	}
+
+	return nil
+}
+
+// InterruptedPlanError explains why a plan was interrupted and what it left undone as of checkpoint
+func InterruptedPlanError(reason string, checkpoint *ModelStreamCheckpoint) string {
+	msg := fmt.Sprintf("Plan was interrupted because %s.", reason)
+
+	if checkpoint == nil {
+		return msg
+	}
+
+	if !checkpoint.BuildOnly && !checkpoint.RepliesFinished && checkpoint.ReplyContent != "" {
+		msg += " The reply so far was saved. Use 'plandex continue' to pick up where it left off."
+	}
+
+	if len(checkpoint.BuildPaths) > 0 {
+		msg += fmt.Sprintf(" These files hadn't finished building: %s. Use 'plandex build' to build them.", strings.Join(checkpoint.BuildPaths, ", "))
+	}
+
+	return msg
 }
 
 func GetActiveOrRecentModelStreams(planIds []string) ([]*ModelStream, error) {
 	var streams []*ModelStream
-	err := Conn.Select(&streams, "SELECT * FROM model_streams WHERE plan_id = ANY($1) AND (finished_at IS NULL OR finished_at > NOW() - INTERVAL '1 hour') ORDER BY created_at", pq.Array(planIds))
+
+	if len(planIds) == 0 {
+		return streams, nil
+	}
+
+	query, args, err := inQuery("SELECT * FROM model_streams WHERE plan_id IN (?) AND (finished_at IS NULL OR finished_at > ?) ORDER BY created_at", planIds, time.Now().UTC().Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&streams, query, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error getting active or recent model streams: %v", err)
@@ -133,7 +313,17 @@ func GetActiveOrRecentModelStreams(planIds []string) ([]*ModelStream, error) {
 
 func GetActiveModelStreams(planIds []string) ([]*ModelStream, error) {
 	var streams []*ModelStream
-	err := Conn.Select(&streams, "SELECT * FROM model_streams WHERE plan_id = ANY($1) AND finished_at IS NULL ORDER BY created_at", pq.Array(planIds))
+
+	if len(planIds) == 0 {
+		return streams, nil
+	}
+
+	query, args, err := inQuery("SELECT * FROM model_streams WHERE plan_id IN (?) AND finished_at IS NULL ORDER BY created_at", planIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&streams, query, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error getting active  model streams: %v", err)
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestFinishOrphanedModelStreams(t *testing.T) {
+	testBackends(t, func(t *testing.T) {
+		user, org, plan := createTestPlan(t, "streams@example.com")
+
+		// a stream from a server that crashed partway through a reply, with a build queued
+		checkpoint := &ModelStreamCheckpoint{
+			MessageNum:   0,
+			ReplyContent: "Let's start by updating main.go",
+			ReplyTokens:  7,
+			BuildPaths:   []string{"main.go"},
+		}
+		stale := time.Now().UTC().Add(-time.Minute)
+
+		var streamId string
+		err := Conn.QueryRow(
+			"INSERT INTO model_streams (org_id, plan_id, user_id, internal_ip, branch, checkpoint, last_heartbeat_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+			org.Id, plan.Id, user.Id, "10.0.0.1", "main", checkpoint, stale,
+		).Scan(&streamId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Conn.Exec(
+			"INSERT INTO repo_locks (org_id, user_id, plan_id, scope, branch, last_heartbeat_at) VALUES ($1, $2, $3, $4, $5, $6)",
+			org.Id, user.Id, plan.Id, LockScopeWrite, "main", stale,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeleteExpiredRepoLocks(); err != nil {
+			t.Fatal(err)
+		}
+		var numLocks int
+		if err := Conn.Get(&numLocks, "SELECT COUNT(*) FROM repo_locks"); err != nil {
+			t.Fatal(err)
+		}
+		if numLocks != 0 {
+			t.Fatalf("expected expired lock to be deleted, got %d locks", numLocks)
+		}
+
+		// a second sweep, like another server noticing the same stream, shouldn't store the reply twice
+		for i := 0; i < 2; i++ {
+			if err := FinishOrphanedModelStreams(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stream, err := GetActiveModelStream(plan.Id, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream != nil {
+			t.Fatal("expected orphaned stream to be finished")
+		}
+
+		branch, err := GetDbBranch(plan.Id, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if branch.Status != shared.PlanStatusError || branch.Error == nil {
+			t.Fatalf("expected error status, got %q", branch.Status)
+		}
+		if !strings.Contains(*branch.Error, "plandex continue") || !strings.Contains(*branch.Error, "main.go") {
+			t.Fatalf("unexpected error: %q", *branch.Error)
+		}
+
+		convo, err := GetPlanConvo(org.Id, plan.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(convo) != 1 {
+			t.Fatalf("expected the partial reply to be stored once, got %d messages", len(convo))
+		}
+		if !convo[0].Stopped || convo[0].Message != checkpoint.ReplyContent {
+			t.Fatalf("unexpected stored reply: %+v", convo[0])
+		}
+	})
+}
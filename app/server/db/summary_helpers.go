@@ -3,14 +3,21 @@ package db
 import (
 	"fmt"
 	"time"
-
-	"github.com/lib/pq"
 )
 
 func GetPlanSummaries(planId string, convoMessageIds []string) ([]*ConvoSummary, error) {
 	var summaries []*ConvoSummary
 
-	err := Conn.Select(&summaries, "SELECT * FROM convo_summaries WHERE plan_id = $1 AND latest_convo_message_id = ANY($2) ORDER BY created_at", planId, pq.Array(convoMessageIds))
+	if len(convoMessageIds) == 0 {
+		return summaries, nil
+	}
+
+	query, args, err := inQuery("SELECT * FROM convo_summaries WHERE plan_id = ? AND latest_convo_message_id IN (?) ORDER BY created_at", planId, convoMessageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&summaries, query, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error getting plan summaries: %v", err)
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
 )
 
 func GetUser(userId string) (*User, error) {
@@ -89,7 +88,16 @@ func ListUsers(orgId string) ([]*User, error) {
 		userIds[i] = ou.UserId
 	}
 
-	err = Conn.Select(&users, "SELECT * FROM users WHERE id = ANY($1)", pq.Array(userIds))
+	if len(userIds) == 0 {
+		return users, nil
+	}
+
+	query, args, err := inQuery("SELECT * FROM users WHERE id IN (?)", userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Conn.Select(&users, query, args...)
 
 	if err != nil {
 		return nil, fmt.Errorf("error listing users: %v", err)
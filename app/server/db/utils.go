@@ -1,12 +1,20 @@
 package db
 
-import "github.com/lib/pq"
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
 
 func IsNonUniqueErr(err error) bool {
-	if err, ok := err.(*pq.Error); ok {
-		if err.Code == "23505" {
-			return true
-		}
+	return store.IsNonUniqueErr(err)
+}
+
+// inQuery expands a slice arg into an 'IN (?)' clause and rebinds the query for the current backend. Queries passed to it use '?' placeholders.
+func inQuery(query string, args ...interface{}) (string, []interface{}, error) {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return "", nil, fmt.Errorf("error building query: %v", err)
 	}
-	return false
+	return Conn.Rebind(query), args, nil
 }
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"plandex-server/logging"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+const webhookSecretRandomLength = 32
+
+// WebhookDeliveriesChannel is published to on the event bus when deliveries are queued, so any instance can send them right away rather than waiting to poll
+const WebhookDeliveriesChannel = "webhooks:deliveries"
+
+// CreateWebhook stores a new webhook along with a generated signing secret, which is set on the webhook
+func CreateWebhook(webhook *Webhook) error {
+	random, err := shared.GetRandomAlphanumeric(webhookSecretRandomLength)
+	if err != nil {
+		return fmt.Errorf("error generating webhook secret: %v", err)
+	}
+	webhook.Secret = shared.WebhookSecretPrefix + string(random)
+
+	err = Conn.QueryRow(
+		"INSERT INTO webhooks (org_id, creator_id, url, secret, events) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		webhook.OrgId, webhook.CreatorId, webhook.Url, webhook.Secret, webhook.Events,
+	).Scan(&webhook.Id, &webhook.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("error creating webhook: %v", err)
+	}
+
+	return nil
+}
+
+func ListWebhooks(orgId string) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	err := Conn.Select(&webhooks, "SELECT * FROM webhooks WHERE org_id = $1 ORDER BY created_at", orgId)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %v", err)
+	}
+
+	return webhooks, nil
+}
+
+func GetWebhook(orgId, id string) (*Webhook, error) {
+	var webhook Webhook
+	err := Conn.Get(&webhook, "SELECT * FROM webhooks WHERE id = $1 AND org_id = $2", id, orgId)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting webhook: %v", err)
+	}
+
+	return &webhook, nil
+}
+
+// DeleteWebhook deletes a webhook along with its delivery log
+func DeleteWebhook(orgId, id string) error {
+	_, err := Conn.Exec("DELETE FROM webhooks WHERE id = $1 AND org_id = $2", id, orgId)
+
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	return nil
+}
+
+// QueueWebhookEvent queues a delivery of the payload to each of the org's webhooks registered for its event. It sets the payload's id and time, which are shared by every delivery of the event.
+func QueueWebhookEvent(orgId string, payload shared.WebhookPayload) error {
+	webhooks, err := ListWebhooks(orgId)
+	if err != nil {
+		return err
+	}
+
+	var matching []*Webhook
+	for _, webhook := range webhooks {
+		for _, event := range webhook.Events {
+			if shared.WebhookEvent(event) == payload.Event {
+				matching = append(matching, webhook)
+				break
+			}
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil
+	}
+
+	payload.Id = uuid.New().String()
+	payload.OrgId = orgId
+	payload.CreatedAt = time.Now().UTC()
+
+	for _, webhook := range matching {
+		_, err := createWebhookDelivery(webhook, payload, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = PublishEvent(WebhookDeliveriesChannel, []byte(payload.Id))
+	if err != nil {
+		// deliveries are still picked up on the next poll
+		logging.Ctx(context.Background()).With("org_id", orgId, "event", payload.Event).Errorf("Error publishing webhook deliveries event: %v", err)
+	}
+
+	return nil
+}
+
+// CreateTestWebhookDelivery creates a webhook.test delivery to a single webhook, whatever events it's registered for. It's left for the caller to send, so it isn't due for a minute--in case the caller never does.
+func CreateTestWebhookDelivery(webhook *Webhook) (*WebhookDelivery, error) {
+	return createWebhookDelivery(webhook, shared.WebhookPayload{
+		Id:        uuid.New().String(),
+		Event:     shared.WebhookEventTest,
+		OrgId:     webhook.OrgId,
+		CreatedAt: time.Now().UTC(),
+	}, time.Now().Add(time.Minute))
+}
+
+func createWebhookDelivery(webhook *Webhook, payload shared.WebhookPayload, nextAttemptAt time.Time) (*WebhookDelivery, error) {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling webhook payload: %v", err)
+	}
+
+	nextAttemptAt = nextAttemptAt.UTC()
+
+	delivery := &WebhookDelivery{
+		OrgId:         webhook.OrgId,
+		WebhookId:     webhook.Id,
+		Event:         payload.Event,
+		Payload:       string(bytes),
+		Status:        shared.WebhookDeliveryStatusPending,
+		NextAttemptAt: &nextAttemptAt,
+	}
+
+	err = Conn.QueryRow(
+		"INSERT INTO webhook_deliveries (org_id, webhook_id, event, payload, status, next_attempt_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		delivery.OrgId, delivery.WebhookId, delivery.Event, delivery.Payload, delivery.Status, delivery.NextAttemptAt,
+	).Scan(&delivery.Id, &delivery.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("error creating webhook delivery: %v", err)
+	}
+
+	return delivery, nil
+}
+
+// ListDueWebhookDeliveries lists pending deliveries whose next attempt is due, oldest first
+func ListDueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := Conn.Select(&deliveries, "SELECT * FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at LIMIT $3", shared.WebhookDeliveryStatusPending, time.Now().UTC(), limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing due webhook deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimWebhookDelivery counts an attempt and pushes the next one back to leaseUntil, so other instances polling for due deliveries leave it alone while it's being sent. It returns false if another instance claimed it first.
+func ClaimWebhookDelivery(delivery *WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	leaseUntil = leaseUntil.UTC()
+
+	res, err := Conn.Exec(
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = $1 WHERE id = $2 AND status = $3 AND attempts = $4",
+		leaseUntil, delivery.Id, shared.WebhookDeliveryStatusPending, delivery.Attempts,
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("error claiming webhook delivery: %v", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	delivery.Attempts++
+	delivery.NextAttemptAt = &leaseUntil
+
+	return true, nil
+}
+
+// UpdateWebhookDeliveryAttempt records the result of the delivery's latest attempt. NextAttemptAt should be set when the delivery is still pending.
+func UpdateWebhookDeliveryAttempt(delivery *WebhookDelivery) error {
+	var nextAttemptAt *time.Time
+	if delivery.NextAttemptAt != nil {
+		t := delivery.NextAttemptAt.UTC()
+		nextAttemptAt = &t
+	}
+
+	var deliveredAt *time.Time
+	if delivery.Status == shared.WebhookDeliveryStatusSucceeded {
+		now := time.Now().UTC()
+		deliveredAt = &now
+		nextAttemptAt = nil
+	}
+
+	_, err := Conn.Exec(
+		"UPDATE webhook_deliveries SET status = $1, response_status = $2, error = $3, next_attempt_at = $4, delivered_at = $5 WHERE id = $6",
+		delivery.Status, delivery.ResponseStatus, delivery.Error, nextAttemptAt, deliveredAt, delivery.Id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("error updating webhook delivery: %v", err)
+	}
+
+	delivery.NextAttemptAt = nextAttemptAt
+	delivery.DeliveredAt = deliveredAt
+
+	return nil
+}
+
+// ListWebhookDeliveries lists a webhook's latest deliveries, newest first
+func ListWebhookDeliveries(webhookId string, limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := Conn.Select(&deliveries, "SELECT * FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2", webhookId, limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhook deliveries: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// QueuePlanWebhookEvent fills in the plan's org and name and queues the event. Failures are only logged, since webhooks shouldn't get in the way of the plan itself.
+func QueuePlanWebhookEvent(planId, branch string, payload shared.WebhookPayload) {
+	logger := logging.Ctx(context.Background()).With("plan_id", planId, "branch", branch, "event", payload.Event)
+
+	plan, err := GetPlan(planId)
+	if err != nil {
+		logger.Errorf("Error getting plan for %s webhook: %v", payload.Event, err)
+		return
+	}
+
+	payload.PlanId = planId
+	payload.PlanName = plan.Name
+	payload.Branch = branch
+
+	err = QueueWebhookEvent(plan.OrgId, payload)
+	if err != nil {
+		logger.With("org_id", plan.OrgId).Errorf("Error queueing %s webhook: %v", payload.Event, err)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+func CreateAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateAccessTokenHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't create access tokens",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.CreateAccessTokenRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "Access token name is required", http.StatusBadRequest)
+		return
+	}
+
+	scope, err := shared.ParseAccessTokenScope(string(req.Scope))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "expiresInDays can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	var projectIds db.StringList
+	for _, projectId := range req.ProjectIds {
+		if !authorizeProject(w, projectId, auth) {
+			return
+		}
+		projectIds = append(projectIds, projectId)
+	}
+
+	accessToken := &db.AccessToken{
+		OrgId:      auth.OrgId,
+		UserId:     auth.User.Id,
+		Name:       req.Name,
+		Scope:      scope,
+		ProjectIds: projectIds,
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		accessToken.ExpiresAt = &expiresAt
+	}
+
+	token, err := db.CreateAccessToken(accessToken)
+
+	if err != nil {
+		logger.Errorf("Error creating access token: %v", err)
+		http.Error(w, "Error creating access token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionAccessTokenCreate, accessToken.Id, map[string]string{"name": accessToken.Name, "scope": string(accessToken.Scope)})
+
+	bytes, err := json.Marshal(shared.CreateAccessTokenResponse{
+		Token:       token,
+		AccessToken: accessToken.ToApi(),
+	})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully created access token %s", accessToken.Id)
+
+	w.Write(bytes)
+}
+
+func ListAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListAccessTokensHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	accessTokens, err := db.ListAccessTokens(auth.OrgId, auth.User.Id)
+
+	if err != nil {
+		logger.Errorf("Error listing access tokens: %v", err)
+		http.Error(w, "Error listing access tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiAccessTokens := []*shared.AccessToken{}
+	for _, accessToken := range accessTokens {
+		apiAccessTokens = append(apiAccessTokens, accessToken.ToApi())
+	}
+
+	bytes, err := json.Marshal(apiAccessTokens)
+
+	if err != nil {
+		logger.Errorf("Error marshalling access tokens: %v", err)
+		http.Error(w, "Error marshalling access tokens: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Info("Successfully listed access tokens")
+}
+
+func RevokeAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RevokeAccessTokenHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	tokenId := mux.Vars(r)["tokenId"]
+
+	revoked, err := db.RevokeAccessToken(auth.OrgId, auth.User.Id, tokenId)
+
+	if err != nil {
+		logger.Errorf("Error revoking access token: %v", err)
+		http.Error(w, "Error revoking access token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !revoked {
+		logger.Warnf("Access token not found: %s", tokenId)
+		http.Error(w, "Access token not found: "+tokenId, http.StatusNotFound)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionAccessTokenRevoke, tokenId, nil)
+
+	logger.Infof("Successfully revoked access token %s", tokenId)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+// executeScopeRoutes are the changes an execute token can make: creating plans and running them, loading context, and applying or rejecting changes. Everything else that changes something, like renaming, archiving, or deleting plans, needs an admin token.
+var executeScopeRoutes = map[string]bool{
+	"POST /projects":                                     true,
+	"PUT /projects/{projectId}/set_plan":                 true,
+	"POST /projects/{projectId}/plans":                   true,
+	"POST /plans/{planId}/{branch}/tell":                 true,
+	"POST /plans/{planId}/{branch}/respond_missing_file": true,
+	"PATCH /plans/{planId}/{branch}/build":               true,
+	"DELETE /plans/{planId}/{branch}/stop":               true,
+	"PATCH /plans/{planId}/{branch}/apply":               true,
+	"PATCH /plans/{planId}/{branch}/reject_all":          true,
+	"PATCH /plans/{planId}/{branch}/reject_file":         true,
+	"PATCH /plans/{planId}/{branch}/reject_files":        true,
+	"POST /plans/{planId}/{branch}/context":              true,
+	"PUT /plans/{planId}/{branch}/context":               true,
+	"DELETE /plans/{planId}/{branch}/context":            true,
+}
+
+// accessTokenRouteScope returns the scope an access token needs for the current route, or false if access tokens can't be used for it at all. Account and token management always need a signed-in session, so a leaked token can't be used to mint more tokens or take over the account.
+func accessTokenRouteScope(r *http.Request) (shared.AccessTokenScope, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+
+	switch {
+	case strings.HasPrefix(tmpl, "/accounts"),
+		strings.HasPrefix(tmpl, "/access_tokens"),
+		tmpl == "/orgs" && r.Method == http.MethodPost:
+		return "", false
+
+	// streaming and current branch lookups don't change anything, despite their methods
+	case r.Method == http.MethodGet,
+		tmpl == "/plans/{planId}/{branch}/connect",
+		tmpl == "/projects/{projectId}/plans/current_branches":
+		return shared.AccessTokenScopeRead, true
+
+	case executeScopeRoutes[r.Method+" "+tmpl]:
+		return shared.AccessTokenScopeExecute, true
+	}
+
+	return shared.AccessTokenScopeAdmin, true
+}
@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"plandex-server/handlers"
+	"plandex-server/routes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+// executeRoutes is every route an execute token can use. It's spelled out separately from the handlers' allowlist so that widening what CI tokens can do has to be done on purpose in both places.
+var executeRoutes = map[string]bool{
+	"POST /projects":                                     true,
+	"PUT /projects/{projectId}/set_plan":                 true,
+	"POST /projects/{projectId}/plans":                   true,
+	"POST /plans/{planId}/{branch}/tell":                 true,
+	"POST /plans/{planId}/{branch}/respond_missing_file": true,
+	"PATCH /plans/{planId}/{branch}/build":               true,
+	"DELETE /plans/{planId}/{branch}/stop":               true,
+	"PATCH /plans/{planId}/{branch}/apply":               true,
+	"PATCH /plans/{planId}/{branch}/reject_all":          true,
+	"PATCH /plans/{planId}/{branch}/reject_file":         true,
+	"PATCH /plans/{planId}/{branch}/reject_files":        true,
+	"POST /plans/{planId}/{branch}/context":              true,
+	"PUT /plans/{planId}/{branch}/context":               true,
+	"DELETE /plans/{planId}/{branch}/context":            true,
+}
+
+var routeVarPattern = regexp.MustCompile(`\{(\w+)(:[^}]*)?\}`)
+
+type routeScope struct {
+	scope   shared.AccessTokenScope
+	allowed bool
+}
+
+// routeScopes sends a request to every route in the router and records the scope an access token would need for each, keyed by method and path template
+func routeScopes(t *testing.T) map[string]routeScope {
+	router := routes.NewRouter()
+
+	res := map[string]routeScope{}
+
+	// runs in place of the handlers, after the router has matched the route
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tmpl, err := mux.CurrentRoute(r).GetPathTemplate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			scope, allowed := handlers.AccessTokenRouteScope(r)
+			res[r.Method+" "+tmpl] = routeScope{scope, allowed}
+		})
+	})
+
+	var requests []*http.Request
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{http.MethodGet}
+		}
+
+		// fill in each variable with its own name so every path is distinct
+		path := routeVarPattern.ReplaceAllString(tmpl, "$1")
+
+		for _, method := range methods {
+			requests = append(requests, httptest.NewRequest(method, path, nil))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range requests {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(res) != len(requests) {
+		t.Fatalf("expected %d routes to be matched, got %d", len(requests), len(res))
+	}
+
+	return res
+}
+
+func TestAccessTokenRouteScope(t *testing.T) {
+	scopes := routeScopes(t)
+
+	for route, got := range scopes {
+		method, tmpl, _ := strings.Cut(route, " ")
+
+		var expected routeScope
+		switch {
+		case strings.HasPrefix(tmpl, "/accounts"),
+			strings.HasPrefix(tmpl, "/access_tokens"),
+			route == "POST /orgs":
+			expected = routeScope{"", false}
+		case method == http.MethodGet,
+			tmpl == "/plans/{planId}/{branch}/connect",
+			tmpl == "/projects/{projectId}/plans/current_branches":
+			expected = routeScope{shared.AccessTokenScopeRead, true}
+		case executeRoutes[route]:
+			expected = routeScope{shared.AccessTokenScopeExecute, true}
+		default:
+			expected = routeScope{shared.AccessTokenScopeAdmin, true}
+		}
+
+		if got != expected {
+			t.Errorf("%s: expected %+v, got %+v", route, expected, got)
+		}
+	}
+
+	for route := range executeRoutes {
+		if _, ok := scopes[route]; !ok {
+			t.Errorf("execute route %s isn't registered", route)
+		}
+	}
+
+	// changes that are easy to mistake for everyday ones, but undo work or reach outside the plan
+	for _, route := range []string{
+		"PATCH /plans/{planId}/{branch}/unapply",
+		"PATCH /plans/{planId}/{branch}/rewind",
+		"PATCH /plans/{planId}/{branch}/share",
+		"PATCH /plans/{planId}/{branch}/unshare",
+		"DELETE /plans/{planId}",
+		"POST /orgs/webhooks",
+		"DELETE /orgs/webhooks/{webhookId}",
+		"POST /orgs/webhooks/{webhookId}/test",
+	} {
+		if got, ok := scopes[route]; !ok || got != (routeScope{shared.AccessTokenScopeAdmin, true}) {
+			t.Errorf("%s: expected an admin token to be required, got %+v", route, got)
+		}
+	}
+}
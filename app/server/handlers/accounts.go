@@ -4,21 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"strings"
 
 	"github.com/plandex/plandex/shared"
 )
 
 func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for StartTrialHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for StartTrialHandler")
 
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -27,16 +29,16 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
 
 	b, err := shared.GetRandomAlphanumeric(6)
 	if err != nil {
-		log.Printf("Error generating random tag: %v\n", err)
+		logger.Errorf("Error generating random tag: %v", err)
 		http.Error(w, "Error generating random tag: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -52,7 +54,7 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.CreateUser(user, tx)
 
 	if err != nil {
-		log.Printf("Error creating user: %v\n", err)
+		logger.Errorf("Error creating user: %v", err)
 		http.Error(w, "Error creating user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -65,7 +67,7 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	err = tx.QueryRow("INSERT INTO orgs (name, owner_id, is_trial) VALUES ($1, $2, true) RETURNING id", orgName, userId).Scan(&orgId)
 
 	if err != nil {
-		log.Printf("Error creating org: %v\n", err)
+		logger.Errorf("Error creating org: %v", err)
 		http.Error(w, "Error creating org: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -74,7 +76,7 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	orgOwnerRoleId, err := db.GetOrgOwnerRoleId()
 
 	if err != nil {
-		log.Printf("Error getting org owner role: %v\n", err)
+		logger.Errorf("Error getting org owner role: %v", err)
 		http.Error(w, "Error getting org owner role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -82,16 +84,16 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	// insert org user
 	err = db.CreateOrgUser(orgId, userId, orgOwnerRoleId, tx)
 	if err != nil {
-		log.Printf("Error inserting org user: %v\n", err)
+		logger.Errorf("Error inserting org user: %v", err)
 		http.Error(w, "Error inserting org user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// create auth token
-	token, _, err := db.CreateAuthToken(userId, true, tx)
+	token, _, err := db.CreateAuthToken(userId, true, authTokenClient(r), tx)
 
 	if err != nil {
-		log.Printf("Error creating auth token: %v\n", err)
+		logger.Errorf("Error creating auth token: %v", err)
 		http.Error(w, "Error creating auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -99,7 +101,7 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	// commit transaction
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -116,23 +118,25 @@ func StartTrialHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully started trial")
+	logger.Info("Successfully started trial")
 
 	w.Write(bytes)
 }
 
 func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateAccountHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateAccountHandler")
 
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -140,7 +144,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	var req shared.CreateAccountRequest
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -149,7 +153,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	emailVerificationId, err := db.ValidateEmailVerification(req.Email, req.Pin)
 
 	if err != nil {
-		log.Printf("Error validating email verification: %v\n", err)
+		logger.Errorf("Error validating email verification: %v", err)
 		http.Error(w, "Error validating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -157,7 +161,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -166,9 +170,9 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -176,7 +180,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	// create user
 	emailSplit := strings.Split(req.Email, "@")
 	if len(emailSplit) != 2 {
-		log.Printf("Invalid email: %v\n", req.Email)
+		logger.Infof("Invalid email: %v", req.Email)
 		http.Error(w, "Invalid email: "+req.Email, http.StatusBadRequest)
 		return
 	}
@@ -191,12 +195,12 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if db.IsNonUniqueErr(err) {
-			log.Printf("User already exists for email: %v\n", req.Email)
+			logger.Infof("User already exists for email: %v", req.Email)
 			http.Error(w, "User already exists for email: "+req.Email, http.StatusConflict)
 			return
 		}
 
-		log.Printf("Error creating user: %v\n", err)
+		logger.Errorf("Error creating user: %v", err)
 		http.Error(w, "Error creating user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -204,10 +208,10 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	userId := user.Id
 
 	// create auth token
-	token, authTokenId, err := db.CreateAuthToken(userId, false, tx)
+	token, authTokenId, err := db.CreateAuthToken(userId, false, authTokenClient(r), tx)
 
 	if err != nil {
-		log.Printf("Error creating auth token: %v\n", err)
+		logger.Errorf("Error creating auth token: %v", err)
 		http.Error(w, "Error creating auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -216,7 +220,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = tx.Exec("UPDATE email_verifications SET user_id = $1, auth_token_id = $2 WHERE id = $3", userId, authTokenId, emailVerificationId)
 
 	if err != nil {
-		log.Printf("Error updating email verification: %v\n", err)
+		logger.Errorf("Error updating email verification: %v", err)
 		http.Error(w, "Error updating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -225,7 +229,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	org, err := db.GetOrgForDomain(domain)
 
 	if err != nil {
-		log.Printf("Error getting org for domain: %v\n", err)
+		logger.Errorf("Error getting org for domain: %v", err)
 		http.Error(w, "Error getting org for domain: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -235,7 +239,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 		orgOwnerRoleId, err := db.GetOrgOwnerRoleId()
 
 		if err != nil {
-			log.Printf("Error getting org owner role: %v\n", err)
+			logger.Errorf("Error getting org owner role: %v", err)
 			http.Error(w, "Error getting org owner role: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -243,7 +247,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 		err = db.CreateOrgUser(org.Id, userId, orgOwnerRoleId, tx)
 
 		if err != nil {
-			log.Printf("Error adding org user: %v\n", err)
+			logger.Errorf("Error adding org user: %v", err)
 			http.Error(w, "Error adding org user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -252,7 +256,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	// commit transaction
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -261,7 +265,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	orgs, err := db.GetAccessibleOrgsForUser(&user)
 
 	if err != nil {
-		log.Printf("Error getting orgs for user: %v\n", err)
+		logger.Errorf("Error getting orgs for user: %v", err)
 		http.Error(w, "Error getting orgs for user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -282,18 +286,20 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully created account")
+	logger.Info("Successfully created account")
 
 	w.Write(bytes)
 }
 
 func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ConvertTrialHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ConvertTrialHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -301,7 +307,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !auth.User.IsTrial {
-		log.Println("Trial isn't active")
+		logger.Info("Trial isn't active")
 		http.Error(w, "Trial isn't active", http.StatusBadRequest)
 		return
 	}
@@ -309,7 +315,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	var req shared.ConvertTrialRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -317,14 +323,14 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	emailVerificationId, err := db.ValidateEmailVerification(req.Email, req.Pin)
 
 	if err != nil {
-		log.Printf("Error validating email verification: %v\n", err)
+		logger.Errorf("Error validating email verification: %v", err)
 		http.Error(w, "Error validating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	emailSplit := strings.Split(req.Email, "@")
 	if len(emailSplit) != 2 {
-		log.Printf("Invalid email: %v\n", req.Email)
+		logger.Infof("Invalid email: %v", req.Email)
 		http.Error(w, "Invalid email: "+req.Email, http.StatusBadRequest)
 		return
 	}
@@ -332,7 +338,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	var domain *string
 	if req.OrgAutoAddDomainUsers {
 		if shared.IsEmailServiceDomain(userDomain) {
-			log.Printf("Invalid domain: %v\n", userDomain)
+			logger.Infof("Invalid domain: %v", userDomain)
 			http.Error(w, "Invalid domain: "+userDomain, http.StatusBadRequest)
 			return
 		}
@@ -343,7 +349,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -352,9 +358,9 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -363,7 +369,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = db.Conn.Exec("UPDATE auth_tokens SET deleted_at = NOW() WHERE token_hash = $1", auth.AuthToken.TokenHash)
 
 	if err != nil {
-		log.Printf("Error deleting auth token: %v\n", err)
+		logger.Errorf("Error deleting auth token: %v", err)
 		http.Error(w, "Error deleting auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -372,20 +378,20 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if db.IsNonUniqueErr(err) {
-			log.Printf("User already exists for email: %v\n", req.Email)
+			logger.Infof("User already exists for email: %v", req.Email)
 			http.Error(w, "User already exists for email: "+req.Email, http.StatusConflict)
 			return
 		}
-		log.Printf("Error updating user: %v\n", err)
+		logger.Errorf("Error updating user: %v", err)
 		http.Error(w, "Error updating user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// create auth token
-	token, authTokenId, err := db.CreateAuthToken(auth.User.Id, false, tx)
+	token, authTokenId, err := db.CreateAuthToken(auth.User.Id, false, authTokenClient(r), tx)
 
 	if err != nil {
-		log.Printf("Error creating auth token: %v\n", err)
+		logger.Errorf("Error creating auth token: %v", err)
 		http.Error(w, "Error creating auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -394,7 +400,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = tx.Exec("UPDATE email_verifications SET user_id = $1, auth_token_id = $2 WHERE id = $3", auth.User.Id, authTokenId, emailVerificationId)
 
 	if err != nil {
-		log.Printf("Error updating email verification: %v\n", err)
+		logger.Errorf("Error updating email verification: %v", err)
 		http.Error(w, "Error updating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -404,11 +410,11 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		if db.IsNonUniqueErr(err) {
-			log.Printf("Org already exists for domain: %v\n", userDomain)
+			logger.Infof("Org already exists for domain: %v", userDomain)
 			http.Error(w, "Org already exists for domain: "+userDomain, http.StatusConflict)
 			return
 		}
-		log.Printf("Error updating org: %v\n", err)
+		logger.Errorf("Error updating org: %v", err)
 		http.Error(w, "Error updating org: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -416,7 +422,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	// commit transaction
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -425,7 +431,7 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	orgs, err := db.GetAccessibleOrgsForUser(auth.User)
 
 	if err != nil {
-		log.Printf("Error getting orgs for user: %v\n", err)
+		logger.Errorf("Error getting orgs for user: %v", err)
 		http.Error(w, "Error getting orgs for user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -446,12 +452,12 @@ func ConvertTrialHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully converted trial")
+	logger.Info("Successfully converted trial")
 
 	w.Write(bytes)
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/types"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func ListAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListAuditEventsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if !auth.HasPermission(types.PermissionReadAuditLogs) {
+		logger.Info("User cannot read audit logs")
+		http.Error(w, "User cannot read audit logs", http.StatusForbidden)
+		return
+	}
+
+	params, err := shared.ParseListAuditEventsParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := db.ListAuditEvents(auth.OrgId, params)
+
+	if err != nil {
+		logger.Errorf("Error listing audit events: %v", err)
+		http.Error(w, "Error listing audit events: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiEvents := []*shared.AuditEvent{}
+	for _, event := range events {
+		apiEvents = append(apiEvents, event.ToApi())
+	}
+
+	bytes, err := json.Marshal(apiEvents)
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully listed %d audit events", len(apiEvents))
+
+	w.Write(bytes)
+}
+
+// auditEvent describes an action taken by the authenticated user in their org, for db.CreateAuditEvent to record in the same transaction as the change
+func auditEvent(r *http.Request, auth *types.ServerAuth, action shared.AuditAction, resourceId string, details map[string]string) *db.AuditEvent {
+	event := newAuditEvent(r, auth.OrgId, auth.User, action, resourceId, details)
+	if auth.AccessToken != nil {
+		event.AccessTokenId = &auth.AccessToken.Id
+	}
+	return event
+}
+
+func newAuditEvent(r *http.Request, orgId string, user *db.User, action shared.AuditAction, resourceId string, details map[string]string) *db.AuditEvent {
+	event := &db.AuditEvent{
+		OrgId:      orgId,
+		ActorId:    user.Id,
+		ActorEmail: user.Email,
+		Action:     action,
+		Details:    details,
+	}
+	if resourceId != "" {
+		event.ResourceId = &resourceId
+	}
+	if ip := authTokenClient(r).Ip; ip != "" {
+		event.Ip = &ip
+	}
+	return event
+}
+
+// recordAuditEvent records an action that has already been made. A failure is logged rather than failing the request, since the change can't be undone at this point.
+func recordAuditEvent(r *http.Request, auth *types.ServerAuth, action shared.AuditAction, resourceId string, details map[string]string) {
+	err := db.CreateAuditEvent(auditEvent(r, auth, action, resourceId, details), nil)
+
+	if err != nil {
+		logging.Ctx(r.Context()).Errorf("Error recording %s audit event: %v", action, err)
+	}
+}
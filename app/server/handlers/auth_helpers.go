@@ -3,28 +3,35 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/types"
 	"strings"
+	"sync"
 
 	"github.com/plandex/plandex/shared"
 )
 
 func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *types.ServerAuth {
-	log.Println("authenticating request")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("authenticating request")
 
 	authHeader := r.Header.Get("Authorization")
 
 	if authHeader == "" {
-		log.Println("no auth header")
+		logger.Info("no auth header")
 		http.Error(w, "no auth header", http.StatusUnauthorized)
 		return nil
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		log.Println("invalid auth header")
+		logger.Info("invalid auth header")
 		http.Error(w, "invalid auth header", http.StatusUnauthorized)
 		return nil
 	}
@@ -36,7 +43,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 	bytes, err := base64.StdEncoding.DecodeString(encoded)
 
 	if err != nil {
-		log.Printf("error decoding auth token: %v\n", err)
+		logger.Errorf("error decoding auth token: %v", err)
 		http.Error(w, "error decoding auth token", http.StatusUnauthorized)
 		return nil
 	}
@@ -46,16 +53,20 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 	err = json.Unmarshal(bytes, &parsed)
 
 	if err != nil {
-		log.Printf("error parsing auth token: %v\n", err)
+		logger.Errorf("error parsing auth token: %v", err)
 		http.Error(w, "error parsing auth token", http.StatusUnauthorized)
 		return nil
 	}
 
+	if strings.HasPrefix(parsed.Token, shared.AccessTokenPrefix) {
+		return authenticateAccessToken(w, r, parsed)
+	}
+
 	// validate the token
 	authToken, err := db.ValidateAuthToken(parsed.Token)
 
 	if err != nil {
-		log.Printf("error validating auth token: %v\n", err)
+		logger.Errorf("error validating auth token: %v", err)
 
 		writeApiError(w, shared.ApiError{
 			Type:   shared.ApiErrorTypeInvalidToken,
@@ -65,14 +76,23 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 		return nil
 	}
 
+	// a failure here shouldn't fail the request--it only affects what 'plandex sessions' shows
+	err = db.TouchAuthToken(authToken, authTokenClient(r))
+
+	if err != nil {
+		logger.Warnf("error recording auth token use: %v", err)
+	}
+
 	user, err := db.GetUser(authToken.UserId)
 
 	if err != nil {
-		log.Printf("error getting user: %v\n", err)
+		logger.Errorf("error getting user: %v", err)
 		http.Error(w, "error getting user", http.StatusInternalServerError)
 		return nil
 	}
 
+	logging.Add(r.Context(), "user_id", user.Id)
+
 	if !requireOrg {
 		return &types.ServerAuth{
 			AuthToken: authToken,
@@ -81,7 +101,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 	}
 
 	if parsed.OrgId == "" {
-		log.Println("no org id")
+		logger.Info("no org id")
 		http.Error(w, "no org id", http.StatusUnauthorized)
 		return nil
 	}
@@ -90,7 +110,7 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 	isMember, err := db.ValidateOrgMembership(authToken.UserId, parsed.OrgId)
 
 	if err != nil {
-		log.Printf("error validating org membership: %v\n", err)
+		logger.Errorf("error validating org membership: %v", err)
 		http.Error(w, "error validating org membership", http.StatusInternalServerError)
 		return nil
 	}
@@ -100,45 +120,47 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 		invite, err := db.GetActiveInviteByEmail(parsed.OrgId, user.Email)
 
 		if err != nil {
-			log.Printf("error getting invite for org user: %v\n", err)
+			logger.Errorf("error getting invite for org user: %v", err)
 			http.Error(w, "error getting invite for org user", http.StatusInternalServerError)
 			return nil
 		}
 
 		if invite != nil {
-			log.Println("accepting invite")
+			logger.Info("accepting invite")
 
 			err := db.AcceptInvite(invite, authToken.UserId)
 
 			if err != nil {
-				log.Printf("error accepting invite: %v\n", err)
+				logger.Errorf("error accepting invite: %v", err)
 				http.Error(w, "error accepting invite", http.StatusInternalServerError)
 				return nil
 			}
 
+			err = db.CreateAuditEvent(newAuditEvent(r, parsed.OrgId, user, shared.AuditActionInviteAccept, invite.Id, map[string]string{"orgRoleId": invite.OrgRoleId}), nil)
+
+			if err != nil {
+				logger.Errorf("error recording invite accept audit event: %v", err)
+			}
+
 		} else {
-			log.Println("user is not a member of the org")
+			logger.Info("user is not a member of the org")
 			http.Error(w, "not a member of org", http.StatusUnauthorized)
 			return nil
 		}
 	}
 
 	// get user permissions
-	permissions, err := db.GetUserPermissions(authToken.UserId, parsed.OrgId)
+	permissionsMap, err := getPermissionsMap(authToken.UserId, parsed.OrgId)
 
 	if err != nil {
-		log.Printf("error getting user permissions: %v\n", err)
+		logger.Errorf("error getting user permissions: %v", err)
 		http.Error(w, "error getting user permissions", http.StatusInternalServerError)
 		return nil
 	}
 
-	// build the permissions map
-	permissionsMap := make(map[types.Permission]bool)
-	for _, permission := range permissions {
-		permissionsMap[types.Permission(permission)] = true
-	}
+	logging.Add(r.Context(), "org_id", parsed.OrgId)
 
-	log.Printf("UserId: %s, Email: %s, OrgId: %s\n", authToken.UserId, user.Email, parsed.OrgId)
+	logger.Infof("UserId: %s, Email: %s, OrgId: %s", authToken.UserId, user.Email, parsed.OrgId)
 
 	return &types.ServerAuth{
 		AuthToken:   authToken,
@@ -149,6 +171,174 @@ func authenticate(w http.ResponseWriter, r *http.Request, requireOrg bool) *type
 
 }
 
+// authenticateAccessToken authenticates requests made with an access token rather than a sign-in token. The token determines the org, and its scope determines which routes it can be used for and which of the user's permissions apply.
+func authenticateAccessToken(w http.ResponseWriter, r *http.Request, parsed shared.AuthHeader) *types.ServerAuth {
+	logger := logging.Ctx(r.Context())
+
+	accessToken, err := db.ValidateAccessToken(parsed.Token)
+
+	if err != nil {
+		logger.Errorf("error validating access token: %v", err)
+
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeInvalidToken,
+			Status: http.StatusUnauthorized,
+			Msg:    "Invalid access token",
+		})
+		return nil
+	}
+
+	logging.Add(r.Context(), "user_id", accessToken.UserId, "org_id", accessToken.OrgId, "access_token_id", accessToken.Id)
+
+	if parsed.OrgId != "" && parsed.OrgId != accessToken.OrgId {
+		logger.Info("access token org doesn't match requested org")
+		http.Error(w, "access token doesn't belong to this org", http.StatusUnauthorized)
+		return nil
+	}
+
+	requiredScope, allowed := accessTokenRouteScope(r)
+
+	if !allowed {
+		logger.Info("access tokens can't be used for this route")
+		http.Error(w, "access tokens can't be used for this request--sign in instead", http.StatusForbidden)
+		return nil
+	}
+
+	if !accessToken.Scope.Includes(requiredScope) {
+		logger.Infof("access token has %s scope, %s required", accessToken.Scope, requiredScope)
+		http.Error(w, fmt.Sprintf("this request needs an access token with %s scope", requiredScope), http.StatusForbidden)
+		return nil
+	}
+
+	user, err := db.GetUser(accessToken.UserId)
+
+	if err != nil {
+		logger.Errorf("error getting user: %v", err)
+		http.Error(w, "error getting user", http.StatusInternalServerError)
+		return nil
+	}
+
+	// the user may have left the org since creating the token
+	isMember, err := db.ValidateOrgMembership(accessToken.UserId, accessToken.OrgId)
+
+	if err != nil {
+		logger.Errorf("error validating org membership: %v", err)
+		http.Error(w, "error validating org membership", http.StatusInternalServerError)
+		return nil
+	}
+
+	if !isMember {
+		logger.Info("access token user is no longer a member of the org")
+		http.Error(w, "not a member of org", http.StatusUnauthorized)
+		return nil
+	}
+
+	permissionsMap, err := getPermissionsMap(accessToken.UserId, accessToken.OrgId)
+
+	if err != nil {
+		logger.Errorf("error getting user permissions: %v", err)
+		http.Error(w, "error getting user permissions", http.StatusInternalServerError)
+		return nil
+	}
+
+	logger.Infof("UserId: %s, AccessTokenId: %s, Scope: %s, OrgId: %s", accessToken.UserId, accessToken.Id, accessToken.Scope, accessToken.OrgId)
+
+	return &types.ServerAuth{
+		AccessToken: accessToken,
+		User:        user,
+		OrgId:       accessToken.OrgId,
+		Permissions: types.AccessTokenPermissions(accessToken.Scope, permissionsMap),
+	}
+}
+
+// authTokenClient describes the client making the request for session management. X-Forwarded-For is only used when the request comes from a proxy in TRUSTED_PROXIES, since anyone can set it otherwise--and then only the entries added by trusted proxies are believed.
+func authTokenClient(r *http.Request) db.AuthTokenClient {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" && isTrustedProxy(ip) {
+		// walk back from the nearest proxy to the first address a trusted proxy didn't add
+		parts := strings.Split(forwarded, ",")
+		for i := len(parts) - 1; i >= 0; i-- {
+			ip = strings.TrimSpace(parts[i])
+			if !isTrustedProxy(ip) {
+				break
+			}
+		}
+	}
+
+	version := r.Header.Get(shared.ClientVersionHeader)
+	if len(version) > 64 {
+		version = version[:64]
+	}
+	if len(ip) > 64 {
+		ip = ip[:64]
+	}
+
+	return db.AuthTokenClient{
+		Version: version,
+		Ip:      ip,
+	}
+}
+
+var trustedProxies []*net.IPNet
+var trustedProxiesOnce sync.Once
+
+// isTrustedProxy is true if ip is in TRUSTED_PROXIES, a comma-separated list of IPs and CIDRs
+func isTrustedProxy(ip string) bool {
+	trustedProxiesOnce.Do(func() {
+		for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if !strings.Contains(entry, "/") {
+				if strings.Contains(entry, ":") {
+					entry += "/128"
+				} else {
+					entry += "/32"
+				}
+			}
+
+			_, ipNet, err := net.ParseCIDR(entry)
+			if err != nil {
+				log.Printf("Ignoring invalid TRUSTED_PROXIES entry %q: %v\n", entry, err)
+				continue
+			}
+			trustedProxies = append(trustedProxies, ipNet)
+		}
+	})
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func getPermissionsMap(userId, orgId string) (map[types.Permission]bool, error) {
+	permissions, err := db.GetUserPermissions(userId, orgId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissionsMap := make(map[types.Permission]bool)
+	for _, permission := range permissions {
+		permissionsMap[types.Permission(permission)] = true
+	}
+
+	return permissionsMap, nil
+}
+
 func authorizeProject(w http.ResponseWriter, projectId string, auth *types.ServerAuth) bool {
 	return authorizeProjectOptional(w, projectId, auth, true)
 }
@@ -156,6 +346,14 @@ func authorizeProject(w http.ResponseWriter, projectId string, auth *types.Serve
 func authorizeProjectOptional(w http.ResponseWriter, projectId string, auth *types.ServerAuth, shouldErr bool) bool {
 	log.Println("authorizing project")
 
+	if !auth.CanAccessProject(projectId) {
+		log.Println("access token isn't allowed to use project")
+		if shouldErr {
+			http.Error(w, "access token isn't allowed to use this project", http.StatusForbidden)
+		}
+		return false
+	}
+
 	projectExists, err := db.ProjectExists(auth.OrgId, projectId)
 
 	if err != nil {
@@ -178,7 +376,7 @@ func authorizeProjectRename(w http.ResponseWriter, projectId string, auth *types
 		return false
 	}
 
-	if !auth.HasPermission(types.PermissionRenameAnyProject) {
+	if !auth.HasProjectPermission(types.PermissionRenameAnyProject, projectId) {
 		log.Println("User does not have permission to rename project")
 		http.Error(w, "User does not have permission to rename project", http.StatusForbidden)
 		return false
@@ -192,7 +390,7 @@ func authorizeProjectDelete(w http.ResponseWriter, projectId string, auth *types
 		return false
 	}
 
-	if !auth.HasPermission(types.PermissionDeleteAnyProject) {
+	if !auth.HasProjectPermission(types.PermissionDeleteAnyProject, projectId) {
 		log.Println("User does not have permission to delete project")
 		http.Error(w, "User does not have permission to delete project", http.StatusForbidden)
 		return false
@@ -218,6 +416,90 @@ func authorizePlan(w http.ResponseWriter, planId string, auth *types.ServerAuth)
 		return nil
 	}
 
+	if !auth.CanAccessProject(plan.ProjectId) {
+		log.Println("access token isn't allowed to use the plan's project")
+		http.Error(w, "access token isn't allowed to use this plan's project", http.StatusForbidden)
+		return nil
+	}
+
+	return plan
+}
+
+// authorizePlanBranch authorizes reading a branch. Other org members can only read a plan's branches that have been shared with the org.
+func authorizePlanBranch(w http.ResponseWriter, planId, branch string, auth *types.ServerAuth) *db.Plan {
+	plan := authorizePlan(w, planId, auth)
+
+	if plan == nil {
+		return nil
+	}
+
+	if plan.OwnerId == auth.User.Id {
+		return plan
+	}
+
+	dbBranch, err := db.GetDbBranch(planId, branch)
+
+	if err != nil {
+		log.Printf("error getting branch: %v\n", err)
+		http.Error(w, "error getting branch", http.StatusInternalServerError)
+		return nil
+	}
+
+	if dbBranch == nil || dbBranch.SharedWithOrgAt == nil {
+		log.Println("branch isn't shared with the org")
+		http.Error(w, "no access to branch", http.StatusUnauthorized)
+		return nil
+	}
+
+	return plan
+}
+
+// authorizePlanBranchUpdate authorizes changing a branch--sending prompts, loading context, applying, rejecting, rewinding, and so on. Sharing a branch only makes it readable, so other org members also need the update_any_plan permission.
+func authorizePlanBranchUpdate(w http.ResponseWriter, planId, branch string, auth *types.ServerAuth) *db.Plan {
+	plan := authorizePlanBranch(w, planId, branch, auth)
+
+	if plan == nil {
+		return nil
+	}
+
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionUpdateAnyPlan, plan.ProjectId) {
+		log.Println("User does not have permission to update plan")
+		http.Error(w, "User does not have permission to update plan", http.StatusForbidden)
+		return nil
+	}
+
+	return plan
+}
+
+func authorizePlanShare(w http.ResponseWriter, planId string, auth *types.ServerAuth) *db.Plan {
+	plan := authorizePlan(w, planId, auth)
+
+	if plan == nil {
+		return nil
+	}
+
+	if plan.OwnerId != auth.User.Id {
+		log.Println("Only the plan owner can share a plan")
+		http.Error(w, "Only the plan owner can share a plan", http.StatusForbidden)
+		return nil
+	}
+
+	return plan
+}
+
+func authorizePlanUnshare(w http.ResponseWriter, planId string, auth *types.ServerAuth) *db.Plan {
+	plan := authorizePlan(w, planId, auth)
+
+	if plan == nil {
+		return nil
+	}
+
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionManageAnyPlanShares, plan.ProjectId) {
+		log.Println("User does not have permission to unshare plan")
+		http.Error(w, "User does not have permission to unshare plan", http.StatusForbidden)
+		return nil
+	}
+
 	return plan
 }
 
@@ -228,7 +510,7 @@ func authorizePlanUpdate(w http.ResponseWriter, planId string, auth *types.Serve
 		return nil
 	}
 
-	if plan.OwnerId != auth.User.Id && !auth.HasPermission(types.PermissionUpdateAnyPlan) {
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionUpdateAnyPlan, plan.ProjectId) {
 		log.Println("User does not have permission to update plan")
 		http.Error(w, "User does not have permission to update plan", http.StatusForbidden)
 		return nil
@@ -244,7 +526,7 @@ func authorizePlanDelete(w http.ResponseWriter, planId string, auth *types.Serve
 		return nil
 	}
 
-	if plan.OwnerId != auth.User.Id && !auth.HasPermission(types.PermissionDeleteAnyPlan) {
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionDeleteAnyPlan, plan.ProjectId) {
 		log.Println("User does not have permission to delete plan")
 		http.Error(w, "User does not have permission to delete plan", http.StatusForbidden)
 		return nil
@@ -260,7 +542,7 @@ func authorizePlanRename(w http.ResponseWriter, planId string, auth *types.Serve
 		return nil
 	}
 
-	if plan.OwnerId != auth.User.Id && !auth.HasPermission(types.PermissionRenameAnyPlan) {
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionRenameAnyPlan, plan.ProjectId) {
 		log.Println("User does not have permission to rename plan")
 		http.Error(w, "User does not have permission to rename plan", http.StatusForbidden)
 		return nil
@@ -276,7 +558,7 @@ func authorizePlanArchive(w http.ResponseWriter, planId string, auth *types.Serv
 		return nil
 	}
 
-	if plan.OwnerId != auth.User.Id && !auth.HasPermission(types.PermissionArchiveAnyPlan) {
+	if plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionArchiveAnyPlan, plan.ProjectId) {
 		log.Println("User does not have permission to archive plan")
 		http.Error(w, "User does not have permission to archive plan", http.StatusForbidden)
 		return nil
@@ -4,16 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func ListBranchesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListBranchesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListBranchesHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -23,9 +25,10 @@ func ListBranchesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	plan := authorizePlan(w, planId, auth)
+	if plan == nil {
 		return
 	}
 
@@ -44,26 +47,39 @@ func ListBranchesHandler(w http.ResponseWriter, r *http.Request) {
 	branches, err := db.ListPlanBranches(auth.OrgId, planId)
 
 	if err != nil {
-		log.Printf("Error getting branches: %v\n", err)
+		logger.Errorf("Error getting branches: %v", err)
 		http.Error(w, "Error getting branches: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// other org members only see shared branches
+	if plan.OwnerId != auth.User.Id {
+		var sharedBranches []*db.Branch
+		for _, branch := range branches {
+			if branch.SharedWithOrgAt != nil {
+				sharedBranches = append(sharedBranches, branch)
+			}
+		}
+		branches = sharedBranches
+	}
+
 	jsonBytes, err := json.Marshal(branches)
 
 	if err != nil {
-		log.Printf("Error marshalling branches: %v\n", err)
+		logger.Errorf("Error marshalling branches: %v", err)
 		http.Error(w, "Error marshalling branches: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully retrieved branches")
+	logger.Info("Successfully retrieved branches")
 
 	w.Write(jsonBytes)
 }
 
 func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateBranchHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateBranchHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -74,27 +90,27 @@ func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 	defer func() {
-		log.Println("Closing request body")
+		logger.Info("Closing request body")
 		r.Body.Close()
 	}()
 
 	var req shared.CreateBranchRequest
 	if err := json.Unmarshal(body, &req); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body ", http.StatusBadRequest)
 		return
 	}
@@ -102,7 +118,7 @@ func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 	parentBranch, err := db.GetDbBranch(planId, branch)
 
 	if err != nil {
-		log.Printf("Error getting parent branch: %v\n", err)
+		logger.Errorf("Error getting parent branch: %v", err)
 		http.Error(w, "Error getting parent branch: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -119,7 +135,7 @@ func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -128,9 +144,9 @@ func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -138,23 +154,25 @@ func CreateBranchHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = db.CreateBranch(plan, parentBranch, req.Name, tx)
 
 	if err != nil {
-		log.Printf("Error creating branch: %v\n", err)
+		logger.Errorf("Error creating branch: %v", err)
 		http.Error(w, "Error creating branch: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// commit the transaction
 	if err := tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully created branch")
+	logger.Info("Successfully created branch")
 }
 
 func DeleteBranchHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteBranchHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteBranchHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -165,14 +183,14 @@ func DeleteBranchHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
 	if branch == "main" {
-		log.Println("Cannot delete main branch")
+		logger.Info("Cannot delete main branch")
 		http.Error(w, "Cannot delete main branch", http.StatusBadRequest)
 		return
 	}
@@ -191,7 +209,7 @@ func DeleteBranchHandler(w http.ResponseWriter, r *http.Request) {
 	)
 
 	if err != nil {
-		log.Printf("Error locking repo: %v\n", err)
+		logger.Errorf("Error locking repo: %v", err)
 		http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -199,17 +217,19 @@ func DeleteBranchHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		err := db.DeleteRepoLock(repoLockId)
 		if err != nil {
-			log.Printf("Error unlocking repo: %v\n", err)
+			logger.Errorf("Error unlocking repo: %v", err)
 		}
 	}()
 
 	err = db.DeleteBranch(auth.OrgId, planId, branch)
 
 	if err != nil {
-		log.Printf("Error deleting branch: %v\n", err)
+		logger.Errorf("Error deleting branch: %v", err)
 		http.Error(w, "Error deleting branch: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted branch")
+	recordAuditEvent(r, auth, shared.AuditActionBranchDelete, planId, map[string]string{"branch": branch})
+
+	logger.Info("Successfully deleted branch")
 }
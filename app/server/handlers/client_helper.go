@@ -5,8 +5,6 @@ import (
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/model"
-
-	"github.com/sashabaranov/go-openai"
 )
 
 type initClientsParams struct {
@@ -19,7 +17,7 @@ type initClientsParams struct {
 	plan        *db.Plan
 }
 
-func initClients(params initClientsParams) map[string]*openai.Client {
+func initClients(params initClientsParams) map[string]*model.Client {
 	w := params.w
 	apiKey := params.apiKey
 	apiKeys := params.apiKeys
@@ -4,27 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/model"
 	"plandex-server/types"
 
 	"github.com/plandex/plandex/shared"
-	"github.com/sashabaranov/go-openai"
 )
 
 func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, loadReq *shared.LoadContextRequest, plan *db.Plan, branchName string) (*shared.LoadContextResponse, []*db.Context) {
+	logger := logging.Ctx(r.Context())
+
 	var err error
 	var settings *shared.PlanSettings
-	var client *openai.Client
+	var client *model.Client
 
 	for _, context := range *loadReq {
 		if context.ContextType == shared.ContextPipedDataType || context.ContextType == shared.ContextNoteType || context.ContextType == shared.ContextImageType {
 			settings, err = db.GetPlanSettings(plan, true)
 
 			if err != nil {
-				log.Printf("Error getting plan settings: %v\n", err)
+				logger.Errorf("Error getting plan settings: %v", err)
 				http.Error(w, "Error getting plan settings: "+err.Error(), http.StatusInternalServerError)
 				return nil, nil
 			}
@@ -50,7 +51,7 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 	for _, context := range *loadReq {
 		if context.ContextType == shared.ContextImageType {
 			if !settings.ModelPack.Planner.BaseModelConfig.HasImageSupport {
-				log.Printf("Error loading context: %s does not support images in context\n", settings.ModelPack.Planner.BaseModelConfig.ModelName)
+				logger.Errorf("Error loading context: %s does not support images in context", settings.ModelPack.Planner.BaseModelConfig.ModelName)
 				http.Error(w, fmt.Sprintf("Error loading context: %s does not support images in context", settings.ModelPack.Planner.BaseModelConfig.ModelName), http.StatusBadRequest)
 				return nil, nil
 			}
@@ -93,7 +94,7 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 		for i := 0; i < num; i++ {
 			err := <-errCh
 			if err != nil {
-				log.Printf("Error: %v\n", err)
+				logger.Errorf("Error: %v", err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return nil, nil
 			}
@@ -119,17 +120,17 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 	})
 
 	if err != nil {
-		log.Printf("Error loading contexts: %v\n", err)
+		logger.Errorf("Error loading contexts: %v", err)
 		http.Error(w, "Error loading contexts: "+err.Error(), http.StatusInternalServerError)
 		return nil, nil
 	}
 
 	if res.MaxTokensExceeded {
-		log.Printf("The total number of tokens (%d) exceeds the maximum allowed (%d)", res.TotalTokens, res.MaxTokens)
+		logger.Infof("The total number of tokens (%d) exceeds the maximum allowed (%d)", res.TotalTokens, res.MaxTokens)
 		bytes, err := json.Marshal(res)
 
 		if err != nil {
-			log.Printf("Error marshalling response: %v\n", err)
+			logger.Errorf("Error marshalling response: %v", err)
 			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 			return nil, nil
 		}
@@ -141,7 +142,7 @@ func loadContexts(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth
 	err = db.GitAddAndCommit(auth.OrgId, plan.Id, branchName, res.Msg)
 
 	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
+		logger.Errorf("Error committing changes: %v", err)
 		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
 		return nil, nil
 	}
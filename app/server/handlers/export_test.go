@@ -0,0 +1,4 @@
+package handlers
+
+// exported for tests that need the full router, which imports this package
+var AccessTokenRouteScope = accessTokenRouteScope
@@ -6,19 +6,23 @@ import (
 	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/types"
 	"runtime/debug"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, scope db.LockScope, ctx context.Context, cancelFn context.CancelFunc, requireBranch bool) *func(err error) {
+	logger := logging.Ctx(r.Context())
+
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
 
 	if requireBranch && branch == "" {
-		log.Println("Branch not specified")
+		logger.Info("Branch not specified")
 		http.Error(w, "Branch not specified", http.StatusBadRequest)
 		return nil
 	}
@@ -30,25 +34,25 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 			PlanId:   planId,
 			Branch:   branch,
 			Scope:    scope,
-			Ctx:      ctx,
+			Ctx:      trace.ContextWithSpan(ctx, trace.SpanFromContext(r.Context())),
 			CancelFn: cancelFn,
 		},
 	)
 
 	if err != nil {
-		log.Printf("Error locking repo: %v\n", err)
+		logger.Errorf("Error locking repo: %v", err)
 		http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
 		return nil
 	}
 
 	fn := func(err error) {
-		log.Println("Unlocking repo in deferred unlock function")
-		log.Printf("err: %v\n", err)
+		logger.Info("Unlocking repo in deferred unlock function")
+		logger.Errorf("err: %v", err)
 
 		if r := recover(); r != nil {
 			stackTrace := debug.Stack()
-			log.Printf("Recovered from panic: %v\n", r)
-			log.Printf("Stack trace: %s\n", stackTrace)
+			logger.Errorf("Recovered from panic: %v", r)
+			logger.Errorf("Stack trace: %s", stackTrace)
 			err = fmt.Errorf("server panic: %v", r)
 			http.Error(w, "Error locking repo: "+err.Error(), http.StatusInternalServerError)
 		}
@@ -56,12 +60,12 @@ func lockRepo(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, sc
 		// log.Println("Rolling back repo if error")
 		err = RollbackRepoIfErr(auth.OrgId, planId, err)
 		if err != nil {
-			log.Printf("Error rolling back repo: %v\n", err)
+			logger.Errorf("Error rolling back repo: %v", err)
 		}
 
 		err = db.DeleteRepoLock(repoLockId)
 		if err != nil {
-			log.Printf("Error unlocking repo: %v\n", err)
+			logger.Errorf("Error unlocking repo: %v", err)
 		}
 	}
 
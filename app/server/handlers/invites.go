@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/email"
+	"plandex-server/logging"
 	"plandex-server/types"
 	"strings"
 
@@ -14,7 +14,9 @@ import (
 )
 
 func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for InviteUserHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for InviteUserHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -35,7 +37,7 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	var req shared.InviteRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -45,7 +47,7 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	permission := types.Permission(strings.Join([]string{string(types.PermissionInviteUser), req.OrgRoleId}, "|"))
 
 	if !auth.HasPermission(permission) {
-		log.Printf("User does not have permission to invite user with role: %v\n", req.OrgRoleId)
+		logger.Infof("User does not have permission to invite user with role: %v", req.OrgRoleId)
 		http.Error(w, "User does not have permission to invite user with role: "+req.OrgRoleId, http.StatusForbidden)
 		return
 	}
@@ -53,7 +55,7 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// ensure user doesn't already have access to org via domain
 	split := strings.Split(req.Email, "@")
 	if len(split) != 2 {
-		log.Printf("Invalid email: %v\n", req.Email)
+		logger.Infof("Invalid email: %v", req.Email)
 		http.Error(w, "Invalid email: "+req.Email, http.StatusBadRequest)
 		return
 	}
@@ -61,13 +63,13 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	org, err := db.GetOrg(auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error getting org: %v\n", err)
+		logger.Errorf("Error getting org: %v", err)
 		http.Error(w, "Error getting org: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if org.AutoAddDomainUsers && org.Domain == domain {
-		log.Printf("User already has access to org via domain: %v\n", domain)
+		logger.Infof("User already has access to org via domain: %v", domain)
 		http.Error(w, "User already has access to org via domain: "+*domain, http.StatusBadRequest)
 	}
 
@@ -75,7 +77,7 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := db.GetUserByEmail(req.Email)
 
 	if err != nil {
-		log.Printf("Error getting user: %v\n", err)
+		logger.Errorf("Error getting user: %v", err)
 		http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -84,13 +86,13 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 		isMember, err := db.ValidateOrgMembership(user.Id, auth.OrgId)
 
 		if err != nil {
-			log.Printf("Error validating org membership: %v\n", err)
+			logger.Errorf("Error validating org membership: %v", err)
 			http.Error(w, "Error validating org membership: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		if isMember {
-			log.Println("User is already a member of org")
+			logger.Info("User is already a member of org")
 			http.Error(w, "User is already a member of org", http.StatusBadRequest)
 			return
 		}
@@ -100,13 +102,13 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	invite, err := db.GetActiveInviteByEmail(auth.OrgId, req.Email)
 
 	if err != nil {
-		log.Printf("Error getting invite: %v\n", err)
+		logger.Errorf("Error getting invite: %v", err)
 		http.Error(w, "Error getting invite: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if invite != nil {
-		log.Println("Invite already exists")
+		logger.Info("Invite already exists")
 		http.Error(w, "Invite already exists", http.StatusBadRequest)
 		return
 	}
@@ -114,7 +116,7 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -123,31 +125,40 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
 
-	err = db.CreateInvite(&db.Invite{
+	invite = &db.Invite{
 		OrgId:     auth.OrgId,
 		OrgRoleId: req.OrgRoleId,
 		Email:     req.Email,
 		Name:      req.Name,
 		InviterId: currentUserId,
-	}, tx)
+	}
+	err = db.CreateInvite(invite, tx)
 
 	if err != nil {
-		log.Printf("Error creating invite: %v\n", err)
+		logger.Errorf("Error creating invite: %v", err)
 		http.Error(w, "Error creating invite: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionInviteCreate, invite.Id, map[string]string{"email": req.Email, "orgRoleId": req.OrgRoleId}), tx)
+
+	if err != nil {
+		logger.Errorf("Error recording audit event: %v", err)
+		http.Error(w, "Error recording audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	err = email.SendInviteEmail(req.Email, req.Name, auth.User.Name, org.Name)
 
 	if err != nil {
-		log.Printf("Error sending invite email: %v\n", err)
+		logger.Errorf("Error sending invite email: %v", err)
 		http.Error(w, "Error sending invite email: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -155,16 +166,18 @@ func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// commit transaction
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully created invite")
+	logger.Info("Successfully created invite")
 }
 
 func ListPendingInvitesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for ListInvitesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for ListInvitesHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -182,7 +195,7 @@ func ListPendingInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	invites, err := db.ListPendingInvites(auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error listing invites: %v\n", err)
+		logger.Errorf("Error listing invites: %v", err)
 		http.Error(w, "Error listing invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -195,17 +208,19 @@ func ListPendingInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiInvites)
 
 	if err != nil {
-		log.Printf("Error marshalling invites: %v\n", err)
+		logger.Errorf("Error marshalling invites: %v", err)
 		http.Error(w, "Error marshalling invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
-	log.Println("Successfully processed request for ListPendingInvitesHandler")
+	logger.Info("Successfully processed request for ListPendingInvitesHandler")
 }
 
 func ListAcceptedInvitesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for ListAcceptedInvitesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for ListAcceptedInvitesHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -223,7 +238,7 @@ func ListAcceptedInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	invites, err := db.ListAcceptedInvites(auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error listing invites: %v\n", err)
+		logger.Errorf("Error listing invites: %v", err)
 		http.Error(w, "Error listing invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -236,17 +251,19 @@ func ListAcceptedInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiInvites)
 
 	if err != nil {
-		log.Printf("Error marshalling invites: %v\n", err)
+		logger.Errorf("Error marshalling invites: %v", err)
 		http.Error(w, "Error marshalling invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
-	log.Println("Successfully processed request for ListAcceptedInvitesHandler")
+	logger.Info("Successfully processed request for ListAcceptedInvitesHandler")
 }
 
 func ListAllInvitesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for ListAllInvitesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for ListAllInvitesHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -264,7 +281,7 @@ func ListAllInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	invites, err := db.ListAllInvites(auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error listing invites: %v\n", err)
+		logger.Errorf("Error listing invites: %v", err)
 		http.Error(w, "Error listing invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -277,17 +294,19 @@ func ListAllInvitesHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiInvites)
 
 	if err != nil {
-		log.Printf("Error marshalling invites: %v\n", err)
+		logger.Errorf("Error marshalling invites: %v", err)
 		http.Error(w, "Error marshalling invites: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
-	log.Println("Successfully processed request for ListAllInvitesHandler")
+	logger.Info("Successfully processed request for ListAllInvitesHandler")
 }
 
 func DeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for DeleteInviteHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for DeleteInviteHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -308,13 +327,13 @@ func DeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
 	invite, err := db.GetInvite(inviteId)
 
 	if err != nil {
-		log.Printf("Error getting invite: %v\n", err)
+		logger.Errorf("Error getting invite: %v", err)
 		http.Error(w, "Error getting invite: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if invite == nil || invite.OrgId != auth.OrgId {
-		log.Printf("Invite not found: %v\n", inviteId)
+		logger.Warnf("Invite not found: %v", inviteId)
 		http.Error(w, "Invite not found: "+inviteId, http.StatusNotFound)
 		return
 	}
@@ -326,7 +345,7 @@ func DeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
 
 	if !(auth.HasPermission(removePermission) ||
 		(auth.User.Id == invite.InviterId && auth.HasPermission(invitePermission))) {
-		log.Printf("User does not have permission to remove invite with role: %v\n", invite.OrgRoleId)
+		logger.Infof("User does not have permission to remove invite with role: %v", invite.OrgRoleId)
 		http.Error(w, "User does not have permission to remove invite with role: "+invite.OrgRoleId, http.StatusForbidden)
 		return
 	}
@@ -334,10 +353,12 @@ func DeleteInviteHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.DeleteInvite(inviteId, nil)
 
 	if err != nil {
-		log.Printf("Error deleting invite: %v\n", err)
+		logger.Errorf("Error deleting invite: %v", err)
 		http.Error(w, "Error deleting invite: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted invite")
+	recordAuditEvent(r, auth, shared.AuditActionInviteDelete, invite.Id, map[string]string{"email": invite.Email, "orgRoleId": invite.OrgRoleId})
+
+	logger.Info("Successfully deleted invite")
 }
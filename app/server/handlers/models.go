@@ -2,16 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func CreateCustomModelHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateCustomModelHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateCustomModelHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -20,7 +22,7 @@ func CreateCustomModelHandler(w http.ResponseWriter, r *http.Request) {
 
 	var model shared.AvailableModel
 	if err := json.NewDecoder(r.Body).Decode(&model); err != nil {
-		log.Printf("Error decoding request body: %v\n", err)
+		logger.Errorf("Error decoding request body: %v", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
@@ -43,18 +45,20 @@ func CreateCustomModelHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := db.CreateCustomModel(dbModel); err != nil {
-		log.Printf("Error creating custom model: %v\n", err)
+		logger.Errorf("Error creating custom model: %v", err)
 		http.Error(w, "Failed to create custom model: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 
-	log.Println("Successfully created custom model")
+	logger.Info("Successfully created custom model")
 }
 
 func ListCustomModelsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListCustomModelsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListCustomModelsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -63,18 +67,20 @@ func ListCustomModelsHandler(w http.ResponseWriter, r *http.Request) {
 
 	models, err := db.ListCustomModels(auth.OrgId)
 	if err != nil {
-		log.Printf("Error fetching custom models: %v\n", err)
+		logger.Errorf("Error fetching custom models: %v", err)
 		http.Error(w, "Failed to fetch custom models: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	json.NewEncoder(w).Encode(models)
 
-	log.Println("Successfully fetched custom models")
+	logger.Info("Successfully fetched custom models")
 }
 
 func DeleteAvailableModelHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteAvailableModelHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteAvailableModelHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -83,18 +89,20 @@ func DeleteAvailableModelHandler(w http.ResponseWriter, r *http.Request) {
 
 	modelId := mux.Vars(r)["modelId"]
 	if err := db.DeleteAvailableModel(modelId); err != nil {
-		log.Printf("Error deleting custom model: %v\n", err)
+		logger.Errorf("Error deleting custom model: %v", err)
 		http.Error(w, "Failed to delete custom model: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 
-	log.Println("Successfully deleted custom model")
+	logger.Info("Successfully deleted custom model")
 }
 
 func CreateModelPackHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateModelPackHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateModelPackHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -120,18 +128,20 @@ func CreateModelPackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := db.CreateModelPack(dbMs); err != nil {
-		log.Printf("Error creating model pack: %v\n", err)
+		logger.Errorf("Error creating model pack: %v", err)
 		http.Error(w, "Failed to create model pack: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 
-	log.Println("Successfully created model pack")
+	logger.Info("Successfully created model pack")
 }
 
 func ListModelPacksHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListModelPacksHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListModelPacksHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -140,7 +150,7 @@ func ListModelPacksHandler(w http.ResponseWriter, r *http.Request) {
 
 	sets, err := db.ListModelPacks(auth.OrgId)
 	if err != nil {
-		log.Printf("Error fetching model packs: %v\n", err)
+		logger.Errorf("Error fetching model packs: %v", err)
 		http.Error(w, "Failed to fetch model packs: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -153,11 +163,13 @@ func ListModelPacksHandler(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(apiPacks)
 
-	log.Println("Successfully fetched model packs")
+	logger.Info("Successfully fetched model packs")
 }
 
 func DeleteModelPackHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteModelPackHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteModelPackHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -166,15 +178,15 @@ func DeleteModelPackHandler(w http.ResponseWriter, r *http.Request) {
 
 	setId := mux.Vars(r)["setId"]
 
-	log.Printf("Deleting model pack with id: %s\n", setId)
+	logger.Infof("Deleting model pack with id: %s", setId)
 
 	if err := db.DeleteModelPack(setId); err != nil {
-		log.Printf("Error deleting model pack: %v\n", err)
+		logger.Errorf("Error deleting model pack: %v", err)
 		http.Error(w, "Failed to delete model pack: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 
-	log.Println("Successfully deleted model pack")
+	logger.Info("Successfully deleted model pack")
 }
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/types"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+func CreateOrgRoleHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateOrgRoleHandler")
+
+	auth := authorizeManageOrgRoles(w, r)
+	if auth == nil {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.CreateOrgRoleRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Label = strings.TrimSpace(req.Label)
+	if req.Label == "" {
+		req.Label = req.Name
+	}
+
+	err = shared.ValidateCustomRole(req.Name, req.Permissions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeOrgRolePermissions(w, req.Permissions, auth) {
+		return
+	}
+
+	existingId, err := db.GetOrgRoleIdByName(auth.OrgId, req.Name)
+
+	if err != nil {
+		logger.Errorf("Error getting org role: %v", err)
+		http.Error(w, "Error getting org role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if existingId != "" {
+		http.Error(w, "A role named "+req.Name+" already exists", http.StatusConflict)
+		return
+	}
+
+	role := &db.OrgRole{
+		OrgId:       &auth.OrgId,
+		Name:        req.Name,
+		Label:       req.Label,
+		Description: req.Description,
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		logger.Errorf("Error starting transaction: %v", err)
+		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = db.CreateOrgRole(role, req.Permissions, tx)
+
+	if err != nil {
+		logger.Errorf("Error creating org role: %v", err)
+		http.Error(w, "Error creating org role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionRoleCreate, role.Id, map[string]string{"name": role.Name, "permissions": formatAuditPermissions(req.Permissions)}), tx)
+
+	if err != nil {
+		logger.Errorf("Error recording audit event: %v", err)
+		http.Error(w, "Error recording audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Errorf("Error committing transaction: %v", err)
+		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiRole := role.ToApi()
+	apiRole.Permissions = req.Permissions
+
+	bytes, err := json.Marshal(apiRole)
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully created org role %s", role.Id)
+
+	w.Write(bytes)
+}
+
+func UpdateOrgRoleHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UpdateOrgRoleHandler")
+
+	auth := authorizeManageOrgRoles(w, r)
+	if auth == nil {
+		return
+	}
+
+	role := authorizeCustomOrgRole(w, r, auth)
+	if role == nil {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.UpdateOrgRoleRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = shared.ValidateCustomRolePermissions(req.Permissions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !authorizeOrgRolePermissions(w, req.Permissions, auth) {
+		return
+	}
+
+	if label := strings.TrimSpace(req.Label); label != "" {
+		role.Label = label
+	}
+	role.Description = req.Description
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		logger.Errorf("Error starting transaction: %v", err)
+		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = db.UpdateOrgRole(role, req.Permissions, tx)
+
+	if err != nil {
+		logger.Errorf("Error updating org role: %v", err)
+		http.Error(w, "Error updating org role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionRoleUpdate, role.Id, map[string]string{"name": role.Name, "permissions": formatAuditPermissions(req.Permissions)}), tx)
+
+	if err != nil {
+		logger.Errorf("Error recording audit event: %v", err)
+		http.Error(w, "Error recording audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Errorf("Error committing transaction: %v", err)
+		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiRole := role.ToApi()
+	apiRole.Permissions = req.Permissions
+
+	bytes, err := json.Marshal(apiRole)
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully updated org role %s", role.Id)
+
+	w.Write(bytes)
+}
+
+func DeleteOrgRoleHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteOrgRoleHandler")
+
+	auth := authorizeManageOrgRoles(w, r)
+	if auth == nil {
+		return
+	}
+
+	role := authorizeCustomOrgRole(w, r, auth)
+	if role == nil {
+		return
+	}
+
+	numActive, err := db.NumActiveWithRole(auth.OrgId, role.Id)
+
+	if err != nil {
+		logger.Errorf("Error counting users with role: %v", err)
+		http.Error(w, "Error counting users with role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if numActive > 0 {
+		http.Error(w, "Role "+role.Name+" still has users or pending invites--give them another role first", http.StatusConflict)
+		return
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		logger.Errorf("Error starting transaction: %v", err)
+		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = db.DeleteOrgRole(auth.OrgId, role.Id, tx)
+
+	if err != nil {
+		logger.Errorf("Error deleting org role: %v", err)
+		http.Error(w, "Error deleting org role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionRoleDelete, role.Id, map[string]string{"name": role.Name}), tx)
+
+	if err != nil {
+		logger.Errorf("Error recording audit event: %v", err)
+		http.Error(w, "Error recording audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Errorf("Error committing transaction: %v", err)
+		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully deleted org role %s", role.Id)
+}
+
+func authorizeManageOrgRoles(w http.ResponseWriter, r *http.Request) *types.ServerAuth {
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return nil
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't manage org roles",
+		})
+		return nil
+	}
+
+	if !auth.HasPermission(types.PermissionManageOrgRoles) {
+		logging.Ctx(r.Context()).Info("User cannot manage org roles")
+		http.Error(w, "User cannot manage org roles", http.StatusForbidden)
+		return nil
+	}
+
+	return auth
+}
+
+// authorizeCustomOrgRole gets the role from the url, which must be one of the org's custom roles--built-in roles can't be changed
+func authorizeCustomOrgRole(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth) *db.OrgRole {
+	logger := logging.Ctx(r.Context())
+
+	roleId := mux.Vars(r)["roleId"]
+
+	role, err := db.GetOrgRole(auth.OrgId, roleId)
+
+	if err != nil {
+		logger.Errorf("Error getting org role: %v", err)
+		http.Error(w, "Error getting org role: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	if role == nil {
+		http.Error(w, "Org role not found", http.StatusNotFound)
+		return nil
+	}
+
+	if role.OrgId == nil {
+		http.Error(w, "Built-in roles can't be changed", http.StatusForbidden)
+		return nil
+	}
+
+	return role
+}
+
+func authorizeOrgRolePermissions(w http.ResponseWriter, permissions []*shared.OrgRolePermission, auth *types.ServerAuth) bool {
+	for _, permission := range permissions {
+		if permission.ResourceId != "" && !authorizeProject(w, permission.ResourceId, auth) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatAuditPermissions(permissions []*shared.OrgRolePermission) string {
+	var res []string
+	for _, permission := range permissions {
+		if permission.ResourceId == "" {
+			res = append(res, permission.Permission)
+		} else {
+			res = append(res, permission.Permission+"|"+permission.ResourceId)
+		}
+	}
+	return strings.Join(res, ",")
+}
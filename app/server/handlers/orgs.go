@@ -3,16 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/types"
 
 	"github.com/plandex/plandex/shared"
 )
 
 func ListOrgsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListOrgsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListOrgsHandler")
 
 	auth := authenticate(w, r, false)
 	if auth == nil {
@@ -22,7 +24,7 @@ func ListOrgsHandler(w http.ResponseWriter, r *http.Request) {
 	orgs, err := db.GetAccessibleOrgsForUser(auth.User)
 
 	if err != nil {
-		log.Printf("Error listing orgs: %v\n", err)
+		logger.Errorf("Error listing orgs: %v", err)
 		http.Error(w, "Error listing orgs: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -35,18 +37,20 @@ func ListOrgsHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiOrgs)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully listed orgs")
+	logger.Info("Successfully listed orgs")
 
 	w.Write(bytes)
 }
 
 func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateOrgHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateOrgHandler")
 
 	auth := authenticate(w, r, false)
 	if auth == nil {
@@ -65,7 +69,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -73,7 +77,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	var req shared.CreateOrgRequest
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -81,7 +85,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -90,9 +94,9 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -100,7 +104,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	var domain *string
 	if req.AutoAddDomainUsers {
 		if shared.IsEmailServiceDomain(auth.User.Domain) {
-			log.Printf("Invalid domain: %v\n", auth.User.Domain)
+			logger.Infof("Invalid domain: %v", auth.User.Domain)
 			http.Error(w, "Invalid domain: "+auth.User.Domain, http.StatusBadRequest)
 			return
 		}
@@ -112,7 +116,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	org, err := db.CreateOrg(&req, auth.AuthToken.UserId, domain, tx)
 
 	if err != nil {
-		log.Printf("Error creating org: %v\n", err)
+		logger.Errorf("Error creating org: %v", err)
 		http.Error(w, "Error creating org: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -121,7 +125,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 		err = db.AddOrgDomainUsers(org.Id, *org.Domain, tx)
 
 		if err != nil {
-			log.Printf("Error adding org domain users: %v\n", err)
+			logger.Errorf("Error adding org domain users: %v", err)
 			http.Error(w, "Error adding org domain users: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -130,7 +134,7 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	err = tx.Commit()
 
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -142,18 +146,20 @@ func CreateOrgHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully created org")
+	logger.Info("Successfully created org")
 
 	w.Write(bytes)
 }
 
 func GetOrgSessionHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for GetOrgSessionHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetOrgSessionHandler")
 
 	auth := authenticate(w, r, true)
 
@@ -161,11 +167,13 @@ func GetOrgSessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("Successfully got org session")
+	logger.Info("Successfully got org session")
 }
 
 func ListOrgRolesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListOrgRolesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListOrgRolesHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -182,7 +190,7 @@ func ListOrgRolesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !auth.HasPermission(types.PermissionListOrgRoles) {
-		log.Println("User cannot list org roles")
+		logger.Info("User cannot list org roles")
 		http.Error(w, "User cannot list org roles", http.StatusForbidden)
 		return
 	}
@@ -190,25 +198,40 @@ func ListOrgRolesHandler(w http.ResponseWriter, r *http.Request) {
 	roles, err := db.ListOrgRoles(auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error listing org roles: %v\n", err)
+		logger.Errorf("Error listing org roles: %v", err)
 		http.Error(w, "Error listing org roles: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	permissions, err := db.ListOrgRolePermissions(auth.OrgId)
+
+	if err != nil {
+		logger.Errorf("Error listing org role permissions: %v", err)
+		http.Error(w, "Error listing org role permissions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	permissionsByRoleId := map[string][]*shared.OrgRolePermission{}
+	for _, permission := range permissions {
+		permissionsByRoleId[permission.OrgRoleId] = append(permissionsByRoleId[permission.OrgRoleId], permission.ToApi())
+	}
+
 	var apiRoles []*shared.OrgRole
 	for _, role := range roles {
-		apiRoles = append(apiRoles, role.ToApi())
+		apiRole := role.ToApi()
+		apiRole.Permissions = permissionsByRoleId[role.Id]
+		apiRoles = append(apiRoles, apiRole)
 	}
 
 	bytes, err := json.Marshal(apiRoles)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully listed org roles")
+	logger.Info("Successfully listed org roles")
 
 	w.Write(bytes)
 }
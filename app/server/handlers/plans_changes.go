@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	modelPlan "plandex-server/model/plan"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -16,7 +17,9 @@ import (
 )
 
 func CurrentPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CurrentPlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CurrentPlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -25,10 +28,11 @@ func CurrentPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
+	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -50,7 +54,7 @@ func CurrentPlanHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Printf("Error getting current plan state: %v\n", err)
+		logger.Errorf("Error getting current plan state: %v", err)
 		http.Error(w, "Error getting current plan state: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -58,18 +62,20 @@ func CurrentPlanHandler(w http.ResponseWriter, r *http.Request) {
 	jsonBytes, err := json.Marshal(planState)
 
 	if err != nil {
-		log.Printf("Error marshalling plan state: %v\n", err)
+		logger.Errorf("Error marshalling plan state: %v", err)
 		http.Error(w, "Error marshalling plan state: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully retrieved current plan state")
+	logger.Info("Successfully retrieved current plan state")
 
 	w.Write(jsonBytes)
 }
 
 func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ApplyPlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ApplyPlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -79,9 +85,9 @@ func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
@@ -91,7 +97,7 @@ func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -99,7 +105,7 @@ func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.ApplyPlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -116,19 +122,30 @@ func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	settings, err := db.GetPlanSettings(plan, true)
 	if err != nil {
-		log.Printf("Error getting plan settings: %v\n", err)
+		logger.Errorf("Error getting plan settings: %v", err)
 		http.Error(w, "Error getting plan settings: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	currentPlan, err := db.ApplyPlan(auth.OrgId, auth.User.Id, branch, plan)
+	currentPlan, err := db.ApplyPlan(auth.OrgId, auth.User.Id, branch, plan, requestBody.Paths)
 
 	if err != nil {
-		log.Printf("Error applying plan: %v\n", err)
+		logger.Errorf("Error applying plan: %v", err)
 		http.Error(w, "Error applying plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	applyDetails := map[string]string{"branch": branch}
+	if len(requestBody.Paths) > 0 {
+		applyDetails["paths"] = strings.Join(requestBody.Paths, ",")
+	}
+	recordAuditEvent(r, auth, shared.AuditActionPlanApply, planId, applyDetails)
+
+	db.QueuePlanWebhookEvent(planId, branch, shared.WebhookPayload{
+		Event: shared.WebhookEventPlanApplied,
+		Paths: requestBody.Paths,
+	})
+
 	clients := initClients(
 		initClientsParams{
 			w:           w,
@@ -145,18 +162,69 @@ func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
 	s, err := modelPlan.GenCommitMsgForPendingResults(client, settings.ModelPack.CommitMsg, currentPlan, r.Context())
 
 	if err != nil {
-		log.Printf("Error generating commit message: %v\n", err)
+		logger.Errorf("Error generating commit message: %v", err)
 		http.Error(w, "Error generating commit message: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write([]byte(s))
 
-	log.Println("Successfully applied plan", planId)
+	logger.Infof("Successfully applied plan %v", planId)
+}
+
+func UnapplyPlanHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UnapplyPlanHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
+		return
+	}
+
+	var err error
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	err = db.UnapplyPlan(auth.OrgId, planId, branch)
+
+	if err == db.ErrNoApplyToUndo {
+		logger.Errorf("Error unapplying plan: %v", err)
+		http.Error(w, "Can't unapply: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		logger.Errorf("Error unapplying plan: %v", err)
+		http.Error(w, "Error unapplying plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionPlanUnapply, planId, map[string]string{"branch": branch})
+
+	logger.Infof("Successfully unapplied plan %v", planId)
 }
 
 func RejectAllChangesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RejectAllChangesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RejectAllChangesHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -166,9 +234,9 @@ func RejectAllChangesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -186,7 +254,7 @@ func RejectAllChangesHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.RejectAllResults(auth.OrgId, planId)
 
 	if err != nil {
-		log.Printf("Error rejecting all changes: %v\n", err)
+		logger.Errorf("Error rejecting all changes: %v", err)
 		http.Error(w, "Error rejecting all changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -194,16 +262,18 @@ func RejectAllChangesHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branch, "🚫 Rejected all pending changes")
 
 	if err != nil {
-		log.Printf("Error committing rejected changes: %v\n", err)
+		logger.Errorf("Error committing rejected changes: %v", err)
 		http.Error(w, "Error committing rejected changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully rejected all changes for plan", planId)
+	logger.Infof("Successfully rejected all changes for plan %v", planId)
 }
 
 func RejectFileHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RejectFileHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RejectFileHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -214,16 +284,16 @@ func RejectFileHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
 	var req shared.RejectFileRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("Error decoding request: %v\n", err)
+		logger.Errorf("Error decoding request: %v", err)
 		http.Error(w, "Error decoding request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -241,7 +311,7 @@ func RejectFileHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.RejectPlanFile(auth.OrgId, planId, req.FilePath, time.Now())
 
 	if err != nil {
-		log.Printf("Error rejecting result: %v\n", err)
+		logger.Errorf("Error rejecting result: %v", err)
 		http.Error(w, "Error rejecting result: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -249,16 +319,18 @@ func RejectFileHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branch, fmt.Sprintf("🚫 Rejected pending changes to file: %s", req.FilePath))
 
 	if err != nil {
-		log.Printf("Error committing rejected changes: %v\n", err)
+		logger.Errorf("Error committing rejected changes: %v", err)
 		http.Error(w, "Error committing rejected changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully rejected plan file", req.FilePath)
+	logger.Infof("Successfully rejected plan file %v", req.FilePath)
 }
 
 func RejectFilesHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RejectFilesHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RejectFilesHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -269,16 +341,16 @@ func RejectFilesHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
 	var req shared.RejectFilesRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		log.Printf("Error decoding request: %v\n", err)
+		logger.Errorf("Error decoding request: %v", err)
 		http.Error(w, "Error decoding request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -296,7 +368,7 @@ func RejectFilesHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.RejectPlanFiles(auth.OrgId, planId, req.Paths, time.Now())
 
 	if err != nil {
-		log.Printf("Error rejecting result: %v\n", err)
+		logger.Errorf("Error rejecting result: %v", err)
 		http.Error(w, "Error rejecting result: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -313,25 +385,27 @@ func RejectFilesHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branch, msg)
 
 	if err != nil {
-		log.Printf("Error committing rejected changes: %v\n", err)
+		logger.Errorf("Error committing rejected changes: %v", err)
 		http.Error(w, "Error committing rejected changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully rejected plan files", req.Paths)
+	logger.Infof("Successfully rejected plan files %v", req.Paths)
 }
 
 func ArchivePlanHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
-	log.Println("Received request for ArchivePlanHandler")
+	logger.Info("Received request for ArchivePlanHandler")
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
 	plan := authorizePlanArchive(w, planId, auth)
 
@@ -340,7 +414,7 @@ func ArchivePlanHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if plan.ArchivedAt != nil {
-		log.Println("Plan already archived")
+		logger.Info("Plan already archived")
 		http.Error(w, "Plan already archived", http.StatusBadRequest)
 		return
 	}
@@ -348,38 +422,42 @@ func ArchivePlanHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := db.Conn.Exec("UPDATE plans SET archived_at = NOW() WHERE id = $1", planId)
 
 	if err != nil {
-		log.Printf("Error archiving plan: %v\n", err)
+		logger.Errorf("Error archiving plan: %v", err)
 		http.Error(w, "Error archiving plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		log.Printf("Error getting rows affected: %v\n", err)
+		logger.Errorf("Error getting rows affected: %v", err)
 		http.Error(w, "Error getting rows affected: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Println("Plan not found")
+		logger.Warn("Plan not found")
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	log.Println("Successfully archived plan", planId)
+	recordAuditEvent(r, auth, shared.AuditActionPlanArchive, planId, map[string]string{"name": plan.Name})
+
+	logger.Infof("Successfully archived plan %v", planId)
 }
 
 func UnarchivePlanHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
-	log.Println("Received request for UnarchivePlanHandler")
+	logger.Info("Received request for UnarchivePlanHandler")
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
 	plan := authorizePlanArchive(w, planId, auth)
 
@@ -388,7 +466,7 @@ func UnarchivePlanHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if plan.ArchivedAt == nil {
-		log.Println("Plan isn't archived")
+		logger.Info("Plan isn't archived")
 		http.Error(w, "Plan isn't archived", http.StatusBadRequest)
 		return
 	}
@@ -396,29 +474,33 @@ func UnarchivePlanHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := db.Conn.Exec("UPDATE plans SET archived_at = NULL WHERE id = $1", planId)
 
 	if err != nil {
-		log.Printf("Error archiving plan: %v\n", err)
+		logger.Errorf("Error archiving plan: %v", err)
 		http.Error(w, "Error archiving plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		log.Printf("Error getting rows affected: %v\n", err)
+		logger.Errorf("Error getting rows affected: %v", err)
 		http.Error(w, "Error getting rows affected: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Println("Plan not found")
+		logger.Warn("Plan not found")
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	log.Println("Successfully unarchived plan", planId)
+	recordAuditEvent(r, auth, shared.AuditActionPlanUnarchive, planId, map[string]string{"name": plan.Name})
+
+	logger.Infof("Successfully unarchived plan %v", planId)
 }
 
 func GetPlanDiffsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for GetPlanDiffs")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetPlanDiffs")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -429,9 +511,9 @@ func GetPlanDiffsHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -449,12 +531,64 @@ func GetPlanDiffsHandler(w http.ResponseWriter, r *http.Request) {
 	diffs, err := db.GetPlanDiffs(auth.OrgId, planId)
 
 	if err != nil {
-		log.Printf("Error getting plan diffs: %v\n", err)
+		logger.Errorf("Error getting plan diffs: %v", err)
 		http.Error(w, "Error getting plan diffs: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write([]byte(diffs))
 
-	log.Println("Successfully retrieved plan diffs")
+	logger.Info("Successfully retrieved plan diffs")
+}
+
+func GetPlanPatchesHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetPlanPatchesHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
+		return
+	}
+
+	var err error
+	ctx, cancel := context.WithCancel(context.Background())
+	unlockFn := lockRepo(w, r, auth, db.LockScopeRead, ctx, cancel, true)
+	if unlockFn == nil {
+		return
+	} else {
+		defer func() {
+			(*unlockFn)(err)
+		}()
+	}
+
+	patches, err := db.GetPlanPatches(auth.OrgId, planId, auth.User.Name, auth.User.Email)
+
+	if err != nil {
+		logger.Errorf("Error getting plan patches: %v", err)
+		http.Error(w, "Error getting plan patches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(shared.ExportPatchesResponse{Patches: patches})
+
+	if err != nil {
+		logger.Errorf("Error marshalling plan patches: %v", err)
+		http.Error(w, "Error marshalling plan patches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Info("Successfully retrieved plan patches")
 }
@@ -4,16 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func ListContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListContextHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -22,9 +24,10 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
-	log.Println("planId: ", planId)
+	branch := vars["branch"]
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -42,7 +45,7 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
 
 	if err != nil {
-		log.Printf("Error getting contexts: %v\n", err)
+		logger.Errorf("Error getting contexts: %v", err)
 		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -56,7 +59,7 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiContexts)
 
 	if err != nil {
-		log.Printf("Error marshalling contexts: %v\n", err)
+		logger.Errorf("Error marshalling contexts: %v", err)
 		http.Error(w, "Error marshalling contexts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -65,7 +68,9 @@ func ListContextHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for LoadContextHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for LoadContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -75,9 +80,9 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branchName, auth)
 	if plan == nil {
 		return
 	}
@@ -85,7 +90,7 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -93,7 +98,7 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.LoadContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -107,18 +112,20 @@ func LoadContextHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed LoadContextHandler request")
+	logger.Info("Successfully processed LoadContextHandler request")
 
 	w.Write(bytes)
 }
 
 func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for UpdateContextHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UpdateContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -128,9 +135,9 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branchName, auth)
 	if plan == nil {
 		return
 	}
@@ -138,7 +145,7 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -146,7 +153,7 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.UpdateContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -169,17 +176,17 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Printf("Error error updating contexts: %v\n", err)
+		logger.Errorf("Error error updating contexts: %v", err)
 		http.Error(w, "Error error updating contexts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if updateRes.MaxTokensExceeded {
-		log.Printf("The total number of tokens (%d) exceeds the maximum allowed (%d)", updateRes.TotalTokens, updateRes.MaxTokens)
+		logger.Infof("The total number of tokens (%d) exceeds the maximum allowed (%d)", updateRes.TotalTokens, updateRes.MaxTokens)
 		bytes, err := json.Marshal(updateRes)
 
 		if err != nil {
-			log.Printf("Error marshalling response: %v\n", err)
+			logger.Errorf("Error marshalling response: %v", err)
 			http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -191,7 +198,7 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branchName, updateRes.Msg)
 
 	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
+		logger.Errorf("Error committing changes: %v", err)
 		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -199,18 +206,20 @@ func UpdateContextHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(updateRes)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed UpdateContextHandler request")
+	logger.Info("Successfully processed UpdateContextHandler request")
 
 	w.Write(bytes)
 }
 
 func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteContextHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteContextHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -220,9 +229,9 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branchName := vars["branch"]
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branchName, auth)
 
 	if plan == nil {
 		return
@@ -231,7 +240,7 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	branch, err := db.GetDbBranch(planId, branchName)
 
 	if err != nil {
-		log.Printf("Error getting branch: %v\n", err)
+		logger.Errorf("Error getting branch: %v", err)
 		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -239,7 +248,7 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -247,7 +256,7 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.DeleteContextRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -265,7 +274,7 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	dbContexts, err := db.GetPlanContexts(auth.OrgId, planId, false)
 
 	if err != nil {
-		log.Printf("Error getting contexts: %v\n", err)
+		logger.Errorf("Error getting contexts: %v", err)
 		http.Error(w, "Error getting contexts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -280,7 +289,7 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.ContextRemove(auth.OrgId, planId, toRemove)
 
 	if err != nil {
-		log.Printf("Error deleting contexts: %v\n", err)
+		logger.Errorf("Error deleting contexts: %v", err)
 		http.Error(w, "Error deleting contexts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -296,14 +305,14 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branchName, commitMsg)
 
 	if err != nil {
-		log.Printf("Error committing changes: %v\n", err)
+		logger.Errorf("Error committing changes: %v", err)
 		http.Error(w, "Error committing changes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	err = db.AddPlanContextTokens(planId, branchName, -removeTokens)
 	if err != nil {
-		log.Printf("Error updating plan tokens: %v\n", err)
+		logger.Errorf("Error updating plan tokens: %v", err)
 		http.Error(w, "Error updating plan tokens: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -317,12 +326,12 @@ func DeleteContextHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted contexts")
+	logger.Info("Successfully deleted contexts")
 
 	w.Write(bytes)
 }
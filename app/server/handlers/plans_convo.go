@@ -3,15 +3,17 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 )
 
 func ListConvoHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for ListConvoHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for ListConvoHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -19,10 +21,11 @@ func ListConvoHandler(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
+	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -40,7 +43,7 @@ func ListConvoHandler(w http.ResponseWriter, r *http.Request) {
 	convoMessages, err := db.GetPlanConvo(auth.OrgId, planId)
 
 	if err != nil {
-		log.Println("Error getting plan convo: ", err)
+		logger.Errorf("Error getting plan convo: %v", err)
 		http.Error(w, "Error getting plan convo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -48,18 +51,20 @@ func ListConvoHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(convoMessages)
 
 	if err != nil {
-		log.Println("Error marshalling plan convo: ", err)
+		logger.Errorf("Error marshalling plan convo: %v", err)
 		http.Error(w, "Error marshalling plan convo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed request for ListConvoHandler")
+	logger.Info("Successfully processed request for ListConvoHandler")
 	w.Write(bytes)
 
 }
 
 func GetPlanStatusHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for GetPlanStatusHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for GetPlanStatusHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -70,9 +75,9 @@ func GetPlanStatusHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranch(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
@@ -91,13 +96,13 @@ func GetPlanStatusHandler(w http.ResponseWriter, r *http.Request) {
 	convoMessages, err := db.GetPlanConvo(auth.OrgId, planId)
 
 	if err != nil {
-		log.Println("Error getting plan convo: ", err)
+		logger.Errorf("Error getting plan convo: %v", err)
 		http.Error(w, "Error getting plan convo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if len(convoMessages) == 0 {
-		log.Println("No messages found for plan")
+		logger.Info("No messages found for plan")
 		return
 	}
 
@@ -109,13 +114,13 @@ func GetPlanStatusHandler(w http.ResponseWriter, r *http.Request) {
 	summmaries, err := db.GetPlanSummaries(planId, convoMessageIds)
 
 	if err != nil {
-		log.Println("Error getting plan summaries: ", err)
+		logger.Errorf("Error getting plan summaries: %v", err)
 		http.Error(w, "Error getting plan summaries: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if len(summmaries) == 0 {
-		log.Println("No summaries found for plan")
+		logger.Info("No summaries found for plan")
 		return
 	}
 
@@ -125,5 +130,5 @@ func GetPlanStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(bytes)
 
-	log.Println("Successfully processed request for GetPlanStatusHandler")
+	logger.Info("Successfully processed request for GetPlanStatusHandler")
 }
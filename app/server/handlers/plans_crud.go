@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/types"
 	"sort"
 	"strings"
@@ -18,23 +18,25 @@ import (
 )
 
 func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreatePlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreatePlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
-	if !auth.HasPermission(types.PermissionCreatePlan) {
-		log.Println("User does not have permission to create a plan")
-		http.Error(w, "User does not have permission to create a plan", http.StatusForbidden)
-		return
-	}
-
 	vars := mux.Vars(r)
 	projectId := vars["projectId"]
 
-	log.Println("projectId: ", projectId)
+	logger.Infof("projectId: %v", projectId)
+
+	if !auth.HasProjectPermission(types.PermissionCreatePlan, projectId) {
+		logger.Info("User does not have permission to create a plan")
+		http.Error(w, "User does not have permission to create a plan", http.StatusForbidden)
+		return
+	}
 
 	if !authorizeProject(w, projectId, auth) {
 		return
@@ -44,7 +46,7 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 		user, err := db.GetUser(auth.User.Id)
 
 		if err != nil {
-			log.Printf("Error getting user: %v\n", err)
+			logger.Errorf("Error getting user: %v", err)
 			http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -67,7 +69,7 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -75,7 +77,7 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.CreatePlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -90,7 +92,7 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 		err = db.DeleteDraftPlans(auth.OrgId, projectId, auth.User.Id)
 
 		if err != nil {
-			log.Printf("Error deleting draft plans: %v\n", err)
+			logger.Errorf("Error deleting draft plans: %v", err)
 			http.Error(w, "Error deleting draft plans: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -102,7 +104,7 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 			err := db.Conn.Get(&count, "SELECT COUNT(*) FROM plans WHERE project_id = $1 AND owner_id = $2 AND name = $3", projectId, auth.User.Id, name)
 
 			if err != nil {
-				log.Printf("Error checking if plan exists: %v\n", err)
+				logger.Errorf("Error checking if plan exists: %v", err)
 				http.Error(w, "Error checking if plan exists: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -119,11 +121,13 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 	plan, err := db.CreatePlan(auth.OrgId, projectId, auth.User.Id, name)
 
 	if err != nil {
-		log.Printf("Error creating plan: %v\n", err)
+		logger.Errorf("Error creating plan: %v", err)
 		http.Error(w, "Error creating plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	recordAuditEvent(r, auth, shared.AuditActionPlanCreate, plan.Id, map[string]string{"name": plan.Name, "projectId": projectId})
+
 	resp := shared.CreatePlanResponse{
 		Id:   plan.Id,
 		Name: plan.Name,
@@ -132,18 +136,20 @@ func CreatePlanHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Printf("Successfully created plan: %v\n", plan)
+	logger.Infof("Successfully created plan: %v", plan)
 }
 
 func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for GetPlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetPlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -153,7 +159,7 @@ func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
 	plan := authorizePlan(w, planId, auth)
 
@@ -164,7 +170,7 @@ func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(plan)
 
 	if err != nil {
-		log.Printf("Error marshalling plan: %v\n", err)
+		logger.Errorf("Error marshalling plan: %v", err)
 		http.Error(w, "Error marshalling plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -173,7 +179,9 @@ func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func RenamePlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RenamePlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RenamePlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -183,7 +191,7 @@ func RenamePlanHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
 	plan := authorizePlan(w, planId, auth)
 
@@ -193,19 +201,19 @@ func RenamePlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.RenamePlanRequest
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if plan.OwnerId != auth.User.Id {
-		log.Println("Only the plan owner can rename a plan")
+		logger.Info("Only the plan owner can rename a plan")
 		http.Error(w, "Only the plan owner can rename a plan", http.StatusForbidden)
 		return
 	}
 
 	if requestBody.Name == "" {
-		log.Println("Name cannot be empty")
+		logger.Info("Name cannot be empty")
 		http.Error(w, "Name cannot be empty", http.StatusBadRequest)
 		return
 	}
@@ -213,16 +221,20 @@ func RenamePlanHandler(w http.ResponseWriter, r *http.Request) {
 	err := db.RenamePlan(planId, requestBody.Name, nil)
 
 	if err != nil {
-		log.Printf("Error renaming plan: %v\n", err)
+		logger.Errorf("Error renaming plan: %v", err)
 		http.Error(w, "Error renaming plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully renamed plan")
+	recordAuditEvent(r, auth, shared.AuditActionPlanRename, planId, map[string]string{"name": requestBody.Name, "previousName": plan.Name})
+
+	logger.Info("Successfully renamed plan")
 }
 
 func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeletePlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeletePlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -232,7 +244,7 @@ func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
 	plan := authorizePlanDelete(w, planId, auth)
 
@@ -241,7 +253,7 @@ func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if plan.OwnerId != auth.User.Id {
-		log.Println("Only the plan owner can delete a plan")
+		logger.Info("Only the plan owner can delete a plan")
 		http.Error(w, "Only the plan owner can delete a plan", http.StatusForbidden)
 		return
 	}
@@ -249,20 +261,20 @@ func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := db.Conn.Exec("DELETE FROM plans WHERE id = $1", planId)
 
 	if err != nil {
-		log.Printf("Error deleting plan: %v\n", err)
+		logger.Errorf("Error deleting plan: %v", err)
 		http.Error(w, "Error deleting plan: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		log.Printf("Error getting rows affected: %v\n", err)
+		logger.Errorf("Error getting rows affected: %v", err)
 		http.Error(w, "Error getting rows affected: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Println("Plan not found")
+		logger.Warn("Plan not found")
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
@@ -270,16 +282,20 @@ func DeletePlanHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.DeletePlanDir(auth.OrgId, planId)
 
 	if err != nil {
-		log.Printf("Error deleting plan dir: %v\n", err)
+		logger.Errorf("Error deleting plan dir: %v", err)
 		http.Error(w, "Error deleting plan dir: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted plan", planId)
+	recordAuditEvent(r, auth, shared.AuditActionPlanDelete, planId, map[string]string{"name": plan.Name, "projectId": plan.ProjectId})
+
+	logger.Infof("Successfully deleted plan %v", planId)
 }
 
 func DeleteAllPlansHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for DeleteAllPlansHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteAllPlansHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -289,7 +305,7 @@ func DeleteAllPlansHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectId := vars["projectId"]
 
-	log.Println("projectId: ", projectId)
+	logger.Infof("projectId: %v", projectId)
 
 	if !authorizeProject(w, projectId, auth) {
 		return
@@ -298,16 +314,20 @@ func DeleteAllPlansHandler(w http.ResponseWriter, r *http.Request) {
 	err := db.DeleteOwnerPlans(auth.OrgId, projectId, auth.User.Id)
 
 	if err != nil {
-		log.Printf("Error deleting plans: %v\n", err)
+		logger.Errorf("Error deleting plans: %v", err)
 		http.Error(w, "Error deleting plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully deleted all plans")
+	recordAuditEvent(r, auth, shared.AuditActionPlanDeleteAll, projectId, nil)
+
+	logger.Info("Successfully deleted all plans")
 }
 
 func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListPlans")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListPlans")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -316,10 +336,10 @@ func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
 
 	projectIds := r.URL.Query()["projectId"]
 
-	log.Println("projectIds: ", projectIds)
+	logger.Infof("projectIds: %v", projectIds)
 
 	if len(projectIds) == 0 {
-		log.Println("No project ids provided")
+		logger.Info("No project ids provided")
 		http.Error(w, "No project ids provided", http.StatusBadRequest)
 		return
 	}
@@ -332,7 +352,7 @@ func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(authorizedProjectIds) == 0 {
-		log.Println("No authorized project ids provided")
+		logger.Info("No authorized project ids provided")
 		http.Error(w, "No authorized project ids provided", http.StatusForbidden)
 		return
 	}
@@ -340,7 +360,7 @@ func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
 	plans, err := db.ListOwnedPlans(authorizedProjectIds, auth.User.Id, false)
 
 	if err != nil {
-		log.Printf("Error listing plans: %v\n", err)
+		logger.Errorf("Error listing plans: %v", err)
 		http.Error(w, "Error listing plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -353,7 +373,7 @@ func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(apiPlans)
 
 	if err != nil {
-		log.Printf("Error marshalling plans: %v\n", err)
+		logger.Errorf("Error marshalling plans: %v", err)
 		http.Error(w, "Error marshalling plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -362,7 +382,9 @@ func ListPlansHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func ListArchivedPlansHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListArchivedPlansHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListArchivedPlansHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -370,10 +392,10 @@ func ListArchivedPlansHandler(w http.ResponseWriter, r *http.Request) {
 
 	projectIds := r.URL.Query()["projectId"]
 
-	log.Println("projectIds: ", projectIds)
+	logger.Infof("projectIds: %v", projectIds)
 
 	if len(projectIds) == 0 {
-		log.Println("No project ids provided")
+		logger.Info("No project ids provided")
 		http.Error(w, "No project ids provided", http.StatusBadRequest)
 		return
 	}
@@ -387,7 +409,7 @@ func ListArchivedPlansHandler(w http.ResponseWriter, r *http.Request) {
 	plans, err := db.ListOwnedPlans(projectIds, auth.User.Id, true)
 
 	if err != nil {
-		log.Printf("Error listing plans: %v\n", err)
+		logger.Errorf("Error listing plans: %v", err)
 		http.Error(w, "Error listing plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -399,18 +421,20 @@ func ListArchivedPlansHandler(w http.ResponseWriter, r *http.Request) {
 
 	jsonBytes, err := json.Marshal(apiPlans)
 	if err != nil {
-		log.Printf("Error marshalling plans: %v\n", err)
+		logger.Errorf("Error marshalling plans: %v", err)
 		http.Error(w, "Error marshalling plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed ListArchivedPlansHandler request")
+	logger.Info("Successfully processed ListArchivedPlansHandler request")
 
 	w.Write(jsonBytes)
 }
 
 func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListPlansRunningHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListPlansRunningHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -419,10 +443,10 @@ func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
 	projectIds := r.URL.Query()["projectId"]
 	includeRecent := r.URL.Query().Get("recent") == "true"
 
-	log.Println("projectIds: ", projectIds)
+	logger.Infof("projectIds: %v", projectIds)
 
 	if len(projectIds) == 0 {
-		log.Println("No project ids provided")
+		logger.Info("No project ids provided")
 		http.Error(w, "No project ids provided", http.StatusBadRequest)
 		return
 	}
@@ -436,7 +460,7 @@ func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
 	plans, err := db.ListOwnedPlans(projectIds, auth.User.Id, false)
 
 	if err != nil {
-		log.Printf("Error listing plans: %v\n", err)
+		logger.Errorf("Error listing plans: %v", err)
 		http.Error(w, "Error listing plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -477,7 +501,7 @@ func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
 	for i := 0; i < 2; i++ {
 		err := <-errCh
 		if err != nil {
-			log.Println(err)
+			logger.Errorf("%v", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -508,14 +532,14 @@ func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
 		branchComposite := stream.PlanId + "|" + stream.Branch
 		apiBranch, ok := apiBranchesByComposite[branchComposite]
 		if !ok {
-			log.Printf("Stream %s has no branch\n", stream.Id)
+			logger.Infof("Stream %s has no branch", stream.Id)
 			http.Error(w, "Stream has no branch", http.StatusInternalServerError)
 			return
 		}
 
 		apiPlan, ok := apiPlansById[stream.PlanId]
 		if !ok {
-			log.Printf("Stream %s has no plan\n", stream.Id)
+			logger.Infof("Stream %s has no plan", stream.Id)
 			http.Error(w, "Stream has no plan", http.StatusInternalServerError)
 			return
 		}
@@ -557,18 +581,20 @@ func ListPlansRunningHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed ListPlansRunningHandler request")
+	logger.Info("Successfully processed ListPlansRunningHandler request")
 
 	w.Write(bytes)
 }
 
 func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CurrentBranchByPlanIdHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CurrentBranchByPlanIdHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -577,7 +603,7 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectId := vars["projectId"]
 
-	log.Println("projectId: ", projectId)
+	logger.Infof("projectId: %v", projectId)
 
 	if !authorizeProject(w, projectId, auth) {
 		return
@@ -585,7 +611,7 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req shared.GetCurrentBranchByPlanIdRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -593,13 +619,23 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 	plans, err := db.ListOwnedPlans([]string{projectId}, auth.User.Id, false)
 
 	if err != nil {
-		log.Printf("Error listing plans: %v\n", err)
+		logger.Errorf("Error listing plans: %v", err)
 		http.Error(w, "Error listing plans: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	sharedPlans, err := db.ListSharedPlans(auth.OrgId, []string{projectId}, auth.User.Id)
+
+	if err != nil {
+		logger.Errorf("Error listing shared plans: %v", err)
+		http.Error(w, "Error listing shared plans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	plans = append(plans, sharedPlans...)
+
 	if len(plans) == 0 {
-		log.Println("No plans found")
+		logger.Info("No plans found")
 		http.Error(w, "No plans found", http.StatusNotFound)
 		return
 	}
@@ -616,7 +652,13 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		orConditions = append(orConditions, fmt.Sprintf("(plan_id = $%d AND name = $%d)", currentArg, currentArg+1))
+		condition := fmt.Sprintf("(plan_id = $%d AND name = $%d)", currentArg, currentArg+1)
+		if plan.OwnerId != auth.User.Id {
+			// other members' plans only include shared branches
+			condition = fmt.Sprintf("(plan_id = $%d AND name = $%d AND shared_with_org_at IS NOT NULL)", currentArg, currentArg+1)
+		}
+
+		orConditions = append(orConditions, condition)
 		queryArgs = append(queryArgs, plan.Id, branchName)
 
 		currentArg += 2
@@ -628,7 +670,7 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.Conn.Select(&branches, query, queryArgs...)
 
 	if err != nil {
-		log.Printf("Error getting branches: %v\n", err)
+		logger.Errorf("Error getting branches: %v", err)
 		http.Error(w, "Error getting branches: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -641,12 +683,12 @@ func GetCurrentBranchByPlanIdHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling branches: %v\n", err)
+		logger.Errorf("Error marshalling branches: %v", err)
 		http.Error(w, "Error marshalling branches: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed GetCurrentBranchByPlanIdHandler request")
+	logger.Info("Successfully processed GetCurrentBranchByPlanIdHandler request")
 
 	w.Write(bytes)
 }
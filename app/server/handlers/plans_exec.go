@@ -1,17 +1,15 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"plandex-server/db"
 	"plandex-server/host"
+	"plandex-server/logging"
 	modelPlan "plandex-server/model/plan"
 	"plandex-server/types"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
@@ -20,7 +18,9 @@ import (
 const TrialMaxReplies = 10
 
 func TellPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for TellPlanHandler", "ip:", host.Ip)
+	logger := logging.Ctx(r.Context())
+
+	logger.Infof("Received request for TellPlanHandler ip: %v", host.Ip)
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -31,33 +31,33 @@ func TellPlanHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	plan := authorizePlanExecUpdate(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 	defer func() {
-		log.Println("Closing request body")
+		logger.Info("Closing request body")
 		r.Body.Close()
 	}()
 
 	var requestBody shared.TellPlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if requestBody.ApiKey == "" && len(requestBody.ApiKeys) == 0 {
-		log.Println("API key is required")
+		logger.Info("API key is required")
 		http.Error(w, "API key is required", http.StatusBadRequest)
 		return
 	}
@@ -66,7 +66,7 @@ func TellPlanHandler(w http.ResponseWriter, r *http.Request) {
 		user, err := db.GetUser(auth.User.Id)
 
 		if err != nil {
-			log.Printf("Error getting user: %v\n", err)
+			logger.Errorf("Error getting user: %v", err)
 			http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -97,23 +97,25 @@ func TellPlanHandler(w http.ResponseWriter, r *http.Request) {
 			plan:        plan,
 		},
 	)
-	err = modelPlan.Tell(clients, plan, branch, auth, &requestBody)
+	err = modelPlan.Tell(clients, plan, branch, auth, &requestBody, r.Context())
 
 	if err != nil {
-		log.Printf("Error telling plan: %v\n", err)
+		logger.Errorf("Error telling plan: %v", err)
 		http.Error(w, "Error telling plan", http.StatusInternalServerError)
 		return
 	}
 
 	if requestBody.ConnectStream {
-		startResponseStream(w, auth, planId, branch, false)
+		startResponseStream(w, r, auth, planId, branch, false)
 	}
 
-	log.Println("Successfully processed request for TellPlanHandler")
+	logger.Info("Successfully processed request for TellPlanHandler")
 }
 
 func BuildPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for BuildPlanHandler", "ip:", host.Ip)
+	logger := logging.Ctx(r.Context())
+
+	logger.Infof("Received request for BuildPlanHandler ip: %v", host.Ip)
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -123,32 +125,32 @@ func BuildPlanHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
-	plan := authorizePlanExecUpdate(w, planId, auth)
+	logger.Infof("planId: %v", planId)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
 	defer func() {
-		log.Println("Closing request body")
+		logger.Info("Closing request body")
 		r.Body.Close()
 	}()
 
 	var requestBody shared.BuildPlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if requestBody.ApiKey == "" && len(requestBody.ApiKeys) == 0 {
-		log.Println("API key is required")
+		logger.Info("API key is required")
 		http.Error(w, "API key is required", http.StatusBadRequest)
 		return
 	}
@@ -164,169 +166,123 @@ func BuildPlanHandler(w http.ResponseWriter, r *http.Request) {
 			plan:        plan,
 		},
 	)
-	numBuilds, err := modelPlan.Build(clients, plan, branch, auth)
+	numBuilds, err := modelPlan.Build(clients, plan, branch, auth, r.Context())
 
 	if err != nil {
-		log.Printf("Error building plan: %v\n", err)
+		logger.Errorf("Error building plan: %v", err)
 		http.Error(w, "Error building plan", http.StatusInternalServerError)
 		return
 	}
 
 	if numBuilds == 0 {
-		log.Println("No builds were executed")
+		logger.Info("No builds were executed")
 		http.Error(w, shared.NoBuildsErr, http.StatusNotFound)
 		return
 	}
 
 	if requestBody.ConnectStream {
-		startResponseStream(w, auth, planId, branch, false)
+		startResponseStream(w, r, auth, planId, branch, false)
 	}
 
-	log.Println("Successfully processed request for BuildPlanHandler")
+	logger.Info("Successfully processed request for BuildPlanHandler")
 }
 
 func ConnectPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ConnectPlanHandler", "ip:", host.Ip)
+	logger := logging.Ctx(r.Context())
+
+	logger.Infof("Received request for ConnectPlanHandler ip: %v", host.Ip)
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
-	log.Println("planId: ", planId)
-	log.Println("branch: ", branch)
-	active := modelPlan.GetActivePlan(planId, branch)
-	isProxy := r.URL.Query().Get("proxy") == "true"
-
-	if active == nil {
-		if isProxy {
-			log.Println("No active plan on proxied request")
-			http.Error(w, "No active plan", http.StatusNotFound)
-			return
-		}
-
-		log.Println("No active plan -- proxying request")
-
-		proxyActivePlanMethod(w, r, planId, branch, "connect")
-		return
-	}
+	logger.Infof("planId: %v", planId)
+	logger.Infof("branch: %v", branch)
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
-		log.Println("No auth")
+		logger.Info("No auth")
 		return
 	}
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranch(w, planId, branch, auth)
 	if plan == nil {
-		log.Println("No plan")
+		logger.Info("No plan")
+		return
+	}
+
+	active := modelPlan.GetActivePlan(planId, branch)
+
+	if active == nil {
+		logger.Info("No active plan on this server -- connecting to remote plan")
+		startRemoteResponseStream(w, r, auth, planId, branch)
 		return
 	}
 
-	startResponseStream(w, auth, planId, branch, true)
+	startResponseStream(w, r, auth, planId, branch, true)
 
-	log.Println("Successfully processed request for ConnectPlanHandler")
+	logger.Info("Successfully processed request for ConnectPlanHandler")
 }
 
 func StopPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for StopPlanHandler", "ip:", host.Ip)
+	logger := logging.Ctx(r.Context())
+
+	logger.Infof("Received request for StopPlanHandler ip: %v", host.Ip)
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
-	log.Println("planId: ", planId)
-	log.Println("branch: ", branch)
-	active := modelPlan.GetActivePlan(planId, branch)
-	isProxy := r.URL.Query().Get("proxy") == "true"
-
-	if active == nil {
-		if isProxy {
-			log.Println("No active plan on proxied request")
-			http.Error(w, "No active plan", http.StatusNotFound)
-			return
-		}
-		proxyActivePlanMethod(w, r, planId, branch, "stop")
-		return
-	}
+	logger.Infof("planId: %v", planId)
+	logger.Infof("branch: %v", branch)
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
-	log.Println("Sending stream aborted message to client")
-
-	active.Stream(shared.StreamMessage{
-		Type: shared.StreamMessageAborted,
-	})
-
-	// give some time for stream message to be processed before canceling
-	log.Println("Sleeping for 100ms before canceling")
-	time.Sleep(100 * time.Millisecond)
+	active := modelPlan.GetActivePlan(planId, branch)
 
-	var err error
-	ctx, cancel := context.WithCancel(context.Background())
-	unlockFn := lockRepo(w, r, auth, db.LockScopeWrite, ctx, cancel, true)
-	if unlockFn == nil {
+	if active == nil {
+		logger.Info("No active plan on this server -- sending stop to remote plan")
+		sendRemotePlanCommand(w, r, planId, branch, nil, &types.PlanCommand{
+			Type:   types.PlanCommandStop,
+			UserId: auth.User.Id,
+			OrgId:  auth.OrgId,
+		})
 		return
-	} else {
-		defer func() {
-			(*unlockFn)(err)
-
-			if err == nil {
-				err = modelPlan.Stop(planId, branch, auth.User.Id, auth.OrgId)
-
-				if err != nil {
-					log.Printf("Error stopping plan: %v\n", err)
-					http.Error(w, "Error stopping plan", http.StatusInternalServerError)
-					return
-				}
-
-				log.Println("Successfully processed request for StopPlanHandler")
-			}
-		}()
 	}
 
-	log.Println("Stopping plan")
-	err = modelPlan.StorePartialReply(planId, branch, auth.User.Id, auth.OrgId)
+	err := modelPlan.StopActivePlan(planId, branch, auth.User.Id, auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error storing partial reply: %v\n", err)
-		http.Error(w, "Error storing partial reply", http.StatusInternalServerError)
+		logger.Errorf("Error stopping plan: %v", err)
+		http.Error(w, "Error stopping plan", http.StatusInternalServerError)
 		return
 	}
+
+	logger.Info("Successfully processed request for StopPlanHandler")
 }
 
 func RespondMissingFileHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RespondMissingFileHandler", "ip:", host.Ip)
+	logger := logging.Ctx(r.Context())
+
+	logger.Infof("Received request for RespondMissingFileHandler ip: %v", host.Ip)
 
 	vars := mux.Vars(r)
 	planId := vars["planId"]
 	branch := vars["branch"]
-	log.Println("planId: ", planId)
-	log.Println("branch: ", branch)
-	isProxy := r.URL.Query().Get("proxy") == "true"
-
-	active := modelPlan.GetActivePlan(planId, branch)
-	if active == nil {
-		if isProxy {
-			log.Println("No active plan on proxied request")
-			http.Error(w, "No active plan", http.StatusNotFound)
-			return
-		}
-
-		proxyActivePlanMethod(w, r, planId, branch, "respond_missing_file")
-		return
-	}
+	logger.Infof("planId: %v", planId)
+	logger.Infof("branch: %v", branch)
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
@@ -334,7 +290,7 @@ func RespondMissingFileHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -342,15 +298,26 @@ func RespondMissingFileHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.RespondMissingFileRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
-	log.Println("missing file choice:", requestBody.Choice)
+	logger.Infof("missing file choice: %v", requestBody.Choice)
+
+	active := modelPlan.GetActivePlan(planId, branch)
+
+	if active == nil {
+		// make sure some server is running the plan before loading the file
+		if getRemoteModelStream(w, r, planId, branch) == nil {
+			return
+		}
+	}
+
+	var dbContext *db.Context
 
 	if requestBody.Choice == shared.RespondMissingFileChoiceLoad {
-		log.Println("loading missing file")
+		logger.Info("loading missing file")
 		res, dbContexts := loadContexts(w, r, auth, &shared.LoadContextRequest{
 			&shared.LoadContextParams{
 				ContextType: shared.ContextFileType,
@@ -363,34 +330,30 @@ func RespondMissingFileHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		dbContext := dbContexts[0]
+		dbContext = dbContexts[0]
 
-		log.Println("loaded missing file:", dbContext.FilePath)
+		logger.Infof("loaded missing file: %v", dbContext.FilePath)
+	}
 
-		modelPlan.UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
-			activePlan.Contexts = append(activePlan.Contexts, dbContext)
-			activePlan.ContextsByPath[dbContext.FilePath] = dbContext
+	if active == nil {
+		logger.Info("No active plan on this server -- sending missing file response to remote plan")
+		sendRemotePlanCommand(w, r, planId, branch, nil, &types.PlanCommand{
+			Type:               types.PlanCommandRespondMissingFile,
+			UserId:             auth.User.Id,
+			OrgId:              auth.OrgId,
+			MissingFileChoice:  requestBody.Choice,
+			MissingFileContext: dbContext,
 		})
+		return
 	}
 
-	// This will resume model stream
-	log.Println("Resuming model stream")
-	active.MissingFileResponseCh <- requestBody.Choice
+	err = modelPlan.RespondMissingFile(planId, branch, requestBody.Choice, dbContext)
 
-	log.Println("Successfully processed request for RespondMissingFileHandler")
-}
-
-func authorizePlanExecUpdate(w http.ResponseWriter, planId string, auth *types.ServerAuth) *db.Plan {
-	plan := authorizePlan(w, planId, auth)
-	if plan == nil {
-		return nil
-	}
-
-	if plan.OwnerId != auth.User.Id && !auth.HasPermission(types.PermissionUpdateAnyPlan) {
-		log.Println("User does not have permission to update plan")
-		http.Error(w, "User does not have permission to update plan", http.StatusForbidden)
-		return nil
+	if err != nil {
+		logger.Errorf("Error responding to missing file: %v", err)
+		http.Error(w, "Error responding to missing file", http.StatusInternalServerError)
+		return
 	}
 
-	return plan
+	logger.Info("Successfully processed request for RespondMissingFileHandler")
 }
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/types"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+// Review comments can be left by anyone who can read the branch, including org members it's been shared with. Resolving them takes permission to update the plan.
+
+func ListReviewCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListReviewCommentsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
+		return
+	}
+
+	dbBranch := getReviewBranch(w, r, planId, branch)
+	if dbBranch == nil {
+		return
+	}
+
+	comments, err := db.ListReviewComments(dbBranch.Id, r.URL.Query().Get("all") == "true")
+
+	if err != nil {
+		logger.Errorf("Error listing review comments: %v", err)
+		http.Error(w, "Error listing review comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := []*shared.ReviewComment{}
+	for _, comment := range comments {
+		res = append(res, comment.ToApi(branch))
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Errorf("Error marshalling review comments: %v", err)
+		http.Error(w, "Error marshalling review comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+}
+
+func CreateReviewCommentHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateReviewCommentHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	plan := authorizePlanBranch(w, planId, branch, auth)
+	if plan == nil {
+		return
+	}
+
+	var req shared.CreateReviewCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+
+	if err != nil {
+		logger.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Error decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = shared.ValidateReviewComment(req)
+
+	if err != nil {
+		logger.Infof("Invalid review comment: %v", err)
+		http.Error(w, "Invalid review comment: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbBranch := getReviewBranch(w, r, planId, branch)
+	if dbBranch == nil {
+		return
+	}
+
+	comment := &db.ReviewComment{
+		OrgId:       auth.OrgId,
+		PlanId:      plan.Id,
+		BranchId:    dbBranch.Id,
+		AuthorId:    auth.User.Id,
+		Path:        strings.TrimSpace(req.Path),
+		Body:        strings.TrimSpace(req.Body),
+		AuthorName:  auth.User.Name,
+		AuthorEmail: auth.User.Email,
+	}
+	if req.ReplacementId != "" {
+		comment.ReplacementId = &req.ReplacementId
+	}
+
+	err = db.CreateReviewComment(comment)
+
+	if err != nil {
+		logger.Errorf("Error creating review comment: %v", err)
+		http.Error(w, "Error creating review comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(comment.ToApi(branch))
+
+	if err != nil {
+		logger.Errorf("Error marshalling review comment: %v", err)
+		http.Error(w, "Error marshalling review comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Created review comment %s", comment.Id)
+
+	w.Write(bytes)
+}
+
+func ResolveReviewCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ResolveReviewCommentsHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
+		return
+	}
+
+	var req shared.ResolveReviewCommentsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+
+	if err != nil {
+		logger.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Error decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dbBranch := getReviewBranch(w, r, planId, branch)
+	if dbBranch == nil {
+		return
+	}
+
+	numResolved, err := db.ResolveReviewComments(dbBranch.Id, req.Ids)
+
+	if err != nil {
+		logger.Errorf("Error resolving review comments: %v", err)
+		http.Error(w, "Error resolving review comments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Resolved %d review comments", numResolved)
+}
+
+func DeleteReviewCommentHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteReviewCommentHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+	commentId := vars["commentId"]
+
+	logger.Infof("planId: %v branch: %v commentId: %v", planId, branch, commentId)
+
+	plan := authorizePlanBranch(w, planId, branch, auth)
+	if plan == nil {
+		return
+	}
+
+	dbBranch := getReviewBranch(w, r, planId, branch)
+	if dbBranch == nil {
+		return
+	}
+
+	comment, err := db.GetReviewComment(dbBranch.Id, commentId)
+
+	if err != nil {
+		logger.Errorf("Error getting review comment: %v", err)
+		http.Error(w, "Error getting review comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if comment == nil {
+		logger.Info("Review comment not found")
+		http.Error(w, "Review comment not found", http.StatusNotFound)
+		return
+	}
+
+	// authors can delete their own comments, and anyone who can update the plan can delete any comment on it
+	if comment.AuthorId != auth.User.Id && plan.OwnerId != auth.User.Id && !auth.HasProjectPermission(types.PermissionUpdateAnyPlan, plan.ProjectId) {
+		logger.Info("User does not have permission to delete review comment")
+		http.Error(w, "User does not have permission to delete review comment", http.StatusForbidden)
+		return
+	}
+
+	err = db.DeleteReviewComment(comment.Id)
+
+	if err != nil {
+		logger.Errorf("Error deleting review comment: %v", err)
+		http.Error(w, "Error deleting review comment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Deleted review comment %s", comment.Id)
+}
+
+func getReviewBranch(w http.ResponseWriter, r *http.Request, planId, branch string) *db.Branch {
+	logger := logging.Ctx(r.Context())
+
+	dbBranch, err := db.GetDbBranch(planId, branch)
+
+	if err != nil {
+		logger.Errorf("Error getting branch: %v", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	if dbBranch == nil {
+		logger.Infof("Branch %s not found", branch)
+		http.Error(w, "Branch not found", http.StatusNotFound)
+		return nil
+	}
+
+	return dbBranch
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+func SharePlanHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for SharePlanHandler")
+
+	setBranchShared(w, r, true)
+}
+
+func UnsharePlanHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UnsharePlanHandler")
+
+	setBranchShared(w, r, false)
+}
+
+// setBranchShared shares a branch with the org read-only, or stops sharing it. Only the plan's owner can share, while users with the manage_any_plan_shares permission can also unshare.
+func setBranchShared(w http.ResponseWriter, r *http.Request, share bool) {
+	logger := logging.Ctx(r.Context())
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	vars := mux.Vars(r)
+	planId := vars["planId"]
+	branch := vars["branch"]
+
+	logger.Infof("planId: %v branch: %v", planId, branch)
+
+	var plan *db.Plan
+	if share {
+		plan = authorizePlanShare(w, planId, auth)
+	} else {
+		plan = authorizePlanUnshare(w, planId, auth)
+	}
+	if plan == nil {
+		return
+	}
+
+	dbBranch, err := db.GetDbBranch(planId, branch)
+
+	if err != nil {
+		logger.Errorf("Error getting branch: %v", err)
+		http.Error(w, "Error getting branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if dbBranch == nil {
+		logger.Infof("Branch %s not found", branch)
+		http.Error(w, "Branch not found", http.StatusNotFound)
+		return
+	}
+
+	if (dbBranch.SharedWithOrgAt != nil) == share {
+		logger.Info("Branch sharing is already up to date")
+		return
+	}
+
+	err = db.SetBranchSharedWithOrg(planId, branch, share)
+
+	if err != nil {
+		logger.Errorf("Error updating branch sharing: %v", err)
+		http.Error(w, "Error updating branch sharing: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	action := shared.AuditActionPlanShare
+	if !share {
+		action = shared.AuditActionPlanUnshare
+	}
+	recordAuditEvent(r, auth, action, planId, map[string]string{"branch": branch})
+
+	logger.Infof("Successfully updated branch sharing, shared: %v", share)
+}
+
+func ListSharedPlansHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListSharedPlansHandler")
+
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	projectIds := r.URL.Query()["projectId"]
+
+	logger.Infof("projectIds: %v", projectIds)
+
+	if len(projectIds) == 0 {
+		logger.Info("No project ids provided")
+		http.Error(w, "No project ids provided", http.StatusBadRequest)
+		return
+	}
+
+	authorizedProjectIds := []string{}
+	for _, projectId := range projectIds {
+		if authorizeProjectOptional(w, projectId, auth, false) {
+			authorizedProjectIds = append(authorizedProjectIds, projectId)
+		}
+	}
+
+	if len(authorizedProjectIds) == 0 {
+		logger.Info("No authorized project ids provided")
+		http.Error(w, "No authorized project ids provided", http.StatusForbidden)
+		return
+	}
+
+	plans, err := db.ListSharedPlans(auth.OrgId, authorizedProjectIds, auth.User.Id)
+
+	if err != nil {
+		logger.Errorf("Error listing shared plans: %v", err)
+		http.Error(w, "Error listing shared plans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var planIds []string
+	for _, plan := range plans {
+		planIds = append(planIds, plan.Id)
+	}
+
+	branchesByPlanId, err := db.ListSharedBranchNames(planIds)
+
+	if err != nil {
+		logger.Errorf("Error listing shared branches: %v", err)
+		http.Error(w, "Error listing shared branches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	users, err := db.ListUsers(auth.OrgId)
+
+	if err != nil {
+		logger.Errorf("Error listing users: %v", err)
+		http.Error(w, "Error listing users: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	usersById := make(map[string]*db.User)
+	for _, user := range users {
+		usersById[user.Id] = user
+	}
+
+	res := []*shared.SharedPlan{}
+	for _, plan := range plans {
+		sharedPlan := &shared.SharedPlan{
+			Plan:     plan.ToApi(),
+			Branches: branchesByPlanId[plan.Id],
+		}
+		// owners who have left the org aren't listed by ListUsers, but their shared plans are still readable
+		if owner := usersById[plan.OwnerId]; owner != nil {
+			sharedPlan.OwnerName = owner.Name
+			sharedPlan.OwnerEmail = owner.Email
+		}
+		res = append(res, sharedPlan)
+	}
+
+	bytes, err := json.Marshal(res)
+
+	if err != nil {
+		logger.Errorf("Error marshalling shared plans: %v", err)
+		http.Error(w, "Error marshalling shared plans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+}
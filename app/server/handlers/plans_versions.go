@@ -4,16 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func ListLogsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListLogsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListLogsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -24,9 +26,9 @@ func ListLogsHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranch(w, planId, branch, auth) == nil {
 		return
 	}
 
@@ -44,7 +46,7 @@ func ListLogsHandler(w http.ResponseWriter, r *http.Request) {
 	body, shas, err := db.GetGitCommitHistory(auth.OrgId, planId, branch)
 
 	if err != nil {
-		log.Println("Error getting logs: ", err)
+		logger.Errorf("Error getting logs: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -57,18 +59,20 @@ func ListLogsHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Println("Error marshalling logs: ", err)
+		logger.Errorf("Error marshalling logs: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("Successfully processed request for ListLogsHandler")
+	logger.Info("Successfully processed request for ListLogsHandler")
 }
 
 func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RewindPlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RewindPlanHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -79,16 +83,16 @@ func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId)
+	logger.Infof("planId: %v", planId)
 
-	if authorizePlan(w, planId, auth) == nil {
+	if authorizePlanBranchUpdate(w, planId, branch, auth) == nil {
 		return
 	}
 
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -96,7 +100,7 @@ func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.RewindPlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
@@ -114,15 +118,17 @@ func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitRewindToSha(auth.OrgId, planId, branch, requestBody.Sha)
 
 	if err != nil {
-		log.Println("Error rewinding plan: ", err)
+		logger.Errorf("Error rewinding plan: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	recordAuditEvent(r, auth, shared.AuditActionPlanRewind, planId, map[string]string{"branch": branch, "sha": requestBody.Sha})
+
 	err = db.SyncPlanTokens(auth.OrgId, planId, branch)
 
 	if err != nil {
-		log.Println("Error syncing plan tokens: ", err)
+		logger.Errorf("Error syncing plan tokens: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -130,7 +136,7 @@ func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
 	sha, latest, err := db.GetLatestCommit(auth.OrgId, planId, branch)
 
 	if err != nil {
-		log.Println("Error getting latest commit: ", err)
+		logger.Errorf("Error getting latest commit: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -143,12 +149,12 @@ func RewindPlanHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Println("Error marshalling response: ", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("Successfully processed request for RewindPlanHandler")
+	logger.Info("Successfully processed request for RewindPlanHandler")
 }
@@ -3,26 +3,34 @@ package handlers
 import (
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 
 	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateProjectHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateProjectHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
 	}
 
+	if auth.AccessToken != nil && auth.AccessToken.ProjectIds != nil {
+		logger.Info("Access token limited to specific projects can't create a project")
+		http.Error(w, "Access token is limited to specific projects, so it can't create a new one", http.StatusForbidden)
+		return
+	}
+
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -30,13 +38,13 @@ func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.CreateProjectRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if requestBody.Name == "" {
-		log.Println("Received empty name field")
+		logger.Info("Received empty name field")
 		http.Error(w, "name field is required", http.StatusBadRequest)
 		return
 	}
@@ -44,7 +52,7 @@ func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -53,9 +61,9 @@ func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -63,14 +71,14 @@ func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	projectId, err := db.CreateProject(auth.OrgId, requestBody.Name, tx)
 
 	if err != nil {
-		log.Printf("Error creating project: %v\n", err)
+		logger.Errorf("Error creating project: %v", err)
 		http.Error(w, "Error creating project: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -82,18 +90,20 @@ func CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("Successfully created project", projectId)
+	logger.Infof("Successfully created project %v", projectId)
 }
 
 func ListProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for ListProjectsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListProjectsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -103,7 +113,7 @@ func ListProjectsHandler(w http.ResponseWriter, r *http.Request) {
 	rows, err := db.Conn.Query("SELECT id, name FROM projects WHERE org_id = $1", auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error listing projects: %v\n", err)
+		logger.Errorf("Error listing projects: %v", err)
 		http.Error(w, "Error listing projects: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -114,16 +124,19 @@ func ListProjectsHandler(w http.ResponseWriter, r *http.Request) {
 		var project shared.Project
 		err := rows.Scan(&project.Id, &project.Name)
 		if err != nil {
-			log.Printf("Error scanning project: %v\n", err)
+			logger.Errorf("Error scanning project: %v", err)
 			http.Error(w, "Error scanning project: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !auth.CanAccessProject(project.Id) {
+			continue
+		}
 		projects = append(projects, project)
 	}
 
 	bytes, err := json.Marshal(projects)
 	if err != nil {
-		log.Printf("Error marshalling projects: %v\n", err)
+		logger.Errorf("Error marshalling projects: %v", err)
 		http.Error(w, "Error marshalling projects: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -132,7 +145,9 @@ func ListProjectsHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func ProjectSetPlanHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for UpdateProjectSetPlanHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UpdateProjectSetPlanHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -141,7 +156,7 @@ func ProjectSetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectId := vars["projectId"]
 
-	log.Println("projectId: ", projectId)
+	logger.Infof("projectId: %v", projectId)
 
 	if !authorizeProject(w, projectId, auth) {
 		return
@@ -150,7 +165,7 @@ func ProjectSetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -158,13 +173,13 @@ func ProjectSetPlanHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.SetProjectPlanRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if requestBody.PlanId == "" {
-		log.Println("Received empty planId field")
+		logger.Info("Received empty planId field")
 		http.Error(w, "planId field is required", http.StatusBadRequest)
 		return
 	}
@@ -172,16 +187,18 @@ func ProjectSetPlanHandler(w http.ResponseWriter, r *http.Request) {
 	// update statement here -- need auth / current user id
 
 	if err != nil {
-		log.Printf("Error updating project: %v\n", err)
+		logger.Errorf("Error updating project: %v", err)
 		http.Error(w, "Error updating project: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully set project plan", projectId)
+	logger.Infof("Successfully set project plan %v", projectId)
 }
 
 func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for RenameProjectHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RenameProjectHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -190,7 +207,7 @@ func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectId := vars["projectId"]
 
-	log.Println("projectId: ", projectId)
+	logger.Infof("projectId: %v", projectId)
 
 	if !authorizeProjectRename(w, projectId, auth) {
 		return
@@ -199,7 +216,7 @@ func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
@@ -207,13 +224,13 @@ func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
 
 	var requestBody shared.RenameProjectRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
-		log.Printf("Error parsing request body: %v\n", err)
+		logger.Errorf("Error parsing request body: %v", err)
 		http.Error(w, "Error parsing request body", http.StatusBadRequest)
 		return
 	}
 
 	if requestBody.Name == "" {
-		log.Println("Received empty name field")
+		logger.Info("Received empty name field")
 		http.Error(w, "name field is required", http.StatusBadRequest)
 		return
 	}
@@ -221,7 +238,7 @@ func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
 	res, err := db.Conn.Exec("UPDATE projects SET name = $1 WHERE id = $2", requestBody.Name, projectId)
 
 	if err != nil {
-		log.Printf("Error updating project: %v\n", err)
+		logger.Errorf("Error updating project: %v", err)
 		http.Error(w, "Error updating project: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -229,17 +246,17 @@ func RenameProjectHandler(w http.ResponseWriter, r *http.Request) {
 	rowsAffected, err := res.RowsAffected()
 
 	if err != nil {
-		log.Printf("Error getting rows affected: %v\n", err)
+		logger.Errorf("Error getting rows affected: %v", err)
 		http.Error(w, "Error getting rows affected: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("Project not found: %v\n", projectId)
+		logger.Warnf("Project not found: %v", projectId)
 		http.Error(w, "Project not found: "+projectId, http.StatusNotFound)
 		return
 	}
 
-	log.Println("Successfully renamed project", projectId)
+	logger.Infof("Successfully renamed project %v", projectId)
 
 }
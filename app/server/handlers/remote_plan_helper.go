@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	modelPlan "plandex-server/model/plan"
+	"plandex-server/types"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+// how often a remote stream checks that the plan's server is still alive
+const remoteStreamCheckInterval = 5 * time.Second
+
+// getRemoteModelStream returns the model stream for a plan that's active on another server, writing an error response if there isn't one
+func getRemoteModelStream(w http.ResponseWriter, r *http.Request, planId, branch string) *db.ModelStream {
+	logger := logging.Ctx(r.Context())
+
+	modelStream, err := db.GetActiveModelStream(planId, branch)
+
+	if err != nil {
+		logger.Errorf("Error getting active model stream: %v", err)
+		http.Error(w, "Error getting active model stream", http.StatusInternalServerError)
+		return nil
+	}
+
+	if modelStream == nil {
+		logger.Infof("No active model stream for plan %s", planId)
+		http.Error(w, "No active model stream for plan", http.StatusNotFound)
+		return nil
+	}
+
+	return modelStream
+}
+
+// sendRemotePlanCommand sends a command to the server running a plan and writes the response. Pass a nil modelStream to look it up.
+func sendRemotePlanCommand(w http.ResponseWriter, r *http.Request, planId, branch string, modelStream *db.ModelStream, cmd *types.PlanCommand) bool {
+	logger := logging.Ctx(r.Context())
+
+	if modelStream == nil {
+		modelStream = getRemoteModelStream(w, r, planId, branch)
+		if modelStream == nil {
+			return false
+		}
+	}
+
+	err := modelPlan.SendPlanCommand(r.Context(), planId, branch, cmd)
+
+	if err == modelPlan.ErrPlanCommandTimeout {
+		// the model stream is still heartbeating but nothing is listening for it, so something went wrong -- set it to finished
+		err := db.SetModelStreamFinished(modelStream.Id)
+		if err != nil {
+			logger.Errorf("Error setting model stream %s to finished: %v", modelStream.Id, err)
+		}
+
+		err = db.SetPlanStatus(planId, branch, shared.PlanStatusError, "No active stream for plan")
+		if err != nil {
+			logger.Errorf("Error setting plan %s status to error: %v", planId, err)
+		}
+
+		logger.Infof("No active plan for plan %s", planId)
+		http.Error(w, "No active plan for plan", http.StatusNotFound)
+		return false
+	} else if err != nil {
+		logger.Errorf("Error sending %s command to plan %s: %v", cmd.Type, planId, err)
+		http.Error(w, "Error sending command to plan: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// startRemoteResponseStream streams a plan that's active on another server, relaying its messages from the event bus
+func startRemoteResponseStream(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, planId, branch string) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Remote stream: connecting to plan stream")
+
+	// subscribe before connecting so the catch-up messages aren't missed
+	events, unsubscribe, err := db.SubscribeEvents(types.PlanStreamChannel(planId, branch))
+	if err != nil {
+		logger.Errorf("Remote stream: error subscribing to plan stream: %v", err)
+		http.Error(w, "Error subscribing to plan stream", http.StatusInternalServerError)
+		return
+	}
+	defer unsubscribe()
+
+	modelStream := getRemoteModelStream(w, r, planId, branch)
+	if modelStream == nil {
+		return
+	}
+
+	subscriberId := uuid.New().String()
+	lastSeq, resume := getResumeSeq(r, modelStream.Id)
+
+	ok := sendRemotePlanCommand(w, r, planId, branch, modelStream, &types.PlanCommand{
+		Type:         types.PlanCommandConnect,
+		UserId:       auth.User.Id,
+		OrgId:        auth.OrgId,
+		SubscriberId: subscriberId,
+		Resume:       resume,
+		LastSeq:      lastSeq,
+	})
+	if !ok {
+		return
+	}
+
+	defer func() {
+		logger.Info("Remote stream: client stream closed")
+
+		bytes, err := json.Marshal(types.PlanCommand{
+			Id:           uuid.New().String(),
+			Type:         types.PlanCommandDisconnect,
+			SubscriberId: subscriberId,
+		})
+		if err != nil {
+			logger.Errorf("Remote stream: error marshalling disconnect command: %v", err)
+			return
+		}
+
+		err = db.PublishEvent(types.PlanCommandChannel(planId, branch), bytes)
+		if err != nil {
+			logger.Errorf("Remote stream: error sending disconnect command: %v", err)
+		}
+	}()
+
+	stream := newPlanStreamWriter(w, r, modelStream.Id)
+	stopStream := stream.start()
+	defer stopStream()
+
+	bytes, err := json.Marshal(shared.StreamMessage{
+		Type:          shared.StreamMessageStart,
+		ModelStreamId: modelStream.Id,
+	})
+	if err != nil {
+		logger.Errorf("Remote stream: error marshalling message: %v", err)
+		return
+	}
+
+	err = stream.send(string(bytes))
+	if err != nil {
+		logger.Errorf("Remote stream: error sending initial message: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(remoteStreamCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			modelStream, err := db.GetActiveModelStream(planId, branch)
+			if err != nil {
+				logger.Errorf("Remote stream: error getting active model stream: %v", err)
+			} else if modelStream == nil {
+				logger.Info("Remote stream: model stream finished")
+				return
+			}
+
+		case payload := <-events:
+			var event types.PlanStreamEvent
+			err := json.Unmarshal(payload, &event)
+			if err != nil {
+				logger.Errorf("Remote stream: error unmarshalling stream event: %v", err)
+				continue
+			}
+
+			if event.Done {
+				logger.Info("Remote stream: plan finished")
+				return
+			}
+
+			if event.SubscriberId != "" && event.SubscriberId != subscriberId {
+				continue
+			}
+
+			err = stream.send(string(event.Message))
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -4,31 +4,42 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/email"
+	"plandex-server/logging"
+	"plandex-server/sso"
 	"strings"
 
+	"github.com/gorilla/mux"
 	"github.com/plandex/plandex/shared"
 )
 
 func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for CreateEmailVerificationHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateEmailVerificationHandler")
 
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if sso.Required() {
+		logger.Info("Email verification disabled because sso is required")
+		http.Error(w, fmt.Sprintf("This server requires signing in with %s", sso.ProviderName()), http.StatusForbidden)
+		return
+	}
+
 	var req shared.CreateEmailVerificationRequest
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -37,7 +48,7 @@ func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
 	// create pin - 6 alphanumeric characters
 	pinBytes, err := shared.GetRandomAlphanumeric(6)
 	if err != nil {
-		log.Printf("Error generating random pin: %v\n", err)
+		logger.Errorf("Error generating random pin: %v", err)
 		http.Error(w, "Error generating random pin: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -50,7 +61,7 @@ func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.CreateEmailVerification(req.Email, req.UserId, pinHash)
 
 	if err != nil {
-		log.Printf("Error creating email verification: %v\n", err)
+		logger.Errorf("Error creating email verification: %v", err)
 		http.Error(w, "Error creating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -58,7 +69,7 @@ func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
 	err = email.SendVerificationEmail(req.Email, string(pinBytes))
 
 	if err != nil {
-		log.Printf("Error sending verification email: %v\n", err)
+		logger.Errorf("Error sending verification email: %v", err)
 		http.Error(w, "Error sending verification email: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -68,7 +79,7 @@ func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
 		user, err := db.GetUserByEmail(req.Email)
 
 		if err != nil {
-			log.Printf("Error getting user: %v\n", err)
+			logger.Errorf("Error getting user: %v", err)
 			http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -85,23 +96,25 @@ func CreateEmailVerificationHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully created email verification")
+	logger.Info("Successfully created email verification")
 
 	w.Write(bytes)
 }
 
 func SignInHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for SignInHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for SignInHandler")
 
 	// read the request body
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error reading request body: %v\n", err)
+		logger.Errorf("Error reading request body: %v", err)
 		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -109,7 +122,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	var req shared.SignInRequest
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		log.Printf("Error unmarshalling request: %v\n", err)
+		logger.Errorf("Error unmarshalling request: %v", err)
 		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -118,19 +131,19 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := db.GetUserByEmail(req.Email)
 
 	if err != nil {
-		log.Printf("Error getting user: %v\n", err)
+		logger.Errorf("Error getting user: %v", err)
 		http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if user == nil {
-		log.Printf("User not found for email: %v\n", req.Email)
+		logger.Warnf("User not found for email: %v", req.Email)
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
 	if user != nil && user.IsTrial {
-		log.Printf("Trial user can't sign in: %v\n", req.Email)
+		logger.Infof("Trial user can't sign in: %v", req.Email)
 		http.Error(w, "Trial user can't sign in", http.StatusForbidden)
 		return
 	}
@@ -138,7 +151,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	emailVerificationId, err := db.ValidateEmailVerification(req.Email, req.Pin)
 
 	if err != nil {
-		log.Printf("Error validating email verification: %v\n", err)
+		logger.Errorf("Error validating email verification: %v", err)
 		http.Error(w, "Error validating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -146,7 +159,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -155,18 +168,18 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
 
 	// create auth token
-	token, authTokenId, err := db.CreateAuthToken(user.Id, false, tx)
+	token, authTokenId, err := db.CreateAuthToken(user.Id, false, authTokenClient(r), tx)
 
 	if err != nil {
-		log.Printf("Error creating auth token: %v\n", err)
+		logger.Errorf("Error creating auth token: %v", err)
 		http.Error(w, "Error creating auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -175,7 +188,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	_, err = tx.Exec("UPDATE email_verifications SET user_id = $1, auth_token_id = $2 WHERE id = $3", user.Id, authTokenId, emailVerificationId)
 
 	if err != nil {
-		log.Printf("Error updating email verification: %v\n", err)
+		logger.Errorf("Error updating email verification: %v", err)
 		http.Error(w, "Error updating email verification: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -183,7 +196,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	// commit transaction
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error committing transaction: %v\n", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -192,7 +205,7 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	orgs, err := db.GetAccessibleOrgsForUser(user)
 
 	if err != nil {
-		log.Printf("Error getting orgs for user: %v\n", err)
+		logger.Errorf("Error getting orgs for user: %v", err)
 		http.Error(w, "Error getting orgs for user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -213,18 +226,20 @@ func SignInHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Printf("Error marshalling response: %v\n", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully signed in")
+	logger.Info("Successfully signed in")
 
 	w.Write(bytes)
 }
 
 func SignOutHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for SignOutHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for SignOutHandler")
 
 	auth := authenticate(w, r, false)
 	if auth == nil {
@@ -234,10 +249,107 @@ func SignOutHandler(w http.ResponseWriter, r *http.Request) {
 	_, err := db.Conn.Exec("UPDATE auth_tokens SET deleted_at = NOW() WHERE token_hash = $1", auth.AuthToken.TokenHash)
 
 	if err != nil {
-		log.Printf("Error deleting auth token: %v\n", err)
+		logger.Errorf("Error deleting auth token: %v", err)
 		http.Error(w, "Error deleting auth token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully signed out")
+	logger.Info("Successfully signed out")
+}
+
+func ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListSessionsHandler")
+
+	auth := authenticate(w, r, false)
+	if auth == nil {
+		return
+	}
+
+	authTokens, err := db.ListAuthTokens(auth.User.Id)
+
+	if err != nil {
+		logger.Errorf("Error listing sessions: %v", err)
+		http.Error(w, "Error listing sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessions := []*shared.Session{}
+	for _, authToken := range authTokens {
+		sessions = append(sessions, authToken.ToApiSession(auth.AuthToken.Id))
+	}
+
+	bytes, err := json.Marshal(sessions)
+
+	if err != nil {
+		logger.Errorf("Error marshalling sessions: %v", err)
+		http.Error(w, "Error marshalling sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Info("Successfully listed sessions")
+}
+
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RevokeSessionHandler")
+
+	auth := authenticate(w, r, false)
+	if auth == nil {
+		return
+	}
+
+	sessionId := mux.Vars(r)["sessionId"]
+
+	revoked, err := db.RevokeAuthToken(auth.User.Id, sessionId)
+
+	if err != nil {
+		logger.Errorf("Error revoking session: %v", err)
+		http.Error(w, "Error revoking session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !revoked {
+		logger.Warnf("Session not found: %s", sessionId)
+		http.Error(w, "Session not found: "+sessionId, http.StatusNotFound)
+		return
+	}
+
+	logger.Infof("Successfully revoked session %s", sessionId)
+}
+
+// RevokeOtherSessionsHandler signs the user out everywhere but the session making the request
+func RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for RevokeOtherSessionsHandler")
+
+	auth := authenticate(w, r, false)
+	if auth == nil {
+		return
+	}
+
+	numRevoked, err := db.RevokeOtherAuthTokens(auth.User.Id, auth.AuthToken.Id)
+
+	if err != nil {
+		logger.Errorf("Error revoking sessions: %v", err)
+		http.Error(w, "Error revoking sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(shared.RevokeSessionsResponse{NumRevoked: numRevoked})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Infof("Successfully revoked %d other sessions", numRevoked)
 }
@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"reflect"
 
 	"github.com/gorilla/mux"
@@ -14,7 +14,9 @@ import (
 )
 
 func GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for GetSettingsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetSettingsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -25,9 +27,9 @@ func GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranch(w, planId, branch, auth)
 	if plan == nil {
 		return
 	}
@@ -46,7 +48,7 @@ func GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	settings, err := db.GetPlanSettings(plan, true)
 
 	if err != nil {
-		log.Println("Error getting settings: ", err)
+		logger.Errorf("Error getting settings: %v", err)
 		http.Error(w, "Error getting settings", http.StatusInternalServerError)
 		return
 	}
@@ -54,18 +56,20 @@ func GetSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(settings)
 
 	if err != nil {
-		log.Println("Error marshalling settings: ", err)
+		logger.Errorf("Error marshalling settings: %v", err)
 		http.Error(w, "Error marshalling settings", http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("GetSettingsHandler processed successfully")
+	logger.Info("GetSettingsHandler processed successfully")
 
 	w.Write(bytes)
 }
 
 func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for UpdateSettingsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UpdateSettingsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -76,9 +80,9 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	planId := vars["planId"]
 	branch := vars["branch"]
 
-	log.Println("planId: ", planId, "branch: ", branch)
+	logger.Infof("planId: %v branch: %v", planId, branch)
 
-	plan := authorizePlan(w, planId, auth)
+	plan := authorizePlanBranchUpdate(w, planId, branch, auth)
 
 	if plan == nil {
 		return
@@ -88,7 +92,7 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&req)
 
 	if err != nil {
-		log.Println("Error decoding request body: ", err)
+		logger.Errorf("Error decoding request body: %v", err)
 		http.Error(w, "Error decoding request body", http.StatusInternalServerError)
 		return
 	}
@@ -106,7 +110,7 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	originalSettings, err := db.GetPlanSettings(plan, true)
 
 	if err != nil {
-		log.Println("Error getting settings: ", err)
+		logger.Errorf("Error getting settings: %v", err)
 		http.Error(w, "Error getting settings", http.StatusInternalServerError)
 		return
 	}
@@ -120,7 +124,7 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.StorePlanSettings(plan, req.Settings)
 
 	if err != nil {
-		log.Println("Error storing settings: ", err)
+		logger.Errorf("Error storing settings: %v", err)
 		http.Error(w, "Error storing settings", http.StatusInternalServerError)
 		return
 	}
@@ -130,7 +134,7 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.GitAddAndCommit(auth.OrgId, planId, branch, commitMsg)
 
 	if err != nil {
-		log.Println("Error committing settings: ", err)
+		logger.Errorf("Error committing settings: %v", err)
 		http.Error(w, "Error committing settings", http.StatusInternalServerError)
 		return
 	}
@@ -141,19 +145,21 @@ func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Println("Error marshalling response: ", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response", http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("UpdateSettingsHandler processed successfully")
+	logger.Info("UpdateSettingsHandler processed successfully")
 
 }
 
 func GetDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for GetDefaultSettingsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetDefaultSettingsHandler")
 
 	auth := authenticate(w, r, true)
 	if auth == nil {
@@ -163,7 +169,7 @@ func GetDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	settings, err := db.GetOrgDefaultSettings(auth.OrgId, true)
 
 	if err != nil {
-		log.Println("Error getting default settings: ", err)
+		logger.Errorf("Error getting default settings: %v", err)
 		http.Error(w, "Error getting default settings", http.StatusInternalServerError)
 		return
 	}
@@ -171,18 +177,20 @@ func GetDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(settings)
 
 	if err != nil {
-		log.Println("Error marshalling default settings: ", err)
+		logger.Errorf("Error marshalling default settings: %v", err)
 		http.Error(w, "Error marshalling default settings", http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("GetDefaultSettingsHandler processed successfully")
+	logger.Info("GetDefaultSettingsHandler processed successfully")
 }
 
 func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request for UpdateDefaultSettingsHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for UpdateDefaultSettingsHandler")
 
 	auth := authenticate(w, r, true)
 
@@ -194,7 +202,7 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&req)
 
 	if err != nil {
-		log.Println("Error decoding request body: ", err)
+		logger.Errorf("Error decoding request body: %v", err)
 		http.Error(w, "Error decoding request body", http.StatusInternalServerError)
 		return
 	}
@@ -202,7 +210,7 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	tx, err := db.Conn.Beginx()
 
 	if err != nil {
-		log.Println("Error starting transaction: ", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction", http.StatusInternalServerError)
 		return
 	}
@@ -210,9 +218,9 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -220,7 +228,7 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	originalSettings, err := db.GetOrgDefaultSettingsForUpdate(auth.OrgId, tx, true)
 
 	if err != nil {
-		log.Println("Error getting default settings: ", err)
+		logger.Errorf("Error getting default settings: %v", err)
 		http.Error(w, "Error getting default settings", http.StatusInternalServerError)
 		return
 	}
@@ -233,7 +241,7 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 
 	if !req.Settings.UpdatedAt.Equal(originalSettings.UpdatedAt) {
 		err = fmt.Errorf("default settings have been updated since you last fetched them")
-		log.Println("Error updating default settings: ", err)
+		logger.Errorf("Error updating default settings: %v", err)
 		http.Error(w, "Error updating default settings: "+err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -241,35 +249,43 @@ func UpdateDefaultSettingsHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.StoreOrgDefaultSettings(auth.OrgId, req.Settings, tx)
 
 	if err != nil {
-		log.Println("Error storing default settings: ", err)
+		logger.Errorf("Error storing default settings: %v", err)
 		http.Error(w, "Error storing default settings: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	commitMsg := getUpdateCommitMsg(req.Settings, originalSettings, true)
+
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionDefaultsUpdate, auth.OrgId, map[string]string{"changes": commitMsg}), tx)
+
+	if err != nil {
+		logger.Errorf("Error creating audit event: %v", err)
+		http.Error(w, "Error creating audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	err = tx.Commit()
 
 	if err != nil {
-		log.Println("Error committing transaction: ", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	commitMsg := getUpdateCommitMsg(req.Settings, originalSettings, true)
-
 	res := shared.UpdateSettingsResponse{
 		Msg: commitMsg,
 	}
 	bytes, err := json.Marshal(res)
 
 	if err != nil {
-		log.Println("Error marshalling response: ", err)
+		logger.Errorf("Error marshalling response: %v", err)
 		http.Error(w, "Error marshalling response", http.StatusInternalServerError)
 		return
 	}
 
 	w.Write(bytes)
 
-	log.Println("UpdateDefaultSettingsHandler processed successfully")
+	logger.Info("UpdateDefaultSettingsHandler processed successfully")
 }
 
 func getUpdateCommitMsg(settings *shared.PlanSettings, originalSettings *shared.PlanSettings, isOrgDefault bool) string {
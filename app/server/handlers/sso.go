@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/sso"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func GetSsoConfigHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for GetSsoConfigHandler")
+
+	bytes, err := json.Marshal(shared.SsoConfigResponse{
+		Enabled:      sso.Enabled(),
+		Required:     sso.Required(),
+		ProviderName: sso.ProviderName(),
+	})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+}
+
+func StartSsoHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for StartSsoHandler")
+
+	if !sso.Enabled() {
+		http.Error(w, "SSO isn't configured on this server", http.StatusNotFound)
+		return
+	}
+
+	login, pollSecret, err := db.CreateSsoLogin()
+
+	if err != nil {
+		logger.Errorf("Error creating sso login: %v", err)
+		http.Error(w, "Error creating sso login: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authUrl, err := sso.AuthCodeUrl(r.Context(), login.State, login.Nonce, login.CodeVerifier)
+
+	if err != nil {
+		logger.Errorf("Error getting sso auth url: %v", err)
+		http.Error(w, "Error getting sso auth url: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	bytes, err := json.Marshal(shared.StartSsoResponse{
+		LoginId:    login.Id,
+		PollSecret: pollSecret,
+		AuthUrl:    authUrl,
+		UserCode:   db.FormatSsoUserCode(login.UserCode),
+		ExpiresAt:  login.CreatedAt.Add(db.SsoLoginExpiration),
+	})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Started sso login %s", login.Id)
+
+	w.Write(bytes)
+}
+
+// SsoCallbackHandler is where the identity provider redirects the user's browser after they sign in. Rather than finishing the login, it asks for the code the CLI is showing, so that a sign in link sent to someone else can't be used to sign in as them. The form posts back to ConfirmSsoHandler.
+func SsoCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for SsoCallbackHandler")
+
+	if !sso.Enabled() {
+		writeSsoPage(w, http.StatusNotFound, "SSO isn't configured on this server.")
+		return
+	}
+
+	query := r.URL.Query()
+
+	login := getPendingSsoLogin(w, r, query.Get("state"))
+	if login == nil {
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		logger.Infof("Identity provider returned error: %s %s", providerErr, query.Get("error_description"))
+		failSsoLogin(w, r, login, http.StatusUnauthorized, fmt.Sprintf("Sign in failed: %s %s", providerErr, query.Get("error_description")))
+		return
+	}
+
+	writeSsoConfirmPage(w, query.Get("state"), query.Get("code"))
+}
+
+// ConfirmSsoHandler finishes a login once the user has entered the code the CLI showed them. A wrong code fails the login, so the code can't be guessed.
+func ConfirmSsoHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ConfirmSsoHandler")
+
+	if !sso.Enabled() {
+		writeSsoPage(w, http.StatusNotFound, "SSO isn't configured on this server.")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		writeSsoPage(w, http.StatusBadRequest, "Invalid sign in form. Please run the sign in command again.")
+		return
+	}
+
+	login := getPendingSsoLogin(w, r, r.PostForm.Get("state"))
+	if login == nil {
+		return
+	}
+
+	if !db.SsoUserCodeMatches(login, r.PostForm.Get("user_code")) {
+		logger.Info("Sso user code doesn't match")
+		failSsoLogin(w, r, login, http.StatusForbidden, "Sign in failed: that code doesn't match the one in your terminal. Please run the sign in command again.")
+		return
+	}
+
+	identity, err := sso.Exchange(r.Context(), r.PostForm.Get("code"), login.Nonce, login.CodeVerifier)
+
+	if err != nil {
+		logger.Errorf("Error exchanging sso code: %v", err)
+		failSsoLogin(w, r, login, http.StatusUnauthorized, "Sign in failed: couldn't verify your identity with the provider.")
+		return
+	}
+
+	if !identity.EmailVerified {
+		logger.Infof("Sso email not verified: %s", identity.Email)
+		failSsoLogin(w, r, login, http.StatusForbidden, fmt.Sprintf("Sign in failed: %s isn't verified with your identity provider.", identity.Email))
+		return
+	}
+
+	user, err := provisionSsoUser(r.Context(), login, identity)
+
+	if err != nil {
+		logger.Errorf("Error provisioning sso user: %v", err)
+		failSsoLogin(w, r, login, http.StatusInternalServerError, "Error signing in. Please try again.")
+		return
+	}
+
+	logger.Infof("Sso login completed for user %s", user.Id)
+
+	writeSsoPage(w, http.StatusOK, fmt.Sprintf("Signed in as %s. You can close this window and go back to the terminal.", user.Email))
+}
+
+// getPendingSsoLogin looks up the login for state, writing an error page if it's expired or already finished
+func getPendingSsoLogin(w http.ResponseWriter, r *http.Request, state string) *db.SsoLogin {
+	logger := logging.Ctx(r.Context())
+
+	login, err := db.GetPendingSsoLoginByState(state)
+
+	if err != nil {
+		logger.Errorf("Error getting sso login: %v", err)
+		writeSsoPage(w, http.StatusInternalServerError, "Error getting sign in. Please try again.")
+		return nil
+	}
+
+	if login == nil {
+		logger.Info("Sso login not found or expired")
+		writeSsoPage(w, http.StatusBadRequest, "This sign in link has expired or was already used. Please run the sign in command again.")
+		return nil
+	}
+
+	logging.Add(r.Context(), "sso_login_id", login.Id)
+
+	return login
+}
+
+// failSsoLogin completes the login with an error for the CLI to show, and shows it in the browser too
+func failSsoLogin(w http.ResponseWriter, r *http.Request, login *db.SsoLogin, status int, msg string) {
+	err := db.CompleteSsoLogin(login.Id, nil, &msg, nil)
+	if err != nil {
+		logging.Ctx(r.Context()).Errorf("Error completing sso login: %v", err)
+	}
+	writeSsoPage(w, status, msg)
+}
+
+// provisionSsoUser creates the user if they're new, adds them to the org for their email domain with a role from their groups, and completes the login--all in one transaction
+func provisionSsoUser(ctx context.Context, login *db.SsoLogin, identity *sso.Identity) (*db.User, error) {
+	logger := logging.Ctx(ctx)
+
+	emailSplit := strings.Split(identity.Email, "@")
+	if len(emailSplit) != 2 {
+		return nil, fmt.Errorf("invalid email: %s", identity.Email)
+	}
+	domain := emailSplit[1]
+
+	user, err := db.GetUserByEmail(identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %v", err)
+	}
+
+	org, err := db.GetOrgForDomain(domain)
+	if err != nil {
+		return nil, fmt.Errorf("error getting org for domain: %v", err)
+	}
+
+	var orgRoleId string
+	var fromGroups bool
+	if org != nil && org.AutoAddDomainUsers {
+		var roleName string
+		roleName, fromGroups = sso.RoleForGroups(identity.Groups)
+
+		if roleName != "" {
+			orgRoleId, err = db.GetOrgRoleIdByName(org.Id, roleName)
+			if err != nil {
+				return nil, fmt.Errorf("error getting org role: %v", err)
+			}
+			if orgRoleId == "" {
+				// a misconfigured mapping shouldn't lock users out--they just aren't added to the org
+				logger.Warnf("OIDC role %q doesn't exist in org %s", roleName, org.Id)
+			}
+		}
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if user == nil {
+		name := identity.Name
+		if name == "" {
+			name = emailSplit[0]
+		}
+
+		user = &db.User{
+			Name:   name,
+			Email:  identity.Email,
+			Domain: domain,
+		}
+		err = db.CreateUser(user, tx)
+		if err != nil {
+			return nil, fmt.Errorf("error creating user: %v", err)
+		}
+
+		logger.Infof("Created sso user %s", user.Id)
+	} else if user.IsTrial {
+		return nil, fmt.Errorf("trial user can't sign in with sso")
+	}
+
+	if orgRoleId != "" {
+		// roles follow group changes, except for the org's owner, who can't be demoted by the identity provider
+		updateRole := fromGroups && org.OwnerId != user.Id
+
+		err = db.AddOrgDomainUser(org.Id, user.Id, orgRoleId, updateRole, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.CompleteSsoLogin(login.Id, &user.Id, nil, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return user, nil
+}
+
+// PollSsoHandler lets the CLI that started a login pick up its session once the user has signed in with the identity provider. Pending logins return 202.
+func PollSsoHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var req shared.PollSsoRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error unmarshalling request: %v", err)
+		http.Error(w, "Error unmarshalling request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	login, err := db.ConsumeSsoLogin(req.LoginId, req.PollSecret)
+
+	if err != nil {
+		logger.Errorf("Error getting sso login: %v", err)
+		http.Error(w, "Error getting sso login: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if login == nil {
+		http.Error(w, "Sign in expired--please try again", http.StatusNotFound)
+		return
+	}
+
+	if login.CompletedAt == nil {
+		w.WriteHeader(http.StatusAccepted)
+		bytes, _ := json.Marshal(shared.PollSsoResponse{Pending: true})
+		w.Write(bytes)
+		return
+	}
+
+	if login.Error != nil {
+		http.Error(w, *login.Error, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := db.GetUser(*login.UserId)
+
+	if err != nil {
+		logger.Errorf("Error getting user: %v", err)
+		http.Error(w, "Error getting user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		logger.Errorf("Error starting transaction: %v", err)
+		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	token, _, err := db.CreateAuthToken(user.Id, false, authTokenClient(r), tx)
+
+	if err != nil {
+		logger.Errorf("Error creating auth token: %v", err)
+		http.Error(w, "Error creating auth token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Errorf("Error committing transaction: %v", err)
+		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	orgs, err := db.GetAccessibleOrgsForUser(user)
+
+	if err != nil {
+		logger.Errorf("Error getting orgs for user: %v", err)
+		http.Error(w, "Error getting orgs for user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var apiOrgs []*shared.Org
+	for _, org := range orgs {
+		apiOrgs = append(apiOrgs, org.ToApi())
+	}
+
+	bytes, err := json.Marshal(shared.PollSsoResponse{
+		Session: &shared.SessionResponse{
+			UserId:   user.Id,
+			Token:    token,
+			Email:    user.Email,
+			UserName: user.Name,
+			Orgs:     apiOrgs,
+		},
+	})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully signed in with sso: %s", user.Id)
+
+	w.Write(bytes)
+}
+
+func writeSsoPage(w http.ResponseWriter, status int, msg string) {
+	writeSsoHtml(w, status, fmt.Sprintf("<p>%s</p>", html.EscapeString(msg)))
+}
+
+// writeSsoConfirmPage asks for the code shown in the CLI, carrying the provider's state and code along to ConfirmSsoHandler
+func writeSsoConfirmPage(w http.ResponseWriter, state, code string) {
+	writeSsoHtml(w, http.StatusOK, fmt.Sprintf(
+		"<p>Enter the code shown in your terminal to finish signing in. If you didn't just run the sign in command, close this window.</p>"+
+			"<form method=\"post\" action=\"\"><input type=\"hidden\" name=\"state\" value=\"%s\"><input type=\"hidden\" name=\"code\" value=\"%s\">"+
+			"<input name=\"user_code\" placeholder=\"XXXX-XXXX\" autocomplete=\"off\" autofocus required> <button type=\"submit\">Sign in</button></form>",
+		html.EscapeString(state), html.EscapeString(code),
+	))
+}
+
+func writeSsoHtml(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the confirm page mustn't be framed, or another site could trick the user into submitting it
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<!DOCTYPE html><html><head><title>Plandex</title></head><body style=\"font-family: sans-serif; margin: 4em auto; max-width: 40em\"><h2>Plandex</h2>%s</body></html>", body)
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"plandex-server/db"
+	"plandex-server/logging"
 	modelPlan "plandex-server/model/plan"
 	"plandex-server/types"
 	"time"
@@ -13,69 +13,91 @@ import (
 	"github.com/plandex/plandex/shared"
 )
 
-func startResponseStream(w http.ResponseWriter, auth *types.ServerAuth, planId, branch string, isConnect bool) {
-	log.Println("Response stream manager: starting plan stream")
+func startResponseStream(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth, planId, branch string, isConnect bool) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Response stream manager: starting plan stream")
 
 	active := modelPlan.GetActivePlan(planId, branch)
 
 	if active == nil {
-		log.Printf("Response stream manager: active plan not found for plan ID %s on branch %s\n", planId, branch)
+		logger.Warnf("Response stream manager: active plan not found for plan ID %s on branch %s", planId, branch)
 		http.Error(w, "Active plan not found", http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Transfer-Encoding", "chunked")
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	stream := newPlanStreamWriter(w, r, active.ModelStreamId)
+	stopStream := stream.start()
+	defer stopStream()
 
 	// send initial message to client
 	msg := shared.StreamMessage{
-		Type: shared.StreamMessageStart,
+		Type:          shared.StreamMessageStart,
+		ModelStreamId: active.ModelStreamId,
 	}
 
 	bytes, err := json.Marshal(msg)
 
 	if err != nil {
-		log.Printf("Response stream manager: error marshalling message: %v\n", err)
+		logger.Errorf("Response stream manager: error marshalling message: %v", err)
 		return
 	}
 
-	log.Println("Response stream manager: sending initial message")
-	err = sendStreamMessage(w, string(bytes))
+	logger.Info("Response stream manager: sending initial message")
+	err = stream.send(string(bytes))
 	if err != nil {
-		log.Println("Response stream manager: error sending initial message:", err)
+		logger.Errorf("Response stream manager: error sending initial message: %v", err)
 		return
 	}
 
+	var subscriptionId string
+	var ch chan string
+	resumed := false
+
 	if isConnect {
-		time.Sleep(100 * time.Millisecond)
-		err = initConnectActive(auth, planId, branch, w)
+		lastSeq, ok := getResumeSeq(r, active.ModelStreamId)
+		if ok {
+			subscriptionId, ch, resumed = modelPlan.ResumePlan(planId, branch, lastSeq)
+			logger.Infof("Response stream manager: resuming after message %d: %v", lastSeq, resumed)
+		}
+	}
 
-		if err != nil {
-			log.Println("Response stream manager: error initializing connection to active plan:", err)
-			return
+	if !resumed {
+		// a fresh connection, or the client missed more than is buffered, so catch it up from the plan's state instead
+		if isConnect {
+			time.Sleep(100 * time.Millisecond)
+			err = initConnectActive(auth, planId, branch, stream)
+
+			if err != nil {
+				logger.Errorf("Response stream manager: error initializing connection to active plan: %v", err)
+				return
+			}
 		}
+
+		subscriptionId, ch = modelPlan.SubscribePlan(planId, branch)
 	}
 
-	subscriptionId, ch := modelPlan.SubscribePlan(planId, branch)
 	defer func() {
-		log.Println("Response stream manager: client stream closed")
+		logger.Info("Response stream manager: client stream closed")
 		modelPlan.UnsubscribePlan(planId, branch, subscriptionId)
 	}()
 
-	if isConnect {
-		time.Sleep(50 * time.Millisecond)
-	} else {
-		time.Sleep(100 * time.Millisecond)
+	if !resumed {
+		if isConnect {
+			time.Sleep(50 * time.Millisecond)
+		} else {
+			time.Sleep(100 * time.Millisecond)
+		}
 	}
 
 	for {
 		select {
 		case <-active.Ctx.Done():
-			log.Println("Response stream manager: context done")
+			logger.Info("Response stream manager: context done")
 			return
 		case msg := <-ch:
 			// log.Println("Response stream manager: sending message:", msg)
-			err = sendStreamMessage(w, msg)
+			err = stream.send(msg)
 			if err != nil {
 				return
 			}
@@ -84,120 +106,44 @@ func startResponseStream(w http.ResponseWriter, auth *types.ServerAuth, planId,
 
 }
 
-func sendStreamMessage(w http.ResponseWriter, msg string) error {
-	bytes := []byte(msg + shared.STREAM_MESSAGE_SEPARATOR)
+func initConnectActive(auth *types.ServerAuth, planId, branch string, stream *planStreamWriter) error {
+	log.Println("Response stream manager: initializing connection to active plan")
 
-	// log.Printf("Response stream manager: writing message to client: %s\n", msg)
+	msgs, err := modelPlan.GetConnectActiveMessages(planId, branch)
 
-	_, err := w.Write(bytes)
 	if err != nil {
-		log.Printf("Response stream manager: error writing to client: %v\n", err)
 		return err
-	} else if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
 	}
-	return nil
-}
 
-func initConnectActive(auth *types.ServerAuth, planId, branch string, w http.ResponseWriter) error {
-	log.Println("Response stream manager: initializing connection to active plan")
-
-	active := modelPlan.GetActivePlan(planId, branch)
-
-	if active == nil {
-		return fmt.Errorf("active plan not found for plan ID %s on branch %s", planId, branch)
-	}
+	for _, msg := range msgs {
+		bytes, err := json.Marshal(msg)
 
-	msg := shared.StreamMessage{
-		Type: shared.StreamMessageConnectActive,
-	}
-
-	if active.Prompt != "" && !active.BuildOnly {
-		msg.InitPrompt = active.Prompt
-	}
-
-	if active.BuildOnly {
-		msg.InitBuildOnly = true
-	}
-
-	if len(active.StoredReplyIds) > 0 {
-		convo, err := db.GetPlanConvo(auth.OrgId, active.Id)
 		if err != nil {
-			return fmt.Errorf("error getting plan convo: %v", err)
+			return fmt.Errorf("error marshalling message: %v", err)
 		}
 
-		convoMsgById := map[string]*db.ConvoMessage{}
-		for _, convoMsg := range convo {
-			convoMsgById[convoMsg.Id] = convoMsg
-		}
+		log.Printf("Response stream manager: sending %s message\n", msg.Type)
+		err = stream.send(string(bytes))
 
-		for _, replyId := range active.StoredReplyIds {
-			if convoMsg, ok := convoMsgById[replyId]; ok {
-				msg.InitReplies = append(msg.InitReplies, convoMsg.Message)
-			}
+		if err != nil {
+			return fmt.Errorf("error sending message: %v", err)
 		}
 	}
 
-	if active.CurrentReplyContent != "" {
-		msg.InitReplies = append(msg.InitReplies, active.CurrentReplyContent)
-	}
-
-	if active.MissingFilePath != "" {
-		msg.MissingFilePath = active.MissingFilePath
-	}
-
-	bytes, err := json.Marshal(msg)
+	return nil
+}
 
-	if err != nil {
-		return fmt.Errorf("error marshalling message: %v", err)
+// getResumeSeq returns the seq of the last message a reconnecting client received, if its Last-Event-ID is from the plan's current stream
+func getResumeSeq(r *http.Request, modelStreamId string) (int64, bool) {
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		return 0, false
 	}
 
-	log.Println("Response stream manager: sending connect message")
-	err = sendStreamMessage(w, string(bytes))
-
-	if err != nil {
-		return fmt.Errorf("error sending connect message: %v", err)
+	streamId, seq, ok := shared.ParseStreamEventId(lastEventId)
+	if !ok || streamId != modelStreamId {
+		return 0, false
 	}
 
-	buildQueuesByPath := modelPlan.GetActivePlan(planId, branch).BuildQueuesByPath
-
-	// if we're connecting to an active stream and there are active builds, send initial build info
-	if len(buildQueuesByPath) > 0 {
-
-		for path, queue := range buildQueuesByPath {
-			buildInfo := shared.BuildInfo{Path: path}
-
-			for _, build := range queue {
-				if build.BuildFinished() {
-					buildInfo.NumTokens = 0
-					buildInfo.Finished = true
-				} else {
-					tokens := build.WithLineNumsBufferTokens
-
-					buildInfo.Finished = false
-					buildInfo.NumTokens += tokens
-				}
-			}
-
-			msg := shared.StreamMessage{
-				Type:      shared.StreamMessageBuildInfo,
-				BuildInfo: &buildInfo,
-			}
-			bytes, err := json.Marshal(msg)
-
-			if err != nil {
-				return fmt.Errorf("error marshalling message: %v", err)
-			}
-
-			err = sendStreamMessage(w, string(bytes))
-
-			if err != nil {
-				return fmt.Errorf("error sending message: %v", err)
-			}
-
-		}
-
-	}
-
-	return nil
+	return seq, true
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// SSE comments sent while a plan is quiet (waiting on the model or a missing file) so proxies don't close the connection as idle
+const sseHeartbeatInterval = 15 * time.Second
+
+// planStreamWriter writes plan stream messages in the protocol the client asked for. Clients that send 'Accept: text/event-stream' get server-sent events, with one event per message named for its type and ids that SSE clients send back as Last-Event-ID when they reconnect. Everything else gets the original protocol: JSON messages separated by STREAM_MESSAGE_SEPARATOR, which older CLIs expect.
+type planStreamWriter struct {
+	w             http.ResponseWriter
+	sse           bool
+	modelStreamId string
+	mu            sync.Mutex
+	closed        bool
+}
+
+func newPlanStreamWriter(w http.ResponseWriter, r *http.Request, modelStreamId string) *planStreamWriter {
+	return &planStreamWriter{
+		w:             w,
+		sse:           strings.Contains(r.Header.Get("Accept"), shared.EVENT_STREAM_CONTENT_TYPE),
+		modelStreamId: modelStreamId,
+	}
+}
+
+// start writes the response headers and, for SSE, starts heartbeats. Call the returned function when the stream ends.
+func (s *planStreamWriter) start() (stop func()) {
+	if !s.sse {
+		s.w.Header().Set("Transfer-Encoding", "chunked")
+		s.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		return func() {}
+	}
+
+	s.w.Header().Set("Content-Type", shared.EVENT_STREAM_CONTENT_TYPE)
+	s.w.Header().Set("Cache-Control", "no-cache")
+	// stop nginx and similar proxies from buffering events
+	s.w.Header().Set("X-Accel-Buffering", "no")
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(sseHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if s.write(": heartbeat\n\n") != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// the response can't be written once the handler returns, so make sure a heartbeat isn't mid-write
+			s.mu.Lock()
+			s.closed = true
+			s.mu.Unlock()
+			close(done)
+		})
+	}
+}
+
+// send writes a JSON-encoded StreamMessage
+func (s *planStreamWriter) send(msg string) error {
+	if !s.sse {
+		return s.write(msg + shared.STREAM_MESSAGE_SEPARATOR)
+	}
+
+	var streamMsg shared.StreamMessage
+	err := json.Unmarshal([]byte(msg), &streamMsg)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling stream message: %v", err)
+	}
+
+	var events strings.Builder
+	err = s.writeSSEEvents(&events, streamMsg)
+	if err != nil {
+		return err
+	}
+
+	return s.write(events.String())
+}
+
+// writeSSEEvents writes a message as an event. Multi messages only exist to batch writes, so they're unpacked into an event per message, with the multi's seq going to the last one.
+func (s *planStreamWriter) writeSSEEvents(b *strings.Builder, msg shared.StreamMessage) error {
+	if msg.Type == shared.StreamMessageMulti {
+		for i, m := range msg.StreamMessages {
+			if i == len(msg.StreamMessages)-1 {
+				m.Seq = msg.Seq
+			}
+			err := s.writeSSEEvents(b, m)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// encoded JSON never contains a raw newline, so it always fits on a single data line
+	bytes, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error marshalling stream message: %v", err)
+	}
+
+	if msg.Seq > 0 {
+		fmt.Fprintf(b, "id: %s\n", shared.FormatStreamEventId(s.modelStreamId, msg.Seq))
+	}
+	fmt.Fprintf(b, "event: %s\ndata: %s\n\n", msg.Type, bytes)
+
+	return nil
+}
+
+func (s *planStreamWriter) write(data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("stream closed")
+	}
+
+	_, err := s.w.Write([]byte(data))
+	if err != nil {
+		log.Printf("Response stream manager: error writing to client: %v\n", err)
+		return err
+	} else if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
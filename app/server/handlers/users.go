@@ -2,9 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"io"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/types"
 	"strings"
 
@@ -13,7 +14,9 @@ import (
 )
 
 func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for ListUsersHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for ListUsersHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -30,7 +33,7 @@ func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
 
 	users, err := db.ListUsers(auth.OrgId)
 	if err != nil {
-		log.Println("Error listing users: ", err)
+		logger.Errorf("Error listing users: %v", err)
 		http.Error(w, "Error listing users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -42,7 +45,7 @@ func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
 
 	orgUsers, err := db.ListOrgUsers(auth.OrgId)
 	if err != nil {
-		log.Println("Error listing org users: ", err)
+		logger.Errorf("Error listing org users: %v", err)
 		http.Error(w, "Error listing org users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -60,18 +63,20 @@ func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
 	bytes, err := json.Marshal(resp)
 
 	if err != nil {
-		log.Println("Error marshalling users: ", err)
+		logger.Errorf("Error marshalling users: %v", err)
 		http.Error(w, "Error marshalling users: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed request for ListUsersHandler")
+	logger.Info("Successfully processed request for ListUsersHandler")
 
 	w.Write(bytes)
 }
 
 func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received a request for DeleteOrgUserHandler")
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for DeleteOrgUserHandler")
 	auth := authenticate(w, r, true)
 	if auth == nil {
 		return
@@ -89,12 +94,12 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userId := vars["userId"]
 
-	log.Println("userId: ", userId)
+	logger.Infof("userId: %v", userId)
 
 	orgUser, err := db.GetOrgUser(userId, auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error getting org user: %v\n", err)
+		logger.Errorf("Error getting org user: %v", err)
 		http.Error(w, "Error getting org user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -103,7 +108,7 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	removePermission := types.Permission(strings.Join([]string{string(types.PermissionRemoveUser), orgUser.OrgRoleId}, "|"))
 
 	if !auth.HasPermission(removePermission) {
-		log.Printf("User does not have permission to remove user with role: %v\n", orgUser.OrgRoleId)
+		logger.Infof("User does not have permission to remove user with role: %v", orgUser.OrgRoleId)
 		http.Error(w, "User does not have permission to remove user with role: "+orgUser.OrgRoleId, http.StatusForbidden)
 		return
 	}
@@ -112,13 +117,13 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	isMember, err := db.ValidateOrgMembership(userId, auth.OrgId)
 
 	if err != nil {
-		log.Printf("Error validating org membership: %v\n", err)
+		logger.Errorf("Error validating org membership: %v", err)
 		http.Error(w, "Error validating org membership: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if !isMember {
-		log.Printf("User %s is not a member of org %s\n", userId, auth.OrgId)
+		logger.Infof("User %s is not a member of org %s", userId, auth.OrgId)
 		http.Error(w, "User "+userId+" is not a member of org "+auth.OrgId, http.StatusForbidden)
 		return
 	}
@@ -126,7 +131,7 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	// verify user isn't the only org owner
 	ownerRoleId, err := db.GetOrgOwnerRoleId()
 	if err != nil {
-		log.Printf("Error getting org owner role id: %v\n", err)
+		logger.Errorf("Error getting org owner role id: %v", err)
 		http.Error(w, "Error getting org owner role id: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -135,13 +140,13 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 		numOwners, err := db.NumUsersWithRole(auth.OrgId, ownerRoleId)
 
 		if err != nil {
-			log.Printf("Error getting number of org owners: %v\n", err)
+			logger.Errorf("Error getting number of org owners: %v", err)
 			http.Error(w, "Error getting number of org owners: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		if numOwners == 1 {
-			log.Println("Cannot delete the only org owner")
+			logger.Info("Cannot delete the only org owner")
 			http.Error(w, "Cannot delete the only org owner", http.StatusForbidden)
 			return
 		}
@@ -150,7 +155,7 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	// start a transaction
 	tx, err := db.Conn.Beginx()
 	if err != nil {
-		log.Printf("Error starting transaction: %v\n", err)
+		logger.Errorf("Error starting transaction: %v", err)
 		http.Error(w, "Error starting transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -159,9 +164,9 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				log.Printf("transaction rollback error: %v\n", rbErr)
+				logger.Errorf("transaction rollback error: %v", rbErr)
 			} else {
-				log.Println("transaction rolled back")
+				logger.Info("transaction rolled back")
 			}
 		}
 	}()
@@ -169,15 +174,23 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	err = db.DeleteOrgUser(auth.OrgId, userId, tx)
 
 	if err != nil {
-		log.Println("Error deleting org user: ", err)
+		logger.Errorf("Error deleting org user: %v", err)
 		http.Error(w, "Error deleting org user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	err = db.CreateAuditEvent(auditEvent(r, auth, shared.AuditActionUserRemove, userId, map[string]string{"orgRoleId": orgUser.OrgRoleId}), tx)
+
+	if err != nil {
+		logger.Errorf("Error recording audit event: %v", err)
+		http.Error(w, "Error recording audit event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	invite, err := db.GetActiveInviteByEmail(auth.OrgId, auth.User.Email)
 
 	if err != nil {
-		log.Println("Error getting invite for org user: ", err)
+		logger.Errorf("Error getting invite for org user: %v", err)
 		http.Error(w, "Error getting invite for org user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -186,7 +199,7 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 		err = db.DeleteInvite(invite.Id, tx)
 
 		if err != nil {
-			log.Println("Error deleting invite: ", err)
+			logger.Errorf("Error deleting invite: %v", err)
 			http.Error(w, "Error deleting invite: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -195,10 +208,109 @@ func DeleteOrgUserHandler(w http.ResponseWriter, r *http.Request) {
 	err = tx.Commit()
 
 	if err != nil {
-		log.Println("Error committing transaction: ", err)
+		logger.Errorf("Error committing transaction: %v", err)
 		http.Error(w, "Error committing transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully processed request for DeleteOrgUserHandler")
+	logger.Info("Successfully processed request for DeleteOrgUserHandler")
+}
+
+func SetOrgUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received a request for SetOrgUserRoleHandler")
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't set user roles",
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	userId := vars["userId"]
+
+	logger.Infof("userId: %v", userId)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.SetOrgUserRoleRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orgUser, err := db.GetOrgUser(userId, auth.OrgId)
+
+	if err != nil {
+		logger.Errorf("Error getting org user: %v", err)
+		http.Error(w, "Error getting org user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if orgUser == nil {
+		logger.Infof("User %s is not a member of org %s", userId, auth.OrgId)
+		http.Error(w, "User "+userId+" is not a member of org "+auth.OrgId, http.StatusNotFound)
+		return
+	}
+
+	role, err := db.GetOrgRole(auth.OrgId, req.OrgRoleId)
+
+	if err != nil {
+		logger.Errorf("Error getting org role: %v", err)
+		http.Error(w, "Error getting org role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if role == nil {
+		http.Error(w, "Org role not found", http.StatusNotFound)
+		return
+	}
+
+	// ensure current user can change the target user's role, and give them the new one
+	if !auth.HasPermission(types.PermissionSetUserRole.ForResource(orgUser.OrgRoleId)) || !auth.HasPermission(types.PermissionSetUserRole.ForResource(role.Id)) {
+		logger.Infof("User does not have permission to change role %s to %s", orgUser.OrgRoleId, role.Id)
+		http.Error(w, "User does not have permission to change this user's role to "+role.Label, http.StatusForbidden)
+		return
+	}
+
+	org, err := db.GetOrg(auth.OrgId)
+
+	if err != nil {
+		logger.Errorf("Error getting org: %v", err)
+		http.Error(w, "Error getting org: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if org.OwnerId == userId && role.Name != "owner" {
+		http.Error(w, "The org's creator must keep the owner role", http.StatusForbidden)
+		return
+	}
+
+	err = db.SetOrgUserRole(auth.OrgId, userId, role.Id)
+
+	if err != nil {
+		logger.Errorf("Error setting org user role: %v", err)
+		http.Error(w, "Error setting org user role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionUserSetRole, userId, map[string]string{"fromOrgRoleId": orgUser.OrgRoleId, "orgRoleId": role.Id, "orgRole": role.Name})
+
+	logger.Infof("Successfully set user %s role to %s", userId, role.Id)
 }
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/types"
+	"plandex-server/webhooks"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/plandex/plandex/shared"
+)
+
+const maxListWebhookDeliveries = 50
+
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListWebhooksHandler")
+
+	auth := authorizeManageWebhooks(w, r)
+	if auth == nil {
+		return
+	}
+
+	res, err := db.ListWebhooks(auth.OrgId)
+
+	if err != nil {
+		logger.Errorf("Error listing webhooks: %v", err)
+		http.Error(w, "Error listing webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiWebhooks := []*shared.Webhook{}
+	for _, webhook := range res {
+		apiWebhooks = append(apiWebhooks, webhook.ToApi())
+	}
+
+	bytes, err := json.Marshal(apiWebhooks)
+
+	if err != nil {
+		logger.Errorf("Error marshalling webhooks: %v", err)
+		http.Error(w, "Error marshalling webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Info("Successfully listed webhooks")
+}
+
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for CreateWebhookHandler")
+
+	auth := authorizeManageWebhooks(w, r)
+	if auth == nil {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Errorf("Error reading request body: %v", err)
+		http.Error(w, "Error reading request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var req shared.CreateWebhookRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		logger.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Url = strings.TrimSpace(req.Url)
+
+	err = shared.ValidateWebhookRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = webhooks.CheckUrl(req.Url)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var events db.StringList
+	for _, event := range req.Events {
+		events = append(events, string(event))
+	}
+
+	webhook := &db.Webhook{
+		OrgId:     auth.OrgId,
+		CreatorId: auth.User.Id,
+		Url:       req.Url,
+		Events:    events,
+	}
+
+	err = db.CreateWebhook(webhook)
+
+	if err != nil {
+		logger.Errorf("Error creating webhook: %v", err)
+		http.Error(w, "Error creating webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionWebhookCreate, webhook.Id, map[string]string{"url": webhook.Url, "events": strings.Join(webhook.Events, ",")})
+
+	bytes, err := json.Marshal(shared.CreateWebhookResponse{
+		Webhook: webhook.ToApi(),
+		Secret:  webhook.Secret,
+	})
+
+	if err != nil {
+		logger.Errorf("Error marshalling response: %v", err)
+		http.Error(w, "Error marshalling response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Successfully created webhook %s", webhook.Id)
+
+	w.Write(bytes)
+}
+
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for DeleteWebhookHandler")
+
+	auth := authorizeManageWebhooks(w, r)
+	if auth == nil {
+		return
+	}
+
+	webhook := authorizeWebhook(w, r, auth)
+	if webhook == nil {
+		return
+	}
+
+	err := db.DeleteWebhook(auth.OrgId, webhook.Id)
+
+	if err != nil {
+		logger.Errorf("Error deleting webhook: %v", err)
+		http.Error(w, "Error deleting webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	recordAuditEvent(r, auth, shared.AuditActionWebhookDelete, webhook.Id, map[string]string{"url": webhook.Url})
+
+	logger.Infof("Successfully deleted webhook %s", webhook.Id)
+}
+
+func ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for ListWebhookDeliveriesHandler")
+
+	auth := authorizeManageWebhooks(w, r)
+	if auth == nil {
+		return
+	}
+
+	webhook := authorizeWebhook(w, r, auth)
+	if webhook == nil {
+		return
+	}
+
+	deliveries, err := db.ListWebhookDeliveries(webhook.Id, maxListWebhookDeliveries)
+
+	if err != nil {
+		logger.Errorf("Error listing webhook deliveries: %v", err)
+		http.Error(w, "Error listing webhook deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	apiDeliveries := []*shared.WebhookDelivery{}
+	for _, delivery := range deliveries {
+		apiDeliveries = append(apiDeliveries, delivery.ToApi())
+	}
+
+	bytes, err := json.Marshal(apiDeliveries)
+
+	if err != nil {
+		logger.Errorf("Error marshalling webhook deliveries: %v", err)
+		http.Error(w, "Error marshalling webhook deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(bytes)
+
+	logger.Infof("Successfully listed deliveries for webhook %s", webhook.Id)
+}
+
+// TestWebhookHandler sends a webhook.test event to the webhook right away and responds with how the delivery went
+func TestWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	logger := logging.Ctx(r.Context())
+
+	logger.Info("Received request for TestWebhookHandler")
+
+	auth := authorizeManageWebhooks(w, r)
+	if auth == nil {
+		return
+	}
+
+	webhook := authorizeWebhook(w, r, auth)
+	if webhook == nil {
+		return
+	}
+
+	delivery, err := db.CreateTestWebhookDelivery(webhook)
+
+	if err != nil {
+		logger.Errorf("Error creating test webhook delivery: %v", err)
+		http.Error(w, "Error creating test webhook delivery: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, err = webhooks.Deliver(delivery)
+
+	if err != nil {
+		logger.Errorf("Error delivering test webhook: %v", err)
+		http.Error(w, "Error delivering test webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(delivery.ToApi())
+
+	if err != nil {
+		logger.Errorf("Error marshalling webhook delivery: %v", err)
+		http.Error(w, "Error marshalling webhook delivery: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Infof("Sent test delivery %s to webhook %s with status %s", delivery.Id, webhook.Id, delivery.Status)
+
+	w.Write(bytes)
+}
+
+func authorizeManageWebhooks(w http.ResponseWriter, r *http.Request) *types.ServerAuth {
+	auth := authenticate(w, r, true)
+	if auth == nil {
+		return nil
+	}
+
+	if auth.User.IsTrial {
+		writeApiError(w, shared.ApiError{
+			Type:   shared.ApiErrorTypeTrialActionNotAllowed,
+			Status: http.StatusForbidden,
+			Msg:    "Anonymous trial user can't manage webhooks",
+		})
+		return nil
+	}
+
+	if !auth.HasPermission(types.PermissionManageWebhooks) {
+		logging.Ctx(r.Context()).Info("User cannot manage webhooks")
+		http.Error(w, "User cannot manage webhooks", http.StatusForbidden)
+		return nil
+	}
+
+	return auth
+}
+
+func authorizeWebhook(w http.ResponseWriter, r *http.Request, auth *types.ServerAuth) *db.Webhook {
+	logger := logging.Ctx(r.Context())
+
+	webhookId := mux.Vars(r)["webhookId"]
+
+	webhook, err := db.GetWebhook(auth.OrgId, webhookId)
+
+	if err != nil {
+		logger.Errorf("Error getting webhook: %v", err)
+		http.Error(w, "Error getting webhook: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	if webhook == nil {
+		http.Error(w, "Webhook not found: "+webhookId, http.StatusNotFound)
+		return nil
+	}
+
+	return webhook
+}
@@ -0,0 +1,202 @@
+package local
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"plandex-server/db"
+	"plandex-server/model/plan"
+	"plandex-server/routes"
+	"plandex-server/webhooks"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// Local mode runs the API inside the CLI process: an embedded SQLite store and plan repos under a single directory, the regular routes served on a loopback port, and a single account that needs no email verification.
+
+const (
+	localEmail    = "local@localhost"
+	localDomain   = "localhost"
+	localUserName = "Local User"
+	localOrgName  = "Local"
+)
+
+type Server struct {
+	Host    string
+	Session *shared.SessionResponse
+}
+
+func Start(dir string) (*Server, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("error creating local dir: %v", err)
+	}
+
+	db.BaseDir = filepath.Join(dir, "server")
+
+	err = db.ConnectUrl("sqlite://" + filepath.ToSlash(filepath.Join(dir, "plandex.db")))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to local database: %v", err)
+	}
+
+	err = db.MigrationsUp()
+	if err != nil {
+		return nil, fmt.Errorf("error running migrations: %v", err)
+	}
+
+	session, err := loadSession(filepath.Join(dir, "token"))
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error listening on loopback: %v", err)
+	}
+
+	go func() {
+		err := http.Serve(listener, routes.NewRouter())
+		if err != nil {
+			log.Printf("Local server stopped: %v\n", err)
+		}
+	}()
+
+	log.Println("Started local server on " + listener.Addr().String())
+
+	// receivers on the user's own machine are the usual case here
+	webhooks.AllowPrivateUrls = true
+	webhooks.Start()
+
+	return &Server{
+		Host:    "http://" + listener.Addr().String(),
+		Session: session,
+	}, nil
+}
+
+func NumActivePlans() int {
+	return plan.NumActivePlans()
+}
+
+// loadSession returns the local account, creating it on first run. The token is kept in tokenPath so that separate CLI invocations share it rather than minting a new one each time.
+func loadSession(tokenPath string) (*shared.SessionResponse, error) {
+	user, err := getOrCreateUser()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getOrCreateToken(tokenPath, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := db.GetAccessibleOrgsForUser(user)
+	if err != nil {
+		return nil, fmt.Errorf("error getting orgs: %v", err)
+	}
+
+	var apiOrgs []*shared.Org
+	for _, org := range orgs {
+		apiOrgs = append(apiOrgs, org.ToApi())
+	}
+
+	return &shared.SessionResponse{
+		UserId:   user.Id,
+		Token:    token,
+		Email:    user.Email,
+		UserName: user.Name,
+		Orgs:     apiOrgs,
+	}, nil
+}
+
+func getOrCreateUser() (*db.User, error) {
+	user, err := db.GetUserByEmail(localEmail)
+	if err != nil {
+		return nil, fmt.Errorf("error getting local user: %v", err)
+	}
+
+	if user != nil {
+		return user, nil
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Printf("transaction rollback error: %v\n", rbErr)
+			}
+		}
+	}()
+
+	user = &db.User{
+		Name:   localUserName,
+		Email:  localEmail,
+		Domain: localDomain,
+	}
+
+	err = db.CreateUser(user, tx)
+	if err != nil {
+		if db.IsNonUniqueErr(err) {
+			// another plandex process created it first
+			return db.GetUserByEmail(localEmail)
+		}
+		return nil, fmt.Errorf("error creating local user: %v", err)
+	}
+
+	_, err = db.CreateOrg(&shared.CreateOrgRequest{Name: localOrgName}, user.Id, nil, tx)
+	if err != nil {
+		return nil, fmt.Errorf("error creating local org: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	return user, nil
+}
+
+func getOrCreateToken(tokenPath, userId string) (string, error) {
+	bytes, err := os.ReadFile(tokenPath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("error reading local token: %v", err)
+	}
+
+	if err == nil {
+		token := strings.TrimSpace(string(bytes))
+		authToken, err := db.ValidateAuthToken(token)
+		if err == nil && authToken.UserId == userId {
+			return token, nil
+		}
+		// expired or from a reset database--mint a new one below
+	}
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	token, _, err := db.CreateAuthToken(userId, false, db.AuthTokenClient{}, tx)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	err = os.WriteFile(tokenPath, []byte(token), 0600)
+	if err != nil {
+		return "", fmt.Errorf("error writing local token: %v", err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Logger logs through the default slog logger with the fields carried on its context. The f variants take printf-style arguments, which is how most of the server's messages are written.
+type Logger struct {
+	ctx context.Context
+}
+
+func Ctx(ctx context.Context) *Logger {
+	return &Logger{ctx: ctx}
+}
+
+// With returns a logger whose lines also carry args
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{ctx: With(l.ctx, args...)}
+}
+
+func (l *Logger) Debug(msg string, args ...any) { l.log(slog.LevelDebug, msg, args) }
+func (l *Logger) Info(msg string, args ...any)  { l.log(slog.LevelInfo, msg, args) }
+func (l *Logger) Warn(msg string, args ...any)  { l.log(slog.LevelWarn, msg, args) }
+func (l *Logger) Error(msg string, args ...any) { l.log(slog.LevelError, msg, args) }
+
+func (l *Logger) Debugf(format string, args ...any) { l.logf(slog.LevelDebug, format, args) }
+func (l *Logger) Infof(format string, args ...any)  { l.logf(slog.LevelInfo, format, args) }
+func (l *Logger) Warnf(format string, args ...any)  { l.logf(slog.LevelWarn, format, args) }
+func (l *Logger) Errorf(format string, args ...any) { l.logf(slog.LevelError, format, args) }
+
+func (l *Logger) logf(level slog.Level, format string, args []any) {
+	if !slog.Default().Enabled(l.ctx, level) {
+		return
+	}
+	l.write(level, fmt.Sprintf(format, args...), nil)
+}
+
+func (l *Logger) log(level slog.Level, msg string, args []any) {
+	if !slog.Default().Enabled(l.ctx, level) {
+		return
+	}
+	l.write(level, msg, args)
+}
+
+// write builds the record itself rather than calling slog.Log so that the source is the caller of Infof etc. rather than this file
+func (l *Logger) write(level slog.Level, msg string, args []any) {
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:])
+
+	r := slog.NewRecord(time.Now(), level, strings.TrimSuffix(msg, "\n"), pcs[0])
+	r.Add(args...)
+
+	_ = slog.Default().Handler().Handle(l.ctx, r)
+}
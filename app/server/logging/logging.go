@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Structured logging with correlation fields carried on the context. A request's context is tagged with its request id, org, user, plan and branch as they become known, and an active plan's context with the plan's ids and model stream, so every line logged through Ctx can be filtered down to one request or one plan in a busy multi-tenant log. Lines logged with the standard log package still go through the same handler, just without the fields.
+
+type ctxKey struct{}
+
+type fields struct {
+	parent *fields
+
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+func (f *fields) add(attrs []slog.Attr) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attrs = append(f.attrs, attrs...)
+}
+
+func (f *fields) collect() []slog.Attr {
+	var res []slog.Attr
+	if f.parent != nil {
+		res = f.parent.collect()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append(res, f.attrs...)
+}
+
+func (f *fields) get(key string) string {
+	f.mu.Lock()
+	for _, attr := range f.attrs {
+		if attr.Key == key {
+			f.mu.Unlock()
+			return attr.Value.String()
+		}
+	}
+	f.mu.Unlock()
+
+	if f.parent != nil {
+		return f.parent.get(key)
+	}
+	return ""
+}
+
+// Init sets the default logger from LOG_LEVEL (debug, info, warn or error, default info) and LOG_FORMAT (text or json, default text)
+func Init() error {
+	level := slog.LevelInfo
+	if s := os.Getenv("LOG_LEVEL"); s != "" {
+		err := level.UnmarshalText([]byte(s))
+		if err != nil {
+			return fmt.Errorf("error parsing LOG_LEVEL: %v", err)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var inner slog.Handler
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "", "text":
+		inner = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		inner = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q, expected text or json", os.Getenv("LOG_FORMAT"))
+	}
+
+	slog.SetDefault(slog.New(&handler{inner}))
+
+	return nil
+}
+
+// With returns a copy of ctx whose log lines carry args (alternating keys and values, as with slog) in addition to the fields ctx already carries
+func With(ctx context.Context, args ...any) context.Context {
+	parent, _ := ctx.Value(ctxKey{}).(*fields)
+	return context.WithValue(ctx, ctxKey{}, &fields{parent: parent, attrs: argsToAttrs(args)})
+}
+
+// Add adds args to the fields ctx already carries, for values that are only known after the context has been handed out, like the user on an authenticated request or the model stream on an active plan. It does nothing if ctx wasn't created with With.
+func Add(ctx context.Context, args ...any) {
+	f, ok := ctx.Value(ctxKey{}).(*fields)
+	if !ok {
+		return
+	}
+	f.add(argsToAttrs(args))
+}
+
+// RequestId returns the id of the request ctx belongs to, or an empty string if it doesn't belong to one
+func RequestId(ctx context.Context) string {
+	f, ok := ctx.Value(ctxKey{}).(*fields)
+	if !ok {
+		return ""
+	}
+	return f.get(RequestIdKey)
+}
+
+const RequestIdKey = "request_id"
+
+func argsToAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	var r slog.Record
+	r.Add(args...)
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
+
+// handler adds the fields carried on the context, along with its trace id if it has a span, to every record
+type handler struct {
+	slog.Handler
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if f, ok := ctx.Value(ctxKey{}).(*fields); ok {
+			r.AddAttrs(f.collect()...)
+		}
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			r.AddAttrs(slog.String("trace_id", spanCtx.TraceID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func captureJson(t *testing.T, level slog.Level) *bytes.Buffer {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(&handler{slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestFieldsAreInheritedAndAddedLater(t *testing.T) {
+	buf := captureJson(t, slog.LevelInfo)
+
+	planCtx := With(context.Background(), RequestIdKey, "req-1", "plan_id", "plan-1")
+	fileCtx := With(planCtx, "path", "main.go")
+
+	// added to the plan after the file's context was derived from it
+	Add(planCtx, "model_stream_id", "stream-1")
+
+	Ctx(fileCtx).Infof("Building file %s\n", "main.go")
+
+	var line map[string]any
+	err := json.Unmarshal(buf.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("error parsing log line %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]string{
+		"msg":             "Building file main.go",
+		"request_id":      "req-1",
+		"plan_id":         "plan-1",
+		"model_stream_id": "stream-1",
+		"path":            "main.go",
+	}
+	for k, v := range expected {
+		if line[k] != v {
+			t.Errorf("expected %s to be %q, got %v", k, v, line[k])
+		}
+	}
+
+	if RequestId(fileCtx) != "req-1" {
+		t.Errorf("expected request id req-1, got %q", RequestId(fileCtx))
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	buf := captureJson(t, slog.LevelInfo)
+
+	Ctx(context.Background()).Debugf("chunk %d", 1)
+	if buf.Len() != 0 {
+		t.Errorf("expected debug line to be dropped, got %q", buf.String())
+	}
+
+	// Add on a context without fields is a no-op rather than a panic
+	Add(context.Background(), "org_id", "org-1")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -8,30 +9,65 @@ import (
 	"os/signal"
 	"plandex-server/db"
 	"plandex-server/host"
+	"plandex-server/logging"
 	"plandex-server/model/plan"
+	"plandex-server/routes"
+	"plandex-server/sso"
+	"plandex-server/tracing"
+	"plandex-server/webhooks"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+const orphanedPlansCheckInterval = 30 * time.Second
+
+// how long to let active plans finish after SIGTERM before interrupting them. Most orchestrators send SIGKILL 30 seconds after SIGTERM.
+const defaultShutdownGracePeriod = 20 * time.Second
+
 func main() {
 
-	err := host.LoadIp()
+	err := logging.Init()
+	if err != nil {
+		log.Fatal("Error initializing logging: ", err)
+	}
+
+	err = host.LoadIp()
 	if err != nil {
 		log.Fatal("Error loading IP: ", err)
 	}
 
+	err = sso.Init()
+	if err != nil {
+		log.Fatal("Error initializing SSO: ", err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatal("Error initializing tracing: ", err)
+	}
+
 	err = db.Connect()
 	if err != nil {
 		log.Fatal("Error initializing database: ", err)
 	}
 
+	err = db.ConnectPlanStorage()
+	if err != nil {
+		log.Fatal("Error initializing plan storage: ", err)
+	}
+
 	err = db.MigrationsUp()
 	if err != nil {
 		log.Fatal("Error running migrations: ", err)
 	}
 
+	// finish plans left running by servers that crashed or were restarted, then keep checking for them
+	go finishOrphanedPlans()
+
+	webhooks.Start()
+
 	if os.Getenv("GOENV") == "development" {
 		log.Println("In development mode.")
 	}
@@ -42,7 +78,7 @@ func main() {
 		externalPort = "8080"
 	}
 
-	go startServer(externalPort, routes())
+	go startServer(externalPort, routes.NewRouter())
 	log.Println("Started server on port " + externalPort)
 
 	sigTermChan := make(chan os.Signal, 1)
@@ -51,15 +87,32 @@ func main() {
 	go func() {
 		<-sigTermChan
 
+		gracePeriod := getShutdownGracePeriod()
+		deadline := time.Now().Add(gracePeriod)
+
 		for {
 			l := plan.NumActivePlans()
 			if l == 0 {
 				break
 			}
+
+			if time.Now().After(deadline) {
+				log.Printf("%d active plans still running after %s | interrupting them\n", l, gracePeriod)
+				plan.InterruptActivePlans()
+				break
+			}
+
 			log.Printf("Waiting for %d active plans to finish...\n", l)
 			time.Sleep(1 * time.Second)
 		}
 
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := shutdownTracing(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Error flushing traces: %v\n", err)
+		}
+
 		os.Exit(0)
 	}()
 
@@ -72,3 +125,39 @@ func startServer(port string, routes *mux.Router) {
 		log.Fatalf("Failed to start server on port %s: %v", port, err)
 	}
 }
+
+func finishOrphanedPlans() {
+	for {
+		err := db.FinishOrphanedModelStreams()
+		if err != nil {
+			log.Printf("Error finishing orphaned plans: %v\n", err)
+		}
+
+		err = db.DeleteExpiredRepoLocks()
+		if err != nil {
+			log.Printf("Error deleting expired repo locks: %v\n", err)
+		}
+
+		err = db.DeleteExpiredSsoLogins()
+		if err != nil {
+			log.Printf("Error deleting expired sso logins: %v\n", err)
+		}
+
+		time.Sleep(orphanedPlansCheckInterval)
+	}
+}
+
+func getShutdownGracePeriod() time.Duration {
+	s := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	if s == "" {
+		return defaultShutdownGracePeriod
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Printf("Invalid SHUTDOWN_GRACE_PERIOD %q, using %s: %v\n", s, defaultShutdownGracePeriod, err)
+		return defaultShutdownGracePeriod
+	}
+
+	return d
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics for operating a server: what's running, how the model providers are doing, and where time goes in locks, git and handlers. They're served at /metrics.
+
+const namespace = "plandex"
+
+// model streams and calls run for much longer than the default buckets allow for
+var modelStreamBuckets = prometheus.ExponentialBuckets(1, 2, 13)  // 1s to ~68m
+var modelCallBuckets = prometheus.ExponentialBuckets(0.25, 2, 11) // 250ms to ~4m
+
+var (
+	ActivePlans = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_plans",
+		Help:      "Plans with a model stream running on this server.",
+	})
+
+	ModelStreams = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_streams_total",
+		Help:      "Model streams that ended on this server, by how they ended (finished, error or stopped).",
+	}, []string{"outcome"})
+
+	ModelStreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "model_stream_duration_seconds",
+		Help:      "How long model streams ran, by how they ended.",
+		Buckets:   modelStreamBuckets,
+	}, []string{"outcome"})
+
+	ModelCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_calls_total",
+		Help:      "Model calls by provider, role, kind (stream or completion) and result (success or error), counted once retries are done.",
+	}, []string{"provider", "role", "kind", "result"})
+
+	ModelCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "model_call_duration_seconds",
+		Help:      "Time from sending a model call to the provider until it responded, including retries. For streams, this is the time until the stream opened.",
+		Buckets:   modelCallBuckets,
+	}, []string{"provider", "role", "kind"})
+
+	ModelCallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_call_errors_total",
+		Help:      "Failed requests to model providers, including those that were retried.",
+	}, []string{"provider", "role", "kind"})
+
+	ModelCallRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_call_retries_total",
+		Help:      "Model call retries after a failed request.",
+	}, []string{"provider", "role", "kind"})
+
+	ModelCallQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "model_call_queue_wait_seconds",
+		Help:      "Time model calls waited for the model call scheduler before being sent.",
+		Buckets:   modelCallBuckets,
+	}, []string{"provider", "role"})
+
+	RepoLockWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_lock_wait_seconds",
+		Help:      "Time taken to lock a plan repo, by scope (r or w) and result (acquired or failed).",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 13), // 5ms to ~20s
+	}, []string{"scope", "result"})
+
+	RepoLockRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repo_lock_retries_total",
+		Help:      "Repo lock retries, by scope and reason (conflict for serialization or deadlock errors, held when another lock was in the way).",
+	}, []string{"scope", "reason"})
+
+	GitOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "git_operation_duration_seconds",
+		Help:      "Time taken by git operations on plan repos.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14), // 1ms to ~8s
+	}, []string{"operation"})
+
+	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle API requests, by route, method and status code. Plan streams stay open for as long as the client is connected.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+)
+
+// ObserveSince records the time elapsed since start. It's meant to be deferred: defer metrics.ObserveSince(metrics.GitOperationDuration.WithLabelValues("commit"), time.Now())
+func ObserveSince(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the metrics. If METRICS_AUTH_TOKEN is set, requests must send it as a bearer token.
+func Handler() http.Handler {
+	handler := promhttp.Handler()
+	token := os.Getenv("METRICS_AUTH_TOKEN")
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
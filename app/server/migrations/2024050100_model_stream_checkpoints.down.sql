@@ -0,0 +1,2 @@
+ALTER TABLE model_streams DROP COLUMN user_id;
+ALTER TABLE model_streams DROP COLUMN checkpoint;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  org_id UUID NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  scope VARCHAR(32) NOT NULL,
+  project_ids JSON,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+CREATE UNIQUE INDEX access_tokens_idx ON access_tokens(token_hash);
+CREATE INDEX access_tokens_org_user_idx ON access_tokens(org_id, user_id);
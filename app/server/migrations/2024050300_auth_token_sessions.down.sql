@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS auth_tokens_user_idx;
+ALTER TABLE auth_tokens DROP COLUMN ip;
+ALTER TABLE auth_tokens DROP COLUMN client_version;
+ALTER TABLE auth_tokens DROP COLUMN last_used_at;
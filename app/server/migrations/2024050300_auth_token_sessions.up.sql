@@ -0,0 +1,4 @@
+ALTER TABLE auth_tokens ADD COLUMN last_used_at TIMESTAMP;
+ALTER TABLE auth_tokens ADD COLUMN client_version VARCHAR(64);
+ALTER TABLE auth_tokens ADD COLUMN ip VARCHAR(64);
+CREATE INDEX auth_tokens_user_idx ON auth_tokens(user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS sso_logins (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  state VARCHAR(64) NOT NULL,
+  nonce VARCHAR(64) NOT NULL,
+  code_verifier VARCHAR(128) NOT NULL,
+  poll_secret_hash VARCHAR(64) NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  error TEXT,
+  completed_at TIMESTAMP,
+  consumed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX sso_logins_state_idx ON sso_logins(state);
+CREATE INDEX sso_logins_created_at_idx ON sso_logins(created_at);
@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE name = 'read_audit_logs';
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS prevent_audit_event_update;
+DROP TABLE IF EXISTS audit_events;
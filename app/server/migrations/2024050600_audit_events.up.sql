@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  org_id UUID NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+  -- no foreign key so events outlive the user--actor_email keeps who it was
+  actor_id UUID NOT NULL,
+  actor_email VARCHAR(255) NOT NULL,
+  access_token_id UUID,
+  action VARCHAR(64) NOT NULL,
+  resource_id VARCHAR(255),
+  details JSON,
+  ip VARCHAR(64),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_events_org_created_idx ON audit_events(org_id, created_at);
+CREATE INDEX audit_events_org_actor_idx ON audit_events(org_id, actor_email, created_at);
+CREATE INDEX audit_events_org_action_idx ON audit_events(org_id, action, created_at);
+
+CREATE OR REPLACE FUNCTION prevent_audit_event_update()
+RETURNS TRIGGER AS $$
+BEGIN
+  RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE ON audit_events FOR EACH ROW EXECUTE FUNCTION prevent_audit_event_update();
+
+INSERT INTO permissions (name, description, resource_id) VALUES
+  ('read_audit_logs', 'Read an org''s audit log', NULL);
+
+INSERT INTO org_roles_permissions (org_role_id, permission_id)
+SELECT
+    (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'owner') AS org_role_id,
+    p.id AS permission_id
+FROM
+    permissions p
+WHERE
+    p.name = 'read_audit_logs';
@@ -0,0 +1 @@
+ALTER TABLE sso_logins DROP COLUMN user_code;
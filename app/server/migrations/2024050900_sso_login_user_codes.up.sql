@@ -0,0 +1 @@
+ALTER TABLE sso_logins ADD COLUMN user_code VARCHAR(16) NOT NULL DEFAULT '';
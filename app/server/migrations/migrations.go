@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// Postgres migrations live at the top level and SQLite migrations in sqlite/. They're embedded so the server doesn't depend on its working directory to find them.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
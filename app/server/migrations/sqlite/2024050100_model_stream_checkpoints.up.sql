@@ -0,0 +1,2 @@
+ALTER TABLE model_streams ADD COLUMN user_id TEXT REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE model_streams ADD COLUMN checkpoint JSON;
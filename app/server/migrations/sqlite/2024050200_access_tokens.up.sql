@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS access_tokens (
+  id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))),
+  org_id TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+  user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  scope VARCHAR(32) NOT NULL,
+  project_ids JSON,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  deleted_at TIMESTAMP
+);
+CREATE UNIQUE INDEX access_tokens_idx ON access_tokens(token_hash);
+CREATE INDEX access_tokens_org_user_idx ON access_tokens(org_id, user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS sso_logins (
+  id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))),
+  state VARCHAR(64) NOT NULL,
+  nonce VARCHAR(64) NOT NULL,
+  code_verifier VARCHAR(128) NOT NULL,
+  poll_secret_hash VARCHAR(64) NOT NULL,
+  user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+  error TEXT,
+  completed_at TIMESTAMP,
+  consumed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+CREATE UNIQUE INDEX sso_logins_state_idx ON sso_logins(state);
+CREATE INDEX sso_logins_created_at_idx ON sso_logins(created_at);
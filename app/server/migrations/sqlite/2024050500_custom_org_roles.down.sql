@@ -0,0 +1,9 @@
+UPDATE orgs_users SET org_role_id = (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'member') WHERE org_role_id IN (SELECT id FROM org_roles WHERE org_id IS NOT NULL);
+UPDATE invites SET org_role_id = (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'member') WHERE org_role_id IN (SELECT id FROM org_roles WHERE org_id IS NOT NULL);
+
+DELETE FROM permissions WHERE resource_id IN (SELECT id FROM org_roles WHERE org_id IS NOT NULL);
+DELETE FROM org_roles WHERE org_id IS NOT NULL;
+DELETE FROM permissions WHERE name = 'manage_org_roles';
+
+DROP INDEX IF EXISTS org_roles_permissions_role_idx;
+ALTER TABLE org_roles_permissions DROP COLUMN resource_id;
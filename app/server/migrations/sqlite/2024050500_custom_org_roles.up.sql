@@ -0,0 +1,15 @@
+-- set when a role's permission only applies to one resource, like a project, instead of the whole org
+ALTER TABLE org_roles_permissions ADD COLUMN resource_id TEXT;
+CREATE INDEX org_roles_permissions_role_idx ON org_roles_permissions(org_role_id);
+
+INSERT INTO permissions (name, description, resource_id) VALUES
+  ('manage_org_roles', 'Create, update, and delete an org''s custom roles', NULL);
+
+INSERT INTO org_roles_permissions (org_role_id, permission_id)
+SELECT
+    (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'owner') AS org_role_id,
+    p.id AS permission_id
+FROM
+    permissions p
+WHERE
+    p.name = 'manage_org_roles';
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'read_audit_logs';
+
+DROP TRIGGER IF EXISTS audit_events_append_only;
+DROP TABLE IF EXISTS audit_events;
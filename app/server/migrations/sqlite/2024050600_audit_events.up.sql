@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+  id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6)))),
+  org_id TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+  -- no foreign key so events outlive the user--actor_email keeps who it was
+  actor_id TEXT NOT NULL,
+  actor_email VARCHAR(255) NOT NULL,
+  access_token_id TEXT,
+  action VARCHAR(64) NOT NULL,
+  resource_id VARCHAR(255),
+  details JSON,
+  ip VARCHAR(64),
+  created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX audit_events_org_created_idx ON audit_events(org_id, created_at);
+CREATE INDEX audit_events_org_actor_idx ON audit_events(org_id, actor_email, created_at);
+CREATE INDEX audit_events_org_action_idx ON audit_events(org_id, action, created_at);
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit events are append-only');
+END;
+
+INSERT INTO permissions (name, description, resource_id) VALUES
+  ('read_audit_logs', 'Read an org''s audit log', NULL);
+
+INSERT INTO org_roles_permissions (org_role_id, permission_id)
+SELECT
+    (SELECT id FROM org_roles WHERE org_id IS NULL AND name = 'owner') AS org_role_id,
+    p.id AS permission_id
+FROM
+    permissions p
+WHERE
+    p.name = 'read_audit_logs';
@@ -2,19 +2,83 @@ package model
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net/url"
+	"plandex-server/metrics"
+	"plandex-server/tracing"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const OPENAI_STREAM_CHUNK_TIMEOUT = time.Duration(30) * time.Second
 
-func InitClients(apiKeys map[string]string, endpointsByApiKeyEnvVar map[string]string, openAIEndpoint, orgId string) map[string]*openai.Client {
-	clients := make(map[string]*openai.Client)
+const (
+	modelCallKindStream     = "stream"
+	modelCallKindCompletion = "completion"
+)
+
+// Client is an OpenAI-compatible API client, along with what the model call scheduler needs to know about it
+type Client struct {
+	*openai.Client
+
+	// KeyId identifies the API key without holding on to it
+	KeyId string
+
+	// Provider is the host of the API endpoint, which identifies the provider even when it's a custom one
+	Provider string
+}
+
+// ChatCompletionStream holds the stream's place in the model call scheduler, and keeps its span open, until it's closed
+type ChatCompletionStream struct {
+	*openai.ChatCompletionStream
+	release func()
+	span    trace.Span
+
+	// what the model sent, kept only while the span is recording so its tokens can be counted on close
+	output strings.Builder
+}
+
+func (s *ChatCompletionStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	resp, err := s.ChatCompletionStream.Recv()
+	if err == nil && s.span.IsRecording() {
+		for _, choice := range resp.Choices {
+			s.output.WriteString(choice.Delta.Content)
+			if choice.Delta.FunctionCall != nil {
+				s.output.WriteString(choice.Delta.FunctionCall.Arguments)
+			}
+			for _, toolCall := range choice.Delta.ToolCalls {
+				s.output.WriteString(toolCall.Function.Arguments)
+			}
+		}
+	}
+	return resp, err
+}
+
+func (s *ChatCompletionStream) Close() error {
+	defer s.release()
+
+	if s.span.IsRecording() {
+		numTokens, err := shared.GetNumTokens(s.output.String())
+		if err == nil {
+			s.span.SetAttributes(attribute.Int("gen_ai.usage.output_tokens", numTokens))
+		}
+	}
+	s.span.End()
+
+	return s.ChatCompletionStream.Close()
+}
+
+func InitClients(apiKeys map[string]string, endpointsByApiKeyEnvVar map[string]string, openAIEndpoint, orgId string) map[string]*Client {
+	clients := make(map[string]*Client)
 	for key, apiKey := range apiKeys {
 		var clientEndpoint string
 		var clientOrgId string
@@ -29,7 +93,7 @@ func InitClients(apiKeys map[string]string, endpointsByApiKeyEnvVar map[string]s
 	return clients
 }
 
-func newClient(apiKey, endpoint, orgId string) *openai.Client {
+func newClient(apiKey, endpoint, orgId string) *Client {
 	config := openai.DefaultConfig(apiKey)
 	if endpoint != "" {
 		config.BaseURL = endpoint
@@ -38,19 +102,51 @@ func newClient(apiKey, endpoint, orgId string) *openai.Client {
 		config.OrgID = orgId
 	}
 
-	return openai.NewClientWithConfig(config)
+	provider := config.BaseURL
+	if u, err := url.Parse(config.BaseURL); err == nil && u.Host != "" {
+		provider = u.Host
+	}
+
+	keyHash := sha256.Sum256([]byte(apiKey))
+
+	return &Client{
+		Client:   openai.NewClientWithConfig(config),
+		KeyId:    hex.EncodeToString(keyHash[:8]),
+		Provider: provider,
+	}
 }
 
+// role is the role the model is playing in the call. It's only used to label metrics and traces.
 func CreateChatCompletionStreamWithRetries(
-	client *openai.Client,
+	client *Client,
+	role shared.ModelRole,
 	ctx context.Context,
 	req openai.ChatCompletionRequest,
-) (*openai.ChatCompletionStream, error) {
-	return createChatCompletionStream(client, ctx, req, 0)
+) (*ChatCompletionStream, error) {
+	ctx, span := startModelCallSpan(ctx, client, role, modelCallKindStream, req)
+
+	release, err := scheduleModelCall(client, role, ctx, req)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	span.AddEvent("scheduled")
+
+	start := time.Now()
+	stream, err := createChatCompletionStream(client, role, ctx, req, 0)
+	recordModelCall(client, role, modelCallKindStream, ctx, start, err)
+	if err != nil {
+		release()
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	return &ChatCompletionStream{ChatCompletionStream: stream, release: release, span: span}, nil
 }
 
 func createChatCompletionStream(
-	client *openai.Client,
+	client *Client,
+	role shared.ModelRole,
 	ctx context.Context,
 	req openai.ChatCompletionRequest,
 	numRetry int,
@@ -63,6 +159,7 @@ func createChatCompletionStream(
 
 	if err != nil {
 		log.Printf("Error creating chat completion stream: %v, retry: %d\n", err, numRetry)
+		recordRequestError(ctx, client, role, modelCallKindStream, numRetry, err)
 
 		if isNonRetriableErr(err) {
 			return nil, err
@@ -82,11 +179,13 @@ func createChatCompletionStream(
 					waitDuration = 60 * time.Second
 				}
 				time.Sleep(waitDuration)
-				return createChatCompletionStream(client, ctx, req, numRetry+1)
+				recordRetry(ctx, client, role, modelCallKindStream, numRetry)
+				return createChatCompletionStream(client, role, ctx, req, numRetry+1)
 			}
 
 			waitBackoff(numRetry)
-			return createChatCompletionStream(client, ctx, req, numRetry+1)
+			recordRetry(ctx, client, role, modelCallKindStream, numRetry)
+			return createChatCompletionStream(client, role, ctx, req, numRetry+1)
 		}
 
 		log.Println("Max retries reached - no retry")
@@ -96,16 +195,41 @@ func createChatCompletionStream(
 	return stream, nil
 }
 
+// role is the role the model is playing in the call. It's only used to label metrics and traces.
 func CreateChatCompletionWithRetries(
-	client *openai.Client,
+	client *Client,
+	role shared.ModelRole,
 	ctx context.Context,
 	req openai.ChatCompletionRequest,
 ) (openai.ChatCompletionResponse, error) {
-	return createChatCompletion(client, ctx, req, 0)
+	ctx, span := startModelCallSpan(ctx, client, role, modelCallKindCompletion, req)
+
+	release, err := scheduleModelCall(client, role, ctx, req)
+	if err != nil {
+		tracing.End(span, err)
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer release()
+	span.AddEvent("scheduled")
+
+	start := time.Now()
+	resp, err := createChatCompletion(client, role, ctx, req, 0)
+	recordModelCall(client, role, modelCallKindCompletion, ctx, start, err)
+
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+		)
+	}
+	tracing.End(span, err)
+
+	return resp, err
 }
 
 func createChatCompletion(
-	client *openai.Client,
+	client *Client,
+	role shared.ModelRole,
 	ctx context.Context,
 	req openai.ChatCompletionRequest,
 	numRetry int,
@@ -119,6 +243,7 @@ func createChatCompletion(
 
 	if err != nil {
 		log.Printf("Error creating chat completion: %v, retry: %d\n", err, numRetry)
+		recordRequestError(ctx, client, role, modelCallKindCompletion, numRetry, err)
 
 		if isNonRetriableErr(err) {
 			return openai.ChatCompletionResponse{}, err
@@ -141,11 +266,13 @@ func createChatCompletion(
 				}
 
 				time.Sleep(waitDuration)
-				return createChatCompletion(client, ctx, req, numRetry+1)
+				recordRetry(ctx, client, role, modelCallKindCompletion, numRetry)
+				return createChatCompletion(client, role, ctx, req, numRetry+1)
 			}
 
 			waitBackoff(numRetry)
-			return createChatCompletion(client, ctx, req, numRetry+1)
+			recordRetry(ctx, client, role, modelCallKindCompletion, numRetry)
+			return createChatCompletion(client, role, ctx, req, numRetry+1)
 		}
 
 		log.Println("Max retries reached - no retry")
@@ -155,6 +282,56 @@ func createChatCompletion(
 	return resp, nil
 }
 
+func startModelCallSpan(ctx context.Context, client *Client, role shared.ModelRole, kind string, req openai.ChatCompletionRequest) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(ctx, "model.call",
+		attribute.String("plandex.model.role", string(role)),
+		attribute.String("plandex.model.provider", client.Provider),
+		attribute.String("plandex.model.kind", kind),
+		attribute.String("gen_ai.request.model", req.Model),
+	)
+
+	// counting tokens in a big prompt isn't free, so only when the span is going somewhere
+	if span.IsRecording() {
+		span.SetAttributes(attribute.Int("plandex.model.estimated_input_tokens", estimateRequestTokens(req)))
+	}
+
+	return ctx, span
+}
+
+// recordRequestError records a failed request to the provider, whether or not it will be retried
+func recordRequestError(ctx context.Context, client *Client, role shared.ModelRole, kind string, numRetry int, err error) {
+	trace.SpanFromContext(ctx).AddEvent("request failed", trace.WithAttributes(
+		attribute.Int("plandex.model.retry", numRetry),
+		attribute.String("error", err.Error()),
+	))
+
+	if ctx.Err() == nil {
+		metrics.ModelCallErrors.WithLabelValues(client.Provider, string(role), kind).Inc()
+	}
+}
+
+func recordRetry(ctx context.Context, client *Client, role shared.ModelRole, kind string, numRetry int) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("plandex.model.retries", numRetry+1))
+	metrics.ModelCallRetries.WithLabelValues(client.Provider, string(role), kind).Inc()
+}
+
+// recordModelCall records a model call's result and latency once retries are done. Calls cut short by their context finishing, which usually means the plan was stopped, don't say anything about the provider, so they're only counted.
+func recordModelCall(client *Client, role shared.ModelRole, kind string, ctx context.Context, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+		if ctx.Err() != nil {
+			result = "cancelled"
+		}
+	}
+
+	metrics.ModelCalls.WithLabelValues(client.Provider, string(role), kind, result).Inc()
+
+	if result != "cancelled" {
+		metrics.ModelCallDuration.WithLabelValues(client.Provider, string(role), kind).Observe(time.Since(start).Seconds())
+	}
+}
+
 func isNonRetriableErr(err error) bool {
 	errStr := err.Error()
 
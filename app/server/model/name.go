@@ -11,7 +11,7 @@ import (
 	"github.com/sashabaranov/go-openai"
 )
 
-func GenPlanName(client *openai.Client, config shared.ModelRoleConfig, planContent string) (string, error) {
+func GenPlanName(client *Client, config shared.ModelRoleConfig, planContent string) (string, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -26,6 +26,7 @@ func GenPlanName(client *openai.Client, config shared.ModelRoleConfig, planConte
 
 	resp, err := CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleName,
 		context.Background(),
 		openai.ChatCompletionRequest{
 			Model: config.BaseModelConfig.ModelName,
@@ -82,7 +83,7 @@ func GenPlanName(client *openai.Client, config shared.ModelRoleConfig, planConte
 
 }
 
-func GenPipedDataName(client *openai.Client, config shared.ModelRoleConfig, pipedContent string) (string, error) {
+func GenPipedDataName(client *Client, config shared.ModelRoleConfig, pipedContent string) (string, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -105,6 +106,7 @@ func GenPipedDataName(client *openai.Client, config shared.ModelRoleConfig, pipe
 
 	resp, err := CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleName,
 		context.Background(),
 		openai.ChatCompletionRequest{
 			Model: config.BaseModelConfig.ModelName,
@@ -161,7 +163,7 @@ func GenPipedDataName(client *openai.Client, config shared.ModelRoleConfig, pipe
 
 }
 
-func GenNoteName(client *openai.Client, config shared.ModelRoleConfig, note string) (string, error) {
+func GenNoteName(client *Client, config shared.ModelRoleConfig, note string) (string, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -184,6 +186,7 @@ func GenNoteName(client *openai.Client, config shared.ModelRoleConfig, note stri
 
 	resp, err := CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleName,
 		context.Background(),
 		openai.ChatCompletionRequest{
 			Model: config.BaseModelConfig.ModelName,
@@ -1,28 +1,34 @@
 package plan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/model"
 	"plandex-server/model/prompts"
 	"plandex-server/syntax"
+	"plandex-server/tracing"
 	"plandex-server/types"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func Build(
-	clients map[string]*openai.Client,
+	clients map[string]*model.Client,
 	plan *db.Plan,
 	branch string,
 	auth *types.ServerAuth,
+	ctx context.Context,
 ) (int, error) {
-	log.Printf("Build: Called with plan ID %s on branch %s\n", plan.Id, branch)
-	log.Println("Build: Starting Build operation")
+	logger := logging.Ctx(ctx)
+
+	logger.Infof("Build: Called with plan ID %s on branch %s", plan.Id, branch)
+	logger.Info("Build: Starting Build operation")
 
 	state := activeBuildStreamState{
 		clients:       clients,
@@ -31,6 +37,7 @@ func Build(
 		currentUserId: auth.User.Id,
 		plan:          plan,
 		branch:        branch,
+		log:           logger,
 	}
 
 	streamDone := func() {
@@ -41,18 +48,18 @@ func Build(
 	}
 
 	onErr := func(err error) (int, error) {
-		log.Printf("Build error: %v\n", err)
+		logger.Errorf("Build error: %v", err)
 		streamDone()
 		return 0, err
 	}
 
-	pendingBuildsByPath, err := state.loadPendingBuilds()
+	pendingBuildsByPath, err := state.loadPendingBuilds(ctx)
 	if err != nil {
 		return onErr(err)
 	}
 
 	if len(pendingBuildsByPath) == 0 {
-		log.Println("No pending builds")
+		logger.Info("No pending builds")
 		streamDone()
 		return 0, nil
 	}
@@ -60,11 +67,11 @@ func Build(
 	err = db.SetPlanStatus(plan.Id, branch, shared.PlanStatusBuilding, "")
 
 	if err != nil {
-		log.Printf("Error setting plan status to building: %v\n", err)
+		logger.Errorf("Error setting plan status to building: %v", err)
 		return onErr(fmt.Errorf("error setting plan status to building: %v", err))
 	}
 
-	log.Printf("Starting %d builds\n", len(pendingBuildsByPath))
+	logger.Infof("Starting %d builds", len(pendingBuildsByPath))
 
 	for _, pendingBuilds := range pendingBuildsByPath {
 		go state.queueBuilds(pendingBuilds)
@@ -89,17 +96,17 @@ func (state *activeBuildStreamState) queueBuilds(activeBuilds []*types.ActiveBui
 			active.BuildQueuesByPath[filePath] = append(active.BuildQueuesByPath[filePath], activeBuilds...)
 			isBuilding = active.IsBuildingByPath[filePath]
 		})
-		log.Printf("Queued %d build(s) for file %s\n", len(activeBuilds), filePath)
+		state.log.Infof("Queued %d build(s) for file %s", len(activeBuilds), filePath)
 
 		if isBuilding {
-			log.Printf("Already building file %s\n", filePath)
+			state.log.Infof("Already building file %s", filePath)
 			return
 		} else {
-			log.Printf("Not building file %s\n", filePath)
+			state.log.Infof("Not building file %s", filePath)
 
 			active := GetActivePlan(planId, branch)
 			if active == nil {
-				log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+				state.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 				return
 			}
 
@@ -117,10 +124,10 @@ func (state *activeBuildStreamState) queueBuilds(activeBuilds []*types.ActiveBui
 }
 
 func (buildState *activeBuildStreamState) execPlanBuild(activeBuild *types.ActiveBuild) {
-	log.Println("execPlanBuild")
+	buildState.log.Info("execPlanBuild")
 
 	if activeBuild == nil {
-		log.Println("No active build")
+		buildState.log.Info("No active build")
 		return
 	}
 
@@ -129,7 +136,7 @@ func (buildState *activeBuildStreamState) execPlanBuild(activeBuild *types.Activ
 
 	activePlan := GetActivePlan(planId, branch)
 	if activePlan == nil {
-		log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		buildState.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 		return
 	}
 	filePath := activeBuild.Path
@@ -141,7 +148,7 @@ func (buildState *activeBuildStreamState) execPlanBuild(activeBuild *types.Activ
 	}
 
 	// stream initial status to client
-	log.Printf("streaming initial build info for file %s\n", filePath)
+	buildState.log.Infof("streaming initial build info for file %s", filePath)
 	buildInfo := &shared.BuildInfo{
 		Path:      filePath,
 		NumTokens: 0,
@@ -152,14 +159,26 @@ func (buildState *activeBuildStreamState) execPlanBuild(activeBuild *types.Activ
 		BuildInfo: buildInfo,
 	})
 
+	spanName := "build.file"
+	if activeBuild.IsVerification {
+		spanName = "build.verify"
+	}
+	_, span := tracing.Start(activePlan.Ctx, spanName, attribute.String("plandex.build.path", filePath))
+
+	// builds cut short by the plan stopping don't reach onFinishBuildFile or onBuildFileError, so their spans end here. Ending a span twice is a no-op.
+	context.AfterFunc(activePlan.Ctx, func() { span.End() })
+
 	fileState := &activeBuildStreamFileState{
 		activeBuildStreamState: buildState,
 		filePath:               filePath,
 		activeBuild:            activeBuild,
+		span:                   span,
+		log:                    activePlan.Log().With("path", filePath),
 	}
 	err := fileState.loadBuildFile(activeBuild)
 	if err != nil {
-		log.Printf("Error loading build file: %v\n", err)
+		buildState.log.Errorf("Error loading build file: %v", err)
+		tracing.End(span, err)
 		return
 	}
 
@@ -182,15 +201,15 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		fileState.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 		return
 	}
 
-	log.Printf("Building file %s\n", filePath)
+	fileState.log.Infof("Building file %s", filePath)
 
-	log.Println("activePlan.ContextsByPath files:")
+	fileState.log.Debug("activePlan.ContextsByPath files:")
 	for k := range activePlan.ContextsByPath {
-		log.Println(k)
+		fileState.log.Debugf("%v", k)
 	}
 
 	// get relevant file context (if any)
@@ -200,17 +219,17 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 	currentPlanFile, fileInCurrentPlan := currentPlan.CurrentPlanFiles.Files[filePath]
 
 	if fileInCurrentPlan {
-		log.Printf("File %s found in current plan.\n", filePath)
+		fileState.log.Infof("File %s found in current plan.", filePath)
 		currentState = currentPlanFile
 
 		// log.Println("\n\nCurrent state:\n", currentState, "\n\n")
 
 	} else if contextPart != nil {
-		log.Printf("File %s found in model context. Using context state.\n", filePath)
+		fileState.log.Infof("File %s found in model context. Using context state.", filePath)
 		currentState = contextPart.Body
 
 		if currentState == "" {
-			log.Println("Context state is empty. That's bad.")
+			fileState.log.Info("Context state is empty. That's bad.")
 		}
 
 		// log.Println("\n\nCurrent state:\n", currentState, "\n\n")
@@ -219,7 +238,7 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 	fileState.preBuildState = currentState
 
 	if currentState == "" {
-		log.Printf("File %s not found in model context or current plan. Creating new file.\n", filePath)
+		fileState.log.Warnf("File %s not found in model context or current plan. Creating new file.", filePath)
 
 		buildInfo := &shared.BuildInfo{
 			Path:      filePath,
@@ -236,7 +255,7 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 		validationRes, err := syntax.Validate(activePlan.Ctx, filePath, activeBuild.FileContent)
 
 		if err != nil {
-			log.Printf("Error validating syntax for new file '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error validating syntax for new file '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error validating syntax for new file '%s': %v", filePath, err))
 			return
 		}
@@ -254,7 +273,7 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 			SyntaxErrors:    validationRes.Errors,
 		}
 
-		log.Println("build exec - Plan file result:")
+		fileState.log.Info("build exec - Plan file result:")
 		// spew.Dump(planRes)
 
 		fileState.isNewFile = true
@@ -265,12 +284,12 @@ func (fileState *activeBuildStreamFileState) buildFile() {
 		currentNumTokens, err := shared.GetNumTokens(currentState)
 
 		if err != nil {
-			log.Printf("Error getting num tokens for current state: %v\n", err)
+			fileState.log.Errorf("Error getting num tokens for current state: %v", err)
 			fileState.onBuildFileError(fmt.Errorf("error getting num tokens for current state: %v", err))
 			return
 		}
 
-		log.Printf("Current state num tokens: %d\n", currentNumTokens)
+		fileState.log.Infof("Current state num tokens: %d", currentNumTokens)
 
 		activeBuild.CurrentFileTokens = currentNumTokens
 	}
@@ -290,11 +309,11 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		fileState.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 		return
 	}
 
-	log.Println("buildFileLineNums - getting file from model: " + filePath)
+	fileState.log.Info("buildFileLineNums - getting file from model: " + filePath)
 	// log.Println("File context:", fileContext)
 
 	// log.Println("currentState:", currentState)
@@ -308,7 +327,7 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 		},
 	}
 
-	log.Println("buildFileLineNums - calling model for file: " + filePath)
+	fileState.log.Info("buildFileLineNums - calling model for file: " + filePath)
 
 	// for _, msg := range fileMessages {
 	// 	log.Printf("%s: %s\n", msg.Role, msg.Content)
@@ -346,9 +365,9 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 	client := clients[envVar]
 
 	if config.BaseModelConfig.HasStreamingFunctionCalls {
-		stream, err := model.CreateChatCompletionStreamWithRetries(client, activePlan.Ctx, modelReq)
+		stream, err := model.CreateChatCompletionStreamWithRetries(client, shared.ModelRoleBuilder, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 		if err != nil {
-			log.Printf("Error creating plan file stream for path '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error creating plan file stream for path '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error creating plan file stream for path '%s': %v", filePath, err))
 			return
 		}
@@ -356,13 +375,13 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 		go fileState.listenStreamChangesWithLineNums(stream)
 	} else {
 
-		log.Println("request:")
-		log.Println(spew.Sdump(modelReq))
+		fileState.log.Info("request:")
+		fileState.log.Debug(spew.Sdump(modelReq))
 
-		resp, err := model.CreateChatCompletionWithRetries(client, activePlan.Ctx, modelReq)
+		resp, err := model.CreateChatCompletionWithRetries(client, shared.ModelRoleBuilder, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 
 		if err != nil {
-			log.Printf("Error building file '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error building file '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error building file '%s': %v", filePath, err))
 			return
 		}
@@ -380,7 +399,7 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 		}
 
 		if s == "" {
-			log.Println("no ListReplacements function call found in response")
+			fileState.log.Info("no ListReplacements function call found in response")
 			fileState.lineNumsRetryOrError(fmt.Errorf("no ListReplacements function call found in response"))
 			return
 		}
@@ -389,7 +408,7 @@ func (fileState *activeBuildStreamFileState) buildFileLineNums() {
 
 		err = json.Unmarshal(bytes, &res)
 		if err != nil {
-			log.Printf("Error unmarshalling build response: %v\n", err)
+			fileState.log.Errorf("Error unmarshalling build response: %v", err)
 			fileState.lineNumsRetryOrError(fmt.Errorf("error unmarshalling build response: %v", err))
 			return
 		}
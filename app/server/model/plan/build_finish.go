@@ -2,9 +2,9 @@ package plan
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/tracing"
 	"plandex-server/types"
 	"strings"
 
@@ -12,7 +12,7 @@ import (
 )
 
 func (state *activeBuildStreamFileState) onFinishBuild() {
-	log.Println("Build finished")
+	state.log.Info("Build finished")
 
 	planId := state.plan.Id
 	branch := state.branch
@@ -27,7 +27,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 	ap := GetActivePlan(planId, branch)
 
 	if ap == nil {
-		log.Println("onFinishBuild - Active plan not found")
+		state.log.Warn("onFinishBuild - Active plan not found")
 		return
 	}
 
@@ -36,7 +36,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 		doneCh = ap.CurrentReplyDoneCh
 	}
 	if stillStreaming {
-		log.Println("Reply is still streaming, waiting for it to finish before finishing build")
+		state.log.Info("Reply is still streaming, waiting for it to finish before finishing build")
 		<-doneCh
 	}
 
@@ -45,16 +45,16 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 	ap = GetActivePlan(planId, branch)
 
 	if ap == nil {
-		log.Println("onFinishBuild - Active plan not found")
+		state.log.Warn("onFinishBuild - Active plan not found")
 		return
 	}
 
 	if !ap.BuildFinished() {
-		log.Println("Build not finished after waiting for reply to finish streaming")
+		state.log.Info("Build not finished after waiting for reply to finish streaming")
 		return
 	}
 
-	log.Println("Locking repo for finished build")
+	state.log.Info("Locking repo for finished build")
 
 	repoLockId, err := db.LockRepo(
 		db.LockRepoParams{
@@ -70,7 +70,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 	)
 
 	if err != nil {
-		log.Printf("Error locking repo for finished build: %v\n", err)
+		state.log.Errorf("Error locking repo for finished build: %v", err)
 		ap.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
 			Status: http.StatusInternalServerError,
@@ -79,33 +79,33 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 		return
 	}
 
-	log.Println("Locked repo for finished build")
+	state.log.Info("Locked repo for finished build")
 
 	err = func() error {
 		var err error
 		defer func() {
 			if err != nil {
-				log.Printf("Finish build error: %v\n", err)
+				state.log.Errorf("Finish build error: %v", err)
 				err = db.GitClearUncommittedChanges(currentOrgId, planId)
 				if err != nil {
-					log.Printf("Error clearing uncommitted changes: %v\n", err)
+					state.log.Errorf("Error clearing uncommitted changes: %v", err)
 				}
-				log.Println("Cleared uncommitted changes")
+				state.log.Info("Cleared uncommitted changes")
 			}
 
 			err := db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo: %v\n", err)
+				state.log.Errorf("Error unlocking repo: %v", err)
 			}
 
-			log.Println("Unlocked repo")
+			state.log.Info("Unlocked repo")
 		}()
 
 		// get plan descriptions
 		var planDescs []*db.ConvoMessageDescription
 		planDescs, err = db.GetConvoMessageDescriptions(currentOrgId, planId)
 		if err != nil {
-			log.Printf("Error getting pending build descriptions: %v\n", err)
+			state.log.Errorf("Error getting pending build descriptions: %v", err)
 			return fmt.Errorf("error getting pending build descriptions: %v", err)
 		}
 
@@ -124,7 +124,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 			ConvoMessageDescriptions: planDescs,
 		})
 		if err != nil {
-			log.Printf("Error getting current plan state: %v\n", err)
+			state.log.Errorf("Error getting current plan state: %v", err)
 			return fmt.Errorf("error getting current plan state: %v", err)
 		}
 
@@ -133,6 +133,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 			if len(desc.Files) > 0 {
 				desc.DidBuild = true
 				desc.BuildPathsInvalidated = map[string]bool{}
+				desc.BuildPathConflicts = nil
 			}
 
 			go func(desc *db.ConvoMessageDescription) {
@@ -150,20 +151,20 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 		for range unbuiltDescs {
 			err = <-descErrCh
 			if err != nil {
-				log.Printf("Error storing description: %v\n", err)
+				state.log.Errorf("Error storing description: %v", err)
 				return err
 			}
 		}
 
-		err = db.GitAddAndCommit(currentOrgId, planId, branch, currentPlan.PendingChangesSummaryForBuild())
+		err = gitAddAndCommit(ap.Ctx, currentOrgId, planId, branch, currentPlan.PendingChangesSummaryForBuild())
 
 		if err != nil {
 			if strings.Contains(err.Error(), "nothing to commit") {
-				log.Println("Nothing to commit")
+				state.log.Info("Nothing to commit")
 				return nil
 			}
 
-			log.Printf("Error committing plan build: %v\n", err)
+			state.log.Errorf("Error committing plan build: %v", err)
 			ap.StreamDoneCh <- &shared.ApiError{
 				Type:   shared.ApiErrorTypeOther,
 				Status: http.StatusInternalServerError,
@@ -172,7 +173,7 @@ func (state *activeBuildStreamFileState) onFinishBuild() {
 			return err
 		}
 
-		log.Println("Plan build committed")
+		state.log.Info("Plan build committed")
 
 		return nil
 
@@ -202,13 +203,21 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Println("onFinishBuildFile - Active plan not found")
+		fileState.log.Warn("onFinishBuildFile - Active plan not found")
 		return
 	}
 
 	filePath := fileState.filePath
 
-	log.Println("onFinishBuildFile: " + filePath)
+	fileState.log.Info("onFinishBuildFile: " + filePath)
+
+	// fixes carry on with the same build, so they keep its span open
+	fixing := false
+	defer func() {
+		if !fixing {
+			fileState.span.End()
+		}
+	}()
 
 	if planRes != nil {
 
@@ -220,12 +229,13 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 				Branch:      branch,
 				PlanBuildId: build.Id,
 				Scope:       db.LockScopeWrite,
-				Ctx:         activePlan.Ctx,
+				Ctx:         fileState.traceCtx(activePlan.Ctx),
 				CancelFn:    activePlan.CancelFn,
 			},
 		)
 		if err != nil {
-			log.Printf("Error locking repo for build file: %v\n", err)
+			fileState.log.Errorf("Error locking repo for build file: %v", err)
+			tracing.SetError(fileState.span, err)
 			activePlan.StreamDoneCh <- &shared.ApiError{
 				Type:   shared.ApiErrorTypeOther,
 				Status: http.StatusInternalServerError,
@@ -238,26 +248,27 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 			var err error
 			defer func() {
 				if err != nil {
-					log.Printf("Error storing plan result: %v\n", err)
+					fileState.log.Errorf("Error storing plan result: %v", err)
 					err = db.GitClearUncommittedChanges(currentOrgId, planId)
 					if err != nil {
-						log.Printf("Error clearing uncommitted changes: %v\n", err)
+						fileState.log.Errorf("Error clearing uncommitted changes: %v", err)
 					}
 				}
 
-				log.Println("Plan result stored successfully. Unlocking repo.")
+				fileState.log.Info("Plan result stored successfully. Unlocking repo.")
 
 				err := db.DeleteRepoLock(repoLockId)
 				if err != nil {
-					log.Printf("Error unlocking repo: %v\n", err)
+					fileState.log.Errorf("Error unlocking repo: %v", err)
 				}
 			}()
 
-			log.Println("Storing plan result")
+			fileState.log.Info("Storing plan result")
 
 			err = db.StorePlanResult(planRes)
 			if err != nil {
-				log.Printf("Error storing plan result: %v\n", err)
+				fileState.log.Errorf("Error storing plan result: %v", err)
+				tracing.SetError(fileState.span, err)
 				activePlan.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
 					Status: http.StatusInternalServerError,
@@ -266,7 +277,7 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 				return err
 			}
 
-			log.Println("Plan result stored")
+			fileState.log.Info("Plan result stored")
 			return nil
 		}()
 
@@ -287,6 +298,7 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 				fileState.syntaxErrors = planRes.SyntaxErrors
 				fileState.preBuildState = fileState.updated
 				fileState.updated = updated
+				fixing = true
 				go fileState.fixFileLineNums()
 				return
 			}
@@ -295,6 +307,7 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 			fileState.syntaxErrors = planRes.SyntaxErrors
 			fileState.preBuildState = fileState.updated
 			fileState.updated = updated
+			fixing = true
 			go fileState.fixFileLineNums()
 			return
 		}
@@ -304,10 +317,10 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 
 	// if more builds are queued, start the next one regardless of whether this is a verification build or not, then return
 	if !activePlan.PathQueueEmpty(filePath) {
-		log.Printf("Processing next build for file %s\n", filePath)
+		fileState.log.Infof("Processing next build for file %s", filePath)
 		activePlan := GetActivePlan(planId, branch)
 		if activePlan == nil {
-			log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+			fileState.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 			return
 		}
 		queue := activePlan.BuildQueuesByPath[filePath]
@@ -320,7 +333,7 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 		}
 
 		if nextBuild != nil {
-			log.Println("Calling execPlanBuild for next build in queue")
+			fileState.log.Info("Calling execPlanBuild for next build in queue")
 			go fileState.execPlanBuild(nextBuild)
 		}
 		return
@@ -340,10 +353,10 @@ func (fileState *activeBuildStreamFileState) onFinishBuildFile(planRes *db.PlanF
 			}
 		})
 
-		log.Printf("Finished building file %s\n", filePath)
+		fileState.log.Infof("Finished building file %s", filePath)
 
 		if buildFinished {
-			log.Println("Finished building plan, calling onFinishBuild")
+			fileState.log.Info("Finished building plan, calling onFinishBuild")
 			fileState.onFinishBuild()
 		}
 	} else {
@@ -368,14 +381,16 @@ func (fileState *activeBuildStreamFileState) onBuildFileError(err error) {
 	activeBuild := fileState.activeBuild
 	currentOrgId := fileState.currentOrgId
 
+	tracing.End(fileState.span, err)
+
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Println("onBuildFileError - Active plan not found")
+		fileState.log.Warn("onBuildFileError - Active plan not found")
 		return
 	}
 
-	log.Printf("Error for file %s: %v\n", filePath, err)
+	fileState.log.Errorf("Error for file %s: %v", filePath, err)
 
 	activeBuild.Success = false
 	activeBuild.Error = err
@@ -387,20 +402,20 @@ func (fileState *activeBuildStreamFileState) onBuildFileError(err error) {
 	}
 
 	if err != nil {
-		log.Printf("Error storing plan error result: %v\n", err)
+		fileState.log.Errorf("Error storing plan error result: %v", err)
 	}
 
 	build.Error = err.Error()
 
 	err = db.SetBuildError(build)
 	if err != nil {
-		log.Printf("Error setting build error: %v\n", err)
+		fileState.log.Errorf("Error setting build error: %v", err)
 	}
 
 	// rollback repo in case there are uncommitted builds
 	err = db.GitClearUncommittedChanges(currentOrgId, planId)
 
 	if err != nil {
-		log.Printf("Error clearing uncommitted changes: %v\n", err)
+		fileState.log.Errorf("Error clearing uncommitted changes: %v", err)
 	}
 }
@@ -3,7 +3,6 @@ package plan
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"plandex-server/model"
 	"plandex-server/model/prompts"
 	"plandex-server/types"
@@ -11,6 +10,8 @@ import (
 
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (fileState *activeBuildStreamFileState) fixFileLineNums() {
@@ -25,7 +26,7 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("fixFileLineNums - Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		fileState.log.Warnf("fixFileLineNums - Active plan not found for plan ID %s and branch %s", planId, branch)
 		return
 	}
 
@@ -34,7 +35,13 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 
 	// log.Printf("%s - fixFileLineNums - Incorrectly updated content hash: %s\n", filePath, sha)
 
-	log.Println("fixFileLineNums - getting file from model: " + filePath)
+	fileState.span.AddEvent("fix", trace.WithAttributes(
+		attribute.Bool("plandex.build.fixing_syntax", fileState.isFixingSyntax),
+		attribute.Int("plandex.build.fix_epoch", fileState.syntaxNumEpoch),
+		attribute.Int("plandex.build.fix_retry", fileState.syntaxNumRetry),
+	))
+
+	fileState.log.Info("fixFileLineNums - getting file from model: " + filePath)
 	// log.Println("File context:", fileContext)
 
 	reasoning := ""
@@ -61,7 +68,7 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 		},
 	}
 
-	log.Println("fixFileLineNums - calling model for file: " + filePath)
+	fileState.log.Info("fixFileLineNums - calling model for file: " + filePath)
 
 	// for _, msg := range fileMessages {
 	// 	log.Printf("%s: %s\n", msg.Role, msg.Content)
@@ -100,9 +107,9 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 
 	if config.BaseModelConfig.HasStreamingFunctionCalls {
 
-		stream, err := model.CreateChatCompletionStreamWithRetries(client, activePlan.Ctx, modelReq)
+		stream, err := model.CreateChatCompletionStreamWithRetries(client, shared.ModelRoleAutoFix, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 		if err != nil {
-			log.Printf("Error creating plan file stream for path '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error creating plan file stream for path '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error creating plan file stream for path '%s': %v", filePath, err))
 			return
 		}
@@ -119,10 +126,10 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 			BuildInfo: buildInfo,
 		})
 
-		resp, err := model.CreateChatCompletionWithRetries(client, activePlan.Ctx, modelReq)
+		resp, err := model.CreateChatCompletionWithRetries(client, shared.ModelRoleAutoFix, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 
 		if err != nil {
-			log.Printf("Error building file '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error building file '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error building file '%s': %v", filePath, err))
 			return
 		}
@@ -140,7 +147,7 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 		}
 
 		if s == "" {
-			log.Println("no ListReplacements function call found in response")
+			fileState.log.Info("no ListReplacements function call found in response")
 			fileState.fixRetryOrAbort(fmt.Errorf("no ListReplacements function call found in response"))
 			return
 		}
@@ -149,7 +156,7 @@ func (fileState *activeBuildStreamFileState) fixFileLineNums() {
 
 		err = json.Unmarshal(bytes, &res)
 		if err != nil {
-			log.Printf("Error unmarshalling fix response: %v\n", err)
+			fileState.log.Errorf("Error unmarshalling fix response: %v", err)
 			fileState.fixRetryOrAbort(fmt.Errorf("error unmarshalling fix response: %v", err))
 			return
 		}
@@ -2,7 +2,6 @@ package plan
 
 import (
 	"fmt"
-	"log"
 	"math/rand"
 	"plandex-server/types"
 	"sort"
@@ -23,7 +22,7 @@ func (fileState *activeBuildStreamFileState) onFixResult(res types.ChangesWithLi
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStreamFixChanges - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStreamFixChanges - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 	sorted := []*shared.StreamedChangeWithLineNums{}
@@ -44,7 +43,7 @@ func (fileState *activeBuildStreamFileState) onFixResult(res types.ChangesWithLi
 		iStartLine, _, err := sorted[i].GetLines()
 
 		if err != nil {
-			log.Printf("listenStream - Error getting start line for change %v: %v\n", sorted[i], err)
+			fileState.log.Errorf("listenStream - Error getting start line for change %v: %v", sorted[i], err)
 			fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - error getting start line for change %v: %v", sorted[i], err))
 			return false
 		}
@@ -52,7 +51,7 @@ func (fileState *activeBuildStreamFileState) onFixResult(res types.ChangesWithLi
 		jStartLine, _, err = sorted[j].GetLines()
 
 		if err != nil {
-			log.Printf("listenStream - Error getting start line for change %v: %v\n", sorted[j], err)
+			fileState.log.Errorf("listenStream - Error getting start line for change %v: %v", sorted[j], err)
 			fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - error getting start line for change %v: %v", sorted[j], err))
 			return false
 		}
@@ -90,16 +89,16 @@ func (fileState *activeBuildStreamFileState) onFixResult(res types.ChangesWithLi
 	)
 
 	if err != nil {
-		log.Println("listenStreamFixChanges - Error getting plan result:", err)
+		fileState.log.Errorf("listenStreamFixChanges - Error getting plan result: %v", err)
 		fileState.fixRetryOrAbort(fmt.Errorf("listenStreamFixChanges - error getting plan result for file '%s': %v", filePath, err))
 		return
 	}
 
 	if !allSucceeded {
-		log.Println("listenStreamFixChanges - Failed replacements:")
+		fileState.log.Error("listenStreamFixChanges - Failed replacements:")
 		for _, replacement := range planFileResult.Replacements {
 			if replacement.Failed {
-				spew.Dump(replacement)
+				fileState.log.Error(spew.Sdump(replacement))
 			}
 		}
 
@@ -109,7 +108,7 @@ func (fileState *activeBuildStreamFileState) onFixResult(res types.ChangesWithLi
 
 	}
 
-	log.Println("listenStreamFixChanges - Plan file result:")
+	fileState.log.Info("listenStreamFixChanges - Plan file result:")
 	// spew.Dump(planFileResult)
 
 	// reset fix state
@@ -146,19 +145,19 @@ func (fileState *activeBuildStreamFileState) fixRetryOrAbort(err error) {
 		fileState.fixFileNumRetry++
 		fileState.activeBuild.FixBuffer = ""
 		fileState.activeBuild.FixBufferTokens = 0
-		log.Printf("Retrying fix file '%s' due to error: %v\n", fileState.filePath, err)
+		fileState.log.Errorf("Retrying fix file '%s' due to error: %v", fileState.filePath, err)
 
 		// Exponential backoff
 		time.Sleep(time.Duration((fileState.verifyFileNumRetry*fileState.verifyFileNumRetry)/2)*200*time.Millisecond + time.Duration(rand.Intn(500))*time.Millisecond)
 
 		fileState.fixFileLineNums()
 	} else {
-		log.Printf("Aborting fix file '%s' due to error: %v\n", fileState.filePath, err)
+		fileState.log.Errorf("Aborting fix file '%s' due to error: %v", fileState.filePath, err)
 
 		activePlan := GetActivePlan(fileState.plan.Id, fileState.branch)
 
 		if activePlan == nil {
-			log.Println("fixRetryOrAbort - Active plan not found")
+			fileState.log.Warn("fixRetryOrAbort - Active plan not found")
 			return
 		}
 
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"plandex-server/model"
 	"plandex-server/types"
@@ -12,10 +11,9 @@ import (
 	"time"
 
 	"github.com/plandex/plandex/shared"
-	"github.com/sashabaranov/go-openai"
 )
 
-func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *openai.ChatCompletionStream) {
+func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *model.ChatCompletionStream) {
 	filePath := fileState.filePath
 	planId := fileState.plan.Id
 	branch := fileState.branch
@@ -23,7 +21,7 @@ func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *open
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStreamFixChanges - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStreamFixChanges - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -52,10 +50,10 @@ func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *open
 				}
 				timer.Reset(model.OPENAI_STREAM_CHUNK_TIMEOUT)
 			} else {
-				log.Printf("listenStreamFixChanges - File %s: Error receiving stream chunk: %v\n", filePath, err)
+				fileState.log.Errorf("listenStreamFixChanges - File %s: Error receiving stream chunk: %v", filePath, err)
 
 				if err == context.Canceled {
-					log.Printf("listenStreamFixChanges - File %s: Stream canceled\n", filePath)
+					fileState.log.Infof("listenStreamFixChanges - File %s: Stream canceled", filePath)
 					return
 				}
 
@@ -76,7 +74,7 @@ func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *open
 
 				trimmed := strings.TrimSpace(content)
 				if trimmed == "{%invalidjson%}" || trimmed == "``(no output)``````" {
-					log.Println("listenStreamFixChanges - File", filePath+":", "%invalidjson%} token in streamed chunk")
+					fileState.log.Warnf("listenStreamFixChanges - File %s: %%invalidjson%%} token in streamed chunk", filePath)
 					fileState.fixRetryOrAbort(fmt.Errorf("listenStreamFixChanges - invalid JSON in streamed chunk for file '%s'", filePath))
 					return
 				}
@@ -99,13 +97,13 @@ func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *open
 				// After a reasonable threshhold, if buffer has significantly more tokens than original file + proposed changes, something is wrong
 				cutoff := int(math.Max(float64(fileState.activeBuild.CurrentFileTokens+fileState.activeBuild.FileContentTokens), 500) * 20)
 				if fileState.activeBuild.FixBufferTokens > 500 && fileState.activeBuild.FixBufferTokens > cutoff {
-					log.Printf("File %s: Stream buffer tokens too high\n", filePath)
-					log.Printf("Current file tokens: %d\n", fileState.activeBuild.CurrentFileTokens)
-					log.Printf("File content tokens: %d\n", fileState.activeBuild.FileContentTokens)
-					log.Printf("Cutoff: %d\n", cutoff)
-					log.Printf("Buffer tokens: %d\n", fileState.activeBuild.FixBufferTokens)
-					log.Println("Buffer:")
-					log.Println(fileState.activeBuild.FixBuffer)
+					fileState.log.Warnf("File %s: Stream buffer tokens too high", filePath)
+					fileState.log.Debugf("Current file tokens: %d", fileState.activeBuild.CurrentFileTokens)
+					fileState.log.Debugf("File content tokens: %d", fileState.activeBuild.FileContentTokens)
+					fileState.log.Debugf("Cutoff: %d", cutoff)
+					fileState.log.Debugf("Buffer tokens: %d", fileState.activeBuild.FixBufferTokens)
+					fileState.log.Debug("Buffer:")
+					fileState.log.Debugf("%v", fileState.activeBuild.FixBuffer)
 
 					fileState.fixRetryOrAbort(fmt.Errorf("listenStreamFixChanges - stream buffer tokens too high for file '%s'", filePath))
 					return
@@ -116,12 +114,12 @@ func (fileState *activeBuildStreamFileState) listenStreamFixChanges(stream *open
 			err = json.Unmarshal([]byte(fileState.activeBuild.FixBuffer), &streamed)
 
 			if err == nil {
-				log.Printf("listenStreamFixChanges - File %s: Parsed streamed replacements\n", filePath)
+				fileState.log.Debugf("listenStreamFixChanges - File %s: Parsed streamed replacements", filePath)
 				// spew.Dump(streamed)
 				fileState.onFixResult(streamed)
 				return
 			} else if len(delta.ToolCalls) == 0 {
-				log.Println("listenStreamFixChanges - Stream chunk missing function call.")
+				fileState.log.Debug("listenStreamFixChanges - Stream chunk missing function call.")
 
 				fileState.fixRetryOrAbort(fmt.Errorf("listenStreamFixChanges - stream chunk missing function call. File: %s", filePath))
 				return
@@ -1,8 +1,8 @@
 package plan
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/types"
@@ -10,16 +10,16 @@ import (
 	"github.com/plandex/plandex/shared"
 )
 
-func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.ActiveBuild, error) {
+func (state *activeBuildStreamState) loadPendingBuilds(ctx context.Context) (map[string][]*types.ActiveBuild, error) {
 	clients := state.clients
 	plan := state.plan
 	branch := state.branch
 	auth := state.auth
 
-	active, err := activatePlan(clients, plan, branch, auth, "", true)
+	active, err := activatePlan(clients, plan, branch, auth, "", true, ctx)
 
 	if err != nil {
-		log.Printf("Error activating plan: %v\n", err)
+		state.log.Errorf("Error activating plan: %v", err)
 	}
 
 	repoLockId, err := db.LockRepo(
@@ -45,7 +45,7 @@ func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.Ac
 		defer func() {
 			err := db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo: %v\n", err)
+				state.log.Errorf("Error unlocking repo: %v", err)
 			}
 		}()
 
@@ -54,7 +54,7 @@ func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.Ac
 		go func() {
 			res, err := db.GetPlanContexts(auth.OrgId, plan.Id, true)
 			if err != nil {
-				log.Printf("Error getting plan modelContext: %v\n", err)
+				state.log.Errorf("Error getting plan modelContext: %v", err)
 				errCh <- fmt.Errorf("error getting plan modelContext: %v", err)
 				return
 			}
@@ -67,7 +67,7 @@ func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.Ac
 			res, err := active.PendingBuildsByPath(auth.OrgId, auth.User.Id, nil)
 
 			if err != nil {
-				log.Printf("Error getting pending builds by path: %v\n", err)
+				state.log.Errorf("Error getting pending builds by path: %v", err)
 				errCh <- fmt.Errorf("error getting pending builds by path: %v", err)
 				return
 			}
@@ -80,7 +80,7 @@ func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.Ac
 		go func() {
 			res, err := db.GetPlanSettings(plan, true)
 			if err != nil {
-				log.Printf("Error getting plan settings: %v\n", err)
+				state.log.Errorf("Error getting plan settings: %v", err)
 				errCh <- fmt.Errorf("error getting plan settings: %v", err)
 				return
 			}
@@ -92,7 +92,7 @@ func (state *activeBuildStreamState) loadPendingBuilds() (map[string][]*types.Ac
 		for i := 0; i < 3; i++ {
 			err = <-errCh
 			if err != nil {
-				log.Printf("Error getting plan data: %v\n", err)
+				state.log.Errorf("Error getting plan data: %v", err)
 				return err
 			}
 		}
@@ -143,7 +143,7 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 	err := db.StorePlanBuild(build)
 
 	if err != nil {
-		log.Printf("Error storing plan build: %v\n", err)
+		state.log.Errorf("Error storing plan build: %v", err)
 		UpdateActivePlan(activePlan.Id, activePlan.Branch, func(ap *types.ActivePlan) {
 			ap.IsBuildingByPath[filePath] = false
 		})
@@ -158,7 +158,7 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 	var currentPlan *shared.CurrentPlanState
 	var convo []*db.ConvoMessage
 
-	log.Println("Locking repo for load build file")
+	state.log.Info("Locking repo for load build file")
 
 	repoLockId, err := db.LockRepo(
 		db.LockRepoParams{
@@ -168,12 +168,12 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 			Branch:      branch,
 			PlanBuildId: build.Id,
 			Scope:       db.LockScopeRead,
-			Ctx:         activePlan.Ctx,
+			Ctx:         state.traceCtx(activePlan.Ctx),
 			CancelFn:    activePlan.CancelFn,
 		},
 	)
 	if err != nil {
-		log.Printf("Error locking repo for build file: %v\n", err)
+		state.log.Errorf("Error locking repo for build file: %v", err)
 		UpdateActivePlan(activePlan.Id, activePlan.Branch, func(ap *types.ActivePlan) {
 			ap.IsBuildingByPath[filePath] = false
 		})
@@ -185,15 +185,15 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 		return err
 	}
 
-	log.Println("Locked repo for load build file")
+	state.log.Info("Locked repo for load build file")
 
 	err = func() error {
 		defer func() {
-			log.Printf("Unlocking repo for load build file")
+			state.log.Info("Unlocking repo for load build file")
 
 			err := db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo: %v\n", err)
+				state.log.Errorf("Error unlocking repo: %v", err)
 			}
 		}()
 
@@ -205,7 +205,7 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 				PlanId: planId,
 			})
 			if err != nil {
-				log.Printf("Error getting current plan state: %v\n", err)
+				state.log.Errorf("Error getting current plan state: %v", err)
 				UpdateActivePlan(activePlan.Id, activePlan.Branch, func(ap *types.ActivePlan) {
 					ap.IsBuildingByPath[filePath] = false
 				})
@@ -219,14 +219,14 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 			}
 			currentPlan = res
 
-			log.Println("Got current plan state")
+			state.log.Info("Got current plan state")
 			errCh <- nil
 		}()
 
 		go func() {
 			res, err := db.GetPlanConvo(currentOrgId, planId)
 			if err != nil {
-				log.Printf("Error getting plan convo: %v\n", err)
+				state.log.Errorf("Error getting plan convo: %v", err)
 				errCh <- fmt.Errorf("error getting plan convo: %v", err)
 				return
 			}
@@ -238,7 +238,7 @@ func (state *activeBuildStreamFileState) loadBuildFile(activeBuild *types.Active
 		for i := 0; i < 2; i++ {
 			err = <-errCh
 			if err != nil {
-				log.Printf("Error getting plan data: %v\n", err)
+				state.log.Errorf("Error getting plan data: %v", err)
 				return err
 			}
 		}
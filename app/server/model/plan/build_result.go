@@ -3,9 +3,9 @@ package plan
 import (
 	"context"
 	"fmt"
-	"log"
 	"math/rand"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/syntax"
 	"plandex-server/types"
 	"sort"
@@ -47,6 +47,7 @@ func GetPlanResult(ctx context.Context, params PlanResultParams) (*db.PlanFileRe
 	planId := params.PlanId
 	planBuildId := params.PlanBuildId
 	filePath := params.FilePath
+	logger := logging.Ctx(ctx).With("path", filePath)
 	preBuildState := params.PreBuildState
 	streamedChangesWithLineNums := params.ChangesWithLineNums
 
@@ -92,29 +93,29 @@ func GetPlanResult(ctx context.Context, params PlanResultParams) (*db.PlanFileRe
 		startLine, endLine, err := streamedChange.GetLines()
 
 		if err != nil {
-			log.Println("getPlanResult - Error getting lines from streamedChange:", err)
+			logger.Errorf("getPlanResult - Error getting lines from streamedChange: %v", err)
 			return nil, "", false, fmt.Errorf("error getting lines from streamedChange: %v", err)
 		}
 
 		if startLine > len(preBuildStateLines) {
-			log.Printf("Start line is greater than preBuildStateLines length: %d > %d\n", startLine, len(preBuildStateLines))
+			logger.Infof("Start line is greater than preBuildStateLines length: %d > %d", startLine, len(preBuildStateLines))
 			return nil, "", false, fmt.Errorf("start line is greater than preBuildStateLines length: %d > %d", startLine, len(preBuildStateLines))
 		}
 
 		if endLine < 1 {
-			log.Printf("End line is less than 1: %d\n", endLine)
+			logger.Infof("End line is less than 1: %d", endLine)
 			return nil, "", false, fmt.Errorf("end line is less than 1: %d", endLine)
 		}
 		if endLine > len(preBuildStateLines) {
-			log.Printf("End line is greater than preBuildStateLines length: %d > %d\n", endLine, len(preBuildStateLines))
+			logger.Infof("End line is greater than preBuildStateLines length: %d > %d", endLine, len(preBuildStateLines))
 			return nil, "", false, fmt.Errorf("end line is greater than preBuildStateLines length: %d > %d", endLine, len(preBuildStateLines))
 		}
 
 		if startLine < highestEndLine {
-			log.Printf("Start line is less than highestEndLine: %d < %d\n", startLine, highestEndLine)
+			logger.Infof("Start line is less than highestEndLine: %d < %d", startLine, highestEndLine)
 
-			log.Printf("streamedChange:\n")
-			log.Println(spew.Sdump(streamedChangesWithLineNums))
+			logger.Info("streamedChange:")
+			logger.Debug(spew.Sdump(streamedChangesWithLineNums))
 
 			if params.OverlapStrategy == OverlapStrategyError {
 				return nil, "", false, fmt.Errorf("start line is less than highestEndLine: %d < %d", startLine,
@@ -126,7 +127,7 @@ func GetPlanResult(ctx context.Context, params PlanResultParams) (*db.PlanFileRe
 
 		if endLine < highestEndLine {
 			if params.OverlapStrategy == OverlapStrategyError {
-				log.Printf("End line is less than highestEndLine: %d < %d\n", endLine, highestEndLine)
+				logger.Infof("End line is less than highestEndLine: %d < %d", endLine, highestEndLine)
 				return nil, "", false, fmt.Errorf("end line is less than highestEndLine: %d < %d", endLine, highestEndLine)
 			} else {
 				continue
@@ -154,7 +155,7 @@ func GetPlanResult(ctx context.Context, params PlanResultParams) (*db.PlanFileRe
 		replacements = append(replacements, replacement)
 	}
 
-	log.Println("Will apply replacements")
+	logger.Info("Will apply replacements")
 	// log.Println("preBuildState:", preBuildState)
 
 	// log.Println("Replacements:")
@@ -198,7 +199,7 @@ func GetPlanResult(ctx context.Context, params PlanResultParams) (*db.PlanFileRe
 		validationRes, err := syntax.Validate(ctx, filePath, updated)
 
 		if err != nil {
-			log.Println("Error validating syntax:", err)
+			logger.Errorf("Error validating syntax: %v", err)
 			return nil, "", false, fmt.Errorf("error validating syntax: %v", err)
 		}
 
@@ -224,7 +225,7 @@ func (fileState *activeBuildStreamFileState) onBuildResult(res types.ChangesWith
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStream - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStream - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -246,7 +247,7 @@ func (fileState *activeBuildStreamFileState) onBuildResult(res types.ChangesWith
 		iStartLine, _, err := sorted[i].GetLines()
 
 		if err != nil {
-			log.Printf("listenStream - Error getting start line for change %v: %v\n", sorted[i], err)
+			fileState.log.Errorf("listenStream - Error getting start line for change %v: %v", sorted[i], err)
 			fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - error getting start line for change %v: %v", sorted[i], err))
 			return false
 		}
@@ -254,7 +255,7 @@ func (fileState *activeBuildStreamFileState) onBuildResult(res types.ChangesWith
 		jStartLine, _, err = sorted[j].GetLines()
 
 		if err != nil {
-			log.Printf("listenStream - Error getting start line for change %v: %v\n", sorted[j], err)
+			fileState.log.Errorf("listenStream - Error getting start line for change %v: %v", sorted[j], err)
 			fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - error getting start line for change %v: %v", sorted[j], err))
 			return false
 		}
@@ -285,16 +286,16 @@ func (fileState *activeBuildStreamFileState) onBuildResult(res types.ChangesWith
 	)
 
 	if err != nil {
-		log.Println("listenStream - Error getting plan result:", err)
+		fileState.log.Errorf("listenStream - Error getting plan result: %v", err)
 		fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - error getting plan result for file '%s': %v", filePath, err))
 		return
 	}
 
 	if !allSucceeded {
-		log.Println("listenStream - Failed replacements:")
+		fileState.log.Error("listenStream - Failed replacements:")
 		for _, replacement := range planFileResult.Replacements {
 			if replacement.Failed {
-				spew.Dump(replacement)
+				fileState.log.Error(spew.Sdump(replacement))
 			}
 		}
 
@@ -316,8 +317,8 @@ func (fileState *activeBuildStreamFileState) onBuildResult(res types.ChangesWith
 
 	fileState.updated = updatedFile
 
-	log.Println("build stream - Plan file result:", planFileResult != nil)
-	log.Printf("updatedFile exists: %v\n", updatedFile != "")
+	fileState.log.Infof("build stream - Plan file result: %v", planFileResult != nil)
+	fileState.log.Infof("updatedFile exists: %v", updatedFile != "")
 
 	fileState.onFinishBuildFile(planFileResult, updatedFile)
 }
@@ -327,18 +328,18 @@ func (fileState *activeBuildStreamFileState) lineNumsRetryOrError(err error) {
 		fileState.lineNumsNumRetry++
 		fileState.activeBuild.WithLineNumsBuffer = ""
 		fileState.activeBuild.WithLineNumsBufferTokens = 0
-		log.Printf("Retrying line nums build file '%s' due to error: %v\n", fileState.filePath, err)
+		fileState.log.Errorf("Retrying line nums build file '%s' due to error: %v", fileState.filePath, err)
 
 		activePlan := GetActivePlan(fileState.plan.Id, fileState.branch)
 
 		if activePlan == nil {
-			log.Println("lineNumsRetryOrError - Active plan not found")
+			fileState.log.Warn("lineNumsRetryOrError - Active plan not found")
 			return
 		}
 
 		select {
 		case <-activePlan.Ctx.Done():
-			log.Println("lineNumsRetryOrError - Context canceled. Exiting.")
+			fileState.log.Info("lineNumsRetryOrError - Context canceled. Exiting.")
 			return
 		case <-time.After(time.Duration((fileState.verifyFileNumRetry*fileState.verifyFileNumRetry)/2)*200*time.Millisecond + time.Duration(rand.Intn(500))*time.Millisecond):
 			break
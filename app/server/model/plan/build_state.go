@@ -1,11 +1,14 @@
 package plan
 
 import (
+	"context"
 	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/model"
 	"plandex-server/types"
 
 	"github.com/plandex/plandex/shared"
-	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const MaxBuildStreamErrorRetries = 3 // uses semi-exponential backoff so be careful with this
@@ -14,7 +17,7 @@ const FixSyntaxRetries = 2
 const FixSyntaxEpochs = 2
 
 type activeBuildStreamState struct {
-	clients       map[string]*openai.Client
+	clients       map[string]*model.Client
 	auth          *types.ServerAuth
 	currentOrgId  string
 	currentUserId string
@@ -23,6 +26,7 @@ type activeBuildStreamState struct {
 	settings      *shared.PlanSettings
 	modelContext  []*db.Context
 	convo         []*db.ConvoMessage
+	log           *logging.Logger
 }
 
 type activeBuildStreamFileState struct {
@@ -50,4 +54,15 @@ type activeBuildStreamFileState struct {
 	syntaxErrors       []string
 
 	isNewFile bool
+
+	// the plan's logger, tagged with the file's path
+	log *logging.Logger
+
+	// covers the build from loading it until it's stored, including any fixes
+	span trace.Span
+}
+
+// traceCtx carries ctx's cancellation with the file's build span, so model calls, locks and commits for the file are traced under it
+func (fileState *activeBuildStreamFileState) traceCtx(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(ctx, fileState.span)
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"plandex-server/model"
 	"plandex-server/types"
@@ -12,10 +11,9 @@ import (
 	"time"
 
 	"github.com/plandex/plandex/shared"
-	"github.com/sashabaranov/go-openai"
 )
 
-func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(stream *openai.ChatCompletionStream) {
+func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(stream *model.ChatCompletionStream) {
 	filePath := fileState.filePath
 	planId := fileState.plan.Id
 	branch := fileState.branch
@@ -23,7 +21,7 @@ func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(str
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStream - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStream - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -52,10 +50,10 @@ func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(str
 				}
 				timer.Reset(model.OPENAI_STREAM_CHUNK_TIMEOUT)
 			} else {
-				log.Printf("listenStream - File %s: Error receiving stream chunk: %v\n", filePath, err)
+				fileState.log.Errorf("listenStream - File %s: Error receiving stream chunk: %v", filePath, err)
 
 				if err == context.Canceled {
-					log.Printf("listenStream - File %s: Stream canceled\n", filePath)
+					fileState.log.Infof("listenStream - File %s: Stream canceled", filePath)
 					// log.Println("current buffer:")
 					// log.Println(fileState.activeBuild.WithLineNumsBuffer)
 					return
@@ -79,7 +77,7 @@ func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(str
 
 				trimmed := strings.TrimSpace(content)
 				if trimmed == "{%invalidjson%}" || trimmed == "``(no output)``````" {
-					log.Println("File", filePath+":", "%invalidjson%} token in streamed chunk")
+					fileState.log.Warnf("File %s: %%invalidjson%%} token in streamed chunk", filePath)
 					fileState.lineNumsRetryOrError(fmt.Errorf("invalid JSON in streamed chunk for file '%s'", filePath))
 
 					return
@@ -103,13 +101,13 @@ func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(str
 				// After a reasonable threshhold, if buffer has significantly more tokens than original file + proposed changes, something is wrong
 				cutoff := int(math.Max(float64(fileState.activeBuild.CurrentFileTokens+fileState.activeBuild.FileContentTokens), 500) * 20)
 				if fileState.activeBuild.WithLineNumsBufferTokens > 500 && fileState.activeBuild.WithLineNumsBufferTokens > cutoff {
-					log.Printf("File %s: Stream buffer tokens too high\n", filePath)
-					log.Printf("Current file tokens: %d\n", fileState.activeBuild.CurrentFileTokens)
-					log.Printf("File content tokens: %d\n", fileState.activeBuild.FileContentTokens)
-					log.Printf("Cutoff: %d\n", cutoff)
-					log.Printf("Buffer tokens: %d\n", fileState.activeBuild.WithLineNumsBufferTokens)
-					log.Println("Buffer:")
-					log.Println(fileState.activeBuild.WithLineNumsBuffer)
+					fileState.log.Warnf("File %s: Stream buffer tokens too high", filePath)
+					fileState.log.Debugf("Current file tokens: %d", fileState.activeBuild.CurrentFileTokens)
+					fileState.log.Debugf("File content tokens: %d", fileState.activeBuild.FileContentTokens)
+					fileState.log.Debugf("Cutoff: %d", cutoff)
+					fileState.log.Debugf("Buffer tokens: %d", fileState.activeBuild.WithLineNumsBufferTokens)
+					fileState.log.Debug("Buffer:")
+					fileState.log.Debugf("%v", fileState.activeBuild.WithLineNumsBuffer)
 
 					fileState.lineNumsRetryOrError(fmt.Errorf("listenStream - stream buffer tokens too high for file '%s'", filePath))
 					return
@@ -120,13 +118,13 @@ func (fileState *activeBuildStreamFileState) listenStreamChangesWithLineNums(str
 			err = json.Unmarshal([]byte(fileState.activeBuild.WithLineNumsBuffer), &streamed)
 
 			if err == nil {
-				log.Printf("listenStream - File %s: Parsed streamed replacements\n", filePath)
+				fileState.log.Debugf("listenStream - File %s: Parsed streamed replacements", filePath)
 				// spew.Dump(streamed)
 
 				fileState.onBuildResult(streamed)
 				return
 			} else if len(delta.ToolCalls) == 0 {
-				log.Println("listenStream - Stream chunk missing function call.")
+				fileState.log.Debug("listenStream - Stream chunk missing function call.")
 				// log.Println(spew.Sdump(response))
 				// log.Println(spew.Sdump(fileState))
 
@@ -3,7 +3,6 @@ package plan
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"plandex-server/db"
 	"plandex-server/model"
 	"plandex-server/model/prompts"
@@ -24,11 +23,11 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		fileState.log.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 		return
 	}
 
-	log.Printf("verifyFileBuild - Verifying file %s\n", filePath)
+	fileState.log.Infof("verifyFileBuild - Verifying file %s", filePath)
 
 	// log.Println("File context:", fileContext)
 
@@ -40,14 +39,14 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 	verifyState, err := fileState.GetVerifyState()
 
 	if err != nil {
-		log.Printf("Error getting verify state for file '%s': %v\n", filePath, err)
+		fileState.log.Errorf("Error getting verify state for file '%s': %v", filePath, err)
 		fileState.onBuildFileError(fmt.Errorf("error getting verify state for file '%s': %v", filePath, err))
 		return
 	}
 
 	if verifyState == nil {
-		log.Printf("verifyFileBuild - Verify state not found for file '%s'\n", filePath)
-		log.Println("finishing file build")
+		fileState.log.Warnf("verifyFileBuild - Verify state not found for file '%s'", filePath)
+		fileState.log.Info("finishing file build")
 		fileState.onFinishBuildFile(nil, "")
 		return
 	}
@@ -57,13 +56,13 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 		diff, err = db.GetDiffsForBuild(verifyState.preBuildFileState, updated)
 
 		if err != nil {
-			log.Printf("Error getting diffs for file '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error getting diffs for file '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error getting diffs for file '%s': %v", filePath, err))
 			return
 		}
 	}
 
-	log.Println("verifyFileBuild - got diff for file: " + filePath)
+	fileState.log.Info("verifyFileBuild - got diff for file: " + filePath)
 
 	sysPrompt := prompts.GetVerifyPrompt(
 		verifyState.preBuildFileState,
@@ -81,7 +80,7 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 		},
 	}
 
-	log.Println("verifyFileBuild - Calling verify model for file: " + filePath)
+	fileState.log.Info("verifyFileBuild - Calling verify model for file: " + filePath)
 
 	// for _, msg := range fileMessages {
 	// 	log.Printf("%s: %s\n", msg.Role, msg.Content)
@@ -116,9 +115,9 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 	client := clients[envVar]
 
 	if config.BaseModelConfig.HasStreamingFunctionCalls {
-		stream, err := model.CreateChatCompletionStreamWithRetries(client, activePlan.Ctx, modelReq)
+		stream, err := model.CreateChatCompletionStreamWithRetries(client, shared.ModelRoleVerifier, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 		if err != nil {
-			log.Printf("Error creating plan file stream for path '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error creating plan file stream for path '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error creating plan file stream for path '%s': %v", filePath, err))
 			return
 		}
@@ -135,10 +134,10 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 			BuildInfo: buildInfo,
 		})
 
-		resp, err := model.CreateChatCompletionWithRetries(client, activePlan.Ctx, modelReq)
+		resp, err := model.CreateChatCompletionWithRetries(client, shared.ModelRoleVerifier, modelCallCtx(fileState.traceCtx(activePlan.Ctx), activePlan, filePath), modelReq)
 
 		if err != nil {
-			log.Printf("Error verifying file '%s': %v\n", filePath, err)
+			fileState.log.Errorf("Error verifying file '%s': %v", filePath, err)
 			fileState.onBuildFileError(fmt.Errorf("error verifying file '%s': %v", filePath, err))
 			return
 		}
@@ -156,7 +155,7 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 		}
 
 		if s == "" {
-			log.Println("no VerifyOutput function call found in response")
+			fileState.log.Info("no VerifyOutput function call found in response")
 			fileState.verifyRetryOrAbort(fmt.Errorf("no Verify Output function call found in response"))
 			return
 		}
@@ -165,7 +164,7 @@ func (fileState *activeBuildStreamFileState) verifyFileBuild() {
 
 		err = json.Unmarshal(bytes, &res)
 		if err != nil {
-			log.Printf("Error unmarshalling verify response: %v\n", err)
+			fileState.log.Errorf("Error unmarshalling verify response: %v", err)
 			fileState.verifyRetryOrAbort(fmt.Errorf("error unmarshalling verify response: %v", err))
 			return
 		}
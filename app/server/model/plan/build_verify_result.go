@@ -1,8 +1,8 @@
 package plan
 
 import (
-	"log"
 	"math/rand"
+	"plandex-server/db"
 	"plandex-server/types"
 	"time"
 
@@ -18,7 +18,7 @@ func (fileState *activeBuildStreamFileState) onVerifyResult(res types.VerifyResu
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStreamVerifyOutput - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStreamVerifyOutput - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -32,24 +32,31 @@ func (fileState *activeBuildStreamFileState) onVerifyResult(res types.VerifyResu
 			Type:      shared.StreamMessageBuildInfo,
 			BuildInfo: buildInfo,
 		})
-		log.Println("build verify - res.IsCorrect")
+		fileState.log.Info("build verify - res.IsCorrect")
 
 		time.Sleep(50 * time.Millisecond)
 
 		fileState.onFinishBuildFile(nil, "")
 	} else {
 
-		log.Printf("listenStreamVerifyOutput - File %s: Streamed verify result is incorrect\n", filePath)
+		fileState.log.Infof("listenStreamVerifyOutput - File %s: Streamed verify result is incorrect", filePath)
 
 		fileState.verificationErrors = res.GetReasoning()
 		fileState.isFixingOther = true
 
+		// the plan goes on to fix the file, but teams may want to know how often builds need it
+		go db.QueuePlanWebhookEvent(planId, branch, shared.WebhookPayload{
+			Event:  shared.WebhookEventBuildVerifyFailed,
+			Path:   filePath,
+			Reason: fileState.verificationErrors,
+		})
+
 		// log.Println("Verification errors:")
 		// log.Println(fileState.verificationErrors)
 
 		select {
 		case <-activePlan.Ctx.Done():
-			log.Println("listenStreamVerifyOutput - Context canceled. Exiting.")
+			fileState.log.Info("listenStreamVerifyOutput - Context canceled. Exiting.")
 			return
 		case <-time.After(time.Duration(rand.Intn(1001)) * time.Millisecond):
 			break
@@ -64,14 +71,14 @@ func (fileState *activeBuildStreamFileState) verifyRetryOrAbort(err error) {
 		fileState.verifyFileNumRetry++
 		fileState.activeBuild.VerifyBuffer = ""
 		fileState.activeBuild.VerifyBufferTokens = 0
-		log.Printf("Retrying verify file '%s' due to error: %v\n", fileState.filePath, err)
+		fileState.log.Errorf("Retrying verify file '%s' due to error: %v", fileState.filePath, err)
 
 		// Exponential backoff
 		time.Sleep(time.Duration((fileState.verifyFileNumRetry*fileState.verifyFileNumRetry)/2)*200*time.Millisecond + time.Duration(rand.Intn(500))*time.Millisecond)
 
 		fileState.verifyFileBuild()
 	} else {
-		log.Printf("Aborting verify file '%s' due to error: %v\n", fileState.filePath, err)
+		fileState.log.Errorf("Aborting verify file '%s' due to error: %v", fileState.filePath, err)
 
 		fileState.onFinishBuildFile(nil, "")
 	}
@@ -2,7 +2,6 @@ package plan
 
 import (
 	"fmt"
-	"log"
 	"plandex-server/db"
 	"plandex-server/types"
 	"time"
@@ -21,7 +20,7 @@ func (fileState *activeBuildStreamFileState) GetVerifyState() (*verifyState, err
 	fileResults := planState.PlanResult.FileResultsByPath[path]
 
 	if fileResults == nil {
-		log.Println("No file results for path: ", path)
+		fileState.log.Infof("No file results for path: %v", path)
 		return nil, nil
 	}
 
@@ -107,12 +106,12 @@ func (fileState *activeBuildStreamFileState) MarkLatestResultVerified(passed boo
 			Branch:      branch,
 			PlanBuildId: build.Id,
 			Scope:       db.LockScopeWrite,
-			Ctx:         activePlan.Ctx,
+			Ctx:         fileState.traceCtx(activePlan.Ctx),
 			CancelFn:    activePlan.CancelFn,
 		},
 	)
 	if err != nil {
-		log.Printf("Error locking repo for build file: %v\n", err)
+		fileState.log.Errorf("Error locking repo for build file: %v", err)
 		return fmt.Errorf("error locking repo for build file: %v", err)
 	}
 
@@ -120,16 +119,16 @@ func (fileState *activeBuildStreamFileState) MarkLatestResultVerified(passed boo
 		var err error
 		defer func() {
 			if err != nil {
-				log.Printf("Error: %v\n", err)
+				fileState.log.Errorf("Error: %v", err)
 				err = db.GitClearUncommittedChanges(currentOrgId, planId)
 				if err != nil {
-					log.Printf("Error clearing uncommitted changes: %v\n", err)
+					fileState.log.Errorf("Error clearing uncommitted changes: %v", err)
 				}
 			}
 
 			err := db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo: %v\n", err)
+				fileState.log.Errorf("Error unlocking repo: %v", err)
 			}
 		}()
 
@@ -150,7 +149,7 @@ func (fileState *activeBuildStreamFileState) MarkLatestResultVerified(passed boo
 
 		err = db.StorePlanResult(latestPlanRes)
 		if err != nil {
-			log.Printf("Error storing plan result: %v\n", err)
+			fileState.log.Errorf("Error storing plan result: %v", err)
 			return fmt.Errorf("error storing plan result: %v", err)
 		}
 		return nil
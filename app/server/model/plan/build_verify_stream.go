@@ -4,17 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"plandex-server/model"
 	"plandex-server/types"
 	"strings"
 	"time"
 
 	"github.com/plandex/plandex/shared"
-	"github.com/sashabaranov/go-openai"
 )
 
-func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *openai.ChatCompletionStream) {
+func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *model.ChatCompletionStream) {
 
 	filePath := fileState.filePath
 	planId := fileState.plan.Id
@@ -23,7 +21,7 @@ func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *op
 	activePlan := GetActivePlan(planId, branch)
 
 	if activePlan == nil {
-		log.Printf("listenStreamVerifyOutput - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		fileState.log.Warnf("listenStreamVerifyOutput - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -52,10 +50,10 @@ func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *op
 				}
 				timer.Reset(model.OPENAI_STREAM_CHUNK_TIMEOUT)
 			} else {
-				log.Printf("listenStreamVerifyOutput - File %s: Error receiving stream chunk: %v\n", filePath, err)
+				fileState.log.Errorf("listenStreamVerifyOutput - File %s: Error receiving stream chunk: %v", filePath, err)
 
 				if err == context.Canceled {
-					log.Printf("listenStreamVerifyOutput - File %s: Stream canceled\n", filePath)
+					fileState.log.Infof("listenStreamVerifyOutput - File %s: Stream canceled", filePath)
 					return
 				}
 
@@ -77,7 +75,7 @@ func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *op
 
 				trimmed := strings.TrimSpace(content)
 				if trimmed == "{%invalidjson%}" || trimmed == "``(no output)``````" {
-					log.Println("File", filePath+":", "%invalidjson%} token in streamed chunk")
+					fileState.log.Warnf("File %s: %%invalidjson%%} token in streamed chunk", filePath)
 					fileState.verifyRetryOrAbort(fmt.Errorf("invalid JSON in streamed chunk for file '%s'", filePath))
 					return
 				}
@@ -102,13 +100,13 @@ func (fileState *activeBuildStreamFileState) listenStreamVerifyOutput(stream *op
 			err = json.Unmarshal([]byte(fileState.activeBuild.VerifyBuffer), &streamed)
 
 			if err == nil {
-				log.Printf("listenStreamVerifyOutput - File %s: Parsed streamed verify result\n", filePath)
+				fileState.log.Debugf("listenStreamVerifyOutput - File %s: Parsed streamed verify result", filePath)
 				// spew.Dump(streamed)
 
 				fileState.onVerifyResult(streamed)
 				return
 			} else if len(delta.ToolCalls) == 0 {
-				log.Println("listenStreamVerifyOutput - Stream chunk missing function call.")
+				fileState.log.Debug("listenStreamVerifyOutput - Stream chunk missing function call.")
 				// log.Println(spew.Sdump(response))
 
 				fileState.verifyRetryOrAbort(fmt.Errorf("listenStreamVerifyOutput - stream chunk missing function call. Reason: %s, File: %s", choice.FinishReason, filePath))
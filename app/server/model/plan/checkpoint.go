@@ -0,0 +1,109 @@
+package plan
+
+import (
+	"net/http"
+	"plandex-server/db"
+	"plandex-server/types"
+	"reflect"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+const checkpointInterval = 2 * time.Second
+
+// checkpointActivePlan saves the plan's progress to its model stream while the plan is active, so the plan can be finished cleanly if this server goes away before it's done
+func checkpointActivePlan(active *types.ActivePlan) {
+	var last *db.ModelStreamCheckpoint
+
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-active.Ctx.Done():
+			return
+		case <-ticker.C:
+			last = saveCheckpoint(active, last)
+		}
+	}
+}
+
+// saveCheckpoint saves the plan's checkpoint if it has changed since last, returning the latest one
+func saveCheckpoint(active *types.ActivePlan, last *db.ModelStreamCheckpoint) *db.ModelStreamCheckpoint {
+	var checkpoint *db.ModelStreamCheckpoint
+	// take the snapshot under the same lock that guards updates to the plan
+	UpdateActivePlan(active.Id, active.Branch, func(ap *types.ActivePlan) {
+		checkpoint = ap.Checkpoint()
+	})
+
+	if checkpoint == nil || reflect.DeepEqual(checkpoint, last) {
+		return last
+	}
+
+	err := db.SetModelStreamCheckpoint(active.ModelStreamId, checkpoint)
+	if err != nil {
+		active.Log().Errorf("Error saving checkpoint for plan %s: %v", active.Id, err)
+		return last
+	}
+
+	return checkpoint
+}
+
+const interruptReason = "the server it was running on shut down"
+
+// how long an interrupted plan's goroutines get to release its repo locks once cancelled
+const interruptLockWait = 3 * time.Second
+
+// InterruptActivePlans finishes every plan still running on this server from a final checkpoint, for when the server is shutting down and can't wait any longer for them. Plans are cancelled before their checkpoints are restored so nothing is still writing to their repos during the restore.
+func InterruptActivePlans() {
+	var interrupted []*types.ActivePlan
+
+	for _, key := range activePlans.Keys() {
+		active := activePlans.Get(key)
+		if active == nil || active.ModelStreamId == "" {
+			continue
+		}
+
+		active.Log().Info("Interrupting plan")
+
+		checkpoint := saveCheckpoint(active, nil)
+
+		// the stream stops with the plan's context, so clients have to be told before it's cancelled
+		active.Stream(shared.StreamMessage{
+			Type: shared.StreamMessageError,
+			Error: &shared.ApiError{
+				Type:   shared.ApiErrorTypeOther,
+				Status: http.StatusServiceUnavailable,
+				Msg:    db.InterruptedPlanError(interruptReason, checkpoint),
+			},
+		})
+		active.FlushStreamBuffer()
+
+		interrupted = append(interrupted, active)
+	}
+
+	if len(interrupted) == 0 {
+		return
+	}
+
+	// give clients a moment to receive the error
+	time.Sleep(500 * time.Millisecond)
+
+	for _, active := range interrupted {
+		active.CancelFn()
+		active.SummaryCancelFn()
+	}
+
+	for _, active := range interrupted {
+		err := db.WaitForRepoUnlocked(active.Id, active.Branch, interruptLockWait)
+		if err != nil {
+			active.Log().Warnf("Restoring interrupted plan's checkpoint without waiting for its repo locks: %v", err)
+		}
+
+		_, err = db.FinishInterruptedModelStream(active.ModelStreamId, interruptReason)
+		if err != nil {
+			active.Log().Errorf("Error finishing interrupted plan: %v", err)
+		}
+	}
+}
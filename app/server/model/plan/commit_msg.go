@@ -12,7 +12,7 @@ import (
 	"github.com/sashabaranov/go-openai"
 )
 
-func genPlanDescription(client *openai.Client, config shared.ModelRoleConfig, planId, branch string, ctx context.Context) (*db.ConvoMessageDescription, error) {
+func genPlanDescription(client *model.Client, config shared.ModelRoleConfig, planId, branch string, ctx context.Context) (*db.ConvoMessageDescription, error) {
 	activePlan := GetActivePlan(planId, branch)
 	if activePlan == nil {
 		return nil, fmt.Errorf("active plan not found")
@@ -25,6 +25,7 @@ func genPlanDescription(client *openai.Client, config shared.ModelRoleConfig, pl
 
 	descResp, err := model.CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleCommitMsg,
 		ctx,
 		openai.ChatCompletionRequest{
 			Model: config.BaseModelConfig.ModelName,
@@ -92,7 +93,7 @@ func genPlanDescription(client *openai.Client, config shared.ModelRoleConfig, pl
 	}, nil
 }
 
-func GenCommitMsgForPendingResults(client *openai.Client, config shared.ModelRoleConfig, current *shared.CurrentPlanState, ctx context.Context) (string, error) {
+func GenCommitMsgForPendingResults(client *model.Client, config shared.ModelRoleConfig, current *shared.CurrentPlanState, ctx context.Context) (string, error) {
 	s := ""
 
 	num := 0
@@ -120,6 +121,7 @@ func GenCommitMsgForPendingResults(client *openai.Client, config shared.ModelRol
 
 	resp, err := model.CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleCommitMsg,
 		ctx,
 		openai.ChatCompletionRequest{
 			Model:       config.BaseModelConfig.ModelName,
@@ -0,0 +1,87 @@
+package plan
+
+import (
+	"fmt"
+	"plandex-server/db"
+	"plandex-server/types"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// GetConnectActiveMessages builds the messages that catch a newly connected client up on an active plan: the prompt and replies so far, then the state of any builds
+func GetConnectActiveMessages(planId, branch string) ([]shared.StreamMessage, error) {
+	active := GetActivePlan(planId, branch)
+
+	if active == nil {
+		return nil, fmt.Errorf("active plan not found for plan ID %s on branch %s", planId, branch)
+	}
+
+	return connectActiveMessages(active)
+}
+
+// connectActiveMessages is GetConnectActiveMessages for a plan that's already been looked up. It doesn't touch the active plans map, so it's safe to call while holding the plan's own locks.
+func connectActiveMessages(active *types.ActivePlan) ([]shared.StreamMessage, error) {
+	msg := shared.StreamMessage{
+		Type: shared.StreamMessageConnectActive,
+	}
+
+	if active.Prompt != "" && !active.BuildOnly {
+		msg.InitPrompt = active.Prompt
+	}
+
+	if active.BuildOnly {
+		msg.InitBuildOnly = true
+	}
+
+	if len(active.StoredReplyIds) > 0 {
+		convo, err := db.GetPlanConvo(active.OrgId, active.Id)
+		if err != nil {
+			return nil, fmt.Errorf("error getting plan convo: %v", err)
+		}
+
+		convoMsgById := map[string]*db.ConvoMessage{}
+		for _, convoMsg := range convo {
+			convoMsgById[convoMsg.Id] = convoMsg
+		}
+
+		for _, replyId := range active.StoredReplyIds {
+			if convoMsg, ok := convoMsgById[replyId]; ok {
+				msg.InitReplies = append(msg.InitReplies, convoMsg.Message)
+			}
+		}
+	}
+
+	if active.CurrentReplyContent != "" {
+		msg.InitReplies = append(msg.InitReplies, active.CurrentReplyContent)
+	}
+
+	if active.MissingFilePath != "" {
+		msg.MissingFilePath = active.MissingFilePath
+	}
+
+	msgs := []shared.StreamMessage{msg}
+
+	// if we're connecting to an active stream and there are active builds, send initial build info
+	for path, queue := range active.BuildQueuesByPath {
+		buildInfo := shared.BuildInfo{Path: path}
+
+		for _, build := range queue {
+			if build.BuildFinished() {
+				buildInfo.NumTokens = 0
+				buildInfo.Finished = true
+			} else {
+				tokens := build.WithLineNumsBufferTokens
+
+				buildInfo.Finished = false
+				buildInfo.NumTokens += tokens
+			}
+		}
+
+		msgs = append(msgs, shared.StreamMessage{
+			Type:      shared.StreamMessageBuildInfo,
+			BuildInfo: &buildInfo,
+		})
+	}
+
+	return msgs, nil
+}
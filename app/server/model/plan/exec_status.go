@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"plandex-server/db"
 	"plandex-server/model"
 	"plandex-server/model/prompts"
 	"strings"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -22,7 +22,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 	envVar := config.BaseModelConfig.ApiKeyEnvVar
 	client := clients[envVar]
 
-	log.Println("Checking if plan should continue based on exec status")
+	state.log.Info("Checking if plan should continue based on exec status")
 
 	// First try to determine if the plan should continue based on the last paragraph without calling the model
 	paragraphs := strings.Split(message, "\n\n")
@@ -34,7 +34,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 	if lastParagraphLower != "" {
 		nextIdx := strings.Index(lastParagraph, "Next, ")
 		if nextIdx >= 0 {
-			log.Println("Plan can be continued based on last paragraph")
+			state.log.Info("Plan can be continued based on last paragraph")
 			return true, lastParagraph, nil
 		}
 	}
@@ -53,13 +53,13 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 	var latestSummary *db.ConvoSummary
 
 	if latestSummaryCh != nil {
-		log.Println("Waiting for latest summary")
+		state.log.Info("Waiting for latest summary")
 		select {
 		case <-ctx.Done():
-			log.Println("Context cancelled while waiting for latest summary")
+			state.log.Info("Context cancelled while waiting for latest summary")
 			return false, "", fmt.Errorf("context cancelled while waiting for latest summary")
 		case latestSummary = <-latestSummaryCh:
-			log.Println("Got latest summary")
+			state.log.Info("Got latest summary")
 		}
 	}
 
@@ -75,9 +75,9 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 		},
 	}
 
-	log.Println("Calling model to check if plan should continue")
-	log.Println("Has latest summary:", summary != "")
-	log.Println("Has prev assistant msg:", prevAssistantMsg != "")
+	state.log.Info("Calling model to check if plan should continue")
+	state.log.Infof("Has latest summary: %v", summary != "")
+	state.log.Infof("Has prev assistant msg: %v", prevAssistantMsg != "")
 
 	// log.Println("messages:")
 	// log.Println(spew.Sdump(messages))
@@ -89,6 +89,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 
 	resp, err := model.CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRoleExecStatus,
 		ctx,
 		openai.ChatCompletionRequest{
 			Model: config.BaseModelConfig.ModelName,
@@ -112,7 +113,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 	)
 
 	if err != nil {
-		log.Printf("Error during plan exec status check model call: %v\n", err)
+		state.log.Errorf("Error during plan exec status check model call: %v", err)
 		// return false, fmt.Errorf("error during plan exec status check model call: %v", err)
 
 		// Instead of erroring out, just don't continue the plan
@@ -140,8 +141,8 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 	}
 
 	if strRes == "" {
-		log.Println("No shouldAutoContinue function call found in response")
-		log.Println(spew.Sdump(resp))
+		state.log.Info("No shouldAutoContinue function call found in response")
+		state.log.Debug(spew.Sdump(resp))
 
 		// return false, fmt.Errorf("no shouldAutoContinue function call found in response")
 
@@ -151,7 +152,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 
 	err = json.Unmarshal([]byte(strRes), &res)
 	if err != nil {
-		log.Printf("Error unmarshalling plan exec status response: %v\n", err)
+		state.log.Errorf("Error unmarshalling plan exec status response: %v", err)
 
 		// return false, fmt.Errorf("error unmarshalling plan exec status response: %v", err)
 
@@ -159,7 +160,7 @@ func (state *activeTellStreamState) execStatusShouldContinue(message string, lat
 		return false, "", nil
 	}
 
-	log.Printf("Plan exec status response: %v\n", res)
+	state.log.Infof("Plan exec status response: %v", res)
 
 	return res.ShouldContinue, res.Reasoning, nil
 }
@@ -0,0 +1,249 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/types"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plandex/plandex/shared"
+)
+
+const planCommandTimeout = 10 * time.Second
+
+var ErrPlanCommandTimeout = errors.New("no response from the server running the plan")
+
+// SendPlanCommand sends a command to the instance running a plan and waits for it to be handled. It returns ErrPlanCommandTimeout if no instance answers.
+func SendPlanCommand(ctx context.Context, planId, branch string, cmd *types.PlanCommand) error {
+	cmd.Id = uuid.New().String()
+
+	results, unsubscribe, err := db.SubscribeEvents(types.PlanResultChannel(planId, branch))
+	if err != nil {
+		return fmt.Errorf("error subscribing to plan results: %v", err)
+	}
+	defer unsubscribe()
+
+	bytes, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("error marshalling plan command: %v", err)
+	}
+
+	err = db.PublishEvent(types.PlanCommandChannel(planId, branch), bytes)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.After(planCommandTimeout)
+	for {
+		select {
+		case <-timeout:
+			return ErrPlanCommandTimeout
+		case payload := <-results:
+			var result types.PlanCommandResult
+			err := json.Unmarshal(payload, &result)
+			if err != nil {
+				logging.Ctx(ctx).Errorf("Error unmarshalling plan command result: %v", err)
+				continue
+			}
+
+			if result.Id != cmd.Id {
+				continue
+			}
+
+			if result.Error != "" {
+				return errors.New(result.Error)
+			}
+
+			return nil
+		}
+	}
+}
+
+// listenPlanCommands handles commands sent by other instances for as long as the plan is active
+func listenPlanCommands(active *types.ActivePlan) error {
+	commands, unsubscribe, err := db.SubscribeEvents(types.PlanCommandChannel(active.Id, active.Branch))
+	if err != nil {
+		return fmt.Errorf("error subscribing to plan commands: %v", err)
+	}
+
+	go func() {
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-active.Ctx.Done():
+				if active.NumRemoteSubscribers() > 0 {
+					err := active.PublishStreamEvent(types.PlanStreamEvent{Done: true})
+					if err != nil {
+						active.Log().Errorf("Error publishing stream done event: %v", err)
+					}
+				}
+				return
+			case payload := <-commands:
+				var cmd types.PlanCommand
+				err := json.Unmarshal(payload, &cmd)
+				if err != nil {
+					active.Log().Errorf("Error unmarshalling plan command: %v", err)
+					continue
+				}
+
+				// stop and missing file responses can block for a while, so don't hold up other commands
+				go handlePlanCommand(active, &cmd)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func handlePlanCommand(active *types.ActivePlan, cmd *types.PlanCommand) {
+	active.Log().Infof("Handling %s command for plan %s", cmd.Type, active.Id)
+
+	var err error
+
+	switch cmd.Type {
+	case types.PlanCommandConnect:
+		if cmd.Resume {
+			var resumed bool
+			resumed, err = active.ResumeRemote(cmd.SubscriberId, cmd.LastSeq)
+			if err != nil || resumed {
+				break
+			}
+		}
+
+		err = active.ConnectRemote(cmd.SubscriberId, func() ([]string, error) {
+			msgs, err := connectActiveMessages(active)
+			if err != nil {
+				return nil, err
+			}
+
+			var res []string
+			for _, msg := range msgs {
+				bytes, err := json.Marshal(msg)
+				if err != nil {
+					return nil, fmt.Errorf("error marshalling message: %v", err)
+				}
+				res = append(res, string(bytes))
+			}
+			return res, nil
+		})
+
+	case types.PlanCommandDisconnect:
+		active.RemoveRemoteSubscriber(cmd.SubscriberId)
+		// no one waits on a disconnect
+		return
+
+	case types.PlanCommandStop:
+		err = StopActivePlan(active.Id, active.Branch, cmd.UserId, cmd.OrgId)
+
+	case types.PlanCommandRespondMissingFile:
+		err = RespondMissingFile(active.Id, active.Branch, cmd.MissingFileChoice, cmd.MissingFileContext)
+
+	default:
+		err = fmt.Errorf("unknown plan command: %s", cmd.Type)
+	}
+
+	result := types.PlanCommandResult{Id: cmd.Id}
+	if err != nil {
+		active.Log().Errorf("Error handling %s command for plan %s: %v", cmd.Type, active.Id, err)
+		result.Error = err.Error()
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		active.Log().Errorf("Error marshalling plan command result: %v", err)
+		return
+	}
+
+	err = db.PublishEvent(types.PlanResultChannel(active.Id, active.Branch), bytes)
+	if err != nil {
+		active.Log().Errorf("Error publishing plan command result: %v", err)
+	}
+}
+
+// StopActivePlan aborts a plan running on this instance, storing whatever it has replied so far
+func StopActivePlan(planId, branch, currentUserId, currentOrgId string) error {
+	active := GetActivePlan(planId, branch)
+
+	if active == nil {
+		return fmt.Errorf("no active plan with id %s", planId)
+	}
+
+	logger := active.Log()
+
+	logger.Info("Sending stream aborted message to client")
+
+	active.Stream(shared.StreamMessage{
+		Type: shared.StreamMessageAborted,
+	})
+
+	// give some time for stream message to be processed before canceling
+	logger.Info("Sleeping for 100ms before canceling")
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+
+	repoLockId, err := db.LockRepo(
+		db.LockRepoParams{
+			UserId:   currentUserId,
+			OrgId:    currentOrgId,
+			PlanId:   planId,
+			Branch:   branch,
+			Scope:    db.LockScopeWrite,
+			Ctx:      ctx,
+			CancelFn: cancelFn,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("error locking repo: %v", err)
+	}
+
+	logger.Info("Stopping plan")
+	err = StorePartialReply(planId, branch, currentUserId, currentOrgId)
+
+	if err != nil {
+		rollbackErr := db.GitClearUncommittedChanges(currentOrgId, planId)
+		if rollbackErr != nil {
+			logger.Errorf("Error rolling back repo: %v", rollbackErr)
+		}
+	}
+
+	unlockErr := db.DeleteRepoLock(repoLockId)
+	if unlockErr != nil {
+		logger.Errorf("Error unlocking repo: %v", unlockErr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error storing partial reply: %v", err)
+	}
+
+	return Stop(planId, branch, currentUserId, currentOrgId)
+}
+
+// RespondMissingFile resumes a plan on this instance that's waiting on a file the model asked for. dbContext is the file, already loaded, if the choice was to load it.
+func RespondMissingFile(planId, branch string, choice shared.RespondMissingFileChoice, dbContext *db.Context) error {
+	active := GetActivePlan(planId, branch)
+
+	if active == nil {
+		return fmt.Errorf("no active plan with id %s", planId)
+	}
+
+	if dbContext != nil {
+		UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
+			activePlan.Contexts = append(activePlan.Contexts, dbContext)
+			activePlan.ContextsByPath[dbContext.FilePath] = dbContext
+		})
+	}
+
+	// This will resume model stream
+	active.Log().Info("Resuming model stream")
+	active.MissingFileResponseCh <- choice
+
+	return nil
+}
@@ -2,13 +2,18 @@ package plan
 
 import (
 	"context"
-	"log"
+	"errors"
 	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/metrics"
+	"plandex-server/model"
+	"plandex-server/tracing"
 	"plandex-server/types"
 	"strings"
 	"time"
 
 	"github.com/plandex/plandex/shared"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -19,60 +24,90 @@ func GetActivePlan(planId, branch string) *types.ActivePlan {
 	return activePlans.Get(strings.Join([]string{planId, branch}, "|"))
 }
 
-func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool) *types.ActivePlan {
-	activePlan := types.NewActivePlan(orgId, userId, planId, branch, prompt, buildOnly)
+// CreateActivePlan starts a span that covers the plan until it finishes, continuing the trace from ctx. Work done for the plan is traced under it.
+func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool, ctx context.Context) *types.ActivePlan {
+	traceCtx, span := tracing.Start(tracing.Detach(ctx), "plan",
+		append(tracing.PlanAttrs(planId, branch), attribute.Bool("plandex.plan.build_only", buildOnly))...,
+	)
+
+	// the plan's log lines keep the id of the request that started it, so they can be found from the request's
+	logArgs := []any{"org_id", orgId, "user_id", userId, "plan_id", planId, "branch", branch}
+	if requestId := logging.RequestId(ctx); requestId != "" {
+		logArgs = append(logArgs, logging.RequestIdKey, requestId)
+	}
+
+	activePlan := types.NewActivePlan(orgId, userId, planId, branch, prompt, buildOnly, logging.With(traceCtx, logArgs...))
+	logger := activePlan.Log()
+
 	key := strings.Join([]string{planId, branch}, "|")
 
 	activePlans.Set(key, activePlan)
+	metrics.ActivePlans.Inc()
+	startedAt := time.Now()
+
+	// other instances reach this plan through commands on the event bus
+	err := listenPlanCommands(activePlan)
+	if err != nil {
+		logger.Errorf("Error listening for commands for plan %s: %v", planId, err)
+	}
 
 	go func() {
 		for {
 			select {
 			case <-activePlan.Ctx.Done():
-				log.Printf("case <-activePlan.Ctx.Done(): %s\n", planId)
+				logger.Infof("case <-activePlan.Ctx.Done(): %s", planId)
 
 				err := db.SetPlanStatus(planId, branch, shared.PlanStatusStopped, "")
 				if err != nil {
-					log.Printf("Error setting plan %s status to stopped: %v\n", planId, err)
+					logger.Errorf("Error setting plan %s status to stopped: %v", planId, err)
 				}
 
-				DeleteActivePlan(orgId, userId, planId, branch)
+				recordModelStreamEnd("stopped", startedAt)
+				span.SetAttributes(attribute.String("plandex.plan.outcome", "stopped"))
+				span.End()
+				DeleteActivePlan(activePlan)
 
 				return
 			case apiErr := <-activePlan.StreamDoneCh:
-				log.Printf("case apiErr := <-activePlan.StreamDoneCh: %s\n", planId)
-				log.Printf("apiErr: %v\n", apiErr)
+				logger.Infof("case apiErr := <-activePlan.StreamDoneCh: %s", planId)
+				logger.Debugf("apiErr: %v", apiErr)
 
 				if apiErr == nil {
-					log.Printf("Plan %s stream completed successfully", planId)
+					logger.Infof("Plan %s stream completed successfully", planId)
+					recordModelStreamEnd("finished", startedAt)
+					span.SetAttributes(attribute.String("plandex.plan.outcome", "finished"))
+					span.End()
 
 					err := db.SetPlanStatus(planId, branch, shared.PlanStatusFinished, "")
 					if err != nil {
-						log.Printf("Error setting plan %s status to ready: %v\n", planId, err)
+						logger.Errorf("Error setting plan %s status to ready: %v", planId, err)
 					}
 
 				} else {
-					log.Printf("Error streaming plan %s: %v\n", planId, apiErr)
+					logger.Errorf("Error streaming plan %s: %v", planId, apiErr)
+					recordModelStreamEnd("error", startedAt)
+					span.SetAttributes(attribute.String("plandex.plan.outcome", "error"))
+					tracing.End(span, errors.New(apiErr.Msg))
 
 					err := db.SetPlanStatus(planId, branch, shared.PlanStatusError, apiErr.Msg)
 					if err != nil {
-						log.Printf("Error setting plan %s status to error: %v\n", planId, err)
+						logger.Errorf("Error setting plan %s status to error: %v", planId, err)
 					}
 
-					log.Println("Sending error message to client")
+					logger.Error("Sending error message to client")
 					activePlan.Stream(shared.StreamMessage{
 						Type:  shared.StreamMessageError,
 						Error: apiErr,
 					})
 
-					log.Println("Stopping any active summary stream")
+					logger.Info("Stopping any active summary stream")
 					activePlan.SummaryCancelFn()
 
 					time.Sleep(50 * time.Millisecond)
 				}
 
 				activePlan.CancelFn()
-				DeleteActivePlan(orgId, userId, planId, branch)
+				DeleteActivePlan(activePlan)
 				return
 			}
 		}
@@ -81,15 +116,17 @@ func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bo
 	return activePlan
 }
 
-func DeleteActivePlan(orgId, userId, planId, branch string) {
+func DeleteActivePlan(active *types.ActivePlan) {
+	logger := active.Log()
+
 	ctx, cancelFn := context.WithCancel(context.Background())
 
 	repoLockId, err := db.LockRepo(
 		db.LockRepoParams{
-			UserId:   userId,
-			OrgId:    orgId,
-			PlanId:   planId,
-			Branch:   branch,
+			UserId:   active.UserId,
+			OrgId:    active.OrgId,
+			PlanId:   active.Id,
+			Branch:   active.Branch,
 			Scope:    db.LockScopeWrite,
 			Ctx:      ctx,
 			CancelFn: cancelFn,
@@ -97,23 +134,29 @@ func DeleteActivePlan(orgId, userId, planId, branch string) {
 	)
 
 	if err != nil {
-		log.Printf("Error locking repo for plan %s: %v\n", planId, err)
+		logger.Errorf("Error locking repo for plan %s: %v", active.Id, err)
 	} else {
 
 		defer func() {
 			err := db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo for plan %s: %v\n", planId, err)
+				logger.Errorf("Error unlocking repo for plan %s: %v", active.Id, err)
 			}
 		}()
 
-		err := db.GitClearUncommittedChanges(orgId, planId)
+		err := db.GitClearUncommittedChanges(active.OrgId, active.Id)
 		if err != nil {
-			log.Printf("Error clearing uncommitted changes for plan %s: %v\n", planId, err)
+			logger.Errorf("Error clearing uncommitted changes for plan %s: %v", active.Id, err)
 		}
 	}
 
-	activePlans.Delete(strings.Join([]string{planId, branch}, "|"))
+	activePlans.Delete(strings.Join([]string{active.Id, active.Branch}, "|"))
+	metrics.ActivePlans.Dec()
+}
+
+func recordModelStreamEnd(outcome string, startedAt time.Time) {
+	metrics.ModelStreams.WithLabelValues(outcome).Inc()
+	metrics.ModelStreamDuration.WithLabelValues(outcome).Observe(time.Since(startedAt).Seconds())
 }
 
 func UpdateActivePlan(planId, branch string, fn func(*types.ActivePlan)) {
@@ -121,31 +164,67 @@ func UpdateActivePlan(planId, branch string, fn func(*types.ActivePlan)) {
 }
 
 func SubscribePlan(planId, branch string) (string, chan string) {
-	log.Printf("Subscribing to plan %s\n", planId)
 	var id string
 	var ch chan string
 	UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
+		activePlan.Log().Infof("Subscribing to plan %s", planId)
 		id, ch = activePlan.Subscribe()
 	})
 	return id, ch
 }
 
-func UnsubscribePlan(planId, branch, subscriptionId string) {
-	log.Printf("UnsubscribePlan %s - %s - %s\n", planId, branch, subscriptionId)
+// ResumePlan subscribes a reconnecting client, replaying what it missed after lastSeq. It returns false if the plan no longer has those messages.
+func ResumePlan(planId, branch string, lastSeq int64) (string, chan string, bool) {
+	var id string
+	var ch chan string
+	var ok bool
+	UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
+		activePlan.Log().Infof("Resuming plan %s after message %d", planId, lastSeq)
+		id, ch, ok = activePlan.Resume(lastSeq)
+	})
+	return id, ch, ok
+}
 
+func UnsubscribePlan(planId, branch, subscriptionId string) {
 	active := GetActivePlan(planId, branch)
 
 	if active == nil {
-		log.Printf("No active plan found for plan ID %s on branch %s\n", planId, branch)
+		// the plan already finished, taking its subscriptions with it
 		return
 	}
 
 	UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
 		activePlan.Unsubscribe(subscriptionId)
-		log.Printf("Unsubscribed from plan %s - %s - %s\n", planId, branch, subscriptionId)
+		activePlan.Log().Infof("Unsubscribed %s from plan %s", subscriptionId, planId)
 	})
 }
 
 func NumActivePlans() int {
 	return activePlans.Len()
 }
+
+// gitAddAndCommit commits the plan's changes in a span under ctx's, so commits show up in the trace of whatever made them
+func gitAddAndCommit(ctx context.Context, orgId, planId, branch, message string) error {
+	_, span := tracing.Start(ctx, "git.commit", tracing.PlanAttrs(planId, branch)...)
+	err := db.GitAddAndCommit(orgId, planId, branch, message)
+	tracing.End(span, err)
+	return err
+}
+
+// modelCallCtx scopes a plan's model calls to its org and plan for the model call scheduler, streaming the call's place in line to the client while it waits. path is the file being built, or empty for the reply.
+func modelCallCtx(ctx context.Context, active *types.ActivePlan, path string) context.Context {
+	return model.WithCallScope(ctx, model.CallScope{
+		OrgId:  active.OrgId,
+		PlanId: active.Id,
+		Branch: active.Branch,
+		OnQueued: func(position int) {
+			active.Stream(shared.StreamMessage{
+				Type: shared.StreamMessageQueued,
+				QueueInfo: &shared.QueueInfo{
+					Path:     path,
+					Position: position,
+				},
+			})
+		},
+	})
+}
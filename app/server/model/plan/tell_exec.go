@@ -1,29 +1,37 @@
 package plan
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/model"
 	"plandex-server/model/lib"
 	"plandex-server/model/prompts"
+	"plandex-server/tracing"
 	"plandex-server/types"
 
 	"github.com/google/uuid"
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func Tell(clients map[string]*openai.Client, plan *db.Plan, branch string, auth *types.ServerAuth, req *shared.TellPlanRequest) error {
-	log.Printf("Tell: Called with plan ID %s on branch %s\n", plan.Id, branch)
+// ctx is the request's context. The plan's trace continues from it, but the plan isn't cancelled when the request finishes.
+func Tell(clients map[string]*model.Client, plan *db.Plan, branch string, auth *types.ServerAuth, req *shared.TellPlanRequest, ctx context.Context) error {
+	logger := logging.Ctx(ctx)
 
-	_, err := activatePlan(clients, plan, branch, auth, req.Prompt, false)
+	logger.Infof("Tell: Called with plan ID %s on branch %s", plan.Id, branch)
+
+	_, err := activatePlan(clients, plan, branch, auth, req.Prompt, false, ctx)
 
 	if err != nil {
-		log.Printf("Error activating plan: %v\n", err)
+		logger.Errorf("Error activating plan: %v", err)
 		return err
 	}
 
@@ -40,12 +48,12 @@ func Tell(clients map[string]*openai.Client, plan *db.Plan, branch string, auth
 		0,
 	)
 
-	log.Printf("Tell: Tell operation completed successfully for plan ID %s on branch %s\n", plan.Id, branch)
+	logger.Infof("Tell: Tell operation completed successfully for plan ID %s on branch %s", plan.Id, branch)
 	return nil
 }
 
 func execTellPlan(
-	clients map[string]*openai.Client,
+	clients map[string]*model.Client,
 	plan *db.Plan,
 	branch string,
 	auth *types.ServerAuth,
@@ -67,9 +75,24 @@ func execTellPlan(
 		return
 	}
 
+	logger := active.Log()
+
+	_, span := tracing.Start(active.Ctx, "tell.iteration",
+		attribute.Int("plandex.tell.iteration", iteration),
+		attribute.String("plandex.tell.missing_file_response", string(missingFileResponse)),
+		attribute.Int("plandex.tell.error_retry", numErrorRetry),
+	)
+	// once the reply is streaming, listenStream ends the span
+	listening := false
+	defer func() {
+		if !listening {
+			span.End()
+		}
+	}()
+
 	if os.Getenv("IS_CLOUD") != "" &&
 		missingFileResponse == "" {
-		log.Println("execTellPlan: IS_CLOUD environment variable is set")
+		logger.Info("execTellPlan: IS_CLOUD environment variable is set")
 		if auth.User.IsTrial {
 			if plan.TotalReplies >= types.TrialMaxReplies {
 				active.StreamDoneCh <- &shared.ApiError{
@@ -88,14 +111,14 @@ func execTellPlan(
 	planId := plan.Id
 	err := db.SetPlanStatus(planId, branch, shared.PlanStatusReplying, "")
 	if err != nil {
-		log.Printf("Error setting plan %s status to replying: %v\n", planId, err)
+		logger.Errorf("Error setting plan %s status to replying: %v", planId, err)
 		active.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
 			Status: http.StatusInternalServerError,
 			Msg:    "Error setting plan status to replying",
 		}
 
-		log.Printf("execTellPlan: execTellPlan operation completed for plan ID %s on branch %s, iteration %d\n", plan.Id, branch, iteration)
+		logger.Infof("execTellPlan: execTellPlan operation completed for plan ID %s on branch %s, iteration %d", plan.Id, branch, iteration)
 		return
 	}
 
@@ -110,6 +133,8 @@ func execTellPlan(
 		iteration:              iteration,
 		missingFileResponse:    missingFileResponse,
 		currentReplyNumRetries: numErrorRetry,
+		span:                   span,
+		log:                    logger,
 	}
 
 	err = state.loadTellPlan()
@@ -155,7 +180,7 @@ func execTellPlan(
 	modelContextText, modelContextTokens, err := lib.FormatModelContext(state.modelContext)
 	if err != nil {
 		err = fmt.Errorf("error formatting model modelContext: %v", err)
-		log.Println(err)
+		logger.Errorf("%v", err)
 
 		active.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
@@ -187,7 +212,7 @@ func execTellPlan(
 		if context.ContextType == shared.ContextImageType {
 			if !state.settings.ModelPack.Planner.BaseModelConfig.HasImageSupport {
 				err = fmt.Errorf("%s does not support images in context", state.settings.ModelPack.Planner.BaseModelConfig.ModelName)
-				log.Println(err)
+				logger.Errorf("%v", err)
 				active.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
 					Status: http.StatusBadRequest,
@@ -224,7 +249,7 @@ func execTellPlan(
 		numPromptTokens, err = shared.GetNumTokens(req.Prompt)
 		if err != nil {
 			err = fmt.Errorf("error getting number of tokens in prompt: %v", err)
-			log.Println(err)
+			logger.Errorf("%v", err)
 			active.StreamDoneCh <- &shared.ApiError{
 				Type:   shared.ApiErrorTypeOther,
 				Status: http.StatusInternalServerError,
@@ -238,16 +263,16 @@ func execTellPlan(
 	state.tokensBeforeConvo = prompts.CreateSysMsgNumTokens + modelContextTokens + state.latestSummaryTokens + promptTokens
 
 	// print out breakdown of token usage
-	log.Printf("System message tokens: %d\n", prompts.CreateSysMsgNumTokens)
-	log.Printf("Context tokens: %d\n", modelContextTokens)
-	log.Printf("Prompt tokens: %d\n", promptTokens)
-	log.Printf("Latest summary tokens: %d\n", state.latestSummaryTokens)
-	log.Printf("Total tokens before convo: %d\n", state.tokensBeforeConvo)
+	logger.Infof("System message tokens: %d", prompts.CreateSysMsgNumTokens)
+	logger.Infof("Context tokens: %d", modelContextTokens)
+	logger.Infof("Prompt tokens: %d", promptTokens)
+	logger.Infof("Latest summary tokens: %d", state.latestSummaryTokens)
+	logger.Infof("Total tokens before convo: %d", state.tokensBeforeConvo)
 
 	if state.tokensBeforeConvo > state.settings.GetPlannerEffectiveMaxTokens() {
 		// token limit already exceeded before adding conversation
 		err := fmt.Errorf("token limit exceeded before adding conversation")
-		log.Printf("Error: %v\n", err)
+		logger.Errorf("Error: %v", err)
 		active.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
 			Status: http.StatusInternalServerError,
@@ -278,12 +303,12 @@ func execTellPlan(
 			// if the user is continuing the plan, we need to check whether the previous message was a user message or assistant message
 			lastMessage := state.messages[len(state.messages)-1]
 
-			log.Println("User is continuing plan. Last message:\n\n", lastMessage.Content)
+			logger.Infof("User is continuing plan. Last message:\n\n %v", lastMessage.Content)
 
 			if lastMessage.Role == openai.ChatMessageRoleUser {
 				// if last message was a user message, we want to remove it from the messages array and then use that last message as the prompt so we can continue from where the user left off
 
-				log.Println("User is continuing plan. Last message was user message. Using last user message as prompt")
+				logger.Info("User is continuing plan. Last message was user message. Using last user message as prompt")
 
 				state.messages = state.messages[:len(state.messages)-1]
 				promptMessage = &openai.ChatCompletionMessage{
@@ -295,7 +320,7 @@ func execTellPlan(
 			} else {
 
 				// if the last message was an assistant message, we'll use the user continue prompt
-				log.Println("User is continuing plan. Last message was assistant message. Using user continue prompt")
+				logger.Info("User is continuing plan. Last message was assistant message. Using user continue prompt")
 
 				// otherwise we'll use the continue prompt
 				promptMessage = &openai.ChatCompletionMessage{
@@ -334,13 +359,13 @@ func execTellPlan(
 		state.promptMessage = promptMessage
 		state.messages = append(state.messages, *promptMessage)
 	} else {
-		log.Println("Missing file response:", missingFileResponse, "setting replyParser")
+		logger.Infof("Missing file response: %v setting replyParser", missingFileResponse)
 
 		state.replyParser.AddChunk(active.CurrentReplyContent, true)
 		res := state.replyParser.Read()
 		currentFile := res.CurrentFilePath
 
-		log.Printf("Current file: %s\n", currentFile)
+		logger.Infof("Current file: %s", currentFile)
 		// log.Println("Current reply content:\n", active.CurrentReplyContent)
 
 		replyContent := active.CurrentReplyContent
@@ -350,7 +375,7 @@ func execTellPlan(
 			replyBeforeCurrentFile := state.replyParser.GetReplyBeforeCurrentPath()
 			numTokens, err = shared.GetNumTokens(replyBeforeCurrentFile)
 			if err != nil {
-				log.Printf("Error getting num tokens for reply before current file: %v\n", err)
+				logger.Errorf("Error getting num tokens for reply before current file: %v", err)
 				active.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
 					Status: http.StatusInternalServerError,
@@ -398,7 +423,7 @@ func execTellPlan(
 		}
 	}
 
-	log.Printf("\n\nMessages: %d\n", len(state.messages))
+	logger.Infof("Messages: %d", len(state.messages))
 	// for _, message := range state.messages {
 	// 	log.Printf("%s: %s\n", message.Role, message.Content)
 	// }
@@ -414,9 +439,9 @@ func execTellPlan(
 	envVar := state.settings.ModelPack.Planner.BaseModelConfig.ApiKeyEnvVar
 	client := clients[envVar]
 
-	stream, err := model.CreateChatCompletionStreamWithRetries(client, active.ModelStreamCtx, modelReq)
+	stream, err := model.CreateChatCompletionStreamWithRetries(client, shared.ModelRolePlanner, modelCallCtx(trace.ContextWithSpan(active.ModelStreamCtx, span), active, ""), modelReq)
 	if err != nil {
-		log.Printf("Error starting reply stream: %v\n", err)
+		logger.Errorf("Error starting reply stream: %v", err)
 
 		active.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
@@ -431,7 +456,7 @@ func execTellPlan(
 			pendingBuildsByPath, err := active.PendingBuildsByPath(auth.OrgId, auth.User.Id, state.convo)
 
 			if err != nil {
-				log.Printf("Error getting pending builds by path: %v\n", err)
+				logger.Errorf("Error getting pending builds by path: %v", err)
 				active.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
 					Status: http.StatusInternalServerError,
@@ -441,11 +466,11 @@ func execTellPlan(
 			}
 
 			if len(pendingBuildsByPath) == 0 {
-				log.Println("Tell plan: no pending builds")
+				logger.Info("Tell plan: no pending builds")
 				return
 			}
 
-			log.Printf("Tell plan: found %d pending builds\n", len(pendingBuildsByPath))
+			logger.Infof("Tell plan: found %d pending builds", len(pendingBuildsByPath))
 			// spew.Dump(pendingBuildsByPath)x
 
 			buildState := &activeBuildStreamState{
@@ -457,6 +482,7 @@ func execTellPlan(
 				branch:        branch,
 				settings:      state.settings,
 				modelContext:  state.modelContext,
+				log:           logger,
 			}
 
 			for _, pendingBuilds := range pendingBuildsByPath {
@@ -470,5 +496,6 @@ func execTellPlan(
 		ap.CurrentReplyDoneCh = make(chan bool, 1)
 	})
 
+	listening = true
 	go state.listenStream(stream)
 }
@@ -2,7 +2,6 @@ package plan
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/model"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (state *activeTellStreamState) loadTellPlan() error {
@@ -41,13 +41,13 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			PlanId:   planId,
 			Branch:   branch,
 			Scope:    lockScope,
-			Ctx:      active.Ctx,
+			Ctx:      trace.ContextWithSpan(active.Ctx, state.span),
 			CancelFn: active.CancelFn,
 		},
 	)
 
 	if err != nil {
-		log.Printf("execTellPlan: Error locking repo for plan ID %s on branch %s: %v\n", plan.Id, branch, err)
+		state.log.Errorf("execTellPlan: Error locking repo for plan ID %s on branch %s: %v", plan.Id, branch, err)
 		active.StreamDoneCh <- &shared.ApiError{
 			Type:   shared.ApiErrorTypeOther,
 			Status: http.StatusInternalServerError,
@@ -67,7 +67,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 	go func() {
 		res, err := db.GetPlanSettings(plan, true)
 		if err != nil {
-			log.Printf("Error getting plan settings: %v\n", err)
+			state.log.Errorf("Error getting plan settings: %v", err)
 			errCh <- fmt.Errorf("error getting plan settings: %v", err)
 			return
 		}
@@ -80,14 +80,14 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			name, err := model.GenPlanName(client, settings.ModelPack.Namer, req.Prompt)
 
 			if err != nil {
-				log.Printf("Error generating plan name: %v\n", err)
+				state.log.Errorf("Error generating plan name: %v", err)
 				errCh <- fmt.Errorf("error generating plan name: %v", err)
 				return
 			}
 
 			tx, err := db.Conn.Beginx()
 			if err != nil {
-				log.Printf("Error starting transaction: %v\n", err)
+				state.log.Errorf("Error starting transaction: %v", err)
 				errCh <- fmt.Errorf("error starting transaction: %v", err)
 			}
 
@@ -95,9 +95,9 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			defer func() {
 				if err != nil {
 					if rbErr := tx.Rollback(); rbErr != nil {
-						log.Printf("transaction rollback error: %v\n", rbErr)
+						state.log.Errorf("transaction rollback error: %v", rbErr)
 					} else {
-						log.Println("transaction rolled back")
+						state.log.Info("transaction rolled back")
 					}
 				}
 			}()
@@ -105,7 +105,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			err = db.RenamePlan(planId, name, tx)
 
 			if err != nil {
-				log.Printf("Error renaming plan: %v\n", err)
+				state.log.Errorf("Error renaming plan: %v", err)
 				errCh <- fmt.Errorf("error renaming plan: %v", err)
 				return
 			}
@@ -113,14 +113,14 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			err = db.IncNumNonDraftPlans(currentUserId, tx)
 
 			if err != nil {
-				log.Printf("Error incrementing num non draft plans: %v\n", err)
+				state.log.Errorf("Error incrementing num non draft plans: %v", err)
 				errCh <- fmt.Errorf("error incrementing num non draft plans: %v", err)
 				return
 			}
 
 			err = tx.Commit()
 			if err != nil {
-				log.Printf("Error committing transaction: %v\n", err)
+				state.log.Errorf("Error committing transaction: %v", err)
 				errCh <- fmt.Errorf("error committing transaction: %v", err)
 				return
 			}
@@ -135,7 +135,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 		} else {
 			res, err := db.GetPlanContexts(currentOrgId, planId, true)
 			if err != nil {
-				log.Printf("Error getting plan modelContext: %v\n", err)
+				state.log.Errorf("Error getting plan modelContext: %v", err)
 				errCh <- fmt.Errorf("error getting plan modelContext: %v", err)
 				return
 			}
@@ -147,7 +147,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 	go func() {
 		res, err := db.GetPlanConvo(currentOrgId, planId)
 		if err != nil {
-			log.Printf("Error getting plan convo: %v\n", err)
+			state.log.Errorf("Error getting plan convo: %v", err)
 			errCh <- fmt.Errorf("error getting plan convo: %v", err)
 			return
 		}
@@ -158,7 +158,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 
 		promptTokens, err := shared.GetNumTokens(req.Prompt)
 		if err != nil {
-			log.Printf("Error getting prompt num tokens: %v\n", err)
+			state.log.Errorf("Error getting prompt num tokens: %v", err)
 			errCh <- fmt.Errorf("error getting prompt num tokens: %v", err)
 			return
 		}
@@ -170,7 +170,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 			if iteration == 0 && missingFileResponse == "" && !req.IsUserContinue {
 				num := len(convo) + 1
 
-				log.Printf("storing user message | len(convo): %d | num: %d\n", len(convo), num)
+				state.log.Infof("storing user message | len(convo): %d | num: %d", len(convo), num)
 
 				userMsg = &db.ConvoMessage{
 					OrgId:   currentOrgId,
@@ -185,7 +185,7 @@ func (state *activeTellStreamState) loadTellPlan() error {
 				_, err = db.StoreConvoMessage(userMsg, auth.User.Id, branch, true)
 
 				if err != nil {
-					log.Printf("Error storing user message: %v\n", err)
+					state.log.Errorf("Error storing user message: %v", err)
 					innerErrCh <- fmt.Errorf("error storing user message: %v", err)
 					return
 				}
@@ -205,24 +205,24 @@ func (state *activeTellStreamState) loadTellPlan() error {
 				convoMessageIds = append(convoMessageIds, convoMessage.Id)
 			}
 
-			log.Println("getting plan summaries")
-			log.Println("convoMessageIds:", convoMessageIds)
+			state.log.Info("getting plan summaries")
+			state.log.Infof("convoMessageIds: %v", convoMessageIds)
 
 			res, err := db.GetPlanSummaries(planId, convoMessageIds)
 			if err != nil {
-				log.Printf("Error getting plan summaries: %v\n", err)
+				state.log.Errorf("Error getting plan summaries: %v", err)
 				innerErrCh <- fmt.Errorf("error getting plan summaries: %v", err)
 				return
 			}
 			summaries = res
 
-			log.Printf("got %d plan summaries", len(summaries))
+			state.log.Infof("got %d plan summaries", len(summaries))
 
 			if len(summaries) > 0 {
 				var err error
 				latestSummaryTokens, err = shared.GetNumTokens(summaries[len(summaries)-1].Summary)
 				if err != nil {
-					log.Printf("Error getting latest summary tokens: %v\n", err)
+					state.log.Errorf("Error getting latest summary tokens: %v", err)
 					innerErrCh <- fmt.Errorf("error getting latest summary tokens: %v", err)
 					return
 				}
@@ -250,16 +250,16 @@ func (state *activeTellStreamState) loadTellPlan() error {
 		var err error
 		defer func() {
 			if err != nil {
-				log.Printf("Error: %v\n", err)
+				state.log.Errorf("Error: %v", err)
 				err = db.GitClearUncommittedChanges(auth.OrgId, planId)
 				if err != nil {
-					log.Printf("Error clearing uncommitted changes: %v\n", err)
+					state.log.Errorf("Error clearing uncommitted changes: %v", err)
 				}
 			}
 
 			err = db.DeleteRepoLock(repoLockId)
 			if err != nil {
-				log.Printf("Error unlocking repo: %v\n", err)
+				state.log.Errorf("Error unlocking repo: %v", err)
 				active.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
 					Status: http.StatusInternalServerError,
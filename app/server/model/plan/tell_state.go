@@ -2,14 +2,17 @@ package plan
 
 import (
 	"plandex-server/db"
+	"plandex-server/logging"
+	"plandex-server/model"
 	"plandex-server/types"
 
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type activeTellStreamState struct {
-	clients                map[string]*openai.Client
+	clients                map[string]*model.Client
 	req                    *shared.TellPlanRequest
 	auth                   *types.ServerAuth
 	currentOrgId           string
@@ -32,4 +35,8 @@ type activeTellStreamState struct {
 	tokensBeforeConvo      int
 	settings               *shared.PlanSettings
 	currentReplyNumRetries int
+	log                    *logging.Logger
+
+	// covers this iteration from loading the plan until the reply is stored
+	span trace.Span
 }
@@ -3,24 +3,30 @@ package plan
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
 	"plandex-server/model"
+	"plandex-server/tracing"
 	"plandex-server/types"
 	"strings"
 	"time"
 
 	"github.com/plandex/plandex/shared"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const MaxAutoContinueIterations = 100
 const MaxSendRate = 30 * time.Millisecond
 const MaxTellStreamRetries = 4
 
-func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionStream) {
+func (state *activeTellStreamState) listenStream(stream *model.ChatCompletionStream) {
 	defer stream.Close()
+	defer func() {
+		state.span.SetAttributes(attribute.Int("plandex.tell.reply_tokens", state.replyNumTokens))
+		state.span.End()
+	}()
 
 	clients := state.clients
 	auth := state.auth
@@ -42,7 +48,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 	active := GetActivePlan(planId, branch)
 
 	if active == nil {
-		log.Printf("listenStream - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		state.log.Warnf("listenStream - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
@@ -58,11 +64,11 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 		select {
 		case <-active.Ctx.Done():
 			// The main modelContext was canceled (not the timer)
-			log.Println("\nTell: stream canceled")
+			state.log.Info("Tell: stream canceled")
 			return
 		case <-timer.C:
 			// Timer triggered because no new chunk was received in time
-			log.Println("\nTell: stream timeout due to inactivity")
+			state.log.Info("Tell: stream timeout due to inactivity")
 			state.onError(fmt.Errorf("stream timeout due to inactivity"), true, "", "")
 			return
 		default:
@@ -78,7 +84,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 
 			if err != nil {
 				if err.Error() == "context canceled" {
-					log.Println("Tell: stream context canceled")
+					state.log.Debug("Tell: stream context canceled")
 					return
 				}
 
@@ -97,7 +103,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 			choice := response.Choices[0]
 
 			if choice.FinishReason != "" {
-				log.Println("Model stream finished")
+				state.log.Info("Model stream finished")
 				active.FlushStreamBuffer()
 				time.Sleep(100 * time.Millisecond)
 
@@ -121,7 +127,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 
 				go func() {
 					if len(replyFiles) > 0 {
-						log.Println("Generating plan description")
+						state.log.Debug("Generating plan description")
 
 						envVar := settings.ModelPack.CommitMsg.BaseModelConfig.ApiKeyEnvVar
 						client := clients[envVar]
@@ -142,14 +148,14 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 				}()
 
 				go func() {
-					log.Println("Getting exec status")
+					state.log.Debug("Getting exec status")
 					shouldContinue, nextTask, err = state.execStatusShouldContinue(active.CurrentReplyContent, latestSummaryCh, active.Ctx)
 					if err != nil {
 						errCh <- fmt.Errorf("failed to get exec status: %v", err)
 						return
 					}
 
-					log.Printf("Should continue: %v\n", shouldContinue)
+					state.log.Debugf("Should continue: %v", shouldContinue)
 
 					errCh <- nil
 				}()
@@ -162,7 +168,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 					}
 				}
 
-				log.Println("Locking repo to store assistant reply and description")
+				state.log.Debug("Locking repo to store assistant reply and description")
 
 				repoLockId, err := db.LockRepo(
 					db.LockRepoParams{
@@ -171,13 +177,13 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 						PlanId:   planId,
 						Branch:   branch,
 						Scope:    db.LockScopeWrite,
-						Ctx:      active.Ctx,
+						Ctx:      trace.ContextWithSpan(active.Ctx, state.span),
 						CancelFn: active.CancelFn,
 					},
 				)
 
 				if err != nil {
-					log.Printf("Error locking repo: %v\n", err)
+					state.log.Errorf("Error locking repo: %v", err)
 					active.StreamDoneCh <- &shared.ApiError{
 						Type:   shared.ApiErrorTypeOther,
 						Status: http.StatusInternalServerError,
@@ -186,23 +192,23 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 					return
 				}
 
-				log.Println("Locked repo for assistant reply and description")
+				state.log.Debug("Locked repo for assistant reply and description")
 
 				err = func() error {
 					defer func() {
 						if err != nil {
-							log.Printf("Error storing reply and description: %v\n", err)
+							state.log.Errorf("Error storing reply and description: %v", err)
 							err = db.GitClearUncommittedChanges(auth.OrgId, planId)
 							if err != nil {
-								log.Printf("Error clearing uncommitted changes: %v\n", err)
+								state.log.Errorf("Error clearing uncommitted changes: %v", err)
 							}
 						}
 
-						log.Println("Unlocking repo for assistant reply and description")
+						state.log.Debug("Unlocking repo for assistant reply and description")
 
 						err = db.DeleteRepoLock(repoLockId)
 						if err != nil {
-							log.Printf("Error unlocking repo: %v\n", err)
+							state.log.Errorf("Error unlocking repo: %v", err)
 							active.StreamDoneCh <- &shared.ApiError{
 								Type:   shared.ApiErrorTypeOther,
 								Status: http.StatusInternalServerError,
@@ -219,7 +225,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 						return err
 					}
 
-					log.Println("getting description for assistant message: ", assistantMsg.Id)
+					state.log.Debugf("getting description for assistant message: %v", assistantMsg.Id)
 
 					var description *db.ConvoMessageDescription
 					if len(replyFiles) == 0 {
@@ -236,24 +242,24 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 						description.ConvoMessageId = assistantMsg.Id
 					}
 
-					log.Println("Storing description")
+					state.log.Debug("Storing description")
 					err = db.StoreDescription(description)
 
 					if err != nil {
 						state.onError(fmt.Errorf("failed to store description: %v", err), false, assistantMsg.Id, convoCommitMsg)
 						return err
 					}
-					log.Println("Description stored")
+					state.log.Debug("Description stored")
 					// spew.Dump(description)
 
-					log.Println("Comitting reply message and description")
+					state.log.Debug("Comitting reply message and description")
 
-					err = db.GitAddAndCommit(currentOrgId, planId, branch, convoCommitMsg)
+					err = gitAddAndCommit(trace.ContextWithSpan(active.Ctx, state.span), currentOrgId, planId, branch, convoCommitMsg)
 					if err != nil {
 						state.onError(fmt.Errorf("failed to commit: %v", err), false, assistantMsg.Id, convoCommitMsg)
 						return err
 					}
-					log.Println("Assistant reply and description committed")
+					state.log.Debug("Assistant reply and description committed")
 
 					return nil
 				}()
@@ -263,7 +269,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 				}
 
 				// summarize convo needs to come *after* the reply is stored in order to correctly summarize the latest message
-				log.Println("summarize convo")
+				state.log.Debug("summarize convo")
 				envVar := settings.ModelPack.PlanSummary.BaseModelConfig.ApiKeyEnvVar
 				client := clients[envVar]
 
@@ -278,11 +284,11 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 					currentReply: active.CurrentReplyContent,
 				}, active.SummaryCtx)
 
-				log.Println("Sending active.CurrentReplyDoneCh <- true")
+				state.log.Debug("Sending active.CurrentReplyDoneCh <- true")
 
 				active.CurrentReplyDoneCh <- true
 
-				log.Println("Resetting active.CurrentReplyDoneCh")
+				state.log.Debug("Resetting active.CurrentReplyDoneCh")
 
 				UpdateActivePlan(planId, branch, func(ap *types.ActivePlan) {
 					ap.CurrentStreamingReplyId = ""
@@ -290,7 +296,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 				})
 
 				if req.AutoContinue && shouldContinue && iteration < MaxAutoContinueIterations {
-					log.Println("Auto continue plan")
+					state.log.Info("Auto continue plan")
 					// continue plan
 					execTellPlan(clients, plan, branch, auth, req, iteration+1, "", false, nextTask, 0)
 				} else {
@@ -300,21 +306,21 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 						ap.RepliesFinished = true
 					})
 
-					log.Printf("Won't continue plan. Build finished: %v\n", buildFinished)
+					state.log.Infof("Won't continue plan. Build finished: %v", buildFinished)
 
 					time.Sleep(50 * time.Millisecond)
 
 					if buildFinished {
-						log.Println("Plan is finished")
+						state.log.Info("Plan is finished")
 						active.Stream(shared.StreamMessage{
 							Type: shared.StreamMessageFinished,
 						})
 					} else {
-						log.Println("Plan is still building")
-						log.Println("Updating status to building")
+						state.log.Info("Plan is still building")
+						state.log.Debug("Updating status to building")
 						err := db.SetPlanStatus(planId, branch, shared.PlanStatusBuilding, "")
 						if err != nil {
-							log.Printf("Error setting plan status to building: %v\n", err)
+							state.log.Errorf("Error setting plan status to building: %v", err)
 							active.StreamDoneCh <- &shared.ApiError{
 								Type:   shared.ApiErrorTypeOther,
 								Status: http.StatusInternalServerError,
@@ -323,7 +329,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 							return
 						}
 
-						log.Println("Sending RepliesFinished stream message")
+						state.log.Debug("Sending RepliesFinished stream message")
 						active.Stream(shared.StreamMessage{
 							Type: shared.StreamMessageRepliesFinished,
 						})
@@ -382,14 +388,14 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 			if currentFile != "" &&
 				active.ContextsByPath[currentFile] == nil &&
 				req.ProjectPaths[currentFile] && !active.AllowOverwritePaths[currentFile] {
-				log.Printf("Attempting to overwrite a file that isn't in context: %s\n", currentFile)
+				state.log.Debugf("Attempting to overwrite a file that isn't in context: %s", currentFile)
 
 				// attempting to overwrite a file that isn't in context
 				// we will stop the stream and ask the user what to do
-				err := db.SetPlanStatus(planId, branch, shared.PlanStatusMissingFile, "")
+				err := db.SetPlanStatusMissingFile(planId, branch, currentFile)
 
 				if err != nil {
-					log.Printf("Error setting plan %s status to prompting: %v\n", planId, err)
+					state.log.Errorf("Error setting plan %s status to prompting: %v", planId, err)
 					active.StreamDoneCh <- &shared.ApiError{
 						Type:   shared.ApiErrorTypeOther,
 						Status: http.StatusInternalServerError,
@@ -402,32 +408,32 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 					ap.MissingFilePath = currentFile
 				})
 
-				log.Printf("Prompting user for missing file: %s\n", currentFile)
+				state.log.Infof("Prompting user for missing file: %s", currentFile)
 
 				active.Stream(shared.StreamMessage{
 					Type:            shared.StreamMessagePromptMissingFile,
 					MissingFilePath: currentFile,
 				})
 
-				log.Printf("Stopping stream for missing file: %s\n", currentFile)
+				state.log.Debugf("Stopping stream for missing file: %s", currentFile)
 
 				// log.Printf("Current reply content: %s\n", active.CurrentReplyContent)
 
 				// stop stream for now
 				active.CancelModelStreamFn()
 
-				log.Printf("Stopped stream for missing file: %s\n", currentFile)
+				state.log.Debugf("Stopped stream for missing file: %s", currentFile)
 
 				// wait for user response to come in
 				var userChoice shared.RespondMissingFileChoice
 				select {
 				case <-active.Ctx.Done():
-					log.Println("Context cancelled while waiting for missing file response")
+					state.log.Debug("Context cancelled while waiting for missing file response")
 					return
 				case userChoice = <-active.MissingFileResponseCh:
 				}
 
-				log.Printf("User choice for missing file: %s\n", userChoice)
+				state.log.Infof("User choice for missing file: %s", userChoice)
 
 				active.ResetModelCtx()
 
@@ -435,7 +441,7 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 					ap.MissingFilePath = ""
 				})
 
-				log.Println("Continuing stream")
+				state.log.Debug("Continuing stream")
 
 				// continue plan
 				execTellPlan(
@@ -462,16 +468,16 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 			// spew.Dump(replyFiles)
 
 			if len(files) > len(replyFiles) {
-				log.Printf("%d new files\n", len(files)-len(replyFiles))
+				state.log.Debugf("%d new files", len(files)-len(replyFiles))
 
 				for i, file := range files {
 					if i < len(replyFiles) {
 						continue
 					}
 
-					log.Printf("Detected file: %s\n", file)
+					state.log.Debugf("Detected file: %s", file)
 					if req.BuildMode == shared.BuildModeAuto {
-						log.Printf("Queuing build for %s\n", file)
+						state.log.Infof("Queuing build for %s", file)
 						buildState := &activeBuildStreamState{
 							clients:       clients,
 							auth:          auth,
@@ -481,12 +487,13 @@ func (state *activeTellStreamState) listenStream(stream *openai.ChatCompletionSt
 							branch:        branch,
 							settings:      settings,
 							modelContext:  state.modelContext,
+							log:           state.log,
 						}
 
 						fileContentTokens, err := shared.GetNumTokens(fileContents[i])
 
 						if err != nil {
-							log.Printf("Error getting num tokens for file %s: %v\n", file, err)
+							state.log.Errorf("Error getting num tokens for file %s: %v", file, err)
 							state.onError(fmt.Errorf("error getting num tokens for file %s: %v", file, err), true, "", "")
 							return
 						}
@@ -522,7 +529,7 @@ func (state *activeTellStreamState) storeAssistantReply() (*db.ConvoMessage, str
 
 	num := len(convo) + 1
 
-	log.Printf("storing assistant reply | len(convo) %d | num %d\n", len(convo), num)
+	state.log.Infof("storing assistant reply | len(convo) %d | num %d", len(convo), num)
 
 	activePlan := GetActivePlan(planId, branch)
 
@@ -544,7 +551,7 @@ func (state *activeTellStreamState) storeAssistantReply() (*db.ConvoMessage, str
 	commitMsg, err := db.StoreConvoMessage(&assistantMsg, auth.User.Id, branch, false)
 
 	if err != nil {
-		log.Printf("Error storing assistant message: %v\n", err)
+		state.log.Errorf("Error storing assistant message: %v", err)
 		return nil, "", err
 	}
 
@@ -560,7 +567,8 @@ func (state *activeTellStreamState) storeAssistantReply() (*db.ConvoMessage, str
 }
 
 func (state *activeTellStreamState) onError(streamErr error, storeDesc bool, convoMessageId, commitMsg string) {
-	log.Printf("\nStream error: %v\n", streamErr)
+	state.log.Errorf("Stream error: %v", streamErr)
+	tracing.SetError(state.span, streamErr)
 
 	planId := state.plan.Id
 	branch := state.branch
@@ -570,12 +578,12 @@ func (state *activeTellStreamState) onError(streamErr error, storeDesc bool, con
 	active := GetActivePlan(planId, branch)
 
 	if active == nil {
-		log.Printf("tellStream onError - Active plan not found for plan ID %s on branch %s\n", planId, branch)
+		state.log.Warnf("tellStream onError - Active plan not found for plan ID %s on branch %s", planId, branch)
 		return
 	}
 
 	storeDescAndReply := func() error {
-		ctx, cancelFn := context.WithCancel(context.Background())
+		ctx, cancelFn := context.WithCancel(trace.ContextWithSpan(context.Background(), state.span))
 
 		repoLockId, err := db.LockRepo(
 			db.LockRepoParams{
@@ -590,20 +598,20 @@ func (state *activeTellStreamState) onError(streamErr error, storeDesc bool, con
 		)
 
 		if err != nil {
-			log.Printf("Error locking repo for plan %s: %v\n", planId, err)
+			state.log.Errorf("Error locking repo for plan %s: %v", planId, err)
 			return err
 		} else {
 
 			defer func() {
 				err := db.DeleteRepoLock(repoLockId)
 				if err != nil {
-					log.Printf("Error unlocking repo for plan %s: %v\n", planId, err)
+					state.log.Errorf("Error unlocking repo for plan %s: %v", planId, err)
 				}
 			}()
 
 			err := db.GitClearUncommittedChanges(state.currentOrgId, planId)
 			if err != nil {
-				log.Printf("Error clearing uncommitted changes for plan %s: %v\n", planId, err)
+				state.log.Errorf("Error clearing uncommitted changes for plan %s: %v", planId, err)
 				return err
 			}
 		}
@@ -618,7 +626,7 @@ func (state *activeTellStreamState) onError(streamErr error, storeDesc bool, con
 				commitMsg = msg
 				storedMessage = true
 			} else {
-				log.Printf("Error storing assistant message after stream error: %v\n", err)
+				state.log.Errorf("Error storing assistant message after stream error: %v", err)
 				return err
 			}
 		}
@@ -636,15 +644,15 @@ func (state *activeTellStreamState) onError(streamErr error, storeDesc bool, con
 			if err == nil {
 				storedDesc = true
 			} else {
-				log.Printf("Error storing description after stream error: %v\n", err)
+				state.log.Errorf("Error storing description after stream error: %v", err)
 				return err
 			}
 		}
 
 		if storedMessage || storedDesc {
-			err := db.GitAddAndCommit(currentOrgId, planId, branch, commitMsg)
+			err := gitAddAndCommit(ctx, currentOrgId, planId, branch, commitMsg)
 			if err != nil {
-				log.Printf("Error committing after stream error: %v\n", err)
+				state.log.Errorf("Error committing after stream error: %v", err)
 				return err
 			}
 		}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
 	"plandex-server/model"
 	"plandex-server/model/prompts"
 	"time"
@@ -24,7 +24,7 @@ func (state *activeTellStreamState) injectSummariesAsNeeded() bool {
 	active := GetActivePlan(state.plan.Id, state.branch)
 
 	if active == nil {
-		log.Println("summarizeMessagesIfNeeded - Active plan not found")
+		state.log.Warn("summarizeMessagesIfNeeded - Active plan not found")
 		return false
 	}
 
@@ -37,24 +37,24 @@ func (state *activeTellStreamState) injectSummariesAsNeeded() bool {
 		// log.Printf("Timestamp: %s | Tokens: %d | Total: %d | conversationTokens\n", convoMessage.Timestamp, convoMessage.Tokens, conversationTokens)
 	}
 
-	log.Printf("Conversation tokens: %d\n", conversationTokens)
-	log.Printf("Max conversation tokens: %d\n", state.settings.GetPlannerMaxConvoTokens())
+	state.log.Infof("Conversation tokens: %d", conversationTokens)
+	state.log.Infof("Max conversation tokens: %d", state.settings.GetPlannerMaxConvoTokens())
 
 	// log.Println("Tokens up to timestamp:")
 	// spew.Dump(tokensUpToTimestamp)
 
-	log.Printf("Total tokens: %d\n", tokensBeforeConvo+conversationTokens)
-	log.Printf("Max tokens: %d\n", state.settings.GetPlannerEffectiveMaxTokens())
+	state.log.Infof("Total tokens: %d", tokensBeforeConvo+conversationTokens)
+	state.log.Infof("Max tokens: %d", state.settings.GetPlannerEffectiveMaxTokens())
 
 	var summary *db.ConvoSummary
 	if (tokensBeforeConvo+conversationTokens) > state.settings.GetPlannerEffectiveMaxTokens() ||
 		conversationTokens > state.settings.GetPlannerMaxConvoTokens() {
-		log.Println("Token limit exceeded. Attempting to reduce via conversation summary.")
+		state.log.Info("Token limit exceeded. Attempting to reduce via conversation summary.")
 
 		// log.Printf("(tokensBeforeConvo+conversationTokens) > state.settings.GetPlannerEffectiveMaxTokens(): %v\n", (tokensBeforeConvo+conversationTokens) > state.settings.GetPlannerEffectiveMaxTokens())
 		// log.Printf("conversationTokens > state.settings.GetPlannerMaxConvoTokens(): %v\n", conversationTokens > state.settings.GetPlannerMaxConvoTokens())
 
-		log.Printf("Num summaries: %d\n", len(summaries))
+		state.log.Infof("Num summaries: %d", len(summaries))
 
 		// token limit exceeded after adding conversation
 		// get summary for as much as the conversation as necessary to stay under the token limit
@@ -63,20 +63,20 @@ func (state *activeTellStreamState) injectSummariesAsNeeded() bool {
 
 			tokens, ok := tokensUpToTimestamp[timestamp]
 
-			log.Printf("Last message timestamp: %d | found: %v\n", timestamp, ok)
-			log.Printf("Tokens up to timestamp: %d\n", tokens)
+			state.log.Infof("Last message timestamp: %d | found: %v", timestamp, ok)
+			state.log.Infof("Tokens up to timestamp: %d", tokens)
 
 			if !ok {
 				err := fmt.Errorf("conversation summary timestamp not found in conversation")
-				log.Printf("Error: %v\n", err)
+				state.log.Errorf("Error: %v", err)
 
-				log.Println("timestamp:", timestamp)
+				state.log.Infof("timestamp: %v", timestamp)
 
 				// log.Println("Conversation summary:")
 				// spew.Dump(s)
 
-				log.Println("tokensUpToTimestamp:")
-				log.Println(spew.Sdump(tokensUpToTimestamp))
+				state.log.Info("tokensUpToTimestamp:")
+				state.log.Debug(spew.Sdump(tokensUpToTimestamp))
 
 				active.StreamDoneCh <- &shared.ApiError{
 					Type:   shared.ApiErrorTypeOther,
@@ -89,13 +89,13 @@ func (state *activeTellStreamState) injectSummariesAsNeeded() bool {
 			updatedConversationTokens := (conversationTokens - tokens) + s.Tokens
 			savedTokens := conversationTokens - updatedConversationTokens
 
-			log.Printf("Conversation summary tokens: %d\n", tokens)
-			log.Printf("Updated conversation tokens: %d\n", updatedConversationTokens)
-			log.Printf("Saved tokens: %d\n", savedTokens)
+			state.log.Infof("Conversation summary tokens: %d", tokens)
+			state.log.Infof("Updated conversation tokens: %d", updatedConversationTokens)
+			state.log.Infof("Saved tokens: %d", savedTokens)
 
 			if updatedConversationTokens <= state.settings.GetPlannerMaxConvoTokens() &&
 				(tokensBeforeConvo+updatedConversationTokens) <= state.settings.GetPlannerEffectiveMaxTokens() {
-				log.Printf("Summarizing up to %s | saving %d tokens\n", s.LatestConvoMessageCreatedAt.Format(time.RFC3339), savedTokens)
+				state.log.Infof("Summarizing up to %s | saving %d tokens", s.LatestConvoMessageCreatedAt.Format(time.RFC3339), savedTokens)
 				summary = s
 				break
 			}
@@ -103,7 +103,7 @@ func (state *activeTellStreamState) injectSummariesAsNeeded() bool {
 
 		if summary == nil {
 			err := errors.New("couldn't get under token limit with conversation summary")
-			log.Printf("Error: %v\n", err)
+			state.log.Errorf("Error: %v", err)
 			active.StreamDoneCh <- &shared.ApiError{
 				Type:   shared.ApiErrorTypeOther,
 				Status: http.StatusInternalServerError,
@@ -180,9 +180,11 @@ type summarizeConvoParams struct {
 	currentOrgId string
 }
 
-func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params summarizeConvoParams, ctx context.Context) error {
-	log.Printf("summarizeConvo: Called for plan ID %s on branch %s\n", params.planId, params.branch)
-	log.Printf("summarizeConvo: Starting summarizeConvo for planId: %s\n", params.planId)
+func summarizeConvo(client *model.Client, config shared.ModelRoleConfig, params summarizeConvoParams, ctx context.Context) error {
+	logger := logging.Ctx(ctx)
+
+	logger.Infof("summarizeConvo: Called for plan ID %s on branch %s", params.planId, params.branch)
+	logger.Infof("summarizeConvo: Starting summarizeConvo for planId: %s", params.planId)
 	planId := params.planId
 	branch := params.branch
 	convo := params.convo
@@ -193,11 +195,11 @@ func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params
 	active := GetActivePlan(planId, branch)
 
 	if active == nil {
-		log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+		logger.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 		return fmt.Errorf("active plan not found for plan ID %s and branch %s", planId, branch)
 	}
 
-	log.Println("Generating plan summary for planId:", planId)
+	logger.Infof("Generating plan summary for planId: %v", planId)
 
 	// log.Printf("planId: %s\n", planId)
 	// log.Printf("convo: ")
@@ -260,13 +262,13 @@ func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params
 		latestMessageSummarizedAt = latestConvoMessage.CreatedAt
 	}
 
-	log.Println("generating summary - latestMessageId:", latestMessageId)
-	log.Println("generating summary - latestMessageSummarizedAt:", latestMessageSummarizedAt)
+	logger.Infof("generating summary - latestMessageId: %v", latestMessageId)
+	logger.Infof("generating summary - latestMessageSummarizedAt: %v", latestMessageSummarizedAt)
 
 	if userPrompt != "" {
 		active := GetActivePlan(planId, branch)
 		if active == nil {
-			log.Printf("Active plan not found for plan ID %s and branch %s\n", planId, branch)
+			logger.Warnf("Active plan not found for plan ID %s and branch %s", planId, branch)
 			return fmt.Errorf("active plan not found for plan ID %s and branch %s", planId, branch)
 		}
 
@@ -293,7 +295,7 @@ func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params
 		})
 	}
 
-	log.Printf("Calling model for plan summary. Summarizing %d messages\n", len(summaryMessages))
+	logger.Infof("Calling model for plan summary. Summarizing %d messages", len(summaryMessages))
 
 	// log.Println("Generating summary - summary messages:")
 	// spew.Dump(summaryMessages)
@@ -311,11 +313,11 @@ func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params
 	}, ctx)
 
 	if err != nil {
-		log.Printf("summarizeConvo: Error generating plan summary for plan %s: %v\n", params.planId, err)
+		logger.Errorf("summarizeConvo: Error generating plan summary for plan %s: %v", params.planId, err)
 		return err
 	}
 
-	log.Printf("summarizeConvo: Summary generated and stored for plan %s\n", params.planId)
+	logger.Infof("summarizeConvo: Summary generated and stored for plan %s", params.planId)
 
 	// log.Println("Generated summary:")
 	// spew.Dump(summary)
@@ -323,7 +325,7 @@ func summarizeConvo(client *openai.Client, config shared.ModelRoleConfig, params
 	err = db.StoreSummary(summary)
 
 	if err != nil {
-		log.Printf("Error storing plan summary for plan %s: %v\n", planId, err)
+		logger.Errorf("Error storing plan summary for plan %s: %v", planId, err)
 		return err
 	}
 
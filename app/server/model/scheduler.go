@@ -0,0 +1,442 @@
+package model
+
+import (
+	"context"
+	"log"
+	"os"
+	"plandex-server/metrics"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Model calls go through a scheduler that caps how many run at once and how many tokens they send per minute, per API key, per provider and per org. Providers start failing requests (often with EOF errors) when a plan fires off builds for many files at once, so calls that would go over a limit wait in a queue instead. Each plan has its own queue and plans take turns, so one plan building dozens of files can't starve the others.
+
+// default concurrency per API key when MODEL_MAX_CONCURRENT_PER_API_KEY isn't set. Other limits are off by default.
+const defaultMaxConcurrentPerApiKey = 5
+
+const tokensPerMinuteWindow = time.Minute
+
+type limitKind string
+
+const (
+	limitKindApiKey   limitKind = "apiKey"
+	limitKindProvider limitKind = "provider"
+	limitKindOrg      limitKind = "org"
+)
+
+// ModelCallLimits are the limits for a single API key, provider or org. Zero means no limit.
+type ModelCallLimits struct {
+	MaxConcurrent   int
+	TokensPerMinute int
+}
+
+// CallScope is what the scheduler knows about a model call beyond its client: who it's for, and how to tell them it's waiting
+type CallScope struct {
+	OrgId  string
+	PlanId string
+	Branch string
+
+	// OnQueued is called with the call's position in the queue whenever it changes while the call waits, then with 0 once it starts. It isn't called for calls that start right away.
+	OnQueued func(position int)
+}
+
+type callScopeCtxKey struct{}
+
+// WithCallScope attaches a scope to model calls made with ctx
+func WithCallScope(ctx context.Context, scope CallScope) context.Context {
+	return context.WithValue(ctx, callScopeCtxKey{}, scope)
+}
+
+func callScopeFromCtx(ctx context.Context) CallScope {
+	scope, _ := ctx.Value(callScopeCtxKey{}).(CallScope)
+	return scope
+}
+
+type limitKey struct {
+	kind limitKind
+	id   string
+}
+
+type scheduledCall struct {
+	queueKey  string
+	limitKeys []limitKey
+	tokens    int
+	onQueued  func(position int)
+	position  int
+	started   bool
+	ready     chan struct{}
+	moved     chan struct{}
+}
+
+type tokenUsage struct {
+	at     time.Time
+	tokens int
+}
+
+type scheduler struct {
+	mu     sync.Mutex
+	limits map[limitKind]ModelCallLimits
+	now    func() time.Time
+
+	running map[limitKey]int
+	usage   map[limitKey][]tokenUsage
+
+	// waiting calls by plan, and the order plans take turns in
+	queues map[string][]*scheduledCall
+	order  []string
+
+	wakeTimer *time.Timer
+}
+
+var modelScheduler = newScheduler(loadModelCallLimits(), time.Now)
+
+func newScheduler(limits map[limitKind]ModelCallLimits, now func() time.Time) *scheduler {
+	return &scheduler{
+		limits:  limits,
+		now:     now,
+		running: map[limitKey]int{},
+		usage:   map[limitKey][]tokenUsage{},
+		queues:  map[string][]*scheduledCall{},
+	}
+}
+
+func loadModelCallLimits() map[limitKind]ModelCallLimits {
+	return map[limitKind]ModelCallLimits{
+		limitKindApiKey: {
+			MaxConcurrent:   getLimitEnv("MODEL_MAX_CONCURRENT_PER_API_KEY", defaultMaxConcurrentPerApiKey),
+			TokensPerMinute: getLimitEnv("MODEL_TOKENS_PER_MINUTE_PER_API_KEY", 0),
+		},
+		limitKindProvider: {
+			MaxConcurrent:   getLimitEnv("MODEL_MAX_CONCURRENT_PER_PROVIDER", 0),
+			TokensPerMinute: getLimitEnv("MODEL_TOKENS_PER_MINUTE_PER_PROVIDER", 0),
+		},
+		limitKindOrg: {
+			MaxConcurrent:   getLimitEnv("MODEL_MAX_CONCURRENT_PER_ORG", 0),
+			TokensPerMinute: getLimitEnv("MODEL_TOKENS_PER_MINUTE_PER_ORG", 0),
+		},
+	}
+}
+
+func getLimitEnv(name string, def int) int {
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s %q, using %d\n", name, s, def)
+		return def
+	}
+
+	return n
+}
+
+// scheduleModelCall waits until a call with client and req is within every limit that applies to it, returning a function to call when it's done
+func scheduleModelCall(client *Client, role shared.ModelRole, ctx context.Context, req openai.ChatCompletionRequest) (func(), error) {
+	scope := callScopeFromCtx(ctx)
+
+	call := &scheduledCall{
+		onQueued: scope.OnQueued,
+		ready:    make(chan struct{}),
+		moved:    make(chan struct{}, 1),
+	}
+
+	if scope.PlanId != "" {
+		call.queueKey = scope.PlanId + "|" + scope.Branch
+	}
+
+	call.limitKeys = []limitKey{
+		{kind: limitKindApiKey, id: client.KeyId},
+		{kind: limitKindProvider, id: client.Provider},
+	}
+	if scope.OrgId != "" {
+		call.limitKeys = append(call.limitKeys, limitKey{kind: limitKindOrg, id: scope.OrgId})
+	}
+
+	if modelScheduler.limitsTokens() {
+		call.tokens = estimateRequestTokens(req)
+	}
+
+	start := time.Now()
+	release, err := modelScheduler.acquire(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	metrics.ModelCallQueueWait.WithLabelValues(client.Provider, string(role)).Observe(time.Since(start).Seconds())
+
+	return release, nil
+}
+
+func (s *scheduler) limitsTokens() bool {
+	for _, limits := range s.limits {
+		if limits.TokensPerMinute > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scheduler) acquire(ctx context.Context, call *scheduledCall) (func(), error) {
+	s.mu.Lock()
+	s.queues[call.queueKey] = append(s.queues[call.queueKey], call)
+	if len(s.queues[call.queueKey]) == 1 {
+		s.order = append(s.order, call.queueKey)
+	}
+	s.dispatch()
+	s.updatePositions()
+	s.mu.Unlock()
+
+	release := s.releaseFn(call)
+
+	// positions are reported from here rather than wherever they change so they reach onQueued in order
+	reported := 0
+
+	for {
+		select {
+		case <-call.ready:
+			if reported > 0 && call.onQueued != nil {
+				call.onQueued(0)
+			}
+			return release, nil
+
+		case <-call.moved:
+			s.mu.Lock()
+			position := call.position
+			started := call.started
+			s.mu.Unlock()
+
+			if !started && position != reported && call.onQueued != nil {
+				call.onQueued(position)
+				reported = position
+			}
+
+		case <-ctx.Done():
+			s.mu.Lock()
+			if call.started {
+				// started just as the context finished
+				s.mu.Unlock()
+				release()
+				return nil, ctx.Err()
+			}
+			s.remove(call)
+			s.updatePositions()
+			s.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *scheduler) releaseFn(call *scheduledCall) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			for _, key := range call.limitKeys {
+				s.running[key]--
+				if s.running[key] <= 0 {
+					delete(s.running, key)
+				}
+			}
+			s.dispatch()
+			s.updatePositions()
+			s.mu.Unlock()
+		})
+	}
+}
+
+// dispatch starts waiting calls that are within their limits. Plans take turns: each pass considers the call at the front of each plan's queue, starting with the plan that has waited longest since its last turn.
+func (s *scheduler) dispatch() {
+	for {
+		startedOne := false
+
+		for i, queueKey := range s.order {
+			call := s.queues[queueKey][0]
+			if !s.withinLimits(call) {
+				continue
+			}
+
+			s.queues[queueKey] = s.queues[queueKey][1:]
+			s.order = append(s.order[:i:i], s.order[i+1:]...)
+			if len(s.queues[queueKey]) > 0 {
+				s.order = append(s.order, queueKey)
+			} else {
+				delete(s.queues, queueKey)
+			}
+
+			s.start(call)
+			startedOne = true
+			break
+		}
+
+		if !startedOne {
+			break
+		}
+	}
+
+	s.scheduleWake()
+}
+
+func (s *scheduler) withinLimits(call *scheduledCall) bool {
+	for _, key := range call.limitKeys {
+		limits := s.limits[key.kind]
+
+		if limits.MaxConcurrent > 0 && s.running[key] >= limits.MaxConcurrent {
+			return false
+		}
+
+		if limits.TokensPerMinute > 0 {
+			used := s.tokensUsed(key)
+			// a single call bigger than the limit still runs once the window is clear
+			if used > 0 && used+call.tokens > limits.TokensPerMinute {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *scheduler) start(call *scheduledCall) {
+	now := s.now()
+	for _, key := range call.limitKeys {
+		s.running[key]++
+		if s.limits[key.kind].TokensPerMinute > 0 && call.tokens > 0 {
+			s.usage[key] = append(s.usage[key], tokenUsage{at: now, tokens: call.tokens})
+		}
+	}
+	call.started = true
+	close(call.ready)
+}
+
+func (s *scheduler) tokensUsed(key limitKey) int {
+	cutoff := s.now().Add(-tokensPerMinuteWindow)
+
+	usage := s.usage[key]
+	i := 0
+	for i < len(usage) && !usage[i].at.After(cutoff) {
+		i++
+	}
+	usage = usage[i:]
+
+	if len(usage) == 0 {
+		delete(s.usage, key)
+		return 0
+	}
+	s.usage[key] = usage
+
+	total := 0
+	for _, u := range usage {
+		total += u.tokens
+	}
+	return total
+}
+
+// scheduleWake makes sure calls waiting only on token limits are reconsidered when the oldest usage leaves the window, since no release will come along to do it
+func (s *scheduler) scheduleWake() {
+	if s.wakeTimer != nil {
+		s.wakeTimer.Stop()
+		s.wakeTimer = nil
+	}
+
+	if len(s.order) == 0 {
+		return
+	}
+
+	var earliest time.Time
+	for _, queueKey := range s.order {
+		for _, key := range s.queues[queueKey][0].limitKeys {
+			if usage := s.usage[key]; len(usage) > 0 {
+				expiresAt := usage[0].at.Add(tokensPerMinuteWindow)
+				if earliest.IsZero() || expiresAt.Before(earliest) {
+					earliest = expiresAt
+				}
+			}
+		}
+	}
+
+	if earliest.IsZero() {
+		return
+	}
+
+	wait := earliest.Sub(s.now()) + 10*time.Millisecond
+	s.wakeTimer = time.AfterFunc(wait, s.wake)
+}
+
+func (s *scheduler) wake() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dispatch()
+	s.updatePositions()
+}
+
+func (s *scheduler) remove(call *scheduledCall) {
+	queue := s.queues[call.queueKey]
+	for i, c := range queue {
+		if c == call {
+			s.queues[call.queueKey] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+
+	if len(s.queues[call.queueKey]) == 0 {
+		delete(s.queues, call.queueKey)
+		for i, queueKey := range s.order {
+			if queueKey == call.queueKey {
+				s.order = append(s.order[:i:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// updatePositions works out where each waiting call is in line if plans keep taking turns, and signals those whose position changed
+func (s *scheduler) updatePositions() {
+	for planIdx, queueKey := range s.order {
+		for i, call := range s.queues[queueKey] {
+			// every plan ahead in the order gets i+1 turns before this call, every plan behind gets i
+			position := i + 1
+			for otherIdx, otherKey := range s.order {
+				if otherIdx == planIdx {
+					continue
+				}
+				turns := i
+				if otherIdx < planIdx {
+					turns = i + 1
+				}
+				position += min(turns, len(s.queues[otherKey]))
+			}
+
+			if position != call.position {
+				call.position = position
+				select {
+				case call.moved <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// estimateRequestTokens counts the prompt tokens in req, which is what providers' token limits mostly go by
+func estimateRequestTokens(req openai.ChatCompletionRequest) int {
+	total := 0
+	for _, msg := range req.Messages {
+		text := msg.Content
+		for _, part := range msg.MultiContent {
+			text += part.Text
+		}
+
+		numTokens, err := shared.GetNumTokens(text)
+		if err != nil {
+			numTokens = len(text) / 4
+		}
+
+		// role and formatting overhead
+		total += numTokens + 4
+	}
+	return total
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testCall struct {
+	name    string
+	release func()
+	started chan struct{}
+
+	mu        sync.Mutex
+	positions []int
+}
+
+func (c *testCall) lastPosition() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.positions) == 0 {
+		return -1
+	}
+	return c.positions[len(c.positions)-1]
+}
+
+func startTestCall(t *testing.T, s *scheduler, ctx context.Context, name, queueKey string, tokens int) *testCall {
+	t.Helper()
+
+	c := &testCall{name: name, started: make(chan struct{})}
+	call := &scheduledCall{
+		queueKey:  queueKey,
+		limitKeys: []limitKey{{kind: limitKindApiKey, id: "key"}},
+		tokens:    tokens,
+		onQueued: func(position int) {
+			c.mu.Lock()
+			c.positions = append(c.positions, position)
+			c.mu.Unlock()
+		},
+		ready: make(chan struct{}),
+		moved: make(chan struct{}, 1),
+	}
+
+	go func() {
+		release, err := s.acquire(ctx, call)
+		if err != nil {
+			return
+		}
+		c.release = release
+		close(c.started)
+	}()
+
+	return c
+}
+
+func waitFor(t *testing.T, desc string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", desc)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func isStarted(c *testCall) bool {
+	select {
+	case <-c.started:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestSchedulerTakesTurnsAcrossPlans(t *testing.T) {
+	s := newScheduler(map[limitKind]ModelCallLimits{limitKindApiKey: {MaxConcurrent: 1}}, time.Now)
+	ctx := context.Background()
+
+	first := startTestCall(t, s, ctx, "first", "planA", 0)
+	waitFor(t, "first call to start", func() bool { return isStarted(first) })
+
+	// planA queues three builds, then planB queues one
+	var queued []*testCall
+	for _, name := range []string{"a1", "a2", "a3"} {
+		c := startTestCall(t, s, ctx, name, "planA", 0)
+		queued = append(queued, c)
+		waitFor(t, name+" to queue", func() bool { return c.lastPosition() > 0 })
+	}
+	b1 := startTestCall(t, s, ctx, "b1", "planB", 0)
+	waitFor(t, "b1 to queue", func() bool { return b1.lastPosition() > 0 })
+
+	// planB's call goes second rather than behind all of planA's
+	expectedPositions := map[*testCall]int{queued[0]: 1, b1: 2, queued[1]: 3, queued[2]: 4}
+	for c, position := range expectedPositions {
+		waitFor(t, c.name+" position", func() bool { return c.lastPosition() == position })
+	}
+
+	running := first
+	for _, next := range []*testCall{queued[0], b1, queued[1], queued[2]} {
+		running.release()
+		waitFor(t, next.name+" to start", func() bool { return isStarted(next) })
+
+		if next.lastPosition() != 0 {
+			t.Fatalf("expected %s to be told it started, got positions %v", next.name, next.positions)
+		}
+		for _, other := range []*testCall{queued[0], b1, queued[1], queued[2]} {
+			if other != next && !isStarted(other) && other.lastPosition() == 0 {
+				t.Fatalf("%s was told it started while still waiting", other.name)
+			}
+		}
+		running = next
+	}
+	running.release()
+
+	if len(s.running) != 0 || len(s.queues) != 0 || len(s.order) != 0 {
+		t.Fatalf("expected scheduler to be empty, got running %v, queues %v", s.running, s.queues)
+	}
+}
+
+func TestSchedulerTokensPerMinute(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Now()
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	s := newScheduler(map[limitKind]ModelCallLimits{limitKindApiKey: {TokensPerMinute: 100}}, clock)
+	ctx := context.Background()
+
+	// a call bigger than the limit still runs on its own
+	big := startTestCall(t, s, ctx, "big", "planA", 150)
+	waitFor(t, "big call to start", func() bool { return isStarted(big) })
+	big.release()
+
+	small := startTestCall(t, s, ctx, "small", "planA", 10)
+	waitFor(t, "small call to queue", func() bool { return small.lastPosition() == 1 })
+
+	mu.Lock()
+	now = now.Add(tokensPerMinuteWindow + time.Second)
+	mu.Unlock()
+
+	// what the wake timer does once the window clears, without waiting a minute for it
+	s.wake()
+	waitFor(t, "small call to start once the window clears", func() bool { return isStarted(small) })
+	small.release()
+}
+
+func TestSchedulerCancelWhileQueued(t *testing.T) {
+	s := newScheduler(map[limitKind]ModelCallLimits{limitKindApiKey: {MaxConcurrent: 1}}, time.Now)
+
+	first := startTestCall(t, s, context.Background(), "first", "planA", 0)
+	waitFor(t, "first call to start", func() bool { return isStarted(first) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := startTestCall(t, s, ctx, "cancelled", "planA", 0)
+	waitFor(t, "call to queue", func() bool { return cancelled.lastPosition() == 1 })
+
+	next := startTestCall(t, s, context.Background(), "next", "planB", 0)
+	waitFor(t, "next call to queue", func() bool { return next.lastPosition() == 2 })
+
+	cancel()
+	waitFor(t, "next call to move up", func() bool { return next.lastPosition() == 1 })
+
+	first.release()
+	waitFor(t, "next call to start", func() bool { return isStarted(next) })
+	if isStarted(cancelled) {
+		t.Fatal("cancelled call started")
+	}
+	next.release()
+}
@@ -20,7 +20,7 @@ type PlanSummaryParams struct {
 	PlanId                      string
 }
 
-func PlanSummary(client *openai.Client, config shared.ModelRoleConfig, params PlanSummaryParams, ctx context.Context) (*db.ConvoSummary, error) {
+func PlanSummary(client *Client, config shared.ModelRoleConfig, params PlanSummaryParams, ctx context.Context) (*db.ConvoSummary, error) {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -42,6 +42,7 @@ func PlanSummary(client *openai.Client, config shared.ModelRoleConfig, params Pl
 
 	resp, err := CreateChatCompletionWithRetries(
 		client,
+		shared.ModelRolePlanSummary,
 		ctx,
 		openai.ChatCompletionRequest{
 			Model:       config.BaseModelConfig.ModelName,
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"net/http"
+	"plandex-server/logging"
+	"plandex-server/metrics"
+	"plandex-server/tracing"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// instrument records each request's latency, starts a server span for it (continuing the caller's trace if it sent a traceparent header) and tags its context with a request id and the plan and branch for logging. Metrics and spans are labeled with the route template rather than the path so plan ids don't each get their own series or span name.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		ctx, span := tracing.StartRequest(r, r.Method+" "+route,
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+		)
+		defer span.End()
+
+		requestId := r.Header.Get(requestIdHeader)
+		if requestId == "" || len(requestId) > maxRequestIdLength {
+			requestId = uuid.New().String()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+
+		logArgs := []any{logging.RequestIdKey, requestId, "method", r.Method, "route", route}
+
+		if vars := mux.Vars(r); vars["planId"] != "" {
+			span.SetAttributes(tracing.PlanAttrs(vars["planId"], vars["branch"])...)
+			logArgs = append(logArgs, "plan_id", vars["planId"])
+			if vars["branch"] != "" {
+				logArgs = append(logArgs, "branch", vars["branch"])
+			}
+		}
+
+		ctx = logging.With(ctx, logArgs...)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		logging.Ctx(ctx).Debug("Request finished", "status", sw.status, "duration", time.Since(start))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+
+		metrics.HttpRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// a caller's own request id is used if it sends one, so its logs and ours can be joined
+const requestIdHeader = "X-Request-Id"
+const maxRequestIdLength = 128
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush keeps plan streams working through the middleware, since they flush after each message
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
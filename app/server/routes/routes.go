@@ -1,16 +1,19 @@
-package main
+package routes
 
 import (
 	"fmt"
 	"net/http"
 	"os"
 	"plandex-server/handlers"
+	"plandex-server/metrics"
 
 	"github.com/gorilla/mux"
 )
 
-func routes() *mux.Router {
+// NewRouter registers every API handler. It's shared by the server binary and the CLI's local mode, which serves the same routes in-process.
+func NewRouter() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(instrument)
 
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "OK")
@@ -28,20 +31,44 @@ func routes() *mux.Router {
 		fmt.Fprint(w, string(bytes))
 	})
 
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
 	r.HandleFunc("/accounts/start_trial", handlers.StartTrialHandler).Methods("POST")
 	r.HandleFunc("/accounts/email_verifications", handlers.CreateEmailVerificationHandler).Methods("POST")
 	r.HandleFunc("/accounts/sign_in", handlers.SignInHandler).Methods("POST")
 	r.HandleFunc("/accounts/sign_out", handlers.SignOutHandler).Methods("POST")
+	r.HandleFunc("/accounts/sso", handlers.GetSsoConfigHandler).Methods("GET")
+	r.HandleFunc("/accounts/sso/start", handlers.StartSsoHandler).Methods("POST")
+	r.HandleFunc("/accounts/sso/callback", handlers.SsoCallbackHandler).Methods("GET")
+	r.HandleFunc("/accounts/sso/callback", handlers.ConfirmSsoHandler).Methods("POST")
+	r.HandleFunc("/accounts/sso/poll", handlers.PollSsoHandler).Methods("POST")
+	r.HandleFunc("/accounts/sessions", handlers.ListSessionsHandler).Methods("GET")
+	r.HandleFunc("/accounts/sessions", handlers.RevokeOtherSessionsHandler).Methods("DELETE")
+	r.HandleFunc("/accounts/sessions/{sessionId}", handlers.RevokeSessionHandler).Methods("DELETE")
 	r.HandleFunc("/accounts", handlers.CreateAccountHandler).Methods("POST")
 	r.HandleFunc("/accounts/convert_trial", handlers.ConvertTrialHandler).Methods("POST")
 
+	r.HandleFunc("/access_tokens", handlers.ListAccessTokensHandler).Methods("GET")
+	r.HandleFunc("/access_tokens", handlers.CreateAccessTokenHandler).Methods("POST")
+	r.HandleFunc("/access_tokens/{tokenId}", handlers.RevokeAccessTokenHandler).Methods("DELETE")
+
 	r.HandleFunc("/orgs/session", handlers.GetOrgSessionHandler).Methods("GET")
 	r.HandleFunc("/orgs", handlers.ListOrgsHandler).Methods("GET")
 	r.HandleFunc("/orgs", handlers.CreateOrgHandler).Methods("POST")
 
 	r.HandleFunc("/users", handlers.ListUsersHandler).Methods("GET")
 	r.HandleFunc("/orgs/users/{userId}", handlers.DeleteOrgUserHandler).Methods("DELETE")
+	r.HandleFunc("/orgs/users/{userId}/role", handlers.SetOrgUserRoleHandler).Methods("PUT")
 	r.HandleFunc("/orgs/roles", handlers.ListOrgRolesHandler).Methods("GET")
+	r.HandleFunc("/orgs/roles", handlers.CreateOrgRoleHandler).Methods("POST")
+	r.HandleFunc("/orgs/roles/{roleId}", handlers.UpdateOrgRoleHandler).Methods("PUT")
+	r.HandleFunc("/orgs/roles/{roleId}", handlers.DeleteOrgRoleHandler).Methods("DELETE")
+	r.HandleFunc("/orgs/audit_events", handlers.ListAuditEventsHandler).Methods("GET")
+	r.HandleFunc("/orgs/webhooks", handlers.ListWebhooksHandler).Methods("GET")
+	r.HandleFunc("/orgs/webhooks", handlers.CreateWebhookHandler).Methods("POST")
+	r.HandleFunc("/orgs/webhooks/{webhookId}", handlers.DeleteWebhookHandler).Methods("DELETE")
+	r.HandleFunc("/orgs/webhooks/{webhookId}/deliveries", handlers.ListWebhookDeliveriesHandler).Methods("GET")
+	r.HandleFunc("/orgs/webhooks/{webhookId}/test", handlers.TestWebhookHandler).Methods("POST")
 
 	r.HandleFunc("/invites", handlers.InviteUserHandler).Methods("POST")
 	r.HandleFunc("/invites/pending", handlers.ListPendingInvitesHandler).Methods("GET")
@@ -59,6 +86,7 @@ func routes() *mux.Router {
 	r.HandleFunc("/plans", handlers.ListPlansHandler).Methods("GET")
 	r.HandleFunc("/plans/archive", handlers.ListArchivedPlansHandler).Methods("GET")
 	r.HandleFunc("/plans/ps", handlers.ListPlansRunningHandler).Methods("GET")
+	r.HandleFunc("/plans/shared", handlers.ListSharedPlansHandler).Methods("GET")
 
 	r.HandleFunc("/projects/{projectId}/plans", handlers.CreatePlanHandler).Methods("POST")
 
@@ -72,19 +100,29 @@ func routes() *mux.Router {
 	r.HandleFunc("/plans/{planId}/{branch}/respond_missing_file", handlers.RespondMissingFileHandler).Methods("POST")
 
 	r.HandleFunc("/plans/{planId}/{branch}/build", handlers.BuildPlanHandler).Methods("PATCH")
-	r.HandleFunc("/plans/{planId}/{branch}/connect", handlers.ConnectPlanHandler).Methods("PATCH")
+	// GET is for standard SSE clients, which can't send PATCH
+	r.HandleFunc("/plans/{planId}/{branch}/connect", handlers.ConnectPlanHandler).Methods("PATCH", "GET")
 	r.HandleFunc("/plans/{planId}/{branch}/stop", handlers.StopPlanHandler).Methods("DELETE")
 
 	r.HandleFunc("/plans/{planId}/{branch}/current_plan", handlers.CurrentPlanHandler).Methods("GET")
 	r.HandleFunc("/plans/{planId}/{branch}/apply", handlers.ApplyPlanHandler).Methods("PATCH")
+	r.HandleFunc("/plans/{planId}/{branch}/unapply", handlers.UnapplyPlanHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/archive", handlers.ArchivePlanHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/unarchive", handlers.UnarchivePlanHandler).Methods("PATCH")
+	r.HandleFunc("/plans/{planId}/{branch}/share", handlers.SharePlanHandler).Methods("PATCH")
+	r.HandleFunc("/plans/{planId}/{branch}/unshare", handlers.UnsharePlanHandler).Methods("PATCH")
 
 	r.HandleFunc("/plans/{planId}/rename", handlers.RenamePlanHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/{branch}/reject_all", handlers.RejectAllChangesHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/{branch}/reject_file", handlers.RejectFileHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/{branch}/reject_files", handlers.RejectFilesHandler).Methods("PATCH")
 	r.HandleFunc("/plans/{planId}/{branch}/diffs", handlers.GetPlanDiffsHandler).Methods("GET")
+	r.HandleFunc("/plans/{planId}/{branch}/patches", handlers.GetPlanPatchesHandler).Methods("GET")
+
+	r.HandleFunc("/plans/{planId}/{branch}/review_comments", handlers.ListReviewCommentsHandler).Methods("GET")
+	r.HandleFunc("/plans/{planId}/{branch}/review_comments", handlers.CreateReviewCommentHandler).Methods("POST")
+	r.HandleFunc("/plans/{planId}/{branch}/review_comments/resolve", handlers.ResolveReviewCommentsHandler).Methods("PATCH")
+	r.HandleFunc("/plans/{planId}/{branch}/review_comments/{commentId}", handlers.DeleteReviewCommentHandler).Methods("DELETE")
 
 	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.ListContextHandler).Methods("GET")
 	r.HandleFunc("/plans/{planId}/{branch}/context", handlers.LoadContextHandler).Methods("POST")
@@ -0,0 +1,278 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Single sign-on with any OpenID Connect provider, configured with OIDC_* environment variables. The server is the OIDC client: it holds the client secret, runs the authorization code flow with PKCE, and verifies ID tokens. The CLI only opens the provider's login page and waits for the server to finish.
+
+const defaultGroupsClaim = "groups"
+const defaultProviderName = "SSO"
+
+type Config struct {
+	IssuerUrl    string
+	ClientId     string
+	ClientSecret string
+	// the server's /accounts/sso/callback url, registered with the provider
+	RedirectUrl  string
+	Scopes       []string
+	ProviderName string
+
+	GroupsClaim string
+	// checked in order, so list more privileged roles first
+	GroupRoles []GroupRole
+	// role for users on the org's domain whose groups don't match any GroupRoles--empty means they aren't added
+	DefaultRole string
+
+	// when set, email pin sign-in is turned off
+	Required bool
+}
+
+type GroupRole struct {
+	Group string
+	Role  string
+}
+
+// Identity is what a verified ID token says about the user
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+var config *Config
+
+var mu sync.Mutex
+var provider *oidc.Provider
+
+// Init reads OIDC_* environment variables. SSO stays disabled unless OIDC_ISSUER_URL is set. The provider's discovery document is fetched on first use so the server can start while the provider is unreachable.
+func Init() error {
+	c, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	config = c
+	return nil
+}
+
+func configFromEnv() (*Config, error) {
+	issuerUrl := os.Getenv("OIDC_ISSUER_URL")
+	if issuerUrl == "" {
+		return nil, nil
+	}
+
+	c := &Config{
+		IssuerUrl:    issuerUrl,
+		ClientId:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectUrl:  os.Getenv("OIDC_REDIRECT_URL"),
+		ProviderName: os.Getenv("OIDC_PROVIDER_NAME"),
+		GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		DefaultRole:  "member",
+		Required:     os.Getenv("OIDC_REQUIRED") != "",
+	}
+
+	if c.ClientId == "" || c.RedirectUrl == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
+	if c.ProviderName == "" {
+		c.ProviderName = defaultProviderName
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = defaultGroupsClaim
+	}
+
+	if defaultRole, ok := os.LookupEnv("OIDC_DEFAULT_ROLE"); ok {
+		c.DefaultRole = strings.TrimSpace(defaultRole)
+	}
+
+	c.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		c.Scopes = splitList(scopes)
+	}
+
+	groupRoles, err := ParseGroupRoles(os.Getenv("OIDC_GROUP_ROLES"))
+	if err != nil {
+		return nil, err
+	}
+	c.GroupRoles = groupRoles
+
+	return c, nil
+}
+
+// ParseGroupRoles parses a comma-separated list of group=role pairs, like "plandex-admins=admin,engineering=member"
+func ParseGroupRoles(s string) ([]GroupRole, error) {
+	var res []GroupRole
+	for _, pair := range splitList(s) {
+		group, role, found := strings.Cut(pair, "=")
+		group = strings.TrimSpace(group)
+		role = strings.TrimSpace(role)
+		if !found || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid OIDC_GROUP_ROLES entry %q, expected group=role", pair)
+		}
+		res = append(res, GroupRole{Group: group, Role: role})
+	}
+	return res, nil
+}
+
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+func Enabled() bool {
+	return config != nil
+}
+
+func Required() bool {
+	return config != nil && config.Required
+}
+
+func ProviderName() string {
+	if config == nil {
+		return ""
+	}
+	return config.ProviderName
+}
+
+// RoleForGroups returns the role name to give a user with these groups, and whether it came from a group mapping rather than the default role
+func RoleForGroups(groups []string) (string, bool) {
+	return config.roleForGroups(groups)
+}
+
+func (c *Config) roleForGroups(groups []string) (string, bool) {
+	for _, groupRole := range c.GroupRoles {
+		for _, group := range groups {
+			if group == groupRole.Group {
+				return groupRole.Role, true
+			}
+		}
+	}
+	return c.DefaultRole, false
+}
+
+func getProvider(ctx context.Context) (*oidc.Provider, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if provider != nil {
+		return provider, nil
+	}
+
+	p, err := oidc.NewProvider(ctx, config.IssuerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("error loading OIDC provider configuration: %v", err)
+	}
+
+	provider = p
+	return provider, nil
+}
+
+func (c *Config) oauth2Config(p *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.ClientId,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectUrl,
+		Endpoint:     p.Endpoint(),
+		Scopes:       c.Scopes,
+	}
+}
+
+// AuthCodeUrl returns the provider's login page url for a new login
+func AuthCodeUrl(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return config.oauth2Config(p).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange trades the code from the provider's redirect for tokens, verifies the ID token against the login's nonce, and returns the user's identity
+func Exchange(ctx context.Context, code, nonce, codeVerifier string) (*Identity, error) {
+	p, err := getProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.oauth2Config(p).Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging code: %v", err)
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("no id_token in token response")
+	}
+
+	idToken, err := p.Verifier(&oidc.Config{ClientID: config.ClientId}).Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying id token: %v", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("id token nonce doesn't match")
+	}
+
+	var claims map[string]interface{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing id token claims: %v", err)
+	}
+
+	identity := &Identity{
+		Subject: idToken.Subject,
+		// providers that don't send email_verified are trusted to only issue verified emails
+		EmailVerified: true,
+		Groups:        stringsClaim(claims[config.GroupsClaim]),
+	}
+
+	if email, ok := claims["email"].(string); ok {
+		identity.Email = strings.ToLower(strings.TrimSpace(email))
+	}
+	if verified, ok := claims["email_verified"].(bool); ok {
+		identity.EmailVerified = verified
+	}
+	if name, ok := claims["name"].(string); ok {
+		identity.Name = name
+	}
+
+	if identity.Email == "" {
+		return nil, fmt.Errorf("id token has no email claim--make sure the 'email' scope is allowed")
+	}
+
+	return identity, nil
+}
+
+// stringsClaim handles groups sent as a list, or as a single string by some providers
+func stringsClaim(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		var res []string
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// mockProvider is a minimal OIDC provider: discovery, keys, and a token endpoint that issues an ID token for whichever code it was last asked to authorize
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	claims        map[string]interface{}
+	codeChallenge string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		verifierHash := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != m.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     m.idToken(t),
+		})
+	})
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) idToken(t *testing.T) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: m.key, KeyID: "test"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{
+		"iss": m.server.URL,
+		"aud": "plandex",
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func setupMock(t *testing.T) *mockProvider {
+	m := newMockProvider(t)
+
+	prevConfig := config
+	config = &Config{
+		IssuerUrl:    m.server.URL,
+		ClientId:     "plandex",
+		ClientSecret: "secret",
+		RedirectUrl:  "http://localhost:8080/accounts/sso/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  defaultGroupsClaim,
+		DefaultRole:  "member",
+	}
+	provider = nil
+	t.Cleanup(func() {
+		config = prevConfig
+		provider = nil
+	})
+
+	return m
+}
+
+// authorize does what the browser and provider would: reads the PKCE challenge off the login url so the token endpoint can check the verifier
+func (m *mockProvider) authorize(t *testing.T, state, nonce, verifier string) {
+	authUrl, err := AuthCodeUrl(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := parsed.Query()
+	if q.Get("state") != state || q.Get("nonce") != nonce || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url %s", authUrl)
+	}
+	m.codeChallenge = q.Get("code_challenge")
+}
+
+func TestExchange(t *testing.T) {
+	m := setupMock(t)
+	verifier := "verifier-verifier-verifier-verifier-verifier-verifier"
+
+	m.claims = map[string]interface{}{
+		"nonce":          "nonce-1",
+		"email":          "Dev@Example.com",
+		"email_verified": true,
+		"name":           "Dev",
+		"groups":         []string{"engineering", "plandex-admins"},
+	}
+	m.authorize(t, "state-1", "nonce-1", verifier)
+
+	identity, err := Exchange(context.Background(), "good-code", "nonce-1", verifier)
+	if err != nil {
+		t.Fatalf("error exchanging code: %v", err)
+	}
+
+	if identity.Email != "dev@example.com" || !identity.EmailVerified || identity.Name != "Dev" || len(identity.Groups) != 2 {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	_, err = Exchange(context.Background(), "good-code", "other-nonce", verifier)
+	if err == nil {
+		t.Errorf("expected nonce mismatch to fail")
+	}
+
+	_, err = Exchange(context.Background(), "good-code", "nonce-1", "wrong-verifier-wrong-verifier-wrong-verifier-wrong")
+	if err == nil {
+		t.Errorf("expected wrong code verifier to fail")
+	}
+
+	m.claims["email_verified"] = false
+	identity, err = Exchange(context.Background(), "good-code", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.EmailVerified {
+		t.Errorf("expected email to be unverified")
+	}
+}
+
+func TestRoleForGroups(t *testing.T) {
+	groupRoles, err := ParseGroupRoles("plandex-owners=owner, plandex-admins=admin,engineering=member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{GroupRoles: groupRoles, DefaultRole: "member"}
+
+	role, fromGroups := c.roleForGroups([]string{"engineering", "plandex-admins"})
+	if role != "admin" || !fromGroups {
+		t.Errorf("expected admin from groups, got %s, %v", role, fromGroups)
+	}
+
+	role, fromGroups = c.roleForGroups([]string{"sales"})
+	if role != "member" || fromGroups {
+		t.Errorf("expected default member role, got %s, %v", role, fromGroups)
+	}
+
+	_, err = ParseGroupRoles("plandex-admins")
+	if err == nil {
+		t.Errorf("expected entry without a role to be invalid")
+	}
+}
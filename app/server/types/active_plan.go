@@ -3,9 +3,12 @@ package types
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"plandex-server/db"
+	"plandex-server/logging"
+	"sort"
 	"sync"
 	"time"
 
@@ -15,7 +18,8 @@ import (
 
 const MaxStreamRate = 50 * time.Millisecond
 
-// const MaxConcurrentBuildStreams = 3 // otherwise we get EOF errors from openai
+// StreamReplayBufferSize is how many sent stream messages an active plan keeps for clients that reconnect. With rate limiting, that's at least the last 50 seconds of the stream.
+const StreamReplayBufferSize = 1000
 
 type ActiveBuild struct {
 	ReplyId                  string
@@ -37,6 +41,11 @@ type ActiveBuild struct {
 	ToVerifyUpdatedState     string
 }
 
+type streamedMessage struct {
+	seq int64
+	msg string
+}
+
 type subscription struct {
 	ch           chan string
 	ctx          context.Context
@@ -83,19 +92,27 @@ type ActivePlan struct {
 	subscriptions  map[string]*subscription
 	subscriptionMu sync.Mutex
 
-	streamCh              chan string
+	// connections on other instances, keyed by their connect command id
+	remoteSubscribers map[string]bool
+	// the most recently sent messages and the seq of the last one, guarded by subscriptionMu
+	replayBuffer []streamedMessage
+	lastSentSeq  int64
+
+	streamCh              chan streamedMessage
 	streamMu              sync.Mutex
+	streamSeq             int64
 	lastStreamMessageSent time.Time
 	streamMessageBuffer   []shared.StreamMessage
 }
 
-func NewActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool) *ActivePlan {
-	ctx, cancel := context.WithCancel(context.Background())
+// parentCtx carries the plan's trace and log fields. It shouldn't be cancelled by anything else, since the plan's contexts are derived from it.
+func NewActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool, parentCtx context.Context) *ActivePlan {
+	ctx, cancel := context.WithCancel(parentCtx)
 	// child context for model stream so we can cancel it separately if needed
 	modelStreamCtx, cancelModelStream := context.WithCancel(ctx)
 
 	// we don't want to cancel summaries unless the whole plan is stopped or there's an error -- if the active plan finishes, we want summaries to continue -- so they get their own context
-	summaryCtx, cancelSummary := context.WithCancel(context.Background())
+	summaryCtx, cancelSummary := context.WithCancel(parentCtx)
 
 	active := ActivePlan{
 		Id:                    planId,
@@ -120,9 +137,10 @@ func NewActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool)
 		MissingFileResponseCh: make(chan shared.RespondMissingFileChoice),
 		AllowOverwritePaths:   map[string]bool{},
 		SkippedPaths:          map[string]bool{},
-		streamCh:              make(chan string),
+		streamCh:              make(chan streamedMessage),
 		subscriptions:         map[string]*subscription{},
 		subscriptionMu:        sync.Mutex{},
+		remoteSubscribers:     map[string]bool{},
 	}
 
 	go func() {
@@ -136,15 +154,28 @@ func NewActivePlan(orgId, userId, planId, branch, prompt string, buildOnly bool)
 			select {
 			case <-active.Ctx.Done():
 				return
-			case msg := <-active.streamCh:
-				var subscriptions map[string]*subscription
+			case sent := <-active.streamCh:
+				// buffering and delivery happen under one lock so a resuming subscriber gets each message exactly once, either replayed or live
 				active.subscriptionMu.Lock()
-				subscriptions = active.subscriptions
-				active.subscriptionMu.Unlock()
-				for _, sub := range subscriptions {
-					sub.enqueueMessage(msg)
+
+				active.replayBuffer = append(active.replayBuffer, sent)
+				if len(active.replayBuffer) > StreamReplayBufferSize {
+					active.replayBuffer = active.replayBuffer[len(active.replayBuffer)-StreamReplayBufferSize:]
+				}
+				active.lastSentSeq = sent.seq
+
+				for _, sub := range active.subscriptions {
+					sub.enqueueMessage(sent.msg)
+				}
+
+				if len(active.remoteSubscribers) > 0 {
+					err := active.PublishStreamEvent(PlanStreamEvent{Message: json.RawMessage(sent.msg)})
+					if err != nil {
+						log.Printf("ActivePlan: error publishing stream message: %v\n", err)
+					}
 				}
 
+				active.subscriptionMu.Unlock()
 			}
 		}
 	}()
@@ -199,18 +230,6 @@ func (ap *ActivePlan) Stream(msg shared.StreamMessage) {
 		}
 	}
 
-	msgJson, err := json.Marshal(msg)
-	if err != nil {
-		ap.StreamDoneCh <- &shared.ApiError{
-			Type:   shared.ApiErrorTypeOther,
-			Status: http.StatusInternalServerError,
-			Msg:    "Error marshalling stream message: " + err.Error(),
-		}
-		return
-	}
-
-	// log.Printf("ActivePlan: sending stream message: %s\n", string(msgJson))
-
 	if msg.Type == shared.StreamMessageFinished {
 		// send full buffer if we got a finished message
 		if len(ap.streamMessageBuffer) > 0 {
@@ -226,9 +245,23 @@ func (ap *ActivePlan) Stream(msg shared.StreamMessage) {
 		}
 	}
 
-	// log.Println("ActivePlan: sending stream message")
+	// seqs are assigned in the order messages are sent, after any buffer flush above
+	ap.streamSeq++
+	msg.Seq = ap.streamSeq
 
-	ap.streamCh <- string(msgJson)
+	msgJson, err := json.Marshal(msg)
+	if err != nil {
+		ap.StreamDoneCh <- &shared.ApiError{
+			Type:   shared.ApiErrorTypeOther,
+			Status: http.StatusInternalServerError,
+			Msg:    "Error marshalling stream message: " + err.Error(),
+		}
+		return
+	}
+
+	// log.Printf("ActivePlan: sending stream message: %s\n", string(msgJson))
+
+	ap.streamCh <- streamedMessage{seq: msg.Seq, msg: string(msgJson)}
 
 	// log.Println("ActivePlan: sent stream message")
 
@@ -244,6 +277,11 @@ func (ap *ActivePlan) Stream(msg shared.StreamMessage) {
 	}
 }
 
+// Log returns a logger for the plan's activity, tagged with its org, user, plan, branch and model stream
+func (ap *ActivePlan) Log() *logging.Logger {
+	return logging.Ctx(ap.Ctx)
+}
+
 func (ap *ActivePlan) ResetModelCtx() {
 	ap.ModelStreamCtx, ap.CancelModelStreamFn = context.WithCancel(ap.Ctx)
 }
@@ -266,6 +304,29 @@ func (ap *ActivePlan) PathQueueEmpty(path string) bool {
 	return true
 }
 
+// Checkpoint captures what the plan has done so far that isn't in its repo yet
+func (ap *ActivePlan) Checkpoint() *db.ModelStreamCheckpoint {
+	checkpoint := &db.ModelStreamCheckpoint{
+		BuildOnly:       ap.BuildOnly,
+		RepliesFinished: ap.RepliesFinished,
+		MessageNum:      ap.MessageNum,
+	}
+
+	if !ap.BuildOnly && !ap.RepliesFinished {
+		checkpoint.ReplyContent = ap.CurrentReplyContent
+		checkpoint.ReplyTokens = ap.NumTokens
+	}
+
+	for path := range ap.BuildQueuesByPath {
+		if ap.IsBuildingByPath[path] || !ap.PathQueueEmpty(path) {
+			checkpoint.BuildPaths = append(checkpoint.BuildPaths, path)
+		}
+	}
+	sort.Strings(checkpoint.BuildPaths)
+
+	return checkpoint
+}
+
 func (ap *ActivePlan) Subscribe() (string, chan string) {
 	ap.subscriptionMu.Lock()
 	defer ap.subscriptionMu.Unlock()
@@ -275,6 +336,87 @@ func (ap *ActivePlan) Subscribe() (string, chan string) {
 	return id, sub.ch
 }
 
+// Resume subscribes a reconnecting client that last received lastSeq, queueing the messages it missed ahead of new ones. It returns false without subscribing if some of those messages are no longer buffered.
+func (ap *ActivePlan) Resume(lastSeq int64) (string, chan string, bool) {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+
+	missed, ok := ap.missedMessages(lastSeq)
+	if !ok {
+		return "", nil, false
+	}
+
+	id := uuid.New().String()
+	sub := newSubscription()
+	for _, msg := range missed {
+		sub.enqueueMessage(msg)
+	}
+	ap.subscriptions[id] = sub
+	return id, sub.ch, true
+}
+
+// ResumeRemote is Resume for a connection on another instance: the missed messages are published for that connection before it starts getting new ones
+func (ap *ActivePlan) ResumeRemote(subscriberId string, lastSeq int64) (bool, error) {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+
+	missed, ok := ap.missedMessages(lastSeq)
+	if !ok {
+		return false, nil
+	}
+
+	for _, msg := range missed {
+		err := ap.PublishStreamEvent(PlanStreamEvent{SubscriberId: subscriberId, Message: json.RawMessage(msg)})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	ap.remoteSubscribers[subscriberId] = true
+	return true, nil
+}
+
+// ConnectRemote catches up a new connection on another instance and subscribes it. catchUp builds the catch-up messages from the plan's current state--it runs under the same lock that messages are sent under, so nothing streamed between the catch-up and the subscription is missed.
+func (ap *ActivePlan) ConnectRemote(subscriberId string, catchUp func() ([]string, error)) error {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+
+	msgs, err := catchUp()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		err := ap.PublishStreamEvent(PlanStreamEvent{SubscriberId: subscriberId, Message: json.RawMessage(msg)})
+		if err != nil {
+			return err
+		}
+	}
+
+	ap.remoteSubscribers[subscriberId] = true
+	return nil
+}
+
+// missedMessages returns the messages sent after lastSeq, or false if any have already left the replay buffer. Callers hold subscriptionMu.
+func (ap *ActivePlan) missedMessages(lastSeq int64) ([]string, bool) {
+	if lastSeq > ap.lastSentSeq {
+		return nil, false
+	}
+
+	var missed []string
+	for _, sent := range ap.replayBuffer {
+		if sent.seq > lastSeq {
+			missed = append(missed, sent.msg)
+		}
+	}
+
+	if lastSeq < ap.lastSentSeq && (len(ap.replayBuffer) == 0 || ap.replayBuffer[0].seq > lastSeq+1) {
+		return nil, false
+	}
+
+	return missed, true
+}
+
 func (ap *ActivePlan) Unsubscribe(id string) {
 	ap.subscriptionMu.Lock()
 	defer ap.subscriptionMu.Unlock()
@@ -294,6 +436,33 @@ func (ap *ActivePlan) NumSubscribers() int {
 	return len(ap.subscriptions)
 }
 
+func (ap *ActivePlan) AddRemoteSubscriber(id string) {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+	ap.remoteSubscribers[id] = true
+}
+
+func (ap *ActivePlan) RemoveRemoteSubscriber(id string) {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+	delete(ap.remoteSubscribers, id)
+}
+
+func (ap *ActivePlan) NumRemoteSubscribers() int {
+	ap.subscriptionMu.Lock()
+	defer ap.subscriptionMu.Unlock()
+	return len(ap.remoteSubscribers)
+}
+
+func (ap *ActivePlan) PublishStreamEvent(event PlanStreamEvent) error {
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshalling stream event: %v", err)
+	}
+
+	return db.PublishEvent(PlanStreamChannel(ap.Id, ap.Branch), bytes)
+}
+
 func (b *ActiveBuild) BuildFinished() bool {
 	return b.Success || b.Error != nil
 }
@@ -0,0 +1,201 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"plandex-server/db"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sendSeqs pushes messages through the plan's stream manager directly, skipping Stream's rate limiting, and waits until the last one has been buffered
+func sendSeqs(t *testing.T, ap *ActivePlan, from, to int64) {
+	for seq := from; seq <= to; seq++ {
+		ap.streamCh <- streamedMessage{seq: seq, msg: strconv.FormatInt(seq, 10)}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ap.subscriptionMu.Lock()
+		lastSentSeq := ap.lastSentSeq
+		ap.subscriptionMu.Unlock()
+
+		if lastSentSeq >= to {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for seq %d to be sent", to)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// receiveSeqs reads n messages from a subscription and checks they're the seqs following after, in order
+func receiveSeqs(t *testing.T, ch chan string, after int64, n int) {
+	for i := 1; i <= n; i++ {
+		select {
+		case msg := <-ch:
+			if msg != strconv.FormatInt(after+int64(i), 10) {
+				t.Fatalf("expected seq %d, got %s", after+int64(i), msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for seq %d", after+int64(i))
+		}
+	}
+}
+
+func TestActivePlanResume(t *testing.T) {
+	ap := NewActivePlan("org", "user", "plan", "main", "", false, context.Background())
+	defer ap.CancelFn()
+
+	// nothing has been sent yet
+	id, ch, ok := ap.Resume(0)
+	if !ok {
+		t.Fatal("expected resume before any messages to succeed")
+	}
+
+	const extra = 10
+	last := int64(StreamReplayBufferSize + extra)
+	sendSeqs(t, ap, 1, last)
+
+	// a subscriber that was connected the whole time gets everything once
+	receiveSeqs(t, ch, 0, int(last))
+	ap.Unsubscribe(id)
+
+	ap.subscriptionMu.Lock()
+	bufferLen := len(ap.replayBuffer)
+	firstBuffered := ap.replayBuffer[0].seq
+	ap.subscriptionMu.Unlock()
+
+	if bufferLen != StreamReplayBufferSize || firstBuffered != extra+1 {
+		t.Fatalf("expected the last %d messages buffered from seq %d, got %d from seq %d", StreamReplayBufferSize, extra+1, bufferLen, firstBuffered)
+	}
+
+	tests := []struct {
+		name    string
+		lastSeq int64
+		ok      bool
+	}{
+		{"boundary", extra, true},
+		{"evicted", extra - 1, false},
+		{"inside buffer", last / 2, true},
+		{"caught up", last, true},
+		{"past last seq", last + 1, false},
+	}
+
+	for _, test := range tests {
+		id, ch, ok := ap.Resume(test.lastSeq)
+		if ok != test.ok {
+			t.Errorf("%s: expected resume from %d to be %v, got %v", test.name, test.lastSeq, test.ok, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		receiveSeqs(t, ch, test.lastSeq, int(last-test.lastSeq))
+
+		select {
+		case msg := <-ch:
+			t.Errorf("%s: expected no more messages, got %s", test.name, msg)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		ap.Unsubscribe(id)
+	}
+}
+
+func TestActivePlanResumeWhileStreaming(t *testing.T) {
+	ap := NewActivePlan("org", "user", "plan", "main", "", false, context.Background())
+	defer ap.CancelFn()
+
+	sendSeqs(t, ap, 1, 100)
+
+	// messages keep streaming while the client resumes, so each one has to come either from the replay or live, never both or neither
+	done := make(chan bool)
+	go func() {
+		for seq := int64(101); seq <= 300; seq++ {
+			ap.streamCh <- streamedMessage{seq: seq, msg: strconv.FormatInt(seq, 10)}
+		}
+		done <- true
+	}()
+
+	time.Sleep(time.Millisecond)
+
+	const lastSeq = 50
+	id, ch, ok := ap.Resume(lastSeq)
+	if !ok {
+		t.Fatal("expected resume to succeed")
+	}
+	defer ap.Unsubscribe(id)
+
+	receiveSeqs(t, ch, lastSeq, 300-lastSeq)
+	<-done
+
+	select {
+	case msg := <-ch:
+		t.Errorf("expected no more messages, got %s", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestActivePlanConnectRemote(t *testing.T) {
+	ap := NewActivePlan("org", "user", "connect-remote-plan", "main", "", false, context.Background())
+	defer ap.CancelFn()
+
+	events, unsubscribe, err := db.SubscribeEvents(PlanStreamChannel(ap.Id, ap.Branch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	sendSeqs(t, ap, 1, 50)
+
+	// messages keep streaming while the remote connection is caught up, and each must come after the catch-up exactly once
+	done := make(chan bool)
+	go func() {
+		for seq := int64(51); seq <= 300; seq++ {
+			ap.streamCh <- streamedMessage{seq: seq, msg: strconv.FormatInt(seq, 10)}
+		}
+		done <- true
+	}()
+
+	time.Sleep(time.Millisecond)
+
+	var caughtUpTo int64
+	err = ap.ConnectRemote("subscriber", func() ([]string, error) {
+		caughtUpTo = ap.lastSentSeq
+		return []string{`"catch-up"`}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	receive := func() PlanStreamEvent {
+		select {
+		case payload := <-events:
+			var event PlanStreamEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				t.Fatal(err)
+			}
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for stream event")
+		}
+		return PlanStreamEvent{}
+	}
+
+	event := receive()
+	if event.SubscriberId != "subscriber" || string(event.Message) != `"catch-up"` {
+		t.Fatalf("expected the catch-up message first, got %+v", event)
+	}
+
+	for seq := caughtUpTo + 1; seq <= 300; seq++ {
+		event := receive()
+		if string(event.Message) != strconv.FormatInt(seq, 10) {
+			t.Fatalf("expected seq %d, got %s", seq, event.Message)
+		}
+	}
+}
@@ -3,15 +3,36 @@ package types
 import (
 	"log"
 	"plandex-server/db"
+	"strings"
+
+	"github.com/plandex/plandex/shared"
 )
 
 type ServerAuth struct {
-	AuthToken   *db.AuthToken
+	// set for sign-in sessions
+	AuthToken *db.AuthToken
+	// set instead of AuthToken for requests made with an access token
+	AccessToken *db.AccessToken
 	User        *db.User
 	OrgId       string
 	Permissions map[Permission]bool
 }
 
+// CanAccessProject is false if the request was made with an access token that's limited to other projects
+func (a *ServerAuth) CanAccessProject(projectId string) bool {
+	if a.AccessToken == nil || len(a.AccessToken.ProjectIds) == 0 {
+		return true
+	}
+
+	for _, id := range a.AccessToken.ProjectIds {
+		if id == projectId {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (a *ServerAuth) HasPermission(permission Permission) bool {
 	if a.Permissions == nil {
 		return false
@@ -27,8 +48,42 @@ func (a *ServerAuth) HasPermission(permission Permission) bool {
 	return res
 }
 
+// HasProjectPermission is true if the user has the permission org-wide or just for this project, which custom roles allow
+func (a *ServerAuth) HasProjectPermission(permission Permission, projectId string) bool {
+	return a.HasPermission(permission) || a.HasPermission(permission.ForResource(projectId))
+}
+
 type Permission string
 
+// ForResource limits a permission to a resource: a project, or the org role of the users it can act on
+func (p Permission) ForResource(resourceId string) Permission {
+	return Permission(string(p) + "|" + resourceId)
+}
+
+// Base is the permission without its resource
+func (p Permission) Base() Permission {
+	base, _, _ := strings.Cut(string(p), "|")
+	return Permission(base)
+}
+
+// AccessTokenPermissions narrows a user's permissions to what an access token with the given scope may use. Read-only tokens get none, execute tokens can only create projects and plans (and act on plans they own), and admin tokens get all of the user's permissions.
+func AccessTokenPermissions(scope shared.AccessTokenScope, permissions map[Permission]bool) map[Permission]bool {
+	res := map[Permission]bool{}
+
+	for permission := range permissions {
+		switch scope {
+		case shared.AccessTokenScopeAdmin:
+			res[permission] = true
+		case shared.AccessTokenScopeExecute:
+			if base := permission.Base(); base == PermissionCreateProject || base == PermissionCreatePlan {
+				res[permission] = true
+			}
+		}
+	}
+
+	return res
+}
+
 const (
 	PermissionDeleteOrg             Permission = "delete_org"
 	PermissionManageEmailDomainAuth Permission = "manage_email_domain_auth"
@@ -37,6 +92,9 @@ const (
 	PermissionRemoveUser            Permission = "remove_user"
 	PermissionSetUserRole           Permission = "set_user_role"
 	PermissionListOrgRoles          Permission = "list_org_roles"
+	PermissionManageOrgRoles        Permission = "manage_org_roles"
+	PermissionReadAuditLogs         Permission = "read_audit_logs"
+	PermissionManageWebhooks        Permission = "manage_webhooks"
 	PermissionCreateProject         Permission = "create_project"
 	PermissionRenameAnyProject      Permission = "rename_any_project"
 	PermissionDeleteAnyProject      Permission = "delete_any_project"
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"plandex-server/db"
+	"testing"
+
+	"github.com/plandex/plandex/shared"
+)
+
+func TestAccessTokenPermissions(t *testing.T) {
+	userPermissions := map[Permission]bool{
+		PermissionCreateProject: true,
+		PermissionCreatePlan:    true,
+		PermissionInviteUser:    true,
+	}
+
+	tests := []struct {
+		scope    shared.AccessTokenScope
+		expected []Permission
+	}{
+		{shared.AccessTokenScopeRead, nil},
+		{shared.AccessTokenScopeExecute, []Permission{PermissionCreateProject, PermissionCreatePlan}},
+		{shared.AccessTokenScopeAdmin, []Permission{PermissionCreateProject, PermissionCreatePlan, PermissionInviteUser}},
+	}
+
+	for _, test := range tests {
+		res := AccessTokenPermissions(test.scope, userPermissions)
+		if len(res) != len(test.expected) {
+			t.Errorf("%s scope: expected %d permissions, got %v", test.scope, len(test.expected), res)
+		}
+		for _, permission := range test.expected {
+			if !res[permission] {
+				t.Errorf("%s scope: expected %s permission", test.scope, permission)
+			}
+		}
+	}
+
+	// a token never gets permissions its user doesn't have
+	res := AccessTokenPermissions(shared.AccessTokenScopeAdmin, map[Permission]bool{PermissionCreatePlan: true})
+	if res[PermissionInviteUser] {
+		t.Errorf("expected admin token not to gain invite_user permission")
+	}
+}
+
+func TestCanAccessProject(t *testing.T) {
+	signedIn := &ServerAuth{}
+	if !signedIn.CanAccessProject("p1") {
+		t.Errorf("expected signed in user to access any project")
+	}
+
+	unrestricted := &ServerAuth{AccessToken: &db.AccessToken{ProjectIds: db.StringList{}}}
+	if !unrestricted.CanAccessProject("p1") {
+		t.Errorf("expected token without projects to access any project")
+	}
+
+	restricted := &ServerAuth{AccessToken: &db.AccessToken{ProjectIds: db.StringList{"p1"}}}
+	if !restricted.CanAccessProject("p1") || restricted.CanAccessProject("p2") {
+		t.Errorf("expected token restricted to p1 to access only p1")
+	}
+}
+
+func TestHasProjectPermission(t *testing.T) {
+	auth := &ServerAuth{Permissions: map[Permission]bool{
+		PermissionCreateProject:                   true,
+		PermissionUpdateAnyPlan.ForResource("p1"): true,
+	}}
+
+	if !auth.HasProjectPermission(PermissionCreateProject, "p2") {
+		t.Errorf("expected org-wide permission to apply to any project")
+	}
+	if !auth.HasProjectPermission(PermissionUpdateAnyPlan, "p1") {
+		t.Errorf("expected project permission to apply to its project")
+	}
+	if auth.HasProjectPermission(PermissionUpdateAnyPlan, "p2") || auth.HasPermission(PermissionUpdateAnyPlan) {
+		t.Errorf("expected project permission not to apply elsewhere")
+	}
+
+	res := AccessTokenPermissions(shared.AccessTokenScopeExecute, map[Permission]bool{PermissionCreatePlan.ForResource("p1"): true})
+	if !res[PermissionCreatePlan.ForResource("p1")] {
+		t.Errorf("expected execute token to keep project-scoped create_plan permission")
+	}
+}
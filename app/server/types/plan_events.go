@@ -0,0 +1,60 @@
+package types
+
+import (
+	"encoding/json"
+	"plandex-server/db"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// An active plan runs on whichever instance started it. Other instances reach it over the event bus: they send PlanCommands on the plan's command channel, the owning instance answers each on the result channel, and it forwards stream messages on the stream channel while any other instance is connected.
+
+type PlanCommandType string
+
+const (
+	PlanCommandConnect            PlanCommandType = "connect"
+	PlanCommandDisconnect         PlanCommandType = "disconnect"
+	PlanCommandStop               PlanCommandType = "stop"
+	PlanCommandRespondMissingFile PlanCommandType = "respond_missing_file"
+)
+
+type PlanCommand struct {
+	Id     string          `json:"id"`
+	Type   PlanCommandType `json:"type"`
+	UserId string          `json:"userId"`
+	OrgId  string          `json:"orgId"`
+
+	// SubscriberId identifies a remote connection on connect and disconnect
+	SubscriberId string `json:"subscriberId,omitempty"`
+	// Resume asks a connect to replay the messages sent after LastSeq rather than catching up from scratch
+	Resume  bool  `json:"resume,omitempty"`
+	LastSeq int64 `json:"lastSeq,omitempty"`
+
+	MissingFileChoice  shared.RespondMissingFileChoice `json:"missingFileChoice,omitempty"`
+	MissingFileContext *db.Context                     `json:"missingFileContext,omitempty"`
+}
+
+type PlanCommandResult struct {
+	Id    string `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+type PlanStreamEvent struct {
+	// SubscriberId is set when a message is only for one connection--the catch-up messages sent on connect
+	SubscriberId string          `json:"subscriberId,omitempty"`
+	Message      json.RawMessage `json:"message,omitempty"`
+	// Done is sent when the active plan finishes, since remote connections can't watch its context
+	Done bool `json:"done,omitempty"`
+}
+
+func PlanCommandChannel(planId, branch string) string {
+	return "plan:" + planId + ":" + branch + ":commands"
+}
+
+func PlanResultChannel(planId, branch string) string {
+	return "plan:" + planId + ":" + branch + ":results"
+}
+
+func PlanStreamChannel(planId, branch string) string {
+	return "plan:" + planId + ":" + branch + ":stream"
+}
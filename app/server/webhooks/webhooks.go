@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"plandex-server/db"
+	"plandex-server/logging"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+// Outgoing webhooks for plan lifecycle events. Events are queued as deliveries in the database by db.QueueWebhookEvent, then sent from here by whichever instance gets to them first: right away when the event bus announces them, and otherwise on the next poll, which is also what picks up retries.
+
+const deliveryTimeout = 10 * time.Second
+const pollInterval = 15 * time.Second
+const dueBatchSize = 50
+
+// how long a claimed delivery is left to the instance sending it before others treat it as due again
+const claimLease = deliveryTimeout + 30*time.Second
+
+// the wait before each retry--a delivery fails for good once they're used up
+var retryBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour}
+
+// AllowPrivateUrls lets webhooks reach loopback, private and link-local addresses. It's off unless WEBHOOK_ALLOW_PRIVATE_URLS is set, so that org admins on a shared server can't use webhooks to make requests to the server's internal network. Local mode turns it on, since everything there is already on the user's own machine.
+var AllowPrivateUrls = os.Getenv("WEBHOOK_ALLOW_PRIVATE_URLS") != ""
+
+// ranges that aren't covered by net.IP's own checks but still shouldn't be reachable from a webhook
+var blockedNets = parseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "64:ff9b::/96")
+
+var client = &http.Client{
+	Timeout: deliveryTimeout,
+	Transport: &http.Transport{
+		// the address is checked after it's resolved, right before connecting, so a host can't pass the check and then rebind to a private address
+		DialContext:         (&net.Dialer{Timeout: deliveryTimeout, Control: checkDialAddress}).DialContext,
+		TLSHandshakeTimeout: deliveryTimeout,
+	},
+	// a redirect could lead anywhere, so receivers have to answer at the url they registered
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Start sends due deliveries in the background for as long as the process runs
+func Start() {
+	ch, _, err := db.SubscribeEvents(db.WebhookDeliveriesChannel)
+	if err != nil {
+		// polling still sends everything, just not right away
+		logging.Ctx(context.Background()).Errorf("Error subscribing to webhook deliveries: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			DeliverDue()
+
+			select {
+			case <-ch:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// DeliverDue sends every pending delivery whose next attempt is due
+func DeliverDue() {
+	for {
+		deliveries, err := db.ListDueWebhookDeliveries(dueBatchSize)
+		if err != nil {
+			logging.Ctx(context.Background()).Errorf("Error listing due webhook deliveries: %v", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery *db.WebhookDelivery) {
+				defer wg.Done()
+				_, err := Deliver(delivery)
+				if err != nil {
+					deliveryLog(delivery).Errorf("Error delivering webhook: %v", err)
+				}
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < dueBatchSize {
+			return
+		}
+	}
+}
+
+// Deliver claims a delivery, sends it, and records the result, scheduling a retry if it failed and has any left. It returns false without sending if another instance claimed the delivery first.
+func Deliver(delivery *db.WebhookDelivery) (bool, error) {
+	webhook, err := db.GetWebhook(delivery.OrgId, delivery.WebhookId)
+	if err != nil {
+		return false, err
+	}
+	if webhook == nil {
+		// deleted since the delivery was listed, taking the delivery with it
+		return false, nil
+	}
+
+	claimed, err := db.ClaimWebhookDelivery(delivery, time.Now().Add(claimLease))
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	responseStatus, sendErr := send(webhook, delivery)
+
+	delivery.ResponseStatus = nil
+	if responseStatus != 0 {
+		delivery.ResponseStatus = &responseStatus
+	}
+
+	if sendErr == nil {
+		delivery.Status = shared.WebhookDeliveryStatusSucceeded
+		delivery.Error = nil
+	} else {
+		deliveryLog(delivery).Warnf("Webhook delivery attempt %d failed: %v", delivery.Attempts, sendErr)
+
+		errStr := sendErr.Error()
+		delivery.Error = &errStr
+
+		// test deliveries report back to whoever sent them, so there's no point retrying
+		if delivery.Event == shared.WebhookEventTest || delivery.Attempts > len(retryBackoff) {
+			delivery.Status = shared.WebhookDeliveryStatusFailed
+			delivery.NextAttemptAt = nil
+		} else {
+			nextAttemptAt := time.Now().Add(retryBackoff[delivery.Attempts-1])
+			delivery.NextAttemptAt = &nextAttemptAt
+		}
+	}
+
+	return true, db.UpdateWebhookDeliveryAttempt(delivery)
+}
+
+func deliveryLog(delivery *db.WebhookDelivery) *logging.Logger {
+	return logging.Ctx(context.Background()).With("webhook_id", delivery.WebhookId, "delivery_id", delivery.Id, "org_id", delivery.OrgId, "event", delivery.Event)
+}
+
+func send(webhook *db.Webhook, delivery *db.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Plandex-Webhooks")
+	req.Header.Set(shared.WebhookEventHeader, string(delivery.Event))
+	req.Header.Set(shared.WebhookDeliveryHeader, delivery.Id)
+	req.Header.Set(shared.WebhookSignatureHeader, shared.SignWebhookPayload(webhook.Secret, time.Now(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+
+	// the response body isn't kept, since it would show whoever can see the webhook's deliveries whatever the url points at
+	return resp.StatusCode, fmt.Errorf("receiver responded with %d", resp.StatusCode)
+}
+
+// CheckUrl rejects webhook urls whose host is, or resolves to, a private address, so the mistake shows up when the webhook is created rather than on its first delivery. Deliveries check again when they connect, since what a host resolves to can change.
+func CheckUrl(rawUrl string) error {
+	if AllowPrivateUrls {
+		return nil
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing url: %v", err)
+	}
+
+	host := u.Hostname()
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ips, err = net.LookupIP(host)
+		if err != nil {
+			return fmt.Errorf("couldn't resolve %s: %v", host, err)
+		}
+	}
+
+	for _, ip := range ips {
+		if isPrivateIP(ip) {
+			return fmt.Errorf("%s is a private address, which webhooks can't be sent to", host)
+		}
+	}
+
+	return nil
+}
+
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if AllowPrivateUrls {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%s is a private address, which webhooks can't be sent to", host)
+	}
+
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return true
+	}
+
+	for _, ipNet := range blockedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var res []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, ipNet)
+	}
+	return res
+}
@@ -0,0 +1,345 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"plandex-server/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plandex/plandex/shared"
+)
+
+type receivedWebhook struct {
+	event      string
+	deliveryId string
+	payload    shared.WebhookPayload
+}
+
+// testReceiver is a local webhook receiver that verifies each delivery's signature and responds with status
+type testReceiver struct {
+	*httptest.Server
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	status   int
+	received []receivedWebhook
+}
+
+func newTestReceiver(t *testing.T) *testReceiver {
+	receiver := &testReceiver{t: t, status: http.StatusOK}
+
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading body: %v", err)
+		}
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+
+		err = shared.VerifyWebhookSignature(receiver.secret, r.Header.Get(shared.WebhookSignatureHeader), body, time.Now())
+		if err != nil {
+			t.Errorf("expected a valid signature: %v", err)
+		}
+
+		var payload shared.WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("error parsing payload: %v", err)
+		}
+
+		receiver.received = append(receiver.received, receivedWebhook{
+			event:      r.Header.Get(shared.WebhookEventHeader),
+			deliveryId: r.Header.Get(shared.WebhookDeliveryHeader),
+			payload:    payload,
+		})
+
+		w.WriteHeader(receiver.status)
+		if receiver.status >= 300 {
+			w.Write([]byte("unavailable"))
+		}
+	}))
+	t.Cleanup(receiver.Close)
+
+	return receiver
+}
+
+func (receiver *testReceiver) setStatus(status int) {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	receiver.status = status
+}
+
+func (receiver *testReceiver) numReceived() int {
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	return len(receiver.received)
+}
+
+// setupWebhook connects to a fresh sqlite database and registers a webhook for plan.finished pointing at a new receiver. The receiver is on loopback, so private urls are allowed unless the test turns them back off.
+func setupWebhook(t *testing.T) (*db.Webhook, *testReceiver) {
+	prevAllowPrivateUrls := AllowPrivateUrls
+	AllowPrivateUrls = true
+	t.Cleanup(func() {
+		AllowPrivateUrls = prevAllowPrivateUrls
+	})
+
+	prevBaseDir := db.BaseDir
+	db.BaseDir = t.TempDir()
+	t.Setenv("DATABASE_URL", "sqlite://"+filepath.Join(t.TempDir(), "plandex.db"))
+
+	if err := db.Connect(); err != nil {
+		t.Fatalf("error connecting: %v", err)
+	}
+	if err := db.MigrationsUp(); err != nil {
+		t.Fatalf("error running migrations: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Conn.Close()
+		db.BaseDir = prevBaseDir
+	})
+
+	tx, err := db.Conn.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	user := &db.User{Name: "Test", Email: "webhooks@example.com", Domain: "example.com"}
+	if err := db.CreateUser(user, tx); err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+
+	org, err := db.CreateOrg(&shared.CreateOrgRequest{Name: "Test Org"}, user.Id, nil, tx)
+	if err != nil {
+		t.Fatalf("error creating org: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	receiver := newTestReceiver(t)
+
+	webhook := &db.Webhook{OrgId: org.Id, CreatorId: user.Id, Url: receiver.URL, Events: db.StringList{string(shared.WebhookEventPlanFinished)}}
+	if err := db.CreateWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	receiver.secret = webhook.Secret
+
+	return webhook, receiver
+}
+
+// deliverNext makes the webhook's pending delivery due and delivers it
+func deliverNext(t *testing.T, webhook *db.Webhook) *db.WebhookDelivery {
+	_, err := db.Conn.Exec("UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE webhook_id = $2 AND status = $3", time.Now().UTC().Add(-time.Second), webhook.Id, shared.WebhookDeliveryStatusPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := db.ListDueWebhookDeliveries(10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("expected 1 due delivery, got %d (%v)", len(due), err)
+	}
+
+	delivered, err := Deliver(due[0])
+	if err != nil || !delivered {
+		t.Fatalf("expected delivery to be sent (%v)", err)
+	}
+
+	return due[0]
+}
+
+func TestDeliver(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+
+	err := db.QueueWebhookEvent(webhook.OrgId, shared.WebhookPayload{Event: shared.WebhookEventPlanFinished, PlanId: "plan", Status: shared.PlanStatusFinished})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := deliverNext(t, webhook)
+
+	if delivery.Status != shared.WebhookDeliveryStatusSucceeded || delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusOK || delivery.Error != nil {
+		t.Errorf("unexpected delivery %+v", delivery.ToApi())
+	}
+
+	if receiver.numReceived() != 1 {
+		t.Fatalf("expected 1 webhook received, got %d", receiver.numReceived())
+	}
+	received := receiver.received[0]
+	if received.event != string(shared.WebhookEventPlanFinished) || received.deliveryId != delivery.Id || received.payload.PlanId != "plan" || received.payload.OrgId != webhook.OrgId {
+		t.Errorf("unexpected webhook received %+v", received)
+	}
+
+	// a delivery that's already been sent isn't sent again
+	delivered, err := Deliver(delivery)
+	if err != nil || delivered {
+		t.Errorf("expected a sent delivery not to be sent again (%v)", err)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+	receiver.setStatus(http.StatusServiceUnavailable)
+
+	err := db.QueueWebhookEvent(webhook.OrgId, shared.WebhookPayload{Event: shared.WebhookEventPlanFinished})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each failed attempt waits out the next backoff
+	for i, backoff := range retryBackoff {
+		before := time.Now()
+		delivery := deliverNext(t, webhook)
+		after := time.Now()
+
+		if delivery.Status != shared.WebhookDeliveryStatusPending || delivery.Attempts != i+1 {
+			t.Fatalf("attempt %d: expected a pending delivery, got %+v", i+1, delivery.ToApi())
+		}
+		if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusServiceUnavailable || delivery.Error == nil {
+			t.Errorf("attempt %d: expected the failed response to be recorded, got %+v", i+1, delivery.ToApi())
+		}
+		if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(before.Add(backoff)) || delivery.NextAttemptAt.After(after.Add(backoff)) {
+			t.Errorf("attempt %d: expected a retry in %s, got %v", i+1, backoff, delivery.NextAttemptAt)
+		}
+	}
+
+	// the last retry fails for good
+	delivery := deliverNext(t, webhook)
+
+	if delivery.Status != shared.WebhookDeliveryStatusFailed || delivery.Attempts != len(retryBackoff)+1 || delivery.NextAttemptAt != nil {
+		t.Errorf("expected the delivery to fail after %d attempts, got %+v", len(retryBackoff)+1, delivery.ToApi())
+	}
+
+	due, err := db.ListDueWebhookDeliveries(10)
+	if err != nil || len(due) != 0 {
+		t.Errorf("expected no due deliveries, got %d (%v)", len(due), err)
+	}
+
+	if receiver.numReceived() != len(retryBackoff)+1 {
+		t.Errorf("expected %d webhooks received, got %d", len(retryBackoff)+1, receiver.numReceived())
+	}
+}
+
+func TestDeliverRetrySucceeds(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+	receiver.setStatus(http.StatusInternalServerError)
+
+	err := db.QueueWebhookEvent(webhook.OrgId, shared.WebhookPayload{Event: shared.WebhookEventPlanFinished})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliverNext(t, webhook)
+
+	receiver.setStatus(http.StatusNoContent)
+	delivery := deliverNext(t, webhook)
+
+	if delivery.Status != shared.WebhookDeliveryStatusSucceeded || delivery.Attempts != 2 || delivery.Error != nil || delivery.NextAttemptAt != nil || delivery.DeliveredAt == nil {
+		t.Errorf("expected the retry to succeed, got %+v", delivery.ToApi())
+	}
+}
+
+func TestDeliverTestEvent(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+	receiver.setStatus(http.StatusBadGateway)
+
+	delivery, err := db.CreateTestWebhookDelivery(webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivered, err := Deliver(delivery)
+	if err != nil || !delivered {
+		t.Fatalf("expected test delivery to be sent (%v)", err)
+	}
+
+	// test events report straight back to whoever sent them, so they aren't retried
+	if delivery.Status != shared.WebhookDeliveryStatusFailed || delivery.Attempts != 1 || delivery.NextAttemptAt != nil || delivery.Error == nil {
+		t.Errorf("expected the test delivery to fail without a retry, got %+v", delivery.ToApi())
+	}
+
+	if receiver.numReceived() != 1 || receiver.received[0].event != string(shared.WebhookEventTest) {
+		t.Errorf("expected a %s webhook to be received", shared.WebhookEventTest)
+	}
+}
+
+func TestDeliverPrivateUrl(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+	AllowPrivateUrls = false
+
+	delivery, err := db.CreateTestWebhookDelivery(webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivered, err := Deliver(delivery)
+	if err != nil || !delivered {
+		t.Fatalf("expected test delivery to be attempted (%v)", err)
+	}
+
+	if delivery.Status != shared.WebhookDeliveryStatusFailed || delivery.ResponseStatus != nil || delivery.Error == nil {
+		t.Errorf("expected the delivery to fail without connecting, got %+v", delivery.ToApi())
+	}
+
+	if receiver.numReceived() != 0 {
+		t.Errorf("expected no webhooks received, got %d", receiver.numReceived())
+	}
+}
+
+func TestDeliverRedirect(t *testing.T) {
+	webhook, receiver := setupWebhook(t)
+
+	redirected := newTestReceiver(t)
+	receiver.Config.Handler = http.RedirectHandler(redirected.URL, http.StatusTemporaryRedirect)
+
+	delivery, err := db.CreateTestWebhookDelivery(webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Deliver(delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != shared.WebhookDeliveryStatusFailed || delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusTemporaryRedirect {
+		t.Errorf("expected the redirect to be the response, got %+v", delivery.ToApi())
+	}
+
+	if redirected.numReceived() != 0 {
+		t.Errorf("expected the redirect not to be followed")
+	}
+}
+
+func TestCheckUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://93.184.216.34/hooks", true},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hooks", true},
+		{"http://127.0.0.1:8787", false},
+		{"http://localhost:8787", false},
+		{"http://[::1]/hooks", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.5/hooks", false},
+		{"http://192.168.1.10/hooks", false},
+		{"http://100.64.0.1/hooks", false},
+		{"http://0.0.0.0/hooks", false},
+		{"http://[::ffff:127.0.0.1]/hooks", false},
+		{"http://[fd00::1]/hooks", false},
+	}
+
+	for _, test := range tests {
+		err := CheckUrl(test.url)
+		if (err == nil) != test.allowed {
+			t.Errorf("%s: expected allowed to be %v, got error %v", test.url, test.allowed, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"fmt"
+	"time"
+)
+
+// Access tokens are long-lived, revocable credentials for automation like CI. Each belongs to a user in one org, can do no more than that user can, and is limited further by its scope and, optionally, to a set of projects.
+
+// AccessTokenPrefix starts every access token, which is how the server tells them apart from sign-in tokens
+const AccessTokenPrefix = "pdx_"
+
+type AccessTokenScope string
+
+// Scopes are cumulative: execute includes read, and admin includes execute
+const (
+	AccessTokenScopeRead    AccessTokenScope = "read"
+	AccessTokenScopeExecute AccessTokenScope = "execute"
+	AccessTokenScopeAdmin   AccessTokenScope = "admin"
+)
+
+var AccessTokenScopes = []AccessTokenScope{AccessTokenScopeRead, AccessTokenScopeExecute, AccessTokenScopeAdmin}
+
+var AccessTokenScopeDescriptions = map[AccessTokenScope]string{
+	AccessTokenScopeRead:    "Read-only access to plans, context, and org info",
+	AccessTokenScopeExecute: "Read, plus create and run plans: tell, build, apply, load context, branches, settings",
+	AccessTokenScopeAdmin:   "Everything the user can do, including managing org members, models, and default settings",
+}
+
+func (s AccessTokenScope) level() int {
+	for i, scope := range AccessTokenScopes {
+		if scope == s {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (s AccessTokenScope) Includes(other AccessTokenScope) bool {
+	return s.level() >= other.level() && other.level() > 0
+}
+
+func ParseAccessTokenScope(s string) (AccessTokenScope, error) {
+	scope := AccessTokenScope(s)
+	if scope.level() == 0 {
+		return "", fmt.Errorf("invalid scope %q, expected read, execute or admin", s)
+	}
+	return scope, nil
+}
+
+type AccessToken struct {
+	Id         string           `json:"id"`
+	OrgId      string           `json:"orgId"`
+	UserId     string           `json:"userId"`
+	Name       string           `json:"name"`
+	Scope      AccessTokenScope `json:"scope"`
+	ProjectIds []string         `json:"projectIds,omitempty"`
+	ExpiresAt  *time.Time       `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time       `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name  string           `json:"name"`
+	Scope AccessTokenScope `json:"scope"`
+	// empty means all of the org's projects
+	ProjectIds []string `json:"projectIds"`
+	// 0 means the token doesn't expire
+	ExpiresInDays int `json:"expiresInDays"`
+}
+
+type CreateAccessTokenResponse struct {
+	// only returned when the token is created--the server just stores its hash
+	Token       string       `json:"token"`
+	AccessToken *AccessToken `json:"accessToken"`
+}
@@ -0,0 +1,123 @@
+package shared
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionInviteCreate AuditAction = "invite.create"
+	AuditActionInviteDelete AuditAction = "invite.delete"
+	AuditActionInviteAccept AuditAction = "invite.accept"
+
+	AuditActionUserRemove  AuditAction = "user.remove"
+	AuditActionUserSetRole AuditAction = "user.set_role"
+
+	AuditActionRoleCreate AuditAction = "role.create"
+	AuditActionRoleUpdate AuditAction = "role.update"
+	AuditActionRoleDelete AuditAction = "role.delete"
+
+	AuditActionAccessTokenCreate AuditAction = "access_token.create"
+	AuditActionAccessTokenRevoke AuditAction = "access_token.revoke"
+
+	AuditActionWebhookCreate AuditAction = "webhook.create"
+	AuditActionWebhookDelete AuditAction = "webhook.delete"
+
+	AuditActionPlanCreate     AuditAction = "plan.create"
+	AuditActionPlanRename     AuditAction = "plan.rename"
+	AuditActionPlanDelete     AuditAction = "plan.delete"
+	AuditActionPlanDeleteAll  AuditAction = "plan.delete_all"
+	AuditActionPlanArchive    AuditAction = "plan.archive"
+	AuditActionPlanUnarchive  AuditAction = "plan.unarchive"
+	AuditActionPlanApply      AuditAction = "plan.apply"
+	AuditActionPlanUnapply    AuditAction = "plan.unapply"
+	AuditActionPlanRewind     AuditAction = "plan.rewind"
+	AuditActionPlanShare      AuditAction = "plan.share"
+	AuditActionPlanUnshare    AuditAction = "plan.unshare"
+	AuditActionBranchDelete   AuditAction = "branch.delete"
+	AuditActionDefaultsUpdate AuditAction = "settings.update_defaults"
+)
+
+type AuditEvent struct {
+	Id            string            `json:"id"`
+	OrgId         string            `json:"orgId"`
+	ActorId       string            `json:"actorId"`
+	ActorEmail    string            `json:"actorEmail"`
+	AccessTokenId string            `json:"accessTokenId,omitempty"`
+	Action        AuditAction       `json:"action"`
+	ResourceId    string            `json:"resourceId,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+	Ip            string            `json:"ip,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+}
+
+const AuditEventsDefaultLimit = 50
+const AuditEventsMaxLimit = 1000
+
+// ListAuditEventsParams are sent as query params. Events are listed newest first.
+type ListAuditEventsParams struct {
+	ActorEmail string
+	// an action like 'plan.delete', or a category like 'plan' for all of its actions
+	Action string
+	Since  *time.Time
+	Until  *time.Time
+	// the id of the last event from the previous page
+	Before string
+	Limit  int
+}
+
+func (p ListAuditEventsParams) Query() url.Values {
+	q := url.Values{}
+	if p.ActorEmail != "" {
+		q.Set("actor", p.ActorEmail)
+	}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	if p.Since != nil {
+		q.Set("since", p.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if p.Until != nil {
+		q.Set("until", p.Until.UTC().Format(time.RFC3339Nano))
+	}
+	if p.Before != "" {
+		q.Set("before", p.Before)
+	}
+	if p.Limit > 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+func ParseListAuditEventsParams(q url.Values) (ListAuditEventsParams, error) {
+	p := ListAuditEventsParams{
+		ActorEmail: q.Get("actor"),
+		Action:     q.Get("action"),
+		Before:     q.Get("before"),
+		Limit:      AuditEventsDefaultLimit,
+	}
+
+	for key, dest := range map[string]**time.Time{"since": &p.Since, "until": &p.Until} {
+		if v := q.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s time %q: %v", key, v, err)
+			}
+			*dest = &t
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return p, fmt.Errorf("invalid limit %q", v)
+		}
+		p.Limit = min(limit, AuditEventsMaxLimit)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,44 @@
+package shared
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestListAuditEventsParams(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)
+	params := ListAuditEventsParams{
+		ActorEmail: "dev@example.com",
+		Action:     "plan",
+		Since:      &since,
+		Before:     "event-1",
+		Limit:      20,
+	}
+
+	parsed, err := ParseListAuditEventsParams(params.Query())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.ActorEmail != params.ActorEmail || parsed.Action != params.Action || parsed.Before != params.Before || parsed.Limit != 20 || parsed.Until != nil {
+		t.Errorf("unexpected params %+v", parsed)
+	}
+	if parsed.Since == nil || !parsed.Since.Equal(since) {
+		t.Errorf("expected since %v, got %v", since, parsed.Since)
+	}
+
+	defaults, err := ParseListAuditEventsParams(url.Values{})
+	if err != nil || defaults.Limit != AuditEventsDefaultLimit {
+		t.Errorf("expected default limit, got %+v, %v", defaults, err)
+	}
+
+	capped, err := ParseListAuditEventsParams(url.Values{"limit": {"100000"}})
+	if err != nil || capped.Limit != AuditEventsMaxLimit {
+		t.Errorf("expected limit to be capped, got %+v, %v", capped, err)
+	}
+
+	if _, err := ParseListAuditEventsParams(url.Values{"since": {"yesterday"}}); err == nil {
+		t.Errorf("expected invalid time to fail")
+	}
+}
@@ -1,10 +1,26 @@
 package shared
 
+import "time"
+
 type AuthHeader struct {
 	Token string `json:"token"`
 	OrgId string `json:"orgId"`
 }
 
+// ClientVersionHeader is sent by the CLI on every request so sessions can show which version signed in
+const ClientVersionHeader = "X-Plandex-Client-Version"
+
+// Session is a sign-in token from the user's point of view. Tokens themselves are never returned--only their metadata.
+type Session struct {
+	Id            string     `json:"id"`
+	IsTrial       bool       `json:"isTrial"`
+	IsCurrent     bool       `json:"isCurrent"`
+	ClientVersion string     `json:"clientVersion,omitempty"`
+	Ip            string     `json:"ip,omitempty"`
+	LastUsedAt    *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
 type ApiErrorType string
 
 const (
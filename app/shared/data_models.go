@@ -59,6 +59,14 @@ type Plan struct {
 	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
+// SharedPlan is another org member's plan with the branches they've shared
+type SharedPlan struct {
+	Plan       *Plan    `json:"plan"`
+	OwnerName  string   `json:"ownerName"`
+	OwnerEmail string   `json:"ownerEmail"`
+	Branches   []string `json:"branches"`
+}
+
 type Branch struct {
 	Id              string     `json:"id"`
 	PlanId          string     `json:"planId"`
@@ -123,18 +131,19 @@ type ConvoSummary struct {
 }
 
 type ConvoMessageDescription struct {
-	Id                    string          `json:"id"`
-	ConvoMessageId        string          `json:"convoMessageId"`
-	SummarizedToMessageId string          `json:"summarizedToMessageId"`
-	MadePlan              bool            `json:"madePlan"`
-	CommitMsg             string          `json:"commitMsg"`
-	Files                 []string        `json:"files"`
-	DidBuild              bool            `json:"didBuild"`
-	BuildPathsInvalidated map[string]bool `json:"buildPathsInvalidated"`
-	Error                 string          `json:"error"`
-	AppliedAt             *time.Time      `json:"appliedAt,omitempty"`
-	CreatedAt             time.Time       `json:"createdAt"`
-	UpdatedAt             time.Time       `json:"updatedAt"`
+	Id                    string                      `json:"id"`
+	ConvoMessageId        string                      `json:"convoMessageId"`
+	SummarizedToMessageId string                      `json:"summarizedToMessageId"`
+	MadePlan              bool                        `json:"madePlan"`
+	CommitMsg             string                      `json:"commitMsg"`
+	Files                 []string                    `json:"files"`
+	DidBuild              bool                        `json:"didBuild"`
+	BuildPathsInvalidated map[string]bool             `json:"buildPathsInvalidated"`
+	BuildPathConflicts    map[string][]*MergeConflict `json:"buildPathConflicts,omitempty"`
+	Error                 string                      `json:"error"`
+	AppliedAt             *time.Time                  `json:"appliedAt,omitempty"`
+	CreatedAt             time.Time                   `json:"createdAt"`
+	UpdatedAt             time.Time                   `json:"updatedAt"`
 }
 
 type PlanBuild struct {
@@ -203,10 +212,12 @@ type CurrentPlanState struct {
 }
 
 type OrgRole struct {
-	Id          string `json:"id"`
-	IsDefault   bool   `json:"isDefault"`
-	Label       string `json:"label"`
-	Description string `json:"description"`
+	Id          string               `json:"id"`
+	Name        string               `json:"name"`
+	IsDefault   bool                 `json:"isDefault"`
+	Label       string               `json:"label"`
+	Description string               `json:"description"`
+	Permissions []*OrgRolePermission `json:"permissions,omitempty"`
 }
 
 type ModelCompatibility struct {
@@ -0,0 +1,51 @@
+package shared
+
+import "strings"
+
+// LineHunk is a changed region between two versions of a file. Line indexes are 0-based and ranges are half-open, so a pure insertion has OldStart == OldEnd and a pure deletion has NewStart == NewEnd.
+type LineHunk struct {
+	OldStart int `json:"oldStart"`
+	OldEnd   int `json:"oldEnd"`
+	NewStart int `json:"newStart"`
+	NewEnd   int `json:"newEnd"`
+}
+
+// SplitLines splits s into lines, keeping the trailing newline on each line so that joining the lines gives back s.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// DiffLineHunks returns the changed regions between a and b. It uses the same Myers diff and change compaction as 'git diff', so hunks line up with what git would show.
+func DiffLineHunks(a, b []string) []LineHunk {
+	aChanged, bChanged := diffLineChanges(a, b)
+
+	var hunks []LineHunk
+	x, y := 0, 0
+	for x < len(a) || y < len(b) {
+		if x < len(a) && y < len(b) && !aChanged[x] && !bChanged[y] {
+			x++
+			y++
+			continue
+		}
+
+		hunk := LineHunk{OldStart: x, NewStart: y}
+		for x < len(a) && aChanged[x] {
+			x++
+		}
+		for y < len(b) && bChanged[y] {
+			y++
+		}
+		hunk.OldEnd = x
+		hunk.NewEnd = y
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks
+}
@@ -0,0 +1,474 @@
+package shared
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formats commits as a 'git format-patch' series. The output matches what git writes for the same commits, apart from binary files, which are shown as 'Binary files differ' rather than as a binary patch.
+
+const (
+	patchMailWrap    = 72 // diffstat width
+	patchHeaderWrap  = 78
+	patchEncodedWrap = 76
+	patchNameMax     = 64
+	patchNameSuffix  = ".patch"
+)
+
+type PatchCommit struct {
+	Sha         string
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+	Message     string
+	Diffs       []*FileDiff
+}
+
+func FormatPatches(commits []*PatchCommit, signature string) []*PlanPatch {
+	var patches []*PlanPatch
+	for i, commit := range commits {
+		nr := i + 1
+		subject, body := splitCommitMsg(commit.Message)
+
+		var sb strings.Builder
+		sb.WriteString("From " + commit.Sha + " Mon Sep 17 00:00:00 2001\n")
+		writePatchFrom(&sb, commit.AuthorName, commit.AuthorEmail)
+		sb.WriteString("Date: " + commit.Date.Format("Mon, 2 Jan 2006 15:04:05 -0700") + "\n")
+
+		prefix := "Subject: [PATCH] "
+		if len(commits) > 1 {
+			digits := len(strconv.Itoa(len(commits)))
+			prefix = fmt.Sprintf("Subject: [PATCH %0*d/%d] ", digits, nr, len(commits))
+		}
+		// a multi-line subject is joined onto one line, then folded to fit the header
+		title := strings.ReplaceAll(subject, "\n", " ")
+		sb.WriteString(prefix)
+		if needsRfc2047(title) {
+			writeRfc2047(&sb, title, len(prefix), false)
+		} else {
+			sb.WriteString(wrapHeaderText(title, -len(prefix), 1, patchHeaderWrap))
+		}
+		sb.WriteString("\n")
+
+		if hasNonAscii(commit.Message) {
+			sb.WriteString("MIME-Version: 1.0\nContent-Type: text/plain; charset=UTF-8\nContent-Transfer-Encoding: 8bit\n")
+		}
+
+		sb.WriteString("\n")
+		if body = strings.TrimRight(body, " \t\n\r"); body != "" {
+			sb.WriteString(body + "\n")
+		}
+
+		sb.WriteString("---\n")
+		writeDiffStat(&sb, commit.Diffs)
+		for _, diff := range commit.Diffs {
+			if diff.IsNew {
+				sb.WriteString(" create mode 100644 " + quoteDiffPath(diff.NewPath) + "\n")
+			}
+		}
+		sb.WriteString("\n")
+		sb.WriteString(FormatFileDiffs(commit.Diffs, false))
+		sb.WriteString("-- \n" + signature + "\n\n")
+
+		patches = append(patches, &PlanPatch{
+			Name: patchFileName(nr, strings.SplitN(subject, "\n", 2)[0]),
+			Body: sb.String(),
+		})
+	}
+	return patches
+}
+
+// splitCommitMsg splits a message into a subject, which is its first paragraph, and a body. Trailing whitespace is stripped from every line, as git does when printing messages.
+func splitCommitMsg(msg string) (subject, body string) {
+	lines := strings.SplitAfter(msg, "\n")
+
+	i := 0
+	for i < len(lines) && isPatchBlankLine(lines[i]) {
+		i++
+	}
+
+	var subjectLines []string
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\n\r\v\f")
+		if line == "" {
+			i++
+			break
+		}
+		subjectLines = append(subjectLines, line)
+	}
+
+	var sb strings.Builder
+	started := false
+	for ; i < len(lines); i++ {
+		if lines[i] == "" {
+			continue
+		}
+		line := strings.TrimRight(lines[i], " \t\n\r\v\f")
+		if line == "" && !started {
+			continue
+		}
+		started = true
+		sb.WriteString(line + "\n")
+	}
+
+	return strings.Join(subjectLines, "\n"), sb.String()
+}
+
+func isPatchBlankLine(line string) bool {
+	return strings.TrimRight(line, " \t\n\r\v\f") == "" && line != ""
+}
+
+// patchFileName builds names like '0001-Fix-the-thing.patch' from the subject's first line, keeping runs of letters, digits, '.' and '_'
+func patchFileName(nr int, subject string) string {
+	var sb strings.Builder
+	space := 2
+	for i := 0; i < len(subject); i++ {
+		c := subject[i]
+		if isPatchTitleChar(c) {
+			if space == 1 {
+				sb.WriteByte('-')
+			}
+			space = 0
+			sb.WriteByte(c)
+			if c == '.' {
+				for i+1 < len(subject) && subject[i+1] == '.' {
+					i++
+				}
+			}
+		} else {
+			space |= 1
+		}
+	}
+
+	name := fmt.Sprintf("%04d-%s", nr, strings.TrimRight(sb.String(), ".-"))
+
+	maxLen := patchNameMax - (len(patchNameSuffix) + 1)
+	if len(name) > maxLen {
+		name = name[:maxLen]
+	}
+
+	return name + patchNameSuffix
+}
+
+func isPatchTitleChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '.' || c == '_'
+}
+
+func writePatchFrom(sb *strings.Builder, name, email string) {
+	sb.WriteString("From: ")
+
+	maxLength := patchHeaderWrap
+	if needsRfc2047(name) {
+		writeRfc2047(sb, name, len("From: "), true)
+		maxLength = patchEncodedWrap
+	} else if strings.ContainsAny(name, `()<>@,;:\".[]`) {
+		quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+		sb.WriteString(wrapHeaderText(quoted, -len("From: "), 1, maxLength))
+	} else {
+		sb.WriteString(wrapHeaderText(name, -len("From: "), 1, maxLength))
+	}
+
+	if maxLength < lastLineLength(sb.String())+len(" <")+len(email)+len(">") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(" <" + email + ">\n")
+}
+
+func lastLineLength(s string) int {
+	return len(s) - (strings.LastIndexByte(s, '\n') + 1)
+}
+
+func hasNonAscii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 || s[i] == 0x1b {
+			return true
+		}
+	}
+	return false
+}
+
+func needsRfc2047(s string) bool {
+	return hasNonAscii(s) || strings.Contains(s, "\n") || strings.Contains(s, "=?")
+}
+
+// writeRfc2047 q-encodes s as one or more encoded words, breaking lines so none exceeds 76 characters. Addresses restrict which characters can be left as-is.
+func writeRfc2047(sb *strings.Builder, s string, lineLen int, isAddress bool) {
+	const open = "=?UTF-8?q?"
+	sb.WriteString(open)
+	lineLen += len(open)
+
+	for len(s) > 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		chr := s[:size]
+		s = s[size:]
+
+		isSpecial := size > 1 || isRfc2047Special(chr[0], isAddress)
+		encodedLen := 1
+		if isSpecial {
+			encodedLen = 3 * size
+		}
+
+		if lineLen+encodedLen+2 > patchEncodedWrap {
+			sb.WriteString("?=\n " + open)
+			lineLen = len(open) + 1
+		}
+
+		if isSpecial {
+			for i := 0; i < size; i++ {
+				fmt.Fprintf(sb, "=%02X", chr[i])
+			}
+		} else {
+			sb.WriteString(chr)
+		}
+		lineLen += encodedLen
+	}
+
+	sb.WriteString("?=")
+}
+
+func isRfc2047Special(c byte, isAddress bool) bool {
+	if c >= 0x7f || c < 0x20 || c == 0x1b {
+		return true
+	}
+	if c == ' ' || c == '=' || c == '?' || c == '_' {
+		return true
+	}
+	if !isAddress {
+		return false
+	}
+	isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	return !(isAlnum || c == '!' || c == '*' || c == '+' || c == '-' || c == '/')
+}
+
+// wrapHeaderText folds text at spaces so lines stay within width, continuing with indent2 spaces. A negative indent1 is the length of what's already on the first line.
+func wrapHeaderText(text string, indent1, indent2, width int) string {
+	var sb strings.Builder
+
+	bol := 0
+	w, indent := indent1, indent1
+	space := -1
+	if indent < 0 {
+		w = -indent
+		space = 0
+	}
+
+	i := 0
+	for {
+		var c byte
+		if i < len(text) {
+			c = text[i]
+		}
+
+		if c == 0 || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if w <= width || space < 0 {
+				start := bol
+				if c == 0 && i == start {
+					return sb.String()
+				}
+				if space >= 0 {
+					start = space
+				} else {
+					sb.WriteString(strings.Repeat(" ", indent))
+				}
+				sb.WriteString(text[start:i])
+				if c == 0 {
+					return sb.String()
+				}
+				space = i
+				if c == '\t' {
+					w |= 0x07
+				}
+				w++
+				i++
+			} else {
+				sb.WriteString("\n")
+				i = space
+				if i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\n' || text[i] == '\r') {
+					i++
+				}
+				bol = i
+				space = -1
+				w, indent = indent2, indent2
+			}
+			continue
+		}
+
+		if c < 0x20 || c == 0x7f {
+			w--
+		} else {
+			w++
+		}
+		i++
+	}
+}
+
+// writeDiffStat writes the diffstat that 'git format-patch' puts after the '---' line, scaling names and graphs to fit 72 columns
+func writeDiffStat(sb *strings.Builder, diffs []*FileDiff) {
+	if len(diffs) == 0 {
+		return
+	}
+
+	type statFile struct {
+		name           string
+		added, deleted int
+		isBinary       bool
+	}
+
+	var files []*statFile
+	maxLen, maxChange, numberWidth, binWidth := 0, 0, 0, 0
+	for _, diff := range diffs {
+		file := &statFile{name: quoteDiffPath(diff.NewPath), isBinary: diff.IsBinary}
+		if diff.IsBinary {
+			file.added = diff.newSize
+			file.deleted = diff.oldSize
+		} else {
+			for _, hunk := range diff.Hunks {
+				for _, line := range hunk.Lines {
+					switch line.Type {
+					case DiffLineAdded:
+						file.added++
+					case DiffLineRemoved:
+						file.deleted++
+					}
+				}
+			}
+		}
+		files = append(files, file)
+
+		if len(file.name) > maxLen {
+			maxLen = len(file.name)
+		}
+
+		if file.isBinary {
+			// "Bin XXX -> YYY bytes"
+			w := 14 + len(strconv.Itoa(file.added)) + len(strconv.Itoa(file.deleted))
+			if w > binWidth {
+				binWidth = w
+			}
+			numberWidth = 3
+			continue
+		}
+
+		if change := file.added + file.deleted; change > maxChange {
+			maxChange = change
+		}
+	}
+
+	if w := len(strconv.Itoa(maxChange)); w > numberWidth {
+		numberWidth = w
+	}
+
+	width := patchMailWrap
+	if width < 16+6+numberWidth {
+		width = 16 + 6 + numberWidth
+	}
+
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+	nameWidth := maxLen
+
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = width*3/8 - numberWidth - 6
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	adds, dels := 0, 0
+	for _, file := range files {
+		prefix := ""
+		name := file.name
+		length := nameWidth
+		if nameWidth < len(name) {
+			prefix = "..."
+			length -= 3
+			if length < 0 {
+				length = 0
+			}
+			name = name[len(name)-length:]
+			if slash := strings.IndexByte(name, '/'); slash != -1 {
+				name = name[slash:]
+			}
+		}
+		padding := length - len(name)
+		if padding < 0 {
+			padding = 0
+		}
+
+		if file.isBinary {
+			fmt.Fprintf(sb, " %s%s%*s | %*s", prefix, name, padding, "", numberWidth, "Bin")
+			if file.added == 0 && file.deleted == 0 {
+				sb.WriteString("\n")
+			} else {
+				fmt.Fprintf(sb, " %d -> %d bytes\n", file.deleted, file.added)
+			}
+			continue
+		}
+
+		add, del := file.added, file.deleted
+		adds += add
+		dels += del
+
+		if graphWidth <= maxChange {
+			total := scaleLinear(add+del, graphWidth, maxChange)
+			if total < 2 && add > 0 && del > 0 {
+				total = 2
+			}
+			if add < del {
+				add = scaleLinear(add, graphWidth, maxChange)
+				del = total - add
+			} else {
+				del = scaleLinear(del, graphWidth, maxChange)
+				add = total - del
+			}
+		}
+
+		sep := ""
+		if file.added+file.deleted > 0 {
+			sep = " "
+		}
+		fmt.Fprintf(sb, " %s%s%*s | %*d%s%s%s\n", prefix, name, padding, "", numberWidth, file.added+file.deleted, sep, strings.Repeat("+", add), strings.Repeat("-", del))
+	}
+
+	if len(files) == 1 {
+		sb.WriteString(" 1 file changed")
+	} else {
+		fmt.Fprintf(sb, " %d files changed", len(files))
+	}
+	if adds > 0 || dels == 0 {
+		if adds == 1 {
+			sb.WriteString(", 1 insertion(+)")
+		} else {
+			fmt.Fprintf(sb, ", %d insertions(+)", adds)
+		}
+	}
+	if dels > 0 || adds == 0 {
+		if dels == 1 {
+			sb.WriteString(", 1 deletion(-)")
+		} else {
+			fmt.Fprintf(sb, ", %d deletions(-)", dels)
+		}
+	}
+	sb.WriteString("\n")
+}
+
+// scaleLinear scales as if the graph were a column narrower, then adds one, so any change shows at least one '+' or '-'
+func scaleLinear(it, width, maxChange int) int {
+	if it == 0 {
+		return 0
+	}
+	return 1 + (it * (width - 1) / maxChange)
+}
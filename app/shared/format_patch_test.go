@@ -0,0 +1,155 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatPatchesMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	type testCommit struct {
+		name, email, msg string
+		files            map[string]string
+	}
+
+	longPath := "some/deeply/nested/directory/structure/that/is/quite/long/file_name.go"
+	var manyLines strings.Builder
+	for i := 0; i < 120; i++ {
+		fmt.Fprintf(&manyLines, "line %d\n", i)
+	}
+
+	commits := []testCommit{
+		{"Test User", "test@example.com", "Add the parser\n\nParses things.\n  Indented\twith tab   \n\n\nSecond paragraph\n", map[string]string{
+			"parser.go": "package parser\n\nfunc Parse() {}\n",
+			"empty.txt": "",
+		}},
+		{"O'Brien, Pat", "pat@example.com", "Zoë's change: ümlauts in the subject\n", map[string]string{
+			"parser.go": "package parser\n\nfunc Parse() error {\n\treturn nil\n}\n",
+		}},
+		{"Zoë Smith", "zoe@example.com", "A subject that runs over two lines\nbecause it keeps going and going past the point where git would wrap it\n\nbody\n", map[string]string{
+			longPath:    manyLines.String(),
+			"short.txt": "x\n",
+		}},
+		{"Test User", "test@example.com", "Handle =?encoded?= words and .. dots...\n", map[string]string{
+			longPath:    strings.Replace(manyLines.String(), "line 5\n", "line five\n", 1),
+			"short.txt": "x\ny\n",
+		}},
+		{"A Very Long Author Name That Goes On And On For Quite A While Indeed Yes", "long.author.address@example.com", "Only a subject", map[string]string{
+			"short.txt": "",
+		}},
+	}
+	for i := 0; i < 7; i++ {
+		commits = append(commits, testCommit{"Test User", "test@example.com", fmt.Sprintf("Step %d\n", i), map[string]string{
+			"steps.txt": strings.Repeat("step\n", i+1),
+		}})
+	}
+
+	dir := t.TempDir()
+	date := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+	run := func(env []string, args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1"), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+
+	run(nil, "init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(nil, "add", "-A")
+	run(nil, "-c", "user.name=base", "-c", "user.email=base@test", "commit", "-q", "-m", "base")
+
+	current := map[string]string{}
+	var patchCommits []*PatchCommit
+	for i, commit := range commits {
+		at := date.Add(time.Duration(i) * time.Hour)
+
+		original := map[string]string{}
+		for path, body := range commit.files {
+			if prev, ok := current[path]; ok {
+				original[path] = prev
+			}
+			full := filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(body), 0644); err != nil {
+				t.Fatal(err)
+			}
+			current[path] = body
+		}
+
+		msgPath := filepath.Join(t.TempDir(), "msg")
+		if err := os.WriteFile(msgPath, []byte(commit.msg), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		dateStr := strconv.FormatInt(at.Unix(), 10) + " +0000"
+		run(nil, "add", "-A")
+		run([]string{
+			"GIT_AUTHOR_NAME=" + commit.name, "GIT_AUTHOR_EMAIL=" + commit.email, "GIT_AUTHOR_DATE=" + dateStr,
+			"GIT_COMMITTER_NAME=" + commit.name, "GIT_COMMITTER_EMAIL=" + commit.email, "GIT_COMMITTER_DATE=" + dateStr,
+		}, "commit", "-q", "--cleanup=verbatim", "-F", msgPath)
+
+		patchCommits = append(patchCommits, &PatchCommit{
+			Sha:         strings.TrimSpace(run(nil, "rev-parse", "HEAD")),
+			AuthorName:  commit.name,
+			AuthorEmail: commit.email,
+			Date:        at,
+			Message:     commit.msg,
+			Diffs:       DiffFiles(original, commit.files),
+		})
+	}
+
+	outDir := t.TempDir()
+	run(nil, "format-patch", "-q", "--no-color", "--signature=plandex", "-o", outDir, "HEAD~"+strconv.Itoa(len(commits)))
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantNames []string
+	for _, entry := range entries {
+		wantNames = append(wantNames, entry.Name())
+	}
+	sort.Strings(wantNames)
+
+	got := FormatPatches(patchCommits, "plandex")
+	if len(got) != len(wantNames) {
+		t.Fatalf("got %d patches, git wrote %d", len(got), len(wantNames))
+	}
+
+	for i, patch := range got {
+		if patch.Name != wantNames[i] {
+			t.Fatalf("patch %d: got name %q, git has %q", i+1, patch.Name, wantNames[i])
+		}
+
+		want, err := os.ReadFile(filepath.Join(outDir, wantNames[i]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patch.Body != string(want) {
+			t.Fatalf("patch %d differs from git\n--- got:\n%s\n--- want:\n%s", i+1, patch.Body, want)
+		}
+	}
+
+	single := FormatPatches(patchCommits[:1], "plandex")
+	if !strings.Contains(single[0].Body, "Subject: [PATCH] Add the parser\n") {
+		t.Fatalf("expected an unnumbered subject for a single patch, got:\n%s", single[0].Body)
+	}
+}
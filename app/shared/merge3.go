@@ -0,0 +1,161 @@
+package shared
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type MergeConflict struct {
+	StartLine int    `json:"startLine"` // 1-based line in the merged file where the conflicted region starts
+	Base      string `json:"base"`
+	Ours      string `json:"ours"`
+	Theirs    string `json:"theirs"`
+}
+
+type Merge3Result struct {
+	Merged    string           `json:"merged"` // conflicted regions keep 'ours'
+	Conflicts []*MergeConflict `json:"conflicts"`
+}
+
+func (r *Merge3Result) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// Merge3 merges the changes from base → ours and base → theirs line by line. Changes that touch overlapping (or adjacent) regions of base are conflicts unless both sides made the same change.
+func Merge3(base, ours, theirs string) *Merge3Result {
+	baseLines := SplitLines(base)
+	ourLines := SplitLines(ours)
+	theirLines := SplitLines(theirs)
+
+	type sideHunk struct {
+		LineHunk
+		ours bool
+	}
+
+	var all []sideHunk
+	for _, h := range DiffLineHunks(baseLines, ourLines) {
+		all = append(all, sideHunk{h, true})
+	}
+	for _, h := range DiffLineHunks(baseLines, theirLines) {
+		all = append(all, sideHunk{h, false})
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].OldStart < all[j].OldStart
+	})
+
+	// text of base[start:end] as changed by the given hunks from one side
+	sideText := func(lines []string, hunks []LineHunk, start, end int) string {
+		var sb strings.Builder
+		pos := start
+		for _, h := range hunks {
+			sb.WriteString(strings.Join(baseLines[pos:h.OldStart], ""))
+			sb.WriteString(strings.Join(lines[h.NewStart:h.NewEnd], ""))
+			pos = h.OldEnd
+		}
+		sb.WriteString(strings.Join(baseLines[pos:end], ""))
+		return sb.String()
+	}
+
+	res := &Merge3Result{}
+	var merged strings.Builder
+	mergedLine := 1
+	write := func(s string) {
+		merged.WriteString(s)
+		mergedLine += strings.Count(s, "\n")
+	}
+
+	pos := 0
+	for i := 0; i < len(all); {
+		// group every hunk whose base range overlaps or touches the current region
+		start, end := all[i].OldStart, all[i].OldEnd
+		var ourHunks, theirHunks []LineHunk
+		j := i
+		for j < len(all) && all[j].OldStart <= end {
+			if all[j].OldEnd > end {
+				end = all[j].OldEnd
+			}
+			if all[j].ours {
+				ourHunks = append(ourHunks, all[j].LineHunk)
+			} else {
+				theirHunks = append(theirHunks, all[j].LineHunk)
+			}
+			j++
+		}
+		i = j
+
+		write(strings.Join(baseLines[pos:start], ""))
+		pos = end
+
+		ourText := sideText(ourLines, ourHunks, start, end)
+
+		if len(theirHunks) == 0 {
+			write(ourText)
+			continue
+		}
+
+		theirText := sideText(theirLines, theirHunks, start, end)
+
+		if len(ourHunks) == 0 || ourText == theirText {
+			write(theirText)
+			continue
+		}
+
+		res.Conflicts = append(res.Conflicts, &MergeConflict{
+			StartLine: mergedLine,
+			Base:      strings.Join(baseLines[start:end], ""),
+			Ours:      ourText,
+			Theirs:    theirText,
+		})
+		write(ourText)
+	}
+
+	write(strings.Join(baseLines[pos:], ""))
+	res.Merged = merged.String()
+
+	return res
+}
+
+// ReplacementsForUpdate returns replacements that turn from into to. They cover from sequentially, so each one applies at the position where the previous one ended.
+func ReplacementsForUpdate(from, to string) []*Replacement {
+	fromLines := SplitLines(from)
+	toLines := SplitLines(to)
+
+	var replacements []*Replacement
+	fromPos, toPos := 0, 0
+
+	for _, h := range DiffLineHunks(fromLines, toLines) {
+		replacements = append(replacements, &Replacement{
+			Id:  uuid.New().String(),
+			Old: strings.Join(fromLines[fromPos:h.OldEnd], ""),
+			New: strings.Join(toLines[toPos:h.NewEnd], ""),
+		})
+		fromPos = h.OldEnd
+		toPos = h.NewEnd
+	}
+
+	return replacements
+}
+
+// MergeContextUpdates three-way merges updated context files into the pending plan for every path where the pending replacements no longer apply to the updated file. The original context body is the base, the updated file is ours, and the plan's version is theirs. Paths that can't be merged at all (no original context or no plan version) are returned with a nil result.
+func (state *CurrentPlanState) MergeContextUpdates(filesByPath map[string]string) map[string]*Merge3Result {
+	conflictedPaths := state.PlanResult.FileResultsByPath.ConflictedPaths(filesByPath)
+
+	res := make(map[string]*Merge3Result, len(conflictedPaths))
+
+	for path := range conflictedPaths {
+		context := state.ContextsByPath[path]
+		planFile, ok := state.CurrentPlanFiles.Files[path]
+
+		if context == nil || !ok {
+			res[path] = nil
+			continue
+		}
+
+		res[path] = Merge3(context.Body, filesByPath[path], planFile)
+	}
+
+	return res
+}
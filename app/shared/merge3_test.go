@@ -0,0 +1,48 @@
+package shared
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\nf\n"
+
+	t.Run("non-overlapping changes merge cleanly", func(t *testing.T) {
+		res := Merge3(base, "a\nB\nc\nd\ne\nf\n", "a\nb\nc\nd\nE\nf\nnew\n")
+		if res.HasConflicts() {
+			t.Fatalf("unexpected conflicts: %v", res.Conflicts)
+		}
+		if want := "a\nB\nc\nd\nE\nf\nnew\n"; res.Merged != want {
+			t.Fatalf("Merged = %q, want %q", res.Merged, want)
+		}
+	})
+
+	t.Run("identical changes merge cleanly", func(t *testing.T) {
+		res := Merge3(base, "a\nB\nc\nd\ne\nf\n", "a\nB\nc\nd\ne\nf\n")
+		if res.HasConflicts() || res.Merged != "a\nB\nc\nd\ne\nf\n" {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("overlapping changes conflict", func(t *testing.T) {
+		res := Merge3(base, "a\nb\nOURS\nd\ne\nf\n", "a\nb\nTHEIRS\nd\ne\nf\n")
+		if len(res.Conflicts) != 1 {
+			t.Fatalf("expected 1 conflict, got %d", len(res.Conflicts))
+		}
+		c := res.Conflicts[0]
+		if c.StartLine != 3 || c.Base != "c\n" || c.Ours != "OURS\n" || c.Theirs != "THEIRS\n" {
+			t.Fatalf("unexpected conflict: %+v", c)
+		}
+	})
+}
+
+func TestReplacementsForUpdate(t *testing.T) {
+	from := "package main\n\nfunc a() {}\n\nfunc b() {}\n"
+	to := "package main\n\nimport \"fmt\"\n\nfunc a() { fmt.Println() }\n\nfunc b() {}\nfunc c() {}\n"
+
+	updated, ok := ApplyReplacements(from, ReplacementsForUpdate(from, to), false)
+	if !ok {
+		t.Fatal("replacements failed to apply")
+	}
+	if updated != to {
+		t.Fatalf("updated = %q, want %q", updated, to)
+	}
+}
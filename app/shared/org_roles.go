@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultOrgRoleNames are the built-in roles every org has. Custom roles can't reuse these names.
+var DefaultOrgRoleNames = []string{"owner", "billing_admin", "admin", "member"}
+
+type OrgRolePermission struct {
+	Permission string `json:"permission"`
+	// a project id for permissions limited to one project, or an org role id for permissions over users with that role--empty means the permission applies org-wide
+	ResourceId string `json:"resourceId,omitempty"`
+}
+
+type CustomRolePermission struct {
+	Name        string
+	Description string
+	// whether the permission can be limited to a single project
+	ProjectScoped bool
+}
+
+// CustomRolePermissions are the permissions that can be given to custom roles. Permissions over the org itself (billing, deleting the org, email domain auth, managing roles) stay with the built-in roles.
+var CustomRolePermissions = []CustomRolePermission{
+	{Name: "list_org_roles", Description: "List org roles"},
+	{Name: "create_project", Description: "Create a project"},
+	{Name: "rename_any_project", Description: "Rename a project", ProjectScoped: true},
+	{Name: "delete_any_project", Description: "Delete a project", ProjectScoped: true},
+	{Name: "create_plan", Description: "Create and run plans", ProjectScoped: true},
+	{Name: "manage_any_plan_shares", Description: "Unshare a plan any user shared", ProjectScoped: true},
+	{Name: "rename_any_plan", Description: "Rename any user's plan", ProjectScoped: true},
+	{Name: "delete_any_plan", Description: "Delete any user's plan", ProjectScoped: true},
+	{Name: "update_any_plan", Description: "Run and update any user's plan", ProjectScoped: true},
+	{Name: "archive_any_plan", Description: "Archive any user's plan", ProjectScoped: true},
+}
+
+func GetCustomRolePermission(name string) *CustomRolePermission {
+	for i := range CustomRolePermissions {
+		if CustomRolePermissions[i].Name == name {
+			return &CustomRolePermissions[i]
+		}
+	}
+	return nil
+}
+
+var orgRoleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+// ValidateCustomRole checks a custom role's name and permissions. It doesn't check that project ids belong to the org.
+func ValidateCustomRole(name string, permissions []*OrgRolePermission) error {
+	if !orgRoleNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid role name %q--use lowercase letters, numbers, dashes, and underscores", name)
+	}
+
+	for _, defaultName := range DefaultOrgRoleNames {
+		if name == defaultName {
+			return fmt.Errorf("%q is a built-in role", name)
+		}
+	}
+
+	return ValidateCustomRolePermissions(permissions)
+}
+
+func ValidateCustomRolePermissions(permissions []*OrgRolePermission) error {
+	seen := map[OrgRolePermission]bool{}
+
+	for _, permission := range permissions {
+		customPermission := GetCustomRolePermission(permission.Permission)
+		if customPermission == nil {
+			return fmt.Errorf("permission %q can't be given to a custom role", permission.Permission)
+		}
+
+		if permission.ResourceId != "" && !customPermission.ProjectScoped {
+			return fmt.Errorf("permission %q can't be limited to a project", permission.Permission)
+		}
+
+		if seen[*permission] {
+			return fmt.Errorf("permission %q is listed more than once", permission.Permission)
+		}
+		seen[*permission] = true
+	}
+
+	return nil
+}
+
+type CreateOrgRoleRequest struct {
+	Name        string               `json:"name"`
+	Label       string               `json:"label"`
+	Description string               `json:"description"`
+	Permissions []*OrgRolePermission `json:"permissions"`
+}
+
+// UpdateOrgRoleRequest replaces a custom role's label, description, and permissions
+type UpdateOrgRoleRequest struct {
+	Label       string               `json:"label"`
+	Description string               `json:"description"`
+	Permissions []*OrgRolePermission `json:"permissions"`
+}
+
+type SetOrgUserRoleRequest struct {
+	OrgRoleId string `json:"orgRoleId"`
+}
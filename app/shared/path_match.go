@@ -0,0 +1,107 @@
+package shared
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchPathPattern reports whether filePath matches pattern. Each segment of the pattern uses path.Match syntax, '**' matches any number of directories, and a pattern without wildcards also matches everything beneath it when it names a directory.
+func MatchPathPattern(pattern, filePath string) (bool, error) {
+	pattern = cleanPathForMatch(pattern)
+	filePath = cleanPathForMatch(filePath)
+
+	if pattern == "" || pattern == "." {
+		return true, nil
+	}
+
+	if pattern == filePath || strings.HasPrefix(filePath, strings.TrimSuffix(pattern, "/")+"/") {
+		return true, nil
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filePath, "/"))
+}
+
+// FilterPaths returns the paths that match at least one include pattern (or all paths if there are none) and no exclude pattern, preserving order.
+func FilterPaths(paths, include, exclude []string) ([]string, error) {
+	var res []string
+
+	for _, p := range paths {
+		included := len(include) == 0
+		for _, pattern := range include {
+			matched, err := MatchPathPattern(pattern, p)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				included = true
+				break
+			}
+		}
+
+		if !included {
+			continue
+		}
+
+		excluded := false
+		for _, pattern := range exclude {
+			matched, err := MatchPathPattern(pattern, p)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				excluded = true
+				break
+			}
+		}
+
+		if !excluded {
+			res = append(res, p)
+		}
+	}
+
+	return res, nil
+}
+
+func cleanPathForMatch(p string) string {
+	p = filepath.ToSlash(p)
+	p = strings.TrimPrefix(p, "./")
+	if p == "" {
+		return p
+	}
+	return path.Clean(p)
+}
+
+func matchSegments(patternSegs, pathSegs []string) (bool, error) {
+	for len(patternSegs) > 0 {
+		seg := patternSegs[0]
+
+		if seg == "**" {
+			rest := patternSegs[1:]
+			if len(rest) == 0 {
+				return true, nil
+			}
+			for i := 0; i <= len(pathSegs); i++ {
+				matched, err := matchSegments(rest, pathSegs[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(pathSegs) == 0 {
+			return false, nil
+		}
+
+		matched, err := path.Match(seg, pathSegs[0])
+		if err != nil || !matched {
+			return false, err
+		}
+
+		patternSegs = patternSegs[1:]
+		pathSegs = pathSegs[1:]
+	}
+
+	return len(pathSegs) == 0, nil
+}
@@ -0,0 +1,51 @@
+package shared
+
+import "testing"
+
+func TestMatchPathPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"internal/db/**", "internal/db/locks.go", true},
+		{"internal/db/**", "internal/db/migrations/init.sql", true},
+		{"internal/db/**", "internal/dbx/locks.go", false},
+		{"internal/db", "internal/db/migrations/init.sql", true},
+		{"**/*.go", "cmd/apply.go", true},
+		{"**/*.go", "main.go", true},
+		{"*.go", "cmd/apply.go", false},
+		{"cmd/**/apply.go", "cmd/apply.go", true},
+		{"cmd/**/apply.go", "cmd/sub/dir/apply.go", true},
+		{"./cmd/apply.go", "cmd/apply.go", true},
+	}
+
+	for _, c := range cases {
+		got, err := MatchPathPattern(c.pattern, c.path)
+		if err != nil {
+			t.Fatalf("pattern %q: %v", c.pattern, err)
+		}
+		if got != c.want {
+			t.Errorf("MatchPathPattern(%q, %q) = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestFilterPaths(t *testing.T) {
+	paths := []string{"cmd/apply.go", "internal/db/locks.go", "internal/db/git.go", "README.md"}
+
+	got, err := FilterPaths(paths, []string{"internal/**", "cmd"}, []string{"**/git.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"cmd/apply.go", "internal/db/locks.go"}
+	if len(got) != len(want) {
+		t.Fatalf("FilterPaths = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("FilterPaths = %v, want %v", got, want)
+		}
+	}
+}
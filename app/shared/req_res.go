@@ -43,6 +43,33 @@ type SessionResponse struct {
 	Orgs     []*Org `json:"orgs"`
 }
 
+type SsoConfigResponse struct {
+	Enabled bool `json:"enabled"`
+	// email pin sign-in is turned off
+	Required     bool   `json:"required"`
+	ProviderName string `json:"providerName"`
+}
+
+type StartSsoResponse struct {
+	LoginId string `json:"loginId"`
+	// needed to pick up the session once the user has signed in--only returned here
+	PollSecret string `json:"pollSecret"`
+	AuthUrl    string `json:"authUrl"`
+	// shown to the user, who enters it in the browser after signing in so the login can only finish for whoever started it
+	UserCode  string    `json:"userCode"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type PollSsoRequest struct {
+	LoginId    string `json:"loginId"`
+	PollSecret string `json:"pollSecret"`
+}
+
+type PollSsoResponse struct {
+	Pending bool             `json:"pending"`
+	Session *SessionResponse `json:"session,omitempty"`
+}
+
 type CreateOrgRequest struct {
 	Name               string `json:"name"`
 	AutoAddDomainUsers bool   `json:"autoAddDomainUsers"`
@@ -236,8 +263,22 @@ type ApplyPlanRequest struct {
 	ApiKeys     map[string]string `json:"apiKeys"`
 	OpenAIBase  string            `json:"openAIBase"`
 	OpenAIOrgId string            `json:"openAIOrgId"`
+	Paths       []string          `json:"paths"` // if empty, all pending paths are applied
 }
 
 type RenamePlanRequest struct {
 	Name string `json:"name"`
 }
+
+type PlanPatch struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+type ExportPatchesResponse struct {
+	Patches []*PlanPatch `json:"patches"`
+}
+
+type RevokeSessionsResponse struct {
+	NumRevoked int `json:"numRevoked"`
+}
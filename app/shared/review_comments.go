@@ -0,0 +1,99 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const MaxReviewCommentLength = 10000
+
+type ReviewComment struct {
+	Id     string `json:"id"`
+	PlanId string `json:"planId"`
+	Branch string `json:"branch"`
+
+	AuthorId    string `json:"authorId"`
+	AuthorName  string `json:"authorName"`
+	AuthorEmail string `json:"authorEmail"`
+
+	Path string `json:"path"`
+	// empty for comments on the whole file
+	ReplacementId string `json:"replacementId,omitempty"`
+	Body          string `json:"body"`
+
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateReviewCommentRequest struct {
+	Path          string `json:"path"`
+	ReplacementId string `json:"replacementId"`
+	Body          string `json:"body"`
+}
+
+// ResolveReviewCommentsRequest resolves the listed comments, or all of the branch's open comments if Ids is empty
+type ResolveReviewCommentsRequest struct {
+	Ids []string `json:"ids"`
+}
+
+func ValidateReviewComment(req CreateReviewCommentRequest) error {
+	if strings.TrimSpace(req.Path) == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	if strings.TrimSpace(req.Body) == "" {
+		return fmt.Errorf("comment can't be empty")
+	}
+
+	if len(req.Body) > MaxReviewCommentLength {
+		return fmt.Errorf("comment can't be longer than %d characters", MaxReviewCommentLength)
+	}
+
+	return nil
+}
+
+// ReviewCommentsPrompt turns review comments into a prompt asking the model to address them. Comments on a specific change include the change's current code so the model can find it--planResult can be nil if it isn't available.
+func ReviewCommentsPrompt(comments []*ReviewComment, planResult *PlanResult) string {
+	var sb strings.Builder
+
+	sb.WriteString("Please address the following review comments on the pending changes. Update the code where a comment calls for it, and explain anything you decide not to change.\n")
+
+	for i, comment := range comments {
+		author := comment.AuthorName
+		if author == "" {
+			author = comment.AuthorEmail
+		}
+
+		sb.WriteString(fmt.Sprintf("\n%d. %s", i+1, comment.Path))
+
+		rep := findReplacement(planResult, comment.Path, comment.ReplacementId)
+		if rep != nil {
+			sb.WriteString(", on this change:\n```\n")
+			sb.WriteString(strings.TrimRight(rep.New, "\n"))
+			sb.WriteString("\n```\n")
+		} else {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString(fmt.Sprintf("%s says: %s\n", author, strings.TrimSpace(comment.Body)))
+	}
+
+	return sb.String()
+}
+
+func findReplacement(planResult *PlanResult, path, replacementId string) *Replacement {
+	if planResult == nil || replacementId == "" {
+		return nil
+	}
+
+	for _, res := range planResult.FileResultsByPath[path] {
+		for _, rep := range res.Replacements {
+			if rep.Id == replacementId {
+				return rep
+			}
+		}
+	}
+
+	return nil
+}
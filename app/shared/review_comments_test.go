@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReviewCommentsPrompt(t *testing.T) {
+	planResult := &PlanResult{
+		FileResultsByPath: PlanFileResultsByPath{
+			"main.go": {{Path: "main.go", Replacements: []*Replacement{{Id: "rep-1", Old: "a()", New: "b()\n"}}}},
+		},
+	}
+
+	prompt := ReviewCommentsPrompt([]*ReviewComment{
+		{Path: "main.go", ReplacementId: "rep-1", AuthorName: "Dev", Body: "b() needs error handling "},
+		{Path: "README.md", AuthorEmail: "reviewer@example.com", Body: "document the new flag"},
+		{Path: "main.go", ReplacementId: "missing", AuthorName: "Dev", Body: "stale"},
+	}, planResult)
+
+	for _, expected := range []string{
+		"1. main.go, on this change:\n```\nb()\n```\nDev says: b() needs error handling\n",
+		"2. README.md\nreviewer@example.com says: document the new flag\n",
+		"3. main.go\nDev says: stale\n",
+	} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("expected prompt to contain %q, got:\n%s", expected, prompt)
+		}
+	}
+}
+
+func TestValidateReviewComment(t *testing.T) {
+	if err := ValidateReviewComment(CreateReviewCommentRequest{Path: "main.go", Body: "looks good"}); err != nil {
+		t.Errorf("expected valid comment: %v", err)
+	}
+
+	if err := ValidateReviewComment(CreateReviewCommentRequest{Path: "main.go", Body: "  "}); err == nil {
+		t.Errorf("expected empty comment to be invalid")
+	}
+
+	if err := ValidateReviewComment(CreateReviewCommentRequest{Body: "no path"}); err == nil {
+		t.Errorf("expected comment without a path to be invalid")
+	}
+
+	if err := ValidateReviewComment(CreateReviewCommentRequest{Path: "main.go", Body: strings.Repeat("a", MaxReviewCommentLength+1)}); err == nil {
+		t.Errorf("expected long comment to be invalid")
+	}
+}
@@ -1,13 +1,51 @@
 package shared
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 const STREAM_MESSAGE_SEPARATOR = "@@PX@@"
 
+// clients that send this in Accept get the plan stream as server-sent events instead of separated messages
+const EVENT_STREAM_CONTENT_TYPE = "text/event-stream"
+
+// A stream event id identifies the last message a client received, as '<modelStreamId>:<seq>'. Clients send it back in a Last-Event-ID header when they reconnect.
+
+func FormatStreamEventId(modelStreamId string, seq int64) string {
+	return fmt.Sprintf("%s:%d", modelStreamId, seq)
+}
+
+func ParseStreamEventId(id string) (modelStreamId string, seq int64, ok bool) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, false
+	}
+
+	seq, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+
+	return id[:i], seq, true
+}
+
 type BuildInfo struct {
 	Path      string `json:"path"`
 	NumTokens int    `json:"numTokens"`
 	Finished  bool   `json:"finished"`
 }
 
+// QueueInfo is where a model call is in line when it's waiting for the server's model call limits
+type QueueInfo struct {
+	// Path is the file waiting to build, or empty when it's the reply that's waiting
+	Path string `json:"path,omitempty"`
+
+	// Position is 1 for the next call to start, and 0 once the call has started
+	Position int `json:"position"`
+}
+
 type StreamMessageType string
 
 const (
@@ -17,6 +55,7 @@ const (
 	StreamMessageDescribing        StreamMessageType = "describing"
 	StreamMessageRepliesFinished   StreamMessageType = "repliesFinished"
 	StreamMessageBuildInfo         StreamMessageType = "buildInfo"
+	StreamMessageQueued            StreamMessageType = "queued"
 	StreamMessagePromptMissingFile StreamMessageType = "promptMissingFile"
 	StreamMessageAborted           StreamMessageType = "aborted"
 	StreamMessageFinished          StreamMessageType = "finished"
@@ -28,9 +67,13 @@ const (
 type StreamMessage struct {
 	Type StreamMessageType `json:"type"`
 
+	// Seq increases by one with each message an active plan sends, so a client that reconnects can ask for what it missed
+	Seq int64 `json:"seq,omitempty"`
+
 	ReplyChunk string `json:"replyChunk,omitempty"`
 
 	BuildInfo       *BuildInfo               `json:"buildInfo,omitempty"`
+	QueueInfo       *QueueInfo               `json:"queueInfo,omitempty"`
 	Description     *ConvoMessageDescription `json:"description,omitempty"`
 	Error           *ApiError                `json:"error,omitempty"`
 	MissingFilePath string                   `json:"missingFilePath,omitempty"`
@@ -0,0 +1,18 @@
+package shared
+
+import "testing"
+
+func TestParseStreamEventId(t *testing.T) {
+	id := FormatStreamEventId("0b7c2b9e-5f0a-4c1e-9a43-2d1f3c6e8a10", 42)
+
+	streamId, seq, ok := ParseStreamEventId(id)
+	if !ok || streamId != "0b7c2b9e-5f0a-4c1e-9a43-2d1f3c6e8a10" || seq != 42 {
+		t.Fatalf("ParseStreamEventId(%q) = %q, %d, %v", id, streamId, seq, ok)
+	}
+
+	for _, invalid := range []string{"", "42", ":42", "stream:", "stream:-1", "stream:abc"} {
+		if _, _, ok := ParseStreamEventId(invalid); ok {
+			t.Errorf("ParseStreamEventId(%q) should fail", invalid)
+		}
+	}
+}
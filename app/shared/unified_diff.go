@@ -0,0 +1,507 @@
+package shared
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	diffFuncNameMax  = 80
+	diffHunkHeaderSz = 128
+	diffBinaryCheck  = 8000
+	diffAbbrevLen    = 7
+)
+
+type DiffLineType string
+
+const (
+	DiffLineContext DiffLineType = " "
+	DiffLineRemoved DiffLineType = "-"
+	DiffLineAdded   DiffLineType = "+"
+)
+
+type DiffLine struct {
+	Type DiffLineType `json:"type"`
+	Text string       `json:"text"` // includes the trailing newline unless it's the last line of a file without one
+}
+
+type DiffHunk struct {
+	// starts and counts as they appear in the '@@' header
+	OldStart int        `json:"oldStart"`
+	OldLines int        `json:"oldLines"`
+	NewStart int        `json:"newStart"`
+	NewLines int        `json:"newLines"`
+	FuncName string     `json:"funcName"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+type FileDiff struct {
+	OldPath  string      `json:"oldPath"`
+	NewPath  string      `json:"newPath"`
+	OldHash  string      `json:"oldHash"`
+	NewHash  string      `json:"newHash"`
+	IsNew    bool        `json:"isNew"`
+	IsBinary bool        `json:"isBinary"`
+	Hunks    []*DiffHunk `json:"hunks"`
+
+	// where trailing blank lines start in each version, if updated added some--used to highlight them like git does
+	blankAtEofPre  int
+	blankAtEofPost int
+
+	// byte sizes, which the diffstat shows for binary files
+	oldSize int
+	newSize int
+}
+
+// DiffFile diffs original against updated. Pass an empty oldPath for a new file. Returns nil if an existing file is unchanged.
+func DiffFile(oldPath, newPath, original, updated string) *FileDiff {
+	isNew := oldPath == ""
+	if !isNew && original == updated {
+		return nil
+	}
+
+	res := &FileDiff{
+		OldPath: oldPath,
+		NewPath: newPath,
+		OldHash: strings.Repeat("0", diffAbbrevLen),
+		NewHash: gitBlobHash(updated)[:diffAbbrevLen],
+		IsNew:   isNew,
+		oldSize: len(original),
+		newSize: len(updated),
+	}
+	if !isNew {
+		res.OldHash = gitBlobHash(original)[:diffAbbrevLen]
+	}
+
+	if isBinaryContent(original) || isBinaryContent(updated) {
+		res.IsBinary = true
+		return res
+	}
+
+	res.blankAtEofPre, res.blankAtEofPost = blankAtEof(original, updated)
+
+	oldLines := SplitLines(original)
+	newLines := SplitLines(updated)
+	res.Hunks = buildDiffHunks(oldLines, newLines, DiffLineHunks(oldLines, newLines))
+
+	return res
+}
+
+// DiffFiles diffs every path in updated against its version in original, in the order 'git diff' uses. Paths that aren't in original are new files.
+func DiffFiles(original, updated map[string]string) []*FileDiff {
+	var paths []string
+	for path := range updated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var res []*FileDiff
+	for _, path := range paths {
+		oldPath := path
+		orig, ok := original[path]
+		if !ok {
+			oldPath = ""
+		}
+
+		diff := DiffFile(oldPath, path, orig, updated[path])
+		if diff != nil {
+			res = append(res, diff)
+		}
+	}
+
+	return res
+}
+
+func buildDiffHunks(oldLines, newLines []string, changes []LineHunk) []*DiffHunk {
+	var hunks []*DiffHunk
+
+	// like git, the function name search for each hunk picks up where the last one stopped, and the last name found carries over if there's no new one
+	funcName := ""
+	funcLinePrev := -1
+
+	for i := 0; i < len(changes); {
+		// merge changes separated by no more than twice the context
+		j := i
+		for j+1 < len(changes) && changes[j+1].OldStart-changes[j].OldEnd <= 2*diffContextLines {
+			j++
+		}
+		first, last := changes[i], changes[j]
+
+		s1 := max(first.OldStart-diffContextLines, 0)
+		s2 := max(first.NewStart-diffContextLines, 0)
+		e1 := min(last.OldEnd+diffContextLines, len(oldLines))
+		e2 := min(last.NewEnd+diffContextLines, len(newLines))
+
+		for l := s1 - 1; l != funcLinePrev && l >= 0 && l < len(oldLines); l-- {
+			if name, ok := matchFuncName(oldLines[l]); ok {
+				funcName = name
+				break
+			}
+		}
+		funcLinePrev = s1 - 1
+
+		hunk := &DiffHunk{
+			OldStart: s1 + 1,
+			OldLines: e1 - s1,
+			NewStart: s2 + 1,
+			NewLines: e2 - s2,
+			FuncName: funcName,
+		}
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+
+		// context always comes from the new version
+		y := s2
+		for _, change := range changes[i : j+1] {
+			for ; y < change.NewStart; y++ {
+				hunk.Lines = append(hunk.Lines, DiffLine{Type: DiffLineContext, Text: newLines[y]})
+			}
+			for x := change.OldStart; x < change.OldEnd; x++ {
+				hunk.Lines = append(hunk.Lines, DiffLine{Type: DiffLineRemoved, Text: oldLines[x]})
+			}
+			for ; y < change.NewEnd; y++ {
+				hunk.Lines = append(hunk.Lines, DiffLine{Type: DiffLineAdded, Text: newLines[y]})
+			}
+		}
+		for ; y < e2; y++ {
+			hunk.Lines = append(hunk.Lines, DiffLine{Type: DiffLineContext, Text: newLines[y]})
+		}
+
+		hunks = append(hunks, hunk)
+		i = j + 1
+	}
+
+	return hunks
+}
+
+// matchFuncName is git's default hunk header function matcher: any line that starts with a letter, '_' or '$'
+func matchFuncName(line string) (string, bool) {
+	if line == "" {
+		return "", false
+	}
+	c := line[0]
+	if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$') {
+		return "", false
+	}
+	if len(line) > diffFuncNameMax {
+		line = line[:diffFuncNameMax]
+	}
+	return strings.TrimRight(line, " \t\n\r"), true
+}
+
+func (h *DiffHunk) Header() string {
+	var sb strings.Builder
+	sb.WriteString("@@ -")
+	sb.WriteString(formatHunkRange(h.OldStart, h.OldLines))
+	sb.WriteString(" +")
+	sb.WriteString(formatHunkRange(h.NewStart, h.NewLines))
+	sb.WriteString(" @@")
+
+	if h.FuncName != "" {
+		name := h.FuncName
+		// git builds the header in a fixed size buffer
+		if limit := diffHunkHeaderSz - sb.Len() - 2; len(name) > limit {
+			name = name[:limit]
+		}
+		sb.WriteString(" ")
+		sb.WriteString(name)
+	}
+
+	return sb.String()
+}
+
+func formatHunkRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// FormatFileDiffs renders diffs the way 'git diff' does, optionally with git's default colors.
+func FormatFileDiffs(diffs []*FileDiff, color bool) string {
+	var sb strings.Builder
+	for _, diff := range diffs {
+		diff.write(&sb, color)
+	}
+	return sb.String()
+}
+
+func (d *FileDiff) Format(color bool) string {
+	var sb strings.Builder
+	d.write(&sb, color)
+	return sb.String()
+}
+
+const (
+	diffColorMeta  = "\x1b[1m"
+	diffColorFrag  = "\x1b[36m"
+	diffColorOld   = "\x1b[31m"
+	diffColorNew   = "\x1b[32m"
+	diffColorWs    = "\x1b[41m"
+	diffColorReset = "\x1b[m"
+)
+
+func (d *FileDiff) write(sb *strings.Builder, color bool) {
+	meta := func(line string) {
+		if color {
+			sb.WriteString(diffColorMeta + line + diffColorReset + "\n")
+		} else {
+			sb.WriteString(line + "\n")
+		}
+	}
+
+	oldName := "a/" + d.OldPath
+	newName := "b/" + d.NewPath
+	if d.IsNew {
+		oldName = "a/" + d.NewPath
+	}
+
+	meta("diff --git " + quoteDiffPath(oldName) + " " + quoteDiffPath(newName))
+	if d.IsNew {
+		meta("new file mode 100644")
+		meta("index " + d.OldHash + ".." + d.NewHash)
+	} else {
+		meta("index " + d.OldHash + ".." + d.NewHash + " 100644")
+	}
+
+	if d.IsNew {
+		oldName = "/dev/null"
+	}
+
+	if d.IsBinary {
+		sb.WriteString(fmt.Sprintf("Binary files %s and %s differ\n", quoteDiffPath(oldName), quoteDiffPath(newName)))
+		return
+	}
+
+	if len(d.Hunks) == 0 {
+		return
+	}
+
+	meta("--- " + quoteDiffPath(oldName))
+	meta("+++ " + quoteDiffPath(newName))
+
+	for _, hunk := range d.Hunks {
+		header := hunk.Header()
+		if !color {
+			sb.WriteString(header + "\n")
+		} else {
+			end := strings.Index(header[2:], "@@") + 4
+			sb.WriteString(diffColorFrag + header[:end] + diffColorReset)
+			if end < len(header) {
+				// the blank before the function name, then the function name, each in the (empty) default color
+				sb.WriteString(" " + diffColorReset + header[end+1:] + diffColorReset)
+			}
+			sb.WriteString("\n")
+		}
+
+		// git tracks line numbers from the header starts to find blank lines added at the end of the file
+		lnoPre, lnoPost := hunk.OldStart, hunk.NewStart
+
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case DiffLineContext:
+				lnoPre++
+				lnoPost++
+			case DiffLineRemoved:
+				lnoPre++
+			case DiffLineAdded:
+				lnoPost++
+			}
+
+			if !color {
+				sb.WriteString(string(line.Type) + line.Text)
+			} else if line.Type == DiffLineAdded {
+				if d.blankAtEofPre > 0 && d.blankAtEofPre <= lnoPre && d.blankAtEofPost <= lnoPost && isBlankLine(line.Text) {
+					writeColoredLine(sb, diffColorWs, "+", line.Text)
+				} else {
+					sb.WriteString(diffColorNew + "+" + diffColorReset)
+					writeWhitespaceChecked(sb, line.Text)
+				}
+			} else if line.Type == DiffLineRemoved {
+				writeColoredLine(sb, diffColorOld, "-", line.Text)
+			} else {
+				writeColoredLine(sb, "", " ", line.Text)
+			}
+
+			if !strings.HasSuffix(line.Text, "\n") {
+				if !color {
+					sb.WriteString("\n\\ No newline at end of file\n")
+				} else {
+					sb.WriteString("\n\\ No newline at end of file" + diffColorReset + "\n")
+				}
+			}
+		}
+	}
+}
+
+func writeColoredLine(sb *strings.Builder, set, sign, text string) {
+	content := strings.TrimSuffix(text, "\n")
+	withoutCr := strings.TrimSuffix(content, "\r")
+	sb.WriteString(set + sign + withoutCr + diffColorReset)
+	sb.WriteString(text[len(withoutCr):])
+}
+
+// writeWhitespaceChecked writes an added line in green, highlighting trailing whitespace and spaces before tabs in the indent like git's default core.whitespace rules
+func writeWhitespaceChecked(sb *strings.Builder, text string) {
+	line := strings.TrimSuffix(text, "\n")
+	trailingNewline := len(line) < len(text)
+
+	trailingWhitespace := len(line)
+	for i := len(line) - 1; i >= 0 && isXdlSpace(line[i]); i-- {
+		trailingWhitespace = i
+	}
+
+	written := 0
+	for i := 0; i < trailingWhitespace; i++ {
+		if line[i] == ' ' {
+			continue
+		}
+		if line[i] != '\t' {
+			break
+		}
+		if written < i {
+			sb.WriteString(diffColorWs + line[written:i] + diffColorReset + line[i:i+1])
+		} else {
+			sb.WriteString(line[written : i+1])
+		}
+		written = i + 1
+	}
+
+	if trailingWhitespace > written {
+		sb.WriteString(diffColorNew + line[written:trailingWhitespace] + diffColorReset)
+	}
+	if trailingWhitespace < len(line) {
+		sb.WriteString(diffColorWs + line[trailingWhitespace:] + diffColorReset)
+	}
+	if trailingNewline {
+		sb.WriteString("\n")
+	}
+}
+
+func isBlankLine(line string) bool {
+	for i := 0; i < len(line); i++ {
+		if !isXdlSpace(line[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// blankAtEof mirrors git's check for blank lines added at the end of a file. It returns the 1-based line where the trailing blank lines start in each version, or zeros if updated doesn't have more of them than original.
+func blankAtEof(original, updated string) (int, int) {
+	l1 := countTrailingBlank(original)
+	l2 := countTrailingBlank(updated)
+	if l2 <= l1 {
+		return 0, 0
+	}
+	return countDiffLines(original) - l1 + 1, countDiffLines(updated) - l2 + 1
+}
+
+func countTrailingBlank(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	cnt := 0
+	ptr := len(s) - 1
+	if s[ptr] == '\n' {
+		ptr--
+	}
+	for 0 < ptr {
+		prevEol := ptr
+		for ; 0 <= prevEol; prevEol-- {
+			if s[prevEol] == '\n' {
+				break
+			}
+		}
+		if !isBlankLine(s[prevEol+1 : ptr+1]) {
+			break
+		}
+		cnt++
+		ptr = prevEol - 1
+	}
+	return cnt
+}
+
+func countDiffLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	count := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		count++
+	}
+	return count
+}
+
+func isBinaryContent(s string) bool {
+	if len(s) > diffBinaryCheck {
+		s = s[:diffBinaryCheck]
+	}
+	return strings.IndexByte(s, 0) != -1
+}
+
+func gitBlobHash(content string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// quoteDiffPath quotes a path the way git does when it contains control characters, quotes, backslashes or non-ASCII bytes
+func quoteDiffPath(p string) string {
+	needsQuote := false
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needsQuote = true
+			break
+		}
+	}
+	if !needsQuote {
+		return p
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch c {
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				sb.WriteString(fmt.Sprintf("\\%03o", c))
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,202 @@
+package shared
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffFilesMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	original := map[string]string{
+		"modified.go":    "package main\n\nfunc a() {\n\tx := 1\n\treturn x\n}\n\nfunc b() {\n\treturn\n}\n",
+		"no_newline.txt": "keep\n",
+		"emptied.txt":    "a\nb\n",
+		"was_empty.txt":  "",
+		"same.txt":       "unchanged\n",
+		"binary.bin":     "a\x00b",
+		"crlf.txt":       "one\r\ntwo\r\n",
+		"üñí.txt":        "x\n",
+	}
+	updated := map[string]string{
+		"modified.go":    "package main\n\nfunc a() {\n\tx := 2  \n\treturn x\n}\n\nfunc b() {\n  \treturn nil\n}\n\n\n",
+		"no_newline.txt": "keep\nno nl",
+		"emptied.txt":    "",
+		"was_empty.txt":  "content\n",
+		"same.txt":       "unchanged\n",
+		"binary.bin":     "a\x00c",
+		"crlf.txt":       "one\r\n2\r\n",
+		"üñí.txt":        "y\n",
+		"new.txt":        "new\n",
+		"new_empty.txt":  "",
+		"dir/nested.txt": "nested\n",
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 150; i++ {
+		path := fmt.Sprintf("random/%03d.txt", i)
+		original[path], updated[path] = randomFileEdit(rng, 5+rng.Intn(80))
+	}
+
+	// large enough that the diff hits git's cost heuristics
+	original["large.txt"], updated["large.txt"] = randomFileEdit(rng, 4000)
+
+	for _, color := range []bool{false, true} {
+		got := FormatFileDiffs(DiffFiles(original, updated), color)
+		want := gitDiffFiles(t, original, updated, color)
+
+		if got != want {
+			gotFiles := strings.Split(got, "diff --git")
+			wantFiles := strings.Split(want, "diff --git")
+			for i := 0; i < len(gotFiles) && i < len(wantFiles); i++ {
+				if gotFiles[i] != wantFiles[i] {
+					t.Fatalf("color=%v: output differs from git\n--- got:\n%q\n--- want:\n%q", color, gotFiles[i], wantFiles[i])
+				}
+			}
+			t.Fatalf("color=%v: got %d files, git has %d", color, len(gotFiles), len(wantFiles))
+		}
+	}
+}
+
+func TestDiffFileHunks(t *testing.T) {
+	diff := DiffFile("a.go", "a.go", "func a() {\n\treturn 1\n}\n", "func a() {\n\treturn 2\n}\n")
+
+	if len(diff.Hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(diff.Hunks))
+	}
+
+	hunk := diff.Hunks[0]
+	if hunk.Header() != "@@ -1,3 +1,3 @@" {
+		t.Fatalf("unexpected header %q", hunk.Header())
+	}
+
+	var types []string
+	for _, line := range hunk.Lines {
+		types = append(types, string(line.Type))
+	}
+	if got := strings.Join(types, ""); got != " -+ " {
+		t.Fatalf("unexpected line types %q", got)
+	}
+
+	if DiffFile("a.go", "a.go", "same\n", "same\n") != nil {
+		t.Fatalf("expected nil diff for unchanged file")
+	}
+}
+
+func BenchmarkPlanDiffsNative(b *testing.B) {
+	original, updated := largePlanFiles()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		FormatFileDiffs(DiffFiles(original, updated), true)
+	}
+}
+
+func BenchmarkPlanDiffsGit(b *testing.B) {
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git not installed")
+	}
+	original, updated := largePlanFiles()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		gitDiffFiles(b, original, updated, true)
+	}
+}
+
+// a plan touching 40 files of 2000 lines each
+func largePlanFiles() (map[string]string, map[string]string) {
+	rng := rand.New(rand.NewSource(2))
+	original := map[string]string{}
+	updated := map[string]string{}
+	for i := 0; i < 40; i++ {
+		path := fmt.Sprintf("pkg%d/file%d.go", i%5, i)
+		original[path], updated[path] = randomFileEdit(rng, 2000)
+	}
+	return original, updated
+}
+
+var randomLines = []string{
+	"\n", "\n", "}\n", "\t}\n", "\treturn nil\n", "\tif err != nil {\n", "\t\treturn err\n",
+	"func a() {\n", "func b() error {\n", "x := 1\n", "    indented\n", "trailing  \n", "// comment\n",
+}
+
+// randomFileEdit returns a file made of a few repeated lines and a copy with random edits, which gives plenty of equally short diffs to choose between
+func randomFileEdit(rng *rand.Rand, n int) (string, string) {
+	var original []string
+	for i := 0; i < n; i++ {
+		if rng.Intn(3) == 0 {
+			original = append(original, fmt.Sprintf("line %d\n", rng.Intn(n)))
+		} else {
+			original = append(original, randomLines[rng.Intn(len(randomLines))])
+		}
+	}
+
+	var updated []string
+	for i := 0; i < len(original); i++ {
+		switch rng.Intn(12) {
+		case 0:
+			// delete
+		case 1:
+			updated = append(updated, randomLines[rng.Intn(len(randomLines))], original[i])
+		case 2:
+			updated = append(updated, fmt.Sprintf("changed %d\n", rng.Intn(5)))
+		default:
+			updated = append(updated, original[i])
+		}
+	}
+
+	a := strings.Join(original, "")
+	b := strings.Join(updated, "")
+	if rng.Intn(4) == 0 {
+		b = strings.TrimSuffix(b, "\n")
+	}
+	return a, b
+}
+
+// gitDiffFiles is how plan diffs used to be built: commit the originals to a temp repo, stage the updates, and run 'git diff --cached'
+func gitDiffFiles(tb testing.TB, original, updated map[string]string, color bool) string {
+	dir := tb.TempDir()
+
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			tb.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+
+	write := func(files map[string]string) {
+		for path, content := range files {
+			full := filepath.Join(dir, path)
+			if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+				tb.Fatal(err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+
+	run("init", "-q")
+	write(original)
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@test", "commit", "-q", "-m", "original files")
+	write(updated)
+	run("add", ".")
+
+	colorArg := "--no-color"
+	if color {
+		colorArg = "--color=always"
+	}
+	return run("diff", "--cached", colorArg)
+}
@@ -0,0 +1,197 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookEventPlanFinished      WebhookEvent = "plan.finished"
+	WebhookEventPlanError         WebhookEvent = "plan.error"
+	WebhookEventPlanMissingFile   WebhookEvent = "plan.missing_file"
+	WebhookEventPlanApplied       WebhookEvent = "plan.applied"
+	WebhookEventBuildVerifyFailed WebhookEvent = "build.verify_failed"
+
+	// sent by 'plandex webhooks test' to a single webhook, whatever events it's registered for
+	WebhookEventTest WebhookEvent = "webhook.test"
+)
+
+var WebhookEvents = []WebhookEvent{
+	WebhookEventPlanFinished,
+	WebhookEventPlanError,
+	WebhookEventPlanMissingFile,
+	WebhookEventPlanApplied,
+	WebhookEventBuildVerifyFailed,
+}
+
+// WebhookEventForPlanStatus is the event sent when a plan's status changes to status, if there is one
+func WebhookEventForPlanStatus(status PlanStatus) (WebhookEvent, bool) {
+	switch status {
+	case PlanStatusFinished:
+		return WebhookEventPlanFinished, true
+	case PlanStatusError:
+		return WebhookEventPlanError, true
+	case PlanStatusMissingFile:
+		return WebhookEventPlanMissingFile, true
+	}
+	return "", false
+}
+
+// WebhookSecretPrefix starts every webhook signing secret
+const WebhookSecretPrefix = "whsec_"
+
+const (
+	WebhookSignatureHeader = "X-Plandex-Signature"
+	WebhookEventHeader     = "X-Plandex-Event"
+	WebhookDeliveryHeader  = "X-Plandex-Delivery"
+)
+
+// WebhookSignatureTolerance is how old a signature's timestamp can be before receivers should reject it as a replay
+const WebhookSignatureTolerance = 5 * time.Minute
+
+type Webhook struct {
+	Id        string         `json:"id"`
+	OrgId     string         `json:"orgId"`
+	Url       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	CreatorId string         `json:"creatorId"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type CreateWebhookRequest struct {
+	Url    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+}
+
+// CreateWebhookResponse includes the signing secret, which is only ever returned here
+type CreateWebhookResponse struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	Id             string                `json:"id"`
+	WebhookId      string                `json:"webhookId"`
+	Event          WebhookEvent          `json:"event"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"responseStatus,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+}
+
+// WebhookPayload is the JSON body posted to a webhook. Fields that don't apply to the event are omitted.
+type WebhookPayload struct {
+	Id        string       `json:"id"`
+	Event     WebhookEvent `json:"event"`
+	OrgId     string       `json:"orgId"`
+	CreatedAt time.Time    `json:"createdAt"`
+
+	PlanId   string     `json:"planId,omitempty"`
+	PlanName string     `json:"planName,omitempty"`
+	Branch   string     `json:"branch,omitempty"`
+	Status   PlanStatus `json:"status,omitempty"`
+	Error    string     `json:"error,omitempty"`
+
+	// the file the plan tried to update that isn't in context, for plan.missing_file
+	MissingFilePath string `json:"missingFilePath,omitempty"`
+	// for plan.applied, the paths applied when only some of the pending changes were--omitted when they all were
+	Paths []string `json:"paths,omitempty"`
+	// the file whose build didn't verify and why, for build.verify_failed
+	Path   string `json:"path,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func ValidateWebhookRequest(req CreateWebhookRequest) error {
+	u, err := url.Parse(strings.TrimSpace(req.Url))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https url")
+	}
+
+	if len(req.Events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+
+	for _, event := range req.Events {
+		if !IsWebhookEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	return nil
+}
+
+func IsWebhookEvent(event WebhookEvent) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload returns the signature header value for a body sent at timestamp: 't=<unix seconds>,v1=<hex hmac-sha256 of "<t>.<body>">'. Including the timestamp in what's signed lets receivers reject replays.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, webhookHmac(secret, t, body))
+}
+
+// VerifyWebhookSignature checks a signature header made by SignWebhookPayload, and that it was made within WebhookSignatureTolerance of now
+func VerifyWebhookSignature(secret, header string, body []byte, now time.Time) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	if t == "" || sig == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signature timestamp")
+	}
+
+	age := now.Sub(time.Unix(unix, 0))
+	if age > WebhookSignatureTolerance || age < -WebhookSignatureTolerance {
+		return fmt.Errorf("signature timestamp is outside the tolerance")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(webhookHmac(secret, t, body))) {
+		return fmt.Errorf("signature doesn't match")
+	}
+
+	return nil
+}
+
+func webhookHmac(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
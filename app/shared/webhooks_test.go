@@ -0,0 +1,55 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	secret := WebhookSecretPrefix + "test"
+	body := []byte(`{"event":"plan.finished"}`)
+	sentAt := time.Unix(1715000000, 0)
+
+	header := SignWebhookPayload(secret, sentAt, body)
+
+	if err := VerifyWebhookSignature(secret, header, body, sentAt.Add(time.Minute)); err != nil {
+		t.Fatalf("expected signature to verify, got %v", err)
+	}
+
+	for name, check := range map[string]func() error{
+		"wrong secret": func() error {
+			return VerifyWebhookSignature(secret+"x", header, body, sentAt)
+		},
+		"tampered body": func() error {
+			return VerifyWebhookSignature(secret, header, []byte(`{"event":"plan.error"}`), sentAt)
+		},
+		"replayed": func() error {
+			return VerifyWebhookSignature(secret, header, body, sentAt.Add(WebhookSignatureTolerance+time.Second))
+		},
+		"malformed": func() error {
+			return VerifyWebhookSignature(secret, "v1=abc", body, sentAt)
+		},
+	} {
+		if check() == nil {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+}
+
+func TestValidateWebhookRequest(t *testing.T) {
+	valid := CreateWebhookRequest{Url: "http://localhost:8787/hooks", Events: []WebhookEvent{WebhookEventPlanFinished}}
+	if err := ValidateWebhookRequest(valid); err != nil {
+		t.Fatalf("expected valid request, got %v", err)
+	}
+
+	for _, req := range []CreateWebhookRequest{
+		{Url: "ftp://example.com", Events: valid.Events},
+		{Url: "/hooks", Events: valid.Events},
+		{Url: valid.Url},
+		{Url: valid.Url, Events: []WebhookEvent{WebhookEventTest}},
+	} {
+		if ValidateWebhookRequest(req) == nil {
+			t.Errorf("expected %+v to be invalid", req)
+		}
+	}
+}
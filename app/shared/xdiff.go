@@ -0,0 +1,713 @@
+package shared
+
+// This is a port of the Myers diff in git's xdiff library (xdiffi.c, xprepare.c) along with the change compaction and indent heuristic that 'git diff' runs by default. Following it closely means that when several minimal diffs exist we pick the same one git does, so our unified output matches 'git diff' byte for byte.
+
+const (
+	xdlMaxEqLimit         = 1024
+	xdlSimscanWindow      = 100
+	xdlKpdisRun           = 4
+	xdlMaxCostMin         = 256
+	xdlHeurMinCost        = 256
+	xdlSnakeCnt           = 20
+	xdlKHeur              = 4
+	xdlLineMax            = int(^uint(0) >> 1)
+	indentHeuristicMaxLen = 100
+)
+
+type xdFile struct {
+	lines []string
+	ha    []int // equivalence class of each line
+
+	// rchg has a false sentinel on each side, so rchg[i+1] is whether line i changed
+	rchg []bool
+
+	dstart, dend int
+
+	// lines that are left to diff after trimming and discarding lines with no match on the other side
+	reffHa []int
+	rindex []int
+}
+
+func (f *xdFile) changed(i int) bool {
+	return f.rchg[i+1]
+}
+
+func (f *xdFile) setChanged(i int, v bool) {
+	f.rchg[i+1] = v
+}
+
+// diffLineChanges returns, for every line of a and b, whether it's part of a change.
+func diffLineChanges(a, b []string) (aChanged, bChanged []bool) {
+	classes := map[string]int{}
+	var countA, countB []int
+
+	classify := func(lines []string, counts *[]int) *xdFile {
+		f := &xdFile{
+			lines: lines,
+			ha:    make([]int, len(lines)),
+			rchg:  make([]bool, len(lines)+2),
+		}
+		for i, line := range lines {
+			id, ok := classes[line]
+			if !ok {
+				id = len(classes)
+				classes[line] = id
+				countA = append(countA, 0)
+				countB = append(countB, 0)
+			}
+			(*counts)[id]++
+			f.ha[i] = id
+		}
+		return f
+	}
+
+	f1 := classify(a, &countA)
+	f2 := classify(b, &countB)
+
+	xdlTrimEnds(f1, f2)
+	xdlCleanupRecords(f1, f2, countA, countB)
+
+	ndiags := len(f1.reffHa) + len(f2.reffHa) + 3
+	env := &xdAlgoEnv{
+		mxcost:   max(xdlBogoSqrt(ndiags), xdlMaxCostMin),
+		snakeCnt: xdlSnakeCnt,
+		heurMin:  xdlHeurMinCost,
+		kvdf:     make([]int, ndiags),
+		kvdb:     make([]int, ndiags),
+		kvOffset: len(f2.reffHa) + 1,
+	}
+
+	env.recsCmp(f1, 0, len(f1.reffHa), f2, 0, len(f2.reffHa), false)
+
+	xdlChangeCompact(f1, f2)
+	xdlChangeCompact(f2, f1)
+
+	return f1.rchg[1 : len(a)+1], f2.rchg[1 : len(b)+1]
+}
+
+func xdlBogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+func xdlTrimEnds(f1, f2 *xdFile) {
+	lim := min(len(f1.ha), len(f2.ha))
+
+	i := 0
+	for ; i < lim; i++ {
+		if f1.ha[i] != f2.ha[i] {
+			break
+		}
+	}
+	f1.dstart, f2.dstart = i, i
+
+	lim -= i
+	i = 0
+	for ; i < lim; i++ {
+		if f1.ha[len(f1.ha)-1-i] != f2.ha[len(f2.ha)-1-i] {
+			break
+		}
+	}
+	f1.dend = len(f1.ha) - i - 1
+	f2.dend = len(f2.ha) - i - 1
+}
+
+// xdlCleanupRecords marks lines with no match on the other side as changed up front and leaves them out of the diff, along with lines that have many matches and sit in a run of such lines.
+func xdlCleanupRecords(f1, f2 *xdFile, countA, countB []int) {
+	dis1 := make([]byte, len(f1.ha)+1)
+	dis2 := make([]byte, len(f2.ha)+1)
+
+	mark := func(f *xdFile, dis []byte, otherCounts []int) {
+		mlim := min(xdlBogoSqrt(len(f.ha)), xdlMaxEqLimit)
+		for i := f.dstart; i <= f.dend; i++ {
+			nm := otherCounts[f.ha[i]]
+			if nm == 0 {
+				dis[i] = 0
+			} else if nm >= mlim {
+				dis[i] = 2
+			} else {
+				dis[i] = 1
+			}
+		}
+	}
+	mark(f1, dis1, countB)
+	mark(f2, dis2, countA)
+
+	keep := func(f *xdFile, dis []byte) {
+		for i := f.dstart; i <= f.dend; i++ {
+			if dis[i] == 1 || (dis[i] == 2 && !xdlCleanMmatch(dis, i, f.dstart, f.dend)) {
+				f.rindex = append(f.rindex, i)
+				f.reffHa = append(f.reffHa, f.ha[i])
+			} else {
+				f.setChanged(i, true)
+			}
+		}
+	}
+	keep(f1, dis1)
+	keep(f2, dis2)
+}
+
+func xdlCleanMmatch(dis []byte, i, s, e int) bool {
+	if i-s > xdlSimscanWindow {
+		s = i - xdlSimscanWindow
+	}
+	if e-i > xdlSimscanWindow {
+		e = i + xdlSimscanWindow
+	}
+
+	rdis0, rpdis0 := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if dis[i-r] == 0 {
+			rdis0++
+		} else if dis[i-r] == 2 {
+			rpdis0++
+		} else {
+			break
+		}
+	}
+	if rdis0 == 0 {
+		return false
+	}
+
+	rdis1, rpdis1 := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if dis[i+r] == 0 {
+			rdis1++
+		} else if dis[i+r] == 2 {
+			rpdis1++
+		} else {
+			break
+		}
+	}
+	if rdis1 == 0 {
+		return false
+	}
+
+	rdis1 += rdis0
+	rpdis1 += rpdis0
+
+	return rpdis1*xdlKpdisRun < rpdis1+rdis1
+}
+
+type xdAlgoEnv struct {
+	mxcost, snakeCnt, heurMin int
+
+	// forward and backward furthest reaching paths, indexed by diagonal + kvOffset
+	kvdf, kvdb []int
+	kvOffset   int
+}
+
+type xdSplit struct {
+	i1, i2       int
+	minLo, minHi bool
+}
+
+func (env *xdAlgoEnv) recsCmp(f1 *xdFile, off1, lim1 int, f2 *xdFile, off2, lim2 int, needMin bool) {
+	ha1, ha2 := f1.reffHa, f2.reffHa
+
+	// shrink the box by walking through each diagonal snake
+	for off1 < lim1 && off2 < lim2 && ha1[off1] == ha2[off2] {
+		off1++
+		off2++
+	}
+	for off1 < lim1 && off2 < lim2 && ha1[lim1-1] == ha2[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	if off1 == lim1 {
+		for ; off2 < lim2; off2++ {
+			f2.setChanged(f2.rindex[off2], true)
+		}
+	} else if off2 == lim2 {
+		for ; off1 < lim1; off1++ {
+			f1.setChanged(f1.rindex[off1], true)
+		}
+	} else {
+		spl := env.split(ha1, off1, lim1, ha2, off2, lim2, needMin)
+		env.recsCmp(f1, off1, spl.i1, f2, off2, spl.i2, spl.minLo)
+		env.recsCmp(f1, spl.i1, lim1, f2, spl.i2, lim2, spl.minHi)
+	}
+}
+
+// split finds the middle snake of the box, falling back to heuristics when the edit cost gets too high
+func (env *xdAlgoEnv) split(ha1 []int, off1, lim1 int, ha2 []int, off2, lim2 int, needMin bool) xdSplit {
+	kvdf := func(d int) *int { return &env.kvdf[d+env.kvOffset] }
+	kvdb := func(d int) *int { return &env.kvdb[d+env.kvOffset] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+
+	*kvdf(fmid) = off1
+	*kvdb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+
+		if fmin > dmin {
+			fmin--
+			*kvdf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			*kvdf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kvdf(d - 1) >= *kvdf(d + 1) {
+				i1 = *kvdf(d - 1) + 1
+			} else {
+				i1 = *kvdf(d + 1)
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha1[i1] == ha2[i2] {
+				i1++
+				i2++
+			}
+			if i1-prev1 > env.snakeCnt {
+				gotSnake = true
+			}
+			*kvdf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kvdb(d) <= i1 {
+				return xdSplit{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*kvdb(bmin - 1) = xdlLineMax
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			*kvdb(bmax + 1) = xdlLineMax
+		} else {
+			bmax--
+		}
+
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kvdb(d - 1) < *kvdb(d + 1) {
+				i1 = *kvdb(d - 1)
+			} else {
+				i1 = *kvdb(d + 1) - 1
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha1[i1-1] == ha2[i2-1] {
+				i1--
+				i2--
+			}
+			if prev1-i1 > env.snakeCnt {
+				gotSnake = true
+			}
+			*kvdb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kvdf(d) {
+				return xdSplit{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// past the heuristic trigger with a good snake, look for a diagonal that has made interesting progress
+		if gotSnake && ec > env.heurMin {
+			best := 0
+			var spl xdSplit
+			for d := fmax; d >= fmin; d -= 2 {
+				dd := d - fmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := *kvdf(d)
+				i2 := i1 - d
+				v := (i1 - off1) + (i2 - off2) - dd
+
+				if v > xdlKHeur*ec && v > best &&
+					off1+env.snakeCnt <= i1 && i1 < lim1 &&
+					off2+env.snakeCnt <= i2 && i2 < lim2 {
+					for k := 1; ha1[i1-k] == ha2[i2-k]; k++ {
+						if k == env.snakeCnt {
+							best = v
+							spl.i1 = i1
+							spl.i2 = i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				spl.minLo = true
+				spl.minHi = false
+				return spl
+			}
+
+			for d := bmax; d >= bmin; d -= 2 {
+				dd := d - bmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := *kvdb(d)
+				i2 := i1 - d
+				v := (lim1 - i1) + (lim2 - i2) - dd
+
+				if v > xdlKHeur*ec && v > best &&
+					off1 < i1 && i1 <= lim1-env.snakeCnt &&
+					off2 < i2 && i2 <= lim2-env.snakeCnt {
+					for k := 0; ha1[i1+k] == ha2[i2+k]; k++ {
+						if k == env.snakeCnt-1 {
+							best = v
+							spl.i1 = i1
+							spl.i2 = i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				spl.minLo = false
+				spl.minHi = true
+				return spl
+			}
+		}
+
+		// enough is enough--take the furthest reaching path
+		if ec >= env.mxcost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := min(*kvdf(d), lim1)
+				i2 := i1 - d
+				if lim2 < i2 {
+					i1 = lim2 + d
+					i2 = lim2
+				}
+				if fbest < i1+i2 {
+					fbest = i1 + i2
+					fbest1 = i1
+				}
+			}
+
+			bbest, bbest1 := xdlLineMax, xdlLineMax
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := max(off1, *kvdb(d))
+				i2 := i1 - d
+				if i2 < off2 {
+					i1 = off2 + d
+					i2 = off2
+				}
+				if i1+i2 < bbest {
+					bbest = i1 + i2
+					bbest1 = i1
+				}
+			}
+
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return xdSplit{i1: fbest1, i2: fbest - fbest1, minLo: true, minHi: false}
+			}
+			return xdSplit{i1: bbest1, i2: bbest - bbest1, minLo: false, minHi: true}
+		}
+	}
+}
+
+// a group is a run of changed lines (possibly empty) in one file
+type xdGroup struct {
+	start, end int
+}
+
+func groupInit(f *xdFile) xdGroup {
+	g := xdGroup{}
+	for f.changed(g.end) {
+		g.end++
+	}
+	return g
+}
+
+func groupNext(f *xdFile, g *xdGroup) bool {
+	if g.end == len(f.ha) {
+		return false
+	}
+	g.start = g.end + 1
+	for g.end = g.start; f.changed(g.end); g.end++ {
+	}
+	return true
+}
+
+func groupPrevious(f *xdFile, g *xdGroup) bool {
+	if g.start == 0 {
+		return false
+	}
+	g.end = g.start - 1
+	for g.start = g.end; f.changed(g.start - 1); g.start-- {
+	}
+	return true
+}
+
+func groupSlideDown(f *xdFile, g *xdGroup) bool {
+	if g.end < len(f.ha) && f.ha[g.start] == f.ha[g.end] {
+		f.setChanged(g.start, false)
+		g.start++
+		f.setChanged(g.end, true)
+		g.end++
+		for f.changed(g.end) {
+			g.end++
+		}
+		return true
+	}
+	return false
+}
+
+func groupSlideUp(f *xdFile, g *xdGroup) bool {
+	if g.start > 0 && f.ha[g.start-1] == f.ha[g.end-1] {
+		g.start--
+		f.setChanged(g.start, true)
+		g.end--
+		f.setChanged(g.end, false)
+		for f.changed(g.start - 1) {
+			g.start--
+		}
+		return true
+	}
+	return false
+}
+
+// xdlChangeCompact slides each group of changes in f to a canonical position: lined up with a change in the other file if possible, otherwise wherever the indent heuristic scores best.
+func xdlChangeCompact(f, fo *xdFile) {
+	g := groupInit(f)
+	gOther := groupInit(fo)
+
+	for {
+		if g.end != g.start {
+			var groupSize, earliestEnd int
+			endMatchingOther := -1
+
+			for {
+				groupSize = g.end - g.start
+				endMatchingOther = -1
+
+				for groupSlideUp(f, &g) {
+					groupPrevious(fo, &gOther)
+				}
+
+				earliestEnd = g.end
+
+				if gOther.end > gOther.start {
+					endMatchingOther = g.end
+				}
+
+				for groupSlideDown(f, &g) {
+					groupNext(fo, &gOther)
+					if gOther.end > gOther.start {
+						endMatchingOther = g.end
+					}
+				}
+
+				if groupSize == g.end-g.start {
+					break
+				}
+			}
+
+			if g.end == earliestEnd {
+				// no shifting was possible
+			} else if endMatchingOther != -1 {
+				for gOther.end == gOther.start {
+					groupSlideUp(f, &g)
+					groupPrevious(fo, &gOther)
+				}
+			} else {
+				shift := earliestEnd
+				if g.end-groupSize-1 > shift {
+					shift = g.end - groupSize - 1
+				}
+				if g.end-indentHeuristicMaxLen > shift {
+					shift = g.end - indentHeuristicMaxLen
+				}
+
+				bestShift := -1
+				var bestScore splitScore
+				for ; shift <= g.end; shift++ {
+					score := splitScore{}
+					score.add(measureSplit(f, shift))
+					score.add(measureSplit(f, shift-groupSize))
+					if bestShift == -1 || score.cmp(bestScore) <= 0 {
+						bestScore = score
+						bestShift = shift
+					}
+				}
+
+				for g.end > bestShift {
+					groupSlideUp(f, &g)
+					groupPrevious(fo, &gOther)
+				}
+			}
+		}
+
+		if !groupNext(f, &g) {
+			break
+		}
+		groupNext(fo, &gOther)
+	}
+}
+
+const (
+	maxIndent = 200
+	maxBlanks = 20
+
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+)
+
+type splitMeasurement struct {
+	endOfFile  bool
+	indent     int
+	preBlank   int
+	preIndent  int
+	postBlank  int
+	postIndent int
+}
+
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+func isXdlSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// getIndent returns the indent width of line, or -1 if it's blank
+func getIndent(line string) int {
+	ret := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if !isXdlSpace(c) {
+			return ret
+		} else if c == ' ' {
+			ret++
+		} else if c == '\t' {
+			ret += 8 - ret%8
+		}
+		if ret >= maxIndent {
+			return maxIndent
+		}
+	}
+	return -1
+}
+
+func measureSplit(f *xdFile, split int) splitMeasurement {
+	var m splitMeasurement
+	if split >= len(f.lines) {
+		m.endOfFile = true
+		m.indent = -1
+	} else {
+		m.indent = getIndent(f.lines[split])
+	}
+
+	m.preIndent = -1
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = getIndent(f.lines[i])
+		if m.preIndent != -1 {
+			break
+		}
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+
+	m.postIndent = -1
+	for i := split + 1; i < len(f.lines); i++ {
+		m.postIndent = getIndent(f.lines[i])
+		if m.postIndent != -1 {
+			break
+		}
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+
+	return m
+}
+
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+	totalBlank := m.preBlank + postBlank
+
+	s.penalty += totalBlankWeight * totalBlank
+	s.penalty += postBlankWeight * postBlank
+
+	indent := m.indent
+	if indent == -1 {
+		indent = m.postIndent
+	}
+	anyBlanks := totalBlank != 0
+
+	s.effectiveIndent += indent
+
+	if indent == -1 || m.preIndent == -1 || indent == m.preIndent {
+		// no adjustments needed
+	} else if indent > m.preIndent {
+		if anyBlanks {
+			s.penalty += relativeIndentWithBlankPenalty
+		} else {
+			s.penalty += relativeIndentPenalty
+		}
+	} else if m.postIndent != -1 && m.postIndent > indent {
+		if anyBlanks {
+			s.penalty += relativeOutdentWithBlankPenalty
+		} else {
+			s.penalty += relativeOutdentPenalty
+		}
+	} else {
+		if anyBlanks {
+			s.penalty += relativeDedentWithBlankPenalty
+		} else {
+			s.penalty += relativeDedentPenalty
+		}
+	}
+}
+
+func (s splitScore) cmp(other splitScore) int {
+	cmpIndents := 0
+	if s.effectiveIndent > other.effectiveIndent {
+		cmpIndents = 1
+	} else if s.effectiveIndent < other.effectiveIndent {
+		cmpIndents = -1
+	}
+	return indentWeight*cmpIndents + (s.penalty - other.penalty)
+}